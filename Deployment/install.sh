@@ -368,6 +368,14 @@ download_config_files() {
     else
         log_warn "Failed to download lbctl.service"
     fi
+
+    # Download tmpfiles.d snippet (state directories referenced by the unit)
+    if run curl -fsSL -o /etc/tmpfiles.d/lbctl.conf "${base_url}/lbctl.tmpfiles.conf"; then
+        run systemd-tmpfiles --create /etc/tmpfiles.d/lbctl.conf
+        log_info "Installed tmpfiles.d configuration"
+    else
+        log_warn "Failed to download lbctl.tmpfiles.conf"
+    fi
 }
 
 # ------------------------------------------------------------------------------
@@ -0,0 +1,86 @@
+// Package clock abstracts time so timing-dependent code (backoff, health
+// ticks, lock idle timeouts, cache TTLs) can be driven deterministically in tests.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	NewTimer(d time.Duration) Timer
+	Sleep(d time.Duration)
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Real returns a Clock backed by the time package
+func Real() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time        { return time.Now() }
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{t: time.NewTicker(d)}
+}
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{t: time.NewTimer(d)}
+}
+
+// AfterFunc calls f in its own goroutine once c has advanced by d, like
+// time.AfterFunc. The returned Timer has no channel; Stop cancels the call if
+// it hasn't started.
+func AfterFunc(c Clock, d time.Duration, f func()) Timer {
+	if _, ok := c.(realClock); ok {
+		return realTimer{t: time.AfterFunc(d, f)}
+	}
+	t := &funcTimer{t: c.NewTimer(d), stop: make(chan struct{})}
+	go func() {
+		select {
+		case <-t.t.C():
+			f()
+		case <-t.stop:
+		}
+	}()
+	return t
+}
+
+// funcTimer runs AfterFunc on a Clock other than the real one
+type funcTimer struct {
+	t    Timer
+	stop chan struct{}
+	once sync.Once
+}
+
+func (t *funcTimer) C() <-chan time.Time { return nil }
+
+func (t *funcTimer) Stop() bool {
+	if !t.t.Stop() {
+		return false
+	}
+	t.once.Do(func() { close(t.stop) })
+	return true
+}
+
+type realTicker struct{ t *time.Ticker }
+
+func (rt realTicker) C() <-chan time.Time { return rt.t.C }
+func (rt realTicker) Stop()               { rt.t.Stop() }
+
+type realTimer struct{ t *time.Timer }
+
+func (rt realTimer) C() <-chan time.Time { return rt.t.C }
+func (rt realTimer) Stop() bool          { return rt.t.Stop() }
@@ -0,0 +1,109 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeTimerFiresOnAdvance(t *testing.T) {
+	start := time.Unix(100, 0)
+	f := NewFake(start)
+	timer := f.NewTimer(time.Second)
+
+	f.Advance(999 * time.Millisecond)
+	select {
+	case <-timer.C():
+		t.Fatalf("timer fired early")
+	default:
+	}
+
+	f.Advance(time.Millisecond)
+	select {
+	case at := <-timer.C():
+		if !at.Equal(start.Add(time.Second)) {
+			t.Fatalf("timer fired at %s, want %s", at, start.Add(time.Second))
+		}
+	default:
+		t.Fatalf("timer did not fire")
+	}
+	if f.Waiters() != 0 {
+		t.Fatalf("expected fired timer to be removed, got %d waiters", f.Waiters())
+	}
+	if timer.Stop() {
+		t.Fatalf("Stop() on a fired timer should report false")
+	}
+}
+
+func TestFakeTickerDropsMissedTicks(t *testing.T) {
+	f := NewFake(time.Unix(0, 0))
+	ticker := f.NewTicker(time.Second)
+
+	// Like time.Ticker, only one tick is buffered however far the clock moves
+	f.Advance(5 * time.Second)
+	<-ticker.C()
+	select {
+	case <-ticker.C():
+		t.Fatalf("expected missed ticks to be dropped")
+	default:
+	}
+
+	f.Advance(time.Second)
+	select {
+	case <-ticker.C():
+	default:
+		t.Fatalf("ticker did not fire after draining")
+	}
+
+	ticker.Stop()
+	f.Advance(time.Hour)
+	select {
+	case <-ticker.C():
+		t.Fatalf("stopped ticker fired")
+	default:
+	}
+}
+
+func TestFakeSleepAndSet(t *testing.T) {
+	start := time.Unix(0, 0)
+	f := NewFake(start)
+	timer := f.NewTimer(time.Minute)
+
+	f.Sleep(30 * time.Second)
+	if got := f.Now(); !got.Equal(start.Add(30 * time.Second)) {
+		t.Fatalf("Now() after Sleep = %s", got)
+	}
+
+	f.Set(start.Add(2 * time.Minute))
+	select {
+	case <-timer.C():
+	default:
+		t.Fatalf("Set() past the deadline should fire the timer")
+	}
+
+	f.Set(start)
+	if got := f.Now(); !got.Equal(start) {
+		t.Fatalf("Now() after Set backwards = %s", got)
+	}
+}
+
+func TestAfterFuncOnFake(t *testing.T) {
+	f := NewFake(time.Unix(0, 0))
+	fired := make(chan struct{})
+	AfterFunc(f, time.Second, func() { close(fired) })
+
+	f.Advance(time.Second)
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("expected f to run once the clock advanced")
+	}
+
+	stopped := AfterFunc(f, time.Second, func() { t.Error("stopped AfterFunc ran") })
+	if !stopped.Stop() {
+		t.Fatal("expected Stop to cancel a pending call")
+	}
+	if stopped.Stop() {
+		t.Fatal("expected a second Stop to report false")
+	}
+	f.Advance(time.Hour)
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BundleMainFile is the main config file in a tar config bundle
+const BundleMainFile = "config.yaml"
+
+// ParseBundle parses a complete config delivered as one blob, in either of
+// two forms, optionally gzip-compressed:
+//
+//   - One YAML document holding the globals of the main config file and its
+//     services together. It has nowhere to read other files from, so it
+//     can't set include or vars.
+//   - A tar archive laid out like /etc/lbctl: config.yaml at the top and the
+//     vars and service files it names, relative to it.
+func ParseBundle(data []byte) (*Config, error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid config bundle: %w", err)
+		}
+		data, err = io.ReadAll(io.LimitReader(zr, maxBundleSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("invalid config bundle: %w", err)
+		}
+		if len(data) > maxBundleSize {
+			return nil, fmt.Errorf("config bundle exceeds %d bytes unpacked", maxBundleSize)
+		}
+	}
+	if isTar(data) {
+		return parseTarBundle(data)
+	}
+
+	resolved, err := ResolveEnvVars(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve env vars: %w", err)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(resolved, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config YAML: %w", err)
+	}
+	if cfg.Include != "" || cfg.Vars != "" {
+		return nil, fmt.Errorf("a single-document config bundle must not set include or vars")
+	}
+	for i := range cfg.Services {
+		if err := ExpandPools(&cfg.Services[i]); err != nil {
+			return nil, err
+		}
+	}
+	return &cfg, nil
+}
+
+// isTar reports whether data starts with a POSIX tar header
+func isTar(data []byte) bool {
+	return len(data) >= 512 && bytes.HasPrefix(data[257:], []byte("ustar"))
+}
+
+// parseTarBundle loads a tar config bundle with the rules of LoadConfig:
+// the main file holds globals only and each included file services only.
+// The returned config names no files; its vars and services are already
+// loaded.
+func parseTarBundle(data []byte) (*Config, error) {
+	files := make(map[string][]byte)
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid config bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config bundle: %w", err)
+		}
+		files[path.Clean(strings.TrimPrefix(hdr.Name, "/"))] = b
+	}
+
+	main, ok := files[BundleMainFile]
+	if !ok {
+		return nil, fmt.Errorf("config bundle has no %s", BundleMainFile)
+	}
+	cfg, vars, err := parseMainConfig(main, func(name string) ([]byte, error) {
+		if path.IsAbs(name) {
+			return nil, fmt.Errorf("a config bundle must name its vars file relative to %s", BundleMainFile)
+		}
+		b, ok := files[path.Clean(name)]
+		if !ok {
+			return nil, fmt.Errorf("config bundle has no %s", name)
+		}
+		return b, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	cfg.Vars = "" // Already resolved, like the includes
+	if cfg.Include == "" {
+		return cfg, nil
+	}
+	if path.IsAbs(cfg.Include) {
+		return nil, fmt.Errorf("a config bundle must include files relative to %s", BundleMainFile)
+	}
+	pattern := path.Clean(cfg.Include)
+	var names []string
+	for name := range files {
+		if ok, err := path.Match(pattern, name); err != nil {
+			return nil, fmt.Errorf("failed to match include pattern: %w", err)
+		} else if ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names) // Alphabetical order, as LoadConfig
+	for _, name := range names {
+		if err := parseServiceConfig(files[name], cfg, vars, true); err != nil {
+			return nil, fmt.Errorf("failed to load service config %s: %w", name, err)
+		}
+	}
+	cfg.Include = ""
+	return cfg, nil
+}
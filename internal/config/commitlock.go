@@ -0,0 +1,44 @@
+//go:build !windows
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// LockCommit takes the commit lock of the include directory dir, waiting up
+// to commitLockWait for another writer, and returns the function that
+// releases it. The lock file is never removed, so every writer locks the
+// same inode.
+func LockCommit(dir string) (func(), error) {
+	f, err := os.OpenFile(filepath.Join(dir, CommitLockFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open commit lock: %w", err)
+	}
+	deadline := time.Now().Add(commitLockWait)
+	for {
+		err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, unix.EWOULDBLOCK) {
+			_ = f.Close()
+			return nil, fmt.Errorf("failed to take commit lock: %w", err)
+		}
+		if !time.Now().Before(deadline) {
+			_ = f.Close()
+			return nil, ErrCommitInProgress
+		}
+		time.Sleep(commitLockPoll)
+	}
+	return func() {
+		_ = unix.Flock(int(f.Fd()), unix.LOCK_UN)
+		_ = f.Close()
+	}, nil
+}
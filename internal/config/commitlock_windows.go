@@ -0,0 +1,9 @@
+//go:build windows
+
+package config
+
+// LockCommit is a no-op on Windows, which has no flock; shell sessions
+// still serialize commits with their own lock.
+func LockCommit(dir string) (func(), error) {
+	return func() {}, nil
+}
@@ -1,10 +1,24 @@
 package config
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
 )
 
 func TestResolveEnvVars(t *testing.T) {
@@ -156,6 +170,70 @@ services:
 	}
 }
 
+func TestLoadConfigVarsFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	mainPath := filepath.Join(tmpDir, "config.yaml")
+	main := "mode: dr\nvars: node.vars\ninclude: conf.d/*.yaml\nnode:\n  name: ${NODE_NAME}\nnetwork:\n  frontend:\n    vip: ${VIP}\n    cidr: ${CIDR}\n"
+	if err := os.WriteFile(mainPath, []byte(main), 0644); err != nil {
+		t.Fatal(err)
+	}
+	vars := "# Per-node values\nNODE_NAME=lb-a\nVIP = \"192.0.2.10\"\n\nCIDR=24\nBACKEND_PORT='8080'\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "node.vars"), []byte(vars), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(tmpDir, "conf.d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	svc := "services:\n  - name: web\n    protocol: tcp\n    ports: [80]\n    backends:\n      - address: 10.0.0.1\n        port: ${BACKEND_PORT}\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "conf.d", "web.yaml"), []byte(svc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The environment takes precedence over the vars file
+	t.Setenv("NODE_NAME", "lb-env")
+	cfg, err := LoadConfig(mainPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.Node.Name != "lb-env" || cfg.Network.Frontend.VIP != "192.0.2.10" || cfg.Network.Frontend.CIDR != 24 {
+		t.Fatalf("unexpected globals %+v %+v", cfg.Node, cfg.Network.Frontend)
+	}
+	if got := cfg.Services[0].Backends[0].Port; got != 8080 {
+		t.Fatalf("expected the included file to resolve vars, got port %d", got)
+	}
+
+	// A reference neither defines is still an error
+	if err := os.WriteFile(filepath.Join(tmpDir, "node.vars"), []byte("NODE_NAME=lb-a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(mainPath); err == nil || !strings.Contains(err.Error(), "VIP") {
+		t.Fatalf("expected VIP to be missing, got %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(tmpDir, "node.vars")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(mainPath); err == nil || !strings.Contains(err.Error(), "vars file") {
+		t.Fatalf("expected a missing vars file to fail, got %v", err)
+	}
+}
+
+func TestParseVars(t *testing.T) {
+	vars, err := ParseVars([]byte("A=1\n# comment\nB=\"two words\"\nC=\nD=x=y\n"))
+	if err != nil {
+		t.Fatalf("ParseVars() error = %v", err)
+	}
+	want := Vars{"A": "1", "B": "two words", "C": "", "D": "x=y"}
+	if !reflect.DeepEqual(vars, want) {
+		t.Fatalf("ParseVars() = %v, want %v", vars, want)
+	}
+	for _, bad := range []string{"novalue", "lower=1", "A B=1"} {
+		if _, err := ParseVars([]byte(bad)); err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Errorf("ParseVars(%q): expected a line error, got %v", bad, err)
+		}
+	}
+}
+
 func TestLoadConfigEnvResolutionNumeric(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -339,6 +417,322 @@ services:
 	}
 }
 
+func TestLoadConfigExpandsPools(t *testing.T) {
+	tmpDir := t.TempDir()
+	mainPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(mainPath, []byte("mode: dr\ninclude: \"conf.d/*.yaml\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(tmpDir, "conf.d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := `
+services:
+  - name: rack
+    protocol: tcp
+    ports: [80]
+    scheduler: wrr
+    backends:
+      - address: 10.0.1.100
+        port: 80
+        weight: 5
+    pools:
+      - cidr: 10.0.0.0/29
+        port: 80
+        weight: 10
+        exclude: [10.0.0.3]
+        overrides:
+          - address: 10.0.0.5
+            weight: 1
+          - address: 10.0.0.6
+            weight: 1
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "conf.d", "rack.yaml"), []byte(svc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(mainPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	got := cfg.Services[0].Backends
+	want := []Backend{
+		{Address: "10.0.1.100", Port: 80, Weight: 5},
+		{Address: "10.0.0.1", Port: 80, Weight: 10},
+		{Address: "10.0.0.2", Port: 80, Weight: 10},
+		{Address: "10.0.0.4", Port: 80, Weight: 10},
+		{Address: "10.0.0.5", Port: 80, Weight: 1},
+		{Address: "10.0.0.6", Port: 80, Weight: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expanded backends = %+v, want %+v", got, want)
+	}
+	if cfg.Services[0].Pools != nil {
+		t.Fatalf("expected pools to be cleared after expansion")
+	}
+}
+
+func TestConfigSources(t *testing.T) {
+	bundle := `
+mode: dr
+node:
+  name: bundle-node
+services:
+  - name: web
+    protocol: tcp
+    ports: [80]
+    scheduler: wrr
+    pools:
+      - cidr: 10.0.0.0/30
+        port: 8080
+        weight: 2
+`
+	want := []Backend{
+		{Address: "10.0.0.1", Port: 8080, Weight: 2},
+		{Address: "10.0.0.2", Port: 8080, Weight: 2},
+	}
+
+	t.Run("bundle", func(t *testing.T) {
+		cfg, err := BundleSource{Name: "test", Data: []byte(bundle)}.Load()
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if cfg.Node.Name != "bundle-node" || len(cfg.Services) != 1 {
+			t.Fatalf("unexpected config %+v", cfg)
+		}
+		if !reflect.DeepEqual(cfg.Services[0].Backends, want) {
+			t.Fatalf("expanded backends = %+v, want %+v", cfg.Services[0].Backends, want)
+		}
+	})
+
+	t.Run("bundle rejects include", func(t *testing.T) {
+		_, err := ParseBundle([]byte("mode: dr\ninclude: conf.d/*.yaml\n"))
+		if err == nil || !strings.Contains(err.Error(), "include") {
+			t.Fatalf("expected include to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte("mode: dr\nnode:\n  name: file-node\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		src := FileSource{Path: path}
+		cfg, err := src.Load()
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if cfg.Node.Name != "file-node" {
+			t.Fatalf("node name = %q, want file-node", cfg.Node.Name)
+		}
+		if src.String() != "file "+path {
+			t.Fatalf("String() = %q", src.String())
+		}
+	})
+
+	t.Run("http", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(bundle))
+		}))
+		defer srv.Close()
+
+		cfg, err := HTTPSource{URL: srv.URL, Token: "secret"}.Load()
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if !reflect.DeepEqual(cfg.Services[0].Backends, want) {
+			t.Fatalf("expanded backends = %+v, want %+v", cfg.Services[0].Backends, want)
+		}
+		if _, err := (HTTPSource{URL: srv.URL}).Load(); err == nil || !strings.Contains(err.Error(), "401") {
+			t.Fatalf("expected an unauthorized fetch to fail, got %v", err)
+		}
+	})
+}
+
+func TestParseTarBundle(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for _, f := range []struct{ name, body string }{
+		{"config.yaml", "mode: dr\nnode:\n  name: ${NODE}\ninclude: config.d/*.yaml\nvars: node.vars\n"},
+		{"node.vars", "NODE=fleet-node\n"},
+		{"config.d/b.yaml", "services:\n  - name: api\n    protocol: tcp\n    ports: [8080]\n"},
+		{"config.d/a.yaml", "services:\n  - name: web\n    protocol: tcp\n    ports: [80]\n"},
+		{"README", "not config"},
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := ParseBundle(buf.Bytes())
+	if err != nil {
+		t.Fatalf("ParseBundle() error = %v", err)
+	}
+	if cfg.Node.Name != "fleet-node" || cfg.Include != "" || cfg.Vars != "" {
+		t.Fatalf("unexpected globals %+v", cfg)
+	}
+	if len(cfg.Services) != 2 || cfg.Services[0].Name != "web" || cfg.Services[1].Name != "api" {
+		t.Fatalf("expected web then api, got %+v", cfg.Services)
+	}
+}
+
+// minisignKey returns a minisign key pair in the format of its .pub file
+func minisignKey(t *testing.T, id [8]byte) (ed25519.PrivateKey, string) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := append(append([]byte("Ed"), id[:]...), pub...)
+	return priv, "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(raw) + "\n"
+}
+
+// minisign signs data like minisign -S, or minisign -S -l when legacy
+func minisign(priv ed25519.PrivateKey, id [8]byte, data []byte, legacy bool) []byte {
+	alg, signed := "ED", data
+	if legacy {
+		alg = "Ed"
+	} else {
+		digest := blake2b.Sum512(data)
+		signed = digest[:]
+	}
+	sig := ed25519.Sign(priv, signed)
+	trusted := "timestamp:1700000000\tfile:bundle.yaml"
+	global := ed25519.Sign(priv, append(append([]byte{}, sig...), trusted...))
+	raw := append(append([]byte(alg), id[:]...), sig...)
+	return []byte("untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(raw) + "\n" +
+		"trusted comment: " + trusted + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n")
+}
+
+// The prehash minisign's ED signatures are made over
+func TestMinisignPrehash(t *testing.T) {
+	long := make([]byte, 256) // Two full blocks
+	for i := range long {
+		long[i] = byte(i)
+	}
+	for _, tc := range []struct {
+		in   []byte
+		want string
+	}{
+		{nil, "786a02f742015903c6c6fd852552d272912f4740e15847618a86e217f71f5419d25e1031afee585313896444934eb04b903a685b1448b755d56f701afe9be2ce"},
+		{[]byte("abc"), "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923"},
+		{long, "1ecc896f34d3f9cac484c73f75f6a5fb58ee6784be41b35f46067b9c65c63a6794d3d744112c653f73dd7deb6666204c5a9bfa5b46081fc10fdbe7884fa5cbf8"},
+	} {
+		if got := blake2b.Sum512(tc.in); hex.EncodeToString(got[:]) != tc.want {
+			t.Errorf("blake2b.Sum512(%d bytes) = %x, want %s", len(tc.in), got, tc.want)
+		}
+	}
+}
+
+func TestSignedHTTPSource(t *testing.T) {
+	id := [8]byte{1, 2, 3, 4, 5, 6, 7, 8}
+	priv, pubFile := minisignKey(t, id)
+	key, err := ParsePublicKey(pubFile)
+	if err != nil {
+		t.Fatalf("ParsePublicKey() error = %v", err)
+	}
+	if key.ID() != "0807060504030201" {
+		t.Fatalf("ID() = %s", key.ID())
+	}
+
+	bundle := []byte("mode: dr\nnode:\n  name: signed-node\n")
+	var sig []byte
+	served := bundle
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bundle.yaml":
+			_, _ = w.Write(served)
+		case "/bundle.yaml.minisig":
+			_, _ = w.Write(sig)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	src := HTTPSource{URL: srv.URL + "/bundle.yaml", PublicKey: key}
+
+	for _, legacy := range []bool{false, true} {
+		sig = minisign(priv, id, bundle, legacy)
+		cfg, err := src.Load()
+		if err != nil {
+			t.Fatalf("legacy=%v: Load() error = %v", legacy, err)
+		}
+		if cfg.Node.Name != "signed-node" {
+			t.Fatalf("legacy=%v: unexpected config %+v", legacy, cfg)
+		}
+	}
+
+	// A bundle changed after signing is rejected
+	served = []byte("mode: dr\nnode:\n  name: evil-node\n")
+	if _, err := src.Load(); err == nil || !strings.Contains(err.Error(), "verification failed") {
+		t.Fatalf("expected a tampered bundle to be rejected, got %v", err)
+	}
+	served = bundle
+
+	// So is one signed by another key
+	other, _ := minisignKey(t, [8]byte{9})
+	sig = minisign(other, [8]byte{9}, bundle, false)
+	if _, err := src.Load(); err == nil || !strings.Contains(err.Error(), "not 0807060504030201") {
+		t.Fatalf("expected a bundle signed by another key to be rejected, got %v", err)
+	}
+	sig = minisign(other, id, bundle, false)
+	if _, err := src.Load(); err == nil {
+		t.Fatal("expected a forged key ID to be rejected")
+	}
+
+	// And one without a signature
+	src.SignatureURL = srv.URL + "/missing.minisig"
+	if _, err := src.Load(); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("expected a missing signature to be rejected, got %v", err)
+	}
+}
+
+func TestExpandPoolsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		svc  Service
+	}{
+		{"invalid cidr", Service{Pools: []BackendPool{{CIDR: "10.0.0.0/33", Weight: 1}}}},
+		{"too large", Service{Pools: []BackendPool{{CIDR: "10.0.0.0/16", Weight: 1}}}},
+		{"exclude outside cidr", Service{Pools: []BackendPool{{CIDR: "10.0.0.0/30", Weight: 1, Exclude: []string{"10.0.1.1"}}}}},
+		{"override excluded", Service{Pools: []BackendPool{{CIDR: "10.0.0.0/30", Weight: 1, Exclude: []string{"10.0.0.1"}, Overrides: []PoolOverride{{Address: "10.0.0.1", Weight: 2}}}}}},
+		{"override zero weight", Service{Pools: []BackendPool{{CIDR: "10.0.0.0/30", Weight: 1, Overrides: []PoolOverride{{Address: "10.0.0.1"}}}}}},
+		{"duplicate backend", Service{Backends: []Backend{{Address: "10.0.0.1", Weight: 1}}, Pools: []BackendPool{{CIDR: "10.0.0.0/30", Weight: 1}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ExpandPools(&tt.svc); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+
+	svc := Service{Pools: []BackendPool{{CIDR: "fd00::/126", Port: 53, Weight: 1}}}
+	if err := ExpandPools(&svc); err != nil {
+		t.Fatalf("ExpandPools(v6) error = %v", err)
+	}
+	if len(svc.Backends) != 4 || svc.Backends[0].Address != "fd00::" || svc.Backends[3].Address != "fd00::3" {
+		t.Fatalf("unexpected v6 expansion: %+v", svc.Backends)
+	}
+}
+
 func TestLoadConfigRejectsMainServices(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -517,6 +911,44 @@ func TestValidate(t *testing.T) {
 			config:  validConfig,
 			wantErr: false,
 		},
+		{
+			name: "frr vrrp state source",
+			config: func() *Config {
+				c := *validConfig
+				c.VRRP.StateSource = VRRPStateSourceFRR
+				return &c
+			}(),
+			wantErr: false,
+		},
+		{
+			name: "unknown vrrp state source",
+			config: func() *Config {
+				c := *validConfig
+				c.VRRP.StateSource = "keepalived"
+				return &c
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "tcp+udp service",
+			config: func() *Config {
+				c := *validConfig
+				c.Services = []Service{validConfig.Services[0]}
+				c.Services[0].Protocol = "tcp+udp"
+				return &c
+			}(),
+			wantErr: false,
+		},
+		{
+			name: "unknown protocol",
+			config: func() *Config {
+				c := *validConfig
+				c.Services = []Service{validConfig.Services[0]}
+				c.Services[0].Protocol = "sctp"
+				return &c
+			}(),
+			wantErr: true,
+		},
 		{
 			name: "invalid node name",
 			config: &Config{
@@ -577,9 +1009,9 @@ func TestValidate(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name: "invalid scheduler",
+			name: "tun mode",
 			config: &Config{
-				Mode: "dr",
+				Mode: "tun",
 				Node: NodeConfig{Name: "node", Role: "primary"},
 				Network: NetworkConfig{
 					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
@@ -591,18 +1023,18 @@ func TestValidate(t *testing.T) {
 						Name:      "svc",
 						Protocol:  "tcp",
 						Ports:     []int{80},
-						Scheduler: "lc",
+						Scheduler: "rr",
 						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}},
 						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
 					},
 				},
 			},
-			wantErr: true,
+			wantErr: false,
 		},
 		{
-			name: "invalid port range",
+			name: "invalid mode",
 			config: &Config{
-				Mode: "dr",
+				Mode: "bridge",
 				Node: NodeConfig{Name: "node", Role: "primary"},
 				Network: NetworkConfig{
 					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
@@ -611,19 +1043,19 @@ func TestValidate(t *testing.T) {
 				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
 				Services: []Service{
 					{
-						Name:       "svc",
-						Protocol:   "tcp",
-						PortRanges: []PortRange{{Start: 100, End: 50}},
-						Scheduler:  "rr",
-						Backends:   []Backend{{Address: "10.0.0.1", Port: 0, Weight: 1}},
-						Health:     HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
+						Name:      "svc",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "rr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
 					},
 				},
 			},
 			wantErr: true,
 		},
 		{
-			name: "invalid backend weight",
+			name: "invalid scheduler",
 			config: &Config{
 				Mode: "dr",
 				Node: NodeConfig{Name: "node", Role: "primary"},
@@ -637,8 +1069,8 @@ func TestValidate(t *testing.T) {
 						Name:      "svc",
 						Protocol:  "tcp",
 						Ports:     []int{80},
-						Scheduler: "rr",
-						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 0}},
+						Scheduler: "fifo",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}},
 						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
 					},
 				},
@@ -646,7 +1078,7 @@ func TestValidate(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name: "invalid health type",
+			name: "least-connection scheduler",
 			config: &Config{
 				Mode: "dr",
 				Node: NodeConfig{Name: "node", Role: "primary"},
@@ -660,61 +1092,1804 @@ func TestValidate(t *testing.T) {
 						Name:      "svc",
 						Protocol:  "tcp",
 						Ports:     []int{80},
-						Scheduler: "rr",
+						Scheduler: "wlc",
 						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}},
-						Health:    HealthCheck{Enabled: true, Type: "http", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
 					},
 				},
 			},
-			wantErr: true,
+			wantErr: false,
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := Validate(tt.config)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
-			}
-		})
-	}
-}
-
-func TestValidate_DaemonDefaultsAndBounds(t *testing.T) {
-	base := &Config{
-		Mode: "dr",
-		Node: NodeConfig{Name: "node", Role: "primary"},
-		Network: NetworkConfig{
-			Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
-			Backend:  InterfaceConfig{Interface: "eth1"},
+		{
+			name: "sh scheduler with flags",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:           "svc",
+						Protocol:       "tcp",
+						Ports:          []int{80},
+						Scheduler:      "sh",
+						Backends:       []Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}},
+						Health:         HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
+						SchedulerFlags: []string{"sh-fallback", "sh-port"},
+					},
+				},
+			},
+			wantErr: false,
 		},
-		VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
-	}
-
-	t.Run("defaults reconcile interval when unset", func(t *testing.T) {
-		cfg := *base
-		if err := Validate(&cfg); err != nil {
-			t.Fatalf("Validate() error = %v", err)
-		}
-		if cfg.Daemon.ReconcileIntervalMS != 1000 {
-			t.Fatalf("expected default reconcile_interval_ms=1000, got %d", cfg.Daemon.ReconcileIntervalMS)
-		}
-	})
-
-	t.Run("rejects reconcile interval below minimum", func(t *testing.T) {
-		cfg := *base
-		cfg.Daemon.ReconcileIntervalMS = 99
-		if err := Validate(&cfg); err == nil {
-			t.Fatalf("expected error")
-		}
-	})
-
-	t.Run("defaults state_cache.ttl_ms when enabled and unset", func(t *testing.T) {
-		cfg := *base
-		cfg.Daemon.StateCache.Enabled = true
-		cfg.Daemon.StateCache.TTLMS = 0
-		if err := Validate(&cfg); err != nil {
-			t.Fatalf("Validate() error = %v", err)
+		{
+			name: "scheduler flag on wrong scheduler",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:           "svc",
+						Protocol:       "tcp",
+						Ports:          []int{80},
+						Scheduler:      "rr",
+						Backends:       []Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}},
+						Health:         HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
+						SchedulerFlags: []string{"sh-port"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown scheduler flag",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:           "svc",
+						Protocol:       "tcp",
+						Ports:          []int{80},
+						Scheduler:      "sh",
+						Backends:       []Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}},
+						Health:         HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
+						SchedulerFlags: []string{"sh-sticky"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "persistence with netmask",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:               "svc",
+						Protocol:           "tcp",
+						Ports:              []int{80},
+						Scheduler:          "wlc",
+						Backends:           []Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}},
+						Health:             HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
+						PersistenceTimeout: 300, PersistenceNetmask: "255.255.255.0",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "persistence netmask without timeout",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:               "svc",
+						Protocol:           "tcp",
+						Ports:              []int{80},
+						Scheduler:          "wlc",
+						Backends:           []Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}},
+						Health:             HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
+						PersistenceNetmask: "255.255.255.0",
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "non-contiguous persistence netmask",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:               "svc",
+						Protocol:           "tcp",
+						Ports:              []int{80},
+						Scheduler:          "wlc",
+						Backends:           []Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}},
+						Health:             HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
+						PersistenceTimeout: 300, PersistenceNetmask: "255.0.255.0",
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative persistence timeout",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:               "svc",
+						Protocol:           "tcp",
+						Ports:              []int{80},
+						Scheduler:          "wlc",
+						Backends:           []Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}},
+						Health:             HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
+						PersistenceTimeout: -1,
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate scheduler flag",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:           "svc",
+						Protocol:       "tcp",
+						Ports:          []int{80},
+						Scheduler:      "sh",
+						Backends:       []Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}},
+						Health:         HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
+						SchedulerFlags: []string{"sh-port", "SH-PORT"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid port range",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:       "svc",
+						Protocol:   "tcp",
+						PortRanges: []PortRange{{Start: 100, End: 50}},
+						Scheduler:  "rr",
+						Backends:   []Backend{{Address: "10.0.0.1", Port: 0, Weight: 1}},
+						Health:     HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid backend weight",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "svc",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "rr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 0}},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid health type",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "svc",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "rr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}},
+						Health:    HealthCheck{Enabled: true, Type: "http", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "udp health check",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "dns",
+						Protocol:  "udp",
+						Ports:     []int{53},
+						Scheduler: "rr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 53, Weight: 1}},
+						Health:    HealthCheck{Enabled: true, Type: "udp", Port: 53, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2, Payload: "ping", Expect: "pong"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "payload on tcp health check",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "svc",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "rr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2, Payload: "ping"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "dns health check",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "dns",
+						Protocol:  "udp",
+						Ports:     []int{53},
+						Scheduler: "rr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 53, Weight: 1}},
+						Health:    HealthCheck{Enabled: true, Type: "dns", Port: 53, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2, QueryName: "example.com", QueryType: "SRV", ExpectRcode: "NXDOMAIN"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "dns health check without query name",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "dns",
+						Protocol:  "udp",
+						Ports:     []int{53},
+						Scheduler: "rr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 53, Weight: 1}},
+						Health:    HealthCheck{Enabled: true, Type: "dns", Port: 53, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "dns health check with unsupported query type",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "dns",
+						Protocol:  "udp",
+						Ports:     []int{53},
+						Scheduler: "rr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 53, Weight: 1}},
+						Health:    HealthCheck{Enabled: true, Type: "dns", Port: 53, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2, QueryName: "example.com", QueryType: "MX"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "health slow start",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2, SlowStartMS: 30000, SlowStartSteps: 5},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "health slow start steps shorter than interval",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2, SlowStartMS: 2000, SlowStartSteps: 4},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "health min_healthy with last_healthy fallback",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}, {Address: "10.0.0.2", Port: 80, Weight: 10}},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2, MinHealthy: 2, Fallback: "last_healthy"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "health min_healthy above backend count",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}, {Address: "10.0.0.2", Port: 80, Weight: 10}},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2, MinHealthy: 3, Fallback: "all"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "health unknown fallback",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}, {Address: "10.0.0.2", Port: 80, Weight: 10}},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2, MinHealthy: 1, Fallback: "drain"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "backend check address and port override",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10, CheckAddress: "10.0.1.1", CheckPort: 9901}},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "backend forward override",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}, {Address: "10.1.0.1", Port: 80, Weight: 10, Forward: "tun"}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "backend invalid forward",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10, Forward: "gre"}},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "backend connection thresholds",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10, UpperThreshold: 1000, LowerThreshold: 800}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "backend lower threshold without upper",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10, LowerThreshold: 800}},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "backend lower threshold above upper",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10, UpperThreshold: 500, LowerThreshold: 800}},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "backend invalid check address",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10, CheckAddress: "sidecar_1"}},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "backend invalid check port",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10, CheckPort: 70000}},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "health composite probes",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2, Probes: []HealthProbe{{Type: "tcp", Port: 8081}, {Type: "dns", QueryName: "ready.example"}}, Combine: "any"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "health probe invalid type",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2, Probes: []HealthProbe{{Type: "icmp"}}},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "health probe dns without query",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2, Probes: []HealthProbe{{Type: "dns", Port: 53}}},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "health invalid combine",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2, Probes: []HealthProbe{{Type: "tcp", Port: 8081}}, Combine: "xor"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "service observability overrides",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:          "web",
+						Protocol:      "tcp",
+						Ports:         []int{80},
+						Scheduler:     "wrr",
+						Backends:      []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+						Observability: ServiceObservability{DisableBackendMetrics: true, HealthLogLevel: "warn"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "service invalid health log level",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:          "web",
+						Protocol:      "tcp",
+						Ports:         []int{80},
+						Scheduler:     "wrr",
+						Backends:      []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+						Observability: ServiceObservability{HealthLogLevel: "verbose"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative metrics series budget",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:          VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Observability: ObsConfig{Metrics: MetricsConfig{MaxSeriesPerMetric: -1}},
+				Services: []Service{
+					{
+						Name:          "web",
+						Protocol:      "tcp",
+						Ports:         []int{80},
+						Scheduler:     "wrr",
+						Backends:      []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+						Observability: ServiceObservability{},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "metrics cluster and peer labels",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:          VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Observability: ObsConfig{Metrics: MetricsConfig{Cluster: "edge-lb", Peer: "lb-a"}},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "metrics peer without cluster",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:          VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Observability: ObsConfig{Metrics: MetricsConfig{Peer: "lb-a"}},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid vrrp peer address",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000, PeerAddress: "lb-b.example"},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid connection log sample rate",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:          VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Observability: ObsConfig{Logging: LoggingConfig{Connections: ConnLogConfig{Enabled: true, SampleRate: 1.5}}},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "connection log client prefix 0",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:          VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Observability: ObsConfig{Logging: LoggingConfig{Connections: ConnLogConfig{Enabled: true, ClientPrefixV4: intPtr(0), ClientPrefixV6: intPtr(0)}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "connection log client prefix v6 too long",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:          VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Observability: ObsConfig{Logging: LoggingConfig{Connections: ConnLogConfig{Enabled: true, ClientPrefixV6: intPtr(129)}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ipfix export",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:          VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Observability: ObsConfig{Metrics: MetricsConfig{IPFIX: IPFIXConfig{Enabled: true, Collector: "192.0.2.50:4739", SampleRate: 0.1, CacheSize: 512}}},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative service quota",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+						Quota:     ServiceQuota{BytesPerSecond: -1},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "tcp reuse with keepalive",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2, TCPMode: "reuse", TCPKeepaliveMS: 5000, TCPReset: true},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "tcp keepalive without reuse",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2, TCPKeepaliveMS: 5000},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid tcp mode",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2, TCPMode: "syn"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "tcp options on udp check",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+						Health:    HealthCheck{Enabled: true, Type: "udp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2, TCPReset: true},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "mysql user on tcp check",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{
+						Name:      "db",
+						Protocol:  "tcp",
+						Ports:     []int{3306},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 3306, Weight: 10}},
+						Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 3306, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2, MySQLUser: "lbctl"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative drain threshold",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{Drain: DrainConfig{Enabled: true, Threshold: -1}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "health source port range",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{Health: DaemonHealthConfig{SourcePortMin: 40000, SourcePortMax: 40999, ReuseAddr: true}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "health source port range below 1024",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{Health: DaemonHealthConfig{SourcePortMin: 80, SourcePortMax: 2000}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "health source port range reversed",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{Health: DaemonHealthConfig{SourcePortMin: 50000, SourcePortMax: 40000}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative failover budget",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{FailoverBudgetMS: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative auto_reload debounce",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{AutoReload: AutoReloadConfig{Enabled: true, DebounceMS: -1}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "health standby_mode reduced",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{Health: DaemonHealthConfig{StandbyMode: "reduced"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "health standby_mode invalid",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{Health: DaemonHealthConfig{StandbyMode: "sometimes"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "startup_policy adopt",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{StartupPolicy: "adopt"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "startup_policy invalid",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{StartupPolicy: "wipe"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "shutdown_policy drain",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{ShutdownPolicy: "drain", ShutdownDrainTimeoutMS: 60000},
+			},
+			wantErr: false,
+		},
+		{
+			name: "shutdown_policy invalid",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{ShutdownPolicy: "flush"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "shutdown_drain_timeout_ms too large",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{ShutdownPolicy: "drain", ShutdownDrainTimeoutMS: 3_600_000},
+			},
+			wantErr: true,
+		},
+		{
+			name: "health reuse_addr without source port range",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{Health: DaemonHealthConfig{ReuseAddr: true}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "service on additional vip",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", VIPs: []string{"192.168.1.2"}, CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{Name: "web", VIP: "192.168.1.2", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr", Backends: []Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "service vip not a frontend vip",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", VIPs: []string{"192.168.1.2"}, CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{Name: "web", VIP: "192.168.1.3", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr", Backends: []Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid additional vip",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", VIPs: []string{"not-an-ip"}, CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{Name: "web", VIP: "", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr", Backends: []Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate frontend vip",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", VIPs: []string{"192.168.1.1"}, CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Services: []Service{
+					{Name: "web", VIP: "", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr", Backends: []Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "conn sync",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{ConnSync: ConnSyncConfig{Enabled: true, Interface: "eth1", SyncID: 50}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "conn sync without interface",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{ConnSync: ConnSyncConfig{Enabled: true, SyncID: 50}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "conn sync id out of range",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{ConnSync: ConnSyncConfig{Enabled: true, Interface: "eth1", SyncID: 256}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ipvs timeouts",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{IPVSTimeouts: IPVSTimeoutsConfig{TCP: 7200, UDP: 30}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative ipvs timeout",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{IPVSTimeouts: IPVSTimeoutsConfig{TCPFin: -1}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cleanup policy warn",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{Cleanup: "warn"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid cleanup policy",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{Cleanup: "purge"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "http admin api",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{API: APIConfig{HTTP: HTTPAPIConfig{Enabled: true, Bind: "127.0.0.1", Port: 8081, Token: "0123456789abcdef"}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "http admin api without token",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{API: APIConfig{HTTP: HTTPAPIConfig{Enabled: true, Port: 8081, Token: "short"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "http admin api named tokens",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{API: APIConfig{HTTP: HTTPAPIConfig{Enabled: true, Port: 8081, Tokens: []APIToken{{Name: "ci", Token: "0123456789abcdef"}, {Name: "dashboard", Token: "fedcba9876543210"}}}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "http admin api with no token at all",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{API: APIConfig{HTTP: HTTPAPIConfig{Enabled: true, Port: 8081}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "http admin api duplicate token name",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{API: APIConfig{HTTP: HTTPAPIConfig{Enabled: true, Port: 8081, Tokens: []APIToken{{Name: "ci", Token: "0123456789abcdef"}, {Name: "ci", Token: "fedcba9876543210"}}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "http admin api reused token",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{API: APIConfig{HTTP: HTTPAPIConfig{Enabled: true, Port: 8081, Token: "0123456789abcdef", Tokens: []APIToken{{Name: "ci", Token: "0123456789abcdef"}}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "http admin api short named token",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{API: APIConfig{HTTP: HTTPAPIConfig{Enabled: true, Port: 8081, Tokens: []APIToken{{Name: "ci", Token: "short"}}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "http admin api invalid token name",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{API: APIConfig{HTTP: HTTPAPIConfig{Enabled: true, Port: 8081, Tokens: []APIToken{{Name: "ci bot", Token: "0123456789abcdef"}}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "http admin api invalid bind",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{API: APIConfig{HTTP: HTTPAPIConfig{Enabled: true, Bind: "localhost", Port: 8081, Token: "0123456789abcdef"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "http admin api invalid port",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{API: APIConfig{HTTP: HTTPAPIConfig{Enabled: true, Port: 70000, Token: "0123456789abcdef"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "reconcile concurrency too high",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:   VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Daemon: DaemonConfig{ReconcileConcurrency: 65},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative ipvs stats interval",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:          VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Observability: ObsConfig{Metrics: MetricsConfig{IPVSStatsIntervalSeconds: -5}},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ipfix missing collector port",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:          VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Observability: ObsConfig{Metrics: MetricsConfig{IPFIX: IPFIXConfig{Enabled: true, Collector: "192.0.2.50"}}},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid metrics cluster",
+			config: &Config{
+				Mode: "dr",
+				Node: NodeConfig{Name: "node", Role: "primary"},
+				Network: NetworkConfig{
+					Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+					Backend:  InterfaceConfig{Interface: "eth1"},
+				},
+				VRRP:          VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+				Observability: ObsConfig{Metrics: MetricsConfig{Cluster: "edge lb"}},
+				Services: []Service{
+					{
+						Name:      "web",
+						Protocol:  "tcp",
+						Ports:     []int{80},
+						Scheduler: "wrr",
+						Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 10}},
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate_DaemonDefaultsAndBounds(t *testing.T) {
+	base := &Config{
+		Mode: "dr",
+		Node: NodeConfig{Name: "node", Role: "primary"},
+		Network: NetworkConfig{
+			Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+			Backend:  InterfaceConfig{Interface: "eth1"},
+		},
+		VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+	}
+
+	t.Run("defaults reconcile interval when unset", func(t *testing.T) {
+		cfg := *base
+		if err := Validate(&cfg); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		if cfg.Daemon.ReconcileIntervalMS != 1000 {
+			t.Fatalf("expected default reconcile_interval_ms=1000, got %d", cfg.Daemon.ReconcileIntervalMS)
+		}
+	})
+
+	t.Run("rejects reconcile interval below minimum", func(t *testing.T) {
+		cfg := *base
+		cfg.Daemon.ReconcileIntervalMS = 99
+		if err := Validate(&cfg); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+
+	t.Run("defaults VIP check interval to the reconcile interval", func(t *testing.T) {
+		cfg := *base
+		cfg.Daemon.ReconcileIntervalMS = 5000
+		if err := Validate(&cfg); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		if cfg.Daemon.VIPCheckIntervalMS != 5000 {
+			t.Fatalf("expected vip_check_interval_ms=5000, got %d", cfg.Daemon.VIPCheckIntervalMS)
+		}
+	})
+
+	t.Run("rejects VIP check interval below minimum", func(t *testing.T) {
+		cfg := *base
+		cfg.Daemon.VIPCheckIntervalMS = 49
+		if err := Validate(&cfg); err == nil || !strings.Contains(err.Error(), "vip_check_interval_ms") {
+			t.Fatalf("expected vip_check_interval_ms error, got %v", err)
+		}
+	})
+
+	t.Run("defaults reconcile backoff", func(t *testing.T) {
+		cfg := *base
+		if err := Validate(&cfg); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		if b := cfg.Daemon.ReconcileBackoff; b != (ReconcileBackoffConfig{InitialMS: 5000, MaxMS: 10_000, BreakerThreshold: 5}) {
+			t.Fatalf("unexpected reconcile_backoff defaults: %+v", b)
+		}
+
+		cfg = *base
+		cfg.Daemon.ReconcileBackoff.InitialMS = 20_000
+		if err := Validate(&cfg); err != nil || cfg.Daemon.ReconcileBackoff.MaxMS != 20_000 {
+			t.Fatalf("expected max_ms raised to initial_ms, got %d: %v", cfg.Daemon.ReconcileBackoff.MaxMS, err)
+		}
+	})
+
+	t.Run("rejects reconcile backoff out of bounds", func(t *testing.T) {
+		for _, b := range []ReconcileBackoffConfig{
+			{InitialMS: 99},
+			{InitialMS: 5000, MaxMS: 1000},
+			{MaxMS: 3_600_001},
+			{BreakerThreshold: -1},
+		} {
+			cfg := *base
+			cfg.Daemon.ReconcileBackoff = b
+			if err := Validate(&cfg); err == nil || !strings.Contains(err.Error(), "reconcile_backoff") {
+				t.Fatalf("expected reconcile_backoff error for %+v, got %v", b, err)
+			}
+		}
+	})
+
+	t.Run("defaults state_cache.ttl_ms when enabled and unset", func(t *testing.T) {
+		cfg := *base
+		cfg.Daemon.StateCache.Enabled = true
+		cfg.Daemon.StateCache.TTLMS = 0
+		if err := Validate(&cfg); err != nil {
+			t.Fatalf("Validate() error = %v", err)
 		}
 		if cfg.Daemon.StateCache.TTLMS != 500 {
 			t.Fatalf("expected default state_cache.ttl_ms=500, got %d", cfg.Daemon.StateCache.TTLMS)
@@ -729,6 +2904,50 @@ func TestValidate_DaemonDefaultsAndBounds(t *testing.T) {
 			t.Fatalf("expected error")
 		}
 	})
+
+	t.Run("accepts resolver servers with and without port", func(t *testing.T) {
+		cfg := *base
+		cfg.Daemon.Resolver = ResolverConfig{Servers: []string{"10.0.0.53", "10.0.0.54:5353", "[fd00::53]:53"}, CacheTTLMS: 60000}
+		if err := Validate(&cfg); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+	})
+
+	t.Run("accepts hostname backends", func(t *testing.T) {
+		cfg := *base
+		cfg.Services = []Service{{Name: "web", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr",
+			Backends: []Backend{{Address: "api.internal", Weight: 1, CheckAddress: "sidecar.api.internal."}}}}
+		if err := Validate(&cfg); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+	})
+
+	t.Run("rejects invalid backend hosts", func(t *testing.T) {
+		for _, addr := range []string{"10.0.0.300", "api_internal", "-api.internal", "api..internal"} {
+			cfg := *base
+			cfg.Services = []Service{{Name: "web", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr",
+				Backends: []Backend{{Address: addr, Weight: 1}}}}
+			if err := Validate(&cfg); err == nil {
+				t.Fatalf("expected error for %q", addr)
+			}
+		}
+	})
+
+	t.Run("rejects resolver hostname server", func(t *testing.T) {
+		cfg := *base
+		cfg.Daemon.Resolver.Servers = []string{"dns.example"}
+		if err := Validate(&cfg); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+
+	t.Run("rejects negative resolver cache_ttl_ms", func(t *testing.T) {
+		cfg := *base
+		cfg.Daemon.Resolver = ResolverConfig{CacheTTLMS: -1}
+		if err := Validate(&cfg); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
 }
 
 func TestWriteServiceConfig(t *testing.T) {
@@ -772,3 +2991,435 @@ func TestWriteServiceConfig(t *testing.T) {
 		t.Error("file content missing name")
 	}
 }
+
+func TestLoadConfigCommitGeneration(t *testing.T) {
+	tmpDir := t.TempDir()
+	mainPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(mainPath, []byte("mode: dr\ninclude: \"conf.d/*.yaml\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	confDir := filepath.Join(tmpDir, "conf.d")
+	svc := Service{
+		Name:      "a",
+		Protocol:  "tcp",
+		Ports:     []int{80},
+		Scheduler: "rr",
+		Backends:  []Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}},
+		Health:    HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
+	}
+
+	if err := os.Mkdir(confDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	inProgress, err := BeginCommit(confDir)
+	if err == nil {
+		err = WriteServiceConfig(confDir, svc)
+	}
+	if err != nil {
+		t.Fatalf("begin commit: %v", err)
+	}
+	if inProgress != 1 {
+		t.Fatalf("expected in-progress generation 1, got %d", inProgress)
+	}
+	if _, err := LoadConfig(mainPath); !errors.Is(err, ErrCommitInProgress) {
+		t.Fatalf("expected ErrCommitInProgress during commit, got %v", err)
+	}
+
+	gen, err := EndCommit(confDir, inProgress)
+	if err != nil {
+		t.Fatalf("EndCommit() error: %v", err)
+	}
+	cfg, err := LoadConfig(mainPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	if cfg.Generation != gen || gen != 2 || len(cfg.Services) != 1 {
+		t.Fatalf("expected generation 2 with one service, got gen=%d services=%d", cfg.Generation, len(cfg.Services))
+	}
+	if dir := IncludeDir(mainPath, cfg); dir != confDir {
+		t.Fatalf("IncludeDir() = %q, want %q", dir, confDir)
+	}
+
+	// Atomic writes leave no temporary files behind for the include glob.
+	entries, err := os.ReadDir(confDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tmp") {
+			t.Fatalf("unexpected temporary file %s", e.Name())
+		}
+	}
+
+	if applied, err := ReadAppliedGeneration(confDir); err != nil || applied != nil {
+		t.Fatalf("expected no applied generation yet, got %#v, %v", applied, err)
+	}
+	want := AppliedGeneration{Generation: 2, Rejected: 4, Error: "boom", Time: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)}
+	if err := WriteAppliedGeneration(confDir, want); err != nil {
+		t.Fatalf("WriteAppliedGeneration() error: %v", err)
+	}
+	applied, err := ReadAppliedGeneration(confDir)
+	if err != nil || applied == nil || *applied != want {
+		t.Fatalf("ReadAppliedGeneration() = %#v, %v", applied, err)
+	}
+}
+
+func TestServiceReloadRequests(t *testing.T) {
+	dir := t.TempDir()
+	if names, err := PendingServiceReloads(dir); err != nil || len(names) != 0 {
+		t.Fatalf("expected no pending reloads, got %v, %v", names, err)
+	}
+	for _, name := range []string{"web", "api", "web"} {
+		if err := RequestServiceReload(dir, name); err != nil {
+			t.Fatalf("RequestServiceReload(%s) error: %v", name, err)
+		}
+	}
+	if err := RequestServiceReload(dir, "../etc"); err == nil {
+		t.Fatalf("expected an invalid service name to be rejected")
+	}
+
+	names, err := PendingServiceReloads(dir)
+	if err != nil || strings.Join(names, ",") != "api,web" {
+		t.Fatalf("PendingServiceReloads() = %v, %v; want [api web]", names, err)
+	}
+	if err := ClearServiceReload(dir, "api"); err != nil {
+		t.Fatalf("ClearServiceReload() error: %v", err)
+	}
+	if err := ClearServiceReload(dir, "api"); err != nil {
+		t.Fatalf("clearing a handled request should be a no-op: %v", err)
+	}
+	if names, _ := PendingServiceReloads(dir); strings.Join(names, ",") != "web" {
+		t.Fatalf("expected only web pending, got %v", names)
+	}
+}
+
+func TestServiceLabelsAndSelector(t *testing.T) {
+	svc := Service{Name: "pay", Labels: map[string]string{"team": "payments", "env": "prod"}}
+	if err := validateLabels(svc.Labels); err != nil {
+		t.Fatalf("validateLabels() error = %v", err)
+	}
+
+	bad := []map[string]string{
+		{"Team": "payments"},
+		{"team": "pay ments"},
+		{"team": strings.Repeat("x", 65)},
+	}
+	tooMany := make(map[string]string)
+	for i := 0; i <= MaxServiceLabels; i++ {
+		tooMany[fmt.Sprintf("k%d", i)] = "v"
+	}
+	bad = append(bad, tooMany)
+	for _, labels := range bad {
+		if err := validateLabels(labels); err == nil {
+			t.Errorf("expected labels %v to be rejected", labels)
+		}
+	}
+
+	sel, err := ParseSelector("team=payments, env=prod")
+	if err != nil {
+		t.Fatalf("ParseSelector() error = %v", err)
+	}
+	if !sel.Matches(svc.Labels) {
+		t.Fatalf("expected %s to match %v", sel, svc.Labels)
+	}
+	if sel, _ := ParseSelector("team=search"); sel.Matches(svc.Labels) {
+		t.Fatalf("expected team=search not to match")
+	}
+	if sel, _ := ParseSelector(""); !sel.Matches(nil) {
+		t.Fatalf("empty selector should match everything")
+	}
+	if _, err := ParseSelector("team"); err == nil {
+		t.Fatalf("expected term without = to fail")
+	}
+}
+
+func TestLint(t *testing.T) {
+	base := func() Service {
+		return Service{
+			Name:      "web",
+			Protocol:  "tcp",
+			Ports:     []int{80},
+			Scheduler: "wrr",
+			Backends:  []Backend{{Address: "10.0.0.1", Weight: 1}, {Address: "10.0.0.2", Weight: 1}},
+			Health:    HealthCheck{Enabled: true, Type: "tcp", IntervalMS: 1000, TimeoutMS: 300, FailAfter: 3, RecoverAfter: 2},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Config, *Service)
+		want   []string
+	}{
+		{"clean", func(*Config, *Service) {}, nil},
+		{"health disabled in production", func(_ *Config, s *Service) {
+			s.Health.Enabled = false
+			s.Labels = map[string]string{"env": "Production"}
+		}, []string{LintHealthDisabledProd}},
+		{"health disabled outside production", func(_ *Config, s *Service) {
+			s.Health.Enabled = false
+			s.Labels = map[string]string{"env": "staging"}
+		}, nil},
+		{"single backend", func(_ *Config, s *Service) {
+			s.Backends = s.Backends[:1]
+		}, []string{LintSingleBackend}},
+		{"rr with unequal weights", func(_ *Config, s *Service) {
+			s.Scheduler = "rr"
+			s.Backends[1].Weight = 5
+		}, []string{LintRRUnequalWeights}},
+		{"wrr with unequal weights", func(_ *Config, s *Service) {
+			s.Backends[1].Weight = 5
+		}, nil},
+		{"timeout over interval", func(_ *Config, s *Service) {
+			s.Health.TimeoutMS = 2000
+		}, []string{LintTimeoutOverInterval}},
+		{"large port range", func(_ *Config, s *Service) {
+			s.PortRanges = []PortRange{{Start: 10000, End: 20000}}
+		}, []string{LintLargePortRange}},
+		{"port range under a raised limit", func(c *Config, s *Service) {
+			c.Lint.MaxPortRange = 20000
+			s.PortRanges = []PortRange{{Start: 10000, End: 20000}}
+		}, nil},
+		{"catch-all", func(_ *Config, s *Service) {
+			s.Ports = []int{0}
+			s.PersistenceTimeout = 300
+		}, []string{LintCatchAll}},
+		{"catch-all with sh-port", func(_ *Config, s *Service) {
+			s.Ports = []int{0}
+			s.PersistenceTimeout = 300
+			s.Scheduler = "sh"
+			s.SchedulerFlags = []string{"sh-port"}
+		}, []string{LintCatchAll, LintCatchAll}},
+		{"suppressed per service", func(_ *Config, s *Service) {
+			s.Backends = s.Backends[:1]
+			s.Health.TimeoutMS = 2000
+			s.LintIgnore = []string{LintSingleBackend}
+		}, []string{LintTimeoutOverInterval}},
+		{"suppressed globally", func(c *Config, s *Service) {
+			s.Backends = s.Backends[:1]
+			c.Lint.Ignore = []string{LintSingleBackend}
+		}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			svc := base()
+			tt.modify(cfg, &svc)
+			cfg.Services = []Service{svc}
+
+			var got []string
+			for _, f := range Lint(cfg) {
+				if f.Service != "web" || f.Message == "" {
+					t.Errorf("unexpected finding %+v", f)
+				}
+				got = append(got, f.Rule)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("Lint() rules = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate_BackendPortMapping(t *testing.T) {
+	base := func() *Config {
+		return &Config{
+			Mode: "nat",
+			Node: NodeConfig{Name: "node", Role: "primary"},
+			Network: NetworkConfig{
+				Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+				Backend:  InterfaceConfig{Interface: "eth1"},
+			},
+			VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+			Services: []Service{{
+				Name:       "game",
+				Protocol:   "udp",
+				PortRanges: []PortRange{{Start: 7000, End: 7009}},
+				Scheduler:  "rr",
+				Backends:   []Backend{{Address: "10.0.0.1", Weight: 1}},
+			}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*Config, *Backend)
+		wantErr string
+	}{
+		{"offset", func(_ *Config, b *Backend) { b.PortOffset = 10000 }, ""},
+		{"negative offset", func(_ *Config, b *Backend) { b.PortOffset = -6999 }, ""},
+		{"range", func(_ *Config, b *Backend) { b.PortRange = PortRange{Start: 17000, End: 17009} }, ""},
+		{"nat on the backend only", func(c *Config, b *Backend) {
+			c.Mode = "dr"
+			b.Forward = "nat"
+			b.PortOffset = 1
+		}, ""},
+		{"offset past 65535", func(_ *Config, b *Backend) { b.PortOffset = 58530 }, "outside 1-65535"},
+		{"offset below 1", func(_ *Config, b *Backend) { b.PortOffset = -7000 }, "outside 1-65535"},
+		{"range of another length", func(_ *Config, b *Backend) { b.PortRange = PortRange{Start: 17000, End: 17019} }, "spans 20 ports"},
+		{"invalid range", func(_ *Config, b *Backend) { b.PortRange = PortRange{Start: 17009, End: 17000} }, "invalid port_range"},
+		{"range with discrete service ports", func(c *Config, b *Backend) {
+			c.Services[0].Ports = []int{80}
+			b.PortRange = PortRange{Start: 17000, End: 17009}
+		}, "single port range"},
+		{"port and offset", func(_ *Config, b *Backend) {
+			b.Port = 8080
+			b.PortOffset = 1
+		}, "only one of"},
+		{"direct routing", func(c *Config, b *Backend) {
+			c.Mode = "dr"
+			b.PortOffset = 1
+		}, "need nat forwarding"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base()
+			tt.modify(cfg, &cfg.Services[0].Backends[0])
+			err := Validate(cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	svc := base().Services[0]
+	if got := svc.BackendPortOffset(Backend{PortRange: PortRange{Start: 17000, End: 17009}}); got != 10000 {
+		t.Fatalf("BackendPortOffset() for a port_range = %d, want 10000", got)
+	}
+}
+
+func TestValidate_CatchAll(t *testing.T) {
+	base := func() *Config {
+		return &Config{
+			Mode: "dr",
+			Node: NodeConfig{Name: "node", Role: "primary"},
+			Network: NetworkConfig{
+				Frontend: InterfaceConfig{Interface: "eth0", VIP: "192.168.1.1", CIDR: 24},
+				Backend:  InterfaceConfig{Interface: "eth1"},
+			},
+			VRRP: VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+			Services: []Service{{
+				Name:               "transparent",
+				Protocol:           "tcp",
+				Ports:              []int{0},
+				Scheduler:          "wlc",
+				PersistenceTimeout: 300,
+				Backends:           []Backend{{Address: "10.0.0.1", Weight: 1}},
+			}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*Config, *Service)
+		wantErr string
+	}{
+		{"direct routing", func(*Config, *Service) {}, ""},
+		{"nat", func(c *Config, _ *Service) { c.Mode = "nat" }, ""},
+		{"udp", func(_ *Config, s *Service) { s.Protocol = "udp" }, ""},
+		{"without persistence", func(_ *Config, s *Service) { s.PersistenceTimeout = 0 }, "needs persistence_timeout"},
+		{"with other ports", func(_ *Config, s *Service) { s.Ports = []int{0, 80} }, "only port"},
+		{"with a port range", func(_ *Config, s *Service) {
+			s.PortRanges = []PortRange{{Start: 1000, End: 2000}}
+		}, "only port"},
+		{"backend port in dr", func(_ *Config, s *Service) { s.Backends[0].Port = 8080 }, "dr forwarding never changes"},
+		{"backend port_offset in nat", func(c *Config, s *Service) {
+			c.Mode = "nat"
+			s.Backends[0].PortOffset = 1000
+		}, "keeps each connection's destination port"},
+		{"backend tun override", func(_ *Config, s *Service) {
+			s.Backends[0].Forward = "tun"
+			s.Backends[0].Port = 8080
+		}, "tun forwarding never changes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base()
+			tt.modify(cfg, &cfg.Services[0])
+			err := Validate(cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServiceProtocols(t *testing.T) {
+	for proto, want := range map[string][]string{
+		"":        {"tcp"},
+		"tcp":     {"tcp"},
+		"UDP":     {"udp"},
+		"tcp+udp": {"tcp", "udp"},
+	} {
+		if got := (Service{Protocol: proto}).Protocols(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Protocols() of %q = %v, want %v", proto, got, want)
+		}
+	}
+}
+
+func TestValidateLintRules(t *testing.T) {
+	if err := validateLintRules([]string{LintSingleBackend, LintLargePortRange}); err != nil {
+		t.Fatalf("known rules rejected: %v", err)
+	}
+	if err := validateLintRules([]string{"LB999"}); err == nil {
+		t.Fatalf("expected unknown rule to be rejected")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := &Config{
+		Mode:       "dr",
+		Generation: 2,
+		Services: []Service{
+			{Name: "web", Ports: []int{80, 443}, Backends: []Backend{{Address: "10.0.0.1", Weight: 1}, {Address: "10.0.0.2", Weight: 1}}},
+			{Name: "dns", Ports: []int{53}, Backends: []Backend{{Address: "10.0.1.1", Weight: 1}}},
+			{Name: "same", Ports: []int{22}, Backends: []Backend{{Address: "10.0.2.1", Weight: 1}}},
+		},
+	}
+	next := &Config{
+		Mode:       "dr",
+		Generation: 4,
+		Services: []Service{
+			{Name: "web", Ports: []int{80}, Backends: []Backend{{Address: "10.0.0.1", Weight: 5}, {Address: "10.0.0.3", Weight: 1}}},
+			{Name: "same", Ports: []int{22}, Backends: []Backend{{Address: "10.0.2.1", Weight: 1}}},
+			{Name: "game", PortRanges: []PortRange{{Start: 7000, End: 7009}}, Backends: []Backend{{Address: "10.0.3.1", Weight: 1}}},
+		},
+	}
+
+	d := Diff(old, next)
+	if strings.Join(d.ServicesAdded, ",") != "game" || strings.Join(d.ServicesRemoved, ",") != "dns" || strings.Join(d.ServicesModified, ",") != "web" {
+		t.Fatalf("unexpected services added=%v removed=%v modified=%v", d.ServicesAdded, d.ServicesRemoved, d.ServicesModified)
+	}
+	// web swaps 10.0.0.2 for 10.0.0.3 (a weight change is not a removal), dns goes, game comes
+	if d.BackendsAdded != 2 || d.BackendsRemoved != 2 {
+		t.Fatalf("expected 2 backends added and 2 removed, got %d/%d", d.BackendsAdded, d.BackendsRemoved)
+	}
+	// -1 on web, -1 for dns, +10 for game
+	if d.PortsDelta != 8 {
+		t.Fatalf("expected ports delta 8, got %d", d.PortsDelta)
+	}
+	if d.GlobalsChanged {
+		t.Fatalf("a new generation alone should not count as a global change")
+	}
+
+	next.Mode = "nat"
+	if !Diff(old, next).GlobalsChanged {
+		t.Fatalf("expected a mode change to count as a global change")
+	}
+}
+
+func intPtr(v int) *int { return &v }
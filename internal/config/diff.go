@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ConfigDiff summarizes what changed between two configs, for audit events
+// that log consumers can alert on (e.g. any backend removal).
+type ConfigDiff struct {
+	ServicesAdded    []string // Sorted by name
+	ServicesRemoved  []string
+	ServicesModified []string
+	BackendsAdded    int // Backends (by address and port) new to a service, including added services
+	BackendsRemoved  int // Backends gone from a service, including removed services
+	PortsDelta       int // Change in the number of ports, counting each port of a range
+	GlobalsChanged   bool
+}
+
+// Diff compares old and next. Services are matched by name and backends by
+// address and port within a service.
+func Diff(old, next *Config) ConfigDiff {
+	var d ConfigDiff
+	oldSvcs := make(map[string]Service, len(old.Services))
+	for _, svc := range old.Services {
+		oldSvcs[svc.Name] = svc
+	}
+	seen := make(map[string]bool, len(next.Services))
+	for _, svc := range next.Services {
+		seen[svc.Name] = true
+		prev, ok := oldSvcs[svc.Name]
+		switch {
+		case !ok:
+			d.ServicesAdded = append(d.ServicesAdded, svc.Name)
+		case !reflect.DeepEqual(prev, svc):
+			d.ServicesModified = append(d.ServicesModified, svc.Name)
+		}
+		added, removed := diffBackends(prev.Backends, svc.Backends)
+		d.BackendsAdded += added
+		d.BackendsRemoved += removed
+		d.PortsDelta += countPorts(svc) - countPorts(prev)
+	}
+	for _, svc := range old.Services {
+		if seen[svc.Name] {
+			continue
+		}
+		d.ServicesRemoved = append(d.ServicesRemoved, svc.Name)
+		d.BackendsRemoved += len(svc.Backends)
+		d.PortsDelta -= countPorts(svc)
+	}
+	sort.Strings(d.ServicesAdded)
+	sort.Strings(d.ServicesRemoved)
+	sort.Strings(d.ServicesModified)
+
+	oldGlobals, nextGlobals := *old, *next
+	oldGlobals.Services, nextGlobals.Services = nil, nil
+	oldGlobals.Generation, nextGlobals.Generation = 0, 0
+	d.GlobalsChanged = !reflect.DeepEqual(oldGlobals, nextGlobals)
+	return d
+}
+
+func diffBackends(old, next []Backend) (added, removed int) {
+	key := func(be Backend) string { return fmt.Sprintf("%s:%d", be.Address, be.Port) }
+	oldKeys := make(map[string]bool, len(old))
+	for _, be := range old {
+		oldKeys[key(be)] = true
+	}
+	nextKeys := make(map[string]bool, len(next))
+	for _, be := range next {
+		k := key(be)
+		nextKeys[k] = true
+		if !oldKeys[k] {
+			added++
+		}
+	}
+	for k := range oldKeys {
+		if !nextKeys[k] {
+			removed++
+		}
+	}
+	return added, removed
+}
+
+func countPorts(svc Service) int {
+	n := len(svc.Ports)
+	for _, pr := range svc.PortRanges {
+		n += pr.End - pr.Start + 1
+	}
+	return n
+}
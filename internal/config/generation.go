@@ -0,0 +1,194 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+)
+
+// Commit generations coordinate shell commits with daemon reloads without
+// file locks. A writer bumps the generation file in the include directory to
+// an odd value before touching any service file and to the next even value
+// once every file is in place. LoadConfig only accepts a load that started
+// and finished on the same even generation, so it never merges a half-written
+// commit. After each reload the daemon records the generation it applied so
+// the writer can wait for the change to take effect. That acknowledgement is
+// daemon state, so it lives in the state dir rather than next to the config.
+const (
+	GenerationFile        = ".generation"
+	AppliedGenerationFile = "generation.applied"
+)
+
+// Writers also hold CommitLockFile in the include directory (see LockCommit)
+// from validating a change until it is published, so a shell commit and the
+// daemon activating a scheduled change never validate against each other's
+// half-done work. Like the generation file, it never matches a *.yaml
+// include pattern.
+const (
+	CommitLockFile = ".commit.lock"
+	commitLockWait = 5 * time.Second
+	commitLockPoll = 50 * time.Millisecond
+)
+
+// ErrCommitInProgress is returned by LoadConfig when the include directory is
+// being rewritten. Callers should retry shortly.
+var ErrCommitInProgress = errdefs.WithCode(errdefs.CodeConflict, errors.New("config commit in progress"))
+
+// AppliedGeneration is the daemon's acknowledgement of a reload.
+type AppliedGeneration struct {
+	Generation uint64    `json:"generation"`         // Generation the daemon is running
+	Rejected   uint64    `json:"rejected,omitempty"` // Newer generation the last reload refused
+	Error      string    `json:"error,omitempty"`    // Why Rejected was refused
+	Time       time.Time `json:"time"`
+}
+
+// IncludeDir returns the directory matched by cfg.Include, resolved relative
+// to the main config file at path, or "" when there are no includes.
+func IncludeDir(path string, cfg *Config) string {
+	pattern := IncludePattern(path, cfg)
+	if pattern == "" {
+		return ""
+	}
+	return filepath.Dir(pattern)
+}
+
+// IncludePattern returns cfg.Include resolved relative to the main config
+// file at path, or "" when there are no includes. A relative include has no
+// pattern when there is no main config file.
+func IncludePattern(path string, cfg *Config) string {
+	if cfg == nil || cfg.Include == "" {
+		return ""
+	}
+	if filepath.IsAbs(cfg.Include) {
+		return cfg.Include
+	}
+	if path == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(path), cfg.Include)
+}
+
+// VarsPath returns the vars file name resolved relative to the main config
+// file at path.
+func VarsPath(path, name string) string {
+	if filepath.IsAbs(name) || path == "" {
+		return name
+	}
+	return filepath.Join(filepath.Dir(path), name)
+}
+
+// ReadGeneration returns the commit generation of dir. A missing file reads
+// as generation 0.
+func ReadGeneration(dir string) (uint64, error) {
+	b, err := os.ReadFile(filepath.Join(dir, GenerationFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read generation: %w", err)
+	}
+	gen, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid generation file: %w", err)
+	}
+	return gen, nil
+}
+
+// BeginCommit marks dir as being rewritten and returns the odd in-progress
+// generation to pass to EndCommit. A generation left odd by an interrupted
+// commit is reused.
+func BeginCommit(dir string) (uint64, error) {
+	gen, err := ReadGeneration(dir)
+	if err != nil {
+		return 0, err
+	}
+	if gen%2 == 0 {
+		gen++
+	}
+	if err := writeGeneration(dir, gen); err != nil {
+		return 0, err
+	}
+	return gen, nil
+}
+
+// EndCommit publishes a commit started with BeginCommit and returns the new
+// generation.
+func EndCommit(dir string, inProgress uint64) (uint64, error) {
+	gen := inProgress + 1
+	if err := writeGeneration(dir, gen); err != nil {
+		return 0, err
+	}
+	return gen, nil
+}
+
+func writeGeneration(dir string, gen uint64) error {
+	if err := writeFileAtomic(filepath.Join(dir, GenerationFile), []byte(strconv.FormatUint(gen, 10)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write generation: %w", err)
+	}
+	return nil
+}
+
+// WriteAppliedGeneration records the outcome of a daemon reload in the state
+// dir.
+func WriteAppliedGeneration(dir string, applied AppliedGeneration) error {
+	data, err := json.Marshal(applied)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, AppliedGenerationFile), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write applied generation: %w", err)
+	}
+	return nil
+}
+
+// ReadAppliedGeneration returns the last reload outcome recorded in the state
+// dir, or nil if the daemon has not recorded one.
+func ReadAppliedGeneration(dir string) (*AppliedGeneration, error) {
+	b, err := os.ReadFile(filepath.Join(dir, AppliedGenerationFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read applied generation: %w", err)
+	}
+	var applied AppliedGeneration
+	if err := json.Unmarshal(b, &applied); err != nil {
+		return nil, fmt.Errorf("invalid applied generation file: %w", err)
+	}
+	return &applied, nil
+}
+
+// writeFileAtomic writes data to a hidden temporary file next to path and
+// renames it into place, so readers see either the old or the new contents.
+// The temporary name never matches a *.yaml include pattern.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+strings.TrimPrefix(filepath.Base(path), ".")+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
+}
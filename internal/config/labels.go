@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Label limits keep service labels safe to use as metric labels
+const (
+	MaxServiceLabels = 8
+	maxLabelValueLen = 64
+)
+
+var (
+	labelKeyRegex   = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+	labelValueRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]*$`)
+)
+
+// Selector matches services whose labels contain every key/value pair
+type Selector map[string]string
+
+// ParseSelector parses "key=value[,key=value...]". An empty string selects
+// everything.
+func ParseSelector(s string) (Selector, error) {
+	sel := make(Selector)
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(term, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid selector term: %s", term)
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if err := validateLabel(k, v); err != nil {
+			return nil, err
+		}
+		sel[k] = v
+	}
+	return sel, nil
+}
+
+func (sel Selector) Matches(labels map[string]string) bool {
+	for k, v := range sel {
+		if got, ok := labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (sel Selector) String() string {
+	terms := make([]string, 0, len(sel))
+	for k, v := range sel {
+		terms = append(terms, k+"="+v)
+	}
+	sort.Strings(terms)
+	return strings.Join(terms, ",")
+}
+
+func validateLabels(labels map[string]string) error {
+	if len(labels) > MaxServiceLabels {
+		return fmt.Errorf("too many labels: %d (max %d)", len(labels), MaxServiceLabels)
+	}
+	for k, v := range labels {
+		if err := validateLabel(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateLabel(k, v string) error {
+	if !labelKeyRegex.MatchString(k) {
+		return fmt.Errorf("invalid label key: %q", k)
+	}
+	if len(v) > maxLabelValueLen || !labelValueRegex.MatchString(v) {
+		return fmt.Errorf("invalid label value for %s: %q", k, v)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Lint rule IDs. A finding is suppressed by listing its rule in the service's
+// lint_ignore, or in lint.ignore for every service.
+const (
+	LintHealthDisabledProd  = "LB001" // Health checks off on a production service
+	LintSingleBackend       = "LB002" // Only one backend, so no redundancy
+	LintRRUnequalWeights    = "LB003" // rr ignores the unequal weights configured
+	LintTimeoutOverInterval = "LB004" // Health timeout longer than the check interval
+	LintLargePortRange      = "LB005" // Port range wider than lint.max_port_range
+	LintCatchAll            = "LB006" // Port 0 service, where persistence decides the backend
+)
+
+// LintRules lists every lint rule ID.
+var LintRules = []string{
+	LintHealthDisabledProd,
+	LintSingleBackend,
+	LintRRUnequalWeights,
+	LintTimeoutOverInterval,
+	LintLargePortRange,
+	LintCatchAll,
+}
+
+// DefaultLintMaxPortRange is the widest port range LB005 allows when
+// lint.max_port_range is unset.
+const DefaultLintMaxPortRange = 1024
+
+// LintFinding is one risky pattern found in a valid config.
+type LintFinding struct {
+	Rule    string
+	Service string
+	Message string
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s %s: %s", f.Rule, f.Service, f.Message)
+}
+
+// Lint flags patterns that pass validation but are risky in production. cfg
+// should already be valid. Findings are sorted by service, then rule.
+func Lint(cfg *Config) []LintFinding {
+	maxRange := cfg.Lint.MaxPortRange
+	if maxRange == 0 {
+		maxRange = DefaultLintMaxPortRange
+	}
+
+	var findings []LintFinding
+	for _, svc := range cfg.Services {
+		add := func(rule, format string, args ...interface{}) {
+			if slices.Contains(cfg.Lint.Ignore, rule) || slices.Contains(svc.LintIgnore, rule) {
+				return
+			}
+			findings = append(findings, LintFinding{Rule: rule, Service: svc.Name, Message: fmt.Sprintf(format, args...)})
+		}
+
+		if !svc.Health.Enabled && isProduction(svc.Labels) {
+			add(LintHealthDisabledProd, "health checks are disabled on a production service")
+		}
+		if len(svc.Backends) == 1 {
+			add(LintSingleBackend, "only one backend; the service is down whenever it is")
+		}
+		if strings.EqualFold(svc.Scheduler, "rr") && !equalWeights(svc.Backends) {
+			add(LintRRUnequalWeights, "backend weights differ but rr ignores them; use wrr")
+		}
+		if svc.Health.Enabled && svc.Health.TimeoutMS > svc.Health.IntervalMS {
+			add(LintTimeoutOverInterval, "health timeout_ms %d exceeds interval_ms %d", svc.Health.TimeoutMS, svc.Health.IntervalMS)
+		}
+		if svc.CatchAll() {
+			add(LintCatchAll, "port 0 takes every port: a client sticks to one backend for all ports for %ds, so %s only places its first connection", svc.PersistenceTimeout, strings.ToLower(svc.Scheduler))
+			if slices.ContainsFunc(svc.SchedulerFlags, func(f string) bool { return strings.EqualFold(f, "sh-port") }) {
+				add(LintCatchAll, "sh-port hashes only the port of a client's first connection; persistence pins the rest")
+			}
+		}
+		for _, pr := range svc.PortRanges {
+			if n := pr.End - pr.Start + 1; n > maxRange {
+				add(LintLargePortRange, "port range %d-%d spans %d ports (limit %d)", pr.Start, pr.End, n, maxRange)
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Service != findings[j].Service {
+			return findings[i].Service < findings[j].Service
+		}
+		return findings[i].Rule < findings[j].Rule
+	})
+	return findings
+}
+
+// isProduction reports whether a service is tagged env or environment
+// prod/production.
+func isProduction(labels map[string]string) bool {
+	for _, key := range []string{"env", "environment"} {
+		switch strings.ToLower(labels[key]) {
+		case "prod", "production":
+			return true
+		}
+	}
+	return false
+}
+
+func equalWeights(backends []Backend) bool {
+	for _, be := range backends {
+		if be.Weight != backends[0].Weight {
+			return false
+		}
+	}
+	return true
+}
+
+// validateLintRules rejects unknown rule IDs, so a typo can't silently leave
+// a finding unsuppressed.
+func validateLintRules(rules []string) error {
+	for _, rule := range rules {
+		if !slices.Contains(LintRules, rule) {
+			return fmt.Errorf("unknown lint rule: %s", rule)
+		}
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -15,39 +16,42 @@ var EnvVarRegex = regexp.MustCompile(`\$\{([A-Z0-9_]+)\}`)
 
 // LoadConfig loads the configuration from the specified path
 func LoadConfig(path string) (*Config, error) {
+	return loadConfig(path, true)
+}
+
+// LoadRawConfig loads the configuration like LoadConfig but leaves service
+// pools unexpanded, for editors that write services back out.
+func LoadRawConfig(path string) (*Config, error) {
+	return loadConfig(path, false)
+}
+
+func loadConfig(path string, expand bool) (*Config, error) {
 	// 1. Read main config
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	// 2. Resolve environment variables
-	resolvedData, err := ResolveEnvVars(data)
+	// 2. Parse the globals, with the vars file they name
+	cfg, vars, err := parseMainConfig(data, func(name string) ([]byte, error) {
+		return os.ReadFile(VarsPath(path, name))
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to resolve env vars: %w", err)
-	}
-
-	// 3. Enforce that the main config contains globals only (no services).
-	var mainTop map[string]interface{}
-	if err := yaml.Unmarshal(resolvedData, &mainTop); err != nil {
-		return nil, fmt.Errorf("failed to parse config YAML: %w", err)
-	}
-	if _, ok := mainTop["services"]; ok {
-		return nil, fmt.Errorf("main config must not define services; define services in config.d files")
-	}
-
-	// 4. Unmarshal main config
-	var cfg Config
-	if err := yaml.Unmarshal(resolvedData, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config YAML: %w", err)
+		return nil, err
 	}
 
-	// 5. Handle includes
+	// 3. Handle includes
 	if cfg.Include != "" {
-		// Resolve include path relative to config file if not absolute
-		includePattern := cfg.Include
-		if !filepath.IsAbs(includePattern) {
-			includePattern = filepath.Join(filepath.Dir(path), includePattern)
+		includePattern := IncludePattern(path, cfg)
+
+		// Only accept includes read entirely within one published commit
+		includeDir := filepath.Dir(includePattern)
+		gen, err := ReadGeneration(includeDir)
+		if err != nil {
+			return nil, err
+		}
+		if gen%2 == 1 {
+			return nil, ErrCommitInProgress
 		}
 
 		matches, err := filepath.Glob(includePattern)
@@ -58,23 +62,87 @@ func LoadConfig(path string) (*Config, error) {
 		sort.Strings(matches) // Alphabetical order
 
 		for _, match := range matches {
-			if err := loadServiceConfig(match, &cfg); err != nil {
+			if err := loadServiceConfig(match, cfg, vars, expand); err != nil {
+				// A file removed or replaced mid-load is a commit, not a broken include
+				if now, _ := ReadGeneration(includeDir); now != gen {
+					return nil, ErrCommitInProgress
+				}
 				return nil, fmt.Errorf("failed to load service config %s: %w", match, err)
 			}
 		}
+
+		after, err := ReadGeneration(includeDir)
+		if err != nil {
+			return nil, err
+		}
+		if after != gen {
+			return nil, ErrCommitInProgress
+		}
+		cfg.Generation = gen
+	}
+
+	return cfg, nil
+}
+
+// parseMainConfig parses a main config file, which holds globals only. Its
+// ${VAR} references, and those of the files it includes, resolve with the
+// vars file it names, which readVars reads.
+func parseMainConfig(data []byte, readVars func(name string) ([]byte, error)) (*Config, Vars, error) {
+	// The vars file has to be known before anything can be resolved
+	var head struct {
+		Vars string `yaml:"vars"`
+	}
+	if err := yaml.Unmarshal(data, &head); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config YAML: %w", err)
+	}
+	var vars Vars
+	if head.Vars != "" {
+		if EnvVarRegex.MatchString(head.Vars) {
+			return nil, nil, fmt.Errorf("vars must name a file without ${VAR} references")
+		}
+		b, err := readVars(head.Vars)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read vars file: %w", err)
+		}
+		if vars, err = ParseVars(b); err != nil {
+			return nil, nil, fmt.Errorf("invalid vars file %s: %w", head.Vars, err)
+		}
+	}
+
+	resolvedData, err := resolveVars(data, vars)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve env vars: %w", err)
+	}
+
+	// Enforce that the main config contains globals only (no services).
+	var mainTop map[string]interface{}
+	if err := yaml.Unmarshal(resolvedData, &mainTop); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config YAML: %w", err)
+	}
+	if _, ok := mainTop["services"]; ok {
+		return nil, nil, fmt.Errorf("main config must not define services; define services in config.d files")
 	}
 
-	return &cfg, nil
+	var cfg Config
+	if err := yaml.Unmarshal(resolvedData, &cfg); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config YAML: %w", err)
+	}
+	return &cfg, vars, nil
 }
 
 // loadServiceConfig loads a service configuration file and appends to the main config
-func loadServiceConfig(path string, cfg *Config) error {
+func loadServiceConfig(path string, cfg *Config, vars Vars, expand bool) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
+	return parseServiceConfig(data, cfg, vars, expand)
+}
 
-	resolvedData, err := ResolveEnvVars(data)
+// parseServiceConfig parses a config.d file and appends its services to cfg,
+// with their pools expanded if expand is set
+func parseServiceConfig(data []byte, cfg *Config, vars Vars, expand bool) error {
+	resolvedData, err := resolveVars(data, vars)
 	if err != nil {
 		return err
 	}
@@ -99,6 +167,14 @@ func loadServiceConfig(path string, cfg *Config) error {
 		return err
 	}
 
+	if expand {
+		for i := range serviceCfg.Services {
+			if err := ExpandPools(&serviceCfg.Services[i]); err != nil {
+				return err
+			}
+		}
+	}
+
 	// Merge services
 	if len(serviceCfg.Services) > 0 {
 		cfg.Services = append(cfg.Services, serviceCfg.Services...)
@@ -109,6 +185,19 @@ func loadServiceConfig(path string, cfg *Config) error {
 
 // ResolveEnvVars replaces ${VAR} with environment variable values
 func ResolveEnvVars(data []byte) ([]byte, error) {
+	return resolveVars(data, nil)
+}
+
+// resolveVars replaces ${VAR} with environment variable values, falling back
+// to vars for variables the environment doesn't set
+func resolveVars(data []byte, vars Vars) ([]byte, error) {
+	lookup := func(name string) (string, bool) {
+		if val, ok := os.LookupEnv(name); ok {
+			return val, true
+		}
+		val, ok := vars[name]
+		return val, ok
+	}
 	content := string(data)
 	var missingVars []string
 
@@ -116,7 +205,7 @@ func ResolveEnvVars(data []byte) ([]byte, error) {
 	matches := EnvVarRegex.FindAllStringSubmatch(content, -1)
 	for _, match := range matches {
 		varName := match[1]
-		if _, ok := os.LookupEnv(varName); !ok {
+		if _, ok := lookup(varName); !ok {
 			// Check if already added to missingVars to avoid duplicates
 			found := false
 			for _, v := range missingVars {
@@ -138,9 +227,42 @@ func ResolveEnvVars(data []byte) ([]byte, error) {
 	// Second pass: replace
 	resolved := EnvVarRegex.ReplaceAllStringFunc(content, func(match string) string {
 		varName := match[2 : len(match)-1] // Remove ${ and }
-		val, _ := os.LookupEnv(varName)
+		val, _ := lookup(varName)
 		return val
 	})
 
 	return []byte(resolved), nil
 }
+
+// Vars are values for ${VAR} references, read from the file the main config
+// names in vars. They let per-node values such as the VIP or node name live
+// in one small file instead of the daemon's environment, which still takes
+// precedence.
+type Vars map[string]string
+
+// ParseVars parses a vars file: one NAME=value per line, like a systemd
+// EnvironmentFile. Blank lines and lines starting with # are ignored, and
+// quotes around a value are removed.
+func ParseVars(data []byte) (Vars, error) {
+	vars := make(Vars)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, val, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !varNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("line %d: expected NAME=value with NAME in A-Z, 0-9 and _", i+1)
+		}
+		val = strings.TrimSpace(val)
+		if len(val) >= 2 && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0] {
+			val = val[1 : len(val)-1]
+		}
+		vars[name] = val
+	}
+	return vars, nil
+}
+
+// varNameRegex matches the names EnvVarRegex can reference
+var varNameRegex = regexp.MustCompile(`^[A-Z0-9_]+$`)
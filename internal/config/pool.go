@@ -0,0 +1,138 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// maxPoolSize caps how many backends a single pool may expand to, so a typo
+// like /8 can't generate millions of IPVS destinations.
+const maxPoolSize = 4096
+
+// BackendPool describes a range of backends by CIDR. Exclude removes
+// addresses (e.g. canaries managed elsewhere); Overrides change the weight of
+// individual addresses.
+type BackendPool struct {
+	CIDR      string         `yaml:"cidr"`
+	Port      int            `yaml:"port"`
+	Weight    int            `yaml:"weight"`
+	Exclude   []string       `yaml:"exclude,omitempty"`
+	Overrides []PoolOverride `yaml:"overrides,omitempty"`
+}
+
+type PoolOverride struct {
+	Address string `yaml:"address"`
+	Weight  int    `yaml:"weight"`
+}
+
+// ExpandPools appends one Backend per usable pool address to svc.Backends
+// and clears svc.Pools. For IPv4 prefixes shorter than /31 the network and
+// broadcast addresses are skipped.
+func ExpandPools(svc *Service) error {
+	seen := make(map[netip.Addr]bool, len(svc.Backends))
+	for _, be := range svc.Backends {
+		if addr, err := netip.ParseAddr(be.Address); err == nil {
+			seen[addr.Unmap()] = true
+		}
+	}
+
+	for i, pool := range svc.Pools {
+		backends, err := expandPool(pool)
+		if err != nil {
+			return fmt.Errorf("service %s pool[%d]: %w", svc.Name, i, err)
+		}
+		for _, be := range backends {
+			addr := netip.MustParseAddr(be.Address)
+			if seen[addr] {
+				return fmt.Errorf("service %s pool[%d]: duplicate backend: %s", svc.Name, i, be.Address)
+			}
+			seen[addr] = true
+			svc.Backends = append(svc.Backends, be)
+		}
+	}
+	svc.Pools = nil
+	return nil
+}
+
+// ExpandConfigPools returns a copy of cfg with the pools of every service
+// expanded. cfg is not modified.
+func ExpandConfigPools(cfg *Config) (*Config, error) {
+	next := *cfg
+	next.Services = make([]Service, len(cfg.Services))
+	for i, svc := range cfg.Services {
+		if len(svc.Pools) > 0 {
+			svc.Backends = append([]Backend(nil), svc.Backends...)
+			if err := ExpandPools(&svc); err != nil {
+				return nil, err
+			}
+		}
+		next.Services[i] = svc
+	}
+	return &next, nil
+}
+
+func expandPool(pool BackendPool) ([]Backend, error) {
+	prefix, err := netip.ParsePrefix(pool.CIDR)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cidr: %s", pool.CIDR)
+	}
+	prefix = prefix.Masked()
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	if hostBits > 12 {
+		return nil, fmt.Errorf("cidr %s exceeds %d addresses", pool.CIDR, maxPoolSize)
+	}
+	if pool.Weight < 1 {
+		return nil, fmt.Errorf("invalid weight: %d", pool.Weight)
+	}
+
+	exclude := make(map[netip.Addr]bool, len(pool.Exclude))
+	for _, s := range pool.Exclude {
+		addr, err := netip.ParseAddr(s)
+		if err != nil || !prefix.Contains(addr) {
+			return nil, fmt.Errorf("exclude %s is not an address in %s", s, pool.CIDR)
+		}
+		exclude[addr] = true
+	}
+	weights := make(map[netip.Addr]int, len(pool.Overrides))
+	for _, o := range pool.Overrides {
+		addr, err := netip.ParseAddr(o.Address)
+		if err != nil || !prefix.Contains(addr) {
+			return nil, fmt.Errorf("override %s is not an address in %s", o.Address, pool.CIDR)
+		}
+		if exclude[addr] {
+			return nil, fmt.Errorf("override %s is excluded", o.Address)
+		}
+		if o.Weight < 1 {
+			return nil, fmt.Errorf("override %s: invalid weight: %d", o.Address, o.Weight)
+		}
+		weights[addr] = o.Weight
+	}
+
+	first, last := prefix.Addr(), lastAddr(prefix)
+	if prefix.Addr().Is4() && hostBits >= 2 {
+		first, last = first.Next(), last.Prev()
+	}
+
+	var backends []Backend
+	for addr := first; addr.IsValid() && addr.Compare(last) <= 0; addr = addr.Next() {
+		if exclude[addr] {
+			continue
+		}
+		weight := pool.Weight
+		if w, ok := weights[addr]; ok {
+			weight = w
+		}
+		backends = append(backends, Backend{Address: addr.String(), Port: pool.Port, Weight: weight})
+	}
+	return backends, nil
+}
+
+// lastAddr returns the highest address in prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(b)*8; bit++ {
+		b[bit/8] |= 0x80 >> (bit % 8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A shell asks the daemon to reload a single service by leaving a request
+// file named after it in the include directory. The daemon picks requests up
+// on its next reconcile tick and removes each one once handled.
+const serviceReloadPrefix = ".reload."
+
+// RequestServiceReload asks the daemon to reload service name from dir.
+// Requesting a service that already has a pending request is a no-op.
+func RequestServiceReload(dir, name string) error {
+	if !nameRegex.MatchString(name) {
+		return fmt.Errorf("invalid service name: %q", name)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, serviceReloadPrefix+name), nil, 0644); err != nil {
+		return fmt.Errorf("failed to request reload of service %s: %w", name, err)
+	}
+	return nil
+}
+
+// PendingServiceReloads returns the services with a reload request in dir,
+// sorted by name.
+func PendingServiceReloads(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read reload requests: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		name, ok := strings.CutPrefix(e.Name(), serviceReloadPrefix)
+		// Temporary files of an in-flight request never match a service name
+		if ok && nameRegex.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ClearServiceReload removes the reload request for service name from dir.
+func ClearServiceReload(dir, name string) error {
+	if err := os.Remove(filepath.Join(dir, serviceReloadPrefix+name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clear reload request for service %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+	"gopkg.in/yaml.v3"
+)
+
+// A shell can stage a change bundle for later instead of committing it: the
+// bundle waits in the include directory until its activation time, when the
+// daemon commits it like a shell would and reloads. Only one bundle can be
+// scheduled at a time. The file name never matches a *.yaml include pattern.
+const ScheduledChangeFile = ".scheduled"
+
+// ErrChangeScheduled is returned by ScheduleChange when a bundle is already
+// waiting.
+var ErrChangeScheduled = errdefs.WithCode(errdefs.CodeConflict, errors.New("a change is already scheduled"))
+
+// ErrStaleScheduledChange is returned by CheckBase when a service the bundle
+// touches changed after the bundle was staged.
+var ErrStaleScheduledChange = errdefs.WithCode(errdefs.CodeConflict, errors.New("scheduled change is stale"))
+
+// ScheduledChange is a staged change bundle waiting for its activation time.
+type ScheduledChange struct {
+	At             time.Time         `yaml:"at"` // When the daemon activates the bundle
+	CreatedAt      time.Time         `yaml:"created_at"`
+	CreatedBy      string            `yaml:"created_by,omitempty"`
+	BaseGeneration uint64            `yaml:"base_generation"`         // Include dir generation the bundle was staged on
+	BaseServices   map[string]string `yaml:"base_services,omitempty"` // ServiceDigests of the touched services on BaseGeneration
+	Services       []Service         `yaml:"services,omitempty"`      // Added or replaced services
+	Deleted        []string          `yaml:"deleted,omitempty"`       // Removed services
+}
+
+// Touched returns the names of the services the bundle adds, replaces or
+// deletes, sorted.
+func (c *ScheduledChange) Touched() []string {
+	names := make([]string, 0, len(c.Services)+len(c.Deleted))
+	for _, svc := range c.Services {
+		names = append(names, svc.Name)
+	}
+	names = append(names, c.Deleted...)
+	sort.Strings(names)
+	return names
+}
+
+// ServiceDigests returns a digest of each named service in cfg, or "" for a
+// name cfg has no service for.
+func ServiceDigests(cfg *Config, names []string) map[string]string {
+	digests := make(map[string]string, len(names))
+	for _, name := range names {
+		digests[name] = ""
+	}
+	for _, svc := range cfg.Services {
+		if _, ok := digests[svc.Name]; !ok {
+			continue
+		}
+		data, err := yaml.Marshal(svc)
+		if err != nil {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		digests[svc.Name] = hex.EncodeToString(sum[:16])
+	}
+	return digests
+}
+
+// CheckBase fails with ErrStaleScheduledChange if cfg, the config the bundle
+// is about to be applied to, changed a service the bundle touches since
+// BaseGeneration, so the bundle would silently undo that change. Commits to
+// other services don't matter. Without BaseServices any commit is a
+// conflict.
+func (c *ScheduledChange) CheckBase(cfg *Config) error {
+	if cfg.Generation == c.BaseGeneration {
+		return nil
+	}
+	if c.BaseServices == nil {
+		return fmt.Errorf("%w: generation %d moved to %d since it was staged", ErrStaleScheduledChange, c.BaseGeneration, cfg.Generation)
+	}
+	names := c.Touched()
+	now := ServiceDigests(cfg, names)
+	var changed []string
+	for _, name := range names {
+		if now[name] != c.BaseServices[name] {
+			changed = append(changed, name)
+		}
+	}
+	if len(changed) > 0 {
+		return fmt.Errorf("%w: %s changed since generation %d (now %d)", ErrStaleScheduledChange, strings.Join(changed, ", "), c.BaseGeneration, cfg.Generation)
+	}
+	return nil
+}
+
+// Candidate returns cfg with the bundle applied and the pools of its
+// services expanded. cfg is not modified.
+func (c *ScheduledChange) Candidate(cfg *Config) (*Config, error) {
+	replaced := make(map[string]bool, len(c.Services)+len(c.Deleted))
+	for _, svc := range c.Services {
+		replaced[svc.Name] = true
+	}
+	for _, name := range c.Deleted {
+		replaced[name] = true
+	}
+	next := *cfg
+	next.Services = make([]Service, 0, len(cfg.Services)+len(c.Services))
+	for _, svc := range cfg.Services {
+		if !replaced[svc.Name] {
+			next.Services = append(next.Services, svc)
+		}
+	}
+	next.Services = append(next.Services, c.Services...)
+	return ExpandConfigPools(&next)
+}
+
+// ScheduleChange writes change to dir. It fails with ErrChangeScheduled if a
+// bundle is already waiting.
+func ScheduleChange(dir string, change *ScheduledChange) error {
+	pending, err := ReadScheduledChange(dir)
+	if err != nil {
+		return err
+	}
+	if pending != nil {
+		return fmt.Errorf("%w for %s", ErrChangeScheduled, pending.At.Format(time.RFC3339))
+	}
+	data, err := yaml.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("failed to marshal scheduled change: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, ScheduledChangeFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write scheduled change: %w", err)
+	}
+	return nil
+}
+
+// ReadScheduledChange returns the bundle waiting in dir, or nil if there is
+// none.
+func ReadScheduledChange(dir string) (*ScheduledChange, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ScheduledChangeFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read scheduled change: %w", err)
+	}
+	var change ScheduledChange
+	if err := yaml.Unmarshal(data, &change); err != nil {
+		return nil, fmt.Errorf("invalid scheduled change: %w", err)
+	}
+	return &change, nil
+}
+
+// CancelScheduledChange removes the bundle waiting in dir. It reports whether
+// there was one.
+func CancelScheduledChange(dir string) (bool, error) {
+	err := os.Remove(filepath.Join(dir, ScheduledChangeFile))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel scheduled change: %w", err)
+	}
+	return true, nil
+}
+
+// ActivateScheduledChange commits change to dir between two generation bumps,
+// the same way a shell commit does, and removes the waiting bundle. It
+// returns the new generation. The caller must hold LockCommit from checking
+// the bundle until this returns.
+func ActivateScheduledChange(dir string, change *ScheduledChange) (uint64, error) {
+	inProgress, err := BeginCommit(dir)
+	if err != nil {
+		return 0, err
+	}
+	var writeErr error
+	for _, svc := range change.Services {
+		if writeErr = WriteServiceConfig(dir, svc); writeErr != nil {
+			break
+		}
+	}
+	if writeErr == nil {
+		for _, name := range change.Deleted {
+			if !nameRegex.MatchString(name) {
+				writeErr = fmt.Errorf("invalid service name: %q", name)
+				break
+			}
+			if err := os.Remove(filepath.Join(dir, name+".yaml")); err != nil && !os.IsNotExist(err) {
+				writeErr = fmt.Errorf("failed to remove service %s: %w", name, err)
+				break
+			}
+		}
+	}
+	// Publish even after a failed write so loads aren't blocked on an odd generation
+	gen, err := EndCommit(dir, inProgress)
+	if writeErr != nil {
+		return 0, writeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	if _, err := CancelScheduledChange(dir); err != nil {
+		return gen, err
+	}
+	return gen, nil
+}
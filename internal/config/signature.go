@@ -0,0 +1,109 @@
+package config
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// PublicKey verifies minisign signatures of config bundles. Bundles are
+// signed with the minisign tool (https://jedisct1.github.io/minisign/):
+//
+//	minisign -S -s fleet.key -m bundle.tar.gz
+//
+// writes the detached signature bundle.tar.gz.minisig next to the bundle.
+type PublicKey struct {
+	id  [8]byte
+	key ed25519.PublicKey
+}
+
+// ParsePublicKey parses a minisign public key: either the contents of its
+// .pub file or just the base64 line.
+func ParsePublicKey(s string) (*PublicKey, error) {
+	lines := minisignLines(s)
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "untrusted comment:") {
+		lines = lines[1:]
+	}
+	if len(lines) != 1 {
+		return nil, fmt.Errorf("invalid minisign public key")
+	}
+	raw, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != "Ed" {
+		return nil, fmt.Errorf("invalid minisign public key")
+	}
+	k := &PublicKey{key: ed25519.PublicKey(raw[10:])}
+	copy(k.id[:], raw[2:10])
+	return k, nil
+}
+
+// ID returns the key ID minisign prints for the key.
+func (k *PublicKey) ID() string {
+	return fmt.Sprintf("%X", reverse(k.id[:]))
+}
+
+// Verify checks sig, the contents of a .minisig file, against data. Both the
+// prehashed signatures minisign makes by default and legacy ones (-l) are
+// accepted; either way the trusted comment must be signed too.
+func (k *PublicKey) Verify(data, sig []byte) error {
+	lines := minisignLines(string(sig))
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "untrusted comment:") {
+		return fmt.Errorf("invalid minisign signature")
+	}
+	trusted, ok := strings.CutPrefix(lines[2], "trusted comment: ")
+	if !ok {
+		return fmt.Errorf("invalid minisign signature: missing trusted comment")
+	}
+	raw, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(raw) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("invalid minisign signature")
+	}
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return fmt.Errorf("invalid minisign signature")
+	}
+	if !bytes.Equal(raw[2:10], k.id[:]) {
+		return fmt.Errorf("signature made with key %X, not %s", reverse(raw[2:10]), k.ID())
+	}
+
+	signed := data
+	switch string(raw[:2]) {
+	case "ED":
+		digest := blake2b.Sum512(data)
+		signed = digest[:]
+	case "Ed":
+	default:
+		return fmt.Errorf("unsupported minisign signature algorithm %q", raw[:2])
+	}
+	if !ed25519.Verify(k.key, signed, raw[10:]) {
+		return fmt.Errorf("signature verification failed")
+	}
+	if !ed25519.Verify(k.key, append(raw[10:len(raw):len(raw)], trusted...), global) {
+		return fmt.Errorf("trusted comment signature verification failed")
+	}
+	return nil
+}
+
+// minisignLines splits a minisign file into its non-empty lines
+func minisignLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// reverse returns b in reverse order: minisign prints key IDs as
+// little-endian numbers.
+func reverse(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		out[len(b)-1-i] = c
+	}
+	return out
+}
@@ -1,5 +1,7 @@
 package config
 
+import "strings"
+
 // Config represents the global configuration
 type Config struct {
 	Mode          string        `yaml:"mode"`
@@ -9,8 +11,12 @@ type Config struct {
 	Observability ObsConfig     `yaml:"observability"`
 	System        SystemConfig  `yaml:"system"`
 	Daemon        DaemonConfig  `yaml:"daemon"`
+	Lint          LintConfig    `yaml:"lint,omitempty"`
 	Include       string        `yaml:"include"`
-	Services      []Service     `yaml:"services"` // Merged from config.d
+	Vars          string        `yaml:"vars,omitempty"` // NAME=value file for ${VAR} references, see ParseVars
+	Services      []Service     `yaml:"services"`       // Merged from config.d
+
+	Generation uint64 `yaml:"-" json:"-"` // Commit generation of the include directory at load time
 }
 
 type NodeConfig struct {
@@ -24,9 +30,20 @@ type NetworkConfig struct {
 }
 
 type InterfaceConfig struct {
-	Interface string `yaml:"interface"`
-	VIP       string `yaml:"vip,omitempty"`
-	CIDR      int    `yaml:"cidr,omitempty"`
+	Interface string   `yaml:"interface"`
+	VIP       string   `yaml:"vip,omitempty"`
+	VIPs      []string `yaml:"vips,omitempty"` // Additional VIPs, owned together with VIP
+	CIDR      int      `yaml:"cidr,omitempty"`
+}
+
+// AllVIPs returns VIP followed by the additional VIPs. Services without a vip
+// of their own are placed on VIP.
+func (c InterfaceConfig) AllVIPs() []string {
+	vips := make([]string, 0, 1+len(c.VIPs))
+	if c.VIP != "" {
+		vips = append(vips, c.VIP)
+	}
+	return append(vips, c.VIPs...)
 }
 
 type VRRPConfig struct {
@@ -34,16 +51,57 @@ type VRRPConfig struct {
 	PriorityPrimary   int `yaml:"priority_primary"`
 	PrioritySecondary int `yaml:"priority_secondary"`
 	AdvertIntervalMS  int `yaml:"advert_interval_ms"`
+	// PreemptAfterReady renders the VRRP instance with preemption disabled and
+	// lets the daemon enable it once the first reconcile has succeeded.
+	PreemptAfterReady bool `yaml:"preempt_after_ready"`
+
+	// PeerAddress is the other node's address for the heartbeat channel.
+	// Each node sends a UDP heartbeat to HeartbeatPort every reconcile
+	// interval; empty disables the channel.
+	PeerAddress   string `yaml:"peer_address,omitempty"`
+	HeartbeatPort int    `yaml:"heartbeat_port,omitempty"` // Default 5406
+
+	// StateSource decides when the node is active: "vip" (default) while
+	// the VIP is on an interface, "frr" while FRR reports the VRRP router
+	// as Master.
+	StateSource string `yaml:"state_source,omitempty"`
 }
 
+// vrrp.state_source values
+const (
+	VRRPStateSourceVIP = "vip"
+	VRRPStateSourceFRR = "frr"
+)
+
 type ObsConfig struct {
 	Logging LoggingConfig `yaml:"logging"`
 	Metrics MetricsConfig `yaml:"metrics"`
 }
 
 type LoggingConfig struct {
-	Console ConsoleLogConfig `yaml:"console"`
-	GELF    GELFLogConfig    `yaml:"gelf"`
+	Console    ConsoleLogConfig   `yaml:"console"`
+	GELF       GELFLogConfig      `yaml:"gelf"`
+	AuditDedup []AuditDedupConfig `yaml:"audit_dedup,omitempty"`
+
+	Connections ConnLogConfig `yaml:"connections"`
+}
+
+// ConnLogConfig logs a sample of new IPVS connections, read from the kernel
+// connection table every reconcile interval while this node owns the VIP.
+// Client addresses are truncated to a network prefix.
+type ConnLogConfig struct {
+	Enabled        bool    `yaml:"enabled"`
+	SampleRate     float64 `yaml:"sample_rate,omitempty"`      // Fraction of new connections logged, 0-1 (default 0.01)
+	ClientPrefixV4 *int    `yaml:"client_prefix_v4,omitempty"` // Default 24; 0 logs no client address bits
+	ClientPrefixV6 *int    `yaml:"client_prefix_v6,omitempty"` // Default 64
+}
+
+// AuditDedupConfig collapses repeated audit events of one type into a summary
+type AuditDedupConfig struct {
+	Event         string   `yaml:"event"`
+	WindowSeconds int      `yaml:"window_seconds"`
+	Burst         int      `yaml:"burst"`                // Events emitted verbatim per window (default 1)
+	KeyFields     []string `yaml:"key_fields,omitempty"` // Default: service_name, backend
 }
 
 type ConsoleLogConfig struct {
@@ -52,16 +110,33 @@ type ConsoleLogConfig struct {
 }
 
 type GELFLogConfig struct {
-	Enabled  bool   `yaml:"enabled"`
-	Host     string `yaml:"host"`
-	Port     int    `yaml:"port"`
-	Protocol string `yaml:"protocol"`
-	Facility string `yaml:"facility"`
+	Enabled          bool   `yaml:"enabled"`
+	Host             string `yaml:"host"`
+	Port             int    `yaml:"port"`
+	Protocol         string `yaml:"protocol"`
+	Facility         string `yaml:"facility"`
+	Compression      string `yaml:"compression,omitempty"`       // UDP: gzip (default), zlib, none
+	CompressionLevel int    `yaml:"compression_level,omitempty"` // UDP: 1-9, 0 = library default
+	BatchSize        int    `yaml:"batch_size,omitempty"`        // TCP: messages per write, <= 1 disables batching
+	FlushIntervalMS  int    `yaml:"flush_interval_ms,omitempty"` // TCP: max delay before a partial batch is sent
 }
 
 type MetricsConfig struct {
-	InfluxDB   InfluxConfig   `yaml:"influxdb"`
-	Prometheus PromConfig     `yaml:"prometheus"`
+	InfluxDB   InfluxConfig `yaml:"influxdb"`
+	Prometheus PromConfig   `yaml:"prometheus"`
+
+	IPFIX IPFIXConfig `yaml:"ipfix"`
+
+	MaxSeriesPerMetric int `yaml:"max_series_per_metric,omitempty"` // Cardinality budget per metric (default 10000)
+
+	IPVSStatsIntervalSeconds int `yaml:"ipvs_stats_interval_seconds,omitempty"` // How often lbctl_ipvs_* gauges are refreshed (default 10)
+
+	// Cluster and Peer are added as cluster/peer labels to every exported
+	// series, so both nodes of an HA pair can be aggregated as one logical
+	// load balancer. Peer defaults to node.name; neither is added unless
+	// Cluster is set.
+	Cluster string `yaml:"cluster,omitempty"`
+	Peer    string `yaml:"peer,omitempty"`
 }
 
 type InfluxConfig struct {
@@ -73,6 +148,17 @@ type InfluxConfig struct {
 	PushIntervalSeconds int    `yaml:"push_interval_seconds"`
 }
 
+// IPFIXConfig exports load-balanced flows, read from the IPVS connection
+// table every reconcile interval while this node owns the VIP, to an IPFIX
+// collector over UDP.
+type IPFIXConfig struct {
+	Enabled           bool    `yaml:"enabled"`
+	Collector         string  `yaml:"collector"`                    // host:port
+	SampleRate        float64 `yaml:"sample_rate,omitempty"`        // Fraction of new flows exported, 0-1 (default 1)
+	CacheSize         int     `yaml:"cache_size,omitempty"`         // Flows buffered per export; excess is dropped (default 1024)
+	ObservationDomain uint32  `yaml:"observation_domain,omitempty"` // IPFIX observation domain ID
+}
+
 type PromConfig struct {
 	Enabled bool   `yaml:"enabled"`
 	Port    int    `yaml:"port"`
@@ -81,17 +167,184 @@ type PromConfig struct {
 }
 
 type SystemConfig struct {
-	StateDir               string       `yaml:"state_dir"`
-	FRRConfig              string       `yaml:"frr_config"`
-	SysctlFile             string       `yaml:"sysctl_file"`
-	TuningProfile          string       `yaml:"tuning_profile"`
-	LockIdleTimeoutMinutes int          `yaml:"lock_idle_timeout_minutes"`
+	StateDir               string `yaml:"state_dir"`
+	FRRConfig              string `yaml:"frr_config"`
+	SysctlFile             string `yaml:"sysctl_file"`
+	TuningProfile          string `yaml:"tuning_profile"`
+	LockIdleTimeoutMinutes int    `yaml:"lock_idle_timeout_minutes"`
+}
+
+// LintConfig tunes the lint command's best-practice rules
+type LintConfig struct {
+	Ignore       []string `yaml:"ignore,omitempty"`         // Rule IDs suppressed for every service
+	MaxPortRange int      `yaml:"max_port_range,omitempty"` // Widest port range before LB005 fires (default 1024)
 }
 
 // DaemonConfig holds runtime daemon settings
 type DaemonConfig struct {
-	ReconcileIntervalMS int         `yaml:"reconcile_interval_ms"`
-	StateCache          CacheConfig `yaml:"state_cache"`
+	ReconcileIntervalMS int                `yaml:"reconcile_interval_ms"`
+	VIPCheckIntervalMS  int                `yaml:"vip_check_interval_ms,omitempty"` // How often to check for the VIP; defaults to reconcile_interval_ms
+	StateCache          CacheConfig        `yaml:"state_cache"`
+	Health              DaemonHealthConfig `yaml:"health"`
+	Resolver            ResolverConfig     `yaml:"resolver"`
+
+	Drain        DrainConfig        `yaml:"drain"`
+	ConnSync     ConnSyncConfig     `yaml:"conn_sync"`
+	IPVSTimeouts IPVSTimeoutsConfig `yaml:"ipvs_timeouts"`
+
+	// Cleanup decides what happens to IPVS services on a managed VIP that
+	// aren't in the config and that lbctl never created or adopted: strict
+	// (default) deletes them, warn keeps them and logs a warning, ignore
+	// keeps them silently. Kept services are exported as
+	// lbctl_ipvs_foreign_service either way. Services lbctl owns are deleted
+	// once they leave the config under every policy.
+	Cleanup string `yaml:"cleanup,omitempty"`
+
+	// DryRun programs an in-memory IPVS instead of the kernel and logs the
+	// operations it would have made, so the daemon can run on hosts without
+	// IPVS.
+	DryRun bool `yaml:"dry_run,omitempty"`
+
+	// ReconcileConcurrency is how many IPVS reads and writes a reconcile
+	// makes at once, each on its own netlink socket. 0 or 1 works through
+	// services one at a time; large port ranges reconcile faster with more.
+	// At most 64.
+	ReconcileConcurrency int `yaml:"reconcile_concurrency,omitempty"`
+
+	ReconcileBackoff ReconcileBackoffConfig `yaml:"reconcile_backoff,omitempty"`
+
+	AutoReload AutoReloadConfig `yaml:"auto_reload"`
+
+	// FailoverBudgetMS is how long IPVS may take to match the config after
+	// the node acquires the VIP before the daemon logs a warning; 0 never
+	// warns. The time is exported as lbctl_failover_programming_seconds
+	// either way.
+	FailoverBudgetMS int `yaml:"failover_budget_ms,omitempty"`
+
+	// WarmStandby keeps every service and destination programmed at weight
+	// 0 while the node doesn't hold the VIP, so acquiring it only has to
+	// set weights instead of creating every kernel object.
+	WarmStandby bool `yaml:"warm_standby,omitempty"`
+
+	// StartupPolicy decides what a node that starts without the VIP does
+	// with IPVS services already on a managed VIP, e.g. left by a crash:
+	// cleanup (default) removes them like losing the VIP would, preserve
+	// leaves them until the VIP changes, and adopt leaves them but takes
+	// ownership of those that match the config. A node that starts with the
+	// VIP reconciles under every policy, and warm_standby always reprograms
+	// services at weight 0.
+	StartupPolicy string `yaml:"startup_policy,omitempty"`
+
+	// ShutdownPolicy decides what a daemon stopping on SIGTERM or SIGINT
+	// does with the IPVS services it manages: preserve (default) leaves them
+	// so traffic keeps flowing across a restart, teardown removes them, and
+	// drain sets every destination's weight to 0, waits up to
+	// ShutdownDrainTimeoutMS (default 30000) for active connections to
+	// finish, then removes them.
+	ShutdownPolicy         string `yaml:"shutdown_policy,omitempty"`
+	ShutdownDrainTimeoutMS int    `yaml:"shutdown_drain_timeout_ms,omitempty"`
+
+	API APIConfig `yaml:"api,omitempty"`
+}
+
+// APIConfig configures admin APIs in addition to the control socket in
+// system.state_dir, which is always served.
+type APIConfig struct {
+	HTTP HTTPAPIConfig `yaml:"http,omitempty"`
+}
+
+// HTTPAPIConfig serves the admin API over HTTP for integrations that can't
+// reach the control socket. Every request must carry
+// "Authorization: Bearer <token>" with Token or one of Tokens.
+type HTTPAPIConfig struct {
+	Enabled bool       `yaml:"enabled"`
+	Bind    string     `yaml:"bind,omitempty"` // Bind address (default 127.0.0.1; "0.0.0.0" or "::" = all interfaces)
+	Port    int        `yaml:"port"`
+	Token   string     `yaml:"token,omitempty"`  // At least 16 characters; audited by a hash prefix
+	Tokens  []APIToken `yaml:"tokens,omitempty"` // One per integration, audited and rate limited by name
+}
+
+// APIToken is a named admin API token.
+type APIToken struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token"` // At least 16 characters
+}
+
+// ConnSyncConfig runs the kernel's IPVS connection sync daemon so established
+// connections survive a VIP failover. The node owning the VIP runs it as
+// master and multicasts its connection table on Interface; the standby runs
+// it as backup and keeps a copy. Both nodes must use the same SyncID.
+type ConnSyncConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Interface string `yaml:"interface"`
+	SyncID    int    `yaml:"sync_id"` // 0-255, distinguishes LB pairs sharing a network
+}
+
+// IPVSTimeoutsConfig sets the kernel's IPVS connection timeouts, in seconds,
+// like ipvsadm --set. They apply to every virtual service on the node. 0
+// leaves a timeout at its current kernel value; removing a timeout from config
+// does not restore the kernel default.
+type IPVSTimeoutsConfig struct {
+	TCP    int `yaml:"tcp,omitempty"`     // Established TCP connections (kernel default 900)
+	TCPFin int `yaml:"tcp_fin,omitempty"` // TCP connections after a FIN (kernel default 120)
+	UDP    int `yaml:"udp,omitempty"`     // UDP flows (kernel default 300)
+}
+
+// DrainConfig controls how backends leave IPVS. When enabled, a backend
+// removed from config (or marked drain) is set to weight 0 and only deleted
+// once its connections fall to Threshold or TimeoutMS has passed. UDP
+// backends count their inactive entries too, as UDP has no active state. A
+// service removed from config is deleted once its backends have drained.
+type DrainConfig struct {
+	Enabled   bool `yaml:"enabled"`
+	TimeoutMS int  `yaml:"timeout_ms"` // Default 300000 (5 minutes)
+	Threshold int  `yaml:"threshold"`  // Connections at or below which the backend is deleted
+}
+
+// ReconcileBackoffConfig spaces out retries of a failing reconcile. The
+// first retry is immediate; the next waits InitialMS and each one after that
+// twice as long, up to MaxMS, plus up to 20% jitter. Once BreakerThreshold
+// reconciles in a row have failed the circuit breaker opens: retries wait
+// MaxMS, lbctl_reconcile_circuit_open is set and a reconcile_circuit_open
+// audit event is emitted, so alerting can page. The next success closes it.
+type ReconcileBackoffConfig struct {
+	InitialMS        int `yaml:"initial_ms,omitempty"`        // Default 5000
+	MaxMS            int `yaml:"max_ms,omitempty"`            // Default 10000
+	BreakerThreshold int `yaml:"breaker_threshold,omitempty"` // Default 5
+}
+
+// AutoReloadConfig reloads the daemon when the config file or an included
+// file changes on disk, as if it had been sent SIGHUP.
+type AutoReloadConfig struct {
+	Enabled    bool `yaml:"enabled"`
+	DebounceMS int  `yaml:"debounce_ms"` // Files must be unchanged this long before reloading (default 2000)
+}
+
+// DaemonHealthConfig holds settings shared by all health checks
+type DaemonHealthConfig struct {
+	MaxInFlight int `yaml:"max_in_flight"` // Concurrent checks across all backends, 0 = unlimited
+
+	// TCP and UDP checks bind local ports in [SourcePortMin, SourcePortMax]
+	// instead of the kernel's ephemeral range; both 0 leaves it to the kernel
+	SourcePortMin int  `yaml:"source_port_min,omitempty"`
+	SourcePortMax int  `yaml:"source_port_max,omitempty"`
+	ReuseAddr     bool `yaml:"reuse_addr,omitempty"` // SO_REUSEADDR on check sockets, so ports in TIME_WAIT can be rebound
+
+	// StandbyMode is how a node that doesn't hold the VIP checks backends:
+	// "full" (default) at the configured intervals, "reduced" at a fifth of
+	// that rate, or "off". Acquiring the VIP checks every backend at once;
+	// a state older than fail_after intervals is dropped, so that check
+	// alone decides it.
+	StandbyMode string `yaml:"standby_mode,omitempty"`
+}
+
+// ResolverConfig holds settings for the DNS cache used to resolve hostname
+// backends. Zero values use the resolver defaults.
+type ResolverConfig struct {
+	Servers       []string `yaml:"servers,omitempty"` // "ip" or "ip:port", queried directly; default from /etc/resolv.conf
+	TimeoutMS     int      `yaml:"timeout_ms"`
+	CacheTTLMS    int      `yaml:"cache_ttl_ms"`    // Longest time resolved addresses are cached; answers from servers expire with their record TTLs first (default 30000)
+	NegativeTTLMS int      `yaml:"negative_ttl_ms"` // How long NXDOMAIN/no-address results are cached
 }
 
 // CacheConfig holds settings for the in-memory IPVS state cache
@@ -107,12 +360,52 @@ type ServiceConfig struct {
 
 type Service struct {
 	Name       string        `yaml:"name"`
-	Protocol   string        `yaml:"protocol"`
-	Ports      []int         `yaml:"ports"`
+	VIP        string        `yaml:"vip,omitempty"` // One of network.frontend vip/vips; default network.frontend.vip
+	Protocol   string        `yaml:"protocol"`      // tcp, udp or tcp+udp, see Protocols
+	Ports      []int         `yaml:"ports"`         // [0] alone is a catch-all for every port, see CatchAll
 	PortRanges []PortRange   `yaml:"port_ranges"`
 	Scheduler  string        `yaml:"scheduler"`
 	Backends   []Backend     `yaml:"backends"`
+	Pools      []BackendPool `yaml:"pools,omitempty"` // CIDR ranges expanded into Backends at load time
 	Health     HealthCheck   `yaml:"health"`
+
+	Labels        map[string]string    `yaml:"labels,omitempty"` // Free-form tags for selectors, metrics and audit events
+	Observability ServiceObservability `yaml:"observability,omitempty"`
+
+	SchedulerFlags []string `yaml:"scheduler_flags,omitempty"` // Scheduler-specific flags, e.g. sh-fallback, sh-port
+
+	PersistenceTimeout int    `yaml:"persistence_timeout,omitempty"` // Seconds a client sticks to its backend; 0 disables persistence
+	PersistenceNetmask string `yaml:"persistence_netmask,omitempty"` // Group clients by this mask (e.g. 255.255.255.0); default 255.255.255.255
+
+	Quota ServiceQuota `yaml:"quota,omitempty"`
+
+	LintIgnore []string `yaml:"lint_ignore,omitempty"` // Lint rule IDs to suppress for this service
+}
+
+// ServiceQuota sets soft limits on a service's traffic, summed over all its
+// ports and both directions. Exceeding one emits a quota_exceeded audit event
+// but never drops traffic. Zero disables a limit.
+type ServiceQuota struct {
+	BytesPerSecond       int64 `yaml:"bytes_per_second,omitempty"`
+	PacketsPerSecond     int64 `yaml:"packets_per_second,omitempty"`
+	ConnectionsPerSecond int64 `yaml:"connections_per_second,omitempty"`
+}
+
+// IPVSSchedulers lists the IPVS schedulers a service may use.
+var IPVSSchedulers = []string{"rr", "wrr", "lc", "wlc", "sed", "nq", "dh", "sh", "lblc", "lblcr"}
+
+// IPVSSchedulerFlags maps each scheduler flag to the scheduler it applies to.
+var IPVSSchedulerFlags = map[string]string{"sh-fallback": "sh", "sh-port": "sh"}
+
+// ForwardMethods lists the IPVS forwarding methods accepted by mode and by a
+// backend's forward override.
+var ForwardMethods = []string{"dr", "nat", "tun"}
+
+// ServiceObservability trims the metrics and logs a service produces, for
+// services with enough backends to strain time-series cardinality.
+type ServiceObservability struct {
+	DisableBackendMetrics bool   `yaml:"disable_backend_metrics,omitempty"` // Skip per-backend health metrics
+	HealthLogLevel        string `yaml:"health_log_level,omitempty"`        // Minimum severity of health events to log (default info)
 }
 
 type PortRange struct {
@@ -121,17 +414,134 @@ type PortRange struct {
 }
 
 type Backend struct {
-	Address string `yaml:"address"`
-	Port    int    `yaml:"port"`
-	Weight  int    `yaml:"weight"`
+	Address      string `yaml:"address"`
+	Port         int    `yaml:"port"`
+	Weight       int    `yaml:"weight"`
+	CheckAddress string `yaml:"check_address,omitempty"` // Health check this address instead of Address (e.g. a sidecar)
+	CheckPort    int    `yaml:"check_port,omitempty"`    // Overrides health.port for this backend
+
+	// PortOffset maps each service port p to p+PortOffset on this backend.
+	// PortRange maps the service's port range onto this one, position for
+	// position. Both need nat forwarding; set at most one of Port, PortOffset
+	// and PortRange.
+	PortOffset int       `yaml:"port_offset,omitempty"`
+	PortRange  PortRange `yaml:"port_range,omitempty"`
+
+	// Forward overrides the forwarding method the global mode selects for
+	// this backend: dr, nat or tun. Lets a mostly-DR service tunnel to
+	// backends in another L2 domain.
+	Forward string `yaml:"forward,omitempty"`
+
+	// Connection thresholds (IPVS u_threshold/l_threshold). Once the backend
+	// has UpperThreshold connections it gets no new ones until it falls to
+	// LowerThreshold, or to 3/4 of UpperThreshold when that is 0. 0 disables.
+	UpperThreshold int `yaml:"upper_threshold,omitempty"`
+	LowerThreshold int `yaml:"lower_threshold,omitempty"`
+
+	// Drain takes the backend out of service for maintenance while keeping
+	// it in config: it is removed from IPVS like a deleted backend, gracefully
+	// when daemon.drain is enabled.
+	Drain bool `yaml:"drain,omitempty"`
+}
+
+// BackendPortOffset is what b adds to each port of s to get its own port,
+// from b's port_offset or port_range. It is 0 for a backend on a fixed port
+// or the service's own.
+func (s Service) BackendPortOffset(b Backend) int {
+	if b.PortRange != (PortRange{}) && len(s.PortRanges) > 0 {
+		return b.PortRange.Start - s.PortRanges[0].Start
+	}
+	return b.PortOffset
+}
+
+// ProtocolTCPUDP declares a service on both tcp and udp, e.g. DNS on port 53.
+const ProtocolTCPUDP = "tcp+udp"
+
+// Protocols returns the IPVS protocols s is programmed on: tcp and udp for
+// ProtocolTCPUDP, which share one health check and one service name in
+// metrics and audit events.
+func (s Service) Protocols() []string {
+	switch strings.ToLower(strings.TrimSpace(s.Protocol)) {
+	case ProtocolTCPUDP:
+		return []string{"tcp", "udp"}
+	case "udp":
+		return []string{"udp"}
+	default:
+		return []string{"tcp"}
+	}
+}
+
+// CatchAll reports whether s is an IPVS port-0 service, declared as
+// ports: [0], which takes connections to every port of its VIP that no other
+// service claims. The kernel only matches it with persistence on, and each
+// connection keeps its destination port.
+func (s Service) CatchAll() bool {
+	return len(s.Ports) == 1 && s.Ports[0] == 0 && len(s.PortRanges) == 0
 }
 
 type HealthCheck struct {
-	Enabled      bool   `yaml:"enabled"`
-	Type         string `yaml:"type"`
-	Port         int    `yaml:"port"`
-	IntervalMS   int    `yaml:"interval_ms"`
-	TimeoutMS    int    `yaml:"timeout_ms"`
-	FailAfter    int    `yaml:"fail_after"`
-	RecoverAfter int    `yaml:"recover_after"`
+	Enabled        bool   `yaml:"enabled"`
+	Type           string `yaml:"type"`
+	Port           int    `yaml:"port"`
+	IntervalMS     int    `yaml:"interval_ms"`
+	TimeoutMS      int    `yaml:"timeout_ms"`
+	FailAfter      int    `yaml:"fail_after"`
+	RecoverAfter   int    `yaml:"recover_after"`
+	JitterPct      int    `yaml:"jitter_percent,omitempty"`   // Random start offset, as % of interval
+	SlowStartMS    int    `yaml:"slow_start_ms,omitempty"`    // Weight ramp duration after recovery; 0 disables
+	SlowStartSteps int    `yaml:"slow_start_steps,omitempty"` // Weight increments during the ramp (default 4)
+	AdaptiveWeight bool   `yaml:"adaptive_weight,omitempty"`  // Scale weight by check latency relative to the fastest backend
+	MinHealthy     int    `yaml:"min_healthy,omitempty"`      // Below this many healthy backends, apply Fallback; 0 disables
+	Fallback       string `yaml:"fallback,omitempty"`         // "all" (configured weights) or "last_healthy" (default all); drained backends stay at 0
+	Payload        string `yaml:"payload,omitempty"`          // UDP: datagram sent on each check
+	Expect         string `yaml:"expect,omitempty"`           // UDP: substring required in the reply
+	QueryName      string `yaml:"query_name,omitempty"`       // DNS: name to resolve
+	QueryType      string `yaml:"query_type,omitempty"`       // DNS: A, AAAA or SRV (default A)
+	ExpectRcode    string `yaml:"expect_rcode,omitempty"`     // DNS: expected rcode (default NOERROR)
+	MySQLUser      string `yaml:"mysql_user,omitempty"`       // MySQL: user the check logs in as, without a password (default lbctl)
+	TCPMode        string `yaml:"tcp_mode,omitempty"`         // TCP: connect (default), half_open or reuse
+	TCPReset       bool   `yaml:"tcp_reset,omitempty"`        // TCP: close with RST (SO_LINGER 0) instead of FIN
+	TCPKeepaliveMS int    `yaml:"tcp_keepalive_ms,omitempty"` // TCP reuse: idle time before keepalive probes on held connections (default interval_ms)
+
+	// Composite checks: Probes run alongside the primary check above
+	Probes  []HealthProbe `yaml:"probes,omitempty"`
+	Combine string        `yaml:"combine,omitempty"` // "all" (default) or "any" check must pass
+
+	// Ping each backend first; no echo reply fails the check as host down
+	// without probing the service
+	ICMPPrecheck bool `yaml:"icmp_precheck,omitempty"`
+}
+
+// HealthProbe is an additional check run alongside the primary health check.
+// Port 0 uses the backend's check port.
+type HealthProbe struct {
+	Type        string `yaml:"type"`
+	Port        int    `yaml:"port,omitempty"`
+	Payload     string `yaml:"payload,omitempty"`
+	Expect      string `yaml:"expect,omitempty"`
+	QueryName   string `yaml:"query_name,omitempty"`
+	QueryType   string `yaml:"query_type,omitempty"`
+	ExpectRcode string `yaml:"expect_rcode,omitempty"`
+	MySQLUser   string `yaml:"mysql_user,omitempty"`
+
+	TCPMode        string `yaml:"tcp_mode,omitempty"`
+	TCPReset       bool   `yaml:"tcp_reset,omitempty"`
+	TCPKeepaliveMS int    `yaml:"tcp_keepalive_ms,omitempty"`
+}
+
+// Primary returns the top-level check as a probe on the backend's check port
+func (h HealthCheck) Primary() HealthProbe {
+	return HealthProbe{
+		Type:        h.Type,
+		Payload:     h.Payload,
+		Expect:      h.Expect,
+		QueryName:   h.QueryName,
+		QueryType:   h.QueryType,
+		ExpectRcode: h.ExpectRcode,
+		MySQLUser:   h.MySQLUser,
+
+		TCPMode:        h.TCPMode,
+		TCPReset:       h.TCPReset,
+		TCPKeepaliveMS: h.TCPKeepaliveMS,
+	}
 }
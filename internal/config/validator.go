@@ -4,27 +4,77 @@ import (
 	"fmt"
 	"net"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
 )
 
 var (
 	// Regex for validation
 	nameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	// hostLabelRegex matches one label of a DNS hostname
+	hostLabelRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
 
 	// Injection characters check
 	injectionChars = []string{";", "'", "\"", "`", "&", "|", ">", "<"}
+
+	validHealthTypes = map[string]bool{"tcp": true, "udp": true, "dns": true, "redis": true, "mysql": true}
+	validDNSTypes    = map[string]bool{"": true, "a": true, "aaaa": true, "srv": true}
+	validDNSRcodes   = map[string]bool{"": true, "noerror": true, "formerr": true, "servfail": true, "nxdomain": true, "notimp": true, "refused": true}
+	validFallbacks   = map[string]bool{"": true, "all": true, "last_healthy": true}
+	validCombines    = map[string]bool{"": true, "all": true, "any": true}
+	validTCPModes    = map[string]bool{"": true, "connect": true, "half_open": true, "reuse": true}
+	validLogLevels   = map[string]bool{"": true, "debug": true, "info": true, "warn": true, "error": true}
+	validCleanups    = map[string]bool{"": true, "strict": true, "warn": true, "ignore": true}
+	validStandbyMode = map[string]bool{"": true, "full": true, "reduced": true, "off": true}
+	validStartups    = map[string]bool{"": true, "cleanup": true, "preserve": true, "adopt": true}
+	validShutdowns   = map[string]bool{"": true, "preserve": true, "teardown": true, "drain": true}
 )
 
-// Validate checks the configuration for errors
+// Validate checks the configuration for errors. They are tagged
+// errdefs.ErrPermanentConfig.
 func Validate(cfg *Config) error {
 	if err := validateGlobal(cfg); err != nil {
-		return err
+		return errdefs.PermanentConfig(err)
 	}
 
 	if err := validateServices(cfg); err != nil {
-		return err
+		return errdefs.PermanentConfig(err)
 	}
 
+	return errdefs.PermanentConfig(validateForwarding(cfg))
+}
+
+// validateForwarding checks backend settings that depend on the global mode,
+// which validateSingleService doesn't know.
+func validateForwarding(cfg *Config) error {
+	for _, svc := range cfg.Services {
+		for j, be := range svc.Backends {
+			if svc.CatchAll() && (be.Port != 0 || be.PortOffset != 0 || be.PortRange != (PortRange{})) {
+				forward := be.Forward
+				if forward == "" {
+					forward = cfg.Mode
+				}
+				if strings.EqualFold(strings.TrimSpace(forward), "nat") {
+					return fmt.Errorf("service %s backend[%d]: a port 0 service keeps each connection's destination port; remove port, port_offset and port_range", svc.Name, j)
+				}
+				return fmt.Errorf("service %s backend[%d]: %s forwarding never changes the destination port; remove port, port_offset and port_range", svc.Name, j, strings.ToLower(forward))
+			}
+			if be.PortOffset == 0 && be.PortRange == (PortRange{}) {
+				continue
+			}
+			forward := be.Forward
+			if forward == "" {
+				forward = cfg.Mode
+			}
+			// Direct routing and tunnelling keep the destination port
+			if !strings.EqualFold(strings.TrimSpace(forward), "nat") {
+				return fmt.Errorf("service %s backend[%d]: port_offset and port_range need nat forwarding", svc.Name, j)
+			}
+		}
+	}
 	return nil
 }
 
@@ -33,15 +83,24 @@ func validateGlobal(cfg *Config) error {
 		defaultReconcileIntervalMS = 1000
 		minReconcileIntervalMS     = 100
 		maxReconcileIntervalMS     = 60_000
+		minVIPCheckIntervalMS      = 50
 
 		defaultStateCacheTTLMS = 500
 		minStateCacheTTLMS     = 1
 		maxStateCacheTTLMS     = 60_000
+
+		defaultBackoffInitialMS = 5000
+		defaultBackoffMaxMS     = 10_000
+		minBackoffMS            = 100
+		maxBackoffMS            = 3_600_000
+		defaultBreakerThreshold = 5
+
+		maxShutdownDrainMS = 600_000
 	)
 
 	// Mode
 	mode := strings.ToLower(strings.TrimSpace(cfg.Mode))
-	if mode != "" && mode != "dr" && mode != "nat" {
+	if mode != "" && !slices.Contains(ForwardMethods, mode) {
 		return fmt.Errorf("invalid mode: %s", cfg.Mode)
 	}
 
@@ -60,8 +119,16 @@ func validateGlobal(cfg *Config) error {
 	if cfg.Network.Frontend.VIP == "" {
 		return fmt.Errorf("frontend VIP is required")
 	}
-	if net.ParseIP(cfg.Network.Frontend.VIP) == nil {
-		return fmt.Errorf("invalid frontend VIP: %s", cfg.Network.Frontend.VIP)
+	seenVIPs := make(map[string]bool)
+	for _, vip := range cfg.Network.Frontend.AllVIPs() {
+		ip := net.ParseIP(vip)
+		if ip == nil {
+			return fmt.Errorf("invalid frontend VIP: %s", vip)
+		}
+		if seenVIPs[ip.String()] {
+			return fmt.Errorf("duplicate frontend VIP: %s", vip)
+		}
+		seenVIPs[ip.String()] = true
 	}
 	if cfg.Network.Frontend.CIDR < 1 || cfg.Network.Frontend.CIDR > 32 {
 		return fmt.Errorf("invalid frontend CIDR: %d", cfg.Network.Frontend.CIDR)
@@ -83,6 +150,17 @@ func validateGlobal(cfg *Config) error {
 	if cfg.VRRP.AdvertIntervalMS < 100 {
 		return fmt.Errorf("invalid advert_interval_ms: %d", cfg.VRRP.AdvertIntervalMS)
 	}
+	if cfg.VRRP.PeerAddress != "" && net.ParseIP(cfg.VRRP.PeerAddress) == nil {
+		return fmt.Errorf("invalid vrrp.peer_address: %s", cfg.VRRP.PeerAddress)
+	}
+	if cfg.VRRP.HeartbeatPort < 0 || cfg.VRRP.HeartbeatPort > 65535 {
+		return fmt.Errorf("invalid vrrp.heartbeat_port: %d", cfg.VRRP.HeartbeatPort)
+	}
+	switch cfg.VRRP.StateSource {
+	case "", VRRPStateSourceVIP, VRRPStateSourceFRR:
+	default:
+		return fmt.Errorf("invalid vrrp.state_source: %s (must be vip or frr)", cfg.VRRP.StateSource)
+	}
 
 	// Observability - logging
 	if cfg.Observability.Logging.Console.Level != "" {
@@ -107,9 +185,68 @@ func validateGlobal(cfg *Config) error {
 		if cfg.Observability.Logging.GELF.Facility == "" {
 			return fmt.Errorf("gelf.facility is required when gelf.enabled is true")
 		}
+		switch strings.ToLower(cfg.Observability.Logging.GELF.Compression) {
+		case "", "gzip", "zlib", "none":
+		default:
+			return fmt.Errorf("invalid gelf.compression: %s", cfg.Observability.Logging.GELF.Compression)
+		}
+		if cfg.Observability.Logging.GELF.CompressionLevel < 0 || cfg.Observability.Logging.GELF.CompressionLevel > 9 {
+			return fmt.Errorf("invalid gelf.compression_level: %d", cfg.Observability.Logging.GELF.CompressionLevel)
+		}
+		if cfg.Observability.Logging.GELF.BatchSize < 0 {
+			return fmt.Errorf("invalid gelf.batch_size: %d", cfg.Observability.Logging.GELF.BatchSize)
+		}
+		if cfg.Observability.Logging.GELF.FlushIntervalMS < 0 {
+			return fmt.Errorf("invalid gelf.flush_interval_ms: %d", cfg.Observability.Logging.GELF.FlushIntervalMS)
+		}
+	}
+
+	seenDedup := make(map[string]bool)
+	for _, d := range cfg.Observability.Logging.AuditDedup {
+		if d.Event == "" {
+			return fmt.Errorf("audit_dedup.event is required")
+		}
+		if seenDedup[d.Event] {
+			return fmt.Errorf("duplicate audit_dedup event: %s", d.Event)
+		}
+		seenDedup[d.Event] = true
+		if d.WindowSeconds < 1 {
+			return fmt.Errorf("invalid audit_dedup.window_seconds for %s: %d", d.Event, d.WindowSeconds)
+		}
+		if d.Burst < 0 {
+			return fmt.Errorf("invalid audit_dedup.burst for %s: %d", d.Event, d.Burst)
+		}
+	}
+
+	conns := cfg.Observability.Logging.Connections
+	if conns.SampleRate < 0 || conns.SampleRate > 1 {
+		return fmt.Errorf("invalid logging.connections.sample_rate: %v", conns.SampleRate)
+	}
+	if p := conns.ClientPrefixV4; p != nil && (*p < 0 || *p > 32) {
+		return fmt.Errorf("invalid logging.connections.client_prefix_v4: %d", *p)
+	}
+	if p := conns.ClientPrefixV6; p != nil && (*p < 0 || *p > 128) {
+		return fmt.Errorf("invalid logging.connections.client_prefix_v6: %d", *p)
 	}
 
 	// Observability - metrics
+	if cfg.Observability.Metrics.MaxSeriesPerMetric < 0 {
+		return fmt.Errorf("invalid metrics.max_series_per_metric: %d", cfg.Observability.Metrics.MaxSeriesPerMetric)
+	}
+	if cfg.Observability.Metrics.IPVSStatsIntervalSeconds < 0 {
+		return fmt.Errorf("invalid metrics.ipvs_stats_interval_seconds: %d", cfg.Observability.Metrics.IPVSStatsIntervalSeconds)
+	}
+	if cluster := cfg.Observability.Metrics.Cluster; cluster != "" && !isValidName(cluster) {
+		return fmt.Errorf("invalid metrics.cluster: %s", cluster)
+	}
+	if peer := cfg.Observability.Metrics.Peer; peer != "" {
+		if cfg.Observability.Metrics.Cluster == "" {
+			return fmt.Errorf("metrics.peer requires metrics.cluster")
+		}
+		if !isValidName(peer) {
+			return fmt.Errorf("invalid metrics.peer: %s", peer)
+		}
+	}
 	if cfg.Observability.Metrics.InfluxDB.Enabled {
 		if cfg.Observability.Metrics.InfluxDB.URL == "" ||
 			cfg.Observability.Metrics.InfluxDB.Token == "" ||
@@ -121,6 +258,17 @@ func validateGlobal(cfg *Config) error {
 			return fmt.Errorf("invalid influxdb.push_interval_seconds: %d", cfg.Observability.Metrics.InfluxDB.PushIntervalSeconds)
 		}
 	}
+	if ipfix := cfg.Observability.Metrics.IPFIX; ipfix.Enabled {
+		if _, port, err := net.SplitHostPort(ipfix.Collector); err != nil || port == "" {
+			return fmt.Errorf("invalid ipfix.collector: %s", ipfix.Collector)
+		}
+		if ipfix.SampleRate < 0 || ipfix.SampleRate > 1 {
+			return fmt.Errorf("invalid ipfix.sample_rate: %v", ipfix.SampleRate)
+		}
+		if ipfix.CacheSize < 0 {
+			return fmt.Errorf("invalid ipfix.cache_size: %d", ipfix.CacheSize)
+		}
+	}
 	if cfg.Observability.Metrics.Prometheus.Enabled {
 		if cfg.Observability.Metrics.Prometheus.Port < 1 || cfg.Observability.Metrics.Prometheus.Port > 65535 {
 			return fmt.Errorf("invalid prometheus.port: %d", cfg.Observability.Metrics.Prometheus.Port)
@@ -156,6 +304,12 @@ func validateGlobal(cfg *Config) error {
 	if cfg.Daemon.ReconcileIntervalMS < minReconcileIntervalMS || cfg.Daemon.ReconcileIntervalMS > maxReconcileIntervalMS {
 		return fmt.Errorf("invalid daemon.reconcile_interval_ms: %d", cfg.Daemon.ReconcileIntervalMS)
 	}
+	if cfg.Daemon.VIPCheckIntervalMS == 0 {
+		cfg.Daemon.VIPCheckIntervalMS = cfg.Daemon.ReconcileIntervalMS
+	}
+	if cfg.Daemon.VIPCheckIntervalMS < minVIPCheckIntervalMS || cfg.Daemon.VIPCheckIntervalMS > maxReconcileIntervalMS {
+		return fmt.Errorf("invalid daemon.vip_check_interval_ms: %d", cfg.Daemon.VIPCheckIntervalMS)
+	}
 	if cfg.Daemon.StateCache.TTLMS < 0 {
 		return fmt.Errorf("invalid daemon.state_cache.ttl_ms: %d", cfg.Daemon.StateCache.TTLMS)
 	}
@@ -167,7 +321,168 @@ func validateGlobal(cfg *Config) error {
 			return fmt.Errorf("invalid daemon.state_cache.ttl_ms: %d", cfg.Daemon.StateCache.TTLMS)
 		}
 	}
+	if cfg.Daemon.Health.MaxInFlight < 0 {
+		return fmt.Errorf("invalid daemon.health.max_in_flight: %d", cfg.Daemon.Health.MaxInFlight)
+	}
+	if h := cfg.Daemon.Health; h.SourcePortMin != 0 || h.SourcePortMax != 0 {
+		if h.SourcePortMin < 1024 || h.SourcePortMax > 65535 || h.SourcePortMin > h.SourcePortMax {
+			return fmt.Errorf("invalid daemon.health source port range: %d-%d", h.SourcePortMin, h.SourcePortMax)
+		}
+	} else if h.ReuseAddr {
+		return fmt.Errorf("daemon.health.reuse_addr requires source_port_min and source_port_max")
+	}
+	if !validStandbyMode[strings.ToLower(cfg.Daemon.Health.StandbyMode)] {
+		return fmt.Errorf("invalid daemon.health.standby_mode: %s", cfg.Daemon.Health.StandbyMode)
+	}
+	if err := validateResolver(cfg.Daemon.Resolver); err != nil {
+		return err
+	}
+	if cfg.Daemon.FailoverBudgetMS < 0 {
+		return fmt.Errorf("invalid daemon.failover_budget_ms: %d", cfg.Daemon.FailoverBudgetMS)
+	}
+	b := &cfg.Daemon.ReconcileBackoff
+	if b.InitialMS == 0 {
+		b.InitialMS = defaultBackoffInitialMS
+	}
+	if b.MaxMS == 0 {
+		b.MaxMS = max(defaultBackoffMaxMS, b.InitialMS)
+	}
+	if b.BreakerThreshold == 0 {
+		b.BreakerThreshold = defaultBreakerThreshold
+	}
+	if b.InitialMS < minBackoffMS || b.InitialMS > maxBackoffMS {
+		return fmt.Errorf("invalid daemon.reconcile_backoff.initial_ms: %d", b.InitialMS)
+	}
+	if b.MaxMS < b.InitialMS || b.MaxMS > maxBackoffMS {
+		return fmt.Errorf("invalid daemon.reconcile_backoff.max_ms: %d (must be %d-%d)", b.MaxMS, b.InitialMS, maxBackoffMS)
+	}
+	if b.BreakerThreshold < 1 {
+		return fmt.Errorf("invalid daemon.reconcile_backoff.breaker_threshold: %d", b.BreakerThreshold)
+	}
+	if cfg.Daemon.AutoReload.DebounceMS < 0 {
+		return fmt.Errorf("invalid daemon.auto_reload.debounce_ms: %d", cfg.Daemon.AutoReload.DebounceMS)
+	}
+	if cfg.Daemon.Drain.TimeoutMS < 0 {
+		return fmt.Errorf("invalid daemon.drain.timeout_ms: %d", cfg.Daemon.Drain.TimeoutMS)
+	}
+	if cfg.Daemon.Drain.Threshold < 0 {
+		return fmt.Errorf("invalid daemon.drain.threshold: %d", cfg.Daemon.Drain.Threshold)
+	}
+	if cs := cfg.Daemon.ConnSync; cs.Enabled {
+		if !isValidName(cs.Interface) {
+			return fmt.Errorf("invalid daemon.conn_sync.interface: %s", cs.Interface)
+		}
+		if cs.SyncID < 0 || cs.SyncID > 255 {
+			return fmt.Errorf("invalid daemon.conn_sync.sync_id: %d", cs.SyncID)
+		}
+	}
+	if err := validateIPVSTimeouts(cfg.Daemon.IPVSTimeouts); err != nil {
+		return err
+	}
+	if !validCleanups[strings.ToLower(cfg.Daemon.Cleanup)] {
+		return fmt.Errorf("invalid daemon.cleanup: %s", cfg.Daemon.Cleanup)
+	}
+	if !validStartups[strings.ToLower(cfg.Daemon.StartupPolicy)] {
+		return fmt.Errorf("invalid daemon.startup_policy: %s", cfg.Daemon.StartupPolicy)
+	}
+	if !validShutdowns[strings.ToLower(cfg.Daemon.ShutdownPolicy)] {
+		return fmt.Errorf("invalid daemon.shutdown_policy: %s", cfg.Daemon.ShutdownPolicy)
+	}
+	if cfg.Daemon.ShutdownDrainTimeoutMS < 0 || cfg.Daemon.ShutdownDrainTimeoutMS > maxShutdownDrainMS {
+		return fmt.Errorf("invalid daemon.shutdown_drain_timeout_ms: %d", cfg.Daemon.ShutdownDrainTimeoutMS)
+	}
+	if c := cfg.Daemon.ReconcileConcurrency; c < 0 || c > 64 {
+		return fmt.Errorf("invalid daemon.reconcile_concurrency: %d", c)
+	}
+	if api := cfg.Daemon.API.HTTP; api.Enabled {
+		if api.Port < 1 || api.Port > 65535 {
+			return fmt.Errorf("invalid daemon.api.http.port: %d", api.Port)
+		}
+		if api.Bind != "" && net.ParseIP(api.Bind) == nil {
+			return fmt.Errorf("invalid daemon.api.http.bind: %s", api.Bind)
+		}
+		if err := validateAPITokens(api); err != nil {
+			return err
+		}
+	}
+	if err := validateLintRules(cfg.Lint.Ignore); err != nil {
+		return fmt.Errorf("lint.ignore: %w", err)
+	}
+	if cfg.Lint.MaxPortRange < 0 {
+		return fmt.Errorf("invalid lint.max_port_range: %d", cfg.Lint.MaxPortRange)
+	}
+
+	return nil
+}
+
+// minAPITokenLength keeps the HTTP admin API token out of guessing range
+const minAPITokenLength = 16
+
+// validateAPITokens checks the admin API has at least one token and that
+// every request maps to one name.
+func validateAPITokens(api HTTPAPIConfig) error {
+	if api.Token == "" && len(api.Tokens) == 0 {
+		return fmt.Errorf("daemon.api.http needs a token or tokens")
+	}
+	if api.Token != "" && len(api.Token) < minAPITokenLength {
+		return fmt.Errorf("daemon.api.http.token must be at least %d characters", minAPITokenLength)
+	}
+	names := make(map[string]bool)
+	secrets := map[string]bool{api.Token: api.Token != ""}
+	for i, t := range api.Tokens {
+		if !isValidName(t.Name) {
+			return fmt.Errorf("invalid daemon.api.http.tokens[%d].name: %q", i, t.Name)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("duplicate daemon.api.http.tokens name: %s", t.Name)
+		}
+		names[t.Name] = true
+		if len(t.Token) < minAPITokenLength {
+			return fmt.Errorf("daemon.api.http.tokens[%s].token must be at least %d characters", t.Name, minAPITokenLength)
+		}
+		if secrets[t.Token] {
+			return fmt.Errorf("daemon.api.http.tokens[%s] reuses another token", t.Name)
+		}
+		secrets[t.Token] = true
+	}
+	return nil
+}
+
+// maxIPVSTimeout is the longest timeout the kernel accepts on every HZ
+// setting (INT_MAX / 1000 seconds)
+const maxIPVSTimeout = 2147483
+
+func validateIPVSTimeouts(t IPVSTimeoutsConfig) error {
+	for _, f := range []struct {
+		name string
+		v    int
+	}{{"tcp", t.TCP}, {"tcp_fin", t.TCPFin}, {"udp", t.UDP}} {
+		if f.v < 0 || f.v > maxIPVSTimeout {
+			return fmt.Errorf("invalid daemon.ipvs_timeouts.%s: %d", f.name, f.v)
+		}
+	}
+	return nil
+}
 
+func validateResolver(r ResolverConfig) error {
+	for _, s := range r.Servers {
+		host := s
+		if h, _, err := net.SplitHostPort(s); err == nil {
+			host = h
+		}
+		if net.ParseIP(host) == nil {
+			return fmt.Errorf("invalid daemon.resolver server: %s", s)
+		}
+	}
+	if r.TimeoutMS < 0 {
+		return fmt.Errorf("invalid daemon.resolver.timeout_ms: %d", r.TimeoutMS)
+	}
+	if r.CacheTTLMS < 0 {
+		return fmt.Errorf("invalid daemon.resolver.cache_ttl_ms: %d", r.CacheTTLMS)
+	}
+	if r.NegativeTTLMS < 0 {
+		return fmt.Errorf("invalid daemon.resolver.negative_ttl_ms: %d", r.NegativeTTLMS)
+	}
 	return nil
 }
 
@@ -187,25 +502,68 @@ func validateServices(cfg *Config) error {
 		}
 		serviceNames[svc.Name] = true
 
+		// VIP
+		if svc.VIP != "" && !slices.ContainsFunc(cfg.Network.Frontend.AllVIPs(), func(vip string) bool {
+			return net.ParseIP(vip).Equal(net.ParseIP(svc.VIP))
+		}) {
+			return fmt.Errorf("service %s: vip %s is not a frontend VIP", svc.Name, svc.VIP)
+		}
+
 		// Protocol
 		proto := strings.ToLower(svc.Protocol)
-		if proto != "tcp" && proto != "udp" {
+		if proto != "tcp" && proto != "udp" && proto != ProtocolTCPUDP {
 			return fmt.Errorf("service %s: invalid protocol: %s", svc.Name, svc.Protocol)
 		}
 
 		// Scheduler
 		sched := strings.ToLower(svc.Scheduler)
-		validSchedulers := map[string]bool{"rr": true, "wrr": true, "sh": true}
-		if !validSchedulers[sched] {
+		if !slices.Contains(IPVSSchedulers, sched) {
 			return fmt.Errorf("service %s: invalid scheduler: %s", svc.Name, svc.Scheduler)
 		}
+		seenFlags := make(map[string]bool, len(svc.SchedulerFlags))
+		for _, f := range svc.SchedulerFlags {
+			flag := strings.ToLower(f)
+			owner, ok := IPVSSchedulerFlags[flag]
+			if !ok {
+				return fmt.Errorf("service %s: invalid scheduler flag: %s", svc.Name, f)
+			}
+			if owner != sched {
+				return fmt.Errorf("service %s: scheduler flag %s requires scheduler %s", svc.Name, f, owner)
+			}
+			if seenFlags[flag] {
+				return fmt.Errorf("service %s: duplicate scheduler flag: %s", svc.Name, f)
+			}
+			seenFlags[flag] = true
+		}
+
+		// Persistence
+		if svc.PersistenceTimeout < 0 {
+			return fmt.Errorf("service %s: persistence_timeout must be >= 0", svc.Name)
+		}
+		if svc.PersistenceNetmask != "" {
+			if svc.PersistenceTimeout == 0 {
+				return fmt.Errorf("service %s: persistence_netmask requires persistence_timeout", svc.Name)
+			}
+			if _, err := ParsePersistenceNetmask(svc.PersistenceNetmask); err != nil {
+				return fmt.Errorf("service %s: %w", svc.Name, err)
+			}
+		}
 
 		// Ports and Ranges
 		if len(svc.Ports) == 0 && len(svc.PortRanges) == 0 {
 			return fmt.Errorf("service %s: no ports defined", svc.Name)
 		}
+		if slices.Contains(svc.Ports, 0) {
+			if !svc.CatchAll() {
+				return fmt.Errorf("service %s: port 0 (all ports) must be the service's only port", svc.Name)
+			}
+			// The kernel skips non-persistent port-0 services when scheduling
+			if svc.PersistenceTimeout == 0 {
+				return fmt.Errorf("service %s: port 0 (all ports) needs persistence_timeout", svc.Name)
+			}
+		}
 		for _, p := range svc.Ports {
-			if p < 1 || p > 65535 {
+			if (p < 1 && !svc.CatchAll()) || p > 65535 {
 				return fmt.Errorf("service %s: invalid port: %d", svc.Name, p)
 			}
 		}
@@ -218,9 +576,24 @@ func validateServices(cfg *Config) error {
 			}
 		}
 
+		if err := validateLabels(svc.Labels); err != nil {
+			return fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+		if !validLogLevels[strings.ToLower(svc.Observability.HealthLogLevel)] {
+			return fmt.Errorf("service %s: invalid observability.health_log_level: %s", svc.Name, svc.Observability.HealthLogLevel)
+		}
+
+		if err := validateLintRules(svc.LintIgnore); err != nil {
+			return fmt.Errorf("service %s: lint_ignore: %w", svc.Name, err)
+		}
+
+		if q := svc.Quota; q.BytesPerSecond < 0 || q.PacketsPerSecond < 0 || q.ConnectionsPerSecond < 0 {
+			return fmt.Errorf("service %s: quota limits must not be negative", svc.Name)
+		}
+
 		// Backends
 		for j, be := range svc.Backends {
-			if net.ParseIP(be.Address) == nil {
+			if !IsHost(be.Address) {
 				return fmt.Errorf("service %s backend[%d]: invalid address: %s", svc.Name, j, be.Address)
 			}
 			if be.Weight < 1 {
@@ -230,12 +603,41 @@ func validateServices(cfg *Config) error {
 			if be.Port != 0 && (be.Port < 1 || be.Port > 65535) {
 				return fmt.Errorf("service %s backend[%d]: invalid port: %d", svc.Name, j, be.Port)
 			}
+			if err := validateBackendPortMapping(svc, be); err != nil {
+				return fmt.Errorf("service %s backend[%d]: %w", svc.Name, j, err)
+			}
+			if be.CheckAddress != "" && !IsHost(be.CheckAddress) {
+				return fmt.Errorf("service %s backend[%d]: invalid check_address: %s", svc.Name, j, be.CheckAddress)
+			}
+			if be.CheckPort != 0 && (be.CheckPort < 1 || be.CheckPort > 65535) {
+				return fmt.Errorf("service %s backend[%d]: invalid check_port: %d", svc.Name, j, be.CheckPort)
+			}
+			if be.Forward != "" && !slices.Contains(ForwardMethods, strings.ToLower(be.Forward)) {
+				return fmt.Errorf("service %s backend[%d]: invalid forward: %s", svc.Name, j, be.Forward)
+			}
+			if be.UpperThreshold < 0 || be.LowerThreshold < 0 {
+				return fmt.Errorf("service %s backend[%d]: connection thresholds must not be negative", svc.Name, j)
+			}
+			if be.LowerThreshold > 0 && be.LowerThreshold >= be.UpperThreshold {
+				return fmt.Errorf("service %s backend[%d]: lower_threshold %d must be below upper_threshold %d", svc.Name, j, be.LowerThreshold, be.UpperThreshold)
+			}
 		}
 
 		// Health Check
 		if svc.Health.Enabled {
-			if strings.ToLower(svc.Health.Type) != "tcp" {
-				return fmt.Errorf("service %s: invalid health check type: %s", svc.Name, svc.Health.Type)
+			if err := validateProbe(svc.Health.Primary()); err != nil {
+				return fmt.Errorf("service %s: %w", svc.Name, err)
+			}
+			for k, probe := range svc.Health.Probes {
+				if err := validateProbe(probe); err != nil {
+					return fmt.Errorf("service %s probe[%d]: %w", svc.Name, k, err)
+				}
+				if probe.Port < 0 || probe.Port > 65535 {
+					return fmt.Errorf("service %s probe[%d]: invalid port: %d", svc.Name, k, probe.Port)
+				}
+			}
+			if !validCombines[strings.ToLower(svc.Health.Combine)] {
+				return fmt.Errorf("service %s: invalid health combine: %s", svc.Name, svc.Health.Combine)
 			}
 			if svc.Health.Port < 1 || svc.Health.Port > 65535 {
 				return fmt.Errorf("service %s: invalid health check port: %d", svc.Name, svc.Health.Port)
@@ -252,12 +654,138 @@ func validateServices(cfg *Config) error {
 			if svc.Health.RecoverAfter < 1 {
 				return fmt.Errorf("service %s: invalid health recover_after: %d", svc.Name, svc.Health.RecoverAfter)
 			}
+			if svc.Health.JitterPct < 0 || svc.Health.JitterPct > 100 {
+				return fmt.Errorf("service %s: invalid health jitter_percent: %d", svc.Name, svc.Health.JitterPct)
+			}
+			if svc.Health.MinHealthy < 0 || svc.Health.MinHealthy > len(svc.Backends) {
+				return fmt.Errorf("service %s: invalid health min_healthy: %d (%d backends)", svc.Name, svc.Health.MinHealthy, len(svc.Backends))
+			}
+			if !validFallbacks[strings.ToLower(svc.Health.Fallback)] {
+				return fmt.Errorf("service %s: invalid health fallback: %s", svc.Name, svc.Health.Fallback)
+			}
+			if svc.Health.SlowStartMS < 0 || svc.Health.SlowStartSteps < 0 {
+				return fmt.Errorf("service %s: invalid health slow start: %dms / %d steps", svc.Name, svc.Health.SlowStartMS, svc.Health.SlowStartSteps)
+			}
+			if svc.Health.SlowStartMS > 0 {
+				steps := svc.Health.SlowStartSteps
+				if steps == 0 {
+					steps = 4
+				}
+				// Weight only changes on a check, so each step must span an interval
+				if svc.Health.SlowStartMS/steps < svc.Health.IntervalMS {
+					return fmt.Errorf("service %s: slow_start_ms %d too short for %d steps at interval %d", svc.Name, svc.Health.SlowStartMS, steps, svc.Health.IntervalMS)
+				}
+			}
 		}
 	}
 
 	return nil
 }
 
+// ParsePersistenceNetmask parses a dotted-quad IPv4 netmask such as
+// 255.255.255.0. An empty mask means 255.255.255.255.
+func ParsePersistenceNetmask(mask string) (net.IPMask, error) {
+	if mask == "" {
+		return net.CIDRMask(32, 32), nil
+	}
+	ip := net.ParseIP(mask).To4()
+	if ip == nil {
+		return nil, fmt.Errorf("invalid persistence_netmask: %s", mask)
+	}
+	m := net.IPMask(ip)
+	if ones, bits := m.Size(); ones == 0 && bits == 0 {
+		return nil, fmt.Errorf("invalid persistence_netmask: %s (not contiguous)", mask)
+	}
+	return m, nil
+}
+
+// validateBackendPortMapping checks that be maps every port of svc to a valid
+// port of its own.
+func validateBackendPortMapping(svc Service, be Backend) error {
+	// A catch-all service maps no ports; validateForwarding rejects any
+	if svc.CatchAll() {
+		return nil
+	}
+
+	set := 0
+	for _, on := range []bool{be.Port != 0, be.PortOffset != 0, be.PortRange != (PortRange{})} {
+		if on {
+			set++
+		}
+	}
+	if set > 1 {
+		return fmt.Errorf("set only one of port, port_offset and port_range")
+	}
+
+	if pr := be.PortRange; pr != (PortRange{}) {
+		if len(svc.PortRanges) != 1 || len(svc.Ports) != 0 {
+			return fmt.Errorf("port_range needs a service with a single port range and no ports")
+		}
+		if pr.Start < 1 || pr.End > 65535 || pr.Start > pr.End {
+			return fmt.Errorf("invalid port_range: %d-%d", pr.Start, pr.End)
+		}
+		if sr := svc.PortRanges[0]; pr.End-pr.Start != sr.End-sr.Start {
+			return fmt.Errorf("port_range %d-%d spans %d ports but the service range %d-%d spans %d",
+				pr.Start, pr.End, pr.End-pr.Start+1, sr.Start, sr.End, sr.End-sr.Start+1)
+		}
+	}
+
+	if be.PortOffset != 0 {
+		lo, hi := 65535, 1
+		for _, p := range svc.Ports {
+			lo, hi = min(lo, p), max(hi, p)
+		}
+		for _, pr := range svc.PortRanges {
+			lo, hi = min(lo, pr.Start), max(hi, pr.End)
+		}
+		if lo+be.PortOffset < 1 || hi+be.PortOffset > 65535 {
+			return fmt.Errorf("port_offset %d maps service ports %d-%d outside 1-65535", be.PortOffset, lo, hi)
+		}
+	}
+	return nil
+}
+
+// validateProbe checks a health check type and its type-specific fields
+func validateProbe(p HealthProbe) error {
+	healthType := strings.ToLower(p.Type)
+	if !validHealthTypes[healthType] {
+		return fmt.Errorf("invalid health check type: %s", p.Type)
+	}
+	if healthType != "udp" && (p.Payload != "" || p.Expect != "") {
+		return fmt.Errorf("health payload/expect require a udp health check")
+	}
+	if healthType != "mysql" && p.MySQLUser != "" {
+		return fmt.Errorf("health mysql_user requires a mysql health check")
+	}
+	if healthType != "tcp" && (p.TCPMode != "" || p.TCPReset || p.TCPKeepaliveMS != 0) {
+		return fmt.Errorf("health tcp_mode/tcp_reset/tcp_keepalive_ms require a tcp health check")
+	}
+	if healthType == "tcp" {
+		mode := strings.ToLower(p.TCPMode)
+		if !validTCPModes[mode] {
+			return fmt.Errorf("invalid tcp_mode: %s", p.TCPMode)
+		}
+		if mode == "half_open" && p.TCPReset {
+			return fmt.Errorf("tcp_reset has no effect with tcp_mode half_open")
+		}
+		if p.TCPKeepaliveMS < 0 || (p.TCPKeepaliveMS > 0 && mode != "reuse") {
+			return fmt.Errorf("invalid tcp_keepalive_ms: %d (requires tcp_mode reuse)", p.TCPKeepaliveMS)
+		}
+	}
+	if healthType == "dns" {
+		if p.QueryName == "" {
+			return fmt.Errorf("dns health check requires query_name")
+		}
+		if !validDNSTypes[strings.ToLower(p.QueryType)] {
+			return fmt.Errorf("invalid dns query_type: %s", p.QueryType)
+		}
+		if !validDNSRcodes[strings.ToLower(p.ExpectRcode)] {
+			return fmt.Errorf("invalid dns expect_rcode: %s", p.ExpectRcode)
+		}
+	}
+	return nil
+}
+
 func isValidName(s string) bool {
 	if s == "" {
 		return false
@@ -268,6 +796,27 @@ func isValidName(s string) bool {
 	return !containsInjectionChars(s)
 }
 
+// IsHost reports whether s is an IP address or a DNS hostname. Hostnames are
+// resolved by the daemon's resolver (daemon.resolver).
+func IsHost(s string) bool {
+	if net.ParseIP(s) != nil {
+		return true
+	}
+	s = strings.TrimSuffix(s, ".")
+	if len(s) == 0 || len(s) > 253 {
+		return false
+	}
+	labels := strings.Split(s, ".")
+	for _, label := range labels {
+		if !hostLabelRegex.MatchString(label) {
+			return false
+		}
+	}
+	// An all-numeric last label is a mistyped IP, not a name
+	_, err := strconv.Atoi(labels[len(labels)-1])
+	return err != nil
+}
+
 func containsInjectionChars(s string) bool {
 	for _, char := range injectionChars {
 		if strings.Contains(s, char) {
@@ -32,7 +32,7 @@ func WriteServiceConfig(dir string, svc Service) error {
 	filename := fmt.Sprintf("%s.yaml", svc.Name)
 	path := filepath.Join(dir, filename)
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := writeFileAtomic(path, data, 0644); err != nil {
 		return fmt.Errorf("failed to write service config file: %w", err)
 	}
 
@@ -0,0 +1,203 @@
+package daemon
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/observability"
+	"github.com/malindarathnayake/LibraFlux/internal/routine"
+)
+
+// adminAPIServer is the HTTP admin API (daemon.api.http). It serves part of
+// the control API, behind a bearer token, for integrations that can't reach
+// the control socket:
+//
+//	GET  /status           DaemonStatus
+//	GET  /services         []ServiceStatus
+//	GET  /backends         []BackendHealth
+//	GET  /health           AdminHealth; 503 until ready or while degraded
+//	POST /reload           ReloadResult
+//	GET  /metrics/catalog  []observability.MetricDescription
+//
+// Audit events of POST /reload name the caller by the name of its token in
+// daemon.api.http.tokens, or by a token ID, the first hex digits of the
+// SHA-256 of daemon.api.http.token. POSTs are rate limited per token as on
+// the control socket.
+type adminAPIServer struct {
+	cfg    config.HTTPAPIConfig
+	ln     net.Listener
+	server *http.Server
+}
+
+// AdminHealth is the answer to GET /health on the HTTP admin API.
+type AdminHealth struct {
+	Status string      `json:"status"` // "ok" or "degraded"
+	State  EngineState `json:"state"`
+	Ready  bool        `json:"ready"`
+}
+
+// adminAddr is the listen address of cfg. Unlike the Prometheus listener,
+// the admin API defaults to loopback.
+func adminAddr(cfg config.HTTPAPIConfig) string {
+	bind := cfg.Bind
+	if bind == "" {
+		bind = "127.0.0.1"
+	}
+	return net.JoinHostPort(bind, strconv.Itoa(cfg.Port))
+}
+
+// adminToken is a token the admin API accepts and the caller it stands for.
+type adminToken struct {
+	secret []byte
+	caller apiCaller
+}
+
+// adminTokens lists the tokens of cfg.
+func adminTokens(cfg config.HTTPAPIConfig) []adminToken {
+	var tokens []adminToken
+	if cfg.Token != "" {
+		tokens = append(tokens, adminToken{secret: []byte(cfg.Token), caller: tokenCaller(cfg.Token)})
+	}
+	for _, t := range cfg.Tokens {
+		tokens = append(tokens, adminToken{secret: []byte(t.Token), caller: namedTokenCaller(t.Name)})
+	}
+	return tokens
+}
+
+// authenticate returns the caller whose token got matches. It compares
+// every token so the time taken doesn't tell which one came close.
+func authenticate(tokens []adminToken, got string) (apiCaller, bool) {
+	var caller apiCaller
+	ok := false
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(got), t.secret) == 1 {
+			caller, ok = t.caller, true
+		}
+	}
+	return caller, ok
+}
+
+// adminHandler serves the admin API for c and metrics to requests carrying
+// one of the tokens of cfg.
+func adminHandler(c Controller, metrics *observability.MetricsRegistry, cfg config.HTTPAPIConfig) http.Handler {
+	limiter := newCallerLimiter(time.Now)
+	tokens := adminTokens(cfg)
+	mux := http.NewServeMux()
+	mux.Handle("/status", statusHandler(c))
+	mux.Handle("/services", servicesHandler(c))
+	mux.Handle("/backends", backendHealthHandler(c))
+	mux.Handle("/health", adminHealthHandler(c))
+	mux.Handle("/reload", limiter.limit(reloadHandler(c, false)))
+	mux.Handle("/metrics/catalog", metricCatalogHandler(metrics))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		var caller apiCaller
+		if ok {
+			caller, ok = authenticate(tokens, got)
+		}
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="lbctl"`)
+			writeControlError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+		mux.ServeHTTP(w, r.WithContext(contextWithCaller(r.Context(), caller)))
+	})
+}
+
+func adminHealthHandler(c Controller) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !controlMethod(w, r, http.MethodGet) {
+			return
+		}
+		st := c.Status()
+		h := AdminHealth{Status: "ok", State: st.State, Ready: st.Ready}
+		code := http.StatusOK
+		if !st.Ready || st.State == StateDegradedBackoff {
+			h.Status = "degraded"
+			code = http.StatusServiceUnavailable
+		}
+		writeControl(w, code, h)
+	}
+}
+
+// metricCatalogHandler lists the metrics registered in the running daemon,
+// which can differ from the docs of another version.
+func metricCatalogHandler(metrics *observability.MetricsRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !controlMethod(w, r, http.MethodGet) {
+			return
+		}
+		writeControl(w, http.StatusOK, metrics.Catalog())
+	}
+}
+
+// syncAdminAPI starts, restarts or stops the HTTP admin API to match the
+// running config. It runs on the Run goroutine, which owns e.admin.
+func (e *Engine) syncAdminAPI() {
+	e.mu.Lock()
+	cfg := e.cfg
+	e.mu.Unlock()
+
+	var want config.HTTPAPIConfig
+	if cfg != nil {
+		want = cfg.Daemon.API.HTTP
+	}
+	if e.admin != nil && reflect.DeepEqual(e.admin.cfg, want) {
+		return
+	}
+	e.closeAdminAPI()
+	if !want.Enabled {
+		return
+	}
+
+	addr := adminAddr(want)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		e.logger.Warn("HTTP admin API unavailable", map[string]interface{}{"addr": addr, "error": err.Error()})
+		return
+	}
+	srv := &http.Server{
+		Handler:      adminHandler(e, e.metrics, want),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second, // A reload can wait out a shell commit
+		IdleTimeout:  60 * time.Second,
+	}
+	e.admin = &adminAPIServer{cfg: want, ln: ln, server: srv}
+	release := e.tracker.Track("admin-api", routine.KindListener)
+	go func() {
+		defer release()
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+			e.logger.Error("HTTP admin API error", map[string]interface{}{"error": err.Error()})
+		}
+	}()
+	e.logger.Info("HTTP admin API listening", map[string]interface{}{"addr": addr})
+}
+
+// closeAdminAPI stops accepting at once, so the port can be bound again, and
+// lets requests in flight finish in the background: a POST /reload that
+// disables the API still gets its answer.
+func (e *Engine) closeAdminAPI() {
+	if e.admin == nil {
+		return
+	}
+	srv := e.admin.server
+	_ = e.admin.ln.Close()
+	e.admin = nil
+
+	release := e.tracker.Track("admin-api-drain", routine.KindGoroutine)
+	go func() {
+		defer release()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+		_ = srv.Close()
+	}()
+}
@@ -0,0 +1,162 @@
+package daemon
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/system"
+)
+
+// apiCaller identifies who made a control or admin API request.
+type apiCaller struct {
+	name string // Recorded as the caller of audit events: "uid=0,pid=4242", "token-name=ci" or "token-id=1a2b3c4d"
+	key  string // What the rate limit counts by: the uid or the token, not one command's pid
+}
+
+type callerKey struct{}
+
+func contextWithCaller(ctx context.Context, c apiCaller) context.Context {
+	return context.WithValue(ctx, callerKey{}, c)
+}
+
+// callerOf returns the caller stored in ctx, or the zero apiCaller for
+// changes that didn't come through an API.
+func callerOf(ctx context.Context) apiCaller {
+	c, _ := ctx.Value(callerKey{}).(apiCaller)
+	return c
+}
+
+// socketCaller identifies the peer of a control socket connection by its
+// SO_PEERCRED uid and pid.
+func socketCaller(conn net.Conn) apiCaller {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return apiCaller{name: "unix", key: "unix"}
+	}
+	cred, err := system.PeerCredOf(uc)
+	if err != nil {
+		return apiCaller{name: "unix", key: "unix"}
+	}
+	return apiCaller{
+		name: fmt.Sprintf("uid=%d,pid=%d", cred.UID, cred.PID),
+		key:  fmt.Sprintf("uid=%d", cred.UID),
+	}
+}
+
+// tokenCaller identifies an admin API client by a token ID: a hash prefix
+// that tells tokens apart in the audit log without revealing them.
+func tokenCaller(token string) apiCaller {
+	sum := sha256.Sum256([]byte(token))
+	id := "token-id=" + hex.EncodeToString(sum[:4])
+	return apiCaller{name: id, key: id}
+}
+
+// namedTokenCaller identifies an admin API client by the name of its token
+// in daemon.api.http.tokens.
+func namedTokenCaller(name string) apiCaller {
+	id := "token-name=" + name
+	return apiCaller{name: id, key: id}
+}
+
+// withCaller adds caller to the fields of an audit event or log line, unless
+// the change has no API caller.
+func withCaller(caller string, fields map[string]interface{}) map[string]interface{} {
+	if caller == "" {
+		return fields
+	}
+	if fields == nil {
+		fields = make(map[string]interface{})
+	}
+	fields["caller"] = caller
+	return fields
+}
+
+// Mutating API requests each caller may make: a burst of apiRateBurst, then
+// one per apiRateInterval.
+const (
+	apiRateBurst    = 10
+	apiRateInterval = 200 * time.Millisecond
+)
+
+// callerLimiter rate limits mutating API requests per caller with a token
+// bucket each.
+type callerLimiter struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	burst   float64
+	every   time.Duration
+	buckets map[string]*rateBucket
+}
+
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newCallerLimiter(now func() time.Time) *callerLimiter {
+	return &callerLimiter{
+		now:     now,
+		burst:   apiRateBurst,
+		every:   apiRateInterval,
+		buckets: make(map[string]*rateBucket),
+	}
+}
+
+// allow takes a token from key's bucket. Without one, it returns how long
+// until the next.
+func (l *callerLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	for k, b := range l.buckets {
+		// A full bucket is the same as none; forget idle callers
+		if k != key && l.refill(b, now) >= l.burst {
+			delete(l.buckets, k)
+		}
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &rateBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	if l.refill(b, now) < 1 {
+		return false, time.Duration((1 - b.tokens) * float64(l.every))
+	}
+	b.tokens--
+	return true, 0
+}
+
+func (l *callerLimiter) refill(b *rateBucket, now time.Time) float64 {
+	if now.After(b.last) {
+		b.tokens = math.Min(l.burst, b.tokens+float64(now.Sub(b.last))/float64(l.every))
+		b.last = now
+	}
+	return b.tokens
+}
+
+// limit answers 429 to callers of next over their rate. Only POSTs count;
+// next rejects other methods without using up a caller's tokens.
+func (l *callerLimiter) limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		c := callerOf(r.Context())
+		ok, wait := l.allow(c.key)
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeControlError(w, http.StatusTooManyRequests, fmt.Errorf("too many requests from %s; retry in %s", c.name, wait.Round(time.Millisecond)))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,63 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+)
+
+// reloadRequest is a reload asked for over the control API. A path switches
+// to another config file first.
+type reloadRequest struct {
+	path   string
+	caller string // Recorded in the reload's audit events
+	done   chan error
+}
+
+// switchConfigPath makes the config file at path the one the daemon runs
+// and reloads it with reload. The file is loaded and validated before
+// anything changes, so an invalid one leaves the current config, its file
+// and its watches in place. It runs on the Run goroutine.
+func (e *Engine) switchConfigPath(path string, reload func() error) error {
+	if e.newSource == nil {
+		return errors.New("the daemon's config source has no file to switch")
+	}
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("config path must be absolute: %s", path)
+	}
+	path = filepath.Clean(path)
+	oldPath, oldSource := e.configPath, e.source
+
+	src := e.newSource(path)
+	cfg, err := src.Load()
+	if err == nil {
+		err = e.validateConfig(cfg)
+	}
+	if err != nil {
+		e.logger.Error("Config switch rejected; keeping current config", map[string]interface{}{
+			"config_path": path,
+			"current":     oldPath,
+			"error":       err.Error(),
+		})
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+
+	e.logger.Info("Reload requested (control API)", withCaller(e.reloadCaller, map[string]interface{}{"config_path": path, "previous": oldPath}))
+	e.setConfigSource(path, src)
+	if err := reload(); err != nil {
+		// Changed on disk since it was checked: go back to the running file
+		e.setConfigSource(oldPath, oldSource)
+		e.resetConfigWatch()
+		return err
+	}
+	return nil
+}
+
+func (e *Engine) setConfigSource(path string, src config.Source) {
+	e.mu.Lock()
+	e.configPath = path
+	e.mu.Unlock()
+	e.source = src
+}
@@ -0,0 +1,231 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+)
+
+// defaultAutoReloadDebounce is how long the config files must stay unchanged
+// before daemon.auto_reload reloads them.
+const defaultAutoReloadDebounce = 2 * time.Second
+
+// configWatch notices edits to the config file, its vars and includes for
+// daemon.auto_reload. An inotify watch on their directories reports edits
+// as they happen; where one can't be set up, Run checks on each reconcile
+// tick instead, like service reload requests and scheduled changes. Either
+// way a fingerprint of the files' names, sizes and modification times
+// decides whether they changed. It is owned by Run.
+type configWatch struct {
+	loaded  string    // Fingerprint when the config was last loaded
+	seen    string    // Fingerprint at the last check
+	changed time.Time // When seen last changed
+
+	events   <-chan struct{} // nil while not watching; ticks poll instead
+	done     chan struct{}
+	dirs     string      // Directories watched, one per line
+	debounce clock.Timer // Pending check once edits settle; nil when none
+	failed   bool        // Warned that the watch is unavailable
+}
+
+// configFingerprint identifies the current state of the config file and the
+// vars and service files cfg reads. It is empty for a config that isn't read
+// from files.
+func (e *Engine) configFingerprint(cfg *config.Config) string {
+	if e.configPath == "" {
+		return ""
+	}
+	paths := []string{e.configPath}
+	if cfg != nil && cfg.Vars != "" {
+		paths = append(paths, config.VarsPath(e.configPath, cfg.Vars))
+	}
+	if pattern := config.IncludePattern(e.configPath, cfg); pattern != "" {
+		matches, _ := filepath.Glob(pattern)
+		paths = append(paths, matches...)
+	}
+	var sb strings.Builder
+	for _, path := range paths {
+		sb.WriteString(path)
+		if fi, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&sb, " %d %d", fi.Size(), fi.ModTime().UnixNano())
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// configDirs lists the directories holding the files configFingerprint
+// reads.
+func (e *Engine) configDirs(cfg *config.Config) []string {
+	if e.configPath == "" {
+		return nil
+	}
+	set := map[string]bool{filepath.Dir(e.configPath): true}
+	if cfg != nil && cfg.Vars != "" {
+		set[filepath.Dir(config.VarsPath(e.configPath, cfg.Vars))] = true
+	}
+	if pattern := config.IncludePattern(e.configPath, cfg); pattern != "" {
+		set[filepath.Dir(pattern)] = true
+	}
+	dirs := make([]string, 0, len(set))
+	for dir := range set {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// resetConfigWatch records the files on disk as loaded, after a reload or a
+// service reload, so they aren't reloaded again.
+func (e *Engine) resetConfigWatch() {
+	e.mu.Lock()
+	cfg := e.cfg
+	e.mu.Unlock()
+
+	fp := e.configFingerprint(cfg)
+	e.watch.loaded, e.watch.seen = fp, fp
+	e.stopConfigDebounce()
+}
+
+// syncConfigWatch watches the config directories while daemon.auto_reload
+// is on, rewatching when a reload changes them. Run calls it at startup,
+// after reloads and on every reconcile tick, so a failed watch is retried.
+func (e *Engine) syncConfigWatch() {
+	e.mu.Lock()
+	cfg := e.cfg
+	e.mu.Unlock()
+	if cfg == nil || !cfg.Daemon.AutoReload.Enabled || e.configWatcher == nil {
+		e.closeConfigWatch()
+		return
+	}
+	dirs := e.configDirs(cfg)
+	key := strings.Join(dirs, "\n")
+	if e.watch.events != nil && e.watch.dirs == key {
+		return
+	}
+	e.closeConfigWatch()
+	if len(dirs) == 0 {
+		return
+	}
+	done := make(chan struct{})
+	events, err := e.configWatcher.WatchDirs(dirs, done)
+	if err != nil {
+		close(done)
+		if !e.watch.failed {
+			e.logger.Warn("Config file watch unavailable; detecting changes by polling", map[string]interface{}{"error": err.Error()})
+			e.watch.failed = true
+		}
+		return
+	}
+	if e.watch.failed {
+		e.logger.Info("Config file watch restored", nil)
+		e.watch.failed = false
+	}
+	e.watch.events, e.watch.done, e.watch.dirs = events, done, key
+	// Edits made before the watch started produced no event
+	e.onConfigEvent(true)
+}
+
+// closeConfigWatch ends the watch; ticks poll until it is set up again.
+func (e *Engine) closeConfigWatch() {
+	if e.watch.events == nil {
+		return
+	}
+	close(e.watch.done)
+	e.watch.events, e.watch.done, e.watch.dirs = nil, nil, ""
+	e.stopConfigDebounce()
+}
+
+// onConfigEvent notes an edit reported by the watch and checks again once
+// the debounce passes. ok is false when the watch has ended.
+func (e *Engine) onConfigEvent(ok bool) {
+	if !ok {
+		e.closeConfigWatch()
+		e.logger.Warn("Config file watch ended; detecting changes by polling", nil)
+		e.watch.failed = true
+		return
+	}
+	if e.autoReloadDue() {
+		// Settled already, e.g. edited while the watch was down
+		e.armConfigDebounce(0)
+		return
+	}
+	if e.watch.seen != e.watch.loaded {
+		e.armConfigDebounce(e.autoReloadDebounce())
+	}
+}
+
+// onConfigDebounce reports whether the edits the watch saw have settled
+// and should be reloaded, waiting again if they are still going on.
+func (e *Engine) onConfigDebounce() bool {
+	e.watch.debounce = nil
+	if e.autoReloadDue() {
+		return true
+	}
+	if e.watch.seen != e.watch.loaded {
+		e.armConfigDebounce(e.autoReloadDebounce() - e.clock.Now().Sub(e.watch.changed))
+	}
+	return false
+}
+
+func (e *Engine) armConfigDebounce(d time.Duration) {
+	e.stopConfigDebounce()
+	e.watch.debounce = e.clock.NewTimer(max(d, 0))
+}
+
+func (e *Engine) stopConfigDebounce() {
+	if e.watch.debounce != nil {
+		e.watch.debounce.Stop()
+		e.watch.debounce = nil
+	}
+}
+
+// configDebounceC is the pending debounce's channel, or nil, which never
+// fires, without one.
+func (e *Engine) configDebounceC() <-chan time.Time {
+	if e.watch.debounce == nil {
+		return nil
+	}
+	return e.watch.debounce.C()
+}
+
+// autoReloadDebounce is how long edits must settle before a reload
+func (e *Engine) autoReloadDebounce() time.Duration {
+	e.mu.Lock()
+	cfg := e.cfg
+	e.mu.Unlock()
+	if cfg != nil && cfg.Daemon.AutoReload.DebounceMS > 0 {
+		return time.Duration(cfg.Daemon.AutoReload.DebounceMS) * time.Millisecond
+	}
+	return defaultAutoReloadDebounce
+}
+
+// autoReloadDue reports whether daemon.auto_reload should reload: the files
+// changed since they were loaded and have since stayed unchanged for the
+// debounce, so a reload doesn't pick up an edit half-way through.
+func (e *Engine) autoReloadDue() bool {
+	e.mu.Lock()
+	cfg := e.cfg
+	e.mu.Unlock()
+	if cfg == nil || !cfg.Daemon.AutoReload.Enabled {
+		return false
+	}
+
+	fp := e.configFingerprint(cfg)
+	now := e.clock.Now()
+	switch {
+	case fp == e.watch.loaded:
+		e.watch.seen = fp
+		return false
+	case fp != e.watch.seen:
+		e.watch.seen, e.watch.changed = fp, now
+		return false
+	}
+	return now.Sub(e.watch.changed) >= e.autoReloadDebounce()
+}
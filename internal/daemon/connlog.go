@@ -0,0 +1,169 @@
+package daemon
+
+import (
+	"math/rand"
+	"net"
+	"strconv"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/ipvs"
+)
+
+// Connection log defaults
+const (
+	DefaultConnSampleRate     = 0.01
+	DefaultConnClientPrefixV4 = 24
+	DefaultConnClientPrefixV6 = 64
+)
+
+// connSampler finds connections created since the previous snapshot of the
+// IPVS connection table and picks a sample of them. Snapshots are taken every
+// tick, so a connection that opens and expires between two ticks is never
+// seen.
+type connSampler struct {
+	sample func() float64
+	seen   map[string]struct{} // Keys in the previous snapshot; nil until primed
+}
+
+func newConnSampler() *connSampler {
+	return &connSampler{sample: rand.Float64}
+}
+
+// observe returns the sampled connections of conns that were not in the
+// previous snapshot. The first snapshot after a reset only primes the
+// sampler, so connections that predate sampling aren't reported as new.
+func (s *connSampler) observe(conns []ipvs.Connection, rate float64) []ipvs.Connection {
+	primed := s.seen != nil
+	seen := make(map[string]struct{}, len(conns))
+	var sampled []ipvs.Connection
+	for _, c := range conns {
+		key := c.Key()
+		seen[key] = struct{}{}
+		if !primed {
+			continue
+		}
+		if _, ok := s.seen[key]; ok {
+			continue
+		}
+		if s.sample() < rate {
+			sampled = append(sampled, c)
+		}
+	}
+	s.seen = seen
+	return sampled
+}
+
+func (s *connSampler) reset() {
+	s.seen = nil
+}
+
+// pollConnections reads the IPVS connection table once per tick and feeds
+// it to the connection log and the IPFIX exporter, whichever are enabled.
+func (e *Engine) pollConnections(cfg *config.Config) {
+	logCfg := cfg.Observability.Logging.Connections
+	if !logCfg.Enabled {
+		e.connLog.reset()
+	}
+	if e.ipfix == nil {
+		e.flowSampler.reset()
+	}
+	if !logCfg.Enabled && e.ipfix == nil {
+		return
+	}
+
+	conns, err := e.readConns()
+	if err != nil {
+		e.logger.Warn("Failed to read IPVS connection table", map[string]interface{}{"error": err.Error()})
+		return
+	}
+	services := serviceByAddr(cfg)
+	if logCfg.Enabled {
+		rate := logCfg.SampleRate
+		if rate == 0 {
+			rate = DefaultConnSampleRate
+		}
+		e.logConnections(logCfg, services, e.connLog.observe(conns, rate))
+	}
+	if e.ipfix != nil {
+		rate := cfg.Observability.Metrics.IPFIX.SampleRate
+		if rate == 0 {
+			rate = DefaultFlowSampleRate
+		}
+		e.exportFlows(cfg, services, e.flowSampler.observe(conns, rate))
+	}
+}
+
+// resetConnections forgets the last snapshot, e.g. after losing the VIP
+func (e *Engine) resetConnections() {
+	e.connLog.reset()
+	e.flowSampler.reset()
+}
+
+// logConnections logs each sampled connection to a configured service, tagged
+// with the service name.
+func (e *Engine) logConnections(lc config.ConnLogConfig, services map[string]string, conns []ipvs.Connection) {
+	for _, c := range conns {
+		name, ok := services[serviceAddr(c.VIP, c.Protocol, c.Port)]
+		if !ok {
+			continue
+		}
+		e.logger.Info("Connection sampled", map[string]interface{}{
+			"service_name": name,
+			"protocol":     c.Protocol,
+			"vip":          c.VIP.String(),
+			"port":         c.Port,
+			"client_net":   clientNet(c.Client, lc),
+			"backend":      net.JoinHostPort(c.Dest.String(), strconv.Itoa(int(c.DestPort))),
+			"state":        c.State,
+		})
+	}
+}
+
+// serviceByAddr maps the serviceAddr of every VIP, protocol and port to the
+// service listening there
+func serviceByAddr(cfg *config.Config) map[string]string {
+	m := make(map[string]string)
+	for _, svc := range cfg.Services {
+		vip := svc.VIP
+		if vip == "" {
+			vip = cfg.Network.Frontend.VIP
+		}
+		ip := net.ParseIP(vip)
+		if ip == nil {
+			continue
+		}
+		for _, proto := range svc.Protocols() {
+			for _, p := range svc.Ports {
+				m[serviceAddr(ip, proto, uint16(p))] = svc.Name
+			}
+			for _, pr := range svc.PortRanges {
+				for p := pr.Start; p <= pr.End; p++ {
+					m[serviceAddr(ip, proto, uint16(p))] = svc.Name
+				}
+			}
+		}
+	}
+	return m
+}
+
+// serviceAddr is the "protocol:vip:port" key of an IPVS virtual service
+func serviceAddr(vip net.IP, proto string, port uint16) string {
+	return proto + ":" + net.JoinHostPort(vip.String(), strconv.Itoa(int(port)))
+}
+
+// clientNet truncates a client address to the configured prefix, e.g.
+// 203.0.113.0/24. An explicit prefix of 0 hides the address entirely.
+func clientNet(ip net.IP, lc config.ConnLogConfig) string {
+	bits, prefix := 128, DefaultConnClientPrefixV6
+	if lc.ClientPrefixV6 != nil {
+		prefix = *lc.ClientPrefixV6
+	}
+	if v4 := ip.To4(); v4 != nil {
+		ip, bits, prefix = v4, 32, DefaultConnClientPrefixV4
+		if lc.ClientPrefixV4 != nil {
+			prefix = *lc.ClientPrefixV4
+		}
+	}
+	n := net.IPNet{IP: ip.Mask(net.CIDRMask(prefix, bits)), Mask: net.CIDRMask(prefix, bits)}
+	return n.String()
+}
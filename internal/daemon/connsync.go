@@ -0,0 +1,44 @@
+package daemon
+
+import (
+	"slices"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/ipvs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// syncDaemonSetter is implemented by reconcilers that can run the kernel's
+// IPVS connection sync daemons.
+type syncDaemonSetter interface {
+	SetSyncDaemons(want []ipvs.SyncDaemon) error
+}
+
+// syncConnSync runs the IPVS sync daemon for daemon.conn_sync as master while
+// this node owns the VIP and as backup otherwise, so the standby holds a copy
+// of the connection table when it takes over. It runs on the Run goroutine;
+// a failed change is retried on the next tick. Disabling conn_sync stops the
+// running daemons; a node that never enabled it leaves them alone.
+func (e *Engine) syncConnSync(cfg *config.Config, active bool) {
+	ss, ok := e.reconciler.(syncDaemonSetter)
+	if !ok {
+		return
+	}
+	want := ipvs.SyncDaemonsForConfig(cfg.Daemon.ConnSync, active)
+	if slices.Equal(want, e.connSync) {
+		return
+	}
+	if err := ss.SetSyncDaemons(want); err != nil {
+		e.logger.Warn("Failed to update IPVS connection sync", map[string]interface{}{"error": err.Error()})
+		return
+	}
+	e.connSync = want
+
+	for _, state := range []ipvs.SyncState{ipvs.SyncMaster, ipvs.SyncBackup} {
+		v := 0.0
+		if slices.ContainsFunc(want, func(d ipvs.SyncDaemon) bool { return d.State == state }) {
+			v = 1
+		}
+		e.metrics.Gauge("lbctl_ipvs_sync_daemon_state", prometheus.Labels{"node": cfg.Node.Name, "state": state.String()}).Set(v)
+	}
+}
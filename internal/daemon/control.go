@@ -0,0 +1,629 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+	"github.com/malindarathnayake/LibraFlux/internal/health"
+	"github.com/malindarathnayake/LibraFlux/internal/ipvs"
+	"github.com/malindarathnayake/LibraFlux/internal/observability"
+	"github.com/malindarathnayake/LibraFlux/internal/routine"
+	"github.com/malindarathnayake/LibraFlux/internal/system"
+)
+
+// ControlSocketFile is the control API's unix socket, in system.state_dir.
+// The API speaks JSON over HTTP. It has no authentication of its own: the
+// socket and the state dir around it are root's, so only root can use it.
+// Audit events of POST requests name the caller by the uid and pid of the
+// connecting process, and each uid may make a burst of apiRateBurst POSTs,
+// then one per apiRateInterval; more are answered 429.
+//
+//	GET  /v1/status     DaemonStatus
+//	GET  /v1/services   []ServiceStatus
+//	GET  /v1/health     []BackendHealth
+//	GET  /v1/ipvs       ipvs.Snapshot of the kernel's IPVS table
+//	POST /v1/reconcile  Queue a full reconcile
+//	POST /v1/reload     ReloadRequest: reload config, like SIGHUP, or switch to
+//	                    another config file, and wait for the result
+//	POST /v1/override   OverrideRequest: drain a backend or force its health
+//	POST /v1/log-level  LogLevelRequest
+//	POST /v1/maintenance MaintenanceRequest: take the node out of rotation or back
+const ControlSocketFile = "control.sock"
+
+// DaemonStatus is the answer to GET /v1/status.
+type DaemonStatus struct {
+	Node       string      `json:"node"`
+	Role       string      `json:"role"` // Configured role: primary or secondary
+	State      EngineState `json:"state"`
+	StateSince time.Time   `json:"state_since"`
+	Active     bool        `json:"active"` // Holds the VIP
+	Ready      bool        `json:"ready"`
+	Generation uint64      `json:"generation"`
+	ConfigHash string      `json:"config_hash"`
+	ConfigPath string      `json:"config_path,omitempty"`
+	Services   int         `json:"services"`
+	LogLevel   string      `json:"log_level"`
+
+	Maintenance      bool      `json:"maintenance"`
+	MaintenanceSince time.Time `json:"maintenance_since,omitempty"`
+
+	// LastReconcile is the outcome of the last IPVS write; nil until one runs
+	LastReconcile *ReconcileResult `json:"last_reconcile,omitempty"`
+
+	StartedAt     time.Time      `json:"started_at,omitempty"` // When Run started
+	UptimeSeconds int64          `json:"uptime_seconds"`
+	Backends      BackendSummary `json:"backends"`
+	Pending       PendingWork    `json:"pending"`
+}
+
+// BackendSummary counts the configured backends by last reported health.
+// Backends of services without health checks count as unknown.
+type BackendSummary struct {
+	Total     int `json:"total"`
+	Healthy   int `json:"healthy"`
+	Unhealthy int `json:"unhealthy"`
+	Unknown   int `json:"unknown"`
+}
+
+// PendingWork is what the engine has queued but not yet done.
+type PendingWork struct {
+	Reconcile string    `json:"reconcile,omitempty"` // Reason of the queued IPVS write: drain, weight, reload or disable
+	RetryAt   time.Time `json:"retry_at,omitempty"`  // Earliest retry after failed reconciles
+	Stalled   bool      `json:"stalled,omitempty"`   // Waiting for a config reload after a config error
+	Draining  int       `json:"draining,omitempty"`  // Destinations at weight 0 waiting for their connections to finish
+}
+
+// ReconcileResult is the outcome of one IPVS write.
+type ReconcileResult struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error,omitempty"` // Empty when the write succeeded
+}
+
+// ServiceStatus summarizes one configured service.
+type ServiceStatus struct {
+	Name      string            `json:"name"`
+	VIP       string            `json:"vip"`
+	Protocol  string            `json:"protocol"`
+	Ports     []int             `json:"ports,omitempty"`
+	Scheduler string            `json:"scheduler"`
+	Backends  int               `json:"backends"`
+	Healthy   int               `json:"healthy"`  // Backends last reported HEALTHY
+	Fallback  bool              `json:"fallback"` // Serving fallback backends below health.min_healthy
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// BackendHealth is one backend's health as last reported by the health
+// scheduler.
+type BackendHealth struct {
+	Service  string          `json:"service"`
+	Backend  string          `json:"backend"`
+	State    health.State    `json:"state"`
+	Weight   int             `json:"weight"` // Effective weight; the configured one until health reports
+	Override health.Override `json:"override,omitempty"`
+}
+
+// OverrideRequest is the body of POST /v1/override. An empty Mode clears the
+// backend's override.
+type OverrideRequest struct {
+	Service    string          `json:"service"`
+	Backend    string          `json:"backend"`
+	Mode       health.Override `json:"mode"`
+	TTLSeconds int             `json:"ttl_seconds,omitempty"`
+}
+
+// LogLevelRequest is the body of POST /v1/log-level.
+type LogLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// ReloadResult is the answer to POST /v1/reload.
+type ReloadResult struct {
+	Generation uint64 `json:"generation"`
+	ConfigPath string `json:"config_path,omitempty"` // The config file the daemon now runs
+}
+
+// ReloadRequest is the optional body of POST /v1/reload.
+type ReloadRequest struct {
+	ConfigPath string `json:"config_path,omitempty"` // Switch to this config file; "" reloads the current one
+}
+
+type controlError struct {
+	Error string       `json:"error"`
+	Code  errdefs.Code `json:"code,omitempty"` // Stable error code, see errdefs.Codes
+}
+
+// Controller is what the control API operates on. *Engine implements it.
+type Controller interface {
+	Status() DaemonStatus
+	Services() []ServiceStatus
+	BackendHealth() []BackendHealth
+	IPVS() (*ipvs.Snapshot, error)
+	Reconcile(ctx context.Context) error
+	Reload(ctx context.Context) error
+	ReloadFrom(ctx context.Context, path string) error
+	SetBackendOverride(ctx context.Context, service, backend string, mode health.Override, ttl time.Duration) error
+	ClearBackendOverride(ctx context.Context, service, backend string) error
+	SetLogLevel(ctx context.Context, level observability.LogLevel)
+	SetMaintenance(ctx context.Context, enabled bool) error
+}
+
+// ControlServer serves the control API for a Controller on a unix socket.
+type ControlServer struct {
+	path   string
+	ln     net.Listener
+	server *http.Server
+}
+
+// ListenControl opens the control socket at path. A socket left behind by a
+// daemon that exited is replaced; one that still answers is not.
+func ListenControl(path string, c Controller) (*ControlServer, error) {
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("control socket %s is in use", path)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale control socket: %w", err)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to set control socket mode: %w", err)
+	}
+	return &ControlServer{
+		path: path,
+		ln:   ln,
+		server: &http.Server{
+			Handler:     controlHandler(c),
+			ReadTimeout: 10 * time.Second,
+			IdleTimeout: 60 * time.Second,
+			ConnContext: func(ctx context.Context, conn net.Conn) context.Context {
+				return contextWithCaller(ctx, socketCaller(conn))
+			},
+		},
+	}, nil
+}
+
+// Path returns the socket path.
+func (s *ControlServer) Path() string { return s.path }
+
+// Serve answers requests until Close.
+func (s *ControlServer) Serve() error {
+	if err := s.server.Serve(s.ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Close stops the server, drops open connections and removes the socket.
+func (s *ControlServer) Close() error {
+	return s.server.Close()
+}
+
+func controlHandler(c Controller) http.Handler {
+	limiter := newCallerLimiter(time.Now)
+	mux := http.NewServeMux()
+	mux.Handle("/v1/status", statusHandler(c))
+	mux.Handle("/v1/services", servicesHandler(c))
+	mux.Handle("/v1/health", backendHealthHandler(c))
+	mux.Handle("/v1/ipvs", ipvsHandler(c))
+	mux.Handle("/v1/reconcile", limiter.limit(reconcileHandler(c)))
+	mux.Handle("/v1/reload", limiter.limit(reloadHandler(c, true)))
+	mux.Handle("/v1/override", limiter.limit(overrideHandler(c)))
+	mux.Handle("/v1/log-level", limiter.limit(logLevelHandler(c)))
+	mux.Handle("/v1/maintenance", limiter.limit(maintenanceHandler(c)))
+	return mux
+}
+
+func statusHandler(c Controller) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !controlMethod(w, r, http.MethodGet) {
+			return
+		}
+		writeControl(w, http.StatusOK, c.Status())
+	}
+}
+
+func servicesHandler(c Controller) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !controlMethod(w, r, http.MethodGet) {
+			return
+		}
+		writeControl(w, http.StatusOK, c.Services())
+	}
+}
+
+func backendHealthHandler(c Controller) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !controlMethod(w, r, http.MethodGet) {
+			return
+		}
+		writeControl(w, http.StatusOK, c.BackendHealth())
+	}
+}
+
+// ipvsHandler answers with the IPVS table. Like ipvs.TakeSnapshot, a
+// partial snapshot is still served.
+func ipvsHandler(c Controller) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !controlMethod(w, r, http.MethodGet) {
+			return
+		}
+		snap, err := c.IPVS()
+		if snap == nil {
+			writeControlError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeControl(w, http.StatusOK, snap)
+	}
+}
+
+func reconcileHandler(c Controller) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !controlMethod(w, r, http.MethodPost) {
+			return
+		}
+		if err := c.Reconcile(r.Context()); err != nil {
+			writeControlError(w, http.StatusConflict, err)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// reloadHandler reloads the config. Only with allowSwitch may the request
+// name another config file.
+func reloadHandler(c Controller, allowSwitch bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !controlMethod(w, r, http.MethodPost) {
+			return
+		}
+		var req ReloadRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeControlError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+			return
+		}
+		if req.ConfigPath != "" && !allowSwitch {
+			writeControlError(w, http.StatusForbidden, errors.New("switching the config file needs the control socket"))
+			return
+		}
+		if err := c.ReloadFrom(r.Context(), req.ConfigPath); err != nil {
+			writeControlError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		st := c.Status()
+		writeControl(w, http.StatusOK, ReloadResult{Generation: st.Generation, ConfigPath: st.ConfigPath})
+	}
+}
+
+func overrideHandler(c Controller) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !controlMethod(w, r, http.MethodPost) {
+			return
+		}
+		var req OverrideRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeControlError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+			return
+		}
+		if req.Service == "" || req.Backend == "" {
+			writeControlError(w, http.StatusBadRequest, errors.New("service and backend are required"))
+			return
+		}
+		var err error
+		if req.Mode == health.OverrideNone {
+			err = c.ClearBackendOverride(r.Context(), req.Service, req.Backend)
+		} else {
+			err = c.SetBackendOverride(r.Context(), req.Service, req.Backend, req.Mode, time.Duration(req.TTLSeconds)*time.Second)
+		}
+		if err != nil {
+			writeControlError(w, http.StatusConflict, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func logLevelHandler(c Controller) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !controlMethod(w, r, http.MethodPost) {
+			return
+		}
+		var req LogLevelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeControlError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+			return
+		}
+		level, err := observability.ParseLogLevel(req.Level)
+		if err != nil {
+			writeControlError(w, http.StatusBadRequest, err)
+			return
+		}
+		c.SetLogLevel(r.Context(), level)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func maintenanceHandler(c Controller) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !controlMethod(w, r, http.MethodPost) {
+			return
+		}
+		var req MaintenanceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeControlError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+			return
+		}
+		if err := c.SetMaintenance(r.Context(), req.Enabled); err != nil {
+			writeControlError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func controlMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeControlError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s requires %s", r.URL.Path, method))
+	return false
+}
+
+func writeControl(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeControlError(w http.ResponseWriter, code int, err error) {
+	ec := errdefs.CodeOf(err)
+	if ec == errdefs.CodeUnknown {
+		ec = statusErrorCode(code)
+	}
+	writeControl(w, code, controlError{Error: err.Error(), Code: ec})
+}
+
+// statusErrorCode is the error code for an API error that carries none of
+// its own, by the HTTP status it is answered with.
+func statusErrorCode(status int) errdefs.Code {
+	switch status {
+	case http.StatusBadRequest, http.StatusMethodNotAllowed:
+		return errdefs.CodeUsage
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errdefs.CodeForbidden
+	case http.StatusNotFound:
+		return errdefs.CodeNotFound
+	case http.StatusConflict:
+		return errdefs.CodeConflict
+	case http.StatusTooManyRequests:
+		return errdefs.CodeTransient
+	case http.StatusUnprocessableEntity:
+		return errdefs.CodeReloadRejected
+	}
+	return errdefs.CodeUnknown
+}
+
+// openControlServer starts the control API on the socket in the state dir
+// of cfg. Without it the daemon still runs; the shell falls back to what it
+// can read from disk.
+func (e *Engine) openControlServer(cfg *config.Config) {
+	path := filepath.Join(system.StateDir(cfg), ControlSocketFile)
+	srv, err := ListenControl(path, e)
+	if err != nil {
+		e.logger.Warn("Control API unavailable", map[string]interface{}{"socket": path, "error": err.Error()})
+		return
+	}
+	e.control = srv
+	release := e.tracker.Track("control", routine.KindListener)
+	go func() {
+		defer release()
+		if err := srv.Serve(); err != nil {
+			e.logger.Error("Control API error", map[string]interface{}{"error": err.Error()})
+		}
+	}()
+	e.logger.Info("Control API listening", map[string]interface{}{"socket": path})
+}
+
+func (e *Engine) closeControlServer() {
+	if e.control == nil {
+		return
+	}
+	_ = e.control.Close()
+	e.control = nil
+}
+
+// Status reports the engine's lifecycle state and running config.
+func (e *Engine) Status() DaemonStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	status := DaemonStatus{
+		State:      e.stateLocked(),
+		StateSince: e.lifecycle.Since,
+		Active:     e.active,
+		Ready:      e.ready,
+		ConfigHash: e.cfgHash,
+		ConfigPath: e.configPath,
+		LogLevel:   strings.ToLower(e.logger.Level().String()),
+
+		Maintenance: e.maintenance.Enabled,
+	}
+	if e.maintenance.Enabled {
+		status.MaintenanceSince = e.maintenance.Since
+	}
+	if e.lifecycle.State != status.State {
+		// Changed since the last export, so the time isn't known yet
+		status.StateSince = time.Time{}
+	}
+	if !e.lastReconcile.Time.IsZero() {
+		last := e.lastReconcile
+		status.LastReconcile = &last
+	}
+	if !e.startedAt.IsZero() {
+		status.StartedAt = e.startedAt
+		status.UptimeSeconds = int64(e.clock.Now().Sub(e.startedAt).Seconds())
+	}
+	status.Pending = PendingWork{Stalled: e.reconcileQ.stalled, Draining: e.draining}
+	if e.reconcileQ.pending != reconcileNone {
+		status.Pending.Reconcile = e.reconcileQ.pending.String()
+	}
+	if e.reconcileQ.attempts > 0 && e.reconcileQ.nextRetry.After(e.clock.Now()) {
+		status.Pending.RetryAt = e.reconcileQ.nextRetry
+	}
+	if e.cfg != nil {
+		status.Node = e.cfg.Node.Name
+		status.Role = e.cfg.Node.Role
+		status.Generation = e.cfg.Generation
+		status.Services = len(e.cfg.Services)
+		for _, svc := range e.cfg.Services {
+			for _, b := range svc.Backends {
+				status.Backends.Total++
+				switch e.backendStates[health.BackendKey{Service: svc.Name, Backend: b.Address}] {
+				case health.StateHealthy:
+					status.Backends.Healthy++
+				case health.StateUnhealthy:
+					status.Backends.Unhealthy++
+				default:
+					status.Backends.Unknown++
+				}
+			}
+		}
+	}
+	return status
+}
+
+// Services summarizes every configured service.
+func (e *Engine) Services() []ServiceStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cfg == nil {
+		return nil
+	}
+	result := make([]ServiceStatus, 0, len(e.cfg.Services))
+	for _, svc := range e.cfg.Services {
+		st := ServiceStatus{
+			Name:      svc.Name,
+			VIP:       svc.VIP,
+			Protocol:  svc.Protocol,
+			Ports:     svc.Ports,
+			Scheduler: svc.Scheduler,
+			Backends:  len(svc.Backends),
+			Fallback:  e.fallbackActive[svc.Name],
+			Labels:    svc.Labels,
+		}
+		if st.VIP == "" {
+			st.VIP = e.cfg.Network.Frontend.VIP
+		}
+		for _, b := range svc.Backends {
+			if e.backendStates[health.BackendKey{Service: svc.Name, Backend: b.Address}] == health.StateHealthy {
+				st.Healthy++
+			}
+		}
+		result = append(result, st)
+	}
+	return result
+}
+
+// BackendHealth returns every configured backend with its last reported
+// health state, effective weight and override.
+func (e *Engine) BackendHealth() []BackendHealth {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cfg == nil {
+		return nil
+	}
+	var result []BackendHealth
+	for _, svc := range e.cfg.Services {
+		for _, b := range svc.Backends {
+			key := health.BackendKey{Service: svc.Name, Backend: b.Address}
+			bh := BackendHealth{Service: svc.Name, Backend: b.Address, State: health.StateUnknown, Weight: b.Weight}
+			if state, ok := e.backendStates[key]; ok {
+				bh.State = state
+			}
+			if w, ok := e.backendWeights[key]; ok && w >= 0 {
+				bh.Weight = w
+			}
+			if o, ok := e.overrides[key]; ok {
+				bh.Override = o.Mode
+			}
+			result = append(result, bh)
+		}
+	}
+	return result
+}
+
+// IPVS reads the IPVS table through the engine's reconciler, so callers
+// don't need netlink access of their own. See ipvs.TakeSnapshot.
+func (e *Engine) IPVS() (*ipvs.Snapshot, error) {
+	s, ok := e.reconciler.(snapshotter)
+	if !ok {
+		return nil, errdefs.WithCode(errdefs.CodeConflict, errors.New("reconciler can't read the IPVS table"))
+	}
+	return s.Snapshot()
+}
+
+// Reconcile queues a full reconcile, skipping any retry backoff. Standby
+// nodes have nothing to reconcile.
+func (e *Engine) Reconcile(ctx context.Context) error {
+	e.mu.Lock()
+	active := e.active
+	if active {
+		e.reconcileQ.request(reconcileReload)
+		e.reconcileQ.nextRetry = time.Time{}
+	}
+	e.mu.Unlock()
+	if !active {
+		return errors.New("node is standby; nothing to reconcile")
+	}
+	e.auditor.Emit(observability.AuditReconcileRequested, withCaller(callerOf(ctx).name, nil))
+	e.requestReconcile()
+	return nil
+}
+
+// Reload reloads the config as SIGHUP does and returns the load error, if
+// any. The reload runs on Run's goroutine, so it waits until Run picks it up
+// or ctx is done.
+func (e *Engine) Reload(ctx context.Context) error {
+	return e.ReloadFrom(ctx, "")
+}
+
+// ReloadFrom switches the daemon to the config file at path and reloads,
+// for blue/green config changes; "" reloads the current file. An invalid
+// file is rejected and the current config keeps running. The switch lasts
+// until the next switch or restart.
+func (e *Engine) ReloadFrom(ctx context.Context, path string) error {
+	req := reloadRequest{path: path, caller: callerOf(ctx).name, done: make(chan error, 1)}
+	select {
+	case e.reloadReqCh <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetLogLevel changes the daemon's log level until the next restart.
+func (e *Engine) SetLogLevel(ctx context.Context, level observability.LogLevel) {
+	e.logger.SetLevel(level)
+	fields := withCaller(callerOf(ctx).name, map[string]interface{}{"level": strings.ToLower(level.String())})
+	e.logger.Warn("Log level changed", fields)
+	e.auditor.Emit(observability.AuditLogLevelChanged, fields)
+}
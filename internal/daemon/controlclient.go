@@ -0,0 +1,160 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+	"github.com/malindarathnayake/LibraFlux/internal/health"
+	"github.com/malindarathnayake/LibraFlux/internal/ipvs"
+)
+
+// ControlClient calls a daemon's control API over its unix socket.
+type ControlClient struct {
+	path string
+	http *http.Client
+}
+
+// NewControlClient returns a client for the control socket at path. Nothing
+// is dialled until the first call.
+func NewControlClient(path string) *ControlClient {
+	dialer := &net.Dialer{Timeout: 2 * time.Second}
+	return &ControlClient{
+		path: path,
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", path)
+				},
+			},
+			// A reload can wait out a shell commit before it loads
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Status returns the daemon's lifecycle state and running config.
+func (c *ControlClient) Status(ctx context.Context) (*DaemonStatus, error) {
+	var status DaemonStatus
+	if err := c.call(ctx, http.MethodGet, "/v1/status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Services summarizes every configured service.
+func (c *ControlClient) Services(ctx context.Context) ([]ServiceStatus, error) {
+	var services []ServiceStatus
+	if err := c.call(ctx, http.MethodGet, "/v1/services", nil, &services); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+// BackendHealth returns the health of every configured backend.
+func (c *ControlClient) BackendHealth(ctx context.Context) ([]BackendHealth, error) {
+	var backends []BackendHealth
+	if err := c.call(ctx, http.MethodGet, "/v1/health", nil, &backends); err != nil {
+		return nil, err
+	}
+	return backends, nil
+}
+
+// IPVS returns the daemon's view of the kernel's IPVS table.
+func (c *ControlClient) IPVS(ctx context.Context) (*ipvs.Snapshot, error) {
+	var snap ipvs.Snapshot
+	if err := c.call(ctx, http.MethodGet, "/v1/ipvs", nil, &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// Reconcile queues a full reconcile.
+func (c *ControlClient) Reconcile(ctx context.Context) error {
+	return c.call(ctx, http.MethodPost, "/v1/reconcile", nil, nil)
+}
+
+// Reload reloads the daemon's config and returns the generation it runs.
+func (c *ControlClient) Reload(ctx context.Context) (uint64, error) {
+	var result ReloadResult
+	if err := c.call(ctx, http.MethodPost, "/v1/reload", nil, &result); err != nil {
+		return 0, err
+	}
+	return result.Generation, nil
+}
+
+// ReloadFrom switches the daemon to the config file at path, which must be
+// absolute, and returns the generation it runs. An invalid file is rejected
+// and the daemon keeps its current config.
+func (c *ControlClient) ReloadFrom(ctx context.Context, path string) (uint64, error) {
+	var result ReloadResult
+	if err := c.call(ctx, http.MethodPost, "/v1/reload", ReloadRequest{ConfigPath: path}, &result); err != nil {
+		return 0, err
+	}
+	return result.Generation, nil
+}
+
+// SetOverride drains a backend or forces its health; health.OverrideNone
+// clears the override. A positive ttl clears it automatically.
+func (c *ControlClient) SetOverride(ctx context.Context, service, backend string, mode health.Override, ttl time.Duration) error {
+	req := OverrideRequest{Service: service, Backend: backend, Mode: mode, TTLSeconds: int(ttl / time.Second)}
+	return c.call(ctx, http.MethodPost, "/v1/override", req, nil)
+}
+
+// SetLogLevel changes the daemon's log level until it restarts.
+func (c *ControlClient) SetLogLevel(ctx context.Context, level string) error {
+	return c.call(ctx, http.MethodPost, "/v1/log-level", LogLevelRequest{Level: level}, nil)
+}
+
+// SetMaintenance takes the node out of rotation, or puts it back.
+func (c *ControlClient) SetMaintenance(ctx context.Context, enabled bool) error {
+	return c.call(ctx, http.MethodPost, "/v1/maintenance", MaintenanceRequest{Enabled: enabled}, nil)
+}
+
+func (c *ControlClient) call(ctx context.Context, method, path string, body, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, "http://lbctl"+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return errdefs.WithCode(errdefs.CodeDaemonUnreachable, fmt.Errorf("daemon not reachable at %s: %w", c.path, err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var ce controlError
+		if err := json.NewDecoder(resp.Body).Decode(&ce); err != nil || ce.Error == "" {
+			return errdefs.WithCode(statusErrorCode(resp.StatusCode), fmt.Errorf("daemon returned %s", resp.Status))
+		}
+		if ce.Code == "" {
+			// A daemon from before error codes
+			ce.Code = statusErrorCode(resp.StatusCode)
+		}
+		return errdefs.WithCode(ce.Code, fmt.Errorf("daemon: %s", ce.Error))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid daemon response: %w", err)
+	}
+	return nil
+}
@@ -1,15 +1,35 @@
 package daemon
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"testing"
 	"time"
 
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
 	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+	"github.com/malindarathnayake/LibraFlux/internal/health"
+	"github.com/malindarathnayake/LibraFlux/internal/ipvs"
 	"github.com/malindarathnayake/LibraFlux/internal/observability"
+	"github.com/malindarathnayake/LibraFlux/internal/system"
 )
 
 type fakeTicker struct {
@@ -41,8 +61,9 @@ func (f *fakeNetworkManager) GetInterfaceStatus(_ string) (bool, error) {
 }
 
 type applyCall struct {
-	vip          string
+	vips         []string
 	serviceCount int
+	weights      []int // Every destination's weight, in config order
 }
 
 type fakeReconciler struct {
@@ -50,12 +71,19 @@ type fakeReconciler struct {
 	calls []applyCall
 }
 
-func (r *fakeReconciler) Apply(desired []config.Service, vip string) error {
+func (r *fakeReconciler) Apply(desired []config.Service, vips []string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	var weights []int
+	for _, svc := range desired {
+		for _, b := range svc.Backends {
+			weights = append(weights, b.Weight)
+		}
+	}
 	r.calls = append(r.calls, applyCall{
-		vip:          vip,
+		vips:         vips,
 		serviceCount: len(desired),
+		weights:      weights,
 	})
 	return nil
 }
@@ -137,15 +165,19 @@ func TestEngine_VIPTransitions_ApplyAndDisable(t *testing.T) {
 	net.setPresent(false)
 	ticker.ch <- time.Now()
 	time.Sleep(5 * time.Millisecond)
-	if rec.callCount() != 0 {
-		t.Fatalf("expected no reconcile while standby, got %d", rec.callCount())
+	// Starting standby clears leftover IPVS state once, then leaves it alone
+	if rec.callCount() != 1 {
+		t.Fatalf("expected only the startup cleanup while standby, got %d", rec.callCount())
+	}
+	if c, _ := rec.lastCall(); c.serviceCount != 0 {
+		t.Fatalf("expected the startup cleanup to program no services: %+v", c)
 	}
 
 	net.setPresent(true)
 	ticker.ch <- time.Now()
-	eventually(t, 200*time.Millisecond, func() bool { return rec.callCount() >= 1 })
+	eventually(t, 200*time.Millisecond, func() bool { return rec.callCount() >= 2 })
 	last, _ := rec.lastCall()
-	if last.vip != "192.0.2.10" || last.serviceCount != 1 {
+	if !slices.Equal(last.vips, []string{"192.0.2.10"}) || last.serviceCount != 1 {
 		t.Fatalf("unexpected apply call: %+v", last)
 	}
 
@@ -167,6 +199,294 @@ func TestEngine_VIPTransitions_ApplyAndDisable(t *testing.T) {
 	}
 }
 
+type fakeVRRPState struct {
+	mu    sync.Mutex
+	state string
+	err   error
+}
+
+func (f *fakeVRRPState) set(state string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state, f.err = state, err
+}
+
+func (f *fakeVRRPState) VRRPState(*config.Config) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state, f.err
+}
+
+func TestEngine_VRRPStateFromFRR(t *testing.T) {
+	net := &fakeNetworkManager{}
+	rec := &fakeReconciler{}
+	vrrp := &fakeVRRPState{state: system.VRRPStateBackup}
+	ticker := &fakeTicker{ch: make(chan time.Time, 10)}
+
+	cfg := &config.Config{
+		Node: config.NodeConfig{Name: "node-a", Role: "primary"},
+		Network: config.NetworkConfig{
+			Frontend: config.InterfaceConfig{Interface: "ens160", VIP: "192.0.2.10", CIDR: 32},
+		},
+		VRRP: config.VRRPConfig{VRID: 1, PriorityPrimary: 100, PrioritySecondary: 90, AdvertIntervalMS: 1000, StateSource: config.VRRPStateSourceFRR},
+		Services: []config.Service{
+			{Name: "svc1", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr", Backends: []config.Backend{{Address: "192.0.2.20", Weight: 1}}},
+		},
+	}
+
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        net,
+		Reconciler:     rec,
+		VRRPState:      vrrp,
+		NewTicker:      func(time.Duration) Ticker { return ticker },
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- engine.Run(ctx) }()
+
+	// The VIP seen on an interface doesn't count while FRR says Backup
+	net.setPresent(true)
+	ticker.ch <- time.Now()
+	time.Sleep(5 * time.Millisecond)
+	if c, ok := rec.lastCall(); !ok || c.serviceCount != 0 {
+		t.Fatalf("expected standby while FRR reports Backup, got %+v", c)
+	}
+
+	// Master is active before the address shows up
+	net.setPresent(false)
+	vrrp.set(system.VRRPStateMaster, nil)
+	ticker.ch <- time.Now()
+	eventually(t, 200*time.Millisecond, func() bool {
+		c, ok := rec.lastCall()
+		return ok && c.serviceCount == 1
+	})
+
+	// A failed query keeps the role
+	vrrp.set("", errors.New("vtysh: connection refused"))
+	calls := rec.callCount()
+	ticker.ch <- time.Now()
+	time.Sleep(5 * time.Millisecond)
+	if c, _ := rec.lastCall(); c.serviceCount != 1 || !engine.Status().Active {
+		t.Fatalf("expected to stay active when FRR can't be queried: %+v (calls %d -> %d)", c, calls, rec.callCount())
+	}
+
+	vrrp.set(system.VRRPStateBackup, nil)
+	ticker.ch <- time.Now()
+	eventually(t, 200*time.Millisecond, func() bool {
+		c, ok := rec.lastCall()
+		return ok && c.serviceCount == 0
+	})
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatalf("engine returned error: %v", err)
+	}
+}
+
+// adoptingReconciler records Adopt calls
+type adoptingReconciler struct {
+	fakeReconciler
+	adopted int
+}
+
+func (r *adoptingReconciler) Adopt(desired []config.Service, vips []string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.adopted++
+	return []string{"tcp:192.0.2.10:80"}, nil
+}
+
+func TestEngine_StartupPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy  string
+		applies int
+		adopts  int
+	}{
+		{"", 1, 0},
+		{"cleanup", 1, 0},
+		{"preserve", 0, 0},
+		{"ADOPT", 0, 1},
+	} {
+		rec := &adoptingReconciler{}
+		engine, err := NewEngine(EngineOptions{
+			ConfigPath: "ignored",
+			Logger:     observability.NewLogger(observability.ErrorLevel),
+			Network:    &fakeNetworkManager{},
+			Reconciler: rec,
+		})
+		if err != nil {
+			t.Fatalf("NewEngine: %v", err)
+		}
+		engine.cfg = &config.Config{
+			Node:     config.NodeConfig{Name: "node-a"},
+			Network:  config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+			Daemon:   config.DaemonConfig{StartupPolicy: tc.policy},
+			Services: []config.Service{{Name: "svc1", Protocol: "tcp", Ports: []int{80}}},
+		}
+
+		if err := engine.initialVIPSync(context.Background()); err != nil {
+			t.Fatalf("%q: initialVIPSync: %v", tc.policy, err)
+		}
+		if got := rec.callCount(); got != tc.applies {
+			t.Errorf("%q: expected %d applies, got %d", tc.policy, tc.applies, got)
+		}
+		if c, ok := rec.lastCall(); ok && c.serviceCount != 0 {
+			t.Errorf("%q: expected the cleanup to program no services: %+v", tc.policy, c)
+		}
+		if rec.adopted != tc.adopts {
+			t.Errorf("%q: expected %d adopts, got %d", tc.policy, tc.adopts, rec.adopted)
+		}
+		if !engine.Ready() {
+			t.Errorf("%q: expected a standby to be ready", tc.policy)
+		}
+	}
+}
+
+func TestEngine_WarmStandbyProgramsZeroWeights(t *testing.T) {
+	net := &fakeNetworkManager{}
+	rec := &fakeReconciler{}
+	ticker := &fakeTicker{ch: make(chan time.Time, 10)}
+	cfg := &config.Config{
+		Node:    config.NodeConfig{Name: "node-a", Role: "primary"},
+		Network: config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+		Daemon:  config.DaemonConfig{WarmStandby: true},
+		Services: []config.Service{
+			{Name: "svc1", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr", Backends: []config.Backend{
+				{Address: "192.0.2.20", Weight: 3},
+				{Address: "192.0.2.21", Weight: 5},
+			}},
+		},
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        net,
+		Reconciler:     rec,
+		NewTicker:      func(time.Duration) Ticker { return ticker },
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- engine.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-errCh
+	}()
+
+	lastWeights := func(want ...int) func() bool {
+		return func() bool {
+			c, ok := rec.lastCall()
+			return ok && c.serviceCount == 1 && slices.Equal(c.weights, want)
+		}
+	}
+
+	// A standby programs every destination at weight 0 at startup
+	eventually(t, 200*time.Millisecond, lastWeights(0, 0))
+	if !engine.Ready() {
+		t.Fatal("expected a warm standby to be ready")
+	}
+
+	net.setPresent(true)
+	ticker.ch <- time.Now()
+	eventually(t, 200*time.Millisecond, lastWeights(3, 5))
+
+	// Losing the VIP zeroes the weights instead of removing the services
+	net.setPresent(false)
+	ticker.ch <- time.Now()
+	eventually(t, 200*time.Millisecond, lastWeights(0, 0))
+}
+
+// fakeNotifier records service manager notifications
+type fakeNotifier struct {
+	mu       sync.Mutex
+	states   []string
+	watchdog time.Duration
+}
+
+func (n *fakeNotifier) Notify(state string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.states = append(n.states, state)
+	return nil
+}
+
+func (n *fakeNotifier) WatchdogInterval() time.Duration { return n.watchdog }
+
+func (n *fakeNotifier) sent() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return slices.Clone(n.states)
+}
+
+func TestEngine_NotifiesServiceManager(t *testing.T) {
+	notifier := &fakeNotifier{watchdog: 10 * time.Second}
+	reloadCh := make(chan struct{}, 1)
+	vipTicker := &fakeTicker{ch: make(chan time.Time, 10)}
+	watchdogTicker := &fakeTicker{ch: make(chan time.Time, 10)}
+	cfg := &config.Config{
+		Node:    config.NodeConfig{Name: "node-a"},
+		Network: config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath: "ignored",
+		Logger:     observability.NewLogger(observability.ErrorLevel),
+		Network:    &fakeNetworkManager{},
+		Reconciler: &fakeReconciler{},
+		Notifier:   notifier,
+		ReloadCh:   reloadCh,
+		NewTicker: func(d time.Duration) Ticker {
+			if d == 5*time.Second {
+				return watchdogTicker
+			}
+			return vipTicker
+		},
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- engine.Run(ctx) }()
+
+	waitFor := func(want ...string) {
+		t.Helper()
+		eventually(t, time.Second, func() bool {
+			sent := notifier.sent()
+			return len(sent) >= len(want) && slices.Equal(sent[len(sent)-len(want):], want)
+		})
+	}
+
+	// A standby is ready once it knows its role
+	waitFor("READY=1", "STATUS=Engine standby")
+
+	watchdogTicker.ch <- time.Now()
+	waitFor("WATCHDOG=1")
+
+	reloadCh <- struct{}{}
+	waitFor("RELOADING=1", "READY=1")
+
+	cancel()
+	<-errCh
+	waitFor("STOPPING=1")
+}
+
 func TestEngine_ReloadWhileActive_Reconciles(t *testing.T) {
 	net := &fakeNetworkManager{}
 	net.setPresent(true)
@@ -309,36 +629,4051 @@ func TestEngine_UsesConfigReconcileIntervalForTicker(t *testing.T) {
 	}
 }
 
-func TestContextWithSignals_ReloadAndCancel(t *testing.T) {
-	origNotify := notifySignals
-	origStop := stopSignals
-	defer func() {
-		notifySignals = origNotify
-		stopSignals = origStop
-	}()
+func TestEngine_SeparateVIPCheckInterval(t *testing.T) {
+	net := &fakeNetworkManager{}
+	rec := &fakeReconciler{}
 
-	var captured chan<- os.Signal
-	notifySignals = func(c chan<- os.Signal, _ ...os.Signal) { captured = c }
-	stopSignals = func(chan<- os.Signal) {}
+	var mu sync.Mutex
+	tickers := make(map[time.Duration]*fakeTicker)
+	cfg := &config.Config{
+		Node: config.NodeConfig{Name: "node-a", Role: "primary"},
+		Network: config.NetworkConfig{
+			Frontend: config.InterfaceConfig{Interface: "ens160", VIP: "192.0.2.10", CIDR: 32},
+			Backend:  config.InterfaceConfig{Interface: "ens192"},
+		},
+		VRRP: config.VRRPConfig{VRID: 1, PriorityPrimary: 100, PrioritySecondary: 90, AdvertIntervalMS: 1000},
+		Daemon: config.DaemonConfig{
+			ReconcileIntervalMS: 5000,
+			VIPCheckIntervalMS:  200,
+		},
+	}
 
-	ctx, reload, stop := ContextWithSignals(context.Background(), observability.NewLogger(observability.ErrorLevel))
-	defer stop()
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        net,
+		Reconciler:     rec,
+		ReloadCh:       make(chan struct{}),
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+		NewTicker: func(d time.Duration) Ticker {
+			mu.Lock()
+			defer mu.Unlock()
+			tickers[d] = &fakeTicker{ch: make(chan time.Time)}
+			return tickers[d]
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
 
-	if captured == nil {
-		t.Fatalf("expected signal channel to be captured")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- engine.Run(ctx) }()
+
+	ticker := func(d time.Duration) *fakeTicker {
+		mu.Lock()
+		defer mu.Unlock()
+		return tickers[d]
 	}
+	eventually(t, time.Second, func() bool { return ticker(200*time.Millisecond) != nil && ticker(5*time.Second) != nil })
+	eventually(t, time.Second, func() bool { return rec.callCount() == 1 }) // Startup cleanup
 
-	captured <- syscall.SIGHUP
-	select {
-	case <-reload:
-	case <-time.After(200 * time.Millisecond):
-		t.Fatalf("expected reload notification")
+	// The VIP check alone makes the node active and programs IPVS at once
+	net.setPresent(true)
+	ticker(200 * time.Millisecond).ch <- time.Now()
+	eventually(t, time.Second, func() bool { return engine.State() == StateActive })
+	calls := rec.callCount()
+	if calls != 2 {
+		t.Fatalf("expected one reconcile on acquiring the VIP, got %d calls", calls)
 	}
 
-	captured <- syscall.SIGTERM
+	// Further VIP checks and reconcile ticks leave a converged node alone
+	ticker(200 * time.Millisecond).ch <- time.Now()
+	ticker(5 * time.Second).ch <- time.Now()
+	ticker(200 * time.Millisecond).ch <- time.Now()
+	if got := rec.callCount(); got != calls || engine.State() != StateActive {
+		t.Fatalf("expected no reprogramming while converged, got %d calls in %s", got, engine.State())
+	}
+
+	// Losing the VIP removes the services without waiting for a reconcile tick
+	net.setPresent(false)
+	ticker(200 * time.Millisecond).ch <- time.Now()
+	eventually(t, time.Second, func() bool { return engine.State() == StateStandby })
+	if c, _ := rec.lastCall(); rec.callCount() != calls+1 || c.serviceCount != 0 {
+		t.Fatalf("expected one disable after releasing the VIP, got %d calls, last %+v", rec.callCount(), c)
+	}
+
+	cancel()
 	select {
-	case <-ctx.Done():
-	case <-time.After(200 * time.Millisecond):
-		t.Fatalf("expected context cancellation")
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("engine returned error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("engine did not exit")
+	}
+}
+
+// watchingNetworkManager reports address changes the test sends on changes.
+type watchingNetworkManager struct {
+	fakeNetworkManager
+	mu      sync.Mutex
+	err     error
+	watches int
+	changes chan system.AddressChange
+}
+
+func (f *watchingNetworkManager) WatchAddresses(done <-chan struct{}) (<-chan system.AddressChange, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.watches++
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.changes = make(chan system.AddressChange)
+	return f.changes, nil
+}
+
+func (f *watchingNetworkManager) subscription() (chan system.AddressChange, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.changes, f.watches
+}
+
+func TestEngine_VIPAddressSubscription(t *testing.T) {
+	nm := &watchingNetworkManager{}
+	rec := &fakeReconciler{}
+	ticker := &fakeTicker{ch: make(chan time.Time)}
+	cfg := &config.Config{
+		Node: config.NodeConfig{Name: "node-a", Role: "primary"},
+		Network: config.NetworkConfig{
+			Frontend: config.InterfaceConfig{Interface: "ens160", VIP: "192.0.2.10", CIDR: 32},
+			Backend:  config.InterfaceConfig{Interface: "ens192"},
+		},
+		VRRP: config.VRRPConfig{VRID: 1, PriorityPrimary: 100, PrioritySecondary: 90, AdvertIntervalMS: 1000},
+	}
+
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        nm,
+		Reconciler:     rec,
+		ReloadCh:       make(chan struct{}),
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+		NewTicker:      func(time.Duration) Ticker { return ticker },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- engine.Run(ctx) }()
+	eventually(t, time.Second, func() bool { _, n := nm.subscription(); return n == 1 })
+	changes, _ := nm.subscription()
+
+	// Another address changing doesn't check the VIP
+	nm.setPresent(true)
+	changes <- system.AddressChange{IP: net.ParseIP("192.0.2.99"), Added: true}
+	if engine.State() != StateStandby {
+		t.Fatalf("expected an unrelated address not to check the VIP, got %s", engine.State())
+	}
+
+	// The VIP being added is acted on without a tick
+	changes <- system.AddressChange{IP: net.ParseIP("192.0.2.10"), Added: true}
+	eventually(t, time.Second, func() bool { return engine.State() == StateActive })
+
+	// A broken subscription falls back to polling and is retried on the next tick
+	nm.mu.Lock()
+	nm.err = errors.New("netlink unavailable")
+	nm.mu.Unlock()
+	close(changes)
+	nm.setPresent(false)
+	tick := func() {
+		select {
+		case ticker.ch <- time.Now():
+		default:
+		}
+	}
+	eventually(t, time.Second, func() bool { tick(); _, n := nm.subscription(); return n == 2 })
+	eventually(t, time.Second, func() bool { return engine.State() == StateStandby })
+
+	nm.mu.Lock()
+	nm.err = nil
+	nm.mu.Unlock()
+	eventually(t, time.Second, func() bool { tick(); _, n := nm.subscription(); return n == 3 })
+	changes, _ = nm.subscription()
+	nm.setPresent(true)
+	changes <- system.AddressChange{IP: net.ParseIP("192.0.2.10"), Added: true}
+	eventually(t, time.Second, func() bool { return engine.State() == StateActive })
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("engine returned error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("engine did not exit")
+	}
+}
+
+func TestContextWithSignals_ReloadAndCancel(t *testing.T) {
+	origNotify := notifySignals
+	origStop := stopSignals
+	defer func() {
+		notifySignals = origNotify
+		stopSignals = origStop
+	}()
+
+	var captured chan<- os.Signal
+	notifySignals = func(c chan<- os.Signal, _ ...os.Signal) { captured = c }
+	stopSignals = func(chan<- os.Signal) {}
+
+	ctx, reload, stop := ContextWithSignals(context.Background(), observability.NewLogger(observability.ErrorLevel))
+	defer stop()
+
+	if captured == nil {
+		t.Fatalf("expected signal channel to be captured")
+	}
+
+	captured <- syscall.SIGHUP
+	select {
+	case <-reload:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatalf("expected reload notification")
+	}
+
+	captured <- syscall.SIGTERM
+	select {
+	case <-ctx.Done():
+	case <-time.After(200 * time.Millisecond):
+		t.Fatalf("expected context cancellation")
+	}
+}
+
+type failingReconciler struct {
+	mu    sync.Mutex
+	fail  bool
+	err   error // Returned instead of a generic error when set
+	calls int
+}
+
+func (r *failingReconciler) setFail(f bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fail = f
+}
+
+func (r *failingReconciler) Apply(_ []config.Service, _ []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if r.fail {
+		if r.err != nil {
+			return r.err
+		}
+		return errors.New("apply failed")
+	}
+	return nil
+}
+
+type recordingPreempter struct {
+	mu         sync.Mutex
+	calls      int
+	priorities []int
+}
+
+func (p *recordingPreempter) SetPriority(_ *config.Config, priority int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.priorities = append(p.priorities, priority)
+	return nil
+}
+
+func (p *recordingPreempter) EnablePreempt(_ *config.Config) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	return nil
+}
+
+func (p *recordingPreempter) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+func TestEngine_NotReadyUntilFirstReconcileSucceeds(t *testing.T) {
+	net := &fakeNetworkManager{}
+	net.setPresent(true)
+	rec := &failingReconciler{fail: true}
+	pre := &recordingPreempter{}
+	ticker := &fakeTicker{ch: make(chan time.Time, 10)}
+
+	cfg := &config.Config{
+		Node: config.NodeConfig{Name: "node-a", Role: "primary"},
+		Network: config.NetworkConfig{
+			Frontend: config.InterfaceConfig{Interface: "ens160", VIP: "192.0.2.10", CIDR: 32},
+			Backend:  config.InterfaceConfig{Interface: "ens192"},
+		},
+		VRRP: config.VRRPConfig{VRID: 1, PriorityPrimary: 100, PrioritySecondary: 90, AdvertIntervalMS: 1000, PreemptAfterReady: true},
+		Services: []config.Service{
+			{Name: "svc1", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr", Backends: []config.Backend{{Address: "192.0.2.20", Weight: 1}}},
+		},
+	}
+
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        net,
+		Reconciler:     rec,
+		Preempter:      pre,
+		ReloadCh:       make(chan struct{}),
+		NewTicker:      func(time.Duration) Ticker { return ticker },
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- engine.Run(ctx) }()
+
+	eventually(t, 200*time.Millisecond, func() bool {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return rec.calls >= 1
+	})
+	if engine.Ready() {
+		t.Fatalf("expected engine not ready after failed reconcile")
+	}
+	if pre.count() != 0 {
+		t.Fatalf("expected preemption to stay disabled, got %d calls", pre.count())
+	}
+
+	// First attempt has no backoff, so the next tick retries immediately
+	rec.setFail(false)
+	ticker.ch <- time.Now()
+	eventually(t, 200*time.Millisecond, engine.Ready)
+	if pre.count() != 1 {
+		t.Fatalf("expected preemption enabled once, got %d", pre.count())
+	}
+
+	cancel()
+	select {
+	case <-errCh:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatalf("engine did not exit")
+	}
+}
+
+func TestEngine_ReadyAfterLosingVIPBeforeFirstReconcile(t *testing.T) {
+	net := &fakeNetworkManager{}
+	net.setPresent(true)
+	rec := &failingReconciler{fail: true}
+	ticker := &fakeTicker{ch: make(chan time.Time, 10)}
+	cfg := &config.Config{
+		Node: config.NodeConfig{Name: "node-a", Role: "primary"},
+		Network: config.NetworkConfig{
+			Frontend: config.InterfaceConfig{Interface: "ens160", VIP: "192.0.2.10", CIDR: 32},
+		},
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        net,
+		Reconciler:     rec,
+		ReloadCh:       make(chan struct{}),
+		NewTicker:      func(time.Duration) Ticker { return ticker },
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- engine.Run(ctx) }()
+
+	eventually(t, 200*time.Millisecond, func() bool {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return rec.calls >= 1
+	})
+	if engine.Ready() {
+		t.Fatalf("expected engine not ready after failed reconcile")
+	}
+
+	// The VIP moves away before any reconcile succeeds; the disable succeeds
+	net.setPresent(false)
+	rec.setFail(false)
+	ticker.ch <- time.Now()
+	eventually(t, 200*time.Millisecond, engine.Ready)
+
+	cancel()
+	select {
+	case <-errCh:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatalf("engine did not exit")
+	}
+}
+
+func TestEngine_Maintenance(t *testing.T) {
+	net := &fakeNetworkManager{}
+	net.setPresent(true)
+	rec := &fakeReconciler{}
+	pre := &recordingPreempter{}
+	ticker := &fakeTicker{ch: make(chan time.Time, 10)}
+	stateDir := t.TempDir()
+	cfg := &config.Config{
+		Node:     config.NodeConfig{Name: "node-a", Role: "secondary"},
+		Network:  config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+		VRRP:     config.VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100},
+		System:   config.SystemConfig{StateDir: stateDir},
+		Services: []config.Service{{Name: "svc1", Protocol: "tcp", Ports: []int{80}, Backends: []config.Backend{{Address: "192.0.2.20", Weight: 1}}}},
+	}
+	newEngine := func() *Engine {
+		engine, err := NewEngine(EngineOptions{
+			ConfigPath:     "ignored",
+			Logger:         observability.NewLogger(observability.ErrorLevel),
+			Network:        net,
+			Reconciler:     rec,
+			Preempter:      pre,
+			NewTicker:      func(time.Duration) Ticker { return ticker },
+			LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+			ValidateConfig: func(*config.Config) error { return nil },
+		})
+		if err != nil {
+			t.Fatalf("NewEngine: %v", err)
+		}
+		return engine
+	}
+	run := func(engine *Engine) (context.CancelFunc, chan error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		errCh := make(chan error, 1)
+		go func() { errCh <- engine.Run(ctx) }()
+		return cancel, errCh
+	}
+	stop := func(cancel context.CancelFunc, errCh chan error) {
+		cancel()
+		if err := <-errCh; err != nil {
+			t.Fatalf("engine returned error: %v", err)
+		}
+	}
+	maintenance := map[string]string{"node": "node-a"}
+
+	engine := newEngine()
+	cancel, errCh := run(engine)
+	eventually(t, 200*time.Millisecond, func() bool { return engine.State() == StateActive })
+
+	// Entering maintenance drops to standby at once, although the VIP stays
+	if err := engine.SetMaintenance(context.Background(), true); err != nil {
+		t.Fatalf("SetMaintenance: %v", err)
+	}
+	if c, _ := rec.lastCall(); c.serviceCount != 0 {
+		t.Fatalf("expected IPVS services removed, got %+v", c)
+	}
+	if st := engine.Status(); st.State != StateStandby || st.Active || !st.Maintenance {
+		t.Fatalf("unexpected status in maintenance: %+v", st)
+	}
+	if got := gaugeValue(t, engine, "lbctl_maintenance_mode", maintenance); got != 1 {
+		t.Fatalf("lbctl_maintenance_mode = %v, want 1", got)
+	}
+	// Ticks keep it standby while the VIP is held
+	ticker.ch <- time.Now()
+	time.Sleep(5 * time.Millisecond)
+	if engine.State() != StateStandby {
+		t.Fatalf("expected standby while in maintenance, got %s", engine.State())
+	}
+	stop(cancel, errCh)
+
+	// Maintenance survives a restart: the node starts standby with the VIP
+	engine = newEngine()
+	cancel, errCh = run(engine)
+	eventually(t, 200*time.Millisecond, func() bool { return engine.Ready() })
+	if st := engine.Status(); st.State != StateStandby || !st.Maintenance {
+		t.Fatalf("expected to restart in maintenance: %+v", st)
+	}
+
+	if err := engine.SetMaintenance(context.Background(), false); err != nil {
+		t.Fatalf("SetMaintenance: %v", err)
+	}
+	if c, _ := rec.lastCall(); c.serviceCount != 1 {
+		t.Fatalf("expected IPVS services programmed again, got %+v", c)
+	}
+	if engine.State() != StateActive {
+		t.Fatalf("expected active after maintenance, got %s", engine.State())
+	}
+	if got := gaugeValue(t, engine, "lbctl_maintenance_mode", maintenance); got != 0 {
+		t.Fatalf("lbctl_maintenance_mode = %v, want 0", got)
+	}
+	stop(cancel, errCh)
+
+	pre.mu.Lock()
+	defer pre.mu.Unlock()
+	if want := []int{1, 1, 100}; !slices.Equal(pre.priorities, want) {
+		t.Fatalf("VRRP priorities = %v, want %v", pre.priorities, want)
+	}
+	if status, err := ReadMaintenance(stateDir); err != nil || status.Enabled {
+		t.Fatalf("expected maintenance recorded as off, got %+v, %v", status, err)
+	}
+}
+
+func TestEngine_FailoverBudget(t *testing.T) {
+	var out bytes.Buffer
+	logger := observability.NewLogger(observability.InfoLevel)
+	logger.SetConsoleOutput(&out)
+	clk := clock.NewFake(time.Unix(1000, 0))
+	rec := &failingReconciler{}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath: "ignored",
+		Logger:     logger,
+		Network:    &fakeNetworkManager{},
+		Reconciler: rec,
+		Clock:      clk,
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	cfg := &config.Config{
+		Node:    config.NodeConfig{Name: "node-a"},
+		Network: config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+		Daemon:  config.DaemonConfig{FailoverBudgetMS: 1000},
+	}
+	engine.cfg = cfg
+
+	// The first reconcile fails; the retry 3s later succeeds
+	rec.setFail(true)
+	engine.onVIPAcquired(context.Background(), cfg)
+	clk.Advance(3 * time.Second)
+	rec.setFail(false)
+	engine.tryReconcile(context.Background())
+
+	families, err := engine.metrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	var sum float64
+	var count uint64
+	for _, mf := range families {
+		if mf.GetName() == "lbctl_failover_programming_seconds" {
+			h := mf.GetMetric()[0].GetHistogram()
+			sum, count = h.GetSampleSum(), h.GetSampleCount()
+		}
+	}
+	if count != 1 || sum != 3 {
+		t.Fatalf("expected one 3s failover, got %d samples summing to %v", count, sum)
+	}
+	if !strings.Contains(out.String(), "Failover exceeded its time budget") {
+		t.Fatalf("expected a budget warning, got:\n%s", out.String())
+	}
+
+	// Later reconciles while active aren't failovers
+	engine.mu.Lock()
+	engine.reconcileQ.request(reconcileReload)
+	engine.mu.Unlock()
+	engine.tryReconcile(context.Background())
+	if got := rec.calls; got != 3 {
+		t.Fatalf("expected 3 reconciles, got %d", got)
+	}
+	families, _ = engine.metrics.Registry.Gather()
+	for _, mf := range families {
+		if mf.GetName() == "lbctl_failover_programming_seconds" && mf.GetMetric()[0].GetHistogram().GetSampleCount() != 1 {
+			t.Fatal("expected a reconcile after the failover not to be observed")
+		}
+	}
+}
+
+func TestEngine_ConfigSource(t *testing.T) {
+	bundle := config.BundleSource{Name: "test", Data: []byte("mode: dr\nnode:\n  name: node-a\nservices:\n  - name: web\n")}
+	engine, err := NewEngine(EngineOptions{
+		Source:         bundle,
+		Network:        &fakeNetworkManager{},
+		Reconciler:     &fakeReconciler{},
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	if err := engine.loadAndSetConfig(true); err != nil {
+		t.Fatalf("loadAndSetConfig: %v", err)
+	}
+	cfg := engine.cfg
+	if cfg.Node.Name != "node-a" || len(cfg.Services) != 1 {
+		t.Fatalf("expected the bundle's config, got %+v", cfg)
+	}
+	// Nothing on disk to watch
+	if fp := engine.configFingerprint(cfg); fp != "" {
+		t.Fatalf("expected no config fingerprint, got %q", fp)
+	}
+
+	engine, err = NewEngine(EngineOptions{
+		Source:     config.FileSource{Path: "/etc/lbctl/config.yaml"},
+		Network:    &fakeNetworkManager{},
+		Reconciler: &fakeReconciler{},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	if engine.configPath != "/etc/lbctl/config.yaml" {
+		t.Fatalf("expected the config path from the file source, got %q", engine.configPath)
+	}
+
+	if _, err := NewEngine(EngineOptions{Network: &fakeNetworkManager{}, Reconciler: &fakeReconciler{}}); err == nil {
+		t.Fatal("expected an error without a config path or source")
+	}
+}
+
+func TestEngine_PermanentConfigErrorStopsRetries(t *testing.T) {
+	net := &fakeNetworkManager{}
+	net.setPresent(true)
+	rec := &failingReconciler{fail: true, err: errdefs.PermanentConfig(errors.New("invalid VIP"))}
+	ticker := &fakeTicker{ch: make(chan time.Time, 10)}
+
+	cfg := &config.Config{
+		Node: config.NodeConfig{Name: "node-a", Role: "primary"},
+		Network: config.NetworkConfig{
+			Frontend: config.InterfaceConfig{Interface: "ens160", VIP: "192.0.2.10", CIDR: 32},
+			Backend:  config.InterfaceConfig{Interface: "ens192"},
+		},
+		VRRP: config.VRRPConfig{VRID: 1, PriorityPrimary: 100, PrioritySecondary: 90, AdvertIntervalMS: 1000},
+		Services: []config.Service{
+			{Name: "svc1", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr", Backends: []config.Backend{{Address: "192.0.2.20", Weight: 1}}},
+		},
+	}
+
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        net,
+		Reconciler:     rec,
+		ReloadCh:       make(chan struct{}),
+		NewTicker:      func(time.Duration) Ticker { return ticker },
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- engine.Run(ctx) }()
+
+	eventually(t, 200*time.Millisecond, func() bool {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return rec.calls >= 1
+	})
+
+	// Ticks alone must not retry a config error
+	for i := 0; i < 3; i++ {
+		ticker.ch <- time.Now()
+	}
+	time.Sleep(50 * time.Millisecond)
+	rec.mu.Lock()
+	calls := rec.calls
+	rec.mu.Unlock()
+	if calls != 1 {
+		t.Fatalf("expected a single reconcile attempt, got %d", calls)
+	}
+
+	cancel()
+	select {
+	case <-errCh:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatalf("engine did not exit")
+	}
+}
+
+func TestCalculateBackoff(t *testing.T) {
+	maxJitter := func(max time.Duration) time.Duration { return max }
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: 0},
+		{attempt: 2, want: 6 * time.Second},
+		{attempt: 3, want: 12 * time.Second},
+		{attempt: 7, want: 12 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := calculateBackoff(tt.attempt, backoffPolicyFor(&config.Config{}), maxJitter); got != tt.want {
+			t.Errorf("calculateBackoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+
+	cfg := &config.Config{Daemon: config.DaemonConfig{ReconcileBackoff: config.ReconcileBackoffConfig{InitialMS: 1000, MaxMS: 30_000}}}
+	noJitter := func(time.Duration) time.Duration { return 0 }
+	for attempt, want := range map[int]time.Duration{2: time.Second, 3: 2 * time.Second, 6: 16 * time.Second, 7: 30 * time.Second, math.MaxInt: 30 * time.Second} {
+		if got := calculateBackoff(attempt, backoffPolicyFor(cfg), noJitter); got != want {
+			t.Errorf("calculateBackoff(%d) with 1s-30s = %s, want %s", attempt, got, want)
+		}
+	}
+}
+
+func TestEngine_ReconcileCircuitBreaker(t *testing.T) {
+	var out bytes.Buffer
+	logger := observability.NewLogger(observability.InfoLevel)
+	logger.SetConsoleOutput(&out)
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath: "ignored",
+		Logger:     logger,
+		Network:    &fakeNetworkManager{},
+		Reconciler: &fakeReconciler{},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	cfg := &config.Config{
+		Node:   config.NodeConfig{Name: "node-a"},
+		Daemon: config.DaemonConfig{ReconcileBackoff: config.ReconcileBackoffConfig{BreakerThreshold: 3}},
+	}
+	node := map[string]string{"node": "node-a"}
+	fail := errors.New("netlink: permission denied")
+
+	engine.reconcileSucceeded(cfg)
+	engine.reconcileFailed(cfg, 1, fail)
+	engine.reconcileFailed(cfg, 2, fail)
+	if engine.breakerOpen || gaugeValue(t, engine, "lbctl_reconcile_circuit_open", node) != 0 {
+		t.Fatalf("expected the breaker closed below the threshold")
+	}
+
+	engine.reconcileFailed(cfg, 3, fail)
+	engine.reconcileFailed(cfg, 4, fail)
+	if !engine.breakerOpen || gaugeValue(t, engine, "lbctl_reconcile_circuit_open", node) != 1 {
+		t.Fatalf("expected the breaker open at the threshold")
+	}
+	if got := strings.Count(out.String(), "reconcile_circuit_open"); got != 1 || !strings.Contains(out.String(), "failures=3") {
+		t.Fatalf("expected one open audit event at 3 failures, got %d:\n%s", got, out.String())
+	}
+
+	engine.reconcileSucceeded(cfg)
+	if engine.breakerOpen || gaugeValue(t, engine, "lbctl_reconcile_circuit_open", node) != 0 || !strings.Contains(out.String(), "reconcile_circuit_closed") {
+		t.Fatalf("expected the breaker closed after a success:\n%s", out.String())
+	}
+}
+
+func TestCheckerForHealthComposite(t *testing.T) {
+	h := config.HealthCheck{Type: "tcp", Port: 80}
+	if c := checkerForHealth(h, nil, nil); c != nil {
+		t.Fatalf("plain tcp should use the scheduler default, got %T", c)
+	}
+
+	h.Probes = []config.HealthProbe{{Type: "dns", Port: 8053, QueryName: "ready.example"}}
+	h.Combine = "ANY"
+	c, ok := checkerForHealth(h, nil, nil).(*health.CompositeChecker)
+	if !ok {
+		t.Fatalf("expected composite checker, got %T", checkerForHealth(h, nil, nil))
+	}
+	if !c.Any || len(c.Probes) != 2 {
+		t.Fatalf("unexpected composite: any=%v probes=%d", c.Any, len(c.Probes))
+	}
+	if _, ok := c.Probes[0].Checker.(*health.TCPChecker); !ok || c.Probes[0].Port != 0 {
+		t.Fatalf("expected primary tcp probe on the check port, got %+v", c.Probes[0])
+	}
+	if _, ok := c.Probes[1].Checker.(*health.DNSChecker); !ok || c.Probes[1].Port != 8053 {
+		t.Fatalf("expected dns probe on 8053, got %+v", c.Probes[1])
+	}
+}
+
+func TestCheckerForHealthICMPPrecheck(t *testing.T) {
+	h := config.HealthCheck{Type: "tcp", Port: 80, ICMPPrecheck: true}
+	p, ok := checkerForHealth(h, nil, nil).(*health.PingPrecheck)
+	if !ok {
+		t.Fatalf("expected ping precheck, got %T", checkerForHealth(h, nil, nil))
+	}
+	if _, ok := p.Checker.(*health.TCPChecker); !ok {
+		t.Fatalf("expected precheck to wrap a tcp checker, got %T", p.Checker)
+	}
+
+	h = config.HealthCheck{Type: "redis", Port: 6379, ICMPPrecheck: true}
+	if p, ok := checkerForHealth(h, nil, nil).(*health.PingPrecheck); !ok {
+		t.Fatalf("expected ping precheck, got %T", checkerForHealth(h, nil, nil))
+	} else if _, ok := p.Checker.(*health.RedisChecker); !ok {
+		t.Fatalf("expected precheck to wrap a redis checker, got %T", p.Checker)
+	}
+}
+
+func TestCheckerForHealthTCPOptions(t *testing.T) {
+	h := config.HealthCheck{Type: "tcp", Port: 80, TCPReset: true}
+	if c, ok := checkerForHealth(h, nil, nil).(*health.TCPChecker); !ok || !c.Reset {
+		t.Fatalf("expected resetting tcp checker, got %#v", checkerForHealth(h, nil, nil))
+	}
+
+	h = config.HealthCheck{Type: "tcp", Port: 80, TCPMode: "half_open"}
+	if _, ok := checkerForHealth(h, nil, nil).(*health.HalfOpenChecker); !ok {
+		t.Fatalf("expected half-open checker, got %T", checkerForHealth(h, nil, nil))
+	}
+
+	h = config.HealthCheck{Type: "tcp", Port: 80, TCPMode: "reuse", TCPReset: true, TCPKeepaliveMS: 5000}
+	c, ok := checkerForHealth(h, nil, nil).(*health.ReuseTCPChecker)
+	if !ok {
+		t.Fatalf("expected reusing checker, got %T", checkerForHealth(h, nil, nil))
+	}
+	if c.Keepalive != 5*time.Second || !c.Reset {
+		t.Fatalf("unexpected reuse options: keepalive=%s reset=%v", c.Keepalive, c.Reset)
+	}
+
+	h = config.HealthCheck{Type: "tcp", Port: 80, TCPMode: "connect"}
+	if c := checkerForHealth(h, nil, nil); c != nil {
+		t.Fatalf("plain connect should use the scheduler default, got %T", c)
+	}
+}
+
+func TestCheckerForHealthSourcePortRange(t *testing.T) {
+	cfg := &config.Config{}
+	if d := healthDialer(cfg); d != nil {
+		t.Fatalf("expected no dialer without a source port range, got %T", d)
+	}
+	cfg.Daemon.Health = config.DaemonHealthConfig{SourcePortMin: 40000, SourcePortMax: 40999, ReuseAddr: true}
+	d, ok := healthDialer(cfg).(*health.PortRangeDialer)
+	if !ok || d.Min != 40000 || d.Max != 40999 || !d.ReuseAddr {
+		t.Fatalf("unexpected dialer: %#v", healthDialer(cfg))
+	}
+
+	h := config.HealthCheck{Type: "tcp", Port: 80}
+	if c, ok := checkerForHealth(h, d, nil).(*health.TCPChecker); !ok || c.Dialer != health.Dialer(d) {
+		t.Fatalf("expected tcp checker on the port range dialer, got %#v", checkerForHealth(h, d, nil))
+	}
+	h = config.HealthCheck{Type: "udp", Port: 53}
+	if c, ok := checkerForHealth(h, d, nil).(*health.UDPChecker); !ok || c.Dialer != health.Dialer(d) {
+		t.Fatalf("expected udp checker on the port range dialer, got %#v", checkerForHealth(h, d, nil))
+	}
+
+	// TCP probes of a composite check and behind an ICMP precheck too
+	h = config.HealthCheck{Type: "tcp", Port: 80, ICMPPrecheck: true,
+		Probes: []config.HealthProbe{{Type: "tcp", Port: 8080, TCPMode: "half_open"}, {Type: "udp", Port: 53}}}
+	clk := clock.NewFake(time.Unix(0, 0))
+	pre, ok := checkerForHealth(h, d, clk).(*health.PingPrecheck)
+	if !ok || pre.Clock != clock.Clock(clk) {
+		t.Fatalf("expected an ICMP precheck timed by the engine clock, got %#v", checkerForHealth(h, d, clk))
+	}
+	if p, ok := pre.Pinger.(*health.ICMPPinger); !ok || p.Dialer != health.Dialer(d) {
+		t.Fatalf("expected the pinger on the port range dialer, got %#v", pre.Pinger)
+	}
+	composite, ok := pre.Checker.(*health.CompositeChecker)
+	if !ok || len(composite.Probes) != 3 {
+		t.Fatalf("expected a composite of three probes, got %#v", pre.Checker)
+	}
+	for _, p := range composite.Probes {
+		var got health.Dialer
+		switch c := p.Checker.(type) {
+		case *health.TCPChecker:
+			got = c.Dialer
+		case *health.HalfOpenChecker:
+			got = c.Dialer
+		case *health.UDPChecker:
+			got = c.Dialer
+		}
+		if got != health.Dialer(d) {
+			t.Errorf("probe on port %d: expected the port range dialer, got %#v", p.Port, p.Checker)
+		}
+	}
+}
+
+func TestEngine_ReconcileBackoffFollowsClock(t *testing.T) {
+	net := &fakeNetworkManager{}
+	net.setPresent(true)
+	rec := &failingReconciler{fail: true}
+	ticker := &fakeTicker{ch: make(chan time.Time, 10)}
+	clk := clock.NewFake(time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	cfg := &config.Config{
+		Node: config.NodeConfig{Name: "node-a", Role: "primary"},
+		Network: config.NetworkConfig{
+			Frontend: config.InterfaceConfig{Interface: "ens160", VIP: "192.0.2.10", CIDR: 32},
+			Backend:  config.InterfaceConfig{Interface: "ens192"},
+		},
+		VRRP: config.VRRPConfig{VRID: 1, PriorityPrimary: 100, PrioritySecondary: 90, AdvertIntervalMS: 1000},
+		Services: []config.Service{
+			{Name: "svc1", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr", Backends: []config.Backend{{Address: "192.0.2.20", Weight: 1}}},
+		},
+	}
+
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        net,
+		Reconciler:     rec,
+		ReloadCh:       make(chan struct{}),
+		Clock:          clk,
+		NewTicker:      func(time.Duration) Ticker { return ticker },
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	engine.jitter = func(max time.Duration) time.Duration { return max / 2 }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- engine.Run(ctx) }()
+
+	calls := func() int {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return rec.calls
+	}
+	eventually(t, 200*time.Millisecond, func() bool { return calls() >= 1 })
+
+	// tick advances the clock, then waits for the engine to handle the tick
+	tick := func(d time.Duration) {
+		clk.Advance(d)
+		ticker.ch <- clk.Now()
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	tick(time.Millisecond) // First retry is immediate
+	if got := calls(); got != 2 {
+		t.Fatalf("expected immediate retry, got %d calls", got)
+	}
+
+	tick(5 * time.Second) // Second retry waits 5s + 0.5s jitter
+	if got := calls(); got != 2 {
+		t.Fatalf("retried during backoff, got %d calls", got)
+	}
+	tick(501 * time.Millisecond)
+	if got := calls(); got != 3 {
+		t.Fatalf("expected retry after backoff, got %d calls", got)
+	}
+
+	cancel()
+	select {
+	case <-errCh:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatalf("engine did not exit")
+	}
+}
+
+func TestEngine_MinHealthyFallback(t *testing.T) {
+	backends := []config.Backend{
+		{Address: "192.0.2.21", Weight: 10},
+		{Address: "192.0.2.22", Weight: 20},
+		{Address: "192.0.2.23", Weight: 30},
+	}
+	cfg := &config.Config{
+		Node: config.NodeConfig{Name: "node-a", Role: "primary"},
+		Services: []config.Service{
+			{Name: "svc1", Backends: backends, Health: config.HealthCheck{Enabled: true, MinHealthy: 2}},
+			{Name: "svc2", Backends: backends, Health: config.HealthCheck{Enabled: true, MinHealthy: 2, Fallback: "last_healthy"}},
+		},
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath: "ignored",
+		Logger:     observability.NewLogger(observability.ErrorLevel),
+		Network:    &fakeNetworkManager{},
+		Reconciler: &fakeReconciler{},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	weightsOf := func(svc config.Service) []int {
+		var w []int
+		for _, be := range svc.Backends {
+			w = append(w, be.Weight)
+		}
+		return w
+	}
+	apply := func(weights map[string]int) []config.Service {
+		m := make(map[health.BackendKey]int)
+		for _, svc := range cfg.Services {
+			for addr, w := range weights {
+				m[health.BackendKey{Service: svc.Name, Backend: addr}] = w
+			}
+		}
+		return engine.applyMinHealthy(cfg, applyEffectiveWeights(cfg.Services, m))
+	}
+
+	// Two of three healthy meets the threshold, so weights pass through
+	desired := apply(map[string]int{"192.0.2.21": 0, "192.0.2.22": 5})
+	for _, svc := range desired {
+		if got := weightsOf(svc); got[0] != 0 || got[1] != 5 || got[2] != 30 {
+			t.Fatalf("%s: expected health weights, got %v", svc.Name, got)
+		}
+	}
+
+	// One healthy is below min_healthy
+	desired = apply(map[string]int{"192.0.2.21": 0, "192.0.2.22": 0})
+	if got := weightsOf(desired[0]); got[0] != 10 || got[1] != 20 || got[2] != 30 {
+		t.Fatalf("fallback all: expected configured weights, got %v", got)
+	}
+	if got := weightsOf(desired[1]); got[0] != 0 || got[1] != 5 || got[2] != 30 {
+		t.Fatalf("fallback last_healthy: expected last healthy weights, got %v", got)
+	}
+	if !engine.fallbackActive["svc1"] || !engine.fallbackActive["svc2"] {
+		t.Fatalf("expected fallback to be active: %v", engine.fallbackActive)
+	}
+
+	// Recovery clears the fallback
+	apply(map[string]int{})
+	if engine.fallbackActive["svc1"] || engine.fallbackActive["svc2"] {
+		t.Fatalf("expected fallback to clear: %v", engine.fallbackActive)
+	}
+
+	// Draining below min_healthy keeps the drained backends out of the fallback
+	for _, svc := range cfg.Services {
+		engine.OnOverride(health.OverrideChange{Key: health.BackendKey{Service: svc.Name, Backend: "192.0.2.21"}, New: health.OverrideDrain, Reason: "set"})
+		engine.OnOverride(health.OverrideChange{Key: health.BackendKey{Service: svc.Name, Backend: "192.0.2.22"}, New: health.OverrideUnhealthy, Reason: "set"})
+	}
+	desired = apply(map[string]int{"192.0.2.21": 0, "192.0.2.22": 0})
+	for _, svc := range desired {
+		if got := weightsOf(svc); got[0] != 0 || got[1] != 0 || got[2] != 30 {
+			t.Fatalf("%s: expected overridden backends at weight 0, got %v", svc.Name, got)
+		}
+	}
+	if !engine.fallbackActive["svc1"] || !engine.fallbackActive["svc2"] {
+		t.Fatalf("expected fallback to be active: %v", engine.fallbackActive)
+	}
+}
+
+func TestEngine_ConfigInfoMetric(t *testing.T) {
+	node := config.NodeConfig{Name: "node-a", Role: "primary"}
+	services := []config.Service{{Name: "web", Protocol: "tcp", Ports: []int{80}}}
+	var loadErr error
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath: "ignored",
+		Logger:     observability.NewLogger(observability.ErrorLevel),
+		Network:    &fakeNetworkManager{},
+		Reconciler: &fakeReconciler{},
+		LoadConfig: func(string) (*config.Config, error) {
+			return &config.Config{Mode: "nat", Node: node, Services: services}, loadErr
+		},
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	info := func() map[string]string {
+		t.Helper()
+		families, err := engine.metrics.Registry.Gather()
+		if err != nil {
+			t.Fatalf("Gather: %v", err)
+		}
+		for _, mf := range families {
+			if mf.GetName() != "lbctl_config_info" {
+				continue
+			}
+			if len(mf.GetMetric()) != 1 {
+				t.Fatalf("expected one lbctl_config_info series, got %d", len(mf.GetMetric()))
+			}
+			labels := make(map[string]string)
+			for _, lp := range mf.GetMetric()[0].GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			return labels
+		}
+		return nil
+	}
+
+	if got := info(); got != nil {
+		t.Fatalf("expected no series before a config is loaded, got %v", got)
+	}
+	if err := engine.loadAndSetConfig(true); err != nil {
+		t.Fatalf("loadAndSetConfig: %v", err)
+	}
+	primary := info()
+	if primary["hash"] != engine.cfgHash || primary["role"] != "primary" || primary["mode"] != "nat" || primary["services_hash"] == "" {
+		t.Fatalf("unexpected lbctl_config_info labels: %v", primary)
+	}
+
+	// The secondary's config hash differs, its services hash doesn't
+	node = config.NodeConfig{Name: "node-a", Role: "secondary"}
+	if err := engine.loadAndSetConfig(false); err != nil {
+		t.Fatalf("loadAndSetConfig: %v", err)
+	}
+	secondary := info()
+	if secondary["role"] != "secondary" || secondary["hash"] == primary["hash"] || secondary["services_hash"] != primary["services_hash"] {
+		t.Fatalf("unexpected lbctl_config_info labels after a role change: %v (was %v)", secondary, primary)
+	}
+
+	// A failed load keeps the series of the running config
+	loadErr = errors.New("broken")
+	services = nil
+	if err := engine.loadAndSetConfig(false); err == nil {
+		t.Fatal("expected the load to fail")
+	}
+	if got := info(); !maps.Equal(got, secondary) {
+		t.Fatalf("expected labels unchanged after a failed load, got %v", got)
+	}
+}
+
+func TestEngine_ServiceLabelsInMetricsAndAudit(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath: "ignored",
+		Logger:     observability.NewLogger(observability.ErrorLevel),
+		Network:    &fakeNetworkManager{},
+		Reconciler: &fakeReconciler{},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	engine.cfg = &config.Config{
+		Node: config.NodeConfig{Name: "node-a"},
+		Services: []config.Service{
+			{Name: "pay", Labels: map[string]string{"team": "payments"}},
+			{Name: "dns"},
+		},
+	}
+
+	families, err := engine.metrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	got := make(map[string]string)
+	for _, mf := range families {
+		if mf.GetName() != "lbctl_service_info" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, lp := range m.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			got[labels["service"]] = labels["label_team"]
+		}
+	}
+	if len(got) != 2 || got["pay"] != "payments" || got["dns"] != "" {
+		t.Fatalf("unexpected lbctl_service_info series: %v", got)
+	}
+
+	fields := withServiceLabels(serviceLabels(engine.cfg, "pay"), map[string]interface{}{"service_name": "pay"})
+	if fields["label_team"] != "payments" {
+		t.Fatalf("expected label_team in audit fields, got %v", fields)
+	}
+}
+
+func TestEngine_ServiceObservabilityOverrides(t *testing.T) {
+	var out bytes.Buffer
+	logger := observability.NewLogger(observability.DebugLevel)
+	logger.SetConsoleOutput(&out)
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath: "ignored",
+		Logger:     logger,
+		Network:    &fakeNetworkManager{},
+		Reconciler: &fakeReconciler{},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	engine.cfg = &config.Config{
+		Node: config.NodeConfig{Name: "node-a"},
+		Services: []config.Service{
+			{Name: "quiet", Observability: config.ServiceObservability{DisableBackendMetrics: true, HealthLogLevel: "warn"}},
+			{Name: "loud"},
+		},
+	}
+
+	for _, svc := range []string{"quiet", "loud"} {
+		key := health.BackendKey{Service: svc, Backend: "192.0.2.30"}
+		engine.OnWeightChange(health.WeightChange{Key: key, OldWeight: 5, NewWeight: 0, Reason: "unhealthy"})
+		engine.OnStateChange(health.StateChange{Key: key, Old: health.StateHealthy, New: health.StateUnhealthy})
+	}
+
+	families, err := engine.metrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, mf := range families {
+		if mf.GetName() != "lbctl_health_backend_weight" && mf.GetName() != "lbctl_health_backend_healthy" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if lp.GetName() == "service" && lp.GetValue() == "quiet" {
+					t.Fatalf("unexpected %s series for service with backend metrics disabled", mf.GetName())
+				}
+			}
+		}
+	}
+
+	var quietWeight, quietState, loudWeight int
+	for _, line := range strings.Split(out.String(), "\n") {
+		switch {
+		case strings.Contains(line, "quiet") && strings.Contains(line, "backend_weight_changed"):
+			quietWeight++
+		case strings.Contains(line, "quiet") && strings.Contains(line, "health_state_changed"):
+			quietState++
+		case strings.Contains(line, "loud") && strings.Contains(line, "backend_weight_changed"):
+			loudWeight++
+		}
+	}
+	if quietWeight != 0 || quietState != 1 || loudWeight != 1 {
+		t.Fatalf("unexpected audit events (quiet weight=%d state=%d, loud weight=%d):\n%s", quietWeight, quietState, loudWeight, out.String())
+	}
+}
+
+func TestEngine_StateChangeFailureReason(t *testing.T) {
+	var out bytes.Buffer
+	logger := observability.NewLogger(observability.InfoLevel)
+	logger.SetConsoleOutput(&out)
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath: "ignored",
+		Logger:     logger,
+		Network:    &fakeNetworkManager{},
+		Reconciler: &fakeReconciler{},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	engine.cfg = &config.Config{
+		Node:     config.NodeConfig{Name: "node-a"},
+		Services: []config.Service{{Name: "web"}},
+	}
+
+	engine.OnStateChange(health.StateChange{
+		Key:                 health.BackendKey{Service: "web", Backend: "192.0.2.40"},
+		Old:                 health.StateHealthy,
+		New:                 health.StateUnhealthy,
+		Error:               "dial tcp 192.0.2.40:80: connect: connection refused",
+		Reason:              health.ReasonRefused,
+		ConsecutiveFailures: 3,
+		Time:                time.Unix(1000, 0),
+	})
+
+	if got := out.String(); !strings.Contains(got, "failure_reason=refused") || !strings.Contains(got, "consecutive_failures=3") {
+		t.Fatalf("expected failure context in audit event, got %q", got)
+	}
+	families, err := engine.metrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	got := make(map[string]float64)
+	for _, mf := range families {
+		if mf.GetName() != "lbctl_health_backend_failures_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if lp.GetName() == "reason" {
+					got[lp.GetValue()] = m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	if len(got) != 1 || got[health.ReasonRefused] != 1 {
+		t.Fatalf("unexpected lbctl_health_backend_failures_total series: %v", got)
+	}
+}
+
+// addrChecker reports every address it checks
+type addrChecker chan string
+
+func (c addrChecker) Check(address string, _ int, _ time.Duration) error {
+	select {
+	case c <- address:
+	default:
+	}
+	return nil
+}
+
+func TestEngine_HostnameBackend(t *testing.T) {
+	hc := config.HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 100, FailAfter: 1, RecoverAfter: 1}
+	cfg := &config.Config{
+		Mode: "dr",
+		Node: config.NodeConfig{Name: "node-a", Role: "primary"},
+		Network: config.NetworkConfig{
+			Frontend: config.InterfaceConfig{Interface: "eth0", VIP: "192.0.2.10", CIDR: 24},
+			Backend:  config.InterfaceConfig{Interface: "eth1"},
+		},
+		VRRP: config.VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 1000},
+		Services: []config.Service{{
+			Name: "web", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr", Health: hc,
+			// Resolved through /etc/hosts
+			Backends: []config.Backend{{Address: "localhost", Port: 80, Weight: 1}},
+		}},
+	}
+	checked := make(addrChecker, 1)
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        &fakeNetworkManager{},
+		Reconciler:     &fakeReconciler{},
+		Checker:        checked,
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: config.Validate,
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	if err := engine.loadAndSetConfig(true); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if err := engine.startHealthScheduler(); err != nil {
+		t.Fatalf("startHealthScheduler: %v", err)
+	}
+	t.Cleanup(engine.stopHealthScheduler)
+
+	select {
+	case addr := <-checked:
+		if ip := net.ParseIP(addr); ip == nil || !ip.IsLoopback() {
+			t.Fatalf("expected localhost to be checked at a loopback address, got %q", addr)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("backend was never checked")
+	}
+	if s := engine.Resolver().Stats(); s.Misses == 0 {
+		t.Fatalf("expected the check to go through the resolver, got %+v", s)
+	}
+}
+
+type okChecker struct{}
+
+func (okChecker) Check(string, int, time.Duration) error { return nil }
+
+func TestEngine_BackendOverrideSurvivesSchedulerRestart(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	hc := config.HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 100, FailAfter: 1, RecoverAfter: 1}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath: "ignored",
+		Logger:     observability.NewLogger(observability.ErrorLevel),
+		Network:    &fakeNetworkManager{},
+		Reconciler: &fakeReconciler{},
+		Checker:    okChecker{},
+		Clock:      clk,
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	engine.cfg = &config.Config{
+		Node: config.NodeConfig{Name: "node-a"},
+		Services: []config.Service{
+			{Name: "svc1", Health: hc, Backends: []config.Backend{{Address: "192.0.2.20", Weight: 5}}},
+		},
+	}
+	if err := engine.startHealthScheduler(); err != nil {
+		t.Fatalf("startHealthScheduler: %v", err)
+	}
+	t.Cleanup(engine.stopHealthScheduler)
+
+	key := health.BackendKey{Service: "svc1", Backend: "192.0.2.20"}
+	if err := engine.SetBackendOverride(context.Background(), "svc1", "192.0.2.99", health.OverrideDrain, 0); err == nil {
+		t.Fatalf("expected unknown backend to fail")
+	}
+	if err := engine.SetBackendOverride(context.Background(), "svc1", "192.0.2.20", health.OverrideDrain, time.Minute); err != nil {
+		t.Fatalf("SetBackendOverride: %v", err)
+	}
+	engine.mu.Lock()
+	got, weight := engine.overrides[key], engine.backendWeights[key]
+	engine.mu.Unlock()
+	if got.Mode != health.OverrideDrain || !got.Expires.Equal(clk.Now().Add(time.Minute)) || weight != 0 {
+		t.Fatalf("expected drain override with weight 0, got %+v weight %d", got, weight)
+	}
+
+	// A reload restarts the scheduler; the override must carry over
+	if err := engine.startHealthScheduler(); err != nil {
+		t.Fatalf("startHealthScheduler: %v", err)
+	}
+	engine.mu.Lock()
+	s := engine.scheduler
+	engine.mu.Unlock()
+	if o := s.Overrides()[key]; o.Mode != health.OverrideDrain {
+		t.Fatalf("expected override after restart, got %+v", o)
+	}
+
+	if err := engine.ClearBackendOverride(context.Background(), "svc1", "192.0.2.20"); err != nil {
+		t.Fatalf("ClearBackendOverride: %v", err)
+	}
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	if len(engine.overrides) != 0 {
+		t.Fatalf("expected no overrides after clear, got %v", engine.overrides)
+	}
+}
+
+func TestHealthPace(t *testing.T) {
+	tests := []struct {
+		mode   string
+		active bool
+		want   int
+	}{
+		{mode: "", active: false, want: 1},
+		{mode: "full", active: false, want: 1},
+		{mode: "reduced", active: false, want: standbyReducedPace},
+		{mode: "off", active: false, want: 0},
+		{mode: "OFF", active: false, want: 0},
+		{mode: "reduced", active: true, want: 1},
+		{mode: "off", active: true, want: 1},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{Daemon: config.DaemonConfig{Health: config.DaemonHealthConfig{StandbyMode: tt.mode}}}
+		if got := healthPace(cfg, tt.active); got != tt.want {
+			t.Errorf("healthPace(%q, active=%v) = %d, want %d", tt.mode, tt.active, got, tt.want)
+		}
+	}
+}
+
+func TestEngine_OverrideJournalReplay(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	hc := config.HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 100, FailAfter: 1, RecoverAfter: 1}
+	cfg := &config.Config{
+		Node:   config.NodeConfig{Name: "node-a"},
+		System: config.SystemConfig{StateDir: t.TempDir()},
+		Services: []config.Service{{Name: "svc1", Health: hc, Backends: []config.Backend{
+			{Address: "192.0.2.20", Weight: 5},
+			{Address: "192.0.2.21", Weight: 5},
+			{Address: "192.0.2.22", Weight: 5},
+		}}},
+	}
+	newEngine := func() *Engine {
+		engine, err := NewEngine(EngineOptions{
+			ConfigPath: "ignored",
+			Logger:     observability.NewLogger(observability.ErrorLevel),
+			Network:    &fakeNetworkManager{},
+			Reconciler: &fakeReconciler{},
+			Checker:    okChecker{},
+			Clock:      clk,
+		})
+		if err != nil {
+			t.Fatalf("NewEngine: %v", err)
+		}
+		engine.cfg = cfg
+		restored := engine.restoreOverrides(cfg)
+		if err := engine.startHealthScheduler(); err != nil {
+			t.Fatalf("startHealthScheduler: %v", err)
+		}
+		engine.reportRestoredOverrides(cfg, restored)
+		return engine
+	}
+
+	engine := newEngine()
+	if err := engine.SetBackendOverride(context.Background(), "svc1", "192.0.2.20", health.OverrideDrain, 0); err != nil {
+		t.Fatalf("SetBackendOverride: %v", err)
+	}
+	if err := engine.SetBackendOverride(context.Background(), "svc1", "192.0.2.21", health.OverrideUnhealthy, time.Minute); err != nil {
+		t.Fatalf("SetBackendOverride: %v", err)
+	}
+	if err := engine.SetBackendOverride(context.Background(), "svc1", "192.0.2.22", health.OverrideHealthy, 0); err != nil {
+		t.Fatalf("SetBackendOverride: %v", err)
+	}
+	if err := engine.ClearBackendOverride(context.Background(), "svc1", "192.0.2.22"); err != nil {
+		t.Fatalf("ClearBackendOverride: %v", err)
+	}
+	engine.stopHealthScheduler()
+
+	// A crash mid-append leaves a partial record
+	path := filepath.Join(cfg.System.StateDir, OverrideJournalFile)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	f.WriteString(`{"service":"svc1","backend":"192.0.2.2`)
+	f.Close()
+
+	// The TTL override expires while the daemon is down
+	clk.Advance(2 * time.Minute)
+	engine = newEngine()
+	t.Cleanup(engine.stopHealthScheduler)
+
+	engine.mu.Lock()
+	s, overrides := engine.scheduler, len(engine.overrides)
+	engine.mu.Unlock()
+	if overrides != 1 {
+		t.Fatalf("expected 1 restored override, got %d", overrides)
+	}
+	if o := s.Overrides()[health.BackendKey{Service: "svc1", Backend: "192.0.2.20"}]; o.Mode != health.OverrideDrain {
+		t.Fatalf("expected drain override after restart, got %+v", o)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read journal: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 1 {
+		t.Fatalf("expected the journal compacted to 1 record, got %d:\n%s", lines, data)
+	}
+}
+
+func TestEngine_ControlAPI(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	hc := config.HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 100, FailAfter: 1, RecoverAfter: 1}
+	stateDir := t.TempDir()
+	var mu sync.Mutex
+	gen := uint64(2)
+	load := func(string) (*config.Config, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if gen == 0 {
+			return nil, errors.New("broken config")
+		}
+		return &config.Config{
+			Node:       config.NodeConfig{Name: "node-a", Role: "secondary"},
+			Generation: gen,
+			Network:    config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+			System:     config.SystemConfig{StateDir: stateDir},
+			Services: []config.Service{
+				{Name: "svc1", Health: hc, Backends: []config.Backend{{Address: "192.0.2.20", Weight: 5}}},
+			},
+		}, nil
+	}
+	logger := observability.NewLogger(observability.ErrorLevel)
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         logger,
+		Network:        &fakeNetworkManager{},
+		Reconciler:     &fakeReconciler{},
+		Checker:        okChecker{},
+		Clock:          clk,
+		NewTicker:      func(time.Duration) Ticker { return &fakeTicker{ch: make(chan time.Time)} },
+		LoadConfig:     load,
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- engine.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-errCh
+	}()
+
+	client := NewControlClient(filepath.Join(stateDir, ControlSocketFile))
+	var status *DaemonStatus
+	eventually(t, 2*time.Second, func() bool {
+		status, err = client.Status(context.Background())
+		return err == nil
+	})
+	if status.Node != "node-a" || status.State != StateStandby || !status.Ready || status.Active || status.Generation != 2 || status.LogLevel != "error" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if status.Role != "secondary" || status.LastReconcile == nil || status.LastReconcile.Error != "" || !status.LastReconcile.Time.Equal(clk.Now()) {
+		t.Fatalf("expected the startup cleanup as the last reconcile: %+v", status)
+	}
+	if !status.StartedAt.Equal(time.Unix(1000, 0)) || status.Backends.Total != 1 || status.Pending != (PendingWork{}) {
+		t.Fatalf("unexpected uptime, backend summary or pending work: %+v", status)
+	}
+	if services, err := client.Services(context.Background()); err != nil || len(services) != 1 || services[0].Name != "svc1" || services[0].Backends != 1 {
+		t.Fatalf("unexpected services %+v: %v", services, err)
+	}
+
+	if err := client.Reconcile(context.Background()); err == nil || !strings.Contains(err.Error(), "standby") {
+		t.Fatalf("expected reconcile to be refused on standby, got %v", err)
+	} else if code := errdefs.CodeOf(err); code != errdefs.CodeConflict {
+		t.Fatalf("expected %s for a refused reconcile, got %s", errdefs.CodeConflict, code)
+	}
+
+	// fakeReconciler can't read the table back
+	if _, err := client.IPVS(context.Background()); errdefs.CodeOf(err) != errdefs.CodeConflict {
+		t.Fatalf("expected %s without a snapshotting reconciler, got %v", errdefs.CodeConflict, err)
+	}
+
+	if err := client.SetOverride(context.Background(), "svc1", "192.0.2.20", health.OverrideDrain, time.Minute); err != nil {
+		t.Fatalf("SetOverride: %v", err)
+	}
+	backends, err := client.BackendHealth(context.Background())
+	if err != nil {
+		t.Fatalf("BackendHealth: %v", err)
+	}
+	if len(backends) != 1 || backends[0].Service != "svc1" || backends[0].Backend != "192.0.2.20" ||
+		backends[0].Weight != 0 || backends[0].Override != health.OverrideDrain {
+		t.Fatalf("expected the drained backend, got %+v", backends)
+	}
+	if err := client.SetOverride(context.Background(), "svc1", "192.0.2.99", health.OverrideDrain, 0); err == nil {
+		t.Fatal("expected an unknown backend to be refused")
+	} else if code := errdefs.CodeOf(err); code != errdefs.CodeNotFound {
+		t.Fatalf("expected %s for an unknown backend, got %s", errdefs.CodeNotFound, code)
+	}
+
+	if err := client.SetLogLevel(context.Background(), "debug"); err != nil {
+		t.Fatalf("SetLogLevel: %v", err)
+	}
+	if logger.Level() != observability.DebugLevel {
+		t.Fatalf("expected debug level, got %s", logger.Level())
+	}
+	if err := client.SetLogLevel(context.Background(), "loud"); err == nil {
+		t.Fatal("expected an invalid level to be refused")
+	} else if code := errdefs.CodeOf(err); code != errdefs.CodeUsage {
+		t.Fatalf("expected %s for an invalid level, got %s", errdefs.CodeUsage, code)
+	}
+	logger.SetLevel(observability.ErrorLevel)
+
+	mu.Lock()
+	gen = 4
+	mu.Unlock()
+	if got, err := client.Reload(context.Background()); err != nil || got != 4 {
+		t.Fatalf("expected reload to generation 4, got %d, %v", got, err)
+	}
+	mu.Lock()
+	gen = 0
+	mu.Unlock()
+	if _, err := client.Reload(context.Background()); err == nil || !strings.Contains(err.Error(), "broken config") {
+		t.Fatalf("expected the load error, got %v", err)
+	}
+}
+
+func TestEngine_ReloadFromConfigPath(t *testing.T) {
+	stateDir := t.TempDir()
+	generations := map[string]uint64{"/etc/lbctl/blue.yaml": 1, "/etc/lbctl/green.yaml": 2}
+	load := func(path string) (*config.Config, error) {
+		gen, ok := generations[path]
+		if !ok {
+			return nil, fmt.Errorf("open %s: no such file", path)
+		}
+		return &config.Config{
+			Node:       config.NodeConfig{Name: "node-a", Role: "secondary"},
+			Generation: gen,
+			Network:    config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+			System:     config.SystemConfig{StateDir: stateDir},
+		}, nil
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "/etc/lbctl/blue.yaml",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        &fakeNetworkManager{},
+		Reconciler:     &fakeReconciler{},
+		Clock:          clock.NewFake(time.Unix(1000, 0)),
+		NewTicker:      func(time.Duration) Ticker { return &fakeTicker{ch: make(chan time.Time)} },
+		LoadConfig:     load,
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- engine.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-errCh
+	}()
+
+	client := NewControlClient(filepath.Join(stateDir, ControlSocketFile))
+	eventually(t, 2*time.Second, func() bool {
+		_, err := client.Status(context.Background())
+		return err == nil
+	})
+
+	if got, err := client.ReloadFrom(context.Background(), "/etc/lbctl/green.yaml"); err != nil || got != 2 {
+		t.Fatalf("expected the switch to generation 2, got %d, %v", got, err)
+	}
+	if st, _ := client.Status(context.Background()); st.ConfigPath != "/etc/lbctl/green.yaml" || st.Generation != 2 {
+		t.Fatalf("expected to run green.yaml, got %+v", st)
+	}
+	// Later reloads read the new file
+	if got, err := client.Reload(context.Background()); err != nil || got != 2 {
+		t.Fatalf("expected a reload of green.yaml, got %d, %v", got, err)
+	}
+
+	for path, want := range map[string]string{
+		"/etc/lbctl/missing.yaml": "no such file",
+		"relative.yaml":           "must be absolute",
+	} {
+		if _, err := client.ReloadFrom(context.Background(), path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected an error containing %q, got %v", path, want, err)
+		}
+	}
+	if st, _ := client.Status(context.Background()); st.ConfigPath != "/etc/lbctl/green.yaml" || st.Generation != 2 {
+		t.Fatalf("expected rejected switches to keep green.yaml, got %+v", st)
+	}
+
+	// The HTTP admin API can reload but not switch files
+	req := httptest.NewRequest(http.MethodPost, "/reload", strings.NewReader(`{"config_path": "/etc/lbctl/blue.yaml"}`))
+	req.Header.Set("Authorization", "Bearer 0123456789abcdef")
+	rec := httptest.NewRecorder()
+	adminHandler(engine, engine.metrics, config.HTTPAPIConfig{Token: "0123456789abcdef"}).ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected the admin API to refuse a switch, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestEngine_APICallerAudit(t *testing.T) {
+	hc := config.HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 100, FailAfter: 1, RecoverAfter: 1}
+	stateDir := t.TempDir()
+	load := func(string) (*config.Config, error) {
+		return &config.Config{
+			Node:    config.NodeConfig{Name: "node-a", Role: "secondary"},
+			Network: config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+			System:  config.SystemConfig{StateDir: stateDir},
+			Services: []config.Service{
+				{Name: "svc1", Health: hc, Backends: []config.Backend{{Address: "192.0.2.20", Weight: 5}}},
+			},
+		}, nil
+	}
+	var out bytes.Buffer
+	logger := observability.NewLogger(observability.InfoLevel)
+	logger.SetConsoleOutput(&out)
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         logger,
+		Network:        &fakeNetworkManager{},
+		Reconciler:     &fakeReconciler{},
+		Checker:        okChecker{},
+		Clock:          clock.NewFake(time.Unix(1000, 0)),
+		NewTicker:      func(time.Duration) Ticker { return &fakeTicker{ch: make(chan time.Time)} },
+		LoadConfig:     load,
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- engine.Run(ctx) }()
+
+	client := NewControlClient(filepath.Join(stateDir, ControlSocketFile))
+	eventually(t, 2*time.Second, func() bool {
+		_, err := client.Status(context.Background())
+		return err == nil
+	})
+	if err := client.SetOverride(context.Background(), "svc1", "192.0.2.20", health.OverrideDrain, 0); err != nil {
+		t.Fatalf("SetOverride: %v", err)
+	}
+	if err := client.SetMaintenance(context.Background(), true); err != nil {
+		t.Fatalf("SetMaintenance: %v", err)
+	}
+	if _, err := client.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if err := client.SetLogLevel(context.Background(), "info"); err != nil {
+		t.Fatalf("SetLogLevel: %v", err)
+	}
+
+	const token = "0123456789abcdef"
+	admin := adminHandler(engine, engine.metrics, config.HTTPAPIConfig{
+		Token:  token,
+		Tokens: []config.APIToken{{Name: "ci", Token: "fedcba9876543210"}},
+	})
+	for _, bearer := range []string{token, "fedcba9876543210"} {
+		req := httptest.NewRequest(http.MethodPost, "/reload", nil)
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("admin reload: %d: %s", rec.Code, rec.Body)
+		}
+	}
+
+	// Past its burst, a caller is turned away until its bucket refills
+	var limited error
+	for i := 0; i < 3*apiRateBurst && limited == nil; i++ {
+		limited = client.SetLogLevel(context.Background(), "info")
+	}
+	if limited == nil || !strings.Contains(limited.Error(), "too many requests") {
+		t.Fatalf("expected the control socket to rate limit, got %v", limited)
+	} else if code := errdefs.CodeOf(limited); code != errdefs.CodeTransient {
+		t.Fatalf("expected %s when rate limited, got %s", errdefs.CodeTransient, code)
+	}
+	if _, err := client.Status(context.Background()); err != nil {
+		t.Fatalf("expected reads not to be rate limited: %v", err)
+	}
+
+	cancel()
+	<-errCh
+
+	viaSocket := fmt.Sprintf("caller=uid=%d,pid=%d", os.Getuid(), os.Getpid())
+	sum := sha256.Sum256([]byte(token))
+	viaHTTP := "caller=token-id=" + hex.EncodeToString(sum[:4])
+	viaNamed := "caller=token-name=ci"
+	lines := strings.Split(out.String(), "\n")
+	audited := func(event, caller string) bool {
+		for _, line := range lines {
+			if strings.Contains(line, "_audit_event="+event+" ") && strings.Contains(line, caller) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, want := range []struct{ event, caller string }{
+		{"health_override", viaSocket},
+		{"maintenance_changed", viaSocket},
+		{"config_loaded", viaSocket},
+		{"log_level_changed", viaSocket},
+		{"config_loaded", viaHTTP},
+		{"config_loaded", viaNamed},
+	} {
+		if !audited(want.event, want.caller) {
+			t.Errorf("expected a %s audit event with %s, got:\n%s", want.event, want.caller, out.String())
+		}
+	}
+	for _, line := range lines {
+		if strings.Contains(line, "_audit_event=config_loaded ") && strings.Contains(line, "startup=true") && strings.Contains(line, "caller=") {
+			t.Errorf("expected the startup load without a caller, got %s", line)
+		}
+	}
+}
+
+func TestCallerLimiter(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	l := newCallerLimiter(clk.Now)
+	for i := 0; i < apiRateBurst; i++ {
+		if ok, _ := l.allow("uid=0"); !ok {
+			t.Fatalf("request %d refused within the burst", i+1)
+		}
+	}
+	ok, wait := l.allow("uid=0")
+	if ok || wait != apiRateInterval {
+		t.Fatalf("expected a refusal for %s past the burst, got %v, %s", apiRateInterval, ok, wait)
+	}
+	if ok, _ := l.allow("uid=1000"); !ok {
+		t.Fatal("expected another caller to have its own bucket")
+	}
+	clk.Advance(apiRateInterval)
+	if ok, _ := l.allow("uid=0"); !ok {
+		t.Fatal("expected a request allowed once the bucket refilled")
+	}
+	if ok, _ := l.allow("uid=0"); ok {
+		t.Fatal("expected only one request per interval after the burst")
+	}
+
+	// Requests the handler refuses by method don't use up tokens
+	h := newCallerLimiter(clk.Now).limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if controlMethod(w, r, http.MethodPost) {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	for i := 0; i < 2*apiRateBurst; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/reload", nil))
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("GET: expected 405, got %d", rec.Code)
+		}
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/reload", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected a POST allowed after refused GETs, got %d", rec.Code)
+	}
+}
+
+func TestEngine_AdminAPI(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	const token = "0123456789abcdef"
+	var mu sync.Mutex
+	api := config.HTTPAPIConfig{Enabled: true, Port: port, Token: token}
+	load := func(string) (*config.Config, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return &config.Config{
+			Node:       config.NodeConfig{Name: "node-a"},
+			Generation: 3,
+			Network:    config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+			Daemon:     config.DaemonConfig{API: config.APIConfig{HTTP: api}},
+			Services: []config.Service{
+				{Name: "svc1", Protocol: "tcp", Ports: []int{80}, Backends: []config.Backend{{Address: "192.0.2.20", Weight: 5}}},
+			},
+		}, nil
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        &fakeNetworkManager{},
+		Reconciler:     &fakeReconciler{},
+		Checker:        okChecker{},
+		Clock:          clock.NewFake(time.Unix(1000, 0)),
+		NewTicker:      func(time.Duration) Ticker { return &fakeTicker{ch: make(chan time.Time)} },
+		LoadConfig:     load,
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- engine.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-errCh
+	}()
+
+	base := fmt.Sprintf("http://127.0.0.1:%d", port)
+	call := func(method, path, bearer string, out interface{}) (int, error) {
+		req, err := http.NewRequest(method, base+path, nil)
+		if err != nil {
+			return 0, err
+		}
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		if out != nil && resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return resp.StatusCode, err
+			}
+		}
+		return resp.StatusCode, nil
+	}
+
+	var status DaemonStatus
+	eventually(t, 2*time.Second, func() bool {
+		code, err := call(http.MethodGet, "/status", token, &status)
+		return err == nil && code == http.StatusOK
+	})
+	if status.Node != "node-a" || status.Generation != 3 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	for _, bearer := range []string{"", "wrong-token-wrong-token"} {
+		if code, err := call(http.MethodGet, "/status", bearer, nil); err != nil || code != http.StatusUnauthorized {
+			t.Fatalf("expected 401 for token %q, got %d, %v", bearer, code, err)
+		}
+	}
+
+	var services []ServiceStatus
+	if code, err := call(http.MethodGet, "/services", token, &services); err != nil || code != http.StatusOK {
+		t.Fatalf("GET /services: %d, %v", code, err)
+	}
+	if len(services) != 1 || services[0].Name != "svc1" || services[0].VIP != "192.0.2.10" || services[0].Backends != 1 {
+		t.Fatalf("unexpected services: %+v", services)
+	}
+	var backends []BackendHealth
+	if code, err := call(http.MethodGet, "/backends", token, &backends); err != nil || code != http.StatusOK {
+		t.Fatalf("GET /backends: %d, %v", code, err)
+	}
+	if len(backends) != 1 || backends[0].Backend != "192.0.2.20" {
+		t.Fatalf("unexpected backends: %+v", backends)
+	}
+	var h AdminHealth
+	if code, err := call(http.MethodGet, "/health", token, &h); err != nil || code != http.StatusOK || h.Status != "ok" || h.State != StateStandby {
+		t.Fatalf("GET /health: %d, %+v, %v", code, h, err)
+	}
+	var catalog []observability.MetricDescription
+	if code, err := call(http.MethodGet, "/metrics/catalog", token, &catalog); err != nil || code != http.StatusOK {
+		t.Fatalf("GET /metrics/catalog: %d, %v", code, err)
+	}
+	found := false
+	for _, d := range catalog {
+		if d.Name == "lbctl_reconcile_runs_total" {
+			found = d.Type == "counter" && d.Help != ""
+		}
+	}
+	if !found {
+		t.Fatalf("expected lbctl_reconcile_runs_total in the catalog, got %+v", catalog)
+	}
+	if code, err := call(http.MethodGet, "/reload", token, nil); err != nil || code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected GET /reload to be refused, got %d, %v", code, err)
+	}
+
+	// Disabling the API on reload closes the listener
+	mu.Lock()
+	api.Enabled = false
+	mu.Unlock()
+	var result ReloadResult
+	if code, err := call(http.MethodPost, "/reload", token, &result); err != nil || code != http.StatusOK || result.Generation != 3 {
+		t.Fatalf("POST /reload: %d, %+v, %v", code, result, err)
+	}
+	eventually(t, 2*time.Second, func() bool {
+		_, err := call(http.MethodGet, "/status", token, nil)
+		return err != nil
+	})
+}
+
+func TestEngine_AutoReloadOnConfigChange(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	serviceFile := filepath.Join(dir, "config.d", "web.yaml")
+	if err := os.MkdirAll(filepath.Dir(serviceFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{path, serviceFile} {
+		if err := os.WriteFile(f, []byte("a\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clk := clock.NewFake(time.Unix(1000, 0))
+	ticker := &fakeTicker{ch: make(chan time.Time)}
+	var mu sync.Mutex
+	loads := 0
+	load := func(string) (*config.Config, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		loads++
+		return &config.Config{
+			Node:    config.NodeConfig{Name: "node-a"},
+			Network: config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+			Include: "config.d/*.yaml",
+			Daemon:  config.DaemonConfig{AutoReload: config.AutoReloadConfig{Enabled: true, DebounceMS: 1000}},
+		}, nil
+	}
+	loadCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return loads
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     path,
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        &fakeNetworkManager{},
+		Reconciler:     &fakeReconciler{},
+		Clock:          clk,
+		NewTicker:      func(time.Duration) Ticker { return ticker },
+		LoadConfig:     load,
+		ValidateConfig: func(*config.Config) error { return nil },
+		// Without a file watch, ticks poll the files
+		ConfigWatcher: &fakeConfigWatcher{err: errors.New("inotify unavailable")},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- engine.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-errCh
+	}()
+
+	// Each send waits for Run to finish the previous tick
+	tick := func() {
+		ticker.ch <- clk.Now()
+		ticker.ch <- clk.Now()
+	}
+	tick()
+	if n := loadCount(); n != 1 {
+		t.Fatalf("expected no reload without changes, got %d loads", n)
+	}
+
+	if err := os.WriteFile(serviceFile, []byte("a: changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tick()
+	clk.Advance(500 * time.Millisecond)
+	tick()
+	if n := loadCount(); n != 1 {
+		t.Fatalf("expected no reload within the debounce, got %d loads", n)
+	}
+
+	clk.Advance(time.Second)
+	tick()
+	if n := loadCount(); n != 2 {
+		t.Fatalf("expected a reload once the debounce passed, got %d loads", n)
+	}
+	clk.Advance(5 * time.Second)
+	tick()
+	if n := loadCount(); n != 2 {
+		t.Fatalf("expected a single reload per change, got %d loads", n)
+	}
+}
+
+// fakeConfigWatcher hands out events, a channel the test sends edits on
+type fakeConfigWatcher struct {
+	mu     sync.Mutex
+	err    error
+	dirs   []string
+	events chan struct{}
+}
+
+func (w *fakeConfigWatcher) WatchDirs(dirs []string, done <-chan struct{}) (<-chan struct{}, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return nil, w.err
+	}
+	w.dirs = dirs
+	return w.events, nil
+}
+
+func TestEngine_AutoReloadOnConfigEvent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	serviceFile := filepath.Join(dir, "config.d", "web.yaml")
+	if err := os.MkdirAll(filepath.Dir(serviceFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{path, serviceFile} {
+		if err := os.WriteFile(f, []byte("a\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clk := clock.NewFake(time.Unix(1000, 0))
+	ticker := &fakeTicker{ch: make(chan time.Time)}
+	var loads atomic.Int32
+	load := func(string) (*config.Config, error) {
+		loads.Add(1)
+		return &config.Config{
+			Node:    config.NodeConfig{Name: "node-a"},
+			Network: config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+			Include: "config.d/*.yaml",
+			Daemon:  config.DaemonConfig{AutoReload: config.AutoReloadConfig{Enabled: true, DebounceMS: 1000}},
+		}, nil
+	}
+	watcher := &fakeConfigWatcher{events: make(chan struct{})}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     path,
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        &fakeNetworkManager{},
+		Reconciler:     &fakeReconciler{},
+		Clock:          clk,
+		NewTicker:      func(time.Duration) Ticker { return ticker },
+		LoadConfig:     load,
+		ValidateConfig: func(*config.Config) error { return nil },
+		ConfigWatcher:  watcher,
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- engine.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-errCh
+	}()
+	// Each send waits for Run to finish the previous one
+	tick := func() {
+		ticker.ch <- clk.Now()
+		ticker.ch <- clk.Now()
+	}
+	tick()
+	watcher.mu.Lock()
+	dirs := watcher.dirs
+	watcher.mu.Unlock()
+	if want := []string{dir, filepath.Join(dir, "config.d")}; !slices.Equal(dirs, want) {
+		t.Fatalf("watched %v, want %v", dirs, want)
+	}
+
+	// Ticks don't poll while the watch is up
+	if err := os.WriteFile(serviceFile, []byte("a: changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(5 * time.Second)
+	tick()
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("expected no reload without an event, got %d loads", n)
+	}
+
+	// The event starts the debounce
+	watcher.events <- struct{}{}
+	tick()
+	clk.Advance(500 * time.Millisecond)
+	tick()
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("expected no reload within the debounce, got %d loads", n)
+	}
+	clk.Advance(time.Second)
+	eventually(t, time.Second, func() bool { return loads.Load() == 2 })
+
+	// A closed watch falls back to polling on ticks
+	close(watcher.events)
+	watcher.mu.Lock()
+	watcher.err = errors.New("inotify unavailable")
+	watcher.mu.Unlock()
+	// Run sees the closed channel in its own time; edit until a tick polls
+	for i := 0; loads.Load() == 2; i++ {
+		if i == 50 {
+			t.Fatal("expected polling to reload after the watch closed")
+		}
+		if err := os.WriteFile(serviceFile, []byte(fmt.Sprintf("a: %d\n", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		tick()
+		clk.Advance(2 * time.Second)
+		tick()
+	}
+}
+
+func TestEngine_ReloadDoesNotLeakGoroutines(t *testing.T) {
+	net := &fakeNetworkManager{}
+	net.setPresent(true)
+	reloadCh := make(chan struct{})
+	ticker := &fakeTicker{ch: make(chan time.Time, 10)}
+
+	hc := config.HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 100, FailAfter: 1, RecoverAfter: 1}
+	cfg := &config.Config{
+		Node: config.NodeConfig{Name: "node-a", Role: "primary"},
+		Network: config.NetworkConfig{
+			Frontend: config.InterfaceConfig{Interface: "ens160", VIP: "192.0.2.10", CIDR: 32},
+			Backend:  config.InterfaceConfig{Interface: "ens192"},
+		},
+		VRRP:   config.VRRPConfig{VRID: 1, PriorityPrimary: 100, PrioritySecondary: 90, AdvertIntervalMS: 1000},
+		Daemon: config.DaemonConfig{Health: config.DaemonHealthConfig{MaxInFlight: 1}},
+		Services: []config.Service{
+			{Name: "svc1", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr", Health: hc, Backends: []config.Backend{
+				{Address: "192.0.2.20", Weight: 1},
+				{Address: "192.0.2.21", Weight: 1},
+			}},
+		},
+	}
+
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        net,
+		Reconciler:     &fakeReconciler{},
+		Checker:        okChecker{},
+		ReloadCh:       reloadCh,
+		NewTicker:      func(time.Duration) Ticker { return ticker },
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	tracker := engine.Tracker()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- engine.Run(ctx) }()
+
+	for i := 0; i < 5; i++ {
+		reloadCh <- struct{}{}
+	}
+	eventually(t, 500*time.Millisecond, func() bool {
+		return tracker.Count("health_runner") == 2 && tracker.Count("health_worker") == 1
+	})
+
+	cancel()
+	select {
+	case <-errCh:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatalf("engine did not exit")
+	}
+	if err := tracker.WaitIdle(time.Second); err != nil {
+		t.Fatalf("leak after shutdown: %v", err)
+	}
+}
+
+func TestEngine_ReloadWaitsForCommitAndAcknowledges(t *testing.T) {
+	dir := t.TempDir()
+	confDir := filepath.Join(dir, "conf.d")
+	if err := os.Mkdir(confDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "config.yaml")
+	stateDir := t.TempDir()
+	inProgress, err := config.BeginCommit(confDir)
+	if err != nil {
+		t.Fatalf("BeginCommit: %v", err)
+	}
+
+	// The shell publishes its commit while the engine is retrying.
+	var loads int
+	var validateErr error
+	clk := clock.NewFake(time.Unix(1000, 0))
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath: configPath,
+		Logger:     observability.NewLogger(observability.ErrorLevel),
+		Network:    &fakeNetworkManager{},
+		Reconciler: &fakeReconciler{},
+		Clock:      clk,
+		LoadConfig: func(path string) (*config.Config, error) {
+			loads++
+			if loads == 3 {
+				if _, err := config.EndCommit(confDir, inProgress); err != nil {
+					t.Errorf("EndCommit: %v", err)
+				}
+			}
+			gen, err := config.ReadGeneration(confDir)
+			if err != nil {
+				return nil, err
+			}
+			if gen%2 == 1 {
+				return nil, config.ErrCommitInProgress
+			}
+			return &config.Config{Include: "conf.d/*.yaml", Generation: gen, System: config.SystemConfig{StateDir: stateDir}}, nil
+		},
+		ValidateConfig: func(*config.Config) error { return validateErr },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	if err := engine.loadConfigAfterCommit(context.Background(), true); err != nil {
+		t.Fatalf("loadConfigAfterCommit: %v", err)
+	}
+	if loads != 3 {
+		t.Fatalf("expected 3 load attempts, got %d", loads)
+	}
+	applied, err := config.ReadAppliedGeneration(stateDir)
+	if err != nil || applied == nil || applied.Generation != 2 || applied.Error != "" {
+		t.Fatalf("expected generation 2 acknowledged, got %#v, %v", applied, err)
+	}
+
+	// A rejected reload is acknowledged with the error and the new generation.
+	inProgress, _ = config.BeginCommit(confDir)
+	if _, err := config.EndCommit(confDir, inProgress); err != nil {
+		t.Fatalf("EndCommit: %v", err)
+	}
+	validateErr = errors.New("service web: invalid scheduler: fifo")
+	if err := engine.loadConfigAfterCommit(context.Background(), false); err == nil {
+		t.Fatalf("expected reload to fail validation")
+	}
+	applied, err = config.ReadAppliedGeneration(stateDir)
+	if err != nil || applied == nil || applied.Generation != 2 || applied.Rejected != 4 || !strings.Contains(applied.Error, "invalid scheduler") {
+		t.Fatalf("expected generation 4 rejection acknowledged, got %#v, %v", applied, err)
+	}
+	if got := engine.AppliedGeneration(); got != 2 {
+		t.Fatalf("expected previous config kept, got generation %d", got)
+	}
+	families, err := engine.metrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	var gauge float64
+	for _, mf := range families {
+		if mf.GetName() == "lbctl_config_generation" {
+			gauge = mf.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	if gauge != 2 {
+		t.Fatalf("expected lbctl_config_generation 2, got %v", gauge)
+	}
+}
+
+func TestEngine_AcknowledgeSurfacesUnwritableStateDir(t *testing.T) {
+	dir := t.TempDir()
+	// A path below a regular file can't be written even by root, unlike a
+	// read-only directory
+	blocker := filepath.Join(dir, "state")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	logger := observability.NewLogger(observability.WarnLevel)
+	logger.SetConsoleOutput(&out)
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath: filepath.Join(dir, "config.yaml"),
+		Logger:     logger,
+		Network:    &fakeNetworkManager{},
+		Reconciler: &fakeReconciler{},
+		LoadConfig: func(string) (*config.Config, error) {
+			return &config.Config{Include: "conf.d/*.yaml", Generation: 2, System: config.SystemConfig{StateDir: filepath.Join(blocker, "lbctl")}}, nil
+		},
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	// The reload itself still succeeds; only the acknowledgement fails
+	if err := engine.loadConfigAfterCommit(context.Background(), true); err != nil {
+		t.Fatalf("loadConfigAfterCommit: %v", err)
+	}
+	if got := out.String(); !strings.Contains(got, "Failed to acknowledge config generation") || !strings.Contains(got, blocker) {
+		t.Fatalf("expected acknowledgement failure logged with the state dir, got:\n%s", got)
+	}
+	if got := engine.AppliedGeneration(); got != 2 {
+		t.Fatalf("expected generation 2 applied, got %d", got)
+	}
+}
+
+func TestEngine_PeerHeartbeatUpdatesLastSeen(t *testing.T) {
+	// Reserve a free UDP port for the heartbeat channel
+	probe, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := probe.LocalAddr().(*net.UDPAddr).Port
+	probe.Close()
+
+	cfg := &config.Config{
+		Node: config.NodeConfig{Name: "lb-a", Role: "primary"},
+		VRRP: config.VRRPConfig{PeerAddress: "127.0.0.1", HeartbeatPort: port},
+	}
+	clk := clock.NewFake(time.Unix(1700000000, 0))
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "unused",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        &fakeNetworkManager{},
+		Reconciler:     &fakeReconciler{},
+		Clock:          clk,
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	if err := engine.loadAndSetConfig(true); err != nil {
+		t.Fatalf("loadAndSetConfig: %v", err)
+	}
+	engine.syncPeerChannel()
+	if engine.peer == nil {
+		t.Fatal("expected peer channel to be open")
+	}
+
+	lastSeen := func() (float64, bool) {
+		families, err := engine.metrics.Registry.Gather()
+		if err != nil {
+			t.Fatalf("Gather: %v", err)
+		}
+		for _, mf := range families {
+			if mf.GetName() != "lbctl_peer_last_seen_timestamp" {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				for _, lp := range m.GetLabel() {
+					if lp.GetName() == "remote" && lp.GetValue() == "lb-b" {
+						return m.GetGauge().GetValue(), true
+					}
+				}
+			}
+		}
+		return 0, false
+	}
+
+	sender, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	sender.Write([]byte("not json"))
+	sender.Write([]byte(`{"node":"lb-b","role":"secondary"}`))
+
+	eventually(t, 2*time.Second, func() bool {
+		v, ok := lastSeen()
+		return ok && v == 1700000000
+	})
+
+	// Dropping peer_address on reload closes the channel and frees the port
+	cfg.VRRP.PeerAddress = ""
+	if err := engine.loadAndSetConfig(false); err != nil {
+		t.Fatalf("loadAndSetConfig: %v", err)
+	}
+	engine.syncPeerChannel()
+	if engine.peer != nil {
+		t.Fatal("expected peer channel to be closed")
+	}
+	if err := engine.tracker.WaitIdle(time.Second); err != nil {
+		t.Fatalf("heartbeat receiver still running: %v", err)
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port})
+	if err != nil {
+		t.Fatalf("heartbeat port not released: %v", err)
+	}
+	conn.Close()
+}
+
+func TestEngine_SampleConnections(t *testing.T) {
+	var out bytes.Buffer
+	logger := observability.NewLogger(observability.InfoLevel)
+	logger.SetConsoleOutput(&out)
+
+	conn := func(client string, port uint16, dest string) ipvs.Connection {
+		return ipvs.Connection{
+			Protocol: "tcp", Client: net.ParseIP(client), ClientPort: 40000,
+			VIP: net.ParseIP("192.0.2.10"), Port: port,
+			Dest: net.ParseIP(dest), DestPort: port, State: "ESTABLISHED",
+		}
+	}
+	table := []ipvs.Connection{conn("203.0.113.7", 80, "10.0.0.1")}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:      "ignored",
+		Logger:          logger,
+		Network:         &fakeNetworkManager{},
+		Reconciler:      &fakeReconciler{},
+		ReadConnections: func() ([]ipvs.Connection, error) { return table, nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	cfg := &config.Config{
+		Network: config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+		Observability: config.ObsConfig{Logging: config.LoggingConfig{
+			Connections: config.ConnLogConfig{Enabled: true, SampleRate: 1},
+		}},
+		Services: []config.Service{
+			{Name: "web", Protocol: "tcp", Ports: []int{80}},
+			{Name: "range", Protocol: "TCP", PortRanges: []config.PortRange{{Start: 8000, End: 8010}}},
+		},
+	}
+
+	// Connections open before sampling starts are not reported
+	engine.pollConnections(cfg)
+	if strings.Contains(out.String(), "Connection sampled") {
+		t.Fatalf("expected first poll to only prime the sampler:\n%s", out.String())
+	}
+
+	table = append(table,
+		conn("203.0.113.99", 8005, "10.0.0.2"),
+		conn("2001:db8:1:2:3::9", 80, "10.0.0.1"),
+		conn("203.0.113.50", 9999, "10.0.0.3"), // Not a configured port
+	)
+	engine.pollConnections(cfg)
+	logs := out.String()
+	if strings.Count(logs, "Connection sampled") != 2 {
+		t.Fatalf("expected 2 sampled connections, got:\n%s", logs)
+	}
+	for _, want := range []string{"203.0.113.0/24", "range", "10.0.0.2:8005", "2001:db8:1:2::/64"} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("expected %q in connection logs:\n%s", want, logs)
+		}
+	}
+	if strings.Contains(logs, "203.0.113.99") || strings.Contains(logs, "10.0.0.3") {
+		t.Errorf("expected client address truncated and unknown ports skipped:\n%s", logs)
+	}
+
+	// Nothing new, nothing logged
+	out.Reset()
+	engine.pollConnections(cfg)
+	if out.Len() != 0 {
+		t.Errorf("expected no logs without new connections:\n%s", out.String())
+	}
+}
+
+func TestClientNet(t *testing.T) {
+	zero, eight, fortyEight := 0, 8, 48
+	v4, v6 := net.ParseIP("203.0.113.7"), net.ParseIP("2001:db8:1:2:3::9")
+	for _, tc := range []struct {
+		name   string
+		lc     config.ConnLogConfig
+		v4, v6 string
+	}{
+		{"defaults", config.ConnLogConfig{}, "203.0.113.0/24", "2001:db8:1:2::/64"},
+		{"explicit", config.ConnLogConfig{ClientPrefixV4: &eight, ClientPrefixV6: &fortyEight}, "203.0.0.0/8", "2001:db8:1::/48"},
+		// 0 is a real prefix, not "unset": nothing of the client is logged
+		{"zero", config.ConnLogConfig{ClientPrefixV4: &zero, ClientPrefixV6: &zero}, "0.0.0.0/0", "::/0"},
+	} {
+		if got := clientNet(v4, tc.lc); got != tc.v4 {
+			t.Errorf("%s: v4 got %s, want %s", tc.name, got, tc.v4)
+		}
+		if got := clientNet(v6, tc.lc); got != tc.v6 {
+			t.Errorf("%s: v6 got %s, want %s", tc.name, got, tc.v6)
+		}
+	}
+}
+
+func TestEngine_ExportsFlowsToIPFIX(t *testing.T) {
+	collector, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer collector.Close()
+
+	table := []ipvs.Connection{}
+	cfg := &config.Config{
+		Node:    config.NodeConfig{Name: "lb-a"},
+		Network: config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+		Observability: config.ObsConfig{Metrics: config.MetricsConfig{
+			IPFIX: config.IPFIXConfig{Enabled: true, Collector: collector.LocalAddr().String()},
+		}},
+		Services: []config.Service{{Name: "web", Protocol: "tcp", Ports: []int{80}}},
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:      "ignored",
+		Logger:          observability.NewLogger(observability.ErrorLevel),
+		Network:         &fakeNetworkManager{},
+		Reconciler:      &fakeReconciler{},
+		ReadConnections: func() ([]ipvs.Connection, error) { return table, nil },
+		LoadConfig:      func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig:  func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	if err := engine.loadAndSetConfig(true); err != nil {
+		t.Fatalf("loadAndSetConfig: %v", err)
+	}
+	engine.syncIPFIXExporter()
+	defer engine.closeIPFIXExporter()
+	if engine.ipfix == nil {
+		t.Fatal("expected IPFIX exporter to be open")
+	}
+
+	engine.pollConnections(cfg) // Primes the sampler
+	table = append(table, ipvs.Connection{
+		Protocol: "tcp", Client: net.ParseIP("203.0.113.7"), ClientPort: 40000,
+		VIP: net.ParseIP("192.0.2.10"), Port: 80, Dest: net.ParseIP("10.0.0.1"), DestPort: 80,
+	})
+	engine.pollConnections(cfg)
+
+	buf := make([]byte, 2048)
+	collector.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := collector.Read(buf); err != nil {
+		t.Fatalf("expected an IPFIX message: %v", err)
+	}
+	families, err := engine.metrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	var exported float64
+	for _, mf := range families {
+		if mf.GetName() != "lbctl_ipfix_records_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if lp.GetName() == "result" && lp.GetValue() == "exported" {
+					exported = m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	if exported != 1 {
+		t.Errorf("expected 1 exported record, got %v", exported)
+	}
+
+	// Disabling export on reload closes the exporter
+	cfg.Observability.Metrics.IPFIX.Enabled = false
+	if err := engine.loadAndSetConfig(false); err != nil {
+		t.Fatalf("loadAndSetConfig: %v", err)
+	}
+	engine.syncIPFIXExporter()
+	if engine.ipfix != nil {
+		t.Error("expected IPFIX exporter to be closed")
+	}
+}
+
+func TestEngine_SyncsGELF(t *testing.T) {
+	collector, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer collector.Close()
+	port := collector.LocalAddr().(*net.UDPAddr).Port
+
+	logger := observability.NewLogger(observability.ErrorLevel)
+	defer logger.Close()
+	cfg := &config.Config{
+		Node: config.NodeConfig{Name: "lb-a"},
+		Observability: config.ObsConfig{Logging: config.LoggingConfig{
+			GELF: config.GELFLogConfig{Enabled: true, Host: "127.0.0.1", Port: port, Protocol: "udp", Facility: "lbctl", Compression: "none"},
+		}},
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         logger,
+		Network:        &fakeNetworkManager{},
+		Reconciler:     &fakeReconciler{},
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	received := func() []byte {
+		t.Helper()
+		logger.Error("gelf probe", nil)
+		buf := make([]byte, 8192)
+		collector.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, err := collector.Read(buf)
+		if err != nil {
+			t.Fatalf("expected a GELF message: %v", err)
+		}
+		return buf[:n]
+	}
+
+	if err := engine.loadAndSetConfig(true); err != nil {
+		t.Fatalf("loadAndSetConfig: %v", err)
+	}
+	engine.syncGELF()
+	if msg := received(); msg[0] != '{' {
+		t.Fatalf("expected an uncompressed message with compression none, got % x", msg[:2])
+	}
+
+	// A reload that changes only the compression replaces the writer
+	cfg.Observability.Logging.GELF.Compression = "gzip"
+	if err := engine.loadAndSetConfig(false); err != nil {
+		t.Fatalf("loadAndSetConfig: %v", err)
+	}
+	engine.syncGELF()
+	if msg := received(); msg[0] != 0x1f || msg[1] != 0x8b {
+		t.Fatalf("expected a gzip message after the reload, got % x", msg[:2])
+	}
+
+	cfg.Observability.Logging.GELF.Enabled = false
+	if err := engine.loadAndSetConfig(false); err != nil {
+		t.Fatalf("loadAndSetConfig: %v", err)
+	}
+	engine.syncGELF()
+	if engine.gelfCfg.Enabled {
+		t.Fatal("expected GELF output disabled")
+	}
+}
+
+type syncReconciler struct {
+	fakeReconciler
+	fail  bool
+	calls [][]ipvs.SyncDaemon
+}
+
+func (r *syncReconciler) SetSyncDaemons(want []ipvs.SyncDaemon) error {
+	r.calls = append(r.calls, want)
+	if r.fail {
+		return errors.New("netlink unavailable")
+	}
+	return nil
+}
+
+func TestEngine_ConnSyncFollowsVIPOwnership(t *testing.T) {
+	rec := &syncReconciler{fail: true}
+	cfg := &config.Config{
+		Node:   config.NodeConfig{Name: "lb-a"},
+		Daemon: config.DaemonConfig{ConnSync: config.ConnSyncConfig{Enabled: true, Interface: "eth1", SyncID: 3}},
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        &fakeNetworkManager{},
+		Reconciler:     rec,
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	// A failed start is retried on the next tick
+	engine.syncConnSync(cfg, false)
+	rec.fail = false
+	engine.syncConnSync(cfg, false)
+	engine.syncConnSync(cfg, false)
+	if len(rec.calls) != 2 {
+		t.Fatalf("expected a retry and then no changes, got %d calls", len(rec.calls))
+	}
+	if got := rec.calls[1]; len(got) != 1 || got[0].State != ipvs.SyncBackup || got[0].Interface != "eth1" || got[0].SyncID != 3 {
+		t.Fatalf("expected backup daemon on standby, got %+v", got)
+	}
+	if got := gaugeValue(t, engine, "lbctl_ipvs_sync_daemon_state", map[string]string{"state": "backup"}); got != 1 {
+		t.Errorf("expected backup state gauge 1, got %v", got)
+	}
+
+	engine.syncConnSync(cfg, true)
+	if got := rec.calls[len(rec.calls)-1]; len(got) != 1 || got[0].State != ipvs.SyncMaster {
+		t.Fatalf("expected master daemon once active, got %+v", got)
+	}
+	if got := gaugeValue(t, engine, "lbctl_ipvs_sync_daemon_state", map[string]string{"state": "backup"}); got != 0 {
+		t.Errorf("expected backup state gauge 0, got %v", got)
+	}
+
+	cfg.Daemon.ConnSync.Enabled = false
+	engine.syncConnSync(cfg, true)
+	if got := rec.calls[len(rec.calls)-1]; len(got) != 0 {
+		t.Fatalf("expected daemons stopped once disabled, got %+v", got)
+	}
+}
+
+type timeoutReconciler struct {
+	fakeReconciler
+	fail  bool
+	calls []ipvs.Timeouts
+}
+
+func (r *timeoutReconciler) SetTimeouts(want ipvs.Timeouts) error {
+	r.calls = append(r.calls, want)
+	if r.fail {
+		return errors.New("netlink unavailable")
+	}
+	return nil
+}
+
+func TestEngine_SyncIPVSTimeouts(t *testing.T) {
+	rec := &timeoutReconciler{fail: true}
+	cfg := &config.Config{
+		Node:   config.NodeConfig{Name: "lb-a"},
+		Daemon: config.DaemonConfig{IPVSTimeouts: config.IPVSTimeoutsConfig{TCP: 3600}},
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        &fakeNetworkManager{},
+		Reconciler:     rec,
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	// A failed change is retried on the next tick, then left alone
+	engine.syncIPVSTimeouts(cfg)
+	rec.fail = false
+	engine.syncIPVSTimeouts(cfg)
+	engine.syncIPVSTimeouts(cfg)
+	if len(rec.calls) != 2 {
+		t.Fatalf("expected a retry and then no changes, got %d calls", len(rec.calls))
+	}
+	if got := rec.calls[1]; got != (ipvs.Timeouts{TCP: time.Hour}) {
+		t.Fatalf("expected tcp timeout of an hour, got %+v", got)
+	}
+
+	cfg.Daemon.IPVSTimeouts.UDP = 10
+	engine.syncIPVSTimeouts(cfg)
+	if got := rec.calls[len(rec.calls)-1]; got != (ipvs.Timeouts{TCP: time.Hour, UDP: 10 * time.Second}) {
+		t.Fatalf("expected udp timeout added, got %+v", got)
+	}
+}
+
+type serviceReconciler struct {
+	fakeReconciler
+	applied []struct{ current, desired *config.Service }
+}
+
+func (r *serviceReconciler) ApplyService(current, desired *config.Service, _ []string) error {
+	r.applied = append(r.applied, struct{ current, desired *config.Service }{current, desired})
+	return nil
+}
+
+func TestEngine_ReloadServiceReplacesOnlyThatService(t *testing.T) {
+	dir := t.TempDir()
+	confDir := filepath.Join(dir, "conf.d")
+	if err := os.Mkdir(confDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	hc := config.HealthCheck{Enabled: true, Type: "tcp", Port: 80, IntervalMS: 1000, TimeoutMS: 100, FailAfter: 1, RecoverAfter: 1}
+	running := &config.Config{
+		Include: "conf.d/*.yaml",
+		System:  config.SystemConfig{StateDir: t.TempDir()},
+		Node:    config.NodeConfig{Name: "node-a", Role: "primary"},
+		Network: config.NetworkConfig{Frontend: config.InterfaceConfig{Interface: "ens160", VIP: "192.0.2.10", CIDR: 32}},
+		Services: []config.Service{
+			{Name: "web", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr", Health: hc, Backends: []config.Backend{{Address: "192.0.2.20", Weight: 1}}},
+			{Name: "api", Protocol: "tcp", Ports: []int{443}, Scheduler: "rr", Backends: []config.Backend{{Address: "192.0.2.30", Weight: 1}}},
+		},
+	}
+	onDisk := &config.Config{
+		Include: running.Include,
+		System:  running.System,
+		Node:    running.Node,
+		Network: running.Network,
+		Services: []config.Service{
+			{Name: "web", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr", Health: hc, Backends: []config.Backend{{Address: "192.0.2.21", Weight: 1}}},
+			{Name: "api", Protocol: "tcp", Ports: []int{8443}, Scheduler: "rr", Backends: []config.Backend{{Address: "192.0.2.30", Weight: 1}}},
+			{Name: "dns", Protocol: "udp", Ports: []int{53}, Scheduler: "rr", Backends: []config.Backend{{Address: "192.0.2.40", Weight: 1}}},
+		},
+	}
+	loaded := running
+	rec := &serviceReconciler{}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     filepath.Join(dir, "config.yaml"),
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        &fakeNetworkManager{},
+		Reconciler:     rec,
+		Checker:        okChecker{},
+		LoadConfig:     func(string) (*config.Config, error) { return loaded, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	ctx := context.Background()
+	if err := engine.loadConfigAfterCommit(ctx, true); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if err := engine.startHealthScheduler(); err != nil {
+		t.Fatalf("startHealthScheduler: %v", err)
+	}
+	t.Cleanup(engine.stopHealthScheduler)
+	engine.active = true
+	loaded = onDisk
+
+	if err := engine.reloadService(ctx, "web"); err != nil {
+		t.Fatalf("reloadService(web): %v", err)
+	}
+	cfg := engine.cfg
+	if svc := findService(cfg, "web"); svc == nil || svc.Backends[0].Address != "192.0.2.21" {
+		t.Fatalf("expected web to be reloaded, got %+v", svc)
+	}
+	if svc := findService(cfg, "api"); svc == nil || svc.Ports[0] != 443 {
+		t.Fatalf("expected api to keep its running config, got %+v", svc)
+	}
+	if findService(cfg, "dns") != nil {
+		t.Fatalf("expected dns to stay unloaded")
+	}
+	if len(rec.applied) != 1 || rec.applied[0].current.Backends[0].Address != "192.0.2.20" || rec.applied[0].desired.Backends[0].Address != "192.0.2.21" {
+		t.Fatalf("expected one scoped apply of web, got %+v", rec.applied)
+	}
+	if rec.callCount() != 0 {
+		t.Fatalf("expected no full reconcile, got %d", rec.callCount())
+	}
+	if err := engine.SetBackendOverride(context.Background(), "web", "192.0.2.21", health.OverrideDrain, 0); err != nil {
+		t.Fatalf("expected a health target for the new backend: %v", err)
+	}
+	if err := engine.SetBackendOverride(context.Background(), "web", "192.0.2.20", health.OverrideDrain, 0); err == nil {
+		t.Fatalf("expected the old backend's health target to be gone")
+	}
+
+	if err := engine.reloadService(ctx, "nope"); err == nil {
+		t.Fatalf("expected an unknown service to be rejected")
+	}
+
+	// A shell request in the include directory adds the new service on the next tick
+	if err := config.RequestServiceReload(confDir, "dns"); err != nil {
+		t.Fatal(err)
+	}
+	engine.serviceReloadRequests(ctx)
+	if svc := findService(engine.cfg, "dns"); svc == nil {
+		t.Fatalf("expected dns to be added")
+	}
+	if last := rec.applied[len(rec.applied)-1]; last.current != nil || last.desired.Name != "dns" {
+		t.Fatalf("expected dns to be applied as a new service, got %+v", last)
+	}
+	if names, _ := config.PendingServiceReloads(confDir); len(names) != 0 {
+		t.Fatalf("expected the request to be consumed, got %v", names)
+	}
+}
+
+func TestEngine_ScheduledChanges(t *testing.T) {
+	dir := t.TempDir()
+	confDir := filepath.Join(dir, "conf.d")
+	if err := os.Mkdir(confDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		Include: "conf.d/*.yaml",
+		System:  config.SystemConfig{StateDir: t.TempDir()},
+		Node:    config.NodeConfig{Name: "node-a", Role: "primary"},
+	}
+	var out bytes.Buffer
+	logger := observability.NewLogger(observability.InfoLevel)
+	logger.SetConsoleOutput(&out)
+	clk := clock.NewFake(time.Date(2025, 1, 2, 1, 0, 0, 0, time.UTC))
+	var validateErr error
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     filepath.Join(dir, "config.yaml"),
+		Logger:         logger,
+		Network:        &fakeNetworkManager{},
+		Reconciler:     &fakeReconciler{},
+		Clock:          clk,
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return validateErr },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	ctx := context.Background()
+	if err := engine.loadAndSetConfig(true); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	web := config.Service{Name: "web", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr", Backends: []config.Backend{{Address: "192.0.2.20", Weight: 1}}}
+	schedule := func(at time.Time) {
+		t.Helper()
+		change := &config.ScheduledChange{At: at, CreatedAt: clk.Now(), CreatedBy: "ops@lb-a", Services: []config.Service{web}}
+		if err := config.ScheduleChange(confDir, change); err != nil {
+			t.Fatalf("ScheduleChange: %v", err)
+		}
+	}
+
+	// A bundle cancelled before its time is audited and never committed
+	schedule(clk.Now().Add(time.Hour))
+	if engine.scheduledChanges(ctx) {
+		t.Fatalf("expected nothing committed before the activation time")
+	}
+	if _, err := config.CancelScheduledChange(confDir); err != nil {
+		t.Fatal(err)
+	}
+	engine.scheduledChanges(ctx)
+	if !strings.Contains(out.String(), "config_change_scheduled") || !strings.Contains(out.String(), "config_change_cancelled") {
+		t.Fatalf("expected scheduled and cancelled audit events, got:\n%s", out.String())
+	}
+
+	// A bundle that no longer validates is dropped
+	validateErr = errors.New("backend overlaps")
+	schedule(clk.Now().Add(time.Minute))
+	clk.Advance(2 * time.Minute)
+	if engine.scheduledChanges(ctx) {
+		t.Fatalf("expected an invalid bundle not to be committed")
+	}
+	if change, _ := config.ReadScheduledChange(confDir); change != nil {
+		t.Fatalf("expected the failed bundle to be dropped")
+	}
+	if !strings.Contains(out.String(), "config_change_activation_failed") {
+		t.Fatalf("expected an activation failure audit event, got:\n%s", out.String())
+	}
+
+	validateErr = nil
+	out.Reset()
+	scheduledAt := clk.Now().Add(time.Hour)
+	schedule(scheduledAt)
+	engine.scheduledChanges(ctx)
+	clk.Advance(time.Hour + 5*time.Second)
+	if !engine.scheduledChanges(ctx) {
+		t.Fatalf("expected the bundle to be committed once due")
+	}
+	if _, err := os.Stat(filepath.Join(confDir, "web.yaml")); err != nil {
+		t.Fatalf("expected web.yaml committed: %v", err)
+	}
+	if gen, _ := config.ReadGeneration(confDir); gen != 2 {
+		t.Fatalf("expected generation 2 after activation, got %d", gen)
+	}
+	if change, _ := config.ReadScheduledChange(confDir); change != nil {
+		t.Fatalf("expected the bundle removed after activation")
+	}
+	var activated string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.Contains(line, "config_change_activated") {
+			activated = line
+		}
+	}
+	for _, want := range []string{scheduledAt.Format(time.RFC3339), "delay_ms=5000", "created_by=ops@lb-a", "services_changed=web"} {
+		if !strings.Contains(activated, want) {
+			t.Fatalf("activation audit missing %q: %s", want, activated)
+		}
+	}
+	if engine.scheduledChanges(ctx) || strings.Contains(out.String(), "config_change_cancelled") {
+		t.Fatalf("expected an activated bundle not to be reported as cancelled:\n%s", out.String())
+	}
+
+	// A commit that changed a service since the bundle was staged makes the
+	// bundle stale; commits to other services don't
+	base := &config.Config{Generation: 2, Services: []config.Service{web}}
+	moved := web
+	moved.Backends = []config.Backend{{Address: "192.0.2.21", Weight: 1}}
+	cfg.Generation = 4
+	cfg.Services = []config.Service{moved}
+	api := config.Service{Name: "api", Protocol: "tcp", Ports: []int{443}, Scheduler: "rr", Backends: []config.Backend{{Address: "192.0.2.30", Weight: 1}}}
+	for _, tc := range []struct {
+		change    *config.ScheduledChange
+		committed bool
+	}{
+		{&config.ScheduledChange{Services: []config.Service{web}, BaseServices: config.ServiceDigests(base, []string{"web"})}, false},
+		{&config.ScheduledChange{Services: []config.Service{api}, BaseServices: config.ServiceDigests(base, []string{"api"})}, true},
+	} {
+		out.Reset()
+		tc.change.At, tc.change.CreatedAt, tc.change.BaseGeneration = clk.Now(), clk.Now(), base.Generation
+		if err := config.ScheduleChange(confDir, tc.change); err != nil {
+			t.Fatalf("ScheduleChange: %v", err)
+		}
+		if got := engine.scheduledChanges(ctx); got != tc.committed {
+			t.Fatalf("%v: expected committed=%v, got %v:\n%s", tc.change.Touched(), tc.committed, got, out.String())
+		}
+		if !tc.committed && !strings.Contains(out.String(), "reason=stale") {
+			t.Fatalf("expected a stale activation failure audit event, got:\n%s", out.String())
+		}
+	}
+}
+
+func TestEngine_ConfigChangedAuditSummarizesDiff(t *testing.T) {
+	var out bytes.Buffer
+	logger := observability.NewLogger(observability.InfoLevel)
+	logger.SetConsoleOutput(&out)
+	cfg := &config.Config{
+		Node: config.NodeConfig{Name: "node-a"},
+		Services: []config.Service{
+			{Name: "web", Ports: []int{80}, Backends: []config.Backend{{Address: "192.0.2.20", Weight: 1}, {Address: "192.0.2.21", Weight: 1}}},
+		},
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         logger,
+		Network:        &fakeNetworkManager{},
+		Reconciler:     &fakeReconciler{},
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	if err := engine.loadAndSetConfig(true); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	cfg = &config.Config{
+		Node: cfg.Node,
+		Services: []config.Service{
+			{Name: "web", Ports: []int{80}, Backends: []config.Backend{{Address: "192.0.2.20", Weight: 1}}},
+			{Name: "api", Ports: []int{443, 8443}, Backends: []config.Backend{{Address: "192.0.2.30", Weight: 1}}},
+		},
+	}
+	if err := engine.loadAndSetConfig(false); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	var changed string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.Contains(line, "config_changed") {
+			changed = line
+		}
+	}
+	for _, want := range []string{"services_added=api", "services_modified=web", "backends_removed=1", "backends_added=1", "ports_delta=2", "globals_changed=false"} {
+		if !strings.Contains(changed, want) {
+			t.Fatalf("config_changed audit missing %q: %s", want, changed)
+		}
+	}
+	if strings.Contains(changed, "services_removed=") {
+		t.Fatalf("expected empty service lists to be left out: %s", changed)
+	}
+}
+
+type foreignReconciler struct {
+	fakeReconciler
+	policy  string
+	foreign []*ipvs.Service
+}
+
+func (r *foreignReconciler) SetCleanup(policy string) { r.policy = policy }
+func (r *foreignReconciler) Foreign() []*ipvs.Service { return r.foreign }
+
+func TestEngine_ExportsForeignServices(t *testing.T) {
+	rec := &foreignReconciler{}
+	cfg := &config.Config{
+		Node:   config.NodeConfig{Name: "lb-a"},
+		Daemon: config.DaemonConfig{Cleanup: "warn"},
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        &fakeNetworkManager{},
+		Reconciler:     rec,
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	if err := engine.loadAndSetConfig(true); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if rec.policy != "warn" {
+		t.Fatalf("expected cleanup policy warn, got %q", rec.policy)
+	}
+
+	labels := map[string]string{"vip": "192.0.2.10", "protocol": "tcp", "port": "9999"}
+	rec.foreign = []*ipvs.Service{{Address: net.ParseIP("192.0.2.10"), Protocol: "tcp", Port: 9999}}
+	engine.exportForeign(cfg)
+	if got := gaugeValue(t, engine, "lbctl_ipvs_foreign_service", labels); got != 1 {
+		t.Fatalf("expected foreign service gauge 1, got %v", got)
+	}
+
+	rec.foreign = nil
+	engine.exportForeign(cfg)
+	if got := gaugeValue(t, engine, "lbctl_ipvs_foreign_service", labels); got != 0 {
+		t.Fatalf("expected foreign service gauge 0 once gone, got %v", got)
+	}
+}
+
+type opsReconciler struct {
+	fakeReconciler
+	concurrency int
+	ops         []ipvs.OpCount
+	expand      ipvs.ExpandStats
+}
+
+func (r *opsReconciler) SetConcurrency(n int)              { r.concurrency = n }
+func (r *opsReconciler) LastOps() []ipvs.OpCount           { return r.ops }
+func (r *opsReconciler) LastExpandStats() ipvs.ExpandStats { return r.expand }
+
+func TestEngine_ExportsReconcileOps(t *testing.T) {
+	rec := &opsReconciler{}
+	cfg := &config.Config{
+		Node:   config.NodeConfig{Name: "lb-a"},
+		Daemon: config.DaemonConfig{ReconcileConcurrency: 8},
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        &fakeNetworkManager{},
+		Reconciler:     rec,
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	if err := engine.loadAndSetConfig(true); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if rec.concurrency != 8 {
+		t.Fatalf("expected concurrency 8, got %d", rec.concurrency)
+	}
+
+	rec.ops = []ipvs.OpCount{
+		{Object: "destination", Kind: ipvs.ChangeCreate, OK: 40, Failed: 2},
+		{Object: "service", Kind: ipvs.ChangeCreate, OK: 20},
+	}
+	rec.expand = ipvs.ExpandStats{Hits: 3, Misses: 1}
+	engine.exportOps(cfg)
+	engine.exportOps(cfg)
+	if got := gaugeValue(t, engine, "lbctl_reconcile_operations", nil); got != 62 {
+		t.Fatalf("expected 62 operations, got %v", got)
+	}
+	created := map[string]string{"object": "destination", "kind": "create", "result": "success"}
+	if got := gaugeValue(t, engine, "lbctl_ipvs_operations_total", created); got != 80 {
+		t.Fatalf("expected 80 destination creates, got %v", got)
+	}
+	failed := map[string]string{"object": "destination", "kind": "create", "result": "failure"}
+	if got := gaugeValue(t, engine, "lbctl_ipvs_operations_total", failed); got != 4 {
+		t.Fatalf("expected 4 failed destination creates, got %v", got)
+	}
+	if got := gaugeValue(t, engine, "lbctl_reconcile_expand_cache_total", map[string]string{"result": "hit"}); got != 6 {
+		t.Fatalf("expected 6 expansion cache hits, got %v", got)
+	}
+	if got := gaugeValue(t, engine, "lbctl_reconcile_expand_cache_total", map[string]string{"result": "miss"}); got != 2 {
+		t.Fatalf("expected 2 expansion cache misses, got %v", got)
+	}
+}
+
+type cacheReconciler struct {
+	fakeReconciler
+	stats ipvs.CacheStats
+}
+
+func (r *cacheReconciler) CacheStats() (ipvs.CacheStats, bool) { return r.stats, true }
+
+func TestEngine_ExportsCacheStats(t *testing.T) {
+	rec := &cacheReconciler{}
+	cfg := &config.Config{Node: config.NodeConfig{Name: "lb-a"}}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        &fakeNetworkManager{},
+		Reconciler:     rec,
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	rec.stats = ipvs.CacheStats{Hits: 10, Misses: 4, Entries: 7}
+	engine.exportCacheStats(cfg)
+	rec.stats = ipvs.CacheStats{Hits: 15, Misses: 5, Entries: 3}
+	engine.exportCacheStats(cfg)
+	if got := gaugeValue(t, engine, "lbctl_ipvs_cache_hits_total", nil); got != 15 {
+		t.Fatalf("expected 15 cache hits, got %v", got)
+	}
+	if got := gaugeValue(t, engine, "lbctl_ipvs_cache_misses_total", nil); got != 5 {
+		t.Fatalf("expected 5 cache misses, got %v", got)
+	}
+	if got := gaugeValue(t, engine, "lbctl_ipvs_cache_entries", nil); got != 3 {
+		t.Fatalf("expected 3 cache entries, got %v", got)
+	}
+
+	// A replaced cache counts from zero again
+	rec.stats = ipvs.CacheStats{Hits: 2, Misses: 1, Entries: 1}
+	engine.exportCacheStats(cfg)
+	if got := gaugeValue(t, engine, "lbctl_ipvs_cache_hits_total", nil); got != 17 {
+		t.Fatalf("expected 17 cache hits after reset, got %v", got)
+	}
+}
+
+type statsReconciler struct {
+	fakeReconciler
+	services     []*ipvs.Service
+	destinations map[string][]*ipvs.Destination // By service key
+	reads        int
+}
+
+func (r *statsReconciler) Services() ([]*ipvs.Service, error) {
+	return r.services, nil
+}
+
+// read returns what Stats returns, for tests that hand it to the engine
+func (r *statsReconciler) read() []ipvs.ServiceStats {
+	stats, _ := r.Stats()
+	return stats
+}
+
+func (r *statsReconciler) Stats() ([]ipvs.ServiceStats, error) {
+	r.reads++
+	var stats []ipvs.ServiceStats
+	for _, svc := range r.services {
+		stats = append(stats, ipvs.ServiceStats{Service: svc, Destinations: r.destinations[svc.Key()]})
+	}
+	return stats, nil
+}
+
+// gaugeValue returns the first series of a gauge or counter matching labels
+func gaugeValue(t *testing.T, engine *Engine, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := engine.metrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if v, ok := labels[lp.GetName()]; ok && v != lp.GetValue() {
+					continue metrics
+				}
+			}
+			if c := m.GetCounter(); c != nil {
+				return c.GetValue()
+			}
+			return m.GetGauge().GetValue()
+		}
+	}
+	t.Fatalf("%s%v not found", name, labels)
+	return 0
+}
+
+func TestServiceByAddrMultipleVIPs(t *testing.T) {
+	cfg := &config.Config{
+		Network: config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10", VIPs: []string{"192.0.2.11"}}},
+		Services: []config.Service{
+			{Name: "web", Protocol: "tcp", Ports: []int{80}},
+			{Name: "api", VIP: "192.0.2.11", Protocol: "tcp", Ports: []int{80}},
+		},
+	}
+	byAddr := serviceByAddr(cfg)
+	for vip, want := range map[string]string{"192.0.2.10": "web", "192.0.2.11": "api"} {
+		if got := byAddr[serviceAddr(net.ParseIP(vip), "tcp", 80)]; got != want {
+			t.Errorf("%s: expected %s, got %q", vip, want, got)
+		}
+	}
+	if _, ok := byAddr[serviceAddr(net.ParseIP("192.0.2.12"), "tcp", 80)]; ok {
+		t.Error("expected no service on an unconfigured VIP")
+	}
+}
+
+func TestEngine_TrafficRatesAndQuotas(t *testing.T) {
+	vip := net.ParseIP("192.0.2.10")
+	rec := &statsReconciler{services: []*ipvs.Service{
+		{Address: vip, Protocol: "tcp", Port: 80},
+		{Address: vip, Protocol: "tcp", Port: 443},
+	}}
+	clk := clock.NewFake(time.Unix(1000, 0))
+	cfg := &config.Config{
+		Node:    config.NodeConfig{Name: "lb-a"},
+		Network: config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+		Services: []config.Service{{
+			Name: "web", Protocol: "tcp", Ports: []int{80, 443},
+			Quota: config.ServiceQuota{ConnectionsPerSecond: 50},
+		}},
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        &fakeNetworkManager{},
+		Reconciler:     rec,
+		Clock:          clk,
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	engine.pollTraffic(cfg, rec.read()) // First poll only records the counters
+
+	// Both ports count towards the service
+	rec.services[0].Stats = ipvs.Stats{Connections: 200, BytesIn: 10000, BytesOut: 40000}
+	rec.services[1].Stats = ipvs.Stats{Connections: 100, BytesIn: 10000}
+	clk.Advance(10 * time.Second)
+	engine.pollTraffic(cfg, rec.read())
+
+	if got := gaugeValue(t, engine, "lbctl_service_bytes_per_second", map[string]string{"service": "web", "direction": "in"}); got != 2000 {
+		t.Errorf("expected 2000 B/s in, got %v", got)
+	}
+	if got := gaugeValue(t, engine, "lbctl_service_bytes_per_second", map[string]string{"service": "web", "direction": "out"}); got != 4000 {
+		t.Errorf("expected 4000 B/s out, got %v", got)
+	}
+	if got := gaugeValue(t, engine, "lbctl_service_connections_per_second", map[string]string{"service": "web"}); got != 30 {
+		t.Errorf("expected 30 conn/s, got %v", got)
+	}
+	if got := gaugeValue(t, engine, "lbctl_service_quota_exceeded", map[string]string{"service": "web", "quota": "connections_per_second"}); got != 0 {
+		t.Errorf("expected quota not exceeded, got %v", got)
+	}
+
+	rec.services[0].Stats.Connections += 1000
+	clk.Advance(10 * time.Second)
+	engine.pollTraffic(cfg, rec.read())
+	if got := gaugeValue(t, engine, "lbctl_service_quota_exceeded", map[string]string{"service": "web", "quota": "connections_per_second"}); got != 1 {
+		t.Errorf("expected quota exceeded at 100 conn/s, got %v", got)
+	}
+	if !engine.quotaExceeded["web/connections_per_second"] {
+		t.Error("expected exceeded quota to be tracked")
+	}
+
+	// A counter reset skips the poll instead of reporting a negative rate
+	rec.services[0].Stats = ipvs.Stats{}
+	clk.Advance(10 * time.Second)
+	engine.pollTraffic(cfg, rec.read())
+	if got := gaugeValue(t, engine, "lbctl_service_quota_exceeded", map[string]string{"service": "web", "quota": "connections_per_second"}); got != 1 {
+		t.Errorf("expected quota state unchanged across a counter reset, got %v", got)
+	}
+
+	clk.Advance(10 * time.Second)
+	engine.pollTraffic(cfg, rec.read())
+	if got := gaugeValue(t, engine, "lbctl_service_quota_exceeded", map[string]string{"service": "web", "quota": "connections_per_second"}); got != 0 {
+		t.Errorf("expected quota cleared, got %v", got)
+	}
+	if len(engine.quotaExceeded) != 0 {
+		t.Errorf("expected no exceeded quotas, got %v", engine.quotaExceeded)
+	}
+
+	// A service gone from IPVS loses its series
+	rec.services = nil
+	clk.Advance(10 * time.Second)
+	engine.pollTraffic(cfg, rec.read())
+	for _, name := range []string{"lbctl_service_bytes_per_second", "lbctl_service_connections_per_second", "lbctl_service_quota_exceeded"} {
+		if n := engine.metrics.SeriesCount(name); n != 0 {
+			t.Errorf("expected %s removed with the service, got %d series", name, n)
+		}
+	}
+
+	// A tick reads IPVS once for the rates and the lbctl_ipvs_* gauges
+	if err := engine.loadAndSetConfig(true); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	engine.mu.Lock()
+	engine.active = true
+	engine.mu.Unlock()
+	rec.reads = 0
+	engine.reconcileTick(context.Background(), cfg)
+	if rec.reads != 1 {
+		t.Errorf("expected one IPVS stats read per tick, got %d", rec.reads)
+	}
+}
+
+func TestEngine_CollectIPVSStats(t *testing.T) {
+	vip := net.ParseIP("192.0.2.10")
+	rec := &statsReconciler{
+		services: []*ipvs.Service{
+			{Address: vip, Protocol: "tcp", Port: 443, Stats: ipvs.Stats{BytesIn: 500, BPSOut: 64}},
+			{Address: net.ParseIP("192.0.2.99"), Protocol: "tcp", Port: 443}, // Not ours
+		},
+		destinations: map[string][]*ipvs.Destination{
+			"tcp:192.0.2.10:443": {
+				{Address: net.ParseIP("10.0.0.1"), Port: 443, ActiveConns: 3, InactiveConns: 1},
+				{Address: net.ParseIP("10.0.0.2"), Port: 443, ActiveConns: 2, Stats: ipvs.Stats{CPS: 5}},
+			},
+		},
+	}
+	clk := clock.NewFake(time.Unix(1000, 0))
+	cfg := &config.Config{
+		Node:          config.NodeConfig{Name: "lb-a"},
+		Network:       config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+		Observability: config.ObsConfig{Metrics: config.MetricsConfig{IPVSStatsIntervalSeconds: 30}},
+		Services:      []config.Service{{Name: "web", Protocol: "tcp", Ports: []int{443}}},
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        &fakeNetworkManager{},
+		Reconciler:     rec,
+		Clock:          clk,
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	engine.collectIPVSStats(cfg, rec.read())
+	svc := map[string]string{"service": "web", "port": "443"}
+	if got := gaugeValue(t, engine, "lbctl_ipvs_service_connections_active", svc); got != 5 {
+		t.Errorf("expected 5 active connections, got %v", got)
+	}
+	if got := gaugeValue(t, engine, "lbctl_ipvs_service_connections_inactive", svc); got != 1 {
+		t.Errorf("expected 1 inactive connection, got %v", got)
+	}
+	if got := gaugeValue(t, engine, "lbctl_ipvs_service_bytes", map[string]string{"service": "web", "direction": "in"}); got != 500 {
+		t.Errorf("expected 500 bytes in, got %v", got)
+	}
+	if got := gaugeValue(t, engine, "lbctl_ipvs_service_bytes_per_second", map[string]string{"service": "web", "direction": "out"}); got != 64 {
+		t.Errorf("expected 64 B/s out, got %v", got)
+	}
+	if got := gaugeValue(t, engine, "lbctl_ipvs_destination_connections_per_second", map[string]string{"backend": "10.0.0.2:443"}); got != 5 {
+		t.Errorf("expected 5 conn/s on 10.0.0.2, got %v", got)
+	}
+	if n := engine.metrics.SeriesCount("lbctl_ipvs_service_connections_active"); n != 1 {
+		t.Errorf("expected only the VIP's service to be exported, got %d series", n)
+	}
+
+	// Refreshed only once the interval has passed
+	rec.destinations["tcp:192.0.2.10:443"][0].ActiveConns = 10
+	clk.Advance(10 * time.Second)
+	engine.collectIPVSStats(cfg, rec.read())
+	if got := gaugeValue(t, engine, "lbctl_ipvs_service_connections_active", svc); got != 5 {
+		t.Errorf("expected stats unchanged within the interval, got %v", got)
+	}
+	clk.Advance(20 * time.Second)
+	engine.collectIPVSStats(cfg, rec.read())
+	if got := gaugeValue(t, engine, "lbctl_ipvs_service_connections_active", svc); got != 12 {
+		t.Errorf("expected 12 active connections after the interval, got %v", got)
+	}
+
+	// A removed destination loses its series
+	rec.destinations["tcp:192.0.2.10:443"] = rec.destinations["tcp:192.0.2.10:443"][:1]
+	clk.Advance(30 * time.Second)
+	engine.collectIPVSStats(cfg, rec.read())
+	if n := engine.metrics.SeriesCount("lbctl_ipvs_destination_connections_active"); n != 1 {
+		t.Errorf("expected only the remaining destination's series, got %d", n)
+	}
+	if n := engine.metrics.SeriesCount("lbctl_ipvs_destination_bytes"); n != 2 {
+		t.Errorf("expected the remaining destination's in and out series, got %d", n)
+	}
+}
+
+func TestEngine_CollectIPVSStatsTCPAndUDP(t *testing.T) {
+	vip := net.ParseIP("192.0.2.10")
+	rec := &statsReconciler{
+		services: []*ipvs.Service{
+			{Address: vip, Protocol: "tcp", Port: 53, Stats: ipvs.Stats{BytesIn: 100}},
+			{Address: vip, Protocol: "udp", Port: 53, Stats: ipvs.Stats{BytesIn: 400}},
+		},
+		destinations: map[string][]*ipvs.Destination{
+			"tcp:192.0.2.10:53": {{Address: net.ParseIP("10.0.0.1"), Port: 53, ActiveConns: 1}},
+			"udp:192.0.2.10:53": {{Address: net.ParseIP("10.0.0.1"), Port: 53, InactiveConns: 7}},
+		},
+	}
+	cfg := &config.Config{
+		Node:     config.NodeConfig{Name: "lb-a"},
+		Network:  config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+		Services: []config.Service{{Name: "dns", Protocol: "tcp+udp", Ports: []int{53}}},
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        &fakeNetworkManager{},
+		Reconciler:     rec,
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	engine.collectIPVSStats(cfg, rec.read())
+	if got := gaugeValue(t, engine, "lbctl_ipvs_service_bytes", map[string]string{"service": "dns", "direction": "in"}); got != 500 {
+		t.Errorf("expected both protocols' 500 bytes in, got %v", got)
+	}
+	if got := gaugeValue(t, engine, "lbctl_ipvs_destination_connections_inactive", map[string]string{"backend": "10.0.0.1:53"}); got != 7 {
+		t.Errorf("expected 7 inactive connections on 10.0.0.1, got %v", got)
+	}
+	if got := gaugeValue(t, engine, "lbctl_ipvs_service_connections_active", map[string]string{"service": "dns"}); got != 1 {
+		t.Errorf("expected 1 active connection, got %v", got)
+	}
+}
+
+type drainingReconciler struct {
+	fakeReconciler
+	drain    ipvs.DrainConfig
+	draining int
+}
+
+func (r *drainingReconciler) SetDrain(cfg ipvs.DrainConfig) { r.drain = cfg }
+func (r *drainingReconciler) Draining() int                 { return r.draining }
+
+func TestEngine_KeepsReconcilingWhileDraining(t *testing.T) {
+	rec := &drainingReconciler{draining: 2}
+	cfg := &config.Config{
+		Node:     config.NodeConfig{Name: "lb-a"},
+		Network:  config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+		Daemon:   config.DaemonConfig{Drain: config.DrainConfig{Enabled: true, Threshold: 3}},
+		Services: []config.Service{{Name: "web", Protocol: "tcp", Ports: []int{80}}},
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        &fakeNetworkManager{},
+		Reconciler:     rec,
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	if err := engine.loadAndSetConfig(true); err != nil {
+		t.Fatalf("loadAndSetConfig: %v", err)
+	}
+	if !rec.drain.Enabled || rec.drain.Threshold != 3 || rec.drain.Timeout != ipvs.DefaultDrainTimeout {
+		t.Fatalf("unexpected drain config: %+v", rec.drain)
+	}
+
+	engine.mu.Lock()
+	engine.active = true
+	engine.reconcileQ.request(reconcileReload)
+	engine.mu.Unlock()
+
+	engine.tryReconcile(context.Background())
+	if engine.reconcileQ.pending != reconcileDrain {
+		t.Fatal("expected another reconcile while destinations drain")
+	}
+	if got := gaugeValue(t, engine, "lbctl_ipvs_draining_destinations", nil); got != 2 {
+		t.Errorf("expected 2 draining destinations, got %v", got)
+	}
+
+	rec.draining = 0
+	engine.tryReconcile(context.Background())
+	if engine.reconcileQ.pending != reconcileNone {
+		t.Fatal("expected reconciling to stop once draining finished")
+	}
+	if rec.callCount() != 2 {
+		t.Fatalf("expected 2 applies, got %d", rec.callCount())
+	}
+}
+
+func TestEngine_ShutdownPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy  string
+		applies []applyCall
+	}{
+		{"", nil},
+		{"preserve", nil},
+		{"teardown", []applyCall{{serviceCount: 0}}},
+		{"DRAIN", []applyCall{{serviceCount: 1, weights: []int{0}}, {serviceCount: 0}}},
+	} {
+		rec := &statsReconciler{
+			services: []*ipvs.Service{{Address: net.ParseIP("192.0.2.10"), Protocol: "tcp", Port: 80}},
+			destinations: map[string][]*ipvs.Destination{
+				"tcp:192.0.2.10:80": {{Address: net.ParseIP("10.0.0.1"), Port: 80, ActiveConns: 4}},
+			},
+		}
+		var out bytes.Buffer
+		logger := observability.NewLogger(observability.InfoLevel)
+		logger.SetConsoleOutput(&out)
+		clk := clock.NewFake(time.Unix(1000, 0))
+		engine, err := NewEngine(EngineOptions{
+			ConfigPath: "ignored",
+			Logger:     logger,
+			Network:    &fakeNetworkManager{},
+			Reconciler: rec,
+			Clock:      clk,
+		})
+		if err != nil {
+			t.Fatalf("NewEngine: %v", err)
+		}
+		engine.cfg = &config.Config{
+			Node:    config.NodeConfig{Name: "node-a"},
+			Network: config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+			Daemon:  config.DaemonConfig{ShutdownPolicy: tc.policy, ShutdownDrainTimeoutMS: 5000},
+			Services: []config.Service{{Name: "web", Protocol: "tcp", Ports: []int{80}, Backends: []config.Backend{
+				{Address: "10.0.0.1", Weight: 5},
+			}}},
+		}
+		engine.active = true
+
+		engine.shutdown()
+		if len(rec.calls) != len(tc.applies) {
+			t.Fatalf("%q: expected %d applies, got %+v", tc.policy, len(tc.applies), rec.calls)
+		}
+		for i, want := range tc.applies {
+			if got := rec.calls[i]; got.serviceCount != want.serviceCount || !slices.Equal(got.weights, want.weights) {
+				t.Errorf("%q: apply %d: expected %+v, got %+v", tc.policy, i, want, got)
+			}
+		}
+		if !strings.Contains(out.String(), "shutdown") {
+			t.Errorf("%q: expected a shutdown audit event:\n%s", tc.policy, out.String())
+		}
+		if tc.policy == "DRAIN" {
+			// The connections never finished, so the drain waited out its timeout
+			if !strings.Contains(out.String(), "active_connections=4") || clk.Now().Sub(time.Unix(1000, 0)) != 5*time.Second {
+				t.Errorf("expected the drain to time out with 4 connections left after 5s:\n%s", out.String())
+			}
+		}
+	}
+}
+
+// handoffPreempter releases the VIP once the priority drops, checking the
+// weights were still in place until then
+type handoffPreempter struct {
+	recordingPreempter
+	net   *fakeNetworkManager
+	rec   *statsReconciler
+	early []applyCall // Applies made before the handoff
+}
+
+func (p *handoffPreempter) SetPriority(cfg *config.Config, priority int) error {
+	if priority == maintenancePriority {
+		p.early = append(p.early, p.rec.calls...)
+		p.net.setPresent(false)
+	}
+	return p.recordingPreempter.SetPriority(cfg, priority)
+}
+
+func TestEngine_ShutdownDrainHandsOffVIP(t *testing.T) {
+	rec := &statsReconciler{
+		services: []*ipvs.Service{{Address: net.ParseIP("192.0.2.10"), Protocol: "tcp", Port: 80}},
+		destinations: map[string][]*ipvs.Destination{
+			"tcp:192.0.2.10:80": {{Address: net.ParseIP("10.0.0.1"), Port: 80}},
+		},
+	}
+	network := &fakeNetworkManager{}
+	network.setPresent(true)
+	pre := &handoffPreempter{net: network, rec: rec}
+	var out bytes.Buffer
+	logger := observability.NewLogger(observability.InfoLevel)
+	logger.SetConsoleOutput(&out)
+	stateDir := t.TempDir()
+	cfg := &config.Config{
+		Node:     config.NodeConfig{Name: "node-a", Role: "primary"},
+		Network:  config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+		VRRP:     config.VRRPConfig{VRID: 1, PriorityPrimary: 150, PrioritySecondary: 100},
+		System:   config.SystemConfig{StateDir: stateDir},
+		Daemon:   config.DaemonConfig{ShutdownPolicy: "drain", ShutdownDrainTimeoutMS: 5000},
+		Services: []config.Service{{Name: "web", Protocol: "tcp", Ports: []int{80}, Backends: []config.Backend{{Address: "10.0.0.1", Weight: 5}}}},
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath: "ignored",
+		Logger:     logger,
+		Network:    network,
+		Reconciler: rec,
+		Preempter:  pre,
+		Clock:      clock.NewFake(time.Unix(1000, 0)),
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	engine.stateDir = stateDir
+	engine.cfg = cfg
+	engine.active = true
+
+	engine.shutdown()
+	if len(pre.early) != 0 {
+		t.Fatalf("expected no IPVS changes before the VIP was handed off, got %+v", pre.early)
+	}
+	if want := []applyCall{{serviceCount: 1, weights: []int{0}}, {serviceCount: 0}}; len(rec.calls) != 2 ||
+		!slices.Equal(rec.calls[0].weights, want[0].weights) || rec.calls[1].serviceCount != 0 {
+		t.Fatalf("expected weights zeroed, then services removed, got %+v", rec.calls)
+	}
+	if !strings.Contains(out.String(), "vip_handed_off=true") {
+		t.Errorf("expected the shutdown event to report the handoff:\n%s", out.String())
+	}
+	if status, err := ReadMaintenance(stateDir); err != nil || !status.ShutdownHandoff || status.Enabled {
+		t.Fatalf("expected the handoff recorded, got %+v, %v", status, err)
+	}
+
+	// The next start restores the configured priority
+	engine.restoreMaintenance(cfg)
+	if want := []int{maintenancePriority, 150}; !slices.Equal(pre.priorities, want) {
+		t.Fatalf("VRRP priorities = %v, want %v", pre.priorities, want)
+	}
+	if status, err := ReadMaintenance(stateDir); err != nil || status.ShutdownHandoff {
+		t.Fatalf("expected the handoff cleared, got %+v, %v", status, err)
+	}
+}
+
+func TestEngine_RunOnce(t *testing.T) {
+	cfg := &config.Config{
+		Node:    config.NodeConfig{Name: "node-a"},
+		Network: config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+		System:  config.SystemConfig{StateDir: t.TempDir()},
+		Services: []config.Service{{Name: "web", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr", Backends: []config.Backend{
+			{Address: "10.0.0.1", Weight: 3},
+			{Address: "10.0.0.2", Weight: 5},
+		}}},
+	}
+	logger := observability.NewLogger(observability.ErrorLevel)
+	newEngine := func(rec IPVSReconciler, present bool, validate func(*config.Config) error) *Engine {
+		t.Helper()
+		net := &fakeNetworkManager{}
+		net.setPresent(present)
+		engine, err := NewEngine(EngineOptions{
+			ConfigPath:     "ignored",
+			Logger:         logger,
+			Network:        net,
+			Reconciler:     rec,
+			LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+			ValidateConfig: validate,
+		})
+		if err != nil {
+			t.Fatalf("NewEngine: %v", err)
+		}
+		return engine
+	}
+	valid := func(*config.Config) error { return nil }
+
+	// Active: every backend at its configured weight, with no health checks
+	rec := &fakeReconciler{}
+	res, err := newEngine(rec, true, valid).RunOnce(context.Background(), OnceOptions{})
+	if err != nil || !res.Active || res.Services != 1 || res.Backends != 2 || res.ConfigHash == "" {
+		t.Fatalf("unexpected result %+v, %v", res, err)
+	}
+	if c, _ := rec.lastCall(); rec.callCount() != 1 || !slices.Equal(c.weights, []int{3, 5}) {
+		t.Fatalf("expected one apply at the configured weights, got %+v", rec.calls)
+	}
+	if code := OnceExitCode(res, err); code != OnceOK {
+		t.Errorf("expected exit code %d, got %d", OnceOK, code)
+	}
+
+	// Standby: removes the services
+	rec = &fakeReconciler{}
+	res, err = newEngine(rec, false, valid).RunOnce(context.Background(), OnceOptions{})
+	if c, _ := rec.lastCall(); err != nil || res.Active || rec.callCount() != 1 || c.serviceCount != 0 {
+		t.Fatalf("expected a standby to program no services, got %+v, %v", rec.calls, err)
+	}
+
+	// Dry run: plans against IPVS and changes nothing
+	mgr := ipvs.NewSimManager(logger)
+	ipvsRec := ipvs.NewReconciler(mgr, logger)
+	res, err = newEngine(ipvsRec, true, valid).RunOnce(context.Background(), OnceOptions{DryRun: true})
+	if err != nil || res.Plan == nil || res.Operations != 3 {
+		t.Fatalf("expected a plan of 3 creates, got %+v, %v", res, err)
+	}
+	if svcs, _ := mgr.GetServices(); len(svcs) != 0 {
+		t.Fatalf("expected a dry run to change nothing, got %d services", len(svcs))
+	}
+	if code := OnceExitCode(res, err); code != OnceChanges {
+		t.Errorf("expected exit code %d for pending changes, got %d", OnceChanges, code)
+	}
+	var out bytes.Buffer
+	res.WriteSummary(&out)
+	if !strings.Contains(out.String(), "+ service") || !strings.Contains(out.String(), "3 planned") {
+		t.Errorf("unexpected summary:\n%s", out.String())
+	}
+
+	// Applied, a second dry run finds nothing to do
+	if _, err := newEngine(ipvsRec, true, valid).RunOnce(context.Background(), OnceOptions{}); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	res, err = newEngine(ipvsRec, true, valid).RunOnce(context.Background(), OnceOptions{DryRun: true})
+	if code := OnceExitCode(res, err); code != OnceOK {
+		t.Errorf("expected exit code %d once converged, got %d (%+v)", OnceOK, code, res.Plan)
+	}
+
+	// Invalid config
+	rec = &fakeReconciler{}
+	res, err = newEngine(rec, true, func(*config.Config) error { return errors.New("bad") }).RunOnce(context.Background(), OnceOptions{})
+	if code := OnceExitCode(res, err); code != OnceInvalidConfig || rec.callCount() != 0 {
+		t.Errorf("expected exit code %d and no apply for an invalid config, got %d", OnceInvalidConfig, code)
+	}
+}
+
+func TestEngine_ExportsLifecycleState(t *testing.T) {
+	net := &fakeNetworkManager{}
+	rec := &failingReconciler{}
+	clk := clock.NewFake(time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC))
+	cfg := &config.Config{
+		Node:     config.NodeConfig{Name: "lb-a"},
+		Network:  config.NetworkConfig{Frontend: config.InterfaceConfig{VIP: "192.0.2.10"}},
+		Services: []config.Service{{Name: "web", Protocol: "tcp", Ports: []int{80}}},
+	}
+	engine, err := NewEngine(EngineOptions{
+		ConfigPath:     "ignored",
+		Logger:         observability.NewLogger(observability.ErrorLevel),
+		Network:        net,
+		Reconciler:     rec,
+		Clock:          clk,
+		LoadConfig:     func(string) (*config.Config, error) { return cfg, nil },
+		ValidateConfig: func(*config.Config) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	if err := engine.loadAndSetConfig(true); err != nil {
+		t.Fatalf("loadAndSetConfig: %v", err)
+	}
+	engine.stateDir = t.TempDir()
+	ctx := context.Background()
+
+	expect := func(want EngineState) {
+		t.Helper()
+		for _, s := range engineStates {
+			wantVal := 0.0
+			if s == want {
+				wantVal = 1
+			}
+			if got := gaugeValue(t, engine, "lbctl_engine_state", map[string]string{"state": string(s)}); got != wantVal {
+				t.Fatalf("expected lbctl_engine_state{state=%q} %v, got %v", s, wantVal, got)
+			}
+		}
+		status, err := ReadEngineStatus(engine.stateDir)
+		if err != nil || status == nil || status.State != want || !status.Since.Equal(clk.Now()) {
+			t.Fatalf("expected %s recorded at %s, got %+v (%v)", want, clk.Now(), status, err)
+		}
+	}
+
+	if err := engine.initialVIPSync(ctx); err != nil {
+		t.Fatalf("initialVIPSync: %v", err)
+	}
+	engine.exportEngineState(cfg)
+	expect(StateStandby)
+
+	// Acquiring the VIP with a failing reconcile backs off
+	net.setPresent(true)
+	rec.setFail(true)
+	clk.Advance(time.Second)
+	engine.onVIPAcquired(ctx, cfg)
+	expect(StateDegradedBackoff)
+
+	rec.setFail(false)
+	clk.Advance(time.Minute)
+	engine.onVIPTick(ctx)
+	expect(StateActive)
+
+	// Standby only once the managed services are removed
+	net.setPresent(false)
+	rec.setFail(true)
+	clk.Advance(time.Second)
+	engine.onVIPTick(ctx)
+	expect(StateDraining)
+
+	rec.setFail(false)
+	clk.Advance(time.Second)
+	engine.onVIPTick(ctx)
+	expect(StateStandby)
+
+	// Activating until the first reconcile after acquiring the VIP
+	engine.mu.Lock()
+	engine.active = true
+	engine.reconcileQ.replace(reconcileReload)
+	if got := engine.stateLocked(); got != StateActivating {
+		t.Errorf("expected %s before the first reconcile, got %s", StateActivating, got)
+	}
+	engine.mu.Unlock()
+}
+
+func TestCheckSinks(t *testing.T) {
+	if got := CheckSinks(context.Background(), &config.Config{}); len(got) != 0 {
+		t.Fatalf("expected no checks without sinks, got %+v", got)
+	}
+
+	// Nothing listens on the GELF port; the Prometheus port is free to bind
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	gelfPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	promPort := free.Addr().(*net.TCPAddr).Port
+	free.Close()
+
+	cfg := &config.Config{}
+	cfg.Observability.Logging.GELF = config.GELFLogConfig{Enabled: true, Host: "127.0.0.1", Port: gelfPort, Protocol: "tcp", Facility: "lbctl"}
+	cfg.Observability.Metrics.Prometheus = config.PromConfig{Enabled: true, Port: promPort, Bind: "127.0.0.1"}
+	results := CheckSinks(context.Background(), cfg)
+	if len(results) != 2 || results[0].Sink != "gelf" || results[1].Sink != "prometheus" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if results[0].Err == nil {
+		t.Error("expected the GELF check to fail")
+	}
+	if results[1].Err != nil {
+		t.Errorf("expected the Prometheus check to pass: %v", results[1].Err)
+	}
+}
+
+func TestReconcileQueue(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var q reconcileQueue
+
+	if _, ok := q.take(false, now); ok {
+		t.Fatal("expected nothing to run on an empty queue")
+	}
+
+	// Requests coalesce into the highest reason
+	q.request(reconcileWeight)
+	q.request(reconcileReload)
+	q.request(reconcileWeight)
+	if q.pending != reconcileReload || q.depth != 3 {
+		t.Fatalf("expected 3 requests coalesced into a reload, got %s depth %d", q.pending, q.depth)
+	}
+	if _, ok := q.take(true, now); ok {
+		t.Fatal("expected a reconcile not to run as a disable")
+	}
+	reason, ok := q.take(false, now)
+	if !ok || reason != reconcileReload || q.depth != 0 {
+		t.Fatalf("expected the reload to run, got %s %v depth %d", reason, ok, q.depth)
+	}
+
+	// A request made while the write runs waits for the next run
+	q.request(reconcileWeight)
+	q.failed(now.Add(time.Second))
+	if q.pending != reconcileReload || q.attempts != 1 {
+		t.Fatalf("expected the failed reload requeued, got %s attempts %d", q.pending, q.attempts)
+	}
+	if _, ok := q.take(false, now); ok {
+		t.Fatal("expected the retry to wait out the backoff")
+	}
+	if reason, ok := q.take(false, now.Add(2*time.Second)); !ok || reason != reconcileReload {
+		t.Fatalf("expected the reload retried after the backoff, got %s %v", reason, ok)
+	}
+	q.succeeded()
+	if q.pending != reconcileNone || q.attempts != 0 || !q.nextRetry.IsZero() {
+		t.Fatalf("expected a clean queue after success, got %+v", q)
+	}
+
+	// Disable outranks reconciles, and a VIP role change drops what was pending
+	q.request(reconcileWeight)
+	q.request(reconcileDisable)
+	q.request(reconcileReload)
+	if q.pending != reconcileDisable {
+		t.Fatalf("expected disable to win, got %s", q.pending)
+	}
+	q.replace(reconcileReload)
+	if q.pending != reconcileReload || q.depth != 1 {
+		t.Fatalf("expected only the reload pending after acquiring the VIP, got %s depth %d", q.pending, q.depth)
+	}
+
+	// A permanent failure isn't retried
+	q.take(false, now)
+	q.abandoned()
+	if q.pending != reconcileNone || q.attempts != 0 || !q.stalled {
+		t.Fatalf("expected nothing pending and the queue stalled after an abandoned run, got %+v", q)
+	}
+	q.request(reconcileReload)
+	q.take(false, now)
+	q.succeeded()
+	if q.stalled {
+		t.Fatal("expected a success to clear the stall")
+	}
+}
+
+// stuckReconciler stops applying after its first few reconciles
+type stuckReconciler struct {
+	*ipvs.Reconciler
+	applies int
+}
+
+func (r *stuckReconciler) Apply(desired []config.Service, vips []string) error {
+	r.applies++
+	if r.applies > 3 {
+		return nil
+	}
+	return r.Reconciler.Apply(desired, vips)
+}
+
+func TestSoak(t *testing.T) {
+	logger := observability.NewLogger(observability.ErrorLevel)
+	newReconciler := func() (*ipvs.Reconciler, *ipvs.SimManager) {
+		m := ipvs.NewSimManager(logger)
+		return ipvs.NewReconciler(m, logger), m
+	}
+
+	rec, m := newReconciler()
+	res, err := Soak(context.Background(), rec, SoakOptions{
+		Duration:    200 * time.Millisecond,
+		Interval:    time.Millisecond,
+		Seed:        42,
+		MaxServices: 4,
+		MaxBackends: 3,
+		Logger:      logger,
+	})
+	if err != nil {
+		t.Fatalf("Soak: %v", err)
+	}
+	if res.Iterations < 10 || res.Seed != 42 || res.Violation != "" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if res.Mutations["add_service"] == 0 || res.Mutations["reweight_backend"] == 0 {
+		t.Errorf("expected varied churn, got %v", res.Mutations)
+	}
+	if services, _ := m.GetServices(); len(services) != 0 {
+		t.Errorf("expected the scratch VIP to be torn down, got %d services", len(services))
+	}
+
+	// A reconciler that stops converging is caught as drift
+	base, _ := newReconciler()
+	res, err = Soak(context.Background(), &stuckReconciler{Reconciler: base}, SoakOptions{
+		Interval: time.Millisecond,
+		Seed:     42,
+		Logger:   logger,
+	})
+	if err == nil || !strings.Contains(err.Error(), "drift") || res.Iterations != 4 {
+		t.Fatalf("expected drift at iteration 4, got %v after %d iterations", err, res.Iterations)
 	}
 }
@@ -5,47 +5,91 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/prometheus/client_golang/prometheus"
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
 	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
 	"github.com/malindarathnayake/LibraFlux/internal/health"
+	"github.com/malindarathnayake/LibraFlux/internal/ipvs"
 	"github.com/malindarathnayake/LibraFlux/internal/observability"
+	"github.com/malindarathnayake/LibraFlux/internal/resolver"
+	"github.com/malindarathnayake/LibraFlux/internal/routine"
 	"github.com/malindarathnayake/LibraFlux/internal/system"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+// Health checks are bounded by short timeouts, so buckets span 1ms to 5s
+var healthCheckBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+// Buckets for lbctl_failover_programming_seconds: sub-second with a warm
+// standby, up to minutes when reconciles fail and back off
+var failoverBuckets = []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120}
+
 type IPVSReconciler interface {
-	Apply(desired []config.Service, vip string) error
+	Apply(desired []config.Service, vips []string) error
+}
+
+type Ticker = clock.Ticker
+
+// modeSetter is implemented by reconcilers that program a forwarding method
+// (dr, nat or tun) per destination.
+type modeSetter interface {
+	SetMode(mode string)
 }
 
-type Ticker interface {
-	C() <-chan time.Time
-	Stop()
+// resolverSetter is implemented by reconcilers that resolve hostname
+// backends.
+type resolverSetter interface {
+	SetResolver(r ipvs.Resolver)
 }
 
-type realTicker struct{ t *time.Ticker }
+// snapshotter is implemented by reconcilers that can read back the IPVS
+// table they program.
+type snapshotter interface {
+	Snapshot() (*ipvs.Snapshot, error)
+}
 
-func (rt realTicker) C() <-chan time.Time { return rt.t.C }
-func (rt realTicker) Stop()               { rt.t.Stop() }
+// drainer is implemented by reconcilers that drain removed destinations
+// before deleting them. Draining destinations are only deleted by a later
+// Apply, so the engine keeps reconciling while any remain.
+type drainer interface {
+	SetDrain(cfg ipvs.DrainConfig)
+	Draining() int
+}
 
 type EngineOptions struct {
-	ConfigPath string
+	ConfigPath string        // Main config file; required unless Source is set
+	Source     config.Source // Where the config comes from; default a config.FileSource for ConfigPath
 
 	Logger  *observability.Logger
 	Auditor *observability.Auditor
 	Metrics *observability.MetricsRegistry
 
-	Network    system.NetworkManager
-	Reconciler IPVSReconciler
+	Network       system.NetworkManager
+	Reconciler    IPVSReconciler
+	Preempter     system.VRRPPreempter     // Optional; used when vrrp.preempt_after_ready is set
+	VRRPState     system.VRRPStateReader   // Used when vrrp.state_source is frr; default system.NewVtyshStateReader
+	Masquerade    system.MasqueradeManager // Optional; keeps NAT-mode MASQUERADE rules in sync
+	Notifier      system.Notifier          // Service manager notifications; default system.NewSystemdNotifier
+	ConfigWatcher system.FileWatcher       // Reports config edits for daemon.auto_reload; default system.InotifyWatcher
+
+	ReadConnections func() ([]ipvs.Connection, error) // Connection table for logging.connections; default ipvs.ReadConnections
 
 	ReloadCh <-chan struct{}
 
 	VIPCheckInterval time.Duration
-	NewTicker        func(d time.Duration) Ticker
+	Clock            clock.Clock                  // Defaults to the real clock
+	NewTicker        func(d time.Duration) Ticker // Defaults to Clock.NewTicker
 
-	LoadConfig     func(path string) (*config.Config, error)
+	LoadConfig     func(path string) (*config.Config, error) // Shorthand for a Source loading ConfigPath
 	ValidateConfig func(cfg *config.Config) error
 
 	Checker      health.Checker
@@ -53,42 +97,96 @@ type EngineOptions struct {
 }
 
 type Engine struct {
-	configPath string
+	configPath string // Main config file for includes and auto reload; may be "" with a Source. Written by Run under mu
+	source     config.Source
+	newSource  func(path string) config.Source // Source for another config file; nil unless the source reads configPath
 
 	logger  *observability.Logger
 	auditor *observability.Auditor
 	metrics *observability.MetricsRegistry
 
-	network    system.NetworkManager
-	reconciler IPVSReconciler
+	network       system.NetworkManager
+	reconciler    IPVSReconciler
+	preempter     system.VRRPPreempter
+	vrrpState     system.VRRPStateReader
+	configWatcher system.FileWatcher
+	masquerade    system.MasqueradeManager
+	notifier      system.Notifier
 
 	reloadCh <-chan struct{}
 
 	vipCheckInterval time.Duration
+	clock            clock.Clock
 	newTicker        func(d time.Duration) Ticker
+	jitter           func(max time.Duration) time.Duration
 
-	loadConfig     func(path string) (*config.Config, error)
 	validateConfig func(cfg *config.Config) error
 
 	checker      health.Checker
 	newScheduler func(checker health.Checker, observer health.Observer) *health.Scheduler
-
-	mu                 sync.Mutex
-	cfg                *config.Config
-	cfgHash            string
-	active             bool
-	pendingReconcile   bool
-	pendingDisable     bool
-	backendWeights     map[health.BackendKey]int
-	scheduler          *health.Scheduler
-	reconcileAttempts  int       // Tracks consecutive reconcile failures
-	nextReconcileRetry time.Time // When next retry is allowed
-
-	reconcileReqCh chan struct{}
+	supervisor   *routine.Supervisor
+	tracker      *routine.Tracker
+	resolver     *resolver.Resolver // Rebuilt only when daemon.resolver changes, keeping its cache across reloads
+	resolverCfg  config.ResolverConfig
+	peer         *peerChannel // Heartbeat channel to the other node; owned by Run
+	readConns    func() ([]ipvs.Connection, error)
+	connLog      *connSampler                 // Owned by Run
+	flowSampler  *connSampler                 // Owned by Run
+	ipfix        *observability.IPFIXExporter // Owned by Run; nil unless metrics.ipfix is enabled
+	ipfixCfg     config.IPFIXConfig
+	gelfCfg      config.GELFLogConfig // GELF output as last applied; owned by Run
+
+	traffic       *trafficSample               // Last IPVS counter poll; owned by Run
+	quotaExceeded map[string]bool              // service/quota pairs over their limit; owned by Run
+	ipvsStatsAt   time.Time                    // Last lbctl_ipvs_* refresh; owned by Run
+	ipvsSeries    map[string]ipvsStatsSeries   // lbctl_ipvs_* label sets of the last refresh; owned by Run
+	cacheStats    ipvs.CacheStats              // State cache counters at the last export; owned by Run
+	connSync      []ipvs.SyncDaemon            // Sync daemons last started; owned by Run
+	ipvsTimeouts  ipvs.Timeouts                // Kernel timeouts last set; owned by Run
+	foreign       map[string]prometheus.Labels // Foreign IPVS services exported by key; owned by Run
+	scheduled     *config.ScheduledChange      // Last scheduled change bundle seen; owned by Run
+	watch         configWatch                  // Config files on disk, for daemon.auto_reload; owned by Run
+	acquiredAt    time.Time                    // VIP acquisition not yet programmed into IPVS; owned by Run
+	stateDir      string                       // Where lifecycle transitions are recorded; set by Run
+	control       *ControlServer               // Owned by Run; nil while the control socket is unavailable
+	admin         *adminAPIServer              // Owned by Run; nil unless daemon.api.http is enabled
+	heldWarned    bool                         // Warned that the VIP is held in maintenance; owned by Run
+	lastVRRPState string                       // Last VRRP state read from FRR; owned by Run
+	vipWatch      vipWatch                     // Address change subscription; owned by Run
+	breakerOpen   bool                         // Reconcile circuit breaker tripped; owned by Run
+	reloadCaller  string                       // API caller of the reload in progress; owned by Run
+
+	mu              sync.Mutex
+	cfg             *config.Config
+	cfgHash         string
+	servicesHash    string // Hash of cfg.Services alone, equal on both nodes of a pair
+	active          bool
+	ready           bool              // Set once IPVS matches the startup role
+	converged       bool              // IPVS reconciled since the VIP was last acquired
+	lifecycle       EngineStatus      // Last exported lifecycle state
+	maintenance     MaintenanceStatus // Node forced to standby by an operator
+	reconcileQ      reconcileQueue    // Pending IPVS write and its retry backoff
+	lastReconcile   ReconcileResult   // Outcome of the last IPVS write
+	startedAt       time.Time         // When Run started
+	draining        int               // Destinations the last reconcile left draining
+	backendWeights  map[health.BackendKey]int
+	backendStates   map[health.BackendKey]health.State // Last state the health scheduler reported
+	lastHealthy     map[string][]config.Backend        // Per service: last backends that met health.min_healthy
+	fallbackActive  map[string]bool
+	overrides       map[health.BackendKey]health.OverrideInfo // Operator overrides, re-seeded when the scheduler restarts
+	journalMu       sync.Mutex                                // Guards the override journal fields below
+	overrideJournal string                                    // Override journal path, set by Run; empty disables journaling
+	journalAppends  int                                       // Records appended since the journal was last compacted
+	scheduler       *health.Scheduler
+
+	reconcileReqCh  chan struct{}
+	serviceReloadCh chan serviceReload
+	reloadReqCh     chan reloadRequest // Reloads asked for over the control API
+	maintenanceCh   chan maintenanceRequest
 }
 
 func NewEngine(opts EngineOptions) (*Engine, error) {
-	if opts.ConfigPath == "" {
+	if opts.ConfigPath == "" && opts.Source == nil {
 		return nil, fmt.Errorf("missing config path")
 	}
 	if opts.Network == nil {
@@ -112,26 +210,69 @@ func NewEngine(opts EngineOptions) (*Engine, error) {
 	if metrics == nil {
 		metrics = observability.NewMetricsRegistry()
 	}
+	logger.SetMetrics(metrics)
+	metrics.SetLogger(logger)
 
 	vipInterval := opts.VIPCheckInterval
 	if vipInterval <= 0 {
 		vipInterval = time.Second
 	}
 
+	clk := opts.Clock
+	if clk == nil {
+		clk = clock.Real()
+	}
+	auditor.SetClock(clk)
 	newTicker := opts.NewTicker
 	if newTicker == nil {
-		newTicker = func(d time.Duration) Ticker { return realTicker{t: time.NewTicker(d)} }
+		newTicker = clk.NewTicker
 	}
 
-	loadConfig := opts.LoadConfig
-	if loadConfig == nil {
-		loadConfig = config.LoadConfig
+	configPath, source := opts.ConfigPath, opts.Source
+	var newSource func(path string) config.Source
+	fileSource := func(path string) config.Source { return config.FileSource{Path: path} }
+	switch {
+	case source != nil:
+		if fs, ok := source.(config.FileSource); ok {
+			if configPath == "" {
+				configPath = fs.Path
+			}
+			newSource = fileSource
+		}
+	case opts.LoadConfig != nil:
+		load := opts.LoadConfig
+		newSource = func(path string) config.Source {
+			return config.SourceFunc(func() (*config.Config, error) { return load(path) })
+		}
+		source = newSource(configPath)
+	default:
+		newSource = fileSource
+		source = newSource(configPath)
 	}
 	validateConfig := opts.ValidateConfig
 	if validateConfig == nil {
 		validateConfig = config.Validate
 	}
 
+	readConns := opts.ReadConnections
+	if readConns == nil {
+		readConns = ipvs.ReadConnections
+	}
+
+	notifier := opts.Notifier
+	if notifier == nil {
+		notifier = system.NewSystemdNotifier()
+	}
+
+	vrrpState := opts.VRRPState
+	if vrrpState == nil {
+		vrrpState = system.NewVtyshStateReader()
+	}
+	configWatcher := opts.ConfigWatcher
+	if configWatcher == nil {
+		configWatcher = system.InotifyWatcher{}
+	}
+
 	checker := opts.Checker
 	if checker == nil {
 		checker = &health.TCPChecker{Dialer: health.NetDialer{}}
@@ -139,92 +280,314 @@ func NewEngine(opts EngineOptions) (*Engine, error) {
 	newScheduler := opts.NewScheduler
 	if newScheduler == nil {
 		newScheduler = func(c health.Checker, o health.Observer) *health.Scheduler {
-			return health.NewScheduler(c, o)
+			s := health.NewScheduler(c, o)
+			s.SetClock(clk)
+			return s
 		}
 	}
 
 	e := &Engine{
-		configPath:       opts.ConfigPath,
+		configPath:       configPath,
+		source:           source,
+		newSource:        newSource,
 		logger:           logger,
 		auditor:          auditor,
 		metrics:          metrics,
 		network:          opts.Network,
 		reconciler:       opts.Reconciler,
+		preempter:        opts.Preempter,
+		vrrpState:        vrrpState,
+		configWatcher:    configWatcher,
+		masquerade:       opts.Masquerade,
+		notifier:         notifier,
 		reloadCh:         opts.ReloadCh,
 		vipCheckInterval: vipInterval,
+		clock:            clk,
 		newTicker:        newTicker,
-		loadConfig:       loadConfig,
+		jitter:           randomJitter,
 		validateConfig:   validateConfig,
 		checker:          checker,
 		newScheduler:     newScheduler,
 		backendWeights:   make(map[health.BackendKey]int),
+		backendStates:    make(map[health.BackendKey]health.State),
+		lastHealthy:      make(map[string][]config.Backend),
+		fallbackActive:   make(map[string]bool),
+		overrides:        make(map[health.BackendKey]health.OverrideInfo),
 		reconcileReqCh:   make(chan struct{}, 1),
+		serviceReloadCh:  make(chan serviceReload),
+		reloadReqCh:      make(chan reloadRequest),
+		maintenanceCh:    make(chan maintenanceRequest),
+		readConns:        readConns,
+		connLog:          newConnSampler(),
+		flowSampler:      newConnSampler(),
+		quotaExceeded:    make(map[string]bool),
 	}
 
+	e.supervisor = routine.NewSupervisor(e.onPanic)
+	e.tracker = routine.NewTracker()
+	logger.SetTracker(e.tracker)
+
 	e.initMetrics()
 	return e, nil
 }
 
+// Tracker returns the registry of long-lived goroutines and listeners started
+// by the engine, for leak checks and the debug endpoint.
+func (e *Engine) Tracker() *routine.Tracker {
+	return e.tracker
+}
+
+// Resolver returns the shared DNS cache for hostname backends, or nil before
+// the config is loaded.
+func (e *Engine) Resolver() *resolver.Resolver {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.resolver
+}
+
+// Supervisor returns the supervisor used for engine goroutines, so other
+// long-lived workers (e.g. the Influx pusher) share its logging and metrics.
+func (e *Engine) Supervisor() *routine.Supervisor {
+	return e.supervisor
+}
+
+func (e *Engine) onPanic(p routine.Panic) {
+	e.mu.Lock()
+	cfg := e.cfg
+	e.mu.Unlock()
+
+	node := ""
+	if cfg != nil {
+		node = cfg.Node.Name
+	}
+	e.metrics.Counter("lbctl_goroutine_restarts_total", prometheus.Labels{"node": node, "routine": p.Name}).Inc()
+	e.logger.Error("Recovered panic, restarting", map[string]interface{}{
+		"routine":  p.Name,
+		"panic":    fmt.Sprintf("%v", p.Value),
+		"restarts": p.Restarts,
+		"backoff":  p.Backoff.String(),
+		"stack":    string(p.Stack),
+	})
+}
+
 func (e *Engine) initMetrics() {
 	e.metrics.NewGauge("lbctl_vip_is_owner", "1 if this node owns the VIP", []string{"node", "vip"})
+	e.metrics.NewGauge("lbctl_ready", "1 once the first reconcile has succeeded", []string{"node"})
 	e.metrics.NewCounter("lbctl_vip_transitions_total", "VIP ownership transitions", []string{"node", "vip", "direction"})
 	e.metrics.NewCounter("lbctl_reconcile_runs_total", "Reconcile attempts", []string{"node", "result"})
 	e.metrics.NewGauge("lbctl_reconcile_duration_ms", "Last reconcile duration in ms", []string{"node"})
+	e.metrics.NewGauge("lbctl_reconcile_circuit_open", "1 while daemon.reconcile_backoff.breaker_threshold reconciles in a row have failed", []string{"node"})
 	e.metrics.NewGauge("lbctl_health_backend_healthy", "1 if backend is healthy", []string{"node", "service", "backend"})
 	e.metrics.NewGauge("lbctl_health_backend_weight", "Effective backend weight", []string{"node", "service", "backend"})
+	e.metrics.NewGauge("lbctl_service_fallback_active", "1 while a service is below health.min_healthy and serving its fallback backends", []string{"node", "service"})
+	e.metrics.NewGauge("lbctl_health_backend_latency_seconds", "Smoothed (EWMA) health check latency", []string{"node", "service", "backend"})
+	e.metrics.NewCounter("lbctl_goroutine_restarts_total", "Goroutine restarts after a recovered panic", []string{"node", "routine"})
+	e.metrics.NewHistogram("lbctl_health_check_duration_seconds", "Health check round-trip time", []string{"node", "service", "backend", "result"}, healthCheckBuckets)
+	e.metrics.NewCounter("lbctl_health_backend_failures_total", "Backend transitions to UNHEALTHY by failure reason", []string{"node", "service", "backend", "reason"})
+	e.metrics.NewGauge("lbctl_health_backend_override", "1 while an operator override is active, by mode", []string{"node", "service", "backend", "mode"})
+	e.metrics.NewGauge("lbctl_config_generation", "Commit generation of the applied config.d", []string{"node"})
+	e.metrics.NewCounter("lbctl_ipfix_records_total", "Flows handed to the IPFIX exporter, by result", []string{"node", "result"})
+	e.metrics.NewGauge("lbctl_peer_last_seen_timestamp", "Unix time of the last heartbeat received from the peer node", []string{"node", "remote"})
+	e.metrics.NewGauge("lbctl_service_bytes_per_second", "IPVS byte rate per service and direction", []string{"node", "service", "direction"})
+	e.metrics.NewGauge("lbctl_service_packets_per_second", "IPVS packet rate per service and direction", []string{"node", "service", "direction"})
+	e.metrics.NewGauge("lbctl_service_connections_per_second", "New IPVS connections per second per service", []string{"node", "service"})
+	e.metrics.NewGauge("lbctl_service_quota_exceeded", "1 while a service is over its soft quota", []string{"node", "service", "quota"})
+	e.metrics.NewGauge("lbctl_ipvs_sync_daemon_state", "1 while the IPVS connection sync daemon runs in this state", []string{"node", "state"})
+	e.metrics.NewGauge("lbctl_ipvs_foreign_service", "1 while an IPVS service on a managed VIP that is not in config is kept by daemon.cleanup", []string{"node", "vip", "protocol", "port"})
+	e.metrics.NewCounter("lbctl_ipvs_operations_total", "IPVS writes made by reconciles, by object, kind and result", []string{"node", "object", "kind", "result"})
+	e.metrics.NewGauge("lbctl_reconcile_operations", "IPVS writes made by the last reconcile", []string{"node"})
+	e.metrics.NewGauge("lbctl_engine_state", "1 for the engine's current lifecycle state", []string{"node", "state"})
+	e.metrics.NewGauge("lbctl_maintenance_mode", "1 while an operator has put the node in maintenance", []string{"node"})
+	e.metrics.NewHistogram("lbctl_failover_programming_seconds", "Time from acquiring the VIP to the first successful reconcile", []string{"node"}, failoverBuckets)
+	e.metrics.NewGauge("lbctl_reconcile_queue_depth", "Reconcile requests coalesced into the pending IPVS write", []string{"node"})
+	e.metrics.NewCounter("lbctl_reconcile_expand_cache_total", "Config services whose IPVS expansion reconciles reused (hit) or recomputed (miss)", []string{"node", "result"})
+	e.metrics.NewCounter("lbctl_ipvs_cache_hits_total", "IPVS state reads answered from daemon.state_cache", []string{"node"})
+	e.metrics.NewCounter("lbctl_ipvs_cache_misses_total", "IPVS state reads that went to the kernel", []string{"node"})
+	e.metrics.NewGauge("lbctl_ipvs_cache_entries", "IPVS services and destinations held in daemon.state_cache", []string{"node"})
+	e.metrics.NewGauge("lbctl_ipvs_draining_destinations", "Destinations at weight 0 waiting for their connections to finish before deletion", []string{"node"})
+	e.ipvsStatsMetrics()
+	e.metrics.NewInfo("lbctl_service_info", "Service labels, one label_<key> per configured label", e.serviceInfo)
+	e.metrics.NewInfo("lbctl_config_info", "Hash, role and mode of the last config loaded successfully", e.configInfo)
 }
 
 func (e *Engine) Run(ctx context.Context) error {
-	if err := e.loadAndSetConfig(true); err != nil {
+	e.mu.Lock()
+	e.startedAt = e.clock.Now()
+	e.mu.Unlock()
+	if err := e.loadConfigAfterCommit(ctx, true); err != nil {
 		return err
 	}
+	defer e.auditor.FlushDedup()
+	defer e.notify("STOPPING=1")
+	e.selfTestSinks(ctx)
+
+	e.mu.Lock()
+	cfg := e.cfg
+	e.mu.Unlock()
+	restored := e.restoreOverrides(cfg)
+	e.stateDir = system.StateDir(cfg)
+	e.restoreMaintenance(cfg)
 
 	if err := e.startHealthScheduler(); err != nil {
 		return err
 	}
 	defer e.stopHealthScheduler()
+	e.reportRestoredOverrides(cfg, restored)
+
+	e.syncPeerChannel()
+	defer e.closePeerChannel()
+	e.syncIPFIXExporter()
+	defer e.closeIPFIXExporter()
+	e.syncGELF()
 
 	if err := e.initialVIPSync(ctx); err != nil {
 		e.logger.Warn("Initial VIP sync failed", map[string]interface{}{"error": err.Error()})
 	}
-
-	tickInterval := e.vipCheckIntervalFromConfig()
-	ticker := e.newTicker(tickInterval)
-	defer func() { ticker.Stop() }()
+	e.exportEngineState(cfg)
+	e.openControlServer(cfg)
+	defer e.closeControlServer()
+	e.syncAdminAPI()
+	defer e.closeAdminAPI()
+
+	// One ticker drives both the VIP check and the reconcile work unless the
+	// VIP is to be checked at its own interval
+	var ticker, vipTicker Ticker
+	var vipTickC <-chan time.Time
+	var tickInterval, vipInterval time.Duration
+	syncTickers := func() {
+		nextVIP, next := e.tickIntervalsFromConfig()
+		if ticker != nil && next == tickInterval && nextVIP == vipInterval {
+			return
+		}
+		if ticker != nil {
+			ticker.Stop()
+		}
+		if vipTicker != nil {
+			vipTicker.Stop()
+			vipTicker, vipTickC = nil, nil
+		}
+		ticker, tickInterval, vipInterval = e.newTicker(next), next, nextVIP
+		if vipInterval != tickInterval {
+			vipTicker = e.newTicker(vipInterval)
+			vipTickC = vipTicker.C()
+		}
+	}
+	syncTickers()
+	defer func() {
+		ticker.Stop()
+		if vipTicker != nil {
+			vipTicker.Stop()
+		}
+	}()
+	e.syncVIPWatch()
+	defer e.closeVIPWatch()
+	watchdog, stopWatchdog := e.startWatchdog()
+	defer stopWatchdog()
+
+	e.resetConfigWatch()
+	e.syncConfigWatch()
+	defer e.closeConfigWatch()
+
+	reload := func() error {
+		// Before the first READY=1, systemd is still waiting for startup
+		ready := e.Ready()
+		if ready {
+			e.notify("RELOADING=1")
+		}
+		err := e.onReload(ctx)
+		e.resetConfigWatch()
+		e.syncConfigWatch()
+		e.syncPeerChannel()
+		e.syncIPFIXExporter()
+		e.syncGELF()
+		e.syncAdminAPI()
+		syncTickers()
+		if ready {
+			e.notify("READY=1")
+		}
+		return err
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
+			e.shutdown()
 			return nil
 		case <-ticker.C():
-			e.onVIPTick(ctx)
+			if vipTicker != nil {
+				e.onReconcileTick(ctx)
+			} else {
+				e.onVIPTick(ctx)
+			}
+			e.syncVIPWatch()
+			e.syncConfigWatch()
+			if e.serviceReloadRequests(ctx) {
+				e.resetConfigWatch()
+			}
+			switch {
+			case e.scheduledChanges(ctx):
+				reload()
+			case e.watch.events == nil && e.autoReloadDue():
+				e.logger.Info("Reload requested (config changed on disk)", nil)
+				reload()
+			}
+		case <-vipTickC:
+			e.checkVIP(ctx)
+		case change, ok := <-e.vipWatch.changes:
+			e.onAddressChange(ctx, change, ok)
+		case _, ok := <-e.watch.events:
+			e.onConfigEvent(ok)
+		case <-e.configDebounceC():
+			if e.onConfigDebounce() {
+				e.logger.Info("Reload requested (config changed on disk)", nil)
+				reload()
+			}
 		case <-e.reconcileReqCh:
 			e.tryReconcile(ctx)
+		case req := <-e.serviceReloadCh:
+			err := e.reloadService(ctx, req.name)
+			e.resetConfigWatch()
+			req.done <- err
 		case <-e.reloadCh:
-			e.onReload(ctx)
-			nextInterval := e.vipCheckIntervalFromConfig()
-			if nextInterval != tickInterval {
-				ticker.Stop()
-				ticker = e.newTicker(nextInterval)
-				tickInterval = nextInterval
+			e.logger.Info("Reload requested (SIGHUP)", nil)
+			reload()
+		case req := <-e.reloadReqCh:
+			e.reloadCaller = req.caller
+			if req.path != "" {
+				req.done <- e.switchConfigPath(req.path, reload)
+			} else {
+				e.logger.Info("Reload requested (control API)", withCaller(req.caller, nil))
+				req.done <- reload()
 			}
+			e.reloadCaller = ""
+		case req := <-e.maintenanceCh:
+			req.done <- e.setMaintenance(ctx, req.enabled, req.caller)
+		case <-watchdog:
+			e.notify("WATCHDOG=1")
 		}
 	}
 }
 
-func (e *Engine) vipCheckIntervalFromConfig() time.Duration {
+// tickIntervalsFromConfig returns how often to check the VIP and how often
+// to reconcile. Without a config, both use EngineOptions.VIPCheckInterval.
+func (e *Engine) tickIntervalsFromConfig() (vip, reconcile time.Duration) {
 	e.mu.Lock()
 	cfg := e.cfg
 	e.mu.Unlock()
 
+	reconcile = e.vipCheckInterval
 	if cfg != nil && cfg.Daemon.ReconcileIntervalMS > 0 {
-		return time.Duration(cfg.Daemon.ReconcileIntervalMS) * time.Millisecond
+		reconcile = time.Duration(cfg.Daemon.ReconcileIntervalMS) * time.Millisecond
+	}
+	vip = reconcile
+	if cfg != nil && cfg.Daemon.VIPCheckIntervalMS > 0 {
+		vip = time.Duration(cfg.Daemon.VIPCheckIntervalMS) * time.Millisecond
 	}
-	return e.vipCheckInterval
+	return vip, reconcile
 }
 
 func (e *Engine) loadAndSetConfig(isStartup bool) error {
-	cfg, err := e.loadConfig(e.configPath)
+	cfg, err := e.source.Load()
 	if err != nil {
 		return err
 	}
@@ -236,29 +599,74 @@ func (e *Engine) loadAndSetConfig(isStartup bool) error {
 	if err != nil {
 		return err
 	}
+	servicesHash, err := hashServices(cfg.Services)
+	if err != nil {
+		return err
+	}
 
 	e.mu.Lock()
-	oldHash := e.cfgHash
+	oldHash, oldCfg := e.cfgHash, e.cfg
 	e.cfg = cfg
 	e.cfgHash = hash
+	e.servicesHash = servicesHash
 	e.backendWeights = make(map[health.BackendKey]int)
+	e.backendStates = make(map[health.BackendKey]health.State)
+	e.lastHealthy = make(map[string][]config.Backend)
+	if e.resolver == nil || !reflect.DeepEqual(e.resolverCfg, cfg.Daemon.Resolver) {
+		e.resolver = newResolver(cfg.Daemon.Resolver, e.clock)
+		e.resolverCfg = cfg.Daemon.Resolver
+	}
+	res := e.resolver
 	e.mu.Unlock()
+	if rs, ok := e.reconciler.(resolverSetter); ok {
+		rs.SetResolver(res)
+	}
 
 	e.logger.SetNodeConfig(cfg.Node.Name, map[string]interface{}{
 		"role": cfg.Node.Role,
 	})
+	e.logger.SetSecrets(configSecrets(cfg)...)
+	e.auditor.SetDedupRules(auditDedupRules(cfg.Observability.Logging.AuditDedup))
+	e.metrics.SetSeriesBudget(cfg.Observability.Metrics.MaxSeriesPerMetric)
+	e.metrics.SetConstLabels(metricsConstLabels(cfg))
+	if ms, ok := e.reconciler.(modeSetter); ok {
+		ms.SetMode(cfg.Mode)
+	}
+	if fk, ok := e.reconciler.(foreignKeeper); ok {
+		fk.SetCleanup(cfg.Daemon.Cleanup)
+	}
+	e.setOwnerFile(cfg)
+	if cs, ok := e.reconciler.(concurrencySetter); ok {
+		cs.SetConcurrency(cfg.Daemon.ReconcileConcurrency)
+	}
+	if d, ok := e.reconciler.(drainer); ok {
+		drain := ipvs.DrainConfigFromDaemonConfig(cfg.Daemon.Drain)
+		drain.Clock = e.clock
+		d.SetDrain(drain)
+	}
+	if e.masquerade != nil {
+		if err := e.masquerade.Apply(cfg); err != nil {
+			e.logger.Error("Failed to apply masquerade rules", map[string]interface{}{"mode": cfg.Mode, "error": err.Error()})
+		}
+	}
 
-	e.auditor.Emit(observability.AuditConfigLoaded, map[string]interface{}{
+	e.auditor.Emit(observability.AuditConfigLoaded, withCaller(e.reloadCaller, map[string]interface{}{
 		"config_hash":    hash,
 		"services_count": len(cfg.Services),
 		"backends_count": countBackends(cfg.Services),
 		"startup":        isStartup,
-	})
+		"generation":     cfg.Generation,
+	}))
+	e.metrics.Gauge("lbctl_config_generation", prometheus.Labels{"node": cfg.Node.Name}).Set(float64(cfg.Generation))
 	if oldHash != "" && oldHash != hash {
-		e.auditor.Emit(observability.AuditConfigChanged, map[string]interface{}{
+		fields := map[string]interface{}{
 			"old_hash": oldHash,
 			"new_hash": hash,
-		})
+		}
+		if oldCfg != nil {
+			fields = withConfigDiff(config.Diff(oldCfg, cfg), fields)
+		}
+		e.auditor.Emit(observability.AuditConfigChanged, withCaller(e.reloadCaller, fields))
 	}
 
 	return nil
@@ -272,45 +680,136 @@ func (e *Engine) initialVIPSync(ctx context.Context) error {
 		return fmt.Errorf("missing config")
 	}
 
-	present, err := e.network.CheckVIPPresent(cfg.Network.Frontend.VIP)
+	held, err := e.vipHeld(cfg)
 	if err != nil {
 		return err
 	}
+	present := e.vipActive(held)
 
+	// A standby removes IPVS state left from before it started unless
+	// daemon.startup_policy keeps it
+	policy := strings.ToLower(cfg.Daemon.StartupPolicy)
+	if policy == "" {
+		policy = "cleanup"
+	}
 	e.mu.Lock()
 	e.active = present
-	e.pendingReconcile = present
-	e.pendingDisable = false
+	e.converged = false
+	switch {
+	case present:
+		e.reconcileQ.replace(reconcileReload)
+	case cfg.Daemon.WarmStandby || policy == "cleanup" || held:
+		// A node in maintenance never serves, whatever it finds
+		e.reconcileQ.replace(reconcileDisable)
+	default:
+		e.reconcileQ.replace(reconcileNone)
+	}
 	e.mu.Unlock()
 
 	e.updateVIPGauge(cfg, present)
+	e.syncConnSync(cfg, present)
+	e.syncIPVSTimeouts(cfg)
+	e.syncHealthPace(cfg)
 
 	if present {
 		e.logger.Info("VIP present at startup; starting active", map[string]interface{}{"vip": cfg.Network.Frontend.VIP})
 		e.tryReconcile(ctx)
 	} else {
-		e.logger.Info("VIP not present at startup; starting standby", map[string]interface{}{"vip": cfg.Network.Frontend.VIP})
+		if held {
+			e.logger.Info("Node in maintenance at startup; starting standby", map[string]interface{}{"vip": cfg.Network.Frontend.VIP})
+		} else {
+			e.logger.Info("VIP not present at startup; starting standby", map[string]interface{}{"vip": cfg.Network.Frontend.VIP, "startup_policy": policy})
+		}
+		if policy == "adopt" && !cfg.Daemon.WarmStandby && !held {
+			e.adoptIPVS(cfg)
+		}
+		// Standby has nothing to program, so it is ready immediately
+		e.markReady(cfg)
+		e.tryDisable(ctx)
 	}
 	return nil
 }
 
+// Ready reports whether the engine has completed its first successful reconcile.
+func (e *Engine) Ready() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.ready
+}
+
+// markReady flips the startup gate. Until then ownership metrics report 0 and,
+// when configured, VRRP preemption stays disabled.
+func (e *Engine) markReady(cfg *config.Config) {
+	e.mu.Lock()
+	if e.ready {
+		e.mu.Unlock()
+		return
+	}
+	e.ready = true
+	active := e.active
+	e.mu.Unlock()
+	e.notify("READY=1")
+
+	e.logger.Info("Startup reconcile complete; node ready", map[string]interface{}{"active": active})
+	e.metrics.Gauge("lbctl_ready", prometheus.Labels{"node": cfg.Node.Name}).Set(1)
+	e.updateVIPGauge(cfg, active)
+
+	if cfg.VRRP.PreemptAfterReady && e.preempter != nil {
+		if err := e.preempter.EnablePreempt(cfg); err != nil {
+			e.logger.Error("Failed to enable VRRP preemption", map[string]interface{}{"error": err.Error()})
+		}
+	}
+}
+
+// onVIPTick checks the VIP and then does the periodic reconcile work, when
+// both run at the same interval.
 func (e *Engine) onVIPTick(ctx context.Context) {
 	e.mu.Lock()
 	cfg := e.cfg
-	wasActive := e.active
 	e.mu.Unlock()
 	if cfg == nil {
 		return
 	}
+	e.sendPeerHeartbeat(cfg)
+	if !e.checkVIP(ctx) {
+		return
+	}
+	e.reconcileTick(ctx, cfg)
+}
 
-	present, err := e.network.CheckVIPPresent(cfg.Network.Frontend.VIP)
+// onReconcileTick does the periodic reconcile work when the VIP is checked
+// at its own interval (daemon.vip_check_interval_ms).
+func (e *Engine) onReconcileTick(ctx context.Context) {
+	e.mu.Lock()
+	cfg := e.cfg
+	e.mu.Unlock()
+	if cfg == nil {
+		return
+	}
+	e.sendPeerHeartbeat(cfg)
+	e.reconcileTick(ctx, cfg)
+}
+
+// checkVIP checks whether this node holds the VIP and becomes active or
+// standby on a change. It returns false when the check failed.
+func (e *Engine) checkVIP(ctx context.Context) bool {
+	e.mu.Lock()
+	cfg := e.cfg
+	wasActive := e.active
+	e.mu.Unlock()
+	if cfg == nil {
+		return false
+	}
+
+	held, err := e.vipHeld(cfg)
 	if err != nil {
 		e.logger.Warn("VIP check failed", map[string]interface{}{
 			"vip":   cfg.Network.Frontend.VIP,
 			"error": err.Error(),
 		})
-		return
+		return false
 	}
+	present := e.vipActive(held)
 
 	switch {
 	case present && !wasActive:
@@ -320,12 +819,38 @@ func (e *Engine) onVIPTick(ctx context.Context) {
 	default:
 		e.updateVIPGauge(cfg, present)
 	}
+	e.syncConnSync(cfg, present)
+	if present != wasActive {
+		e.exportEngineState(cfg)
+	}
+	return true
+}
+
+// reconcileTick retries IPVS programming for the current role and refreshes
+// the polled metrics.
+func (e *Engine) reconcileTick(ctx context.Context, cfg *config.Config) {
+	e.mu.Lock()
+	present := e.active
+	e.mu.Unlock()
+	e.syncIPVSTimeouts(cfg)
 
 	if present {
 		e.tryReconcile(ctx)
+		e.pollConnections(cfg)
 	} else {
 		e.tryDisable(ctx)
+		e.resetConnections()
+		e.resetTraffic()
 	}
+	if stats, ok := e.readIPVSStats(cfg, present); ok {
+		if present {
+			e.pollTraffic(cfg, stats)
+		}
+		e.collectIPVSStats(cfg, stats)
+	}
+	e.exportCacheStats(cfg)
+	e.exportReconcileQueue(cfg)
+	e.exportEngineState(cfg)
 }
 
 func (e *Engine) onVIPAcquired(ctx context.Context, cfg *config.Config) {
@@ -334,9 +859,10 @@ func (e *Engine) onVIPAcquired(ctx context.Context, cfg *config.Config) {
 
 	e.mu.Lock()
 	e.active = true
-	e.pendingDisable = false
-	e.pendingReconcile = true
+	e.converged = false
+	e.reconcileQ.replace(reconcileReload)
 	e.mu.Unlock()
+	e.acquiredAt = e.clock.Now()
 
 	e.metrics.Counter("lbctl_vip_transitions_total", prometheus.Labels{
 		"node":      cfg.Node.Name,
@@ -345,6 +871,7 @@ func (e *Engine) onVIPAcquired(ctx context.Context, cfg *config.Config) {
 	}).Inc()
 
 	e.updateVIPGauge(cfg, true)
+	e.syncHealthPace(cfg)
 	e.tryReconcile(ctx)
 }
 
@@ -354,9 +881,10 @@ func (e *Engine) onVIPReleased(ctx context.Context, cfg *config.Config) {
 
 	e.mu.Lock()
 	e.active = false
-	e.pendingReconcile = false
-	e.pendingDisable = true
+	e.converged = false
+	e.reconcileQ.replace(reconcileDisable)
 	e.mu.Unlock()
+	e.acquiredAt = time.Time{}
 
 	e.metrics.Counter("lbctl_vip_transitions_total", prometheus.Labels{
 		"node":      cfg.Node.Name,
@@ -365,12 +893,18 @@ func (e *Engine) onVIPReleased(ctx context.Context, cfg *config.Config) {
 	}).Inc()
 
 	e.updateVIPGauge(cfg, false)
+	e.syncHealthPace(cfg)
 	e.tryDisable(ctx)
 }
 
 func (e *Engine) updateVIPGauge(cfg *config.Config, present bool) {
+	e.mu.Lock()
+	ready := e.ready
+	e.mu.Unlock()
+
+	// Don't advertise ownership before IPVS has been programmed
 	val := 0.0
-	if present {
+	if present && ready {
 		val = 1.0
 	}
 	e.metrics.Gauge("lbctl_vip_is_owner", prometheus.Labels{
@@ -379,13 +913,13 @@ func (e *Engine) updateVIPGauge(cfg *config.Config, present bool) {
 	}).Set(val)
 }
 
-func (e *Engine) onReload(ctx context.Context) {
-	e.logger.Info("Reload requested (SIGHUP)", nil)
-
+// onReload reloads the config and restarts health checks. It returns the
+// load error, in which case the previous config keeps running.
+func (e *Engine) onReload(ctx context.Context) error {
 	// Load and validate new config FIRST - don't stop scheduler until we know new config is valid
-	if err := e.loadAndSetConfig(false); err != nil {
+	if err := e.loadConfigAfterCommit(ctx, false); err != nil {
 		e.logger.Error("Config reload failed; keeping previous config and health scheduler", map[string]interface{}{"error": err.Error()})
-		return
+		return err
 	}
 
 	// Config is valid - now safe to stop old scheduler and start new one
@@ -395,102 +929,265 @@ func (e *Engine) onReload(ctx context.Context) {
 		e.logger.Error("Failed to restart health scheduler after reload", map[string]interface{}{"error": err.Error()})
 	}
 
+	e.requestStandbyOrReconcile(ctx)
+	return nil
+}
+
+// AppliedGeneration returns the commit generation of the running config, or
+// 0 before the first load or when config.d is not generation-stamped.
+func (e *Engine) AppliedGeneration() uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cfg == nil {
+		return 0
+	}
+	return e.cfg.Generation
+}
+
+// A shell commit rewrites config.d between two generation bumps; loads that
+// overlap one are retried until the commit is published.
+const (
+	commitWaitAttempts = 50
+	commitWaitDelay    = 100 * time.Millisecond
+)
+
+// loadConfigAfterCommit loads the config, waiting out any in-progress shell
+// commit, and acknowledges the outcome in the state dir so the committing
+// shell knows whether its generation was applied.
+func (e *Engine) loadConfigAfterCommit(ctx context.Context, isStartup bool) error {
+	err := e.loadAndSetConfig(isStartup)
+	for attempt := 1; errors.Is(err, config.ErrCommitInProgress) && attempt < commitWaitAttempts && ctx.Err() == nil; attempt++ {
+		e.clock.Sleep(commitWaitDelay)
+		err = e.loadAndSetConfig(isStartup)
+	}
+	e.acknowledgeGeneration(err)
+	return err
+}
+
+func (e *Engine) acknowledgeGeneration(loadErr error) {
 	e.mu.Lock()
-	active := e.active
-	e.pendingReconcile = true
+	cfg := e.cfg
 	e.mu.Unlock()
 
-	if active {
-		e.tryReconcile(ctx)
+	dir := config.IncludeDir(e.configPath, cfg)
+	if dir == "" {
+		return
+	}
+	applied := config.AppliedGeneration{Generation: cfg.Generation, Time: e.clock.Now().UTC()}
+	if loadErr != nil {
+		applied.Error = loadErr.Error()
+		if gen, err := config.ReadGeneration(dir); err == nil {
+			applied.Rejected = gen
+		}
+	}
+	stateDir := system.StateDir(cfg)
+	if err := config.WriteAppliedGeneration(stateDir, applied); err != nil {
+		e.logger.Warn("Failed to acknowledge config generation", map[string]interface{}{"state_dir": stateDir, "error": err.Error()})
 	}
 }
 
+// tryReconcile runs the pending reconcile, if any, unless the node is standby
+// or backing off after a failure.
 func (e *Engine) tryReconcile(ctx context.Context) {
 	e.mu.Lock()
 	cfg := e.cfg
-	active := e.active
-	pending := e.pendingReconcile
-	
-	// Check backoff timing - skip if we're in backoff period
-	if !time.Now().After(e.nextReconcileRetry) {
+	if cfg == nil || !e.active {
+		e.mu.Unlock()
+		return
+	}
+	reason, ok := e.reconcileQ.take(false, e.clock.Now())
+	if !ok {
 		e.mu.Unlock()
 		return
 	}
-	
 	weights := make(map[health.BackendKey]int, len(e.backendWeights))
 	for k, v := range e.backendWeights {
 		weights[k] = v
 	}
-	attempts := e.reconcileAttempts
+	attempts := e.reconcileQ.attempts
 	e.mu.Unlock()
+	defer e.exportReconcileQueue(cfg)
+	defer e.exportEngineState(cfg)
 
-	if cfg == nil || !active || !pending {
-		return
-	}
-
-	desired := applyEffectiveWeights(cfg.Services, weights)
-	start := time.Now()
-	err := e.reconciler.Apply(desired, cfg.Network.Frontend.VIP)
-	durationMS := float64(time.Since(start).Milliseconds())
+	desired := e.applyMinHealthy(cfg, applyEffectiveWeights(cfg.Services, weights))
+	start := e.clock.Now()
+	err := e.reconciler.Apply(desired, cfg.Network.Frontend.AllVIPs())
+	durationMS := float64(e.clock.Now().Sub(start).Milliseconds())
 	e.metrics.Gauge("lbctl_reconcile_duration_ms", prometheus.Labels{"node": cfg.Node.Name}).Set(durationMS)
 
 	if err != nil {
 		e.metrics.Counter("lbctl_reconcile_runs_total", prometheus.Labels{"node": cfg.Node.Name, "result": "failure"}).Inc()
-		
+		e.exportPartialOps(cfg, err)
+
+		// A config error fails identically on every retry; wait for a reload
+		if errors.Is(err, errdefs.ErrPermanentConfig) {
+			e.mu.Lock()
+			e.reconcileQ.abandoned()
+			e.lastReconcile = ReconcileResult{Time: e.clock.Now(), Error: err.Error()}
+			e.mu.Unlock()
+
+			e.logger.ErrorFields("Reconcile failed, waiting for config reload", observability.Err(err),
+				observability.String("reason", reason.String()))
+			return
+		}
+
+		// Missing privileges need operator action, so skip the fast retries
+		backoffAttempt := attempts + 1
+		if errors.Is(err, errdefs.ErrPermission) && backoffAttempt < 3 {
+			backoffAttempt = 3
+		}
+
+		e.reconcileFailed(cfg, attempts+1, err)
+		if e.breakerOpen {
+			backoffAttempt = math.MaxInt // Retry at the max delay
+		}
+
 		// Calculate backoff with jitter
-		backoff := calculateBackoff(attempts + 1)
+		backoff := calculateBackoff(backoffAttempt, backoffPolicyFor(cfg), e.jitter)
 		e.mu.Lock()
-		e.pendingReconcile = true
-		e.reconcileAttempts++
-		e.nextReconcileRetry = time.Now().Add(backoff)
+		e.reconcileQ.failed(e.clock.Now().Add(backoff))
+		e.lastReconcile = ReconcileResult{Time: e.clock.Now(), Error: err.Error()}
 		e.mu.Unlock()
-		
-		e.logger.Error("Reconcile failed", map[string]interface{}{
-			"error":    err.Error(),
-			"attempts": attempts + 1,
-			"backoff":  backoff.String(),
-		})
+
+		e.logger.ErrorFields("Reconcile failed", observability.Err(err), observability.String("reason", reason.String()),
+			observability.Int("attempts", attempts+1), observability.Duration("backoff", backoff))
 		return
 	}
 
 	// Success - reset retry state
 	e.metrics.Counter("lbctl_reconcile_runs_total", prometheus.Labels{"node": cfg.Node.Name, "result": "success"}).Inc()
+	e.reconcileSucceeded(cfg)
+	draining := 0
+	if d, ok := e.reconciler.(drainer); ok {
+		draining = d.Draining()
+	}
+	e.metrics.Gauge("lbctl_ipvs_draining_destinations", prometheus.Labels{"node": cfg.Node.Name}).Set(float64(draining))
+	e.exportForeign(cfg)
+	e.exportOps(cfg)
 	e.mu.Lock()
-	e.pendingReconcile = false
-	e.reconcileAttempts = 0
-	e.nextReconcileRetry = time.Time{}
+	e.reconcileQ.succeeded()
+	e.lastReconcile = ReconcileResult{Time: e.clock.Now()}
+	e.converged = true
+	e.draining = draining
+	if draining > 0 {
+		e.reconcileQ.request(reconcileDrain)
+	}
 	e.mu.Unlock()
+
+	e.observeFailover(cfg)
+	e.markReady(cfg)
 }
 
-func (e *Engine) tryDisable(ctx context.Context) {
+// observeFailover records how long IPVS took to match the config after the
+// VIP was acquired, retries included, and warns when that exceeds
+// daemon.failover_budget_ms.
+func (e *Engine) observeFailover(cfg *config.Config) {
+	if e.acquiredAt.IsZero() {
+		return
+	}
+	elapsed := e.clock.Now().Sub(e.acquiredAt)
+	e.acquiredAt = time.Time{}
+
+	e.metrics.Histogram("lbctl_failover_programming_seconds", prometheus.Labels{"node": cfg.Node.Name}).Observe(elapsed.Seconds())
+	fields := map[string]interface{}{"vip": cfg.Network.Frontend.VIP, "duration_ms": elapsed.Milliseconds()}
+	if budget := cfg.Daemon.FailoverBudgetMS; budget > 0 && elapsed > time.Duration(budget)*time.Millisecond {
+		fields["budget_ms"] = budget
+		e.logger.Warn("Failover exceeded its time budget", fields)
+		return
+	}
+	e.logger.Info("Failover programmed", fields)
+}
+
+// requestStandbyOrReconcile brings IPVS in line with a newly loaded config:
+// a reconcile while active, or with daemon.warm_standby, a new standby table.
+func (e *Engine) requestStandbyOrReconcile(ctx context.Context) {
 	e.mu.Lock()
-	cfg := e.cfg
-	active := e.active
-	pending := e.pendingDisable
+	active, warm := e.active, e.cfg != nil && e.cfg.Daemon.WarmStandby
+	switch {
+	case active:
+		e.reconcileQ.request(reconcileReload)
+	case warm:
+		e.reconcileQ.request(reconcileDisable)
+	}
 	e.mu.Unlock()
 
-	if cfg == nil || active || !pending {
+	if active {
+		e.tryReconcile(ctx)
+	} else if warm {
+		e.tryDisable(ctx)
+	}
+}
+
+// standbyServices is what a standby programs: nothing, or with
+// daemon.warm_standby, every service with its destinations at weight 0.
+func standbyServices(cfg *config.Config) []config.Service {
+	if !cfg.Daemon.WarmStandby {
+		return nil
+	}
+	return zeroWeights(cfg.Services)
+}
+
+// zeroWeights returns a copy of services with every destination at weight 0.
+func zeroWeights(services []config.Service) []config.Service {
+	zeroed := make([]config.Service, len(services))
+	for i, svc := range services {
+		zeroed[i] = svc
+		zeroed[i].Backends = make([]config.Backend, len(svc.Backends))
+		for j, b := range svc.Backends {
+			b.Weight = 0
+			zeroed[i].Backends[j] = b
+		}
+	}
+	return zeroed
+}
+
+// tryDisable runs the pending disable, if any, while the node is standby.
+// A failed disable is retried on the next tick.
+func (e *Engine) tryDisable(ctx context.Context) {
+	e.mu.Lock()
+	cfg := e.cfg
+	if cfg == nil || e.active {
+		e.mu.Unlock()
 		return
 	}
+	if _, ok := e.reconcileQ.take(true, e.clock.Now()); !ok {
+		e.mu.Unlock()
+		return
+	}
+	e.mu.Unlock()
+	defer e.exportReconcileQueue(cfg)
+	defer e.exportEngineState(cfg)
 
-	start := time.Now()
-	err := e.reconciler.Apply(nil, cfg.Network.Frontend.VIP)
-	durationMS := float64(time.Since(start).Milliseconds())
+	start := e.clock.Now()
+	err := e.reconciler.Apply(standbyServices(cfg), cfg.Network.Frontend.AllVIPs())
+	durationMS := float64(e.clock.Now().Sub(start).Milliseconds())
 	e.metrics.Gauge("lbctl_reconcile_duration_ms", prometheus.Labels{"node": cfg.Node.Name}).Set(durationMS)
 
 	if err != nil {
 		e.metrics.Counter("lbctl_reconcile_runs_total", prometheus.Labels{"node": cfg.Node.Name, "result": "failure"}).Inc()
-		e.logger.Error("Disable failed", map[string]interface{}{"error": err.Error()})
+		e.exportPartialOps(cfg, err)
+		e.logger.ErrorFields("Disable failed", observability.Err(err))
 		e.mu.Lock()
-		e.pendingDisable = true
+		e.reconcileQ.failed(time.Time{})
+		e.lastReconcile = ReconcileResult{Time: e.clock.Now(), Error: err.Error()}
+		failures := e.reconcileQ.attempts
 		e.mu.Unlock()
+		e.reconcileFailed(cfg, failures, err)
 		return
 	}
 
 	e.metrics.Counter("lbctl_reconcile_runs_total", prometheus.Labels{"node": cfg.Node.Name, "result": "success"}).Inc()
+	e.reconcileSucceeded(cfg)
+	e.exportForeign(cfg)
+	e.exportOps(cfg)
 	e.mu.Lock()
-	e.pendingDisable = false
+	e.reconcileQ.succeeded()
+	e.lastReconcile = ReconcileResult{Time: e.clock.Now()}
 	e.mu.Unlock()
+
+	// A node that lost the VIP before its first successful reconcile is now a
+	// clean standby
+	e.markReady(cfg)
 }
 
 func (e *Engine) requestReconcile() {
@@ -502,7 +1199,7 @@ func (e *Engine) requestReconcile() {
 
 func (e *Engine) startHealthScheduler() error {
 	e.mu.Lock()
-	cfg := e.cfg
+	cfg, active := e.cfg, e.active
 	e.mu.Unlock()
 	if cfg == nil {
 		return fmt.Errorf("missing config")
@@ -510,12 +1207,20 @@ func (e *Engine) startHealthScheduler() error {
 
 	e.stopHealthScheduler()
 
-	targets := healthTargets(cfg.Services)
+	targets := healthTargets(cfg.Services, healthDialer(cfg), e.clock)
+	e.seedOverrides(targets)
 	if len(targets) == 0 {
 		return nil
 	}
 
 	s := e.newScheduler(e.checker, e)
+	s.SetMaxInFlight(cfg.Daemon.Health.MaxInFlight)
+	s.SetPace(healthPace(cfg, active))
+	s.SetSupervisor(e.supervisor)
+	s.SetTracker(e.tracker)
+	if r := e.Resolver(); r != nil {
+		s.SetResolver(r)
+	}
 	if err := s.Start(targets); err != nil {
 		return err
 	}
@@ -538,31 +1243,220 @@ func (e *Engine) stopHealthScheduler() {
 	}
 }
 
-func (e *Engine) OnStateChange(change health.StateChange) {
+// standbyReducedPace is how many intervals apart a standby in reduced mode
+// checks each backend.
+const standbyReducedPace = 5
+
+// healthPace is the scheduler pace for the node's role: every interval while
+// it holds the VIP, daemon.health.standby_mode otherwise.
+func healthPace(cfg *config.Config, active bool) int {
+	if active {
+		return 1
+	}
+	switch strings.ToLower(cfg.Daemon.Health.StandbyMode) {
+	case "reduced":
+		return standbyReducedPace
+	case "off":
+		return 0
+	}
+	return 1
+}
+
+// syncHealthPace paces health checks after a VIP transition. Acquiring the
+// VIP checks every backend at once rather than at its next due interval, and
+// the scheduler drops states gone stale while checks were held back.
+func (e *Engine) syncHealthPace(cfg *config.Config) {
+	e.mu.Lock()
+	active, s := e.active, e.scheduler
+	e.mu.Unlock()
+	if s == nil {
+		return
+	}
+	s.SetPace(healthPace(cfg, active))
+	if mode := strings.ToLower(cfg.Daemon.Health.StandbyMode); mode == "reduced" || mode == "off" {
+		e.logger.Info("Health check pace set for role", map[string]interface{}{"active": active, "standby_mode": mode})
+	}
+}
+
+// SetBackendOverride forces a backend healthy, unhealthy or drained regardless
+// of health checks. A positive ttl clears the override automatically.
+func (e *Engine) SetBackendOverride(ctx context.Context, service, backend string, mode health.Override, ttl time.Duration) error {
+	e.mu.Lock()
+	s := e.scheduler
+	e.mu.Unlock()
+	if s == nil {
+		return fmt.Errorf("health checks are not running")
+	}
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = e.clock.Now().Add(ttl)
+	}
+	return s.SetOverride(health.BackendKey{Service: service, Backend: backend}, mode, expires, callerOf(ctx).name)
+}
+
+func (e *Engine) ClearBackendOverride(ctx context.Context, service, backend string) error {
+	e.mu.Lock()
+	s := e.scheduler
+	e.mu.Unlock()
+	if s == nil {
+		return fmt.Errorf("health checks are not running")
+	}
+	return s.ClearOverride(health.BackendKey{Service: service, Backend: backend}, callerOf(ctx).name)
+}
+
+// seedOverrides carries active overrides into a new scheduler's targets and
+// forgets overrides for backends that no longer exist.
+func (e *Engine) seedOverrides(targets []health.Target) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	keep := make(map[health.BackendKey]health.OverrideInfo, len(e.overrides))
+	for i := range targets {
+		if o, ok := e.overrides[targets[i].Key]; ok {
+			targets[i].Override = o.Mode
+			targets[i].OverrideExpires = o.Expires
+			keep[targets[i].Key] = o
+		}
+	}
+	e.overrides = keep
+}
+
+func (e *Engine) OnOverride(change health.OverrideChange) {
 	e.mu.Lock()
 	cfg := e.cfg
+	if change.New == health.OverrideNone {
+		delete(e.overrides, change.Key)
+	} else {
+		e.overrides[change.Key] = health.OverrideInfo{Mode: change.New, Expires: change.Expires}
+	}
 	e.mu.Unlock()
+	e.journalOverride(change)
 	if cfg == nil {
 		return
 	}
+	e.reportOverride(cfg, change)
+}
 
-	val := 0.0
-	if change.New == health.StateHealthy {
-		val = 1.0
+// reportOverride updates lbctl_health_backend_override and audits change.
+func (e *Engine) reportOverride(cfg *config.Config, change health.OverrideChange) {
+	labels := func(mode health.Override) prometheus.Labels {
+		return prometheus.Labels{
+			"node":    cfg.Node.Name,
+			"service": change.Key.Service,
+			"backend": change.Key.Backend,
+			"mode":    string(mode),
+		}
+	}
+	if backendMetrics(findService(cfg, change.Key.Service)) {
+		if change.Old != health.OverrideNone {
+			e.metrics.Gauge("lbctl_health_backend_override", labels(change.Old)).Set(0)
+		}
+		if change.New != health.OverrideNone {
+			e.metrics.Gauge("lbctl_health_backend_override", labels(change.New)).Set(1)
+		}
 	}
 
-	e.metrics.Gauge("lbctl_health_backend_healthy", prometheus.Labels{
-		"node":    cfg.Node.Name,
-		"service": change.Key.Service,
-		"backend": change.Key.Backend,
-	}).Set(val)
-
-	e.auditor.Emit(observability.AuditHealthStateChanged, map[string]interface{}{
+	fields := map[string]interface{}{
 		"service_name": change.Key.Service,
 		"backend":      change.Key.Backend,
-		"old_state":    string(change.Old),
-		"new_state":    string(change.New),
-	})
+		"old_override": string(change.Old),
+		"new_override": string(change.New),
+		"reason":       change.Reason,
+	}
+	if !change.Expires.IsZero() {
+		fields["expires"] = change.Expires.UTC().Format(time.RFC3339)
+	}
+	e.auditor.Emit(observability.AuditHealthOverride, withServiceLabels(serviceLabels(cfg, change.Key.Service), withCaller(change.Caller, fields)))
+}
+
+func (e *Engine) OnStateChange(change health.StateChange) {
+	e.mu.Lock()
+	cfg := e.cfg
+	if cfg != nil {
+		e.backendStates[change.Key] = change.New
+	}
+	e.mu.Unlock()
+	if cfg == nil {
+		return
+	}
+
+	svc := findService(cfg, change.Key.Service)
+	if backendMetrics(svc) {
+		val := 0.0
+		if change.New == health.StateHealthy {
+			val = 1.0
+		}
+		e.metrics.Gauge("lbctl_health_backend_healthy", prometheus.Labels{
+			"node":    cfg.Node.Name,
+			"service": change.Key.Service,
+			"backend": change.Key.Backend,
+		}).Set(val)
+		if change.Reason != "" {
+			e.metrics.Counter("lbctl_health_backend_failures_total", prometheus.Labels{
+				"node":    cfg.Node.Name,
+				"service": change.Key.Service,
+				"backend": change.Key.Backend,
+				"reason":  change.Reason,
+			}).Inc()
+		}
+	}
+
+	// A backend going down is the one health event worth a warning
+	severity := observability.InfoLevel
+	if change.New == health.StateUnhealthy {
+		severity = observability.WarnLevel
+	}
+	if !logHealthEvent(svc, severity) {
+		return
+	}
+	fields := map[string]interface{}{
+		"service_name":          change.Key.Service,
+		"backend":               change.Key.Backend,
+		"old_state":             string(change.Old),
+		"new_state":             string(change.New),
+		"consecutive_failures":  change.ConsecutiveFailures,
+		"consecutive_successes": change.ConsecutiveSuccesses,
+	}
+	if change.Error != "" {
+		fields["error"] = change.Error
+		fields["failure_reason"] = change.Reason
+	}
+	if !change.Time.IsZero() {
+		fields["checked_at"] = change.Time.UTC().Format(time.RFC3339Nano)
+	}
+	e.auditor.Emit(observability.AuditHealthStateChanged, withServiceLabels(serviceLabels(cfg, change.Key.Service), fields))
+}
+
+func (e *Engine) OnCheck(result health.CheckResult) {
+	e.mu.Lock()
+	cfg := e.cfg
+	e.mu.Unlock()
+	if cfg == nil {
+		return
+	}
+
+	if !backendMetrics(findService(cfg, result.Key.Service)) {
+		return
+	}
+
+	outcome := "success"
+	if result.Err != nil {
+		outcome = "failure"
+	}
+	e.metrics.Histogram("lbctl_health_check_duration_seconds", prometheus.Labels{
+		"node":    cfg.Node.Name,
+		"service": result.Key.Service,
+		"backend": result.Key.Backend,
+		"result":  outcome,
+	}).Observe(result.Duration.Seconds())
+	if result.Latency > 0 {
+		e.metrics.Gauge("lbctl_health_backend_latency_seconds", prometheus.Labels{
+			"node":    cfg.Node.Name,
+			"service": result.Key.Service,
+			"backend": result.Key.Backend,
+		}).Set(result.Latency.Seconds())
+	}
 }
 
 func (e *Engine) OnWeightChange(change health.WeightChange) {
@@ -573,23 +1467,30 @@ func (e *Engine) OnWeightChange(change health.WeightChange) {
 		return
 	}
 	e.backendWeights[change.Key] = change.NewWeight
-	e.pendingReconcile = true
 	active := e.active
+	if active {
+		e.reconcileQ.request(reconcileWeight)
+	}
 	e.mu.Unlock()
 
-	e.metrics.Gauge("lbctl_health_backend_weight", prometheus.Labels{
-		"node":    cfg.Node.Name,
-		"service": change.Key.Service,
-		"backend": change.Key.Backend,
-	}).Set(float64(change.NewWeight))
+	svc := findService(cfg, change.Key.Service)
+	if backendMetrics(svc) {
+		e.metrics.Gauge("lbctl_health_backend_weight", prometheus.Labels{
+			"node":    cfg.Node.Name,
+			"service": change.Key.Service,
+			"backend": change.Key.Backend,
+		}).Set(float64(change.NewWeight))
+	}
 
-	e.auditor.Emit(observability.AuditBackendWeightChanged, map[string]interface{}{
-		"service_name": change.Key.Service,
-		"backend":      change.Key.Backend,
-		"old_weight":   change.OldWeight,
-		"new_weight":   change.NewWeight,
-		"reason":       change.Reason,
-	})
+	if logHealthEvent(svc, observability.InfoLevel) {
+		e.auditor.Emit(observability.AuditBackendWeightChanged, withServiceLabels(serviceLabels(cfg, change.Key.Service), map[string]interface{}{
+			"service_name": change.Key.Service,
+			"backend":      change.Key.Backend,
+			"old_weight":   change.OldWeight,
+			"new_weight":   change.NewWeight,
+			"reason":       change.Reason,
+		}))
+	}
 
 	if active {
 		e.requestReconcile()
@@ -605,6 +1506,17 @@ func hashConfig(cfg *config.Config) (string, error) {
 	return hex.EncodeToString(sum[:]), nil
 }
 
+// hashServices hashes the services of a config. Unlike hashConfig it leaves
+// out node settings, so both nodes of a pair agree while in sync.
+func hashServices(services []config.Service) (string, error) {
+	b, err := json.Marshal(services)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
 func countBackends(services []config.Service) int {
 	total := 0
 	for _, svc := range services {
@@ -613,51 +1525,415 @@ func countBackends(services []config.Service) int {
 	return total
 }
 
-func healthTargets(services []config.Service) []health.Target {
+func healthTargets(services []config.Service, dialer health.Dialer, clk clock.Clock) []health.Target {
 	var targets []health.Target
 	for _, svc := range services {
 		if !svc.Health.Enabled {
 			continue
 		}
+		checker := checkerForHealth(svc.Health, dialer, clk)
+		interval := time.Duration(svc.Health.IntervalMS) * time.Millisecond
 		for _, be := range svc.Backends {
+			checkPort := svc.Health.Port
+			if be.CheckPort != 0 {
+				checkPort = be.CheckPort
+			}
 			targets = append(targets, health.Target{
 				Key: health.BackendKey{
 					Service: svc.Name,
 					Backend: be.Address,
 				},
-				CheckPort:        svc.Health.Port,
-				Interval:         time.Duration(svc.Health.IntervalMS) * time.Millisecond,
+				CheckAddress:     be.CheckAddress,
+				CheckPort:        checkPort,
+				Interval:         interval,
 				Timeout:          time.Duration(svc.Health.TimeoutMS) * time.Millisecond,
 				FailAfter:        svc.Health.FailAfter,
 				RecoverAfter:     svc.Health.RecoverAfter,
 				ConfiguredWeight: be.Weight,
+				Checker:          checker,
+				Jitter:           interval * time.Duration(svc.Health.JitterPct) / 100,
+				SlowStart:        time.Duration(svc.Health.SlowStartMS) * time.Millisecond,
+				SlowStartSteps:   svc.Health.SlowStartSteps,
+				AdaptiveWeight:   svc.Health.AdaptiveWeight,
 			})
 		}
 	}
 	return targets
 }
 
-// calculateBackoff returns exponential backoff with jitter
+func newResolver(cfg config.ResolverConfig, clk clock.Clock) *resolver.Resolver {
+	return resolver.New(resolver.Options{
+		Servers:     cfg.Servers,
+		Timeout:     time.Duration(cfg.TimeoutMS) * time.Millisecond,
+		CacheTTL:    time.Duration(cfg.CacheTTLMS) * time.Millisecond,
+		NegativeTTL: time.Duration(cfg.NegativeTTLMS) * time.Millisecond,
+		Clock:       clk,
+	})
+}
+
+func auditDedupRules(cfgs []config.AuditDedupConfig) []observability.AuditDedupRule {
+	var rules []observability.AuditDedupRule
+	for _, c := range cfgs {
+		keys := c.KeyFields
+		if len(keys) == 0 {
+			keys = []string{"service_name", "backend"}
+		}
+		rules = append(rules, observability.AuditDedupRule{
+			Event:     observability.AuditEvent(c.Event),
+			Window:    time.Duration(c.WindowSeconds) * time.Second,
+			Burst:     c.Burst,
+			KeyFields: keys,
+		})
+	}
+	return rules
+}
+
+// configSecrets returns the secrets in cfg to mask in log output.
+func configSecrets(cfg *config.Config) []string {
+	secrets := []string{cfg.Observability.Metrics.InfluxDB.Token, cfg.Daemon.API.HTTP.Token}
+	for _, t := range cfg.Daemon.API.HTTP.Tokens {
+		secrets = append(secrets, t.Token)
+	}
+	return secrets
+}
+
+// metricsConstLabels returns the cluster/peer labels added to every metric,
+// or nil when no cluster is configured.
+func metricsConstLabels(cfg *config.Config) map[string]string {
+	m := cfg.Observability.Metrics
+	if m.Cluster == "" {
+		return nil
+	}
+	peer := m.Peer
+	if peer == "" {
+		peer = cfg.Node.Name
+	}
+	return map[string]string{"cluster": m.Cluster, "peer": peer}
+}
+
+// healthDialer returns the dialer for health checks bound to the configured
+// source port range, or nil when the kernel picks source ports.
+func healthDialer(cfg *config.Config) health.Dialer {
+	h := cfg.Daemon.Health
+	if h.SourcePortMin == 0 && h.SourcePortMax == 0 {
+		return nil
+	}
+	return &health.PortRangeDialer{Min: h.SourcePortMin, Max: h.SourcePortMax, ReuseAddr: h.ReuseAddr}
+}
+
+// checkerForHealth builds the per-target checker for non-TCP health types and
+// TCP checks with non-default options. A plain TCP check returns nil so the
+// target falls back to the engine's default checker, unless checks dial
+// through a non-nil dialer. With icmp_precheck the checker is wrapped in a
+// ping timed by clk.
+func checkerForHealth(h config.HealthCheck, dialer health.Dialer, clk clock.Clock) health.Checker {
+	checker := checkerForChecks(h, dialer)
+	if !h.ICMPPrecheck {
+		return checker
+	}
+	d := dialerOrDefault(dialer)
+	if checker == nil {
+		checker = &health.TCPChecker{Dialer: d}
+	}
+	return &health.PingPrecheck{
+		Pinger:  &health.ICMPPinger{Dialer: d},
+		Checker: checker,
+		Clock:   clk,
+	}
+}
+
+// dialerOrDefault returns dialer, or a plain net dialer when it is nil.
+// Raw ICMP and half-open sockets pass through a port range dialer unbound.
+func dialerOrDefault(dialer health.Dialer) health.Dialer {
+	if dialer == nil {
+		return health.NetDialer{}
+	}
+	return dialer
+}
+
+func checkerForChecks(h config.HealthCheck, dialer health.Dialer) health.Checker {
+	interval := time.Duration(h.IntervalMS) * time.Millisecond
+	if len(h.Probes) == 0 {
+		return checkerForProbe(h.Primary(), interval, dialer)
+	}
+	// The primary check joins the probes; a nil checker means plain TCP
+	checks := append([]config.HealthProbe{h.Primary()}, h.Probes...)
+	composite := &health.CompositeChecker{Any: strings.EqualFold(h.Combine, "any")}
+	for _, p := range checks {
+		checker := checkerForProbe(p, interval, dialer)
+		if checker == nil {
+			checker = &health.TCPChecker{Dialer: dialerOrDefault(dialer)}
+		}
+		composite.Probes = append(composite.Probes, health.Probe{Checker: checker, Port: p.Port})
+	}
+	return composite
+}
+
+func checkerForProbe(p config.HealthProbe, interval time.Duration, dialer health.Dialer) health.Checker {
+	d := dialerOrDefault(dialer)
+	switch strings.ToLower(p.Type) {
+	case "tcp":
+		return tcpCheckerForProbe(p, interval, dialer)
+	case "udp":
+		return &health.UDPChecker{
+			Dialer:  d,
+			Payload: []byte(p.Payload),
+			Expect:  []byte(p.Expect),
+		}
+	case "redis":
+		return &health.RedisChecker{Dialer: d}
+	case "mysql":
+		return &health.MySQLChecker{Dialer: d, User: p.MySQLUser}
+	case "dns":
+		// Names were checked by the config validator; fall back to defaults
+		qtype, _ := health.ParseDNSQueryType(p.QueryType)
+		rcode, _ := health.ParseDNSRcode(p.ExpectRcode)
+		return &health.DNSChecker{
+			Dialer:      d,
+			QueryName:   p.QueryName,
+			QueryType:   qtype,
+			ExpectRcode: rcode,
+		}
+	}
+	return nil
+}
+
+// tcpCheckerForProbe builds a TCP checker for non-default tcp_mode and
+// tcp_reset settings or a non-nil dialer, or returns nil for a plain connect
+// check. Half-open checks send raw SYNs, so the dialer has no ports to bind.
+// Reused connections are probed on the check interval.
+func tcpCheckerForProbe(p config.HealthProbe, interval time.Duration, dialer health.Dialer) health.Checker {
+	d := dialerOrDefault(dialer)
+	switch strings.ToLower(p.TCPMode) {
+	case "half_open":
+		return &health.HalfOpenChecker{Dialer: d}
+	case "reuse":
+		return &health.ReuseTCPChecker{
+			Dialer:    d,
+			Keepalive: time.Duration(p.TCPKeepaliveMS) * time.Millisecond,
+			Interval:  interval,
+			Reset:     p.TCPReset,
+		}
+	}
+	if p.TCPReset || dialer != nil {
+		return &health.TCPChecker{Dialer: d, Reset: p.TCPReset}
+	}
+	return nil
+}
+
+// backoffPolicy is daemon.reconcile_backoff as durations.
+type backoffPolicy struct {
+	initial time.Duration
+	max     time.Duration
+}
+
+// backoffPolicyFor returns cfg's backoff policy, defaulting to 5s then 10s
+// for configs the validator hasn't filled in.
+func backoffPolicyFor(cfg *config.Config) backoffPolicy {
+	p := backoffPolicy{initial: 5 * time.Second, max: 10 * time.Second}
+	if b := cfg.Daemon.ReconcileBackoff; b.InitialMS > 0 {
+		p.initial = time.Duration(b.InitialMS) * time.Millisecond
+		p.max = max(p.initial, time.Duration(b.MaxMS)*time.Millisecond)
+	}
+	return p
+}
+
+// calculateBackoff returns exponential backoff with up to 20% jitter
 // Attempt 1: 0s (immediate)
-// Attempt 2: 5s + jitter (0-1s)
-// Attempt 3+: 10s + jitter (0-2s)
-func calculateBackoff(attempt int) time.Duration {
+// Attempt 2: initial + jitter
+// Attempt 3+: double the last, up to max, + jitter
+func calculateBackoff(attempt int, p backoffPolicy, jitter func(max time.Duration) time.Duration) time.Duration {
 	if attempt <= 1 {
 		return 0
 	}
-	
-	var base time.Duration
-	var jitter time.Duration
-	
-	if attempt == 2 {
-		base = 5 * time.Second
-		jitter = time.Duration(time.Now().UnixNano()%1000) * time.Millisecond
-	} else {
-		base = 10 * time.Second
-		jitter = time.Duration(time.Now().UnixNano()%2000) * time.Millisecond
+	d := p.initial
+	for i := 2; i < attempt && d < p.max; i++ {
+		d *= 2
+	}
+	d = min(d, p.max)
+	return d + jitter(d/5)
+}
+
+func randomJitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// applyMinHealthy replaces the backends of any service with fewer than
+// health.min_healthy healthy (non-zero weight) backends, so a partial outage
+// doesn't concentrate all traffic on the survivors or blackhole the service.
+// Backends an operator drained or forced unhealthy stay at weight 0.
+func (e *Engine) applyMinHealthy(cfg *config.Config, desired []config.Service) []config.Service {
+	var changes []map[string]interface{}
+
+	e.mu.Lock()
+	for i, svc := range desired {
+		policy := cfg.Services[i].Health
+		if !policy.Enabled || policy.MinHealthy <= 0 {
+			continue
+		}
+
+		healthy := 0
+		for _, be := range svc.Backends {
+			if be.Weight > 0 {
+				healthy++
+			}
+		}
+
+		active := healthy < policy.MinHealthy
+		if !active {
+			e.lastHealthy[svc.Name] = svc.Backends
+		} else {
+			fallback := cfg.Services[i].Backends
+			if strings.ToLower(policy.Fallback) == "last_healthy" && e.lastHealthy[svc.Name] != nil {
+				fallback = e.lastHealthy[svc.Name]
+			}
+			backends := make([]config.Backend, len(fallback))
+			copy(backends, fallback)
+			for j := range backends {
+				key := health.BackendKey{Service: svc.Name, Backend: backends[j].Address}
+				if o, ok := e.overrides[key]; ok && (o.Mode == health.OverrideDrain || o.Mode == health.OverrideUnhealthy) {
+					backends[j].Weight = 0
+				}
+			}
+			desired[i].Backends = backends
+		}
+
+		if active == e.fallbackActive[svc.Name] {
+			continue
+		}
+		e.fallbackActive[svc.Name] = active
+		changes = append(changes, withServiceLabels(svc.Labels, map[string]interface{}{
+			"service_name": svc.Name,
+			"active":       active,
+			"healthy":      healthy,
+			"min_healthy":  policy.MinHealthy,
+			"fallback":     fallbackPolicy(policy.Fallback),
+		}))
+	}
+	e.mu.Unlock()
+
+	for _, fields := range changes {
+		val := 0.0
+		if fields["active"].(bool) {
+			val = 1.0
+		}
+		e.metrics.Gauge("lbctl_service_fallback_active", prometheus.Labels{
+			"node":    cfg.Node.Name,
+			"service": fields["service_name"].(string),
+		}).Set(val)
+		e.auditor.Emit(observability.AuditHealthFallback, fields)
+	}
+	return desired
+}
+
+func findService(cfg *config.Config, name string) *config.Service {
+	for i := range cfg.Services {
+		if cfg.Services[i].Name == name {
+			return &cfg.Services[i]
+		}
+	}
+	return nil
+}
+
+func serviceLabels(cfg *config.Config, name string) map[string]string {
+	if svc := findService(cfg, name); svc != nil {
+		return svc.Labels
+	}
+	return nil
+}
+
+// backendMetrics reports whether per-backend metrics are recorded for svc
+func backendMetrics(svc *config.Service) bool {
+	return svc == nil || !svc.Observability.DisableBackendMetrics
+}
+
+// logHealthEvent reports whether a health event of the given severity passes
+// the service's health_log_level
+func logHealthEvent(svc *config.Service, severity observability.LogLevel) bool {
+	if svc == nil || svc.Observability.HealthLogLevel == "" {
+		return true
+	}
+	// Validated on load; an unparseable level logs everything
+	min, err := observability.ParseLogLevel(svc.Observability.HealthLogLevel)
+	return err != nil || severity >= min
+}
+
+// withConfigDiff adds a config change summary to audit fields. Service lists
+// are comma-separated names; empty lists are left out.
+func withConfigDiff(d config.ConfigDiff, fields map[string]interface{}) map[string]interface{} {
+	for key, names := range map[string][]string{
+		"services_added":    d.ServicesAdded,
+		"services_removed":  d.ServicesRemoved,
+		"services_modified": d.ServicesModified,
+	} {
+		if len(names) > 0 {
+			fields[key] = strings.Join(names, ",")
+		}
+	}
+	fields["services_added_count"] = len(d.ServicesAdded)
+	fields["services_removed_count"] = len(d.ServicesRemoved)
+	fields["services_modified_count"] = len(d.ServicesModified)
+	fields["backends_added"] = d.BackendsAdded
+	fields["backends_removed"] = d.BackendsRemoved
+	fields["backends_delta"] = d.BackendsAdded - d.BackendsRemoved
+	fields["ports_delta"] = d.PortsDelta
+	fields["globals_changed"] = d.GlobalsChanged
+	return fields
+}
+
+// withServiceLabels adds a service's labels to audit fields as label_<key>
+func withServiceLabels(labels map[string]string, fields map[string]interface{}) map[string]interface{} {
+	for k, v := range labels {
+		fields["label_"+k] = v
+	}
+	return fields
+}
+
+// serviceInfo reports one lbctl_service_info series per service
+func (e *Engine) serviceInfo() []observability.InfoSeries {
+	e.mu.Lock()
+	cfg := e.cfg
+	e.mu.Unlock()
+	if cfg == nil {
+		return nil
+	}
+
+	series := make([]observability.InfoSeries, 0, len(cfg.Services))
+	for _, svc := range cfg.Services {
+		s := observability.InfoSeries{"node": cfg.Node.Name, "service": svc.Name}
+		for k, v := range svc.Labels {
+			s["label_"+k] = v
+		}
+		series = append(series, s)
+	}
+	return series
+}
+
+// configInfo reports the lbctl_config_info series of the running config
+func (e *Engine) configInfo() []observability.InfoSeries {
+	e.mu.Lock()
+	cfg, hash, servicesHash := e.cfg, e.cfgHash, e.servicesHash
+	e.mu.Unlock()
+	if cfg == nil {
+		return nil
+	}
+	return []observability.InfoSeries{{
+		"node":          cfg.Node.Name,
+		"hash":          hash,
+		"services_hash": servicesHash,
+		"role":          cfg.Node.Role,
+		"mode":          cfg.Mode,
+	}}
+}
+
+func fallbackPolicy(name string) string {
+	if name == "" {
+		return "all"
 	}
-	
-	return base + jitter
+	return strings.ToLower(name)
 }
 
 func applyEffectiveWeights(services []config.Service, weights map[health.BackendKey]int) []config.Service {
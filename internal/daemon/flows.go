@@ -0,0 +1,88 @@
+package daemon
+
+import (
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/ipvs"
+	"github.com/malindarathnayake/LibraFlux/internal/observability"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultFlowSampleRate exports every new flow when metrics.ipfix.sample_rate
+// is unset.
+const DefaultFlowSampleRate = 1.0
+
+// syncIPFIXExporter opens, replaces or closes the IPFIX exporter to match the
+// running config. It runs on the Run goroutine, which owns e.ipfix.
+func (e *Engine) syncIPFIXExporter() {
+	e.mu.Lock()
+	cfg := e.cfg
+	e.mu.Unlock()
+
+	var want config.IPFIXConfig
+	if cfg != nil && cfg.Observability.Metrics.IPFIX.Enabled {
+		want = cfg.Observability.Metrics.IPFIX
+	}
+	if e.ipfix != nil && e.ipfixCfg == want {
+		return
+	}
+	e.closeIPFIXExporter()
+	if !want.Enabled {
+		return
+	}
+
+	x, err := observability.NewIPFIXExporter(observability.IPFIXConfig{
+		Collector:         want.Collector,
+		ObservationDomain: want.ObservationDomain,
+		CacheSize:         want.CacheSize,
+	})
+	if err != nil {
+		e.logger.Warn("IPFIX export unavailable", map[string]interface{}{"collector": want.Collector, "error": err.Error()})
+		return
+	}
+	e.ipfix = x
+	e.ipfixCfg = want
+	e.logger.Info("IPFIX export enabled", map[string]interface{}{"collector": want.Collector})
+}
+
+func (e *Engine) closeIPFIXExporter() {
+	if e.ipfix == nil {
+		return
+	}
+	_ = e.ipfix.Close()
+	e.ipfix = nil
+	e.ipfixCfg = config.IPFIXConfig{}
+	e.flowSampler.reset()
+}
+
+// exportFlows sends the sampled connections to configured services to the
+// IPFIX collector.
+func (e *Engine) exportFlows(cfg *config.Config, services map[string]string, conns []ipvs.Connection) {
+	now := e.clock.Now()
+	var flows []observability.IPFIXFlow
+	for _, c := range conns {
+		if _, ok := services[serviceAddr(c.VIP, c.Protocol, c.Port)]; !ok {
+			continue
+		}
+		flows = append(flows, observability.IPFIXFlow{
+			Protocol: uint8(ipvs.ProtocolToUint16(c.Protocol)),
+			Source:   c.Client,
+			SrcPort:  c.ClientPort,
+			Dest:     c.VIP,
+			DstPort:  c.Port,
+			Backend:  c.Dest,
+			BackPort: c.DestPort,
+			Start:    now,
+		})
+	}
+
+	dropped := e.ipfix.Add(flows...)
+	sent, err := e.ipfix.Flush()
+	if err != nil {
+		e.logger.Warn("IPFIX export failed", map[string]interface{}{"collector": e.ipfixCfg.Collector, "error": err.Error()})
+	}
+	failed := len(flows) - dropped - sent
+
+	node := cfg.Node.Name
+	e.metrics.Counter("lbctl_ipfix_records_total", prometheus.Labels{"node": node, "result": "exported"}).Add(float64(sent))
+	e.metrics.Counter("lbctl_ipfix_records_total", prometheus.Labels{"node": node, "result": "dropped"}).Add(float64(dropped + failed))
+}
@@ -0,0 +1,90 @@
+package daemon
+
+import (
+	"path/filepath"
+	"strconv"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/ipvs"
+	"github.com/malindarathnayake/LibraFlux/internal/system"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// foreignKeeper is implemented by reconcilers that can leave IPVS services
+// they don't own on a managed VIP instead of deleting them (daemon.cleanup).
+type foreignKeeper interface {
+	SetCleanup(policy string)
+	Foreign() []*ipvs.Service
+}
+
+// ownerFileSetter is implemented by reconcilers that persist which IPVS
+// services they own, so a restarted daemon still deletes services removed
+// from config instead of keeping them as foreign.
+type ownerFileSetter interface {
+	SetOwnerFile(path string) error
+}
+
+// adopter is implemented by reconcilers that can take ownership of IPVS
+// services already in place without changing them (daemon.startup_policy
+// adopt).
+type adopter interface {
+	Adopt(desired []config.Service, vips []string) ([]string, error)
+}
+
+// OwnerFile is where the reconciler keeps its owned IPVS services, in
+// system.state_dir
+const OwnerFile = "ipvs-owned.json"
+
+// setOwnerFile points the reconciler at OwnerFile in the state dir of cfg
+func (e *Engine) setOwnerFile(cfg *config.Config) {
+	of, ok := e.reconciler.(ownerFileSetter)
+	if !ok {
+		return
+	}
+	if err := of.SetOwnerFile(filepath.Join(system.StateDir(cfg), OwnerFile)); err != nil {
+		e.logger.Error("Failed to load owned IPVS services", map[string]interface{}{"error": err.Error()})
+	}
+}
+
+// exportForeign sets lbctl_ipvs_foreign_service to 1 for every foreign
+// service the last reconcile kept, and back to 0 once one is gone. It runs on
+// the Run goroutine.
+func (e *Engine) exportForeign(cfg *config.Config) {
+	fk, ok := e.reconciler.(foreignKeeper)
+	if !ok {
+		return
+	}
+	seen := make(map[string]prometheus.Labels)
+	for _, svc := range fk.Foreign() {
+		labels := prometheus.Labels{
+			"node":     cfg.Node.Name,
+			"vip":      svc.Address.String(),
+			"protocol": svc.Protocol,
+			"port":     strconv.Itoa(int(svc.Port)),
+		}
+		seen[svc.Key()] = labels
+		e.metrics.Gauge("lbctl_ipvs_foreign_service", labels).Set(1)
+	}
+	for key, labels := range e.foreign {
+		if _, ok := seen[key]; !ok {
+			e.metrics.Gauge("lbctl_ipvs_foreign_service", labels).Set(0)
+		}
+	}
+	e.foreign = seen
+}
+
+// adoptIPVS takes ownership of the IPVS services a node starting without the
+// VIP finds on a managed VIP that match the config, leaving them in place.
+func (e *Engine) adoptIPVS(cfg *config.Config) {
+	a, ok := e.reconciler.(adopter)
+	if !ok {
+		return
+	}
+	keys, err := a.Adopt(cfg.Services, cfg.Network.Frontend.AllVIPs())
+	if err != nil {
+		e.logger.Warn("Failed to adopt IPVS services", map[string]interface{}{"error": err.Error()})
+	}
+	if len(keys) > 0 {
+		e.logger.Info("Adopted IPVS services found at startup", map[string]interface{}{"services": len(keys)})
+	}
+}
@@ -0,0 +1,49 @@
+package daemon
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/observability"
+)
+
+// syncGELF starts, replaces or stops GELF log output to match the running
+// config, so a reload that changes compression or batching takes effect. It
+// runs on the Run goroutine, which owns e.gelfCfg. Output already running is
+// kept when a new writer can't be created.
+func (e *Engine) syncGELF() {
+	e.mu.Lock()
+	cfg := e.cfg
+	e.mu.Unlock()
+
+	var want config.GELFLogConfig
+	if cfg != nil && cfg.Observability.Logging.GELF.Enabled {
+		want = cfg.Observability.Logging.GELF
+	}
+	if want == e.gelfCfg {
+		return
+	}
+	if !want.Enabled {
+		e.logger.DisableGELF()
+		e.gelfCfg = want
+		e.logger.Info("GELF output disabled", nil)
+		return
+	}
+
+	addr := net.JoinHostPort(want.Host, strconv.Itoa(want.Port))
+	err := e.logger.InitGELFWithOptions(want.Host, want.Port, strings.ToLower(want.Protocol), want.Facility, observability.GELFOptions{
+		Compression:      want.Compression,
+		CompressionLevel: want.CompressionLevel,
+		BatchSize:        want.BatchSize,
+		FlushInterval:    time.Duration(want.FlushIntervalMS) * time.Millisecond,
+	})
+	if err != nil {
+		e.logger.Warn("GELF output unavailable", map[string]interface{}{"addr": addr, "error": err.Error()})
+		return
+	}
+	e.gelfCfg = want
+	e.logger.Info("GELF output enabled", map[string]interface{}{"addr": addr, "protocol": want.Protocol})
+}
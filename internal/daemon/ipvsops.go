@@ -0,0 +1,76 @@
+package daemon
+
+import (
+	"errors"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/ipvs"
+	"github.com/malindarathnayake/LibraFlux/internal/observability"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// concurrencySetter is implemented by reconcilers that can apply IPVS
+// changes in parallel (daemon.reconcile_concurrency).
+type concurrencySetter interface {
+	SetConcurrency(n int)
+}
+
+// opsReporter is implemented by reconcilers that count the IPVS writes of
+// their last Apply.
+type opsReporter interface {
+	LastOps() []ipvs.OpCount
+}
+
+// expandReporter is implemented by reconcilers that memoize the expansion
+// of config services into IPVS services.
+type expandReporter interface {
+	LastExpandStats() ipvs.ExpandStats
+}
+
+// exportPartialOps exports the IPVS writes of a reconcile that failed for
+// only some services, see ipvs.ApplyError. The others were changed all the
+// same.
+func (e *Engine) exportPartialOps(cfg *config.Config, err error) {
+	var ae *ipvs.ApplyError
+	if errors.As(err, &ae) {
+		e.exportOps(cfg)
+	}
+}
+
+// exportOps adds the IPVS writes of the last reconcile to
+// lbctl_ipvs_operations_total and sets lbctl_reconcile_operations to their
+// total. It also counts the last reconcile's expansion cache hits and
+// misses. It runs on the Run goroutine.
+func (e *Engine) exportOps(cfg *config.Config) {
+	if er, ok := e.reconciler.(expandReporter); ok {
+		stats := er.LastExpandStats()
+		e.metrics.Counter("lbctl_reconcile_expand_cache_total", prometheus.Labels{"node": cfg.Node.Name, "result": "hit"}).Add(float64(stats.Hits))
+		e.metrics.Counter("lbctl_reconcile_expand_cache_total", prometheus.Labels{"node": cfg.Node.Name, "result": "miss"}).Add(float64(stats.Misses))
+	}
+
+	or, ok := e.reconciler.(opsReporter)
+	if !ok {
+		return
+	}
+	total := 0
+	var fields []observability.Field
+	for _, oc := range or.LastOps() {
+		for result, n := range map[string]int{"success": oc.OK, "failure": oc.Failed} {
+			if n == 0 {
+				continue
+			}
+			e.metrics.Counter("lbctl_ipvs_operations_total", prometheus.Labels{
+				"node":   cfg.Node.Name,
+				"object": oc.Object,
+				"kind":   oc.Kind,
+				"result": result,
+			}).Add(float64(n))
+		}
+		total += oc.OK + oc.Failed
+		fields = append(fields, observability.Int(oc.Object+"_"+oc.Kind, oc.OK+oc.Failed))
+	}
+	e.metrics.Gauge("lbctl_reconcile_operations", prometheus.Labels{"node": cfg.Node.Name}).Set(float64(total))
+	if total > 0 {
+		e.logger.DebugFields("Reconcile IPVS operations", append(fields, observability.Int("total", total))...)
+	}
+}
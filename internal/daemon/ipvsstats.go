@@ -0,0 +1,209 @@
+package daemon
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/ipvs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultIPVSStatsInterval is how often the lbctl_ipvs_* gauges are
+// refreshed when metrics.ipvs_stats_interval_seconds is unset.
+const DefaultIPVSStatsInterval = 10 * time.Second
+
+// ipvsStatsReader is implemented by reconcilers that can read back services
+// and destinations with their kernel counters.
+type ipvsStatsReader interface {
+	Stats() ([]ipvs.ServiceStats, error)
+}
+
+// cacheReporter is implemented by reconcilers that read IPVS state through
+// a CachedManager (daemon.state_cache).
+type cacheReporter interface {
+	CacheStats() (ipvs.CacheStats, bool)
+}
+
+// exportCacheStats publishes the state cache's hits, misses and size. The
+// counters grow by the change since the last export. It runs on the Run
+// goroutine.
+func (e *Engine) exportCacheStats(cfg *config.Config) {
+	cr, ok := e.reconciler.(cacheReporter)
+	if !ok {
+		return
+	}
+	stats, ok := cr.CacheStats()
+	if !ok {
+		return
+	}
+	last := e.cacheStats
+	if stats.Hits < last.Hits || stats.Misses < last.Misses {
+		// A new cache starts from zero
+		last = ipvs.CacheStats{}
+	}
+	labels := prometheus.Labels{"node": cfg.Node.Name}
+	e.metrics.Counter("lbctl_ipvs_cache_hits_total", labels).Add(float64(stats.Hits - last.Hits))
+	e.metrics.Counter("lbctl_ipvs_cache_misses_total", labels).Add(float64(stats.Misses - last.Misses))
+	e.metrics.Gauge("lbctl_ipvs_cache_entries", labels).Set(float64(stats.Entries))
+	e.cacheStats = stats
+}
+
+// ipvsStatsMetrics registers the lbctl_ipvs_service_* and
+// lbctl_ipvs_destination_* gauges. Destinations add a backend label.
+func (e *Engine) ipvsStatsMetrics() {
+	for _, kind := range []string{"service", "destination"} {
+		labels := []string{"node", "service", "port"}
+		if kind == "destination" {
+			labels = append(labels, "backend")
+		}
+		prefix := "lbctl_ipvs_" + kind + "_"
+		withDir := append(append([]string(nil), labels...), "direction")
+		e.metrics.NewGauge(prefix+"connections_active", "Active IPVS connections per "+kind, labels)
+		e.metrics.NewGauge(prefix+"connections_inactive", "Inactive IPVS connections per "+kind, labels)
+		e.metrics.NewGauge(prefix+"packets", "Packets forwarded per "+kind+" since it was created", withDir)
+		e.metrics.NewGauge(prefix+"bytes", "Bytes forwarded per "+kind+" since it was created", withDir)
+		e.metrics.NewGauge(prefix+"connections_per_second", "Kernel estimate of new connections per second per "+kind, labels)
+		e.metrics.NewGauge(prefix+"packets_per_second", "Kernel estimate of packets per second per "+kind, withDir)
+		e.metrics.NewGauge(prefix+"bytes_per_second", "Kernel estimate of bytes per second per "+kind, withDir)
+	}
+}
+
+// ipvsStatsSeries is a label set published by collectIPVSStats.
+type ipvsStatsSeries struct {
+	prefix string
+	labels prometheus.Labels
+}
+
+// readIPVSStats reads the kernel's IPVS services and destinations once per
+// tick for everything derived from them: the traffic rates and quotas while
+// active, and the lbctl_ipvs_* gauges when they are due. ok is false when
+// nothing needs them or the reconciler can't read them.
+func (e *Engine) readIPVSStats(cfg *config.Config, active bool) (stats []ipvs.ServiceStats, ok bool) {
+	sr, ok := e.reconciler.(ipvsStatsReader)
+	if !ok || (!active && !e.ipvsStatsDue(cfg)) {
+		return nil, false
+	}
+	stats, err := sr.Stats()
+	if err != nil {
+		// Services whose destinations failed are still returned
+		e.logger.Warn("Failed to read IPVS stats", map[string]interface{}{"error": err.Error()})
+	}
+	return stats, true
+}
+
+// ipvsStatsDue reports whether the lbctl_ipvs_* gauges are due for a
+// refresh, once per metrics.ipvs_stats_interval_seconds.
+func (e *Engine) ipvsStatsDue(cfg *config.Config) bool {
+	interval := DefaultIPVSStatsInterval
+	if s := cfg.Observability.Metrics.IPVSStatsIntervalSeconds; s > 0 {
+		interval = time.Duration(s) * time.Second
+	}
+	return e.ipvsStatsAt.IsZero() || e.clock.Now().Sub(e.ipvsStatsAt) >= interval
+}
+
+// collectIPVSStats publishes stats for the configured services when
+// ipvsStatsDue, and removes the series of services and destinations that
+// are gone. It runs on the Run goroutine. Services that aren't configured on
+// a frontend VIP are skipped.
+func (e *Engine) collectIPVSStats(cfg *config.Config, stats []ipvs.ServiceStats) {
+	if !e.ipvsStatsDue(cfg) {
+		return
+	}
+	e.ipvsStatsAt = e.clock.Now()
+
+	// The tcp and udp halves of a tcp+udp service share their labels, so
+	// their counters are summed before being published
+	type sample struct {
+		prefix           string
+		labels           prometheus.Labels
+		active, inactive int
+		stats            ipvs.Stats
+	}
+	var order []string
+	samples := make(map[string]*sample)
+	add := func(prefix string, labels prometheus.Labels, active, inactive int, s ipvs.Stats) {
+		key := prefix + labels["service"] + "|" + labels["port"] + "|" + labels["backend"]
+		sm, ok := samples[key]
+		if !ok {
+			sm = &sample{prefix: prefix, labels: labels}
+			samples[key] = sm
+			order = append(order, key)
+		}
+		sm.active += active
+		sm.inactive += inactive
+		sm.stats = sm.stats.Add(s)
+	}
+
+	byAddr := serviceByAddr(cfg)
+	for _, st := range stats {
+		svc := st.Service
+		name, ok := byAddr[serviceAddr(svc.Address, svc.Protocol, svc.Port)]
+		if !ok {
+			continue
+		}
+		labels := prometheus.Labels{"node": cfg.Node.Name, "service": name, "port": strconv.Itoa(int(svc.Port))}
+
+		var active, inactive int
+		for _, d := range st.Destinations {
+			active += d.ActiveConns
+			inactive += d.InactiveConns
+
+			dl := prometheus.Labels{"backend": net.JoinHostPort(d.Address.String(), strconv.Itoa(int(d.Port)))}
+			for k, v := range labels {
+				dl[k] = v
+			}
+			add("lbctl_ipvs_destination_", dl, d.ActiveConns, d.InactiveConns, d.Stats)
+		}
+		add("lbctl_ipvs_service_", labels, active, inactive, svc.Stats)
+	}
+	published := make(map[string]ipvsStatsSeries, len(order))
+	for _, key := range order {
+		sm := samples[key]
+		e.setIPVSStats(sm.prefix, sm.labels, sm.active, sm.inactive, sm.stats)
+		published[key] = ipvsStatsSeries{prefix: sm.prefix, labels: sm.labels}
+	}
+	for key, gone := range e.ipvsSeries {
+		if _, ok := published[key]; !ok {
+			e.deleteIPVSStats(gone.prefix, gone.labels)
+		}
+	}
+	e.ipvsSeries = published
+}
+
+func (e *Engine) setIPVSStats(prefix string, labels prometheus.Labels, active, inactive int, s ipvs.Stats) {
+	e.metrics.Gauge(prefix+"connections_active", labels).Set(float64(active))
+	e.metrics.Gauge(prefix+"connections_inactive", labels).Set(float64(inactive))
+	e.metrics.Gauge(prefix+"connections_per_second", labels).Set(float64(s.CPS))
+
+	directional := func(name string, in, out uint64) {
+		for dir, v := range map[string]uint64{"in": in, "out": out} {
+			l := prometheus.Labels{"direction": dir}
+			for k, lv := range labels {
+				l[k] = lv
+			}
+			e.metrics.Gauge(prefix+name, l).Set(float64(v))
+		}
+	}
+	directional("packets", s.PacketsIn, s.PacketsOut)
+	directional("bytes", s.BytesIn, s.BytesOut)
+	directional("packets_per_second", s.PPSIn, s.PPSOut)
+	directional("bytes_per_second", s.BPSIn, s.BPSOut)
+}
+
+// deleteIPVSStats removes the series setIPVSStats published for labels.
+func (e *Engine) deleteIPVSStats(prefix string, labels prometheus.Labels) {
+	for _, name := range []string{"connections_active", "connections_inactive", "connections_per_second"} {
+		e.metrics.DeleteGauge(prefix+name, labels)
+	}
+	for _, name := range []string{"packets", "bytes", "packets_per_second", "bytes_per_second"} {
+		for _, dir := range []string{"in", "out"} {
+			l := prometheus.Labels{"direction": dir}
+			for k, lv := range labels {
+				l[k] = lv
+			}
+			e.metrics.DeleteGauge(prefix+name, l)
+		}
+	}
+}
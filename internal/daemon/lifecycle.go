@@ -0,0 +1,127 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// EngineState is where the engine is in its VIP lifecycle.
+type EngineState string
+
+const (
+	StateStandby         EngineState = "standby"          // VIP elsewhere; no managed services programmed
+	StateActivating      EngineState = "activating"       // VIP held; first reconcile since acquiring it pending
+	StateActive          EngineState = "active"           // VIP held; IPVS matches config
+	StateDraining        EngineState = "draining"         // VIP released; managed services not yet removed
+	StateDegradedBackoff EngineState = "degraded-backoff" // VIP held; reconciles failing and backing off, or waiting for a config fix
+)
+
+var engineStates = []EngineState{StateStandby, StateActivating, StateActive, StateDraining, StateDegradedBackoff}
+
+// EngineStateFile is where the daemon records its lifecycle state, in
+// system.state_dir, for show status.
+const EngineStateFile = "engine.state"
+
+// EngineStatus is the engine's lifecycle state and when it was entered.
+type EngineStatus struct {
+	State EngineState `json:"state"`
+	Since time.Time   `json:"since"`
+}
+
+// WriteEngineStatus records status in the state dir.
+func WriteEngineStatus(dir string, status EngineStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, EngineStateFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write engine state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write engine state: %w", err)
+	}
+	return nil
+}
+
+// ReadEngineStatus returns the state recorded in the state dir, or nil if the
+// daemon has not recorded one.
+func ReadEngineStatus(dir string) (*EngineStatus, error) {
+	b, err := os.ReadFile(filepath.Join(dir, EngineStateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read engine state: %w", err)
+	}
+	var status EngineStatus
+	if err := json.Unmarshal(b, &status); err != nil {
+		return nil, fmt.Errorf("invalid engine state file: %w", err)
+	}
+	return &status, nil
+}
+
+// stateLocked derives the lifecycle state. The caller must hold e.mu.
+func (e *Engine) stateLocked() EngineState {
+	switch {
+	case e.active && (e.reconcileQ.attempts > 0 || e.reconcileQ.stalled):
+		return StateDegradedBackoff
+	case e.active && !e.converged:
+		return StateActivating
+	case e.active:
+		return StateActive
+	case e.reconcileQ.pending == reconcileDisable || e.reconcileQ.running == reconcileDisable:
+		return StateDraining
+	}
+	return StateStandby
+}
+
+// State returns the engine's lifecycle state.
+func (e *Engine) State() EngineState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.stateLocked()
+}
+
+// exportEngineState sets lbctl_engine_state and, on a transition, logs it and
+// records it in the state dir once Run has enabled that.
+func (e *Engine) exportEngineState(cfg *config.Config) {
+	e.mu.Lock()
+	state := e.stateLocked()
+	prev := e.lifecycle.State
+	if state != prev {
+		e.lifecycle = EngineStatus{State: state, Since: e.clock.Now().UTC()}
+	}
+	status := e.lifecycle
+	e.mu.Unlock()
+
+	for _, s := range engineStates {
+		val := 0.0
+		if s == state {
+			val = 1
+		}
+		e.metrics.Gauge("lbctl_engine_state", prometheus.Labels{"node": cfg.Node.Name, "state": string(s)}).Set(val)
+	}
+
+	if state == prev {
+		return
+	}
+	if prev != "" {
+		e.logger.Info("Engine state changed", map[string]interface{}{"from": string(prev), "to": string(state)})
+	}
+	e.notify("STATUS=Engine " + string(state))
+	if e.stateDir == "" {
+		return
+	}
+	if err := WriteEngineStatus(e.stateDir, status); err != nil {
+		e.logger.Warn("Failed to record engine state", map[string]interface{}{"state_dir": e.stateDir, "error": err.Error()})
+	}
+}
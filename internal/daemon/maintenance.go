@@ -0,0 +1,206 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/observability"
+	"github.com/malindarathnayake/LibraFlux/internal/system"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MaintenanceFile records maintenance mode in system.state_dir, so a node
+// stays out of rotation across daemon restarts.
+const MaintenanceFile = "maintenance.json"
+
+// maintenancePriority is the VRRP priority of a node in maintenance: the
+// lowest VRRP allows, so any peer takes the VIP over.
+const maintenancePriority = 1
+
+// MaintenanceStatus is whether an operator has taken the node out of
+// rotation, and since when. A node in maintenance behaves as standby even
+// while it holds the VIP.
+type MaintenanceStatus struct {
+	Enabled bool      `json:"enabled"`
+	Since   time.Time `json:"since"`
+
+	// Set when a drain shutdown lowered the VRRP priority to hand the VIP
+	// off; the next start restores the configured priority.
+	ShutdownHandoff bool `json:"shutdown_handoff,omitempty"`
+}
+
+// MaintenanceRequest is the body of POST /v1/maintenance.
+type MaintenanceRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+type maintenanceRequest struct {
+	enabled bool
+	caller  string // Recorded in the maintenance_changed audit event
+	done    chan error
+}
+
+// prioritySetter is implemented by VRRP preempters that can change the
+// node's VRRP priority at runtime.
+type prioritySetter interface {
+	SetPriority(cfg *config.Config, priority int) error
+}
+
+// ReadMaintenance returns the maintenance mode recorded in the state dir;
+// none recorded reads as disabled.
+func ReadMaintenance(dir string) (MaintenanceStatus, error) {
+	var status MaintenanceStatus
+	b, err := os.ReadFile(filepath.Join(dir, MaintenanceFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return status, nil
+		}
+		return status, fmt.Errorf("failed to read maintenance state: %w", err)
+	}
+	if err := json.Unmarshal(b, &status); err != nil {
+		return status, fmt.Errorf("invalid maintenance state file: %w", err)
+	}
+	return status, nil
+}
+
+// WriteMaintenance records status in the state dir.
+func WriteMaintenance(dir string, status MaintenanceStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, MaintenanceFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write maintenance state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write maintenance state: %w", err)
+	}
+	return nil
+}
+
+// SetMaintenance puts the node in maintenance or takes it out of it. The
+// change runs on Run's goroutine, so it waits until Run picks it up or ctx
+// is done.
+func (e *Engine) SetMaintenance(ctx context.Context, enabled bool) error {
+	req := maintenanceRequest{enabled: enabled, caller: callerOf(ctx).name, done: make(chan error, 1)}
+	select {
+	case e.maintenanceCh <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// restoreMaintenance picks up the maintenance mode recorded by the previous
+// run. It runs before the initial VIP sync, so a node in maintenance never
+// starts active.
+func (e *Engine) restoreMaintenance(cfg *config.Config) {
+	status, err := ReadMaintenance(e.stateDir)
+	if err != nil {
+		e.logger.Warn("Failed to restore maintenance mode", map[string]interface{}{"error": err.Error()})
+	}
+	handoff := status.ShutdownHandoff
+	status.ShutdownHandoff = false
+	e.mu.Lock()
+	e.maintenance = status
+	e.mu.Unlock()
+	if status.Enabled {
+		e.logger.Warn("Node in maintenance; it stays standby until maintenance is turned off", map[string]interface{}{"since": status.Since.Format(time.RFC3339)})
+		e.syncVRRPPriority(cfg, true)
+	} else if handoff {
+		e.logger.Info("Restoring the VRRP priority lowered by the last shutdown", nil)
+		if e.syncVRRPPriority(cfg, false) == nil {
+			if err := WriteMaintenance(e.stateDir, status); err != nil {
+				e.logger.Warn("Failed to record maintenance mode", map[string]interface{}{"state_dir": e.stateDir, "error": err.Error()})
+			}
+		}
+	}
+	e.exportMaintenance(cfg, status.Enabled)
+}
+
+// setMaintenance applies a maintenance change on the Run goroutine: it
+// records it, moves VRRP priority and programs IPVS for the resulting role at
+// once. The change stands even if VRRP can't be updated, which is returned.
+func (e *Engine) setMaintenance(ctx context.Context, enabled bool, caller string) error {
+	e.mu.Lock()
+	cfg := e.cfg
+	if e.maintenance.Enabled == enabled || cfg == nil {
+		e.mu.Unlock()
+		return nil
+	}
+	e.maintenance = MaintenanceStatus{Enabled: enabled, Since: e.clock.Now().UTC()}
+	status := e.maintenance
+	e.mu.Unlock()
+
+	if enabled {
+		e.logger.Warn("Entering maintenance; node will act as standby", nil)
+	} else {
+		e.logger.Warn("Leaving maintenance", nil)
+	}
+	e.auditor.Emit(observability.AuditMaintenanceChanged, withCaller(caller, map[string]interface{}{"enabled": enabled}))
+	if err := WriteMaintenance(e.stateDir, status); err != nil {
+		e.logger.Warn("Failed to record maintenance mode", map[string]interface{}{"state_dir": e.stateDir, "error": err.Error()})
+	}
+	e.exportMaintenance(cfg, enabled)
+	err := e.syncVRRPPriority(cfg, enabled)
+	e.onVIPTick(ctx)
+	return err
+}
+
+// syncVRRPPriority lowers the node's VRRP priority in maintenance, so the
+// peer takes the VIP, and restores the configured one afterwards.
+func (e *Engine) syncVRRPPriority(cfg *config.Config, maintenance bool) error {
+	ps, ok := e.preempter.(prioritySetter)
+	if !ok {
+		return nil
+	}
+	priority := system.VRRPPriority(cfg)
+	if maintenance {
+		priority = maintenancePriority
+	}
+	if err := ps.SetPriority(cfg, priority); err != nil {
+		e.logger.Error("Failed to set VRRP priority", map[string]interface{}{"priority": priority, "error": err.Error()})
+		return fmt.Errorf("failed to set VRRP priority %d: %w", priority, err)
+	}
+	return nil
+}
+
+// vipActive reports whether the node acts on holding the VIP: not while in
+// maintenance. A node in maintenance that still holds the VIP, e.g. because
+// its peer is down, doesn't forward its traffic; that is warned about once
+// each time it happens.
+func (e *Engine) vipActive(held bool) bool {
+	e.mu.Lock()
+	maintenance := e.maintenance.Enabled
+	e.mu.Unlock()
+	if !held || !maintenance {
+		e.heldWarned = false
+		return held
+	}
+	if !e.heldWarned {
+		e.logger.Warn("VIP held while in maintenance; its traffic is not forwarded until the peer takes it over", nil)
+		e.heldWarned = true
+	}
+	return false
+}
+
+func (e *Engine) exportMaintenance(cfg *config.Config, enabled bool) {
+	val := 0.0
+	if enabled {
+		val = 1
+	}
+	e.metrics.Gauge("lbctl_maintenance_mode", prometheus.Labels{"node": cfg.Node.Name}).Set(val)
+}
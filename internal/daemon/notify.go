@@ -0,0 +1,26 @@
+package daemon
+
+import "time"
+
+// notify passes state to the service manager. A failure is only logged: a
+// missed watchdog ping gets the daemon restarted anyway, and nothing else it
+// sends is worth stopping for.
+func (e *Engine) notify(state string) {
+	if err := e.notifier.Notify(state); err != nil {
+		e.logger.Warn("Failed to notify service manager", map[string]interface{}{"state": state, "error": err.Error()})
+	}
+}
+
+// startWatchdog returns the channel on which Run pings the service manager's
+// watchdog, at half the interval it expects, and a func that stops it. The
+// channel never fires when no watchdog is configured. Pinging from Run's loop
+// means a hung tick or reload stops the pings and gets the daemon restarted.
+func (e *Engine) startWatchdog() (<-chan time.Time, func()) {
+	interval := e.notifier.WatchdogInterval()
+	if interval <= 0 {
+		return nil, func() {}
+	}
+	e.logger.Info("Service manager watchdog enabled", map[string]interface{}{"interval": interval.String()})
+	ticker := e.newTicker(interval / 2)
+	return ticker.C(), ticker.Stop
+}
@@ -0,0 +1,155 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+	"github.com/malindarathnayake/LibraFlux/internal/ipvs"
+	"github.com/malindarathnayake/LibraFlux/internal/system"
+)
+
+// Exit codes of a one-shot run, see OnceExitCode.
+const (
+	OnceOK            = 0 // IPVS matches the config
+	OnceFailed        = 1 // The reconcile failed
+	OnceChanges       = 2 // Dry run: IPVS differs from the config
+	OnceInvalidConfig = 3 // The config could not be loaded or is invalid
+)
+
+// planner is implemented by reconcilers that can compute a reconcile
+// without making it.
+type planner interface {
+	Plan(desired []config.Service, vips []string) (*ipvs.Plan, error)
+}
+
+// OnceOptions tunes RunOnce.
+type OnceOptions struct {
+	DryRun bool // Plan the reconcile and report it without changing IPVS
+}
+
+// OnceResult summarizes a RunOnce.
+type OnceResult struct {
+	ConfigHash string
+	Active     bool // Whether the node owned the VIP, so programmed its services
+	Services   int
+	Backends   int
+	DryRun     bool
+	Plan       *ipvs.Plan // The planned changes of a dry run
+	Operations int        // IPVS writes made, or planned in a dry run
+	Draining   int        // Destinations left draining, deleted by a later reconcile
+	Duration   time.Duration
+}
+
+// RunOnce loads the config, reconciles IPVS once and returns, for CI
+// pipelines and cron jobs that don't run the daemon. Health checks, the
+// control socket and the other daemon loops are never started, so every
+// backend is programmed at its configured weight. A node without the VIP, or
+// in maintenance, programs what a standby would. With opts.DryRun nothing on
+// the system is changed. The engine must not be Run afterwards.
+func (e *Engine) RunOnce(ctx context.Context, opts OnceOptions) (*OnceResult, error) {
+	res := &OnceResult{DryRun: opts.DryRun}
+	if opts.DryRun {
+		// A dry run leaves masquerade rules alone as well
+		e.masquerade = nil
+	}
+	if err := e.loadAndSetConfig(true); err != nil {
+		return res, errdefs.PermanentConfig(fmt.Errorf("config: %w", err))
+	}
+	e.mu.Lock()
+	cfg, hash := e.cfg, e.cfgHash
+	e.mu.Unlock()
+	res.ConfigHash = hash
+	res.Services = len(cfg.Services)
+	res.Backends = countBackends(cfg.Services)
+
+	maintenance, err := ReadMaintenance(system.StateDir(cfg))
+	if err != nil {
+		e.logger.Warn("Failed to read maintenance mode", map[string]interface{}{"error": err.Error()})
+	}
+	held, err := e.vipHeld(cfg)
+	if err != nil {
+		return res, fmt.Errorf("failed to check VIP: %w", err)
+	}
+	res.Active = held && !maintenance.Enabled
+
+	desired := cfg.Services
+	if !res.Active {
+		desired = standbyServices(cfg)
+		// As at daemon startup, daemon.startup_policy may keep what is there
+		if policy := strings.ToLower(cfg.Daemon.StartupPolicy); desired == nil && (policy == "preserve" || policy == "adopt") {
+			return res, nil
+		}
+	}
+	vips := cfg.Network.Frontend.AllVIPs()
+	start := e.clock.Now()
+	defer func() { res.Duration = e.clock.Now().Sub(start) }()
+
+	if opts.DryRun {
+		p, ok := e.reconciler.(planner)
+		if !ok {
+			return res, errors.New("dry run: reconciler cannot plan")
+		}
+		plan, err := p.Plan(desired, vips)
+		if err != nil {
+			return res, err
+		}
+		res.Plan = plan
+		res.Operations = len(plan.Services) + len(plan.Destinations)
+		return res, nil
+	}
+
+	e.syncIPVSTimeouts(cfg)
+	err = e.reconciler.Apply(desired, vips)
+	if or, ok := e.reconciler.(opsReporter); ok {
+		for _, oc := range or.LastOps() {
+			res.Operations += oc.OK + oc.Failed
+		}
+	}
+	if d, ok := e.reconciler.(drainer); ok {
+		res.Draining = d.Draining()
+	}
+	return res, err
+}
+
+// OnceExitCode is the process exit code for the outcome of RunOnce.
+func OnceExitCode(res *OnceResult, err error) int {
+	switch {
+	case errors.Is(err, errdefs.ErrPermanentConfig):
+		return OnceInvalidConfig
+	case err != nil:
+		return OnceFailed
+	case res.DryRun && res.Operations > 0:
+		return OnceChanges
+	default:
+		return OnceOK
+	}
+}
+
+// WriteSummary prints res for a terminal or CI log: the planned changes of
+// a dry run, then one line per fact.
+func (res *OnceResult) WriteSummary(w io.Writer) {
+	if res.Plan != nil {
+		fmt.Fprint(w, res.Plan.String())
+	}
+	role := "standby"
+	if res.Active {
+		role = "active"
+	}
+	verb := "applied"
+	if res.DryRun {
+		verb = "planned"
+	}
+	fmt.Fprintf(w, "Config:     %s\n", res.ConfigHash)
+	fmt.Fprintf(w, "Role:       %s\n", role)
+	fmt.Fprintf(w, "Services:   %d (%d backends)\n", res.Services, res.Backends)
+	fmt.Fprintf(w, "Operations: %d %s in %s\n", res.Operations, verb, res.Duration.Round(time.Millisecond))
+	if res.Draining > 0 {
+		fmt.Fprintf(w, "Draining:   %d destinations, removed by the next reconcile\n", res.Draining)
+	}
+}
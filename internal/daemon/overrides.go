@@ -0,0 +1,244 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/health"
+	"github.com/malindarathnayake/LibraFlux/internal/system"
+)
+
+// OverrideJournalFile is where operator overrides are journaled, in
+// system.state_dir. Every change is appended and synced before
+// SetBackendOverride or ClearBackendOverride returns, and the journal is
+// replayed on startup, so overrides survive a daemon restart or crash.
+const OverrideJournalFile = "overrides.journal"
+
+// overrideJournalCompactAfter is how many records are appended before the
+// journal is rewritten with only the active overrides.
+const overrideJournalCompactAfter = 256
+
+// overrideRecord is one journal line. An empty Mode clears the override.
+type overrideRecord struct {
+	Service string          `json:"service"`
+	Backend string          `json:"backend"`
+	Mode    health.Override `json:"mode,omitempty"`
+	Expires time.Time       `json:"expires"` // Zero when the override has no TTL
+	At      time.Time       `json:"at"`
+}
+
+func overrideJournalPath(cfg *config.Config) string {
+	return filepath.Join(system.StateDir(cfg), OverrideJournalFile)
+}
+
+// restoreOverrides enables the journal in the state dir of cfg and replays it
+// into the active overrides, dropping the ones that expired while the daemon
+// was down. It returns the restored backends. Run calls it before the first
+// health scheduler starts, which seeds them into its targets.
+func (e *Engine) restoreOverrides(cfg *config.Config) []health.BackendKey {
+	path := overrideJournalPath(cfg)
+	e.journalMu.Lock()
+	e.overrideJournal = path
+	e.journalMu.Unlock()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			e.logger.Warn("Failed to read override journal", map[string]interface{}{"path": path, "error": err.Error()})
+		}
+		return nil
+	}
+
+	active := make(map[health.BackendKey]health.OverrideInfo)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; sc.Scan(); line++ {
+		var rec overrideRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			// A crash can leave the last line half written
+			e.logger.Warn("Skipping invalid override journal record", map[string]interface{}{"path": path, "line": line, "error": err.Error()})
+			continue
+		}
+		key := health.BackendKey{Service: rec.Service, Backend: rec.Backend}
+		if rec.Mode == health.OverrideNone {
+			delete(active, key)
+			continue
+		}
+		if _, err := health.ParseOverride(string(rec.Mode)); err != nil {
+			e.logger.Warn("Skipping invalid override journal record", map[string]interface{}{"path": path, "line": line, "error": err.Error()})
+			continue
+		}
+		active[key] = health.OverrideInfo{Mode: rec.Mode, Expires: rec.Expires}
+	}
+
+	now := e.clock.Now()
+	restored := make([]health.BackendKey, 0, len(active))
+	e.mu.Lock()
+	for key, o := range active {
+		if !o.Expires.IsZero() && !now.Before(o.Expires) {
+			continue
+		}
+		e.overrides[key] = o
+		restored = append(restored, key)
+	}
+	e.mu.Unlock()
+	sort.Slice(restored, func(i, j int) bool { return backendKeyLess(restored[i], restored[j]) })
+	return restored
+}
+
+// reportRestoredOverrides compacts the journal once the health scheduler has
+// dropped overrides for backends no longer in config, and reports the
+// restored overrides that are still active like newly set ones.
+func (e *Engine) reportRestoredOverrides(cfg *config.Config, restored []health.BackendKey) {
+	e.compactOverrideJournal()
+	for _, key := range restored {
+		e.mu.Lock()
+		o, ok := e.overrides[key]
+		e.mu.Unlock()
+		if !ok {
+			continue
+		}
+		e.reportOverride(cfg, health.OverrideChange{Key: key, New: o.Mode, Expires: o.Expires, Reason: "restored"})
+	}
+	if len(restored) > 0 {
+		e.logger.Info("Restored operator overrides from journal", map[string]interface{}{"count": len(restored)})
+	}
+}
+
+// journalOverride appends change to the journal and syncs it. It does
+// nothing until Run has restored the journal.
+func (e *Engine) journalOverride(change health.OverrideChange) {
+	e.journalMu.Lock()
+	defer e.journalMu.Unlock()
+	path := e.overrideJournal
+	if path == "" {
+		return
+	}
+
+	rec := overrideRecord{
+		Service: change.Key.Service,
+		Backend: change.Key.Backend,
+		Mode:    change.New,
+		Expires: change.Expires,
+		At:      e.clock.Now().UTC(),
+	}
+	if err := appendOverrideRecord(path, rec); err != nil {
+		e.logger.Error("Failed to journal operator override", map[string]interface{}{"path": path, "error": err.Error()})
+		return
+	}
+	e.journalAppends++
+	if e.journalAppends >= overrideJournalCompactAfter {
+		e.compactOverrideJournalLocked()
+	}
+}
+
+func (e *Engine) compactOverrideJournal() {
+	e.journalMu.Lock()
+	defer e.journalMu.Unlock()
+	e.compactOverrideJournalLocked()
+}
+
+// compactOverrideJournalLocked rewrites the journal with one record per
+// active override. The caller must hold journalMu.
+func (e *Engine) compactOverrideJournalLocked() {
+	path := e.overrideJournal
+	if path == "" {
+		return
+	}
+	now := e.clock.Now().UTC()
+	e.mu.Lock()
+	recs := make([]overrideRecord, 0, len(e.overrides))
+	for key, o := range e.overrides {
+		recs = append(recs, overrideRecord{Service: key.Service, Backend: key.Backend, Mode: o.Mode, Expires: o.Expires, At: now})
+	}
+	e.mu.Unlock()
+	if len(recs) == 0 {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return
+		}
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		return backendKeyLess(health.BackendKey{Service: recs[i].Service, Backend: recs[i].Backend},
+			health.BackendKey{Service: recs[j].Service, Backend: recs[j].Backend})
+	})
+
+	if err := writeOverrideJournal(path, recs); err != nil {
+		e.logger.Error("Failed to compact override journal", map[string]interface{}{"path": path, "error": err.Error()})
+		return
+	}
+	e.journalAppends = 0
+}
+
+func backendKeyLess(a, b health.BackendKey) bool {
+	if a.Service != b.Service {
+		return a.Service < b.Service
+	}
+	return a.Backend < b.Backend
+}
+
+func appendOverrideRecord(path string, rec overrideRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeOverrideJournal replaces the journal at path with recs. The new
+// journal is synced before it is renamed into place, so a crash leaves either
+// the old or the new one.
+func writeOverrideJournal(path string, recs []overrideRecord) error {
+	var buf bytes.Buffer
+	for _, rec := range recs {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace override journal: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/observability"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultHeartbeatPort is the UDP port of the peer channel when
+// vrrp.heartbeat_port is unset.
+const DefaultHeartbeatPort = 5406
+
+// peerHeartbeat is the datagram exchanged on the peer channel
+type peerHeartbeat struct {
+	Node string `json:"node"`
+	Role string `json:"role"`
+}
+
+// peerChannel exchanges heartbeats with the other node of an HA pair. It is
+// independent of VRRP, where a backup stays silent, so each node can tell
+// whether its peer is alive regardless of who owns the VIP.
+type peerChannel struct {
+	key  string // peer_address:port the channel was opened for
+	conn *net.UDPConn
+	peer *net.UDPAddr
+	done chan struct{}
+}
+
+// peerChannelKey identifies the channel cfg asks for, or "" for none
+func peerChannelKey(cfg *config.Config) string {
+	if cfg == nil || cfg.VRRP.PeerAddress == "" {
+		return ""
+	}
+	port := cfg.VRRP.HeartbeatPort
+	if port == 0 {
+		port = DefaultHeartbeatPort
+	}
+	return net.JoinHostPort(cfg.VRRP.PeerAddress, strconv.Itoa(port))
+}
+
+// openPeerChannel listens on the heartbeat port on all addresses and sends
+// to the peer on the same port.
+func openPeerChannel(key string) (*peerChannel, error) {
+	peer, err := net.ResolveUDPAddr("udp", key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer address %s: %w", key, err)
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: peer.Port})
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for peer heartbeats: %w", err)
+	}
+	return &peerChannel{key: key, conn: conn, peer: peer, done: make(chan struct{})}, nil
+}
+
+func (c *peerChannel) send(hb peerHeartbeat) error {
+	data, err := json.Marshal(hb)
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.WriteToUDP(data, c.peer)
+	return err
+}
+
+// receive calls fn for every heartbeat from the peer's address until the
+// channel is closed. Datagrams from other hosts or that don't decode are
+// dropped.
+func (c *peerChannel) receive(fn func(peerHeartbeat)) {
+	buf := make([]byte, 512)
+	for {
+		n, from, err := c.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		if !from.IP.Equal(c.peer.IP) {
+			continue
+		}
+		var hb peerHeartbeat
+		if err := json.Unmarshal(buf[:n], &hb); err != nil || hb.Node == "" {
+			continue
+		}
+		fn(hb)
+	}
+}
+
+func (c *peerChannel) close() error {
+	close(c.done)
+	return c.conn.Close()
+}
+
+// syncPeerChannel opens, replaces or closes the peer channel to match the
+// running config. It runs on the Run goroutine, which owns e.peer.
+func (e *Engine) syncPeerChannel() {
+	e.mu.Lock()
+	cfg := e.cfg
+	e.mu.Unlock()
+
+	key := peerChannelKey(cfg)
+	if e.peer != nil && e.peer.key == key {
+		return
+	}
+	e.closePeerChannel()
+	if key == "" {
+		return
+	}
+
+	ch, err := openPeerChannel(key)
+	if err != nil {
+		e.logger.Warn("Peer heartbeat channel unavailable", map[string]interface{}{"peer": key, "error": err.Error()})
+		return
+	}
+	e.peer = ch
+	e.tracker.Go("peer-heartbeat", func() {
+		e.supervisor.Run("peer-heartbeat", ch.done, func() { ch.receive(e.onPeerHeartbeat) })
+	})
+	e.logger.Info("Peer heartbeat channel open", map[string]interface{}{"peer": key})
+}
+
+func (e *Engine) closePeerChannel() {
+	if e.peer == nil {
+		return
+	}
+	_ = e.peer.close()
+	e.peer = nil
+}
+
+// sendPeerHeartbeat tells the peer this node is alive. Failures are expected
+// while the peer is down and are only logged at debug level.
+func (e *Engine) sendPeerHeartbeat(cfg *config.Config) {
+	if e.peer == nil {
+		return
+	}
+	if err := e.peer.send(peerHeartbeat{Node: cfg.Node.Name, Role: cfg.Node.Role}); err != nil {
+		e.logger.DebugFields("Peer heartbeat send failed", observability.String("peer", e.peer.key), observability.Err(err))
+	}
+}
+
+// onPeerHeartbeat records when the peer was last heard from. The metric is
+// exported whether or not this node owns the VIP, so monitoring can alert on
+// a dead secondary before it is needed.
+func (e *Engine) onPeerHeartbeat(hb peerHeartbeat) {
+	e.mu.Lock()
+	cfg := e.cfg
+	e.mu.Unlock()
+	if cfg == nil {
+		return
+	}
+	now := e.clock.Now()
+	e.metrics.Gauge("lbctl_peer_last_seen_timestamp", prometheus.Labels{
+		"node":   cfg.Node.Name,
+		"remote": hb.Node,
+	}).Set(float64(now.UnixNano()) / 1e9)
+}
@@ -0,0 +1,24 @@
+package daemon
+
+import (
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/health"
+)
+
+// ProbeAll runs every enabled health check in cfg once and returns one result
+// per backend, in config order. It needs neither a running Engine nor a
+// Scheduler, so it can vet a candidate config before it is committed.
+func ProbeAll(cfg *config.Config) []health.ProbeResult {
+	if cfg == nil {
+		return nil
+	}
+	clk := clock.Real()
+	targets := healthTargets(cfg.Services, healthDialer(cfg), clk)
+	if len(targets) == 0 {
+		return nil
+	}
+	checker := &health.TCPChecker{Dialer: health.NetDialer{}}
+	r := newResolver(cfg.Daemon.Resolver, clk)
+	return health.ProbeTargets(targets, checker, r, cfg.Daemon.Health.MaxInFlight)
+}
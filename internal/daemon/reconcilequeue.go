@@ -0,0 +1,146 @@
+package daemon
+
+import (
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/observability"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// reconcileReason is why an IPVS write was requested. Requests are coalesced
+// into one pending write of the highest reason seen.
+type reconcileReason int
+
+const (
+	reconcileNone    reconcileReason = iota
+	reconcileDrain                   // Destinations still draining; poll again
+	reconcileWeight                  // Health weights, overrides or fallback changed
+	reconcileReload                  // Config loaded or VIP acquired
+	reconcileDisable                 // Standby: remove the managed services, or zero them with daemon.warm_standby
+)
+
+func (r reconcileReason) String() string {
+	switch r {
+	case reconcileDrain:
+		return "drain"
+	case reconcileWeight:
+		return "weight"
+	case reconcileReload:
+		return "reload"
+	case reconcileDisable:
+		return "disable"
+	}
+	return "none"
+}
+
+// reconcileQueue is the single-flight executor state shared by every path
+// that writes IPVS: VIP ticks, reloads, weight changes and service reloads.
+// At most one write is pending; a request of a higher reason replaces a lower
+// one. The write runs between take and one of succeeded, failed or abandoned;
+// requests made meanwhile stay pending for the next run. It is not safe for
+// concurrent use; the engine guards it with e.mu.
+type reconcileQueue struct {
+	pending   reconcileReason
+	depth     int // Requests coalesced into pending
+	running   reconcileReason
+	attempts  int       // Consecutive failed runs
+	nextRetry time.Time // Earliest retry after a failure
+	stalled   bool      // Last run was abandoned; cleared by the next success
+}
+
+// request coalesces a write for reason into the pending one.
+func (q *reconcileQueue) request(reason reconcileReason) {
+	if reason == reconcileNone {
+		return
+	}
+	q.pending = max(q.pending, reason)
+	q.depth++
+}
+
+// replace drops the pending write in favour of reason, for a VIP role change
+// that makes it moot. reconcileNone leaves nothing pending.
+func (q *reconcileQueue) replace(reason reconcileReason) {
+	q.pending, q.depth = reconcileNone, 0
+	q.request(reason)
+}
+
+// take starts the pending write if it is a disable (disable true) or a
+// reconcile (disable false). Reconciles wait out the backoff after a failure;
+// disables don't, since they only remove services.
+func (q *reconcileQueue) take(disable bool, now time.Time) (reconcileReason, bool) {
+	if q.pending == reconcileNone || (q.pending == reconcileDisable) != disable {
+		return reconcileNone, false
+	}
+	if !disable && !now.After(q.nextRetry) {
+		return reconcileNone, false
+	}
+	q.running = q.pending
+	q.pending, q.depth = reconcileNone, 0
+	return q.running, true
+}
+
+// succeeded ends the running write.
+func (q *reconcileQueue) succeeded() {
+	q.running = reconcileNone
+	q.attempts = 0
+	q.nextRetry = time.Time{}
+	q.stalled = false
+}
+
+// failed ends the running write and queues it again, to run no earlier than
+// retryAt.
+func (q *reconcileQueue) failed(retryAt time.Time) {
+	q.request(q.running)
+	q.running = reconcileNone
+	q.attempts++
+	q.nextRetry = retryAt
+}
+
+// abandoned ends the running write without retrying it, for failures that
+// only a config reload can fix.
+func (q *reconcileQueue) abandoned() {
+	q.running = reconcileNone
+	q.attempts = 0
+	q.nextRetry = time.Time{}
+	q.stalled = true
+}
+
+// exportReconcileQueue sets lbctl_reconcile_queue_depth to the requests
+// waiting for the next IPVS write.
+func (e *Engine) exportReconcileQueue(cfg *config.Config) {
+	e.mu.Lock()
+	depth := e.reconcileQ.depth
+	e.mu.Unlock()
+	e.metrics.Gauge("lbctl_reconcile_queue_depth", prometheus.Labels{"node": cfg.Node.Name}).Set(float64(depth))
+}
+
+// reconcileFailed opens the reconcile circuit breaker once failures IPVS
+// writes in a row have failed, per daemon.reconcile_backoff.breaker_threshold.
+func (e *Engine) reconcileFailed(cfg *config.Config, failures int, err error) {
+	threshold := cfg.Daemon.ReconcileBackoff.BreakerThreshold
+	if threshold <= 0 {
+		threshold = 5
+	}
+	if e.breakerOpen || failures < threshold {
+		return
+	}
+	e.breakerOpen = true
+	e.metrics.Gauge("lbctl_reconcile_circuit_open", prometheus.Labels{"node": cfg.Node.Name}).Set(1)
+	e.logger.ErrorFields("Reconcile circuit breaker open", observability.Err(err), observability.Int("failures", failures))
+	e.auditor.Emit(observability.AuditCircuitOpen, map[string]interface{}{
+		"failures": failures,
+		"error":    err.Error(),
+	})
+}
+
+// reconcileSucceeded closes the reconcile circuit breaker.
+func (e *Engine) reconcileSucceeded(cfg *config.Config) {
+	e.metrics.Gauge("lbctl_reconcile_circuit_open", prometheus.Labels{"node": cfg.Node.Name}).Set(0)
+	if !e.breakerOpen {
+		return
+	}
+	e.breakerOpen = false
+	e.logger.Info("Reconcile circuit breaker closed", nil)
+	e.auditor.Emit(observability.AuditCircuitClosed, nil)
+}
@@ -0,0 +1,122 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/observability"
+)
+
+// scheduledChanges watches the change bundle a shell scheduled in the include
+// directory (see config.ScheduleChange). New and cancelled bundles are
+// audited, and a bundle whose time has come is committed. It reports whether
+// a bundle was committed, in which case the caller reloads. It runs on the
+// Run goroutine.
+func (e *Engine) scheduledChanges(ctx context.Context) bool {
+	e.mu.Lock()
+	cfg := e.cfg
+	e.mu.Unlock()
+
+	dir := config.IncludeDir(e.configPath, cfg)
+	if dir == "" {
+		return false
+	}
+	change, err := config.ReadScheduledChange(dir)
+	if err != nil {
+		e.logger.Warn("Failed to read scheduled config change", map[string]interface{}{"error": err.Error()})
+		return false
+	}
+
+	seen := e.scheduled
+	e.scheduled = change
+	if seen != nil && (change == nil || !change.CreatedAt.Equal(seen.CreatedAt)) {
+		e.auditor.Emit(observability.AuditConfigCancelled, scheduleFields(seen))
+	}
+	if change == nil {
+		return false
+	}
+	if seen == nil || !change.CreatedAt.Equal(seen.CreatedAt) {
+		e.auditor.Emit(observability.AuditConfigScheduled, scheduleFields(change))
+	}
+	if e.clock.Now().Before(change.At) {
+		return false
+	}
+
+	e.scheduled = nil
+	return e.activateScheduledChange(ctx, dir, change)
+}
+
+// activateScheduledChange validates change against the config on disk and
+// commits it. A bundle that fails is removed, so it isn't retried every tick;
+// that includes a bundle a later commit made stale.
+func (e *Engine) activateScheduledChange(ctx context.Context, dir string, change *config.ScheduledChange) bool {
+	activatedAt := e.clock.Now()
+	fields := scheduleFields(change)
+	fields["activated_at"] = activatedAt.UTC().Format(time.RFC3339)
+	fields["delay_ms"] = activatedAt.Sub(change.At).Milliseconds()
+
+	gen, err := e.commitScheduledChange(ctx, dir, change)
+	if err != nil {
+		if _, cerr := config.CancelScheduledChange(dir); cerr != nil {
+			e.logger.Warn("Failed to remove scheduled config change", map[string]interface{}{"error": cerr.Error()})
+		}
+		fields["error"] = err.Error()
+		if errors.Is(err, config.ErrStaleScheduledChange) {
+			fields["reason"] = "stale"
+		}
+		e.logger.Error("Scheduled config change failed; it has been dropped", fields)
+		e.auditor.Emit(observability.AuditConfigActivateFailed, fields)
+		return false
+	}
+	fields["generation"] = gen
+	e.auditor.Emit(observability.AuditConfigActivated, fields)
+	return true
+}
+
+// commitScheduledChange commits change under the include directory's commit
+// lock, so no shell commit lands between the checks and the write.
+func (e *Engine) commitScheduledChange(ctx context.Context, dir string, change *config.ScheduledChange) (uint64, error) {
+	unlock, err := config.LockCommit(dir)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	loaded, err := e.source.Load()
+	for attempt := 1; errors.Is(err, config.ErrCommitInProgress) && attempt < commitWaitAttempts && ctx.Err() == nil; attempt++ {
+		e.clock.Sleep(commitWaitDelay)
+		loaded, err = e.source.Load()
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := change.CheckBase(loaded); err != nil {
+		return 0, err
+	}
+	candidate, err := change.Candidate(loaded)
+	if err != nil {
+		return 0, err
+	}
+	if err := e.validateConfig(candidate); err != nil {
+		return 0, err
+	}
+	return config.ActivateScheduledChange(dir, change)
+}
+
+// scheduleFields returns the audit fields describing a scheduled change.
+func scheduleFields(change *config.ScheduledChange) map[string]interface{} {
+	names := make([]string, 0, len(change.Services))
+	for _, svc := range change.Services {
+		names = append(names, svc.Name)
+	}
+	return map[string]interface{}{
+		"scheduled_at":     change.At.UTC().Format(time.RFC3339),
+		"created_at":       change.CreatedAt.UTC().Format(time.RFC3339),
+		"created_by":       change.CreatedBy,
+		"base_generation":  change.BaseGeneration,
+		"services_changed": strings.Join(names, ","),
+		"services_deleted": strings.Join(change.Deleted, ","),
+	}
+}
@@ -0,0 +1,91 @@
+package daemon
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/observability"
+)
+
+// sinkCheckTimeout bounds each telemetry sink check
+const sinkCheckTimeout = 2 * time.Second
+
+// CheckSinks exercises every enabled telemetry sink in cfg (GELF, InfluxDB
+// and the Prometheus listener) concurrently and returns one result per sink,
+// in that order. Like ProbeAll it needs no running Engine, so broken
+// telemetry can be caught at deploy time.
+func CheckSinks(ctx context.Context, cfg *config.Config) []observability.SinkCheck {
+	if cfg == nil {
+		return nil
+	}
+	type check struct {
+		sink, target string
+		run          func(ctx context.Context) error
+	}
+	var checks []check
+
+	if g := cfg.Observability.Logging.GELF; g.Enabled {
+		checks = append(checks, check{"gelf", g.Protocol + "://" + net.JoinHostPort(g.Host, strconv.Itoa(g.Port)), func(context.Context) error {
+			return observability.CheckGELF(g.Host, g.Port, g.Protocol, g.Facility, sinkCheckTimeout)
+		}})
+	}
+	if in := cfg.Observability.Metrics.InfluxDB; in.Enabled {
+		checks = append(checks, check{"influxdb", in.URL, func(ctx context.Context) error {
+			p, err := observability.NewInfluxPusher(observability.InfluxConfig{
+				URL:      in.URL,
+				Token:    in.Token,
+				Org:      in.Org,
+				Bucket:   in.Bucket,
+				Interval: max(time.Duration(in.PushIntervalSeconds)*time.Second, time.Second),
+			}, nil, nil)
+			if err != nil {
+				return err
+			}
+			defer p.Stop()
+			ctx, cancel := context.WithTimeout(ctx, sinkCheckTimeout)
+			defer cancel()
+			return p.TestConnection(ctx)
+		}})
+	}
+	if pc := cfg.Observability.Metrics.Prometheus; pc.Enabled {
+		checks = append(checks, check{"prometheus", net.JoinHostPort(pc.Bind, strconv.Itoa(pc.Port)), func(context.Context) error {
+			return observability.CheckPrometheusBind(observability.PrometheusConfig{Port: pc.Port, Path: pc.Path, Bind: pc.Bind})
+		}})
+	}
+
+	results := make([]observability.SinkCheck, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := c.run(ctx)
+			results[i] = observability.SinkCheck{Sink: c.sink, Target: c.target, Err: err, Latency: time.Since(start)}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// selfTestSinks runs CheckSinks at startup and logs the outcome per sink.
+// Failures are reported but don't stop the daemon.
+func (e *Engine) selfTestSinks(ctx context.Context) {
+	e.mu.Lock()
+	cfg := e.cfg
+	e.mu.Unlock()
+
+	for _, r := range CheckSinks(ctx, cfg) {
+		fields := map[string]interface{}{"sink": r.Sink, "target": r.Target, "latency_ms": r.Latency.Milliseconds()}
+		if r.Err != nil {
+			fields["error"] = r.Err.Error()
+			e.logger.Warn("Telemetry sink self-test failed", fields)
+			continue
+		}
+		e.logger.Info("Telemetry sink self-test passed", fields)
+	}
+}
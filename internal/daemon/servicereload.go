@@ -0,0 +1,243 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+	"github.com/malindarathnayake/LibraFlux/internal/health"
+	"github.com/malindarathnayake/LibraFlux/internal/observability"
+)
+
+// serviceApplier is implemented by reconcilers that can reconcile a single
+// service without diffing every other service in the config.
+type serviceApplier interface {
+	ApplyService(current, desired *config.Service, vips []string) error
+}
+
+type serviceReload struct {
+	name string
+	done chan error
+}
+
+// ReloadService reloads one service from the config on disk: it is
+// revalidated against the running config, and only its health targets and
+// IPVS services are replaced. Every other service keeps running as last
+// loaded. Removing the service from disk removes it from the daemon. The
+// reload runs on Run's goroutine, so it waits until Run picks it up or ctx
+// is done.
+func (e *Engine) ReloadService(ctx context.Context, name string) error {
+	req := serviceReload{name: name, done: make(chan error, 1)}
+	select {
+	case e.serviceReloadCh <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// serviceReloadRequests handles the reload requests a shell left in the
+// include directory (see config.RequestServiceReload). It reports whether
+// there were any.
+func (e *Engine) serviceReloadRequests(ctx context.Context) bool {
+	e.mu.Lock()
+	cfg := e.cfg
+	e.mu.Unlock()
+
+	dir := config.IncludeDir(e.configPath, cfg)
+	if dir == "" {
+		return false
+	}
+	names, err := config.PendingServiceReloads(dir)
+	if err != nil {
+		e.logger.Warn("Failed to read service reload requests", map[string]interface{}{"error": err.Error()})
+		return false
+	}
+	for _, name := range names {
+		if err := config.ClearServiceReload(dir, name); err != nil {
+			// Skip it rather than reload the service again on every tick
+			e.logger.Warn("Failed to clear service reload request", map[string]interface{}{"service_name": name, "error": err.Error()})
+			continue
+		}
+		_ = e.reloadService(ctx, name)
+	}
+	return len(names) > 0
+}
+
+func (e *Engine) reloadService(ctx context.Context, name string) error {
+	e.logger.Info("Service reload requested", map[string]interface{}{"service_name": name})
+	err := e.applyServiceReload(ctx, name)
+	if err != nil {
+		e.logger.Error("Service reload failed; keeping previous service config", map[string]interface{}{
+			"service_name": name,
+			"error":        err.Error(),
+		})
+	}
+	return err
+}
+
+func (e *Engine) applyServiceReload(ctx context.Context, name string) error {
+	loaded, err := e.source.Load()
+	for attempt := 1; errors.Is(err, config.ErrCommitInProgress) && attempt < commitWaitAttempts && ctx.Err() == nil; attempt++ {
+		e.clock.Sleep(commitWaitDelay)
+		loaded, err = e.source.Load()
+	}
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	running := e.cfg
+	e.mu.Unlock()
+	if running == nil {
+		return fmt.Errorf("missing config")
+	}
+	current := findService(running, name)
+	desired := findService(loaded, name)
+	if current == nil && desired == nil {
+		return errdefs.WithCode(errdefs.CodeNotFound, fmt.Errorf("unknown service: %s", name))
+	}
+
+	// Only this service changes; globals and other services stay as running
+	next := *running
+	next.Services = make([]config.Service, 0, len(running.Services)+1)
+	for _, svc := range running.Services {
+		if svc.Name != name {
+			next.Services = append(next.Services, svc)
+		} else if desired != nil {
+			next.Services = append(next.Services, *desired)
+		}
+	}
+	if current == nil {
+		next.Services = append(next.Services, *desired)
+	}
+	if err := e.validateConfig(&next); err != nil {
+		return err
+	}
+	hash, err := hashConfig(&next)
+	if err != nil {
+		return err
+	}
+	desired = findService(&next, name)
+
+	var targets []health.Target
+	if desired != nil {
+		targets = healthTargets([]config.Service{*desired}, healthDialer(&next), e.clock)
+	}
+
+	e.mu.Lock()
+	e.cfg = &next
+	e.cfgHash = hash
+	for key := range e.backendWeights {
+		if key.Service == name {
+			delete(e.backendWeights, key)
+		}
+	}
+	for key := range e.backendStates {
+		if key.Service == name {
+			delete(e.backendStates, key)
+		}
+	}
+	delete(e.lastHealthy, name)
+	keep := make(map[health.BackendKey]bool, len(targets))
+	for i := range targets {
+		keep[targets[i].Key] = true
+		if o, ok := e.overrides[targets[i].Key]; ok {
+			targets[i].Override = o.Mode
+			targets[i].OverrideExpires = o.Expires
+		}
+	}
+	for key := range e.overrides {
+		if key.Service == name && !keep[key] {
+			delete(e.overrides, key)
+		}
+	}
+	s := e.scheduler
+	active := e.active
+	e.mu.Unlock()
+
+	if s != nil {
+		if err := s.ReplaceService(name, targets); err != nil {
+			e.logger.Error("Failed to replace health targets", map[string]interface{}{"service_name": name, "error": err.Error()})
+		}
+	} else if len(targets) > 0 {
+		if err := e.startHealthScheduler(); err != nil {
+			e.logger.Error("Failed to start health scheduler", map[string]interface{}{"error": err.Error()})
+		}
+	}
+	if e.masquerade != nil {
+		if err := e.masquerade.Apply(&next); err != nil {
+			e.logger.Error("Failed to apply masquerade rules", map[string]interface{}{"mode": next.Mode, "error": err.Error()})
+		}
+	}
+
+	event, fields := observability.AuditServiceReloaded, map[string]interface{}{"service_name": name, "config_hash": hash}
+	switch {
+	case current == nil:
+		event = observability.AuditServiceAdded
+	case desired == nil:
+		event = observability.AuditServiceRemoved
+	}
+	if desired != nil {
+		fields["backends_count"] = len(desired.Backends)
+		fields = withServiceLabels(desired.Labels, fields)
+	}
+	e.auditor.Emit(event, fields)
+
+	if active {
+		e.applyReloadedService(ctx, &next, name, current, desired)
+	} else {
+		e.requestStandbyOrReconcile(ctx)
+	}
+	return nil
+}
+
+// applyReloadedService reconciles the IPVS services of one reloaded service.
+// Reconcilers that can't scope a reconcile to one service get a full one,
+// which leaves the unchanged services alone anyway.
+func (e *Engine) applyReloadedService(ctx context.Context, cfg *config.Config, name string, current, desired *config.Service) {
+	sa, ok := e.reconciler.(serviceApplier)
+	if !ok {
+		e.mu.Lock()
+		e.reconcileQ.request(reconcileReload)
+		e.mu.Unlock()
+		e.tryReconcile(ctx)
+		return
+	}
+
+	var want *config.Service
+	if desired != nil {
+		sub := *cfg
+		sub.Services = []config.Service{*desired}
+		e.mu.Lock()
+		weights := make(map[health.BackendKey]int, len(e.backendWeights))
+		for k, v := range e.backendWeights {
+			weights[k] = v
+		}
+		e.mu.Unlock()
+		want = &e.applyMinHealthy(&sub, applyEffectiveWeights(sub.Services, weights))[0]
+	}
+
+	if err := sa.ApplyService(current, want, cfg.Network.Frontend.AllVIPs()); err != nil {
+		// The next full reconcile retries with backoff
+		e.logger.Error("Service reconcile failed", map[string]interface{}{"service_name": name, "error": err.Error()})
+		e.mu.Lock()
+		e.reconcileQ.request(reconcileReload)
+		e.mu.Unlock()
+		return
+	}
+	e.exportForeign(cfg)
+	e.exportOps(cfg)
+	if d, ok := e.reconciler.(drainer); ok && d.Draining() > 0 {
+		e.mu.Lock()
+		e.reconcileQ.request(reconcileDrain)
+		e.mu.Unlock()
+	}
+}
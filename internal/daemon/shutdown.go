@@ -0,0 +1,166 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/observability"
+)
+
+// Shutdown policies, see config.DaemonConfig.ShutdownPolicy.
+const (
+	shutdownPreserve = "preserve"
+	shutdownTeardown = "teardown"
+	shutdownDrain    = "drain"
+)
+
+// defaultShutdownDrainTimeout bounds a drain when
+// daemon.shutdown_drain_timeout_ms is unset.
+const defaultShutdownDrainTimeout = 30 * time.Second
+
+// shutdownDrainPoll is how often a shutdown drain checks for connections.
+const shutdownDrainPoll = time.Second
+
+// shutdown applies daemon.shutdown_policy to the managed IPVS services when
+// Run's context ends, and audits what it did. It runs on the Run goroutine
+// after the main loop has stopped.
+func (e *Engine) shutdown() {
+	e.mu.Lock()
+	cfg := e.cfg
+	active := e.active
+	e.mu.Unlock()
+	if cfg == nil {
+		return
+	}
+
+	policy := strings.ToLower(cfg.Daemon.ShutdownPolicy)
+	if policy == "" {
+		policy = shutdownPreserve
+	}
+	fields := map[string]interface{}{"policy": policy}
+	var errs []error
+	switch policy {
+	case shutdownDrain:
+		// A standby takes no traffic, so there is nothing to wait for
+		if active {
+			remaining, waited, handedOff, err := e.drainForShutdown(cfg)
+			fields["vip_handed_off"] = handedOff
+			fields["waited"] = waited.String()
+			if remaining >= 0 {
+				fields["active_connections"] = remaining
+			}
+			errs = append(errs, err)
+		}
+		fallthrough
+	case shutdownTeardown:
+		errs = append(errs, e.reconciler.Apply(nil, cfg.Network.Frontend.AllVIPs()))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		fields["error"] = err.Error()
+		e.logger.Error("Shutdown policy failed; IPVS services may remain", fields)
+	} else {
+		e.logger.Info("Shutdown policy applied", fields)
+	}
+	e.auditor.Emit(observability.AuditShutdown, fields)
+}
+
+// drainForShutdown hands the VIP off to the peer, then sets every
+// destination's weight to 0 and waits until no connections remain or
+// daemon.shutdown_drain_timeout_ms passes. Zeroing weights first would
+// refuse the new connections still arriving at this node while it holds the
+// VIP. It returns the connections still active, or -1 when the reconciler
+// can't count them, in which case it waits out the timeout.
+func (e *Engine) drainForShutdown(cfg *config.Config) (int, time.Duration, bool, error) {
+	timeout := defaultShutdownDrainTimeout
+	if cfg.Daemon.ShutdownDrainTimeoutMS > 0 {
+		timeout = time.Duration(cfg.Daemon.ShutdownDrainTimeoutMS) * time.Millisecond
+	}
+	// Keep the service manager from killing the daemon mid-drain
+	e.notify(fmt.Sprintf("EXTEND_TIMEOUT_USEC=%d", (timeout + 10*time.Second).Microseconds()))
+	e.logger.Info("Draining IPVS services before shutdown", map[string]interface{}{"timeout": timeout.String()})
+
+	start := e.clock.Now()
+	handedOff := e.handOffVIP(cfg, start.Add(timeout))
+	if err := e.reconciler.Apply(zeroWeights(cfg.Services), cfg.Network.Frontend.AllVIPs()); err != nil {
+		return -1, e.clock.Now().Sub(start), handedOff, fmt.Errorf("failed to zero weights: %w", err)
+	}
+	for {
+		remaining := e.activeConnections(cfg)
+		waited := e.clock.Now().Sub(start)
+		if remaining == 0 || waited >= timeout {
+			return remaining, waited, handedOff, nil
+		}
+		e.notify("WATCHDOG=1")
+		e.clock.Sleep(min(shutdownDrainPoll, timeout-waited))
+	}
+}
+
+// handOffVIP lowers the node's VRRP priority the way maintenance does and
+// waits until deadline for the peer to take the VIP over. It reports whether
+// the VIP left the node; without a priority setter, or with no peer to take
+// it, the drain goes on with the VIP held.
+func (e *Engine) handOffVIP(cfg *config.Config, deadline time.Time) bool {
+	if _, ok := e.preempter.(prioritySetter); !ok {
+		e.logger.Warn("Cannot lower the VRRP priority; draining while holding the VIP", nil)
+		return false
+	}
+	if err := e.syncVRRPPriority(cfg, true); err != nil {
+		return false
+	}
+	e.mu.Lock()
+	status := e.maintenance
+	e.mu.Unlock()
+	status.ShutdownHandoff = true
+	if err := WriteMaintenance(e.stateDir, status); err != nil {
+		e.logger.Warn("Failed to record maintenance mode", map[string]interface{}{"state_dir": e.stateDir, "error": err.Error()})
+	}
+
+	for {
+		held, err := e.vipHeld(cfg)
+		if err == nil && !held {
+			e.logger.Info("VIP handed off to the peer", nil)
+			return true
+		}
+		now := e.clock.Now()
+		if !now.Before(deadline) {
+			e.logger.Warn("VIP still held after the drain timeout; draining anyway", nil)
+			return false
+		}
+		e.notify("WATCHDOG=1")
+		e.clock.Sleep(min(shutdownDrainPoll, deadline.Sub(now)))
+	}
+}
+
+// activeConnections sums the active connections of the IPVS services on
+// the managed VIPs, or returns -1 if the reconciler can't read them.
+func (e *Engine) activeConnections(cfg *config.Config) int {
+	sr, ok := e.reconciler.(ipvsStatsReader)
+	if !ok {
+		return -1
+	}
+	stats, err := sr.Stats()
+	if err != nil {
+		return -1
+	}
+	managed := make(map[string]bool)
+	for _, vip := range cfg.Network.Frontend.AllVIPs() {
+		if ip := net.ParseIP(vip); ip != nil {
+			managed[ip.String()] = true
+		}
+	}
+	total := 0
+	for _, s := range stats {
+		if !managed[s.Service.Address.String()] {
+			continue
+		}
+		for _, d := range s.Destinations {
+			total += d.ActiveConns
+		}
+	}
+	return total
+}
@@ -0,0 +1,283 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/ipvs"
+	"github.com/malindarathnayake/LibraFlux/internal/observability"
+)
+
+// Soak defaults, see SoakOptions
+const (
+	DefaultSoakVIP            = "198.51.100.250" // TEST-NET-2, never a real VIP
+	DefaultSoakInterval       = 100 * time.Millisecond
+	DefaultSoakMaxReconcile   = 5 * time.Second
+	DefaultSoakMaxServices    = 32
+	DefaultSoakMaxBackends    = 16
+	DefaultSoakGoroutineSlack = 16
+)
+
+// SoakReconciler is what Soak drives: a reconciler that can also plan and
+// read IPVS back, such as *ipvs.Reconciler.
+type SoakReconciler interface {
+	IPVSReconciler
+	planner
+	serviceLister
+}
+
+// serviceLister is implemented by reconcilers that can list the IPVS
+// services in the kernel.
+type serviceLister interface {
+	Services() ([]*ipvs.Service, error)
+}
+
+// SoakOptions tunes Soak. Zero values take the Default* constants.
+type SoakOptions struct {
+	Duration       time.Duration // How long to churn; 0 runs until ctx is done
+	Interval       time.Duration // Pause between mutations
+	Seed           int64         // Seeds the mutations, so a failing run can be replayed
+	VIP            string        // Scratch VIP the services are placed on
+	MaxReconcile   time.Duration // Longest a single reconcile may take
+	MaxServices    int
+	MaxBackends    int // Per service
+	GoroutineSlack int // Goroutines allowed above the count at start
+	Clock          clock.Clock
+	Logger         *observability.Logger
+}
+
+// SoakResult summarizes a Soak run.
+type SoakResult struct {
+	Seed         int64
+	Iterations   int
+	Mutations    map[string]int // By kind
+	MaxReconcile time.Duration  // Slowest reconcile seen
+	Services     int            // Services configured when the run stopped
+	Violation    string         // The invariant that failed, if any
+	Duration     time.Duration
+}
+
+// Soak qualifies a reconciler for long-running use. It keeps mutating a
+// scratch config (adding and removing services and backends, changing
+// weights) and reconciles after each change, failing on the first broken
+// invariant:
+//
+//   - no drift: a plan made right after a reconcile is empty
+//   - no leaks: IPVS holds exactly the configured services on the scratch
+//     VIP, and the goroutine count stays near where it started
+//   - bounded reconcile time: no reconcile takes over opts.MaxReconcile
+//
+// Everything on the scratch VIP is removed before Soak returns, so against
+// the kernel opts.VIP must not be one in use.
+func Soak(ctx context.Context, rec SoakReconciler, opts SoakOptions) (*SoakResult, error) {
+	if opts.VIP == "" {
+		opts.VIP = DefaultSoakVIP
+	}
+	if net.ParseIP(opts.VIP) == nil {
+		return nil, fmt.Errorf("soak: invalid VIP %q", opts.VIP)
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultSoakInterval
+	}
+	if opts.MaxReconcile <= 0 {
+		opts.MaxReconcile = DefaultSoakMaxReconcile
+	}
+	if opts.MaxServices <= 0 {
+		opts.MaxServices = DefaultSoakMaxServices
+	}
+	if opts.MaxBackends <= 0 {
+		opts.MaxBackends = DefaultSoakMaxBackends
+	}
+	if opts.GoroutineSlack <= 0 {
+		opts.GoroutineSlack = DefaultSoakGoroutineSlack
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.Real()
+	}
+	if opts.Logger == nil {
+		opts.Logger = observability.NewLogger(observability.InfoLevel)
+	}
+	if opts.Seed == 0 {
+		opts.Seed = opts.Clock.Now().UnixNano()
+	}
+
+	s := &soak{
+		rec:  rec,
+		opts: opts,
+		vips: []string{opts.VIP},
+		rng:  rand.New(rand.NewSource(opts.Seed)),
+		res:  &SoakResult{Seed: opts.Seed, Mutations: make(map[string]int)},
+	}
+	start := opts.Clock.Now()
+	baseline := runtime.NumGoroutine()
+	opts.Logger.Info("Soak starting", map[string]interface{}{"seed": opts.Seed, "vip": opts.VIP, "duration": opts.Duration.String()})
+
+	err := s.run(ctx, start, baseline)
+	// Tear down whatever the run left, even after a violation
+	s.services = nil
+	if terr := s.reconcile(); err == nil && terr != nil {
+		err = terr
+	}
+	s.res.Duration = opts.Clock.Now().Sub(start)
+	if err != nil {
+		s.res.Violation = err.Error()
+		opts.Logger.Error("Soak failed", map[string]interface{}{"seed": opts.Seed, "iterations": s.res.Iterations, "error": err.Error()})
+		return s.res, err
+	}
+	opts.Logger.Info("Soak passed", map[string]interface{}{"seed": opts.Seed, "iterations": s.res.Iterations, "max_reconcile": s.res.MaxReconcile.String()})
+	return s.res, nil
+}
+
+type soak struct {
+	rec      SoakReconciler
+	opts     SoakOptions
+	vips     []string
+	rng      *rand.Rand
+	res      *SoakResult
+	services []config.Service
+	next     int // Numbers service names and backend addresses
+}
+
+func (s *soak) run(ctx context.Context, start time.Time, baseline int) error {
+	for {
+		if s.opts.Duration > 0 && s.opts.Clock.Now().Sub(start) >= s.opts.Duration {
+			return nil
+		}
+		s.res.Mutations[s.mutate()]++
+		s.res.Iterations++
+		s.res.Services = len(s.services)
+		if err := s.reconcile(); err != nil {
+			return fmt.Errorf("iteration %d: %w", s.res.Iterations, err)
+		}
+		if n := runtime.NumGoroutine(); n > baseline+s.opts.GoroutineSlack {
+			return fmt.Errorf("iteration %d: goroutine leak: %d running, %d at start", s.res.Iterations, n, baseline)
+		}
+
+		t := s.opts.Clock.NewTimer(s.opts.Interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil
+		case <-t.C():
+		}
+	}
+}
+
+// reconcile applies the current services and checks the invariants
+func (s *soak) reconcile() error {
+	start := s.opts.Clock.Now()
+	if err := s.rec.Apply(s.services, s.vips); err != nil {
+		return fmt.Errorf("reconcile: %w", err)
+	}
+	took := s.opts.Clock.Now().Sub(start)
+	s.res.MaxReconcile = max(s.res.MaxReconcile, took)
+	if took > s.opts.MaxReconcile {
+		return fmt.Errorf("reconcile took %s, over %s", took, s.opts.MaxReconcile)
+	}
+
+	plan, err := s.rec.Plan(s.services, s.vips)
+	if err != nil {
+		return fmt.Errorf("plan: %w", err)
+	}
+	if !plan.Empty() {
+		return fmt.Errorf("drift after reconcile:\n%s", plan)
+	}
+
+	current, err := s.rec.Services()
+	if err != nil {
+		return fmt.Errorf("read IPVS services: %w", err)
+	}
+	vip := net.ParseIP(s.opts.VIP)
+	held := 0
+	for _, svc := range current {
+		if svc.Address.Equal(vip) {
+			held++
+		}
+	}
+	if held != len(s.services) {
+		return fmt.Errorf("leak: %d IPVS services on %s, want %d", held, s.opts.VIP, len(s.services))
+	}
+	return nil
+}
+
+// mutate makes one random change to the scratch config and returns its kind
+func (s *soak) mutate() string {
+	for {
+		switch s.rng.Intn(5) {
+		case 0:
+			if len(s.services) < s.opts.MaxServices {
+				s.services = append(s.services, s.newService())
+				return "add_service"
+			}
+		case 1:
+			if len(s.services) > 1 {
+				i := s.rng.Intn(len(s.services))
+				s.services = append(s.services[:i:i], s.services[i+1:]...)
+				return "remove_service"
+			}
+		case 2:
+			if svc := s.pick(); svc != nil && len(svc.Backends) < s.opts.MaxBackends {
+				svc.Backends = append(append([]config.Backend(nil), svc.Backends...), s.newBackend(svc))
+				return "add_backend"
+			}
+		case 3:
+			if svc := s.pick(); svc != nil && len(svc.Backends) > 1 {
+				i := s.rng.Intn(len(svc.Backends))
+				svc.Backends = append(svc.Backends[:i:i], svc.Backends[i+1:]...)
+				return "remove_backend"
+			}
+		case 4:
+			if svc := s.pick(); svc != nil {
+				backends := append([]config.Backend(nil), svc.Backends...)
+				backends[s.rng.Intn(len(backends))].Weight = 1 + s.rng.Intn(100)
+				svc.Backends = backends
+				return "reweight_backend"
+			}
+		}
+	}
+}
+
+func (s *soak) pick() *config.Service {
+	if len(s.services) == 0 {
+		return nil
+	}
+	return &s.services[s.rng.Intn(len(s.services))]
+}
+
+func (s *soak) newService() config.Service {
+	s.next++
+	used := make(map[int]bool, len(s.services))
+	for _, svc := range s.services {
+		used[svc.Ports[0]] = true
+	}
+	port := 10000
+	for used[port] {
+		port++
+	}
+	svc := config.Service{
+		Name:      fmt.Sprintf("soak-%d", s.next),
+		Protocol:  "tcp",
+		Ports:     []int{port},
+		Scheduler: "wrr",
+	}
+	svc.Backends = []config.Backend{s.newBackend(&svc)}
+	return svc
+}
+
+// newBackend returns a backend whose address svc doesn't use yet
+func (s *soak) newBackend(svc *config.Service) config.Backend {
+	for {
+		s.next++
+		addr := fmt.Sprintf("10.255.%d.%d", s.next/250%256, 1+s.next%250)
+		if !slices.ContainsFunc(svc.Backends, func(b config.Backend) bool { return b.Address == addr }) {
+			return config.Backend{Address: addr, Weight: 1 + s.rng.Intn(100)}
+		}
+	}
+}
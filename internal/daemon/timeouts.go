@@ -0,0 +1,31 @@
+package daemon
+
+import (
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/ipvs"
+)
+
+// timeoutSetter is implemented by reconcilers that can set the kernel's IPVS
+// connection timeouts.
+type timeoutSetter interface {
+	SetTimeouts(want ipvs.Timeouts) error
+}
+
+// syncIPVSTimeouts applies daemon.ipvs_timeouts on both nodes, so the standby
+// ages synced connections the same way. It runs on the Run goroutine; a
+// failed change is retried on the next tick.
+func (e *Engine) syncIPVSTimeouts(cfg *config.Config) {
+	ts, ok := e.reconciler.(timeoutSetter)
+	if !ok {
+		return
+	}
+	want := ipvs.TimeoutsFromConfig(cfg.Daemon.IPVSTimeouts)
+	if want == e.ipvsTimeouts {
+		return
+	}
+	if err := ts.SetTimeouts(want); err != nil {
+		e.logger.Warn("Failed to set IPVS timeouts", map[string]interface{}{"error": err.Error()})
+		return
+	}
+	e.ipvsTimeouts = want
+}
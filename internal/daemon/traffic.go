@@ -0,0 +1,151 @@
+package daemon
+
+import (
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/ipvs"
+	"github.com/malindarathnayake/LibraFlux/internal/observability"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Quota names, as used in the quota label and audit events
+const (
+	quotaBytes       = "bytes_per_second"
+	quotaPackets     = "packets_per_second"
+	quotaConnections = "connections_per_second"
+)
+
+// serviceCounters are the IPVS counters of a config service, summed over its
+// ports.
+type serviceCounters struct {
+	conns, pktsIn, pktsOut, bytesIn, bytesOut uint64
+}
+
+// trafficSample is the previous poll of the counters; rates are the deltas
+// between two polls.
+type trafficSample struct {
+	at       time.Time
+	counters map[string]serviceCounters
+}
+
+// pollTraffic turns the service counters of stats into per-service rate
+// gauges and checks them against the configured quotas. Series of services
+// that are gone since the last poll are removed. It runs on the Run goroutine
+// while this node owns the VIP.
+func (e *Engine) pollTraffic(cfg *config.Config, stats []ipvs.ServiceStats) {
+	byAddr := serviceByAddr(cfg)
+	counters := make(map[string]serviceCounters)
+	for _, st := range stats {
+		s := st.Service
+		name, ok := byAddr[serviceAddr(s.Address, s.Protocol, s.Port)]
+		if !ok {
+			continue
+		}
+		c := counters[name]
+		c.conns += s.Stats.Connections
+		c.pktsIn += s.Stats.PacketsIn
+		c.pktsOut += s.Stats.PacketsOut
+		c.bytesIn += s.Stats.BytesIn
+		c.bytesOut += s.Stats.BytesOut
+		counters[name] = c
+	}
+
+	now := e.clock.Now()
+	prev := e.traffic
+	e.traffic = &trafficSample{at: now, counters: counters}
+	if prev == nil {
+		return
+	}
+	for name := range prev.counters {
+		if _, ok := counters[name]; !ok {
+			e.deleteTraffic(cfg, name)
+		}
+	}
+	elapsed := now.Sub(prev.at).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	for _, svc := range cfg.Services {
+		cur, ok := counters[svc.Name]
+		if !ok {
+			continue
+		}
+		last, ok := prev.counters[svc.Name]
+		if !ok {
+			continue
+		}
+		// Counters go backwards when a service is recreated; skip a poll
+		// rather than report a bogus rate.
+		if cur.conns < last.conns || cur.pktsIn < last.pktsIn || cur.pktsOut < last.pktsOut ||
+			cur.bytesIn < last.bytesIn || cur.bytesOut < last.bytesOut {
+			continue
+		}
+
+		rate := func(cur, last uint64) float64 { return float64(cur-last) / elapsed }
+		bytesIn, bytesOut := rate(cur.bytesIn, last.bytesIn), rate(cur.bytesOut, last.bytesOut)
+		pktsIn, pktsOut := rate(cur.pktsIn, last.pktsIn), rate(cur.pktsOut, last.pktsOut)
+		conns := rate(cur.conns, last.conns)
+
+		node := cfg.Node.Name
+		e.metrics.Gauge("lbctl_service_bytes_per_second", prometheus.Labels{"node": node, "service": svc.Name, "direction": "in"}).Set(bytesIn)
+		e.metrics.Gauge("lbctl_service_bytes_per_second", prometheus.Labels{"node": node, "service": svc.Name, "direction": "out"}).Set(bytesOut)
+		e.metrics.Gauge("lbctl_service_packets_per_second", prometheus.Labels{"node": node, "service": svc.Name, "direction": "in"}).Set(pktsIn)
+		e.metrics.Gauge("lbctl_service_packets_per_second", prometheus.Labels{"node": node, "service": svc.Name, "direction": "out"}).Set(pktsOut)
+		e.metrics.Gauge("lbctl_service_connections_per_second", prometheus.Labels{"node": node, "service": svc.Name}).Set(conns)
+
+		e.checkQuota(cfg, svc.Name, quotaBytes, svc.Quota.BytesPerSecond, bytesIn+bytesOut)
+		e.checkQuota(cfg, svc.Name, quotaPackets, svc.Quota.PacketsPerSecond, pktsIn+pktsOut)
+		e.checkQuota(cfg, svc.Name, quotaConnections, svc.Quota.ConnectionsPerSecond, conns)
+	}
+}
+
+// checkQuota updates the quota gauge and emits an audit event when a service
+// crosses its limit in either direction. A limit of 0 is disabled.
+func (e *Engine) checkQuota(cfg *config.Config, service, quota string, limit int64, rate float64) {
+	key := service + "/" + quota
+	was := e.quotaExceeded[key]
+	exceeded := limit > 0 && rate > float64(limit)
+
+	v := 0.0
+	if exceeded {
+		v = 1
+	}
+	e.metrics.Gauge("lbctl_service_quota_exceeded", prometheus.Labels{"node": cfg.Node.Name, "service": service, "quota": quota}).Set(v)
+	if exceeded == was {
+		return
+	}
+
+	fields := map[string]interface{}{"service_name": service, "quota": quota, "limit": limit, "rate": rate}
+	if exceeded {
+		e.quotaExceeded[key] = true
+		e.logger.Warn("Service quota exceeded", fields)
+		e.auditor.Emit(observability.AuditQuotaExceeded, fields)
+		return
+	}
+	delete(e.quotaExceeded, key)
+	e.logger.Info("Service quota cleared", fields)
+	e.auditor.Emit(observability.AuditQuotaCleared, fields)
+}
+
+// deleteTraffic removes the rate and quota series of a service that is gone,
+// without auditing its quotas as cleared.
+func (e *Engine) deleteTraffic(cfg *config.Config, service string) {
+	node := cfg.Node.Name
+	for _, dir := range []string{"in", "out"} {
+		e.metrics.DeleteGauge("lbctl_service_bytes_per_second", prometheus.Labels{"node": node, "service": service, "direction": dir})
+		e.metrics.DeleteGauge("lbctl_service_packets_per_second", prometheus.Labels{"node": node, "service": service, "direction": dir})
+	}
+	e.metrics.DeleteGauge("lbctl_service_connections_per_second", prometheus.Labels{"node": node, "service": service})
+	for _, quota := range []string{quotaBytes, quotaPackets, quotaConnections} {
+		e.metrics.DeleteGauge("lbctl_service_quota_exceeded", prometheus.Labels{"node": node, "service": service, "quota": quota})
+		delete(e.quotaExceeded, service+"/"+quota)
+	}
+}
+
+// resetTraffic drops the last sample, e.g. after losing the VIP, so rates
+// restart cleanly once it is reacquired.
+func (e *Engine) resetTraffic() {
+	e.traffic = nil
+}
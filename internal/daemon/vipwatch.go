@@ -0,0 +1,68 @@
+package daemon
+
+import (
+	"context"
+	"net"
+
+	"github.com/malindarathnayake/LibraFlux/internal/system"
+)
+
+// vipWatch is the address change subscription that lets the engine see the
+// VIP move within milliseconds. VIP polling carries on underneath it, so
+// losing the subscription only costs latency.
+type vipWatch struct {
+	changes <-chan system.AddressChange // nil while not subscribed
+	done    chan struct{}
+	failed  bool // Warned that the subscription is unavailable
+}
+
+// syncVIPWatch subscribes to address changes when the network manager
+// supports it and no subscription is open. Run calls it at startup and on
+// every reconcile tick, so a broken subscription is retried.
+func (e *Engine) syncVIPWatch() {
+	w, ok := e.network.(system.AddressWatcher)
+	if !ok || e.vipWatch.changes != nil {
+		return
+	}
+	done := make(chan struct{})
+	changes, err := w.WatchAddresses(done)
+	if err != nil {
+		close(done)
+		if !e.vipWatch.failed {
+			e.logger.Warn("Address change subscription unavailable; detecting VIP moves by polling", map[string]interface{}{"error": err.Error()})
+			e.vipWatch.failed = true
+		}
+		return
+	}
+	if e.vipWatch.failed {
+		e.logger.Info("Address change subscription restored", nil)
+	}
+	e.vipWatch = vipWatch{changes: changes, done: done}
+}
+
+// closeVIPWatch ends the subscription.
+func (e *Engine) closeVIPWatch() {
+	if e.vipWatch.changes == nil {
+		return
+	}
+	close(e.vipWatch.done)
+	e.vipWatch = vipWatch{failed: e.vipWatch.failed}
+}
+
+// onAddressChange checks the VIP at once when change concerns it. ok is
+// false when the subscription has ended.
+func (e *Engine) onAddressChange(ctx context.Context, change system.AddressChange, ok bool) {
+	if !ok {
+		e.closeVIPWatch()
+		e.logger.Warn("Address change subscription ended; detecting VIP moves by polling", nil)
+		e.vipWatch.failed = true
+		return
+	}
+	e.mu.Lock()
+	cfg := e.cfg
+	e.mu.Unlock()
+	if cfg == nil || !change.IP.Equal(net.ParseIP(cfg.Network.Frontend.VIP)) {
+		return
+	}
+	e.checkVIP(ctx)
+}
@@ -0,0 +1,33 @@
+package daemon
+
+import (
+	"fmt"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/system"
+)
+
+// vipHeld reports whether this node holds the VIP, before maintenance is
+// applied. With vrrp.state_source frr, FRR's VRRP state decides rather
+// than the address: while VRRP flaps the VIP can be seen on both nodes, or
+// on neither, for a moment, and each would reconcile on what it saw. FRR's
+// Master/Backup view changes once per transition, so only one node acts on
+// it. A failed query fails the check, leaving the role as it was.
+func (e *Engine) vipHeld(cfg *config.Config) (bool, error) {
+	if cfg.VRRP.StateSource != config.VRRPStateSourceFRR {
+		return e.network.CheckVIPPresent(cfg.Network.Frontend.VIP)
+	}
+	state, err := e.vrrpState.VRRPState(cfg)
+	if err != nil {
+		return false, fmt.Errorf("failed to read VRRP state from FRR: %w", err)
+	}
+	if state != e.lastVRRPState {
+		e.logger.Info("VRRP state changed", map[string]interface{}{
+			"from": e.lastVRRPState,
+			"to":   state,
+			"vrid": cfg.VRRP.VRID,
+		})
+		e.lastVRRPState = state
+	}
+	return state == system.VRRPStateMaster, nil
+}
@@ -0,0 +1,106 @@
+package errdefs
+
+import (
+	"errors"
+	"sort"
+)
+
+// Code identifies a kind of failure for runbooks and automation. Codes are
+// stable: one is never reused for another meaning, and messages may change
+// without their code changing.
+type Code string
+
+// Error codes. 1xxx are problems with what was asked, 2xxx with who asked,
+// 3xxx with the daemon's state at the time.
+const (
+	CodeInvalidConfig     Code = "LBCTL-E1001" // Config failed validation
+	CodeUsage             Code = "LBCTL-E1002" // Malformed command or request
+	CodeUnknownCommand    Code = "LBCTL-E1003"
+	CodeNotFound          Code = "LBCTL-E1004" // Named service or backend doesn't exist
+	CodePermission        Code = "LBCTL-E2001"
+	CodeLockHeld          Code = "LBCTL-E2002" // Another session holds the config lock
+	CodeForbidden         Code = "LBCTL-E2003" // Not allowed over this API
+	CodeTransient         Code = "LBCTL-E3001" // Expected to clear on retry
+	CodeDaemonUnreachable Code = "LBCTL-E3002"
+	CodeReloadRejected    Code = "LBCTL-E3003" // The daemon didn't apply a config
+	CodeConflict          Code = "LBCTL-E3004" // Not possible in the daemon's current state
+	CodeUnknown           Code = "LBCTL-E9999" // Not classified
+)
+
+var codeDescriptions = map[Code]string{
+	CodeInvalidConfig:     "configuration is invalid",
+	CodeUsage:             "malformed command or request",
+	CodeUnknownCommand:    "unknown command",
+	CodeNotFound:          "service or backend not found",
+	CodePermission:        "permission denied",
+	CodeLockHeld:          "configuration locked by another session",
+	CodeForbidden:         "operation not allowed over this API",
+	CodeTransient:         "temporary failure; retry",
+	CodeDaemonUnreachable: "daemon not reachable",
+	CodeReloadRejected:    "daemon did not apply the configuration",
+	CodeConflict:          "not possible in the daemon's current state",
+	CodeUnknown:           "unclassified error",
+}
+
+// Description is a short, fixed explanation of c.
+func (c Code) Description() string {
+	if d, ok := codeDescriptions[c]; ok {
+		return d
+	}
+	return codeDescriptions[CodeUnknown]
+}
+
+// Codes lists every code in order.
+func Codes() []Code {
+	codes := make([]Code, 0, len(codeDescriptions))
+	for c := range codeDescriptions {
+		codes = append(codes, c)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
+
+// Coder is implemented by error types that carry their own code.
+type Coder interface {
+	ErrorCode() Code
+}
+
+// codedError tags err with a code while keeping its message unchanged
+type codedError struct {
+	code Code
+	err  error
+}
+
+func (e *codedError) Error() string   { return e.err.Error() }
+func (e *codedError) Unwrap() error   { return e.err }
+func (e *codedError) ErrorCode() Code { return e.code }
+
+// WithCode tags err with code. The outermost code wins, so a caller can
+// narrow the code of an error it passes on.
+func WithCode(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{code: code, err: err}
+}
+
+// CodeOf returns the code err carries, falling back on its category, or
+// CodeUnknown.
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+	var c Coder
+	if errors.As(err, &c) {
+		return c.ErrorCode()
+	}
+	switch {
+	case errors.Is(err, ErrPermanentConfig):
+		return CodeInvalidConfig
+	case errors.Is(err, ErrPermission):
+		return CodePermission
+	case errors.Is(err, ErrTransient):
+		return CodeTransient
+	}
+	return CodeUnknown
+}
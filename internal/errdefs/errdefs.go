@@ -0,0 +1,81 @@
+// Package errdefs defines error categories shared across packages so callers
+// can decide whether an operation is worth retrying.
+package errdefs
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+)
+
+var (
+	// ErrTransient marks failures expected to clear on retry (busy kernel,
+	// timeouts, refused connections).
+	ErrTransient = errors.New("transient error")
+
+	// ErrPermanentConfig marks failures caused by the configuration itself;
+	// retrying without a config change will fail the same way.
+	ErrPermanentConfig = errors.New("invalid configuration")
+
+	// ErrPermission marks missing privileges or capabilities.
+	ErrPermission = errors.New("permission denied")
+)
+
+// kindError tags err with a category while keeping its message unchanged
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.kind, e.err} }
+
+func wrap(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// Transient tags err as ErrTransient
+func Transient(err error) error { return wrap(ErrTransient, err) }
+
+// PermanentConfig tags err as ErrPermanentConfig
+func PermanentConfig(err error) error { return wrap(ErrPermanentConfig, err) }
+
+// Permission tags err as ErrPermission
+func Permission(err error) error { return wrap(ErrPermission, err) }
+
+// Classify tags err based on the underlying errno or net error. Already
+// categorized and unrecognized errors are returned unchanged.
+func Classify(err error) error {
+	if err == nil || isCategorized(err) {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, os.ErrPermission):
+		return Permission(err)
+	case errors.Is(err, syscall.EAGAIN),
+		errors.Is(err, syscall.EBUSY),
+		errors.Is(err, syscall.EINTR),
+		errors.Is(err, syscall.ENOBUFS),
+		errors.Is(err, syscall.ENOMEM),
+		errors.Is(err, syscall.ETIMEDOUT),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, os.ErrDeadlineExceeded):
+		return Transient(err)
+	}
+
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return Transient(err)
+	}
+	return err
+}
+
+func isCategorized(err error) bool {
+	return errors.Is(err, ErrTransient) || errors.Is(err, ErrPermanentConfig) || errors.Is(err, ErrPermission)
+}
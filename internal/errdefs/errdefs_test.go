@@ -0,0 +1,85 @@
+package errdefs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"eperm", fmt.Errorf("failed to create IPVS handle: %w", syscall.EPERM), ErrPermission},
+		{"eacces", &os.PathError{Op: "open", Path: "/etc/frr/frr.conf", Err: syscall.EACCES}, ErrPermission},
+		{"ebusy", fmt.Errorf("netlink: %w", syscall.EBUSY), ErrTransient},
+		{"timeout", fmt.Errorf("dial: %w", os.ErrDeadlineExceeded), ErrTransient},
+		{"already tagged", PermanentConfig(errors.New("invalid VIP")), ErrPermanentConfig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Classify(tt.err)
+			if !errors.Is(got, tt.want) {
+				t.Fatalf("Classify(%v) not %v", tt.err, tt.want)
+			}
+			if got.Error() != tt.err.Error() {
+				t.Fatalf("message changed: %q -> %q", tt.err.Error(), got.Error())
+			}
+			if !errors.Is(got, tt.err) && got != tt.err {
+				t.Fatalf("original error lost from chain")
+			}
+		})
+	}
+
+	plain := errors.New("something else")
+	if got := Classify(plain); got != plain {
+		t.Fatalf("expected unrecognized error unchanged, got %v", got)
+	}
+	if Classify(nil) != nil || Transient(nil) != nil {
+		t.Fatalf("expected nil to stay nil")
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{"nil", nil, ""},
+		{"plain", errors.New("boom"), CodeUnknown},
+		{"category", PermanentConfig(errors.New("invalid VIP")), CodeInvalidConfig},
+		{"classified", Classify(fmt.Errorf("netlink: %w", syscall.EPERM)), CodePermission},
+		{"coded", WithCode(CodeNotFound, errors.New("unknown service: web")), CodeNotFound},
+		{"wrapped", fmt.Errorf("reload: %w", WithCode(CodeLockHeld, errors.New("locked"))), CodeLockHeld},
+		{"code over category", WithCode(CodeConflict, Transient(errors.New("busy"))), CodeConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CodeOf(tt.err); got != tt.want {
+				t.Fatalf("CodeOf(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+
+	base := errors.New("unknown service: web")
+	err := WithCode(CodeNotFound, base)
+	if err.Error() != base.Error() || !errors.Is(err, base) {
+		t.Fatalf("WithCode changed the error: %v", err)
+	}
+	if WithCode(CodeNotFound, nil) != nil {
+		t.Fatal("expected nil to stay nil")
+	}
+	seen := make(map[Code]bool)
+	for _, c := range Codes() {
+		if seen[c] || c.Description() == "" {
+			t.Fatalf("code %s duplicated or undescribed", c)
+		}
+		seen[c] = true
+	}
+}
@@ -1,9 +1,13 @@
 package health
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"net"
 	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
 )
 
 type Dialer interface {
@@ -16,12 +20,18 @@ func (NetDialer) DialTimeout(network, address string, timeout time.Duration) (ne
 	return net.DialTimeout(network, address, timeout)
 }
 
+// Resolver maps a backend hostname to addresses, typically through a cache
+type Resolver interface {
+	LookupIP(host string) ([]net.IP, error)
+}
+
 type Checker interface {
 	Check(address string, port int, timeout time.Duration) error
 }
 
 type TCPChecker struct {
 	Dialer Dialer
+	Reset  bool // Close with RST (SO_LINGER 0) rather than FIN, leaving no TIME_WAIT behind
 }
 
 func (c *TCPChecker) Check(address string, port int, timeout time.Duration) error {
@@ -29,19 +39,70 @@ func (c *TCPChecker) Check(address string, port int, timeout time.Duration) erro
 		return fmt.Errorf("missing dialer")
 	}
 	if net.ParseIP(address) == nil {
-		return fmt.Errorf("invalid address: %s", address)
+		return errdefs.PermanentConfig(fmt.Errorf("invalid address: %s", address))
 	}
 	if port < 1 || port > 65535 {
-		return fmt.Errorf("invalid port: %d", port)
+		return errdefs.PermanentConfig(fmt.Errorf("invalid port: %d", port))
 	}
 	if timeout <= 0 {
-		return fmt.Errorf("invalid timeout: %s", timeout)
+		return errdefs.PermanentConfig(fmt.Errorf("invalid timeout: %s", timeout))
 	}
 
 	conn, err := c.Dialer.DialTimeout("tcp", fmt.Sprintf("%s:%d", address, port), timeout)
 	if err != nil {
+		return errdefs.Classify(err)
+	}
+	_ = closeConn(conn, c.Reset)
+	return nil
+}
+
+// UDPChecker sends Payload to the backend and waits up to the timeout for a reply.
+// With Expect set, the reply must contain it. Without Expect, silence counts as
+// healthy and only a read error (ICMP port-unreachable) fails the check.
+type UDPChecker struct {
+	Dialer  Dialer
+	Payload []byte
+	Expect  []byte
+}
+
+func (c *UDPChecker) Check(address string, port int, timeout time.Duration) error {
+	if c == nil || c.Dialer == nil {
+		return fmt.Errorf("missing dialer")
+	}
+	if net.ParseIP(address) == nil {
+		return errdefs.PermanentConfig(fmt.Errorf("invalid address: %s", address))
+	}
+	if port < 1 || port > 65535 {
+		return errdefs.PermanentConfig(fmt.Errorf("invalid port: %d", port))
+	}
+	if timeout <= 0 {
+		return errdefs.PermanentConfig(fmt.Errorf("invalid timeout: %s", timeout))
+	}
+
+	conn, err := c.Dialer.DialTimeout("udp", fmt.Sprintf("%s:%d", address, port), timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
 		return err
 	}
-	_ = conn.Close()
+	if _, err := conn.Write(c.Payload); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 2048)
+	n, err := conn.Read(buf)
+	if err != nil {
+		var netErr net.Error
+		if len(c.Expect) == 0 && errors.As(err, &netErr) && netErr.Timeout() {
+			return nil
+		}
+		return err
+	}
+	if len(c.Expect) > 0 && !bytes.Contains(buf[:n], c.Expect) {
+		return fmt.Errorf("unexpected udp response from %s:%d", address, port)
+	}
 	return nil
 }
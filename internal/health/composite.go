@@ -0,0 +1,70 @@
+package health
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Probe is one check within a CompositeChecker. Port 0 uses the port passed
+// to Check.
+type Probe struct {
+	Checker Checker
+	Port    int
+}
+
+// CompositeChecker runs several probes concurrently against a backend and
+// reduces them to a single result. By default every probe must pass; with
+// Any set, one passing probe is enough.
+type CompositeChecker struct {
+	Probes []Probe
+	Any    bool
+}
+
+// Close closes every probe's checker
+func (c *CompositeChecker) Close() error {
+	var errs []error
+	for _, p := range c.Probes {
+		errs = append(errs, CloseChecker(p.Checker))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *CompositeChecker) Check(address string, port int, timeout time.Duration) error {
+	if c == nil || len(c.Probes) == 0 {
+		return fmt.Errorf("no health probes configured")
+	}
+
+	errs := make([]error, len(c.Probes))
+	var wg sync.WaitGroup
+	for i, p := range c.Probes {
+		if p.Checker == nil {
+			errs[i] = fmt.Errorf("probe %d: missing checker", i)
+			continue
+		}
+		probePort := port
+		if p.Port != 0 {
+			probePort = p.Port
+		}
+		wg.Add(1)
+		go func(i int, checker Checker, probePort int) {
+			defer wg.Done()
+			if err := checker.Check(address, probePort, timeout); err != nil {
+				errs[i] = fmt.Errorf("probe %d (port %d): %w", i, probePort, err)
+			}
+		}(i, p.Checker, probePort)
+	}
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if c.Any && len(failed) < len(c.Probes) {
+		return nil
+	}
+	return errors.Join(failed...)
+}
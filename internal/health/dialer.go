@@ -0,0 +1,62 @@
+package health
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// portRangeAttempts bounds how many ports of the range one dial tries before
+// giving up, so a nearly exhausted range fails fast instead of scanning it.
+const portRangeAttempts = 32
+
+// PortRangeDialer dials TCP and UDP checks from local ports in [Min, Max]
+// instead of the kernel's ephemeral range, keeping high-frequency checks out
+// of the ports other traffic needs. Ports are handed out round-robin; a port
+// that is still busy (e.g. in TIME_WAIT without ReuseAddr) is skipped. Other
+// networks, such as raw ICMP, are dialed normally.
+type PortRangeDialer struct {
+	Min, Max  int
+	ReuseAddr bool // Set SO_REUSEADDR so ports in TIME_WAIT can be rebound
+
+	next atomic.Uint32
+}
+
+func (d *PortRangeDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	var local func(port int) net.Addr
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		local = func(port int) net.Addr { return &net.TCPAddr{Port: port} }
+	case "udp", "udp4", "udp6":
+		local = func(port int) net.Addr { return &net.UDPAddr{Port: port} }
+	default:
+		return net.DialTimeout(network, address, timeout)
+	}
+	if d.Min < 1 || d.Max > 65535 || d.Min > d.Max {
+		return nil, fmt.Errorf("invalid source port range %d-%d", d.Min, d.Max)
+	}
+
+	size := uint32(d.Max - d.Min + 1)
+	attempts := min(int(size), portRangeAttempts)
+	deadline := time.Now().Add(timeout)
+	var err error
+	for i := 0; i < attempts; i++ {
+		port := d.Min + int(d.next.Add(1)%size)
+		dialer := net.Dialer{Deadline: deadline, LocalAddr: local(port)}
+		if d.ReuseAddr {
+			dialer.Control = reuseAddrControl
+		}
+		var conn net.Conn
+		conn, err = dialer.Dial(network, address)
+		if err == nil {
+			return conn, nil
+		}
+		if !errors.Is(err, syscall.EADDRINUSE) && !errors.Is(err, syscall.EADDRNOTAVAIL) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("no free source port in %d-%d: %w", d.Min, d.Max, err)
+}
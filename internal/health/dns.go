@@ -0,0 +1,110 @@
+package health
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+	"github.com/malindarathnayake/LibraFlux/internal/resolver"
+)
+
+// DNS query types supported by DNSChecker
+var dnsQueryTypes = map[string]uint16{
+	"A":    resolver.TypeA,
+	"AAAA": resolver.TypeAAAA,
+	"SRV":  resolver.TypeSRV,
+}
+
+// DNS response codes by name
+var dnsRcodes = map[string]int{
+	"NOERROR":  0,
+	"FORMERR":  1,
+	"SERVFAIL": 2,
+	"NXDOMAIN": 3,
+	"NOTIMP":   4,
+	"REFUSED":  5,
+}
+
+// ParseDNSQueryType maps a query type name (A, AAAA, SRV) to its wire value.
+func ParseDNSQueryType(name string) (uint16, error) {
+	if name == "" {
+		return dnsQueryTypes["A"], nil
+	}
+	qtype, ok := dnsQueryTypes[strings.ToUpper(name)]
+	if !ok {
+		return 0, fmt.Errorf("unsupported dns query type: %s", name)
+	}
+	return qtype, nil
+}
+
+// ParseDNSRcode maps a response code name (NOERROR, NXDOMAIN, ...) to its wire value.
+func ParseDNSRcode(name string) (int, error) {
+	if name == "" {
+		return dnsRcodes["NOERROR"], nil
+	}
+	rcode, ok := dnsRcodes[strings.ToUpper(name)]
+	if !ok {
+		return 0, fmt.Errorf("unsupported dns rcode: %s", name)
+	}
+	return rcode, nil
+}
+
+// DNSChecker sends a single recursive query over UDP and requires a response
+// with a matching ID and the expected rcode (NOERROR unless configured).
+type DNSChecker struct {
+	Dialer      Dialer
+	QueryName   string
+	QueryType   uint16
+	ExpectRcode int
+}
+
+func (c *DNSChecker) Check(address string, port int, timeout time.Duration) error {
+	if c == nil || c.Dialer == nil {
+		return fmt.Errorf("missing dialer")
+	}
+	if net.ParseIP(address) == nil {
+		return errdefs.PermanentConfig(fmt.Errorf("invalid address: %s", address))
+	}
+	if port < 1 || port > 65535 {
+		return errdefs.PermanentConfig(fmt.Errorf("invalid port: %d", port))
+	}
+	if timeout <= 0 {
+		return errdefs.PermanentConfig(fmt.Errorf("invalid timeout: %s", timeout))
+	}
+
+	id := uint16(rand.Intn(1 << 16))
+	query, err := resolver.BuildQuery(id, c.QueryName, c.QueryType)
+	if err != nil {
+		return err
+	}
+
+	conn, err := c.Dialer.DialTimeout("udp", fmt.Sprintf("%s:%d", address, port), timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	if _, err := conn.Write(query); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 512)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return err
+	}
+	resp, err := resolver.ParseResponse(buf[:n], id)
+	if err != nil {
+		return err
+	}
+	if resp.Rcode != c.ExpectRcode {
+		return fmt.Errorf("dns rcode %d, expected %d", resp.Rcode, c.ExpectRcode)
+	}
+	return nil
+}
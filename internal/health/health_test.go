@@ -1,11 +1,22 @@
 package health
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
 	"net"
+	"os"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+	"github.com/malindarathnayake/LibraFlux/internal/resolver"
+	"github.com/malindarathnayake/LibraFlux/internal/routine"
 )
 
 type stubConn struct{}
@@ -63,6 +74,78 @@ func TestHealthTCPChecker(t *testing.T) {
 	}
 }
 
+// portChecker fails checks against the ports in down
+type portChecker struct {
+	down map[int]bool
+}
+
+func (c portChecker) Check(address string, port int, timeout time.Duration) error {
+	if c.down[port] {
+		return errdefs.Transient(fmt.Errorf("port %d down", port))
+	}
+	return nil
+}
+
+func TestHealthCompositeChecker(t *testing.T) {
+	// TCP on the backend's check port AND a readiness probe on 8081
+	up := portChecker{}
+	readyDown := portChecker{down: map[int]bool{8081: true}}
+
+	all := &CompositeChecker{Probes: []Probe{{Checker: up}, {Checker: up, Port: 8081}}}
+	if err := all.Check("10.0.0.1", 80, time.Second); err != nil {
+		t.Fatalf("expected AND to pass when every probe passes, got %v", err)
+	}
+	all.Probes[1].Checker = readyDown
+	err := all.Check("10.0.0.1", 80, time.Second)
+	if err == nil || !errors.Is(err, errdefs.ErrTransient) {
+		t.Fatalf("expected transient AND failure, got %v", err)
+	}
+
+	any := &CompositeChecker{Probes: all.Probes, Any: true}
+	if err := any.Check("10.0.0.1", 80, time.Second); err != nil {
+		t.Fatalf("expected OR to pass with one healthy probe, got %v", err)
+	}
+	any.Probes = []Probe{{Checker: readyDown, Port: 8081}, {Checker: nil}}
+	if err := any.Check("10.0.0.1", 80, time.Second); err == nil {
+		t.Fatalf("expected OR to fail when every probe fails")
+	}
+}
+
+func TestProbeTargets(t *testing.T) {
+	r := staticResolver{"api.internal": {net.ParseIP("10.0.2.1")}}
+	targets := []Target{
+		{Key: BackendKey{Service: "svc", Backend: "10.0.0.1"}, CheckPort: 80, Timeout: time.Second},
+		{Key: BackendKey{Service: "svc", Backend: "10.0.0.2"}, CheckAddress: "10.0.1.2", CheckPort: 8081, Timeout: time.Second},
+		{Key: BackendKey{Service: "svc", Backend: "api.internal"}, CheckPort: 80, Timeout: time.Second},
+		{Key: BackendKey{Service: "svc", Backend: "gone.internal"}, CheckPort: 80, Timeout: time.Second},
+		{Key: BackendKey{Service: "dns", Backend: "10.0.0.3"}, CheckPort: 53, Timeout: time.Second, Checker: portChecker{down: map[int]bool{53: true}}},
+	}
+
+	results := ProbeTargets(targets, portChecker{down: map[int]bool{8081: true}}, r, 2)
+	if len(results) != len(targets) {
+		t.Fatalf("expected %d results, got %d", len(targets), len(results))
+	}
+	want := []struct {
+		address string
+		ok      bool
+	}{
+		{"10.0.0.1", true},
+		{"10.0.1.2", false},
+		{"10.0.2.1", true},
+		{"gone.internal", false},
+		{"10.0.0.3", false},
+	}
+	for i, w := range want {
+		res := results[i]
+		if res.Key != targets[i].Key || res.Port != targets[i].CheckPort {
+			t.Fatalf("result %d out of order: %+v", i, res)
+		}
+		if res.Address != w.address || (res.Err == nil) != w.ok {
+			t.Fatalf("result %d = %s err=%v, want %s ok=%v", i, res.Address, res.Err, w.address, w.ok)
+		}
+	}
+}
+
 type fakeTicker struct {
 	ch chan time.Time
 }
@@ -184,3 +267,1457 @@ func TestHealthStateMachineTransitions(t *testing.T) {
 		t.Fatalf("expected third weight 0, got %#v", obs.weights[2])
 	}
 }
+
+func TestHealthUDPChecker(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket: %v", err)
+	}
+	defer pc.Close()
+	port := pc.LocalAddr().(*net.UDPAddr).Port
+
+	// Echo "pong" for "ping", stay silent otherwise
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if string(buf[:n]) == "ping" {
+				_, _ = pc.WriteTo([]byte("pong"), addr)
+			}
+		}
+	}()
+
+	timeout := 100 * time.Millisecond
+
+	c := &UDPChecker{Dialer: NetDialer{}, Payload: []byte("ping"), Expect: []byte("pong")}
+	if err := c.Check("127.0.0.1", port, timeout); err != nil {
+		t.Fatalf("expected matching reply to pass, got %v", err)
+	}
+
+	c = &UDPChecker{Dialer: NetDialer{}, Payload: []byte("ping"), Expect: []byte("nope")}
+	if err := c.Check("127.0.0.1", port, timeout); err == nil {
+		t.Fatalf("expected mismatched reply to fail")
+	}
+
+	c = &UDPChecker{Dialer: NetDialer{}, Payload: []byte("quiet"), Expect: []byte("pong")}
+	if err := c.Check("127.0.0.1", port, timeout); err == nil {
+		t.Fatalf("expected missing reply to fail when expect is set")
+	}
+
+	c = &UDPChecker{Dialer: NetDialer{}, Payload: []byte("quiet")}
+	if err := c.Check("127.0.0.1", port, timeout); err != nil {
+		t.Fatalf("expected silence to pass without expect, got %v", err)
+	}
+}
+
+// echoDialer answers ICMP echo requests over a pipe, optionally with a
+// stray reply for another id first, and with the IPv4 header attached.
+type echoDialer struct {
+	network string
+	silent  bool
+}
+
+func (d *echoDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	d.network = network
+	silent := d.silent
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		req := make([]byte, 64)
+		n, err := server.Read(req)
+		if err != nil {
+			return
+		}
+		if silent {
+			// Hold the pipe open until the pinger gives up
+			_, _ = server.Read(req)
+			return
+		}
+		if network == "ip4:icmp" && icmpChecksum(req[:n]) != 0 {
+			return
+		}
+		stray := append([]byte{icmpv4EchoReply, 0, 0, 0, 0xde, 0xad}, req[6:n]...)
+		reply := append([]byte(nil), req[:n]...)
+		reply[0] = icmpv4EchoReply
+		if network != "ip4:icmp" {
+			stray[0], reply[0] = icmpv6EchoReply, icmpv6EchoReply
+		} else {
+			reply = append(make([]byte, 20), reply...)
+			reply[0] = 0x45
+		}
+		_, _ = server.Write(stray)
+		_, _ = server.Write(reply)
+	}()
+	return client, nil
+}
+
+func TestHealthICMPPinger(t *testing.T) {
+	d := &echoDialer{}
+	p := &ICMPPinger{Dialer: d}
+	if err := p.Ping("10.0.0.1", 100*time.Millisecond); err != nil {
+		t.Fatalf("expected echo reply to pass, got %v", err)
+	}
+	if d.network != "ip4:icmp" {
+		t.Fatalf("unexpected network %q", d.network)
+	}
+	if err := p.Ping("2001:db8::1", 100*time.Millisecond); err != nil {
+		t.Fatalf("expected ICMPv6 echo reply to pass, got %v", err)
+	}
+	if d.network != "ip6:ipv6-icmp" {
+		t.Fatalf("unexpected network %q", d.network)
+	}
+
+	d.silent = true
+	err := p.Ping("10.0.0.1", 20*time.Millisecond)
+	if err == nil || FailureReason(err) != ReasonTimeout {
+		t.Fatalf("expected a timeout without a reply, got %v", err)
+	}
+	if err := p.Ping("db.internal", time.Second); !errors.Is(err, errdefs.ErrPermanentConfig) {
+		t.Fatalf("expected invalid address to be a config error, got %v", err)
+	}
+}
+
+type fakePinger struct{ err error }
+
+func (p fakePinger) Ping(string, time.Duration) error { return p.err }
+
+func TestHealthPingPrecheck(t *testing.T) {
+	checker := &addressChecker{seen: make(chan string, 4)}
+	c := &PingPrecheck{Pinger: fakePinger{err: errors.New("no icmp echo reply")}, Checker: checker}
+
+	err := c.Check("10.0.0.1", 80, time.Second)
+	if !errors.Is(err, ErrHostDown) || FailureReason(err) != ReasonHostDown {
+		t.Fatalf("expected host down, got %v (%s)", err, FailureReason(err))
+	}
+	select {
+	case addr := <-checker.seen:
+		t.Fatalf("service check ran against %s despite the host being down", addr)
+	default:
+	}
+
+	c.Pinger = fakePinger{}
+	if err := c.Check("10.0.0.1", 80, time.Second); err != nil {
+		t.Fatalf("expected check to pass after a ping, got %v", err)
+	}
+	if addr := <-checker.seen; addr != "10.0.0.1" {
+		t.Fatalf("expected service check against 10.0.0.1, got %s", addr)
+	}
+
+	c.Pinger = fakePinger{err: errdefs.Permission(errors.New("operation not permitted"))}
+	if err := c.Check("10.0.0.1", 80, time.Second); errors.Is(err, ErrHostDown) || FailureReason(err) != ReasonPermission {
+		t.Fatalf("expected missing privileges not to look like host down, got %v", err)
+	}
+}
+
+func TestHealthDNSChecker(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket: %v", err)
+	}
+	defer pc.Close()
+	port := pc.LocalAddr().(*net.UDPAddr).Port
+
+	// Answer NOERROR for ok.example, NXDOMAIN for anything else
+	okQuery, _ := resolver.BuildQuery(0, "ok.example", resolver.TypeA)
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			resp := append([]byte(nil), buf[:n]...)
+			rcode := byte(3)
+			if n == len(okQuery) && string(buf[2:n]) == string(okQuery[2:]) {
+				rcode = 0
+			}
+			resp[2] |= 0x80
+			resp[3] = 0x80 | rcode
+			_, _ = pc.WriteTo(resp, addr)
+		}
+	}()
+
+	timeout := 100 * time.Millisecond
+
+	c := &DNSChecker{Dialer: NetDialer{}, QueryName: "ok.example", QueryType: 1}
+	if err := c.Check("127.0.0.1", port, timeout); err != nil {
+		t.Fatalf("expected NOERROR to pass, got %v", err)
+	}
+
+	c = &DNSChecker{Dialer: NetDialer{}, QueryName: "missing.example", QueryType: 1}
+	if err := c.Check("127.0.0.1", port, timeout); err == nil {
+		t.Fatalf("expected NXDOMAIN to fail")
+	}
+
+	rcode, _ := ParseDNSRcode("nxdomain")
+	c = &DNSChecker{Dialer: NetDialer{}, QueryName: "missing.example", QueryType: 1, ExpectRcode: rcode}
+	if err := c.Check("127.0.0.1", port, timeout); err != nil {
+		t.Fatalf("expected configured NXDOMAIN to pass, got %v", err)
+	}
+
+	if _, err := ParseDNSQueryType("MX"); err == nil {
+		t.Fatalf("expected unsupported query type to fail")
+	}
+}
+
+// serveTCP accepts connections on a loopback listener and runs handle for each
+func serveTCP(t *testing.T, handle func(net.Conn)) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			handle(conn)
+			conn.Close()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestHealthRedisChecker(t *testing.T) {
+	timeout := 200 * time.Millisecond
+	c := &RedisChecker{Dialer: NetDialer{}}
+
+	for _, tc := range []struct {
+		reply   string
+		wantErr bool
+	}{
+		{"+PONG\r\n", false},
+		{"-NOAUTH Authentication required.\r\n", false},
+		{"-LOADING Redis is loading the dataset in memory\r\n", true},
+	} {
+		reply := tc.reply
+		port := serveTCP(t, func(conn net.Conn) {
+			buf := make([]byte, 64)
+			_, _ = conn.Read(buf)
+			_, _ = conn.Write([]byte(reply))
+		})
+		err := c.Check("127.0.0.1", port, timeout)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("reply %q: err=%v wantErr=%v", reply, err, tc.wantErr)
+		}
+	}
+}
+
+func TestHealthMySQLChecker(t *testing.T) {
+	timeout := 200 * time.Millisecond
+	c := &MySQLChecker{Dialer: NetDialer{}}
+
+	packet := func(seq byte, payload []byte) []byte {
+		n := len(payload)
+		return append([]byte{byte(n), byte(n >> 8), byte(n >> 16), seq}, payload...)
+	}
+	// Version, thread id, auth data, filler, capabilities with CLIENT_PROTOCOL_41
+	handshake := append([]byte{0x0a}, []byte("8.0.36\x00")...)
+	handshake = append(handshake, make([]byte, 13)...)
+	handshake = append(handshake, 0x00, 0x82)
+	handshake = append(handshake, make([]byte, 20)...)
+	ok := []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00}
+	tooMany := append([]byte{0xff, 0x10, 0x04}, []byte("#08004Too many connections")...)
+	denied := append([]byte{0xff, 0x15, 0x04}, []byte("#28000Access denied for user 'lbctl'")...)
+	blocked := append([]byte{0xff, 0x69, 0x04}, []byte("Host is blocked")...)
+	authSwitch := append([]byte{0xfe}, []byte("caching_sha2_password\x00")...)
+	authSwitch = append(authSwitch, make([]byte, 20)...)
+	moreData := []byte{0x01, 0x04}
+
+	for _, tc := range []struct {
+		name     string
+		login    []byte // Reply to the handshake response; nil sends only data
+		switched []byte // Reply to the answer to an auth switch in login
+		data     []byte
+		wantErr  string
+	}{
+		{name: "login", data: packet(0, handshake), login: ok},
+		{name: "access denied", data: packet(0, handshake), login: denied},
+		{name: "auth switch", data: packet(0, handshake), login: authSwitch, switched: denied},
+		{name: "auth switch login", data: packet(0, handshake), login: authSwitch, switched: ok},
+		{name: "auth switch more data", data: packet(0, handshake), login: authSwitch, switched: moreData},
+		{name: "login error", data: packet(0, handshake), login: blocked, wantErr: "mysql error 1129: Host is blocked"},
+		{name: "error packet", data: packet(0, tooMany), wantErr: "mysql error 1040: Too many connections"},
+		{name: "truncated", data: []byte{0x40, 0, 0, 0, 0x0a}, wantErr: "mysql handshake read failed: unexpected EOF"},
+	} {
+		tc := tc
+		quit := make(chan []byte, 1)
+		port := serveTCP(t, func(conn net.Conn) {
+			_, _ = conn.Write(tc.data)
+			if tc.login == nil {
+				return
+			}
+			seq, resp, err := readMySQLPacket(conn)
+			if err != nil || seq != 1 || !bytes.Contains(resp, []byte("\x00lbctl\x00")) {
+				t.Errorf("%s: unexpected handshake response seq=%d %q: %v", tc.name, seq, resp, err)
+				return
+			}
+			_, _ = conn.Write(packet(2, tc.login))
+			if tc.switched != nil {
+				// The answer carries the empty password: a bare header
+				header := make([]byte, 4)
+				if _, err := io.ReadFull(conn, header); err != nil || !bytes.Equal(header, []byte{0, 0, 0, 3}) {
+					t.Errorf("%s: unexpected auth switch response %v: %v", tc.name, header, err)
+					return
+				}
+				_, _ = conn.Write(packet(4, tc.switched))
+			}
+			b, _ := io.ReadAll(conn)
+			quit <- b
+		})
+		err := c.Check("127.0.0.1", port, timeout)
+		if tc.wantErr == "" && err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if tc.wantErr != "" && (err == nil || err.Error() != tc.wantErr) {
+			t.Fatalf("%s: expected %q, got %v", tc.name, tc.wantErr, err)
+		}
+		if tc.login == nil {
+			continue
+		}
+		// A successful login ends with COM_QUIT; a rejected one is closed by the server
+		want := []byte{}
+		if tc.login[0] == 0x00 || (tc.switched != nil && tc.switched[0] == 0x00) {
+			want = []byte{1, 0, 0, 0, 0x01}
+		}
+		if got := <-quit; !bytes.Equal(got, want) {
+			t.Fatalf("%s: expected %v after the login, got %v", tc.name, want, got)
+		}
+	}
+}
+
+type checkRecordingObserver struct {
+	recordingObserver
+	checks chan CheckResult
+}
+
+func (o *checkRecordingObserver) OnCheck(result CheckResult) {
+	o.checks <- result
+}
+
+func TestFailureReason(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{errdefs.Classify(refused), ReasonRefused},
+		{&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, ReasonReset},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)}, ReasonUnreachable},
+		{fmt.Errorf("read: %w", os.ErrDeadlineExceeded), ReasonTimeout},
+		{&net.DNSError{Err: "no such host", Name: "db.internal", IsNotFound: true}, ReasonDNS},
+		{errdefs.PermanentConfig(errors.New("invalid port: 0")), ReasonConfig},
+		{errors.Join(errors.New("probe 0: bad reply"), fmt.Errorf("probe 1: %w", refused)), ReasonRefused},
+		{errors.New("unexpected reply"), ReasonOther},
+	}
+	for _, tt := range tests {
+		if got := FailureReason(tt.err); got != tt.want {
+			t.Errorf("FailureReason(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestHealthStateChangeCarriesFailureContext(t *testing.T) {
+	ticker := newFakeTicker()
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	checker := &scriptedChecker{
+		script: map[BackendKey][]error{
+			{Service: "svc", Backend: "10.0.0.1"}: {nil, refused, refused, nil},
+		},
+		seen: make(chan BackendKey, 32),
+	}
+	obs := &recordingObserver{}
+	clk := clock.NewFake(time.Unix(1000, 0))
+
+	s := NewScheduler(checker, obs)
+	s.SetClock(clk)
+	s.SetTickerFactory(func(d time.Duration) Ticker { return ticker })
+	t.Cleanup(s.Stop)
+
+	if err := s.Start([]Target{{
+		Key:              BackendKey{Service: "svc", Backend: "10.0.0.1"},
+		CheckPort:        8080,
+		Interval:         10 * time.Millisecond,
+		Timeout:          5 * time.Millisecond,
+		FailAfter:        2,
+		RecoverAfter:     1,
+		ConfiguredWeight: 5,
+	}}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		ticker.ch <- time.Now()
+		<-checker.seen
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		obs.mu.Lock()
+		n := len(obs.states)
+		obs.mu.Unlock()
+		if n >= 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 3 state changes, got %d", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	obs.mu.Lock()
+	defer obs.mu.Unlock()
+	down := obs.states[1]
+	if down.New != StateUnhealthy || down.Reason != ReasonRefused || down.Error != refused.Error() {
+		t.Fatalf("expected refused failure context, got %#v", down)
+	}
+	if down.ConsecutiveFailures != 2 || down.ConsecutiveSuccesses != 0 || !down.Time.Equal(clk.Now()) {
+		t.Fatalf("unexpected counters or time: %#v", down)
+	}
+	up := obs.states[2]
+	if up.New != StateHealthy || up.Error != "" || up.Reason != "" || up.ConsecutiveSuccesses != 1 {
+		t.Fatalf("expected recovery without an error, got %#v", up)
+	}
+}
+
+func TestHealthSchedulerReportsCheckDuration(t *testing.T) {
+	ticker := newFakeTicker()
+	key := BackendKey{Service: "svc", Backend: "10.0.0.1"}
+	checker := &scriptedChecker{
+		script: map[BackendKey][]error{key: {nil, errors.New("fail")}},
+		seen:   make(chan BackendKey, 32),
+	}
+	obs := &checkRecordingObserver{checks: make(chan CheckResult, 4)}
+
+	s := NewScheduler(checker, obs)
+	s.SetTickerFactory(func(d time.Duration) Ticker { return ticker })
+	clock := time.Unix(0, 0)
+	s.now = func() time.Time {
+		clock = clock.Add(3 * time.Millisecond)
+		return clock
+	}
+	t.Cleanup(s.Stop)
+
+	if err := s.Start([]Target{{
+		Key:              key,
+		CheckPort:        8080,
+		Interval:         10 * time.Millisecond,
+		Timeout:          5 * time.Millisecond,
+		FailAfter:        1,
+		RecoverAfter:     1,
+		ConfiguredWeight: 1,
+	}}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	for i, wantErr := range []bool{false, true} {
+		ticker.ch <- time.Now()
+		res := <-obs.checks
+		if res.Key != key {
+			t.Fatalf("check %d: unexpected key %#v", i, res.Key)
+		}
+		if res.Duration != 3*time.Millisecond {
+			t.Fatalf("check %d: expected 3ms, got %s", i, res.Duration)
+		}
+		if (res.Err != nil) != wantErr {
+			t.Fatalf("check %d: err=%v wantErr=%v", i, res.Err, wantErr)
+		}
+	}
+}
+
+func TestHealthSchedulerJitterDelaysFirstTick(t *testing.T) {
+	checker := &scriptedChecker{script: map[BackendKey][]error{}, seen: make(chan BackendKey, 32)}
+	s := NewScheduler(checker, &recordingObserver{})
+
+	var requested time.Duration
+	s.jitter = func(max time.Duration) time.Duration {
+		requested = max
+		return 30 * time.Millisecond
+	}
+	created := make(chan time.Time, 1)
+	s.SetTickerFactory(func(d time.Duration) Ticker {
+		created <- time.Now()
+		return newFakeTicker()
+	})
+	t.Cleanup(s.Stop)
+
+	target := Target{
+		Key:              BackendKey{Service: "svc", Backend: "10.0.0.1"},
+		CheckPort:        8080,
+		Interval:         100 * time.Millisecond,
+		Timeout:          5 * time.Millisecond,
+		FailAfter:        1,
+		RecoverAfter:     1,
+		ConfiguredWeight: 1,
+		Jitter:           50 * time.Millisecond,
+	}
+	start := time.Now()
+	if err := s.Start([]Target{target}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if requested != 50*time.Millisecond {
+		t.Fatalf("expected jitter bound 50ms, got %s", requested)
+	}
+	select {
+	case at := <-created:
+		if at.Sub(start) < 30*time.Millisecond {
+			t.Fatalf("ticker created after %s, expected start delay of 30ms", at.Sub(start))
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("ticker never created")
+	}
+
+	target.Jitter = 200 * time.Millisecond
+	if err := validateTarget(target); err == nil {
+		t.Fatalf("expected jitter larger than interval to fail")
+	}
+	for i := 0; i < 100; i++ {
+		if d := randomJitter(10 * time.Millisecond); d < 0 || d >= 10*time.Millisecond {
+			t.Fatalf("randomJitter out of range: %s", d)
+		}
+	}
+}
+
+func TestHealthSchedulerSlowStartRampsWeight(t *testing.T) {
+	ticker := newFakeTicker()
+	checker := &scriptedChecker{
+		script: map[BackendKey][]error{
+			{Service: "svc", Backend: "10.0.0.1"}: {errors.New("fail")},
+		},
+		seen: make(chan BackendKey, 32),
+	}
+	obs := &recordingObserver{}
+
+	s := NewScheduler(checker, obs)
+	s.SetTickerFactory(func(d time.Duration) Ticker { return ticker })
+	base := time.Unix(1000, 0)
+	var clock time.Time
+	s.now = func() time.Time { return clock }
+	t.Cleanup(s.Stop)
+
+	if err := s.Start([]Target{
+		{
+			Key:              BackendKey{Service: "svc", Backend: "10.0.0.1"},
+			CheckPort:        8080,
+			Interval:         100 * time.Millisecond,
+			Timeout:          5 * time.Millisecond,
+			FailAfter:        1,
+			RecoverAfter:     1,
+			ConfiguredWeight: 10,
+			SlowStart:        400 * time.Millisecond,
+			SlowStartSteps:   4,
+		},
+	}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	drive := func(at time.Duration, wantWeights int) {
+		clock = base.Add(at)
+		ticker.ch <- clock
+		<-checker.seen
+		deadline := time.Now().Add(time.Second)
+		for {
+			obs.mu.Lock()
+			n := len(obs.weights)
+			obs.mu.Unlock()
+			if n >= wantWeights {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d weight changes, got %d", wantWeights, n)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	drive(0, 1)                    // fail -> UNHEALTHY
+	drive(100*time.Millisecond, 2) // recover, ramp starts
+	drive(200*time.Millisecond, 3)
+	drive(300*time.Millisecond, 4)
+	drive(400*time.Millisecond, 5)
+	drive(500*time.Millisecond, 6) // ramp complete
+	drive(600*time.Millisecond, 6)
+
+	obs.mu.Lock()
+	defer obs.mu.Unlock()
+
+	want := []int{0, 2, 4, 6, 8, 10}
+	if len(obs.weights) != len(want) {
+		t.Fatalf("expected %d weight changes, got %#v", len(want), obs.weights)
+	}
+	for i, w := range want {
+		if obs.weights[i].NewWeight != w {
+			t.Fatalf("weight change %d: expected %d, got %#v", i, w, obs.weights[i])
+		}
+	}
+	if obs.weights[1].Reason != "health" || obs.weights[2].Reason != "slow_start" || obs.weights[5].Reason != "slow_start" {
+		t.Fatalf("unexpected reasons: %#v", obs.weights)
+	}
+
+	bad := Target{
+		Key:              BackendKey{Service: "svc", Backend: "10.0.0.1"},
+		CheckPort:        8080,
+		Interval:         100 * time.Millisecond,
+		Timeout:          5 * time.Millisecond,
+		FailAfter:        1,
+		RecoverAfter:     1,
+		ConfiguredWeight: 10,
+		SlowStart:        200 * time.Millisecond,
+		SlowStartSteps:   4,
+	}
+	if err := validateTarget(bad); err == nil {
+		t.Fatalf("expected slow start step shorter than interval to fail")
+	}
+}
+
+// slowChecker advances the fake clock by delay on every check
+type slowChecker struct {
+	clock *clock.Fake
+	delay time.Duration
+	seen  chan struct{}
+}
+
+func (c *slowChecker) Check(address string, port int, timeout time.Duration) error {
+	c.clock.Advance(c.delay)
+	c.seen <- struct{}{}
+	return nil
+}
+
+func TestHealthSchedulerAdaptiveWeight(t *testing.T) {
+	ticker := newFakeTicker()
+	clk := clock.NewFake(time.Unix(1000, 0))
+	checker := &slowChecker{clock: clk, delay: 40 * time.Millisecond, seen: make(chan struct{}, 32)}
+	obs := &checkRecordingObserver{checks: make(chan CheckResult, 8)}
+
+	s := NewScheduler(checker, obs)
+	s.SetClock(clk)
+	s.SetTickerFactory(func(d time.Duration) Ticker { return ticker })
+	// Another backend of the same service answers in 20ms
+	s.latency[BackendKey{Service: "svc", Backend: "10.0.0.2"}] = 20 * time.Millisecond
+	t.Cleanup(s.Stop)
+
+	if err := s.Start([]Target{
+		{
+			Key:              BackendKey{Service: "svc", Backend: "10.0.0.1"},
+			CheckPort:        8080,
+			Interval:         time.Second,
+			Timeout:          time.Second,
+			FailAfter:        1,
+			RecoverAfter:     1,
+			ConfiguredWeight: 100,
+			AdaptiveWeight:   true,
+		},
+	}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	var last CheckResult
+	for i := 0; i < 2; i++ {
+		ticker.ch <- clk.Now()
+		<-checker.seen
+		last = <-obs.checks
+	}
+	if last.Latency != 40*time.Millisecond {
+		t.Fatalf("expected smoothed latency 40ms, got %s", last.Latency)
+	}
+
+	obs.mu.Lock()
+	defer obs.mu.Unlock()
+	// 20ms / 40ms = half the configured weight
+	if len(obs.weights) != 1 || obs.weights[0].NewWeight != 50 {
+		t.Fatalf("expected a single weight change to 50, got %#v", obs.weights)
+	}
+
+	if got, fastest := s.observeLatency(last.Key, 10*time.Millisecond, true); got != 31*time.Millisecond || fastest != 20*time.Millisecond {
+		t.Fatalf("expected EWMA 31ms and fastest 20ms, got %s and %s", got, fastest)
+	}
+	if w := adaptiveWeight(10, 30*time.Millisecond, 10*time.Millisecond); w != 3 {
+		t.Fatalf("adaptiveWeight(10, 30ms, 10ms) = %d, want 3", w)
+	}
+	if w := adaptiveWeight(1, time.Second, time.Millisecond); w != 1 {
+		t.Fatalf("adaptiveWeight should never drop below 1, got %d", w)
+	}
+	if w := adaptiveWeight(10, 0, 0); w != 10 {
+		t.Fatalf("adaptiveWeight without samples = %d, want 10", w)
+	}
+}
+
+type staticResolver map[string][]net.IP
+
+func (r staticResolver) LookupIP(host string) ([]net.IP, error) {
+	ips, ok := r[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return ips, nil
+}
+
+type overrideObserver struct {
+	recordingObserver
+	overrides []OverrideChange
+}
+
+func (o *overrideObserver) OnOverride(change OverrideChange) {
+	o.mu.Lock()
+	o.overrides = append(o.overrides, change)
+	o.mu.Unlock()
+}
+
+func TestHealthSchedulerOverride(t *testing.T) {
+	key := BackendKey{Service: "svc", Backend: "10.0.0.1"}
+	ticker := newFakeTicker()
+	fail := errors.New("fail")
+	checker := &scriptedChecker{
+		script: map[BackendKey][]error{key: {fail, fail, fail, fail}},
+		seen:   make(chan BackendKey, 32),
+	}
+	obs := &overrideObserver{}
+
+	s := NewScheduler(checker, obs)
+	s.SetTickerFactory(func(d time.Duration) Ticker { return ticker })
+	base := time.Unix(1000, 0)
+	var mu sync.Mutex
+	clock := base
+	s.now = func() time.Time { mu.Lock(); defer mu.Unlock(); return clock }
+	t.Cleanup(s.Stop)
+
+	if err := s.Start([]Target{{
+		Key:              key,
+		CheckPort:        8080,
+		Interval:         100 * time.Millisecond,
+		Timeout:          5 * time.Millisecond,
+		FailAfter:        1,
+		RecoverAfter:     1,
+		ConfiguredWeight: 10,
+	}}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	waitWeights := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for {
+			obs.mu.Lock()
+			got := len(obs.weights)
+			obs.mu.Unlock()
+			if got >= n {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d weight changes, got %d", n, got)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	tick := func(at time.Duration) {
+		mu.Lock()
+		clock = base.Add(at)
+		mu.Unlock()
+		ticker.ch <- base.Add(at)
+		<-checker.seen
+	}
+
+	tick(0)
+	waitWeights(1) // probe fails -> UNHEALTHY, weight 0
+
+	if err := s.SetOverride(key, OverrideHealthy, base.Add(250*time.Millisecond), ""); err != nil {
+		t.Fatalf("SetOverride() error = %v", err)
+	}
+	obs.mu.Lock()
+	if last := obs.states[len(obs.states)-1]; last.New != StateHealthy {
+		obs.mu.Unlock()
+		t.Fatalf("expected override to report HEALTHY, got %#v", last)
+	}
+	if last := obs.weights[len(obs.weights)-1]; last.NewWeight != 10 || last.Reason != "override" {
+		obs.mu.Unlock()
+		t.Fatalf("expected override weight 10, got %#v", last)
+	}
+	obs.mu.Unlock()
+
+	tick(100 * time.Millisecond) // still failing, override holds
+	tick(300 * time.Millisecond) // past the TTL
+	waitWeights(3)
+
+	obs.mu.Lock()
+	if len(obs.weights) != 3 || obs.weights[2].NewWeight != 0 || obs.weights[2].Reason != "override_expired" {
+		obs.mu.Unlock()
+		t.Fatalf("expected override to expire back to weight 0, got %#v", obs.weights)
+	}
+	if len(obs.overrides) != 2 || obs.overrides[0].Reason != "set" || obs.overrides[1].Reason != "expired" {
+		obs.mu.Unlock()
+		t.Fatalf("unexpected override events: %#v", obs.overrides)
+	}
+	obs.mu.Unlock()
+
+	if err := s.SetOverride(key, Override("maintenance"), time.Time{}, ""); err == nil {
+		t.Fatalf("expected invalid override to fail")
+	}
+	if err := s.SetOverride(BackendKey{Service: "svc", Backend: "10.9.9.9"}, OverrideDrain, time.Time{}, ""); err == nil {
+		t.Fatalf("expected unknown backend to fail")
+	}
+	if err := s.SetOverride(key, OverrideDrain, time.Time{}, ""); err != nil {
+		t.Fatalf("SetOverride(drain) error = %v", err)
+	}
+	if got := s.Overrides()[key]; got.Mode != OverrideDrain || !got.Expires.IsZero() {
+		t.Fatalf("expected drain override without TTL, got %#v", got)
+	}
+	if err := s.ClearOverride(key, ""); err != nil {
+		t.Fatalf("ClearOverride() error = %v", err)
+	}
+	if len(s.Overrides()) != 0 {
+		t.Fatalf("expected no overrides after clear")
+	}
+	obs.mu.Lock()
+	defer obs.mu.Unlock()
+	if n := len(obs.overrides); n != 4 || obs.overrides[3].Reason != "cleared" {
+		t.Fatalf("expected drain set and clear events, got %#v", obs.overrides)
+	}
+}
+
+func TestHealthSchedulerPace(t *testing.T) {
+	key := BackendKey{Service: "svc", Backend: "10.0.0.1"}
+	ticker := newFakeTicker()
+	checker := &scriptedChecker{script: map[BackendKey][]error{}, seen: make(chan BackendKey, 32)}
+	obs := &overrideObserver{}
+
+	s := NewScheduler(checker, obs)
+	s.SetTickerFactory(func(d time.Duration) Ticker { return ticker })
+	base := time.Unix(1000, 0)
+	var mu sync.Mutex
+	clock := base
+	s.now = func() time.Time { mu.Lock(); defer mu.Unlock(); return clock }
+	t.Cleanup(s.Stop)
+
+	s.SetPace(3)
+	if err := s.Start([]Target{{
+		Key:              key,
+		CheckPort:        8080,
+		Interval:         100 * time.Millisecond,
+		Timeout:          5 * time.Millisecond,
+		FailAfter:        1,
+		RecoverAfter:     1,
+		ConfiguredWeight: 10,
+	}}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	waitCheck := func() {
+		t.Helper()
+		select {
+		case <-checker.seen:
+		case <-time.After(time.Second):
+			t.Fatal("expected a check")
+		}
+	}
+
+	// Only every third tick checks
+	for i := 0; i < 3; i++ {
+		ticker.ch <- base
+	}
+	waitCheck()
+	if n := len(checker.seen); n != 0 {
+		t.Fatalf("expected 1 check in 3 ticks, got %d more", n)
+	}
+
+	// Paused, a tick still expires an override but doesn't check
+	if err := s.SetOverride(key, OverrideDrain, base.Add(time.Second), ""); err != nil {
+		t.Fatalf("SetOverride() error = %v", err)
+	}
+	s.SetPace(0)
+	mu.Lock()
+	clock = base.Add(2 * time.Second)
+	mu.Unlock()
+	ticker.ch <- clock
+	deadline := time.Now().Add(time.Second)
+	for {
+		obs.mu.Lock()
+		n := len(obs.overrides)
+		obs.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the override to expire while paused, got %d override events", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := len(checker.seen); n != 0 {
+		t.Fatalf("expected no checks while paused, got %d", n)
+	}
+
+	// Resuming checks at once, without waiting for a tick
+	s.SetPace(1)
+	waitCheck()
+}
+
+func TestHealthSchedulerResumeForgetsStaleState(t *testing.T) {
+	key := BackendKey{Service: "svc", Backend: "10.0.0.1"}
+	ticker := newFakeTicker()
+	checker := &scriptedChecker{
+		script: map[BackendKey][]error{key: {nil, errors.New("fail"), errors.New("fail")}},
+		seen:   make(chan BackendKey, 32),
+	}
+
+	s := NewScheduler(checker, &recordingObserver{})
+	s.SetTickerFactory(func(d time.Duration) Ticker { return ticker })
+	base := time.Unix(1000, 0)
+	var mu sync.Mutex
+	clock := base
+	s.now = func() time.Time { mu.Lock(); defer mu.Unlock(); return clock }
+	t.Cleanup(s.Stop)
+
+	if err := s.Start([]Target{{
+		Key:              key,
+		CheckPort:        8080,
+		Interval:         100 * time.Millisecond,
+		Timeout:          5 * time.Millisecond,
+		FailAfter:        3,
+		RecoverAfter:     1,
+		ConfiguredWeight: 10,
+	}}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	waitFor := func(state State, failures int) {
+		t.Helper()
+		s.mu.Lock()
+		r := s.runners[key]
+		s.mu.Unlock()
+		deadline := time.Now().Add(time.Second)
+		for {
+			r.mu.Lock()
+			gotState, gotFailures := r.state, r.consecutiveFailures
+			r.mu.Unlock()
+			if gotState == state && gotFailures == failures {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %s after %d failures, got %s after %d", state, failures, gotState, gotFailures)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	resumeAt := func(at time.Time) {
+		t.Helper()
+		s.SetPace(0)
+		mu.Lock()
+		clock = at
+		mu.Unlock()
+		s.SetPace(1)
+		<-checker.seen
+	}
+
+	ticker.ch <- base
+	<-checker.seen
+	waitFor(StateHealthy, 0)
+
+	// After a pause shorter than FailAfter intervals, one failure isn't enough
+	resumeAt(base.Add(200 * time.Millisecond))
+	waitFor(StateHealthy, 1)
+
+	// After a longer one the old state is stale, so the first check decides
+	resumeAt(base.Add(2 * time.Second))
+	waitFor(StateUnhealthy, 1)
+}
+
+// addressChecker records the address of every check
+type addressChecker struct {
+	seen chan string
+}
+
+func (c *addressChecker) Check(address string, port int, timeout time.Duration) error {
+	c.seen <- address
+	return nil
+}
+
+func TestHealthSchedulerResolvesHostnameBackends(t *testing.T) {
+	// One ticker per runner, keyed by interval
+	tickers := map[time.Duration]*fakeTicker{
+		10 * time.Millisecond: newFakeTicker(),
+		20 * time.Millisecond: newFakeTicker(),
+	}
+	checker := &addressChecker{seen: make(chan string, 8)}
+	obs := &recordingObserver{}
+
+	s := NewScheduler(checker, obs)
+	s.SetTickerFactory(func(d time.Duration) Ticker { return tickers[d] })
+	s.SetResolver(staticResolver{"api.internal": {net.ParseIP("10.0.0.9")}})
+	t.Cleanup(s.Stop)
+
+	if err := s.Start([]Target{
+		{
+			Key:              BackendKey{Service: "svc", Backend: "api.internal"},
+			CheckPort:        8080,
+			Interval:         10 * time.Millisecond,
+			Timeout:          5 * time.Millisecond,
+			FailAfter:        1,
+			RecoverAfter:     1,
+			ConfiguredWeight: 1,
+		},
+		{
+			Key:              BackendKey{Service: "svc", Backend: "10.0.0.1"},
+			CheckPort:        8080,
+			Interval:         20 * time.Millisecond,
+			Timeout:          5 * time.Millisecond,
+			FailAfter:        1,
+			RecoverAfter:     1,
+			ConfiguredWeight: 1,
+		},
+	}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	tickers[10*time.Millisecond].ch <- time.Now()
+	if addr := <-checker.seen; addr != "10.0.0.9" {
+		t.Fatalf("expected hostname backend checked at 10.0.0.9, got %s", addr)
+	}
+	tickers[20*time.Millisecond].ch <- time.Now()
+	if addr := <-checker.seen; addr != "10.0.0.1" {
+		t.Fatalf("expected literal backend checked as-is, got %s", addr)
+	}
+
+	if addr, err := s.checkAddress("missing.internal"); err == nil {
+		t.Fatalf("expected lookup failure, got %q", addr)
+	}
+}
+
+func TestHealthSchedulerUsesCheckAddressOverride(t *testing.T) {
+	ticker := newFakeTicker()
+	checker := &addressChecker{seen: make(chan string, 8)}
+
+	s := NewScheduler(checker, &recordingObserver{})
+	s.SetTickerFactory(func(time.Duration) Ticker { return ticker })
+	t.Cleanup(s.Stop)
+
+	if err := s.Start([]Target{{
+		Key:              BackendKey{Service: "svc", Backend: "10.0.0.1"},
+		CheckAddress:     "10.0.1.1",
+		CheckPort:        9901,
+		Interval:         10 * time.Millisecond,
+		Timeout:          5 * time.Millisecond,
+		FailAfter:        1,
+		RecoverAfter:     1,
+		ConfiguredWeight: 1,
+	}}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	ticker.ch <- time.Now()
+	if addr := <-checker.seen; addr != "10.0.1.1" {
+		t.Fatalf("expected check against 10.0.1.1, got %s", addr)
+	}
+}
+
+func TestHealthSchedulerReplaceService(t *testing.T) {
+	s := NewScheduler(&addressChecker{seen: make(chan string, 8)}, &recordingObserver{})
+	s.SetTickerFactory(func(time.Duration) Ticker { return newFakeTicker() })
+	t.Cleanup(s.Stop)
+
+	target := func(service, backend string) Target {
+		return Target{
+			Key:              BackendKey{Service: service, Backend: backend},
+			CheckPort:        80,
+			Interval:         10 * time.Millisecond,
+			Timeout:          5 * time.Millisecond,
+			FailAfter:        1,
+			RecoverAfter:     1,
+			ConfiguredWeight: 1,
+		}
+	}
+	if err := s.Start([]Target{target("a", "10.0.0.1"), target("b", "10.0.0.2")}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	other, err := s.runner(BackendKey{Service: "b", Backend: "10.0.0.2"})
+	if err != nil {
+		t.Fatalf("runner(b): %v", err)
+	}
+
+	if err := s.ReplaceService("a", []Target{target("b", "10.0.0.3")}); err == nil {
+		t.Fatalf("expected a target of another service to be rejected")
+	}
+	if _, err := s.runner(BackendKey{Service: "a", Backend: "10.0.0.1"}); err != nil {
+		t.Fatalf("rejected replace stopped a runner: %v", err)
+	}
+
+	if err := s.ReplaceService("a", []Target{target("a", "10.0.0.4")}); err != nil {
+		t.Fatalf("ReplaceService() error = %v", err)
+	}
+	if _, err := s.runner(BackendKey{Service: "a", Backend: "10.0.0.1"}); err == nil {
+		t.Fatalf("expected the old target of a to be stopped")
+	}
+	if _, err := s.runner(BackendKey{Service: "a", Backend: "10.0.0.4"}); err != nil {
+		t.Fatalf("expected the new target of a to run: %v", err)
+	}
+	if r, err := s.runner(BackendKey{Service: "b", Backend: "10.0.0.2"}); err != nil || r != other {
+		t.Fatalf("expected service b's runner to be left alone")
+	}
+}
+
+// blockingChecker holds each check until released and tracks peak concurrency
+type blockingChecker struct {
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+	started  chan struct{}
+	release  chan struct{}
+}
+
+func (c *blockingChecker) Check(address string, port int, timeout time.Duration) error {
+	c.mu.Lock()
+	c.inFlight++
+	if c.inFlight > c.peak {
+		c.peak = c.inFlight
+	}
+	c.mu.Unlock()
+
+	c.started <- struct{}{}
+	<-c.release
+
+	c.mu.Lock()
+	c.inFlight--
+	c.mu.Unlock()
+	return nil
+}
+
+func TestHealthSchedulerMaxInFlight(t *testing.T) {
+	checker := &blockingChecker{started: make(chan struct{}, 16), release: make(chan struct{})}
+	s := NewScheduler(checker, &recordingObserver{})
+	s.SetMaxInFlight(2)
+
+	tickers := make(chan *fakeTicker, 8)
+	s.SetTickerFactory(func(d time.Duration) Ticker {
+		ft := newFakeTicker()
+		tickers <- ft
+		return ft
+	})
+
+	var targets []Target
+	for _, addr := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"} {
+		targets = append(targets, Target{
+			Key:              BackendKey{Service: "svc", Backend: addr},
+			CheckPort:        8080,
+			Interval:         10 * time.Millisecond,
+			Timeout:          5 * time.Millisecond,
+			FailAfter:        1,
+			RecoverAfter:     1,
+			ConfiguredWeight: 1,
+		})
+	}
+	if err := s.Start(targets); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	for range targets {
+		ft := <-tickers
+		ft.ch <- time.Now()
+	}
+
+	// Two checks start, the other two wait for a free worker
+	for i := 0; i < 2; i++ {
+		<-checker.started
+	}
+	select {
+	case <-checker.started:
+		t.Fatalf("expected at most 2 checks in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Release all four checks
+	go func() {
+		for i := 0; i < 4; i++ {
+			checker.release <- struct{}{}
+		}
+	}()
+	for i := 0; i < 2; i++ {
+		<-checker.started
+	}
+	s.Stop()
+
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+	if checker.peak != 2 {
+		t.Fatalf("expected peak concurrency 2, got %d", checker.peak)
+	}
+}
+
+type panicOnceChecker struct {
+	mu       sync.Mutex
+	panicked bool
+	seen     chan struct{}
+}
+
+func (c *panicOnceChecker) Check(address string, port int, timeout time.Duration) error {
+	c.mu.Lock()
+	first := !c.panicked
+	c.panicked = true
+	c.mu.Unlock()
+	if first {
+		panic("checker bug")
+	}
+	c.seen <- struct{}{}
+	return nil
+}
+
+func TestHealthSchedulerRecoversRunnerPanic(t *testing.T) {
+	checker := &panicOnceChecker{seen: make(chan struct{}, 4)}
+	s := NewScheduler(checker, &recordingObserver{})
+
+	recovered := make(chan routine.Panic, 1)
+	sup := routine.NewSupervisor(func(p routine.Panic) { recovered <- p })
+	sup.MinBackoff = time.Millisecond
+	s.SetSupervisor(sup)
+
+	tickers := make(chan *fakeTicker, 4)
+	s.SetTickerFactory(func(d time.Duration) Ticker {
+		ft := newFakeTicker()
+		tickers <- ft
+		return ft
+	})
+	t.Cleanup(s.Stop)
+
+	if err := s.Start([]Target{{
+		Key:              BackendKey{Service: "svc", Backend: "10.0.0.1"},
+		CheckPort:        8080,
+		Interval:         10 * time.Millisecond,
+		Timeout:          5 * time.Millisecond,
+		FailAfter:        1,
+		RecoverAfter:     1,
+		ConfiguredWeight: 1,
+	}}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	(<-tickers).ch <- time.Now()
+	select {
+	case p := <-recovered:
+		if p.Name != "health_runner" {
+			t.Fatalf("unexpected routine name: %s", p.Name)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("panic not recovered")
+	}
+
+	// The restarted runner creates a fresh ticker and keeps checking
+	select {
+	case ft := <-tickers:
+		ft.ch <- time.Now()
+	case <-time.After(time.Second):
+		t.Fatalf("runner not restarted")
+	}
+	select {
+	case <-checker.seen:
+	case <-time.After(time.Second):
+		t.Fatalf("restarted runner did not check")
+	}
+}
+
+func TestHealthTCPCheckerReset(t *testing.T) {
+	readErr := make(chan error, 1)
+	port := serveTCP(t, func(conn net.Conn) {
+		_, err := conn.Read(make([]byte, 1))
+		readErr <- err
+	})
+
+	c := &TCPChecker{Dialer: NetDialer{}, Reset: true}
+	if err := c.Check("127.0.0.1", port, time.Second); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	select {
+	case err := <-readErr:
+		if !errors.Is(err, syscall.ECONNRESET) {
+			t.Fatalf("expected the backend to see a reset, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("backend never saw the connection close")
+	}
+}
+
+// synDialer answers TCP SYNs over a pipe, first with a segment for another
+// port, then with SYN-ACK, or RST when refuse is set.
+type synDialer struct {
+	network string
+	refuse  bool
+}
+
+type rawConn struct {
+	net.Conn
+	local net.Addr
+}
+
+func (c rawConn) LocalAddr() net.Addr { return c.local }
+
+func (d *synDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	d.network = network
+	refuse := d.refuse
+	local := net.ParseIP("192.0.2.1")
+	if network == "ip6:tcp" {
+		local = net.ParseIP("2001:db8::1")
+	}
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		syn := make([]byte, 64)
+		n, err := server.Read(syn)
+		if err != nil || n < 20 || syn[13] != tcpFlagSYN {
+			return
+		}
+		// A valid checksum sums to zero over the pseudo-header and segment
+		dst := net.ParseIP(address)
+		check := synSegment(local, dst, binary.BigEndian.Uint16(syn[0:2]), binary.BigEndian.Uint16(syn[2:4]), binary.BigEndian.Uint32(syn[4:8]))
+		if !bytes.Equal(check, syn[:n]) {
+			return
+		}
+
+		reply := make([]byte, 20)
+		copy(reply[0:2], syn[2:4])
+		copy(reply[2:4], syn[0:2])
+		binary.BigEndian.PutUint32(reply[8:12], binary.BigEndian.Uint32(syn[4:8])+1)
+		reply[12] = 5 << 4
+		reply[13] = tcpFlagSYN | tcpFlagACK
+		if refuse {
+			reply[13] = tcpFlagRST | tcpFlagACK
+		}
+		stray := append([]byte(nil), reply...)
+		stray[0], stray[1] = 0, 1
+		_, _ = server.Write(stray)
+		_, _ = server.Write(reply)
+	}()
+	return rawConn{Conn: client, local: &net.IPAddr{IP: local}}, nil
+}
+
+func TestHealthHalfOpenChecker(t *testing.T) {
+	d := &synDialer{}
+	c := &HalfOpenChecker{Dialer: d}
+	if err := c.Check("10.0.0.1", 8080, 100*time.Millisecond); err != nil {
+		t.Fatalf("expected syn-ack to pass, got %v", err)
+	}
+	if d.network != "ip4:tcp" {
+		t.Fatalf("unexpected network %q", d.network)
+	}
+	if err := c.Check("2001:db8::2", 8080, 100*time.Millisecond); err != nil {
+		t.Fatalf("expected IPv6 syn-ack to pass, got %v", err)
+	}
+	if d.network != "ip6:tcp" {
+		t.Fatalf("unexpected network %q", d.network)
+	}
+
+	d.refuse = true
+	err := c.Check("10.0.0.1", 8080, 100*time.Millisecond)
+	if FailureReason(err) != ReasonRefused {
+		t.Fatalf("expected rst to fail as refused, got %v", err)
+	}
+}
+
+func TestHealthReuseTCPChecker(t *testing.T) {
+	var mu sync.Mutex
+	accepted := 0
+	release := make(chan struct{})
+	port := serveTCP(t, func(conn net.Conn) {
+		mu.Lock()
+		accepted++
+		first := accepted == 1
+		mu.Unlock()
+		if first {
+			<-release // Hold the first connection until the test drops it
+			return
+		}
+		_, _ = conn.Read(make([]byte, 1))
+	})
+	acceptCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return accepted
+	}
+
+	c := &ReuseTCPChecker{Dialer: NetDialer{}, Keepalive: time.Second}
+	for i := 0; i < 3; i++ {
+		if err := c.Check("127.0.0.1", port, time.Second); err != nil {
+			t.Fatalf("check %d: %v", i, err)
+		}
+	}
+	if n := acceptCount(); n != 1 {
+		t.Fatalf("expected one held connection, got %d accepts", n)
+	}
+
+	// The backend closing the held connection makes the next check redial
+	close(release)
+	deadline := time.Now().Add(2 * time.Second)
+	for acceptCount() < 2 && time.Now().Before(deadline) {
+		if err := c.Check("127.0.0.1", port, time.Second); err != nil {
+			t.Fatalf("check after close: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := acceptCount(); n != 2 {
+		t.Fatalf("expected a redial after the backend closed, got %d accepts", n)
+	}
+
+	if err := CloseChecker(c); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if len(c.conns) != 0 {
+		t.Fatalf("expected Close to drop held connections, got %d", len(c.conns))
+	}
+}
+
+func TestReuseTCPCheckerKeepalive(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		keepalive   time.Duration
+		checkEvery  time.Duration
+		timeout     time.Duration
+		idle        time.Duration
+		interval    time.Duration
+		userTimeout time.Duration
+	}{
+		{"check interval", 0, 5 * time.Second, 2 * time.Second, 5 * time.Second, 2 * time.Second, 9 * time.Second},
+		{"explicit keepalive", 15 * time.Second, 5 * time.Second, 2 * time.Second, 15 * time.Second, 2 * time.Second, 19 * time.Second},
+		{"whole seconds", 0, 0, 200 * time.Millisecond, time.Second, time.Second, 3 * time.Second},
+	} {
+		c := &ReuseTCPChecker{Keepalive: tc.keepalive, Interval: tc.checkEvery}
+		cfg, userTimeout := c.keepalive(tc.timeout)
+		if !cfg.Enable || cfg.Idle != tc.idle || cfg.Interval != tc.interval || cfg.Count != reuseKeepaliveProbes || userTimeout != tc.userTimeout {
+			t.Errorf("%s: got %+v, user timeout %s", tc.name, cfg, userTimeout)
+		}
+	}
+}
+
+func TestHealthPortRangeDialer(t *testing.T) {
+	port := serveTCP(t, func(conn net.Conn) {
+		_, _ = conn.Read(make([]byte, 1))
+	})
+
+	// Borrow a free port from the kernel for the range
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	src := ln.Addr().(*net.TCPAddr).Port
+	_ = ln.Close()
+
+	d := &PortRangeDialer{Min: src, Max: src, ReuseAddr: true}
+	conn, err := d.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), time.Second)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	if got := conn.LocalAddr().(*net.TCPAddr).Port; got != src {
+		t.Fatalf("expected source port %d, got %d", src, got)
+	}
+
+	// The only port of the range is now taken
+	d = &PortRangeDialer{Min: src, Max: src}
+	if _, err := d.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), time.Second); err == nil {
+		t.Fatalf("expected dial to fail with the range exhausted")
+	}
+	_ = conn.Close()
+
+	d = &PortRangeDialer{Min: 2000, Max: 1000}
+	if _, err := d.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), time.Second); err == nil {
+		t.Fatalf("expected an invalid range to be rejected")
+	}
+}
@@ -0,0 +1,146 @@
+package health
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+)
+
+// ErrHostDown marks a check that failed because the backend didn't answer an
+// ICMP echo, as opposed to the host answering but its service failing.
+var ErrHostDown = errors.New("host down")
+
+// Pinger checks that a host is reachable at all
+type Pinger interface {
+	Ping(address string, timeout time.Duration) error
+}
+
+const (
+	icmpv4EchoRequest = 8
+	icmpv4EchoReply   = 0
+	icmpv6EchoRequest = 128
+	icmpv6EchoReply   = 129
+)
+
+// pingSeq gives concurrent pings distinct sequence numbers, since a raw socket
+// sees every echo reply from its peer.
+var pingSeq atomic.Uint32
+
+// ICMPPinger sends one ICMP echo request per Ping over a raw socket, which
+// needs CAP_NET_RAW (already required for IPVS).
+type ICMPPinger struct {
+	Dialer Dialer
+}
+
+func (p *ICMPPinger) Ping(address string, timeout time.Duration) error {
+	if p == nil || p.Dialer == nil {
+		return fmt.Errorf("missing dialer")
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return errdefs.PermanentConfig(fmt.Errorf("invalid address: %s", address))
+	}
+	if timeout <= 0 {
+		return errdefs.PermanentConfig(fmt.Errorf("invalid timeout: %s", timeout))
+	}
+
+	v4 := ip.To4() != nil
+	network, request, reply := "ip4:icmp", byte(icmpv4EchoRequest), byte(icmpv4EchoReply)
+	if !v4 {
+		network, request, reply = "ip6:ipv6-icmp", icmpv6EchoRequest, icmpv6EchoReply
+	}
+
+	conn, err := p.Dialer.DialTimeout(network, address, timeout)
+	if err != nil {
+		return errdefs.Classify(err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+
+	id, seq := uint16(os.Getpid()), uint16(pingSeq.Add(1))
+	msg := []byte{request, 0, 0, 0, byte(id >> 8), byte(id), byte(seq >> 8), byte(seq), 'l', 'b', 'c', 't', 'l'}
+	// The kernel fills in the ICMPv6 checksum
+	if v4 {
+		sum := icmpChecksum(msg)
+		msg[2], msg[3] = byte(sum>>8), byte(sum)
+	}
+	if _, err := conn.Write(msg); err != nil {
+		return errdefs.Classify(err)
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return fmt.Errorf("no icmp echo reply from %s: %w", address, err)
+		}
+		b := buf[:n]
+		// Some platforms return IPv4 raw reads with the IP header attached
+		if v4 && len(b) >= 20 && b[0]>>4 == 4 {
+			b = b[int(b[0]&0x0f)*4:]
+		}
+		if len(b) >= 8 && b[0] == reply &&
+			uint16(b[4])<<8|uint16(b[5]) == id && uint16(b[6])<<8|uint16(b[7]) == seq {
+			return nil
+		}
+	}
+}
+
+// icmpChecksum is the RFC 1071 internet checksum
+func icmpChecksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// PingPrecheck pings a backend before running Checker and fails fast with
+// ErrHostDown when the host doesn't answer. Both share the check timeout.
+type PingPrecheck struct {
+	Pinger  Pinger
+	Checker Checker
+	Clock   clock.Clock // Times the ping; nil uses the real clock
+}
+
+// Close closes the wrapped checker
+func (c *PingPrecheck) Close() error {
+	return CloseChecker(c.Checker)
+}
+
+func (c *PingPrecheck) Check(address string, port int, timeout time.Duration) error {
+	if c == nil || c.Pinger == nil || c.Checker == nil {
+		return fmt.Errorf("missing pinger or checker")
+	}
+
+	clk := c.Clock
+	if clk == nil {
+		clk = clock.Real()
+	}
+	start := clk.Now()
+	if err := c.Pinger.Ping(address, timeout); err != nil {
+		if errors.Is(err, errdefs.ErrPermanentConfig) || errors.Is(err, errdefs.ErrPermission) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", ErrHostDown, err)
+	}
+	remaining := timeout - clk.Now().Sub(start)
+	if remaining <= 0 {
+		return fmt.Errorf("check %s:%d after icmp precheck: %w", address, port, os.ErrDeadlineExceeded)
+	}
+	return c.Checker.Check(address, port, remaining)
+}
@@ -0,0 +1,144 @@
+package health
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+)
+
+// Override forces what the scheduler reports for a backend, regardless of
+// probe results. Probes keep running underneath, so clearing an override
+// reports the backend's real state immediately.
+type Override string
+
+const (
+	OverrideNone      Override = ""
+	OverrideHealthy   Override = "healthy"   // Report HEALTHY at configured weight
+	OverrideUnhealthy Override = "unhealthy" // Report UNHEALTHY at weight 0
+	OverrideDrain     Override = "drain"     // Keep the probed state but set weight 0
+)
+
+func ParseOverride(s string) (Override, error) {
+	switch o := Override(strings.ToLower(s)); o {
+	case OverrideHealthy, OverrideUnhealthy, OverrideDrain:
+		return o, nil
+	}
+	return OverrideNone, fmt.Errorf("invalid health override: %s", s)
+}
+
+// OverrideChange describes an override being set, cleared or expiring
+type OverrideChange struct {
+	Key     BackendKey
+	Old     Override
+	New     Override
+	Expires time.Time // Zero when New has no TTL
+	Reason  string    // "set", "cleared" or "expired"; the daemon also reports "restored"
+	Caller  string    // Who set or cleared it, when the daemon knows
+}
+
+// OverrideObserver is optionally implemented by an Observer to learn about
+// operator overrides.
+type OverrideObserver interface {
+	OnOverride(change OverrideChange)
+}
+
+// OverrideInfo is an active override as returned by Overrides
+type OverrideInfo struct {
+	Mode    Override
+	Expires time.Time
+}
+
+// SetOverride forces the reported state of a backend until ClearOverride is
+// called or, with a non-zero expires, until the first check at or after it.
+// caller is passed on to the OverrideObserver.
+func (s *Scheduler) SetOverride(key BackendKey, mode Override, expires time.Time, caller string) error {
+	if _, err := ParseOverride(string(mode)); err != nil {
+		return err
+	}
+	r, err := s.runner(key)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	old := r.override
+	r.override, r.overrideExpires = mode, expires
+	rep := r.report("override", s.now())
+	r.mu.Unlock()
+
+	s.notifyOverride(OverrideChange{Key: key, Old: old, New: mode, Expires: expires, Reason: "set", Caller: caller})
+	s.notify(key, rep)
+	return nil
+}
+
+// ClearOverride removes a backend's override. Clearing a backend without one
+// is a no-op.
+func (s *Scheduler) ClearOverride(key BackendKey, caller string) error {
+	r, err := s.runner(key)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	old := r.override
+	if old == OverrideNone {
+		r.mu.Unlock()
+		return nil
+	}
+	r.override, r.overrideExpires = OverrideNone, time.Time{}
+	rep := r.report("override_cleared", s.now())
+	r.mu.Unlock()
+
+	s.notifyOverride(OverrideChange{Key: key, Old: old, New: OverrideNone, Reason: "cleared", Caller: caller})
+	s.notify(key, rep)
+	return nil
+}
+
+// Overrides returns the active overrides by backend
+func (s *Scheduler) Overrides() map[BackendKey]OverrideInfo {
+	s.mu.Lock()
+	runners := make([]*runner, 0, len(s.runners))
+	for _, r := range s.runners {
+		runners = append(runners, r)
+	}
+	s.mu.Unlock()
+
+	out := make(map[BackendKey]OverrideInfo)
+	for _, r := range runners {
+		r.mu.Lock()
+		if r.override != OverrideNone {
+			out[r.target.Key] = OverrideInfo{Mode: r.override, Expires: r.overrideExpires}
+		}
+		r.mu.Unlock()
+	}
+	return out
+}
+
+func (s *Scheduler) runner(key BackendKey) (*runner, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.runners[key]
+	if !ok {
+		return nil, errdefs.WithCode(errdefs.CodeNotFound, fmt.Errorf("unknown backend: %s/%s", key.Service, key.Backend))
+	}
+	return r, nil
+}
+
+func (s *Scheduler) notifyOverride(change OverrideChange) {
+	if oo, ok := s.obs.(OverrideObserver); ok {
+		oo.OnOverride(change)
+	}
+}
+
+// expireOverride clears an override whose TTL has passed. The caller must
+// hold r.mu.
+func (r *runner) expireOverride(now time.Time) (OverrideChange, bool) {
+	if r.override == OverrideNone || r.overrideExpires.IsZero() || now.Before(r.overrideExpires) {
+		return OverrideChange{}, false
+	}
+	change := OverrideChange{Key: r.target.Key, Old: r.override, New: OverrideNone, Reason: "expired"}
+	r.override, r.overrideExpires = OverrideNone, time.Time{}
+	return change, true
+}
@@ -0,0 +1,58 @@
+package health
+
+import (
+	"sync"
+	"time"
+)
+
+// ProbeResult is the outcome of a single one-shot check of a target
+type ProbeResult struct {
+	Key     BackendKey
+	Address string // Address probed, after CheckAddress and resolution
+	Port    int
+	Latency time.Duration
+	Err     error
+}
+
+// ProbeTargets checks every target exactly once, without a Scheduler. Results
+// are raw probe outcomes: fail_after/recover_after thresholds and overrides are
+// not applied. Targets without their own Checker use checker; r may be nil
+// when all backends are IPs. maxInFlight bounds concurrent checks (0 means
+// unbounded). Results are returned in target order.
+func ProbeTargets(targets []Target, checker Checker, r Resolver, maxInFlight int) []ProbeResult {
+	results := make([]ProbeResult, len(targets))
+	if maxInFlight <= 0 || maxInFlight > len(targets) {
+		maxInFlight = len(targets)
+	}
+	sem := make(chan struct{}, maxInFlight)
+
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, t Target) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = probeOnce(t, checker, r)
+		}(i, t)
+	}
+	wg.Wait()
+	return results
+}
+
+func probeOnce(t Target, checker Checker, r Resolver) ProbeResult {
+	if t.Checker != nil {
+		checker = t.Checker
+	}
+	res := ProbeResult{Key: t.Key, Address: t.probeHost(), Port: t.CheckPort}
+
+	start := time.Now()
+	address, err := resolveAddress(r, res.Address)
+	if err == nil {
+		res.Address = address
+		err = checker.Check(address, t.CheckPort, t.Timeout)
+	}
+	res.Latency = time.Since(start)
+	res.Err = err
+	return res
+}
@@ -0,0 +1,217 @@
+package health
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+)
+
+// RedisChecker sends PING and requires +PONG. A -NOAUTH reply also passes:
+// the server is up and speaking RESP, it just wants credentials we don't hold.
+type RedisChecker struct {
+	Dialer Dialer
+}
+
+func (c *RedisChecker) Check(address string, port int, timeout time.Duration) error {
+	if c == nil || c.Dialer == nil {
+		return fmt.Errorf("missing dialer")
+	}
+	conn, err := dialProtocol(c.Dialer, address, port, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("*1\r\n$4\r\nPING\r\n")); err != nil {
+		return err
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("redis read failed: %w", err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	switch {
+	case line == "+PONG":
+		return nil
+	case strings.HasPrefix(line, "-NOAUTH"):
+		return nil
+	default:
+		return fmt.Errorf("unexpected redis reply: %q", line)
+	}
+}
+
+// DefaultMySQLCheckUser is the user MySQLChecker logs in as when none is set
+const DefaultMySQLCheckUser = "lbctl"
+
+// MySQL capability flags sent in the handshake response
+const (
+	mysqlClientLongPassword     = 0x00000001
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientSecureConnection = 0x00008000
+)
+
+// mysqlErrAccessDenied is ER_ACCESS_DENIED_ERROR
+const mysqlErrAccessDenied = 1045
+
+// MySQLChecker completes a login as User with an empty password and ends the
+// session with COM_QUIT, like HAProxy's mysql-check. Closing after the
+// greeting instead would count against the server's max_connect_errors and
+// eventually block the balancer's address. The greeting must be protocol
+// version 10. Access denied passes: the server is up, it just doesn't know
+// User. So does an AuthSwitchRequest, which MySQL 8 sends even for unknown
+// users; the check answers it with the empty password so the login still
+// ends cleanly. Any other error packet fails with the server's message.
+type MySQLChecker struct {
+	Dialer Dialer
+	User   string // Default DefaultMySQLCheckUser
+}
+
+func (c *MySQLChecker) Check(address string, port int, timeout time.Duration) error {
+	if c == nil || c.Dialer == nil {
+		return fmt.Errorf("missing dialer")
+	}
+	conn, err := dialProtocol(c.Dialer, address, port, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	seq, payload, err := readMySQLPacket(conn)
+	if err != nil {
+		return fmt.Errorf("mysql handshake read failed: %w", err)
+	}
+	switch payload[0] {
+	case 0x0a:
+	case 0xff:
+		return mysqlError(payload)
+	default:
+		return fmt.Errorf("unsupported mysql protocol version: %d", payload[0])
+	}
+	// Version string, then thread id (4), auth data (8), filler (1) and the
+	// low capability flags (2)
+	end := bytes.IndexByte(payload[1:], 0)
+	if end < 0 || len(payload) < 1+end+1+15 {
+		return fmt.Errorf("malformed mysql handshake")
+	}
+	caps := binary.LittleEndian.Uint16(payload[1+end+1+13:])
+	if caps&mysqlClientProtocol41 == 0 {
+		return fmt.Errorf("mysql server does not support protocol 4.1")
+	}
+
+	user := c.User
+	if user == "" {
+		user = DefaultMySQLCheckUser
+	}
+	if err := writeMySQLPacket(conn, seq+1, mysqlHandshakeResponse(user)); err != nil {
+		return err
+	}
+	seq, payload, err = readMySQLPacket(conn)
+	if err != nil {
+		return fmt.Errorf("mysql login read failed: %w", err)
+	}
+	if payload[0] == 0xfe {
+		// AuthSwitchRequest: the server answered, so it is up whatever
+		// comes next
+		if err := writeMySQLPacket(conn, seq+1, nil); err != nil {
+			return nil
+		}
+		if _, payload, err = readMySQLPacket(conn); err != nil {
+			return nil
+		}
+	}
+	switch payload[0] {
+	case 0x00:
+	case 0x01, 0xfe:
+		// AuthMoreData or another switch after the first: up, but the
+		// login can't finish without a password
+		return nil
+	case 0xff:
+		if len(payload) >= 3 && binary.LittleEndian.Uint16(payload[1:3]) == mysqlErrAccessDenied {
+			return nil
+		}
+		return mysqlError(payload)
+	default:
+		return fmt.Errorf("unexpected mysql login reply: 0x%02x", payload[0])
+	}
+	// COM_QUIT; the server closes without replying
+	return writeMySQLPacket(conn, 0, []byte{0x01})
+}
+
+// mysqlHandshakeResponse builds a HandshakeResponse41 for user with an empty
+// password.
+func mysqlHandshakeResponse(user string) []byte {
+	b := make([]byte, 32, 32+len(user)+2)
+	binary.LittleEndian.PutUint32(b[0:4], mysqlClientLongPassword|mysqlClientProtocol41|mysqlClientSecureConnection)
+	binary.LittleEndian.PutUint32(b[4:8], 1<<24) // Max packet size
+	b[8] = 0x21                                  // utf8_general_ci; 9-31 are reserved zeros
+	b = append(b, user...)
+	return append(b, 0, 0) // NUL terminator, empty auth response
+}
+
+func readMySQLPacket(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	if length == 0 || length > 1<<16 {
+		return 0, nil, fmt.Errorf("invalid mysql packet length: %d", length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[3], payload, nil
+}
+
+func writeMySQLPacket(w io.Writer, seq byte, payload []byte) error {
+	n := len(payload)
+	_, err := w.Write(append([]byte{byte(n), byte(n >> 8), byte(n >> 16), seq}, payload...))
+	return err
+}
+
+// mysqlError formats an error packet: 0xff, 2-byte code, message
+func mysqlError(payload []byte) error {
+	if len(payload) < 3 {
+		return fmt.Errorf("mysql error packet")
+	}
+	code := binary.LittleEndian.Uint16(payload[1:3])
+	return fmt.Errorf("mysql error %d: %s", code, mysqlErrorMessage(payload[3:]))
+}
+
+// mysqlErrorMessage strips the optional "#" + 5-char SQL state marker
+func mysqlErrorMessage(b []byte) string {
+	if len(b) >= 6 && b[0] == '#' {
+		b = b[6:]
+	}
+	return string(b)
+}
+
+func dialProtocol(dialer Dialer, address string, port int, timeout time.Duration) (net.Conn, error) {
+	if net.ParseIP(address) == nil {
+		return nil, errdefs.PermanentConfig(fmt.Errorf("invalid address: %s", address))
+	}
+	if port < 1 || port > 65535 {
+		return nil, errdefs.PermanentConfig(fmt.Errorf("invalid port: %d", port))
+	}
+	if timeout <= 0 {
+		return nil, errdefs.PermanentConfig(fmt.Errorf("invalid timeout: %s", timeout))
+	}
+
+	conn, err := dialer.DialTimeout("tcp", fmt.Sprintf("%s:%d", address, port), timeout)
+	if err != nil {
+		return nil, errdefs.Classify(err)
+	}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
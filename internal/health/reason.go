@@ -0,0 +1,56 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+)
+
+// Failure reasons reported by FailureReason. The set is small and fixed so it
+// can be used as a metrics label.
+const (
+	ReasonHostDown    = "host_down" // No ICMP echo reply; the service wasn't probed
+	ReasonTimeout     = "timeout"
+	ReasonRefused     = "refused"
+	ReasonReset       = "reset"
+	ReasonUnreachable = "unreachable"
+	ReasonDNS         = "dns"
+	ReasonConfig      = "config"
+	ReasonPermission  = "permission"
+	ReasonOther       = "other"
+)
+
+// FailureReason classifies a check error, e.g. "refused" vs "timeout". It
+// returns "" for a nil error.
+func FailureReason(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	switch {
+	case errors.Is(err, ErrHostDown):
+		return ReasonHostDown
+	case errors.As(err, &dnsErr):
+		return ReasonDNS
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return ReasonTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ReasonRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return ReasonReset
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return ReasonUnreachable
+	case errors.Is(err, errdefs.ErrPermanentConfig):
+		return ReasonConfig
+	case errors.Is(err, errdefs.ErrPermission), errors.Is(err, os.ErrPermission):
+		return ReasonPermission
+	}
+	return ReasonOther
+}
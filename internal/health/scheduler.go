@@ -2,8 +2,16 @@ package health
 
 import (
 	"fmt"
+	"math"
+	"math/rand"
+	"net"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+	"github.com/malindarathnayake/LibraFlux/internal/routine"
 )
 
 type State string
@@ -21,18 +29,34 @@ type BackendKey struct {
 
 type Target struct {
 	Key              BackendKey
+	CheckAddress     string // Address to probe; defaults to Key.Backend
 	CheckPort        int
 	Interval         time.Duration
 	Timeout          time.Duration
 	FailAfter        int
 	RecoverAfter     int
 	ConfiguredWeight int
+	Checker          Checker       // Optional per-target override of the scheduler's checker
+	Jitter           time.Duration // Max random delay before the first check, spreading runners over the interval
+	SlowStart        time.Duration // Ramp weight up over this long after recovering; 0 disables
+	SlowStartSteps   int           // Number of weight increments during the ramp (default 4)
+	AdaptiveWeight   bool          // Scale weight down as check latency rises above the service's fastest backend
+	Override         Override      // Initial operator override, e.g. carried across a scheduler restart
+	OverrideExpires  time.Time     // Zero means the override has no TTL
 }
 
 type StateChange struct {
 	Key BackendKey
 	Old State
 	New State
+
+	// Context from the check that caused the change. Error and Reason are
+	// empty when the backend is healthy or the change came from an override.
+	Error                string // Last check error
+	Reason               string // FailureReason of Error, e.g. "refused" or "timeout"
+	ConsecutiveFailures  int
+	ConsecutiveSuccesses int
+	Time                 time.Time
 }
 
 type WeightChange struct {
@@ -47,17 +71,21 @@ type Observer interface {
 	OnWeightChange(change WeightChange)
 }
 
-type Ticker interface {
-	C() <-chan time.Time
-	Stop()
+// CheckResult is the outcome of a single check
+type CheckResult struct {
+	Key      BackendKey
+	Duration time.Duration
+	Latency  time.Duration // Smoothed (EWMA) latency of successful checks; 0 until one succeeds
+	Err      error
 }
 
-type realTicker struct {
-	t *time.Ticker
+// CheckObserver is optionally implemented by an Observer to receive every
+// check result, e.g. to record round-trip latency.
+type CheckObserver interface {
+	OnCheck(result CheckResult)
 }
 
-func (rt realTicker) C() <-chan time.Time { return rt.t.C }
-func (rt realTicker) Stop()               { rt.t.Stop() }
+type Ticker = clock.Ticker
 
 type tickerFactory func(d time.Duration) Ticker
 
@@ -68,30 +96,78 @@ type Scheduler struct {
 	mu      sync.Mutex
 	runners map[BackendKey]*runner
 	tickers tickerFactory
+	timers  func(d time.Duration) clock.Timer
+	now     func() time.Time
+	jitter  func(max time.Duration) time.Duration
 	stopped bool
+	stopCh  chan struct{}
+
+	// Smoothed latency per healthy backend, for adaptive weighting
+	latMu   sync.Mutex
+	latency map[BackendKey]time.Duration
+
+	// Resolves non-IP backends before each check; nil checks them as given
+	resolver Resolver
+
+	// Restarts runners and workers that panic; nil lets panics propagate
+	supervisor *routine.Supervisor
+	tracker    *routine.Tracker
+
+	// Worker pool; nil work channel means each runner checks inline
+	maxInFlight int
+	work        chan *runner
+	workers     sync.WaitGroup
+
+	// Runners check on every pace-th tick; 0 pauses checks
+	pace atomic.Int32
 }
 
 type runner struct {
 	target Target
-	
+
 	mu                   sync.Mutex // Protects state fields below
 	state                State
 	consecutiveSuccesses int
 	consecutiveFailures  int
+	lastErr              error     // Error from the most recent check; nil after a success
+	lastCheck            time.Time // When the most recent check finished; zero before the first
 	effectiveWeight      int
+	rampStart            time.Time // Set while slow-start is ramping weight up
+
+	// Operator override applied on top of the probed state and weight
+	override        Override
+	overrideExpires time.Time
+	reportedState   State
+	reportedWeight  int
 
+	queued atomic.Bool // A check is waiting for or running on a pool worker
+
+	kick   chan struct{} // Check now instead of waiting for the next due tick
 	stopCh chan struct{}
 	doneCh chan struct{}
-	ticker Ticker
 }
 
 func NewScheduler(checker Checker, observer Observer) *Scheduler {
-	return &Scheduler{
+	s := &Scheduler{
 		checker: checker,
 		obs:     observer,
 		runners: make(map[BackendKey]*runner),
-		tickers: func(d time.Duration) Ticker { return realTicker{t: time.NewTicker(d)} },
+		tickers: clock.Real().NewTicker,
+		timers:  clock.Real().NewTimer,
+		now:     time.Now,
+		jitter:  randomJitter,
+		stopCh:  make(chan struct{}),
+		latency: make(map[BackendKey]time.Duration),
+	}
+	s.pace.Store(1)
+	return s
+}
+
+func randomJitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
 	}
+	return time.Duration(rand.Int63n(int64(max)))
 }
 
 func (s *Scheduler) SetTickerFactory(factory func(d time.Duration) Ticker) {
@@ -100,6 +176,70 @@ func (s *Scheduler) SetTickerFactory(factory func(d time.Duration) Ticker) {
 	s.tickers = factory
 }
 
+// SetClock drives tickers, start delays and check timing from c. It must be
+// called before Start.
+func (s *Scheduler) SetClock(c clock.Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tickers = c.NewTicker
+	s.timers = c.NewTimer
+	s.now = c.Now
+}
+
+// SetResolver resolves backends given as hostnames before each check. It must
+// be called before Start.
+func (s *Scheduler) SetResolver(r Resolver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resolver = r
+}
+
+// SetSupervisor recovers panics in runner and worker goroutines and restarts
+// them. It must be called before Start.
+func (s *Scheduler) SetSupervisor(sup *routine.Supervisor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.supervisor = sup
+}
+
+// SetTracker records runner and worker goroutines for leak checks. It must be
+// called before Start.
+func (s *Scheduler) SetTracker(t *routine.Tracker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tracker = t
+}
+
+// SetMaxInFlight bounds the number of concurrent checks across all targets.
+// It must be called before Start; n <= 0 means no limit.
+func (s *Scheduler) SetMaxInFlight(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxInFlight = n
+}
+
+// SetPace checks each target only on every nth tick of its interval; n <= 0
+// pauses checks. Ticks without a check still expire overrides. Raising the
+// pace checks every target at once instead of waiting for its next due tick.
+// It may be called at any time.
+func (s *Scheduler) SetPace(n int) {
+	if n < 0 {
+		n = 0
+	}
+	old := int(s.pace.Swap(int32(n)))
+	if n == 0 || (old != 0 && n >= old) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.runners {
+		select {
+		case r.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
 func (s *Scheduler) Start(targets []Target) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -110,6 +250,14 @@ func (s *Scheduler) Start(targets []Target) error {
 	if s.checker == nil {
 		return fmt.Errorf("missing checker")
 	}
+	if s.maxInFlight > 0 && s.work == nil {
+		s.work = make(chan *runner)
+		for i := 0; i < s.maxInFlight; i++ {
+			s.workers.Add(1)
+			sup := s.supervisor
+			s.tracker.Go("health_worker", func() { s.worker(sup) })
+		}
+	}
 	for _, t := range targets {
 		if err := validateTarget(t); err != nil {
 			return err
@@ -117,21 +265,83 @@ func (s *Scheduler) Start(targets []Target) error {
 		if _, exists := s.runners[t.Key]; exists {
 			return fmt.Errorf("duplicate target: %s/%s", t.Key.Service, t.Key.Backend)
 		}
+		s.startRunner(t)
+	}
+	return nil
+}
+
+// ReplaceService stops the runners of one service and starts targets in
+// their place, leaving every other service's runners and state untouched.
+// All targets must belong to service. Nothing is stopped if a target is
+// invalid.
+func (s *Scheduler) ReplaceService(service string, targets []Target) error {
+	seen := make(map[BackendKey]bool, len(targets))
+	for _, t := range targets {
+		if t.Key.Service != service {
+			return fmt.Errorf("target %s/%s does not belong to service %s", t.Key.Service, t.Key.Backend, service)
+		}
+		if err := validateTarget(t); err != nil {
+			return err
+		}
+		if seen[t.Key] {
+			return fmt.Errorf("duplicate target: %s/%s", t.Key.Service, t.Key.Backend)
+		}
+		seen[t.Key] = true
+	}
+
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return fmt.Errorf("scheduler stopped")
+	}
+	var old []*runner
+	for key, r := range s.runners {
+		if key.Service == service {
+			old = append(old, r)
+			delete(s.runners, key)
+		}
+	}
+	s.mu.Unlock()
 
-		r := &runner{
-			target:          t,
-			state:           StateUnknown,
-			effectiveWeight: -1,
-			stopCh:          make(chan struct{}),
-			doneCh:          make(chan struct{}),
+	for _, r := range old {
+		close(r.stopCh)
+		<-r.doneCh
+		s.forgetLatency(r.target.Key)
+		if r.target.Checker != nil {
+			_ = CloseChecker(r.target.Checker)
 		}
-		r.ticker = s.tickers(t.Interval)
-		s.runners[t.Key] = r
-		go s.run(r)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return fmt.Errorf("scheduler stopped")
+	}
+	for _, t := range targets {
+		s.startRunner(t)
 	}
 	return nil
 }
 
+// startRunner starts checking t. The caller must hold s.mu.
+func (s *Scheduler) startRunner(t Target) {
+	r := &runner{
+		target:          t,
+		state:           StateUnknown,
+		effectiveWeight: -1,
+		override:        t.Override,
+		overrideExpires: t.OverrideExpires,
+		reportedState:   StateUnknown,
+		reportedWeight:  -1,
+		kick:            make(chan struct{}, 1),
+		stopCh:          make(chan struct{}),
+		doneCh:          make(chan struct{}),
+	}
+	s.runners[t.Key] = r
+	sup, tickers, work, delay := s.supervisor, s.tickers, s.work, s.jitter(t.Jitter)
+	s.tracker.Go("health_runner", func() { s.supervise(r, sup, tickers, work, delay) })
+}
+
 func (s *Scheduler) Stop() {
 	s.mu.Lock()
 	if s.stopped {
@@ -145,61 +355,208 @@ func (s *Scheduler) Stop() {
 	}
 	s.mu.Unlock()
 
+	close(s.stopCh)
 	for _, r := range runners {
 		close(r.stopCh)
-		if r.ticker != nil {
-			r.ticker.Stop()
-		}
 		<-r.doneCh
 	}
+
+	// Runners are gone, so nothing sends on work anymore
+	if s.work != nil {
+		close(s.work)
+		s.workers.Wait()
+	}
+
+	// Targets may share a checker; closing one twice is harmless
+	for _, r := range runners {
+		if r.target.Checker != nil {
+			_ = CloseChecker(r.target.Checker)
+		}
+	}
+}
+
+func (s *Scheduler) worker(sup *routine.Supervisor) {
+	defer s.workers.Done()
+	sup.Run("health_worker", s.stopCh, func() {
+		for r := range s.work {
+			s.checkQueued(r)
+		}
+	})
+}
+
+func (s *Scheduler) checkQueued(r *runner) {
+	// Clear even on panic so the runner isn't stuck skipping ticks
+	defer r.queued.Store(false)
+	s.tick(r)
+}
+
+func (s *Scheduler) supervise(r *runner, sup *routine.Supervisor, tickers tickerFactory, work chan<- *runner, delay time.Duration) {
+	defer close(r.doneCh)
+	sup.Run("health_runner", r.stopCh, func() {
+		// Only the first run waits out the jitter delay
+		d := delay
+		delay = 0
+		s.run(r, tickers, work, d)
+	})
+}
+
+// probeHost returns the address checks are sent to
+func (t Target) probeHost() string {
+	if t.CheckAddress != "" {
+		return t.CheckAddress
+	}
+	return t.Key.Backend
 }
 
 func validateTarget(t Target) error {
 	if t.Key.Service == "" {
-		return fmt.Errorf("missing service name")
+		return errdefs.PermanentConfig(fmt.Errorf("missing service name"))
 	}
 	if t.Key.Backend == "" {
-		return fmt.Errorf("missing backend address")
+		return errdefs.PermanentConfig(fmt.Errorf("missing backend address"))
 	}
 	if t.CheckPort < 1 || t.CheckPort > 65535 {
-		return fmt.Errorf("invalid check port: %d", t.CheckPort)
+		return errdefs.PermanentConfig(fmt.Errorf("invalid check port: %d", t.CheckPort))
 	}
 	if t.Interval <= 0 {
-		return fmt.Errorf("invalid interval: %s", t.Interval)
+		return errdefs.PermanentConfig(fmt.Errorf("invalid interval: %s", t.Interval))
 	}
 	if t.Timeout <= 0 {
-		return fmt.Errorf("invalid timeout: %s", t.Timeout)
+		return errdefs.PermanentConfig(fmt.Errorf("invalid timeout: %s", t.Timeout))
 	}
 	if t.FailAfter < 1 {
-		return fmt.Errorf("invalid fail_after: %d", t.FailAfter)
+		return errdefs.PermanentConfig(fmt.Errorf("invalid fail_after: %d", t.FailAfter))
 	}
 	if t.RecoverAfter < 1 {
-		return fmt.Errorf("invalid recover_after: %d", t.RecoverAfter)
+		return errdefs.PermanentConfig(fmt.Errorf("invalid recover_after: %d", t.RecoverAfter))
+	}
+	if t.Jitter < 0 || t.Jitter > t.Interval {
+		return errdefs.PermanentConfig(fmt.Errorf("invalid jitter: %s", t.Jitter))
+	}
+	if t.SlowStart < 0 || t.SlowStartSteps < 0 {
+		return errdefs.PermanentConfig(fmt.Errorf("invalid slow start: %s / %d steps", t.SlowStart, t.SlowStartSteps))
+	}
+	if t.Override != OverrideNone {
+		if _, err := ParseOverride(string(t.Override)); err != nil {
+			return errdefs.PermanentConfig(err)
+		}
+	}
+	// Weight is only re-evaluated on ticks, so shorter steps would be skipped
+	if t.SlowStart > 0 && t.SlowStart/time.Duration(slowStartSteps(t)) < t.Interval {
+		return errdefs.PermanentConfig(fmt.Errorf("slow start step shorter than interval: %s / %d steps", t.SlowStart, slowStartSteps(t)))
 	}
 	return nil
 }
 
-func (s *Scheduler) run(r *runner) {
-	defer close(r.doneCh)
+// run waits out the start delay before creating the ticker, so each runner's
+// ticks stay offset from the others for its whole lifetime.
+func (s *Scheduler) run(r *runner, tickers tickerFactory, work chan<- *runner, delay time.Duration) {
+	if delay > 0 {
+		timer := s.timers(delay)
+		select {
+		case <-r.stopCh:
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+	}
+
+	ticker := tickers(r.target.Interval)
+	defer ticker.Stop()
+	ticks := 0
 	for {
 		select {
 		case <-r.stopCh:
 			return
-		case <-r.ticker.C():
+		case <-r.kick:
+			ticks = 0
+			s.resume(r)
+		case <-ticker.C():
+			ticks++
+			if pace := int(s.pace.Load()); pace == 0 || ticks%pace != 0 {
+				s.idle(r)
+				continue
+			}
+		}
+		if work == nil {
 			s.tick(r)
+			continue
+		}
+		// Skip the check if the previous one hasn't finished, rather than
+		// letting a slow backend pile up queued checks
+		if !r.queued.CompareAndSwap(false, true) {
+			continue
 		}
+		select {
+		case work <- r:
+		case <-r.stopCh:
+			return
+		}
+	}
+}
+
+// resume forgets a probed state that went stale while SetPace held checks
+// back for longer than FailAfter intervals: a backend that died meanwhile
+// would otherwise keep its weight until FailAfter more checks fail. From
+// StateUnknown the next check decides the state on its own. The reported
+// state and weight stand until that check, which the kick runs right away.
+func (s *Scheduler) resume(r *runner) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lastCheck.IsZero() {
+		return
+	}
+	stale := time.Duration(max(r.target.FailAfter, 1)) * r.target.Interval
+	if s.now().Sub(r.lastCheck) <= stale {
+		return
+	}
+	r.state = StateUnknown
+	r.consecutiveSuccesses, r.consecutiveFailures = 0, 0
+}
+
+// idle handles a tick that SetPace skips: the probed state stands, but an
+// override's TTL still runs out on time.
+func (s *Scheduler) idle(r *runner) {
+	now := s.now()
+	r.mu.Lock()
+	expired, ok := r.expireOverride(now)
+	if !ok {
+		r.mu.Unlock()
+		return
 	}
+	rep := r.report("override_expired", now)
+	r.mu.Unlock()
+
+	s.notifyOverride(expired)
+	s.notify(r.target.Key, rep)
 }
 
 func (s *Scheduler) tick(r *runner) {
+	checker := s.checker
+	if r.target.Checker != nil {
+		checker = r.target.Checker
+	}
+
 	// Perform health check without holding lock (I/O operation)
-	err := s.checker.Check(r.target.Key.Backend, r.target.CheckPort, r.target.Timeout)
+	start := s.now()
+	address, err := s.checkAddress(r.target.probeHost())
+	if err == nil {
+		err = checker.Check(address, r.target.CheckPort, r.target.Timeout)
+	}
 	success := err == nil
+	duration := s.now().Sub(start)
+	latency, fastest := s.observeLatency(r.target.Key, duration, success)
+	if co, ok := s.obs.(CheckObserver); ok {
+		co.OnCheck(CheckResult{Key: r.target.Key, Duration: duration, Latency: latency, Err: err})
+	}
+
+	now := s.now()
 
 	// Lock for all state modifications
 	r.mu.Lock()
 	oldState := r.state
-	oldWeight := r.effectiveWeight
+	r.lastErr = err
+	r.lastCheck = now
 
 	if success {
 		r.consecutiveSuccesses++
@@ -225,29 +582,219 @@ func (s *Scheduler) tick(r *runner) {
 		}
 	}
 
+	reason := "health"
 	if r.state == StateHealthy {
 		r.effectiveWeight = r.target.ConfiguredWeight
+		if r.target.AdaptiveWeight {
+			r.effectiveWeight = adaptiveWeight(r.target.ConfiguredWeight, latency, fastest)
+			if oldState == StateHealthy {
+				reason = "latency"
+			}
+		}
+		// Only a recovery ramps; a backend healthy at startup gets full weight
+		if oldState == StateUnhealthy && r.target.SlowStart > 0 {
+			r.rampStart = now
+		}
+		if !r.rampStart.IsZero() {
+			if w, ramping := slowStartWeight(r.target, now.Sub(r.rampStart)); ramping {
+				if w < r.effectiveWeight {
+					r.effectiveWeight = w
+				}
+				if oldState == StateHealthy {
+					reason = "slow_start"
+				}
+			} else {
+				r.rampStart = time.Time{}
+				reason = "slow_start"
+			}
+		}
 	} else if r.state == StateUnhealthy {
 		r.effectiveWeight = 0
+		r.rampStart = time.Time{}
+		// A down backend must not hold the service's fastest baseline
+		s.forgetLatency(r.target.Key)
 	}
 
 	// Capture state changes before unlocking
-	stateChanged := oldState != r.state
-	weightChanged := oldWeight != r.effectiveWeight
-	newState := r.state
-	newWeight := r.effectiveWeight
+	expired, overrideExpired := r.expireOverride(now)
+	if overrideExpired {
+		reason = "override_expired"
+	}
+	rep := r.report(reason, now)
 	r.mu.Unlock()
 
 	// Call observers after releasing lock (to avoid holding lock during callbacks)
-	if stateChanged && s.obs != nil {
-		s.obs.OnStateChange(StateChange{Key: r.target.Key, Old: oldState, New: newState})
+	if overrideExpired {
+		s.notifyOverride(expired)
 	}
-	if weightChanged && s.obs != nil {
+	s.notify(r.target.Key, rep)
+}
+
+// report is a change in what the scheduler reports for a backend
+type report struct {
+	oldState, newState   State
+	oldWeight, newWeight int
+	reason               string
+
+	err                 error
+	failures, successes int
+	at                  time.Time
+}
+
+// report applies the override to the probed state and weight and records the
+// result. The caller must hold r.mu.
+func (r *runner) report(reason string, now time.Time) report {
+	state, weight := r.state, r.effectiveWeight
+	switch r.override {
+	case OverrideHealthy:
+		state, weight = StateHealthy, r.target.ConfiguredWeight
+	case OverrideUnhealthy:
+		state, weight = StateUnhealthy, 0
+	case OverrideDrain:
+		weight = 0
+	}
+	if r.override != OverrideNone {
+		reason = "override"
+	}
+
+	rep := report{
+		oldState:  r.reportedState,
+		newState:  state,
+		oldWeight: r.reportedWeight,
+		newWeight: weight,
+		reason:    reason,
+		failures:  r.consecutiveFailures,
+		successes: r.consecutiveSuccesses,
+		at:        now,
+	}
+	// An overridden state isn't explained by the last probe
+	if r.override == OverrideNone && state == StateUnhealthy {
+		rep.err = r.lastErr
+	}
+	r.reportedState, r.reportedWeight = state, weight
+	return rep
+}
+
+func (s *Scheduler) notify(key BackendKey, rep report) {
+	if s.obs == nil {
+		return
+	}
+	if rep.oldState != rep.newState {
+		change := StateChange{
+			Key:                  key,
+			Old:                  rep.oldState,
+			New:                  rep.newState,
+			Reason:               FailureReason(rep.err),
+			ConsecutiveFailures:  rep.failures,
+			ConsecutiveSuccesses: rep.successes,
+			Time:                 rep.at,
+		}
+		if rep.err != nil {
+			change.Error = rep.err.Error()
+		}
+		s.obs.OnStateChange(change)
+	}
+	if rep.oldWeight != rep.newWeight {
 		s.obs.OnWeightChange(WeightChange{
-			Key:       r.target.Key,
-			OldWeight: oldWeight,
-			NewWeight: newWeight,
-			Reason:    "health",
+			Key:       key,
+			OldWeight: rep.oldWeight,
+			NewWeight: rep.newWeight,
+			Reason:    rep.reason,
 		})
 	}
 }
+
+func slowStartSteps(t Target) int {
+	if t.SlowStartSteps < 1 {
+		return 4
+	}
+	return t.SlowStartSteps
+}
+
+// slowStartWeight returns the ramp weight after elapsed time. The ramp climbs
+// in equal increments and reaches ConfiguredWeight once SlowStart has passed.
+func slowStartWeight(t Target, elapsed time.Duration) (weight int, ramping bool) {
+	if elapsed >= t.SlowStart {
+		return t.ConfiguredWeight, false
+	}
+	steps := slowStartSteps(t)
+	step := int(elapsed * time.Duration(steps) / t.SlowStart)
+	weight = t.ConfiguredWeight * (step + 1) / (steps + 1)
+	if weight < 1 {
+		weight = 1
+	}
+	if weight > t.ConfiguredWeight {
+		weight = t.ConfiguredWeight
+	}
+	return weight, true
+}
+
+// Weight given to the newest sample in the latency EWMA
+const latencyAlpha = 0.3
+
+// observeLatency folds a successful check's duration into the backend's EWMA.
+// It returns the backend's EWMA and the lowest EWMA among its service's
+// backends; failed checks leave both unchanged.
+func (s *Scheduler) observeLatency(key BackendKey, d time.Duration, success bool) (latency, fastest time.Duration) {
+	s.latMu.Lock()
+	defer s.latMu.Unlock()
+
+	latency = s.latency[key]
+	if success {
+		if latency == 0 {
+			latency = d
+		} else {
+			latency = time.Duration(latencyAlpha*float64(d) + (1-latencyAlpha)*float64(latency))
+		}
+		s.latency[key] = latency
+	}
+
+	for k, v := range s.latency {
+		if k.Service == key.Service && (fastest == 0 || v < fastest) {
+			fastest = v
+		}
+	}
+	return latency, fastest
+}
+
+func (s *Scheduler) forgetLatency(key BackendKey) {
+	s.latMu.Lock()
+	delete(s.latency, key)
+	s.latMu.Unlock()
+}
+
+// adaptiveWeight scales configured weight by fastest/latency, so a backend
+// twice as slow as the service's fastest gets half its weight. The ratio is
+// rounded to 10% steps so latency noise doesn't change weights (and trigger a
+// reconcile) on every check.
+func adaptiveWeight(configured int, latency, fastest time.Duration) int {
+	if configured <= 0 || latency <= 0 || fastest <= 0 {
+		return configured
+	}
+	ratio := math.Round(float64(fastest)/float64(latency)*10) / 10
+	weight := int(math.Round(float64(configured) * ratio))
+	if weight < 1 {
+		weight = 1
+	}
+	return weight
+}
+
+// checkAddress resolves a hostname backend to its first address. Lookups go
+// through the resolver's cache, so this doesn't query DNS on every check.
+func (s *Scheduler) checkAddress(backend string) (string, error) {
+	return resolveAddress(s.resolver, backend)
+}
+
+func resolveAddress(r Resolver, backend string) (string, error) {
+	if r == nil || net.ParseIP(backend) != nil {
+		return backend, nil
+	}
+	ips, err := r.LookupIP(backend)
+	if err != nil {
+		return "", err
+	}
+	if len(ips) == 0 {
+		return "", fmt.Errorf("no addresses for %s", backend)
+	}
+	return ips[0].String(), nil
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package health
+
+import "syscall"
+
+// reuseAddrControl sets SO_REUSEADDR before a check socket binds its port
+func reuseAddrControl(network, address string, c syscall.RawConn) error {
+	var serr error
+	if err := c.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+	}); err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package health
+
+import "syscall"
+
+// reuseAddrControl is a no-op on Windows, where SO_REUSEADDR lets
+// another socket steal a bound port
+func reuseAddrControl(network, address string, c syscall.RawConn) error {
+	return nil
+}
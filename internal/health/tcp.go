@@ -0,0 +1,280 @@
+package health
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+)
+
+// closeConn closes conn, with an RST instead of a FIN when reset is set and
+// the connection supports SO_LINGER.
+func closeConn(conn net.Conn, reset bool) error {
+	if l, ok := conn.(interface{ SetLinger(sec int) error }); ok && reset {
+		_ = l.SetLinger(0)
+	}
+	return conn.Close()
+}
+
+// CloseChecker releases resources held by a checker, such as connections
+// kept open between checks. Checkers without any are left alone.
+func CloseChecker(c Checker) error {
+	if cl, ok := c.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
+
+const (
+	tcpFlagSYN = 0x02
+	tcpFlagRST = 0x04
+	tcpFlagACK = 0x10
+)
+
+// HalfOpenChecker sends a bare SYN over a raw socket and passes on SYN-ACK,
+// without completing the handshake: no local socket owns the source port, so
+// the kernel answers the SYN-ACK with RST. The backend's application never
+// sees a connection, which keeps checks out of its logs. An RST reply fails
+// the check as refused. Needs CAP_NET_RAW, which IPVS management
+// (CAP_NET_ADMIN) doesn't imply.
+type HalfOpenChecker struct {
+	Dialer Dialer
+}
+
+func (c *HalfOpenChecker) Check(address string, port int, timeout time.Duration) error {
+	if c == nil || c.Dialer == nil {
+		return fmt.Errorf("missing dialer")
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return errdefs.PermanentConfig(fmt.Errorf("invalid address: %s", address))
+	}
+	if port < 1 || port > 65535 {
+		return errdefs.PermanentConfig(fmt.Errorf("invalid port: %d", port))
+	}
+	if timeout <= 0 {
+		return errdefs.PermanentConfig(fmt.Errorf("invalid timeout: %s", timeout))
+	}
+
+	v4 := ip.To4() != nil
+	network := "ip4:tcp"
+	if !v4 {
+		network = "ip6:tcp"
+	}
+	conn, err := c.Dialer.DialTimeout(network, address, timeout)
+	if err != nil {
+		return errdefs.Classify(err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+
+	// The raw socket is connected, so the kernel has already picked the
+	// source address the checksum must cover
+	local, ok := conn.LocalAddr().(*net.IPAddr)
+	if !ok || local.IP == nil {
+		return fmt.Errorf("half-open check of %s: unknown source address", address)
+	}
+	srcPort := uint16(32768 + rand.Intn(28232)) // Linux's default ephemeral range
+	seq := rand.Uint32()
+	if _, err := conn.Write(synSegment(local.IP, ip, srcPort, uint16(port), seq)); err != nil {
+		return errdefs.Classify(err)
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return fmt.Errorf("no syn-ack from %s:%d: %w", address, port, err)
+		}
+		b := buf[:n]
+		// Some platforms return IPv4 raw reads with the IP header attached
+		if v4 && len(b) >= 20 && b[0]>>4 == 4 {
+			b = b[int(b[0]&0x0f)*4:]
+		}
+		if len(b) < 20 || binary.BigEndian.Uint16(b[0:2]) != uint16(port) || binary.BigEndian.Uint16(b[2:4]) != srcPort {
+			continue
+		}
+		flags := b[13]
+		switch {
+		case flags&tcpFlagRST != 0:
+			return fmt.Errorf("half-open check of %s:%d: %w", address, port, syscall.ECONNREFUSED)
+		case flags&(tcpFlagSYN|tcpFlagACK) == tcpFlagSYN|tcpFlagACK && binary.BigEndian.Uint32(b[8:12]) == seq+1:
+			return nil
+		}
+	}
+}
+
+// synSegment builds a TCP SYN with an MSS option and a valid checksum
+func synSegment(src, dst net.IP, srcPort, dstPort uint16, seq uint32) []byte {
+	seg := make([]byte, 24)
+	binary.BigEndian.PutUint16(seg[0:2], srcPort)
+	binary.BigEndian.PutUint16(seg[2:4], dstPort)
+	binary.BigEndian.PutUint32(seg[4:8], seq)
+	seg[12] = 6 << 4 // Data offset: 6 words, including the option
+	seg[13] = tcpFlagSYN
+	binary.BigEndian.PutUint16(seg[14:16], 64240)
+	copy(seg[20:], []byte{2, 4, 0x05, 0xb4}) // MSS 1460
+
+	// Pseudo-header: addresses, protocol and segment length (RFC 793, RFC 8200)
+	var pseudo []byte
+	if s4, d4 := src.To4(), dst.To4(); s4 != nil && d4 != nil {
+		pseudo = append(append(pseudo, s4...), d4...)
+		pseudo = append(pseudo, 0, syscall.IPPROTO_TCP)
+		pseudo = binary.BigEndian.AppendUint16(pseudo, uint16(len(seg)))
+	} else {
+		pseudo = append(append(pseudo, src.To16()...), dst.To16()...)
+		pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(len(seg)))
+		pseudo = append(pseudo, 0, 0, 0, syscall.IPPROTO_TCP)
+	}
+	sum := icmpChecksum(append(pseudo, seg...))
+	binary.BigEndian.PutUint16(seg[16:18], sum)
+	return seg
+}
+
+// ReuseTCPChecker keeps one connection per backend open across checks instead
+// of dialing every interval, avoiding a TIME_WAIT per check at high check
+// rates. A check passes while the held connection is still open; once the
+// backend closes or resets it, or keepalive probes find the peer gone, the
+// check redials and only fails if that dial fails. Only suitable for plain
+// TCP, where an idle connection is a valid thing to hold.
+//
+// A backend that silently stops answering leaves the connection looking
+// open, so held connections send keepalive probes after Keepalive idle, a
+// check timeout apart, and TCP_USER_TIMEOUT drops the connection once
+// reuseKeepaliveProbes go unanswered: within Keepalive plus a few timeouts
+// rather than the kernel default of over two hours.
+type ReuseTCPChecker struct {
+	Dialer    Dialer
+	Keepalive time.Duration // Idle time before keepalive probes on held connections; 0 = Interval
+	Interval  time.Duration // Check interval, the Keepalive default; 0 = the check timeout
+	Reset     bool          // Close replaced connections with RST rather than FIN
+
+	mu     sync.Mutex
+	conns  map[string]net.Conn // By address:port
+	closed bool
+}
+
+// reuseProbeWait is how long a check waits for a held connection to report
+// EOF or an error. A live idle connection always runs into this deadline.
+const reuseProbeWait = time.Millisecond
+
+// reuseKeepaliveProbes is how many keepalive probes a held connection may
+// leave unanswered before it is dropped.
+const reuseKeepaliveProbes = 2
+
+// keepalive returns the keepalive settings for connections held by checks
+// with timeout, and how long unanswered probes may take before the kernel
+// drops the connection.
+func (c *ReuseTCPChecker) keepalive(timeout time.Duration) (net.KeepAliveConfig, time.Duration) {
+	idle := c.Keepalive
+	if idle <= 0 {
+		idle = c.Interval
+	}
+	if idle <= 0 {
+		idle = timeout
+	}
+	// Keepalive timers count whole seconds
+	idle = max(idle, time.Second)
+	interval := max(timeout, time.Second)
+	cfg := net.KeepAliveConfig{Enable: true, Idle: idle, Interval: interval, Count: reuseKeepaliveProbes}
+	return cfg, idle + reuseKeepaliveProbes*interval
+}
+
+func (c *ReuseTCPChecker) Check(address string, port int, timeout time.Duration) error {
+	if c == nil || c.Dialer == nil {
+		return fmt.Errorf("missing dialer")
+	}
+	key := net.JoinHostPort(address, strconv.Itoa(port))
+
+	// Taken out of the map while in use, so concurrent checks of the same
+	// backend each get their own connection
+	c.mu.Lock()
+	conn := c.conns[key]
+	delete(c.conns, key)
+	c.mu.Unlock()
+
+	if conn != nil {
+		if connAlive(conn) {
+			c.put(key, conn)
+			return nil
+		}
+		_ = closeConn(conn, c.Reset)
+	}
+
+	conn, err := dialProtocol(c.Dialer, address, port, timeout)
+	if err != nil {
+		return err
+	}
+	// Drop the dial deadline; connAlive sets its own on every check
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		_ = closeConn(conn, c.Reset)
+		return err
+	}
+	if ka, ok := conn.(interface {
+		SetKeepAliveConfig(config net.KeepAliveConfig) error
+	}); ok {
+		cfg, userTimeout := c.keepalive(timeout)
+		_ = ka.SetKeepAliveConfig(cfg)
+		// Without it, data left unacknowledged by a vanished peer keeps
+		// the connection open through the retransmit timeout instead
+		if sc, ok := conn.(syscall.Conn); ok {
+			_ = setUserTimeout(sc, userTimeout)
+		}
+	}
+	c.put(key, conn)
+	return nil
+}
+
+// connAlive reports whether the backend still holds conn open. Data the
+// backend sent unprompted is discarded.
+func connAlive(conn net.Conn) bool {
+	if err := conn.SetReadDeadline(time.Now().Add(reuseProbeWait)); err != nil {
+		return false
+	}
+	buf := make([]byte, 512)
+	_, err := conn.Read(buf)
+	if err == nil {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func (c *ReuseTCPChecker) put(key string, conn net.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		_ = closeConn(conn, c.Reset)
+		return
+	}
+	if c.conns == nil {
+		c.conns = make(map[string]net.Conn)
+	}
+	if old := c.conns[key]; old != nil {
+		_ = closeConn(old, c.Reset)
+	}
+	c.conns[key] = conn
+}
+
+// Close closes every held connection. Checks after Close still work but no
+// longer hold connections.
+func (c *ReuseTCPChecker) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for key, conn := range c.conns {
+		_ = closeConn(conn, c.Reset)
+		delete(c.conns, key)
+	}
+	return nil
+}
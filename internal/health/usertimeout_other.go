@@ -0,0 +1,14 @@
+//go:build !linux
+
+package health
+
+import (
+	"syscall"
+	"time"
+)
+
+// setUserTimeout is a no-op without TCP_USER_TIMEOUT; keepalive alone still
+// drops connections to a vanished peer.
+func setUserTimeout(conn syscall.Conn, d time.Duration) error {
+	return nil
+}
@@ -0,0 +1,110 @@
+package ipvs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxListedFailures is how many services ApplyError.Error names
+const maxListedFailures = 5
+
+// ServiceError is the first failure reconciling one IPVS service. Later
+// changes to the service are skipped, so it is the only one.
+type ServiceError struct {
+	Service string // Service.Key
+	Op      string // What failed, e.g. "create service" or "update destination 10.0.0.1:80"
+	Err     error
+}
+
+func (e *ServiceError) Error() string { return fmt.Sprintf("%s: %s: %v", e.Service, e.Op, e.Err) }
+func (e *ServiceError) Unwrap() error { return e.Err }
+
+// ApplyError reports the services an Apply left unreconciled. The other
+// services were reconciled, and a retry only has the failed ones left to
+// change.
+type ApplyError struct {
+	Services int             // Services the Apply had changes for
+	Failed   []*ServiceError // Sorted by service key
+}
+
+// Error summarizes the failures the same way whatever order the writes ran
+// in, naming the first services by key.
+func (e *ApplyError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "IPVS changes failed for %d of %d services: ", len(e.Failed), e.Services)
+	for i, f := range e.Failed {
+		if i == maxListedFailures {
+			fmt.Fprintf(&sb, "; and %d more", len(e.Failed)-maxListedFailures)
+			break
+		}
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+		sb.WriteString(f.Error())
+	}
+	return sb.String()
+}
+
+// Unwrap returns the failures, so errors.Is finds their categories
+func (e *ApplyError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, f := range e.Failed {
+		errs[i] = f
+	}
+	return errs
+}
+
+// failureCollector keeps the first failure of each service for execute's
+// workers.
+type failureCollector struct {
+	mu       sync.Mutex
+	services map[string]bool
+	failed   map[string]*ServiceError
+}
+
+// touch counts key among the services the Apply changes
+func (c *failureCollector) touch(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.services == nil {
+		c.services = make(map[string]bool)
+	}
+	c.services[key] = true
+}
+
+func (c *failureCollector) add(key, op string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.services == nil {
+		c.services = make(map[string]bool)
+	}
+	c.services[key] = true
+	if c.failed == nil {
+		c.failed = make(map[string]*ServiceError)
+	}
+	if _, ok := c.failed[key]; !ok {
+		c.failed[key] = &ServiceError{Service: key, Op: op, Err: err}
+	}
+}
+
+func (c *failureCollector) has(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.failed[key]
+	return ok
+}
+
+// err returns the failures as an *ApplyError, or nil without any
+func (c *failureCollector) err() error {
+	if len(c.failed) == 0 {
+		return nil
+	}
+	e := &ApplyError{Services: len(c.services)}
+	for _, f := range c.failed {
+		e.Failed = append(e.Failed, f)
+	}
+	sort.Slice(e.Failed, func(i, j int) bool { return e.Failed[i].Service < e.Failed[j].Service })
+	return e
+}
@@ -1,9 +1,12 @@
 package ipvs
 
 import (
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
 	"github.com/malindarathnayake/LibraFlux/internal/config"
 )
 
@@ -19,20 +22,22 @@ type CachedManager struct {
 	inner   Manager
 	ttl     time.Duration
 	enabled bool
+	clock   clock.Clock
 
 	mu            sync.RWMutex
 	services      []*Service
 	destCache     map[string][]*Destination // keyed by service.Key()
 	fetchedAt     time.Time                 // services cache timestamp
 	destFetchedAt map[string]time.Time      // per-service destination cache timestamps
-	hits          uint64
-	misses        uint64
+	hits          atomic.Uint64             // Bumped under the read lock too
+	misses        atomic.Uint64
 }
 
 // CacheConfig holds configuration for the state cache
 type CacheConfig struct {
 	Enabled bool
 	TTL     time.Duration
+	Clock   clock.Clock // Optional; defaults to the real clock
 }
 
 // DefaultCacheConfig returns sensible defaults
@@ -58,10 +63,15 @@ func CacheConfigFromDaemonConfig(cfg config.CacheConfig) CacheConfig {
 // NewCachedManager creates a new cached manager wrapping the given Manager.
 // If enabled is false, all operations pass through directly to the inner manager.
 func NewCachedManager(inner Manager, cfg CacheConfig) *CachedManager {
+	clk := cfg.Clock
+	if clk == nil {
+		clk = clock.Real()
+	}
 	return &CachedManager{
 		inner:         inner,
 		ttl:           cfg.TTL,
 		enabled:       cfg.Enabled,
+		clock:         clk,
 		destCache:     make(map[string][]*Destination),
 		destFetchedAt: make(map[string]time.Time),
 	}
@@ -77,7 +87,7 @@ func (c *CachedManager) GetServices() ([]*Service, error) {
 	c.mu.RLock()
 	if c.isValidLocked() {
 		services := c.copyServicesLocked()
-		c.hits++
+		c.hits.Add(1)
 		c.mu.RUnlock()
 		return services, nil
 	}
@@ -89,14 +99,14 @@ func (c *CachedManager) GetServices() ([]*Service, error) {
 
 	// Double-check after acquiring write lock
 	if c.isValidLocked() {
-		c.hits++
+		c.hits.Add(1)
 		return c.copyServicesLocked(), nil
 	}
 
 	// Cache miss - fetch from kernel
 	services, err := c.inner.GetServices()
 	if err != nil {
-		c.misses++
+		c.misses.Add(1)
 		return nil, err
 	}
 
@@ -104,8 +114,8 @@ func (c *CachedManager) GetServices() ([]*Service, error) {
 	c.services = services
 	c.destCache = make(map[string][]*Destination)
 	c.destFetchedAt = make(map[string]time.Time)
-	c.fetchedAt = time.Now()
-	c.misses++
+	c.fetchedAt = c.clock.Now()
+	c.misses.Add(1)
 
 	return c.copyServicesLocked(), nil
 }
@@ -122,7 +132,7 @@ func (c *CachedManager) GetDestinations(svc *Service) ([]*Destination, error) {
 	c.mu.RLock()
 	if c.isDestValidLocked(key) {
 		result := c.copyDestinationsLocked(c.destCache[key])
-		c.hits++
+		c.hits.Add(1)
 		c.mu.RUnlock()
 		return result, nil
 	}
@@ -134,21 +144,21 @@ func (c *CachedManager) GetDestinations(svc *Service) ([]*Destination, error) {
 
 	// Double-check
 	if c.isDestValidLocked(key) {
-		c.hits++
+		c.hits.Add(1)
 		return c.copyDestinationsLocked(c.destCache[key]), nil
 	}
 
 	// Fetch destinations
 	dests, err := c.inner.GetDestinations(svc)
 	if err != nil {
-		c.misses++
+		c.misses.Add(1)
 		return nil, err
 	}
 
 	// Cache them
 	c.destCache[key] = dests
-	c.destFetchedAt[key] = time.Now()
-	c.misses++
+	c.destFetchedAt[key] = c.clock.Now()
+	c.misses.Add(1)
 
 	return c.copyDestinationsLocked(dests), nil
 }
@@ -219,9 +229,26 @@ func (c *CachedManager) Invalidate() {
 
 // Stats returns cache hit/miss statistics.
 func (c *CachedManager) Stats() (hits, misses uint64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// CacheStats is a snapshot of a CachedManager's counters.
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int // Services and destinations held, including expired ones not yet refetched
+}
+
+// Snapshot returns the cache's counters and current size.
+func (c *CachedManager) Snapshot() CacheStats {
 	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.hits, c.misses
+	entries := len(c.services)
+	for _, dests := range c.destCache {
+		entries += len(dests)
+	}
+	c.mu.RUnlock()
+	hits, misses := c.Stats()
+	return CacheStats{Hits: hits, Misses: misses, Entries: entries}
 }
 
 // isValidLocked checks if services cache is valid. Must be called with at least read lock held.
@@ -229,7 +256,7 @@ func (c *CachedManager) isValidLocked() bool {
 	if c.services == nil {
 		return false
 	}
-	return time.Since(c.fetchedAt) < c.ttl
+	return c.clock.Now().Sub(c.fetchedAt) < c.ttl
 }
 
 // isDestValidLocked checks if destinations cache for a key is valid. Must be called with lock held.
@@ -241,7 +268,7 @@ func (c *CachedManager) isDestValidLocked(key string) bool {
 	if _, ok := c.destCache[key]; !ok {
 		return false
 	}
-	return time.Since(fetchedAt) < c.ttl
+	return c.clock.Now().Sub(fetchedAt) < c.ttl
 }
 
 // copyServicesLocked returns a copy of cached services. Must be called with lock held.
@@ -252,6 +279,7 @@ func (c *CachedManager) copyServicesLocked() []*Service {
 	result := make([]*Service, len(c.services))
 	for i, svc := range c.services {
 		copied := *svc
+		copied.Flags = slices.Clone(svc.Flags)
 		result[i] = &copied
 	}
 	return result
@@ -270,7 +298,6 @@ func (c *CachedManager) copyDestinationsLocked(dests []*Destination) []*Destinat
 	return result
 }
 
-
 // Inner returns the underlying manager (useful for testing).
 func (c *CachedManager) Inner() Manager {
 	return c.inner
@@ -281,3 +308,53 @@ func (c *CachedManager) Enabled() bool {
 	return c.enabled
 }
 
+// SyncDaemons passes through to the inner manager; sync daemons aren't cached.
+func (c *CachedManager) SyncDaemons() ([]SyncDaemon, error) {
+	sm, ok := c.inner.(SyncDaemonManager)
+	if !ok {
+		return nil, errNoSyncDaemons
+	}
+	return sm.SyncDaemons()
+}
+
+func (c *CachedManager) StartSyncDaemon(d SyncDaemon) error {
+	sm, ok := c.inner.(SyncDaemonManager)
+	if !ok {
+		return errNoSyncDaemons
+	}
+	return sm.StartSyncDaemon(d)
+}
+
+func (c *CachedManager) StopSyncDaemon(state SyncState) error {
+	sm, ok := c.inner.(SyncDaemonManager)
+	if !ok {
+		return errNoSyncDaemons
+	}
+	return sm.StopSyncDaemon(state)
+}
+
+// Timeouts passes through to the inner manager; timeouts aren't cached.
+func (c *CachedManager) Timeouts() (Timeouts, error) {
+	tm, ok := c.inner.(TimeoutManager)
+	if !ok {
+		return Timeouts{}, errNoTimeouts
+	}
+	return tm.Timeouts()
+}
+
+func (c *CachedManager) SetTimeouts(t Timeouts) error {
+	tm, ok := c.inner.(TimeoutManager)
+	if !ok {
+		return errNoTimeouts
+	}
+	return tm.SetTimeouts(t)
+}
+
+// SetHandles passes through to the inner manager. Managers without a handle
+// pool ignore it.
+func (c *CachedManager) SetHandles(n int) error {
+	if hp, ok := c.inner.(handlePool); ok {
+		return hp.SetHandles(n)
+	}
+	return nil
+}
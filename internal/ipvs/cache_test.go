@@ -6,6 +6,9 @@ import (
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
+	"github.com/malindarathnayake/LibraFlux/internal/observability"
 )
 
 // mockManager is a mock implementation of Manager for testing
@@ -174,8 +177,8 @@ func TestCachedManager_CacheExpiry(t *testing.T) {
 		{Address: parseIP("10.0.0.1"), Protocol: "tcp", Port: 80, Scheduler: "rr"},
 	})
 
-	// Very short TTL
-	cfg := CacheConfig{Enabled: true, TTL: 10 * time.Millisecond}
+	clk := clock.NewFake(time.Unix(0, 0))
+	cfg := CacheConfig{Enabled: true, TTL: 10 * time.Millisecond, Clock: clk}
 	cached := NewCachedManager(mock, cfg)
 
 	// First call
@@ -184,8 +187,17 @@ func TestCachedManager_CacheExpiry(t *testing.T) {
 		t.Fatalf("GetServices failed: %v", err)
 	}
 
-	// Wait for cache to expire
-	time.Sleep(20 * time.Millisecond)
+	// Still within TTL
+	clk.Advance(9 * time.Millisecond)
+	if _, err := cached.GetServices(); err != nil {
+		t.Fatalf("GetServices failed: %v", err)
+	}
+	if mock.getGetCallCount() != 1 {
+		t.Errorf("expected 1 call to mock before expiry, got %d", mock.getGetCallCount())
+	}
+
+	// Expire the cache
+	clk.Advance(time.Millisecond)
 
 	// Second call - cache should be expired
 	_, err = cached.GetServices()
@@ -304,6 +316,41 @@ func TestCachedManager_Destinations(t *testing.T) {
 	}
 }
 
+func TestCachedManager_Snapshot(t *testing.T) {
+	mock := newMockManager()
+	svc := &Service{Address: parseIP("10.0.0.1"), Protocol: "tcp", Port: 80, Scheduler: "rr"}
+	mock.setServices([]*Service{svc})
+	mock.setDestinations(svc.Key(), []*Destination{
+		{Address: parseIP("192.168.1.1"), Port: 8080, Weight: 1},
+		{Address: parseIP("192.168.1.2"), Port: 8080, Weight: 2},
+	})
+
+	cached := NewCachedManager(mock, CacheConfig{Enabled: true, TTL: time.Hour})
+	r := NewReconciler(cached, observability.NewLogger(observability.ErrorLevel))
+
+	stats, ok := r.CacheStats()
+	if !ok {
+		t.Fatal("expected cache stats from a cached manager")
+	}
+	if stats != (CacheStats{}) {
+		t.Errorf("expected empty stats, got %+v", stats)
+	}
+
+	_, _ = cached.GetServices()
+	_, _ = cached.GetServices()
+	_, _ = cached.GetDestinations(svc)
+
+	stats, _ = r.CacheStats()
+	want := CacheStats{Hits: 1, Misses: 2, Entries: 3}
+	if stats != want {
+		t.Errorf("expected %+v, got %+v", want, stats)
+	}
+
+	if _, ok := NewReconciler(mock, observability.NewLogger(observability.ErrorLevel)).CacheStats(); ok {
+		t.Error("expected no cache stats without a cached manager")
+	}
+}
+
 func TestCachedManager_ErrorHandling(t *testing.T) {
 	mock := newMockManager()
 	mock.setServices([]*Service{
@@ -482,4 +529,3 @@ func parseIPManual(s string, a, b, c, d *int) (int, error) {
 	*a, *b, *c, *d = parts[0], parts[1], parts[2], parts[3]
 	return 4, nil
 }
-
@@ -0,0 +1,145 @@
+package ipvs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+)
+
+// Cleanup policies for IPVS services found on a managed VIP that aren't in
+// the desired state (daemon.cleanup), e.g. ones created by other IPVS tooling
+const (
+	CleanupStrict = "strict" // Delete them
+	CleanupWarn   = "warn"   // Keep them and log a warning when first seen
+	CleanupIgnore = "ignore" // Keep them silently
+)
+
+// SetCleanup sets what Apply does with foreign services: IPVS services on a
+// managed VIP that aren't desired and that lbctl never created or adopted. A
+// service lbctl owns is always deleted once it is no longer desired. An empty
+// or unknown policy is strict. It must not be called concurrently with Apply.
+func (r *Reconciler) SetCleanup(policy string) {
+	r.cleanup = strings.ToLower(policy)
+}
+
+// Foreign returns the foreign services the last Apply kept under the warn or
+// ignore policy, sorted by key.
+func (r *Reconciler) Foreign() []*Service {
+	out := make([]*Service, 0, len(r.foreign))
+	for _, key := range sortedKeys(r.foreign) {
+		out = append(out, r.foreign[key])
+	}
+	return out
+}
+
+// keepsForeign reports whether foreign services are left in place
+func (r *Reconciler) keepsForeign() bool {
+	return r.cleanup == CleanupWarn || r.cleanup == CleanupIgnore
+}
+
+// recordForeign remembers the foreign services a plan kept, warning about
+// newly seen ones under the warn policy.
+func (r *Reconciler) recordForeign(kept []*Service) {
+	foreign := make(map[string]*Service, len(kept))
+	for _, svc := range kept {
+		key := svc.Key()
+		if _, seen := r.foreign[key]; !seen && r.cleanup == CleanupWarn {
+			r.logger.Warnf("Keeping IPVS service %s on a managed VIP that is not in config", key)
+		}
+		foreign[key] = svc
+	}
+	r.foreign = foreign
+}
+
+// Adopt takes ownership of the IPVS services on vips that match desired
+// without changing IPVS, and returns their keys. Like services Apply finds
+// already in place, adopted services are never treated as foreign. It must
+// not be called concurrently with Apply.
+func (r *Reconciler) Adopt(desired []config.Service, vips []string) ([]string, error) {
+	plan, err := r.plan(desired, vips)
+	if plan == nil {
+		return nil, err
+	}
+	r.recordOwned(plan.adopted, nil, nil)
+	return plan.adopted, err
+}
+
+// SetOwnerFile keeps the keys of the services lbctl owns in path, so services
+// removed from config while the daemon was down are still deleted rather than
+// kept as foreign. Keys already in the file are loaded; a missing file starts
+// empty. It must not be called concurrently with Apply.
+func (r *Reconciler) SetOwnerFile(path string) error {
+	if path == r.ownerFile {
+		return nil
+	}
+	r.ownerFile = path
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read IPVS owner file: %w", err)
+	}
+	var keys []string
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return fmt.Errorf("invalid IPVS owner file %s: %w", path, err)
+	}
+	if r.owned == nil {
+		r.owned = make(map[string]bool, len(keys))
+	}
+	for _, key := range keys {
+		r.owned[key] = true
+	}
+	return nil
+}
+
+// recordOwned updates the owned services after a plan ran and persists them
+// if they changed.
+func (r *Reconciler) recordOwned(adopted []string, created, deleted map[string]bool) {
+	if r.owned == nil {
+		r.owned = make(map[string]bool)
+	}
+	changed := false
+	for _, key := range adopted {
+		if !r.owned[key] {
+			r.owned[key] = true
+			changed = true
+		}
+	}
+	for key := range created {
+		if !r.owned[key] {
+			r.owned[key] = true
+			changed = true
+		}
+	}
+	for key := range deleted {
+		if r.owned[key] {
+			delete(r.owned, key)
+			changed = true
+		}
+	}
+	if changed && r.ownerFile != "" {
+		if err := writeOwnerFile(r.ownerFile, sortedKeys(r.owned)); err != nil {
+			r.logger.Errorf("Failed to save owned IPVS services: %v", err)
+		}
+	}
+}
+
+func writeOwnerFile(path string, keys []string) error {
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,116 @@
+package ipvs
+
+import (
+	"sort"
+	"sync"
+)
+
+// handlePool is implemented by managers that need one netlink socket per
+// concurrent call, like RealManager.
+type handlePool interface {
+	SetHandles(n int) error
+}
+
+// SetConcurrency lets Apply make up to n IPVS writes at once. Services are
+// created, updated and deleted in parallel. Destinations of different
+// services change in parallel, but those of one service stay in plan order.
+// n <= 1 applies changes one at a time. With n > 1 the manager must be safe
+// for concurrent use; a manager with a handle pool is resized to n handles,
+// and if that fails Apply falls back to one change at a time. It must not be
+// called concurrently with Apply.
+func (r *Reconciler) SetConcurrency(n int) {
+	n = max(n, 1)
+	if hp, ok := r.manager.(handlePool); ok {
+		if err := hp.SetHandles(n); err != nil {
+			if n > 1 {
+				r.logger.Warnf("Applying IPVS changes one at a time: %v", err)
+			}
+			n = 1
+		}
+	}
+	r.concurrency = n
+}
+
+// Concurrency returns the number of IPVS writes Apply makes at once
+func (r *Reconciler) Concurrency() int {
+	return max(r.concurrency, 1)
+}
+
+// parallel calls fn for every index below n on up to workers goroutines
+func parallel(workers, n int, fn func(i int)) {
+	if workers <= 1 || n <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// OpCount is the number of IPVS writes of one kind made by an Apply
+type OpCount struct {
+	Object string // "service" or "destination"
+	Kind   string // ChangeCreate, ChangeUpdate, ChangeDrain or ChangeDelete
+	OK     int
+	Failed int
+}
+
+// LastOps returns the IPVS writes made by the last Apply, sorted by object
+// and kind. Kinds without writes are left out, so an Apply that changed
+// nothing returns none.
+func (r *Reconciler) LastOps() []OpCount {
+	return r.lastOps
+}
+
+type opCounter struct {
+	mu     sync.Mutex
+	counts map[[2]string]*OpCount
+}
+
+func (c *opCounter) add(object, kind string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[[2]string]*OpCount)
+	}
+	k := [2]string{object, kind}
+	oc, ok := c.counts[k]
+	if !ok {
+		oc = &OpCount{Object: object, Kind: kind}
+		c.counts[k] = oc
+	}
+	if err != nil {
+		oc.Failed++
+	} else {
+		oc.OK++
+	}
+}
+
+func (c *opCounter) list() []OpCount {
+	out := make([]OpCount, 0, len(c.counts))
+	for _, oc := range c.counts {
+		out = append(out, *oc)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Object != out[j].Object {
+			return out[i].Object < out[j].Object
+		}
+		return out[i].Kind < out[j].Kind
+	})
+	return out
+}
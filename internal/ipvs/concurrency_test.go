@@ -0,0 +1,291 @@
+package ipvs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/observability"
+)
+
+// lockedManager makes a MockManager safe for concurrent use and records
+// every write in the order it was made.
+type lockedManager struct {
+	mu      sync.Mutex
+	inner   *MockManager
+	ops     []string
+	fail    map[string]bool // "create-service <key>" etc. that fail
+	handles int
+	poolErr error
+}
+
+func newLockedManager() *lockedManager {
+	return &lockedManager{inner: NewMockManager(), fail: make(map[string]bool)}
+}
+
+func (m *lockedManager) write(op string, fn func() error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ops = append(m.ops, op)
+	if m.fail[op] {
+		return fmt.Errorf("%s failed", op)
+	}
+	return fn()
+}
+
+func (m *lockedManager) GetServices() ([]*Service, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.inner.GetServices()
+}
+
+func (m *lockedManager) GetDestinations(svc *Service) ([]*Destination, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if op := "get-destinations " + svc.Key(); m.fail[op] {
+		return nil, fmt.Errorf("%s failed", op)
+	}
+	return slices.Clone(m.inner.Destinations[svc.Key()]), nil
+}
+
+func (m *lockedManager) CreateService(svc *Service) error {
+	return m.write("create-service "+svc.Key(), func() error { return m.inner.CreateService(svc) })
+}
+
+func (m *lockedManager) UpdateService(svc *Service) error {
+	return m.write("update-service "+svc.Key(), func() error { return m.inner.UpdateService(svc) })
+}
+
+func (m *lockedManager) DeleteService(svc *Service) error {
+	return m.write("delete-service "+svc.Key(), func() error { return m.inner.DeleteService(svc) })
+}
+
+func (m *lockedManager) CreateDestination(svc *Service, dst *Destination) error {
+	return m.write("create-destination "+svc.Key()+" "+dst.Key(), func() error { return m.inner.CreateDestination(svc, dst) })
+}
+
+func (m *lockedManager) UpdateDestination(svc *Service, dst *Destination) error {
+	return m.write("update-destination "+svc.Key()+" "+dst.Key(), func() error { return m.inner.UpdateDestination(svc, dst) })
+}
+
+func (m *lockedManager) DeleteDestination(svc *Service, dst *Destination) error {
+	return m.write("delete-destination "+svc.Key()+" "+dst.Key(), func() error { return m.inner.DeleteDestination(svc, dst) })
+}
+
+func (m *lockedManager) SetHandles(n int) error {
+	if m.poolErr != nil {
+		return m.poolErr
+	}
+	m.handles = n
+	return nil
+}
+
+func (m *lockedManager) index(op string) int {
+	return slices.Index(m.ops, op)
+}
+
+func rangeService(backends ...string) config.Service {
+	svc := config.Service{Name: "range", Protocol: "tcp", Scheduler: "rr",
+		PortRanges: []config.PortRange{{Start: 8000, End: 8019}}}
+	for _, addr := range backends {
+		svc.Backends = append(svc.Backends, config.Backend{Address: addr, Weight: 1})
+	}
+	return svc
+}
+
+func opCount(ops []OpCount, object, kind string) OpCount {
+	for _, oc := range ops {
+		if oc.Object == object && oc.Kind == kind {
+			return oc
+		}
+	}
+	return OpCount{Object: object, Kind: kind}
+}
+
+func TestReconcilerConcurrentApply(t *testing.T) {
+	vips := []string{"192.168.1.100"}
+	m := newLockedManager()
+	r := NewReconciler(m, observability.NewLogger(observability.ErrorLevel))
+	r.SetConcurrency(8)
+	if m.handles != 8 || r.Concurrency() != 8 {
+		t.Fatalf("expected 8 handles, got %d (concurrency %d)", m.handles, r.Concurrency())
+	}
+
+	backends := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}
+	if err := r.Apply([]config.Service{rangeService(backends...)}, vips); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if len(m.inner.Services) != 20 {
+		t.Fatalf("expected 20 services, got %d", len(m.inner.Services))
+	}
+	for key, svc := range m.inner.Services {
+		created := m.index("create-service " + key)
+		prev := created
+		for _, addr := range backends {
+			dst := &Destination{Address: parseIP(addr), Port: svc.Port}
+			i := m.index("create-destination " + key + " " + dst.Key())
+			if i <= prev {
+				t.Fatalf("%s: destination %s written at %d, after %d", key, addr, i, prev)
+			}
+			prev = i
+		}
+	}
+	ops := r.LastOps()
+	if oc := opCount(ops, "service", ChangeCreate); oc.OK != 20 || oc.Failed != 0 {
+		t.Errorf("unexpected service creates %+v", oc)
+	}
+	if oc := opCount(ops, "destination", ChangeCreate); oc.OK != 60 || oc.Failed != 0 {
+		t.Errorf("unexpected destination creates %+v", oc)
+	}
+
+	// Nothing to change
+	if err := r.Apply([]config.Service{rangeService(backends...)}, vips); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if ops := r.LastOps(); len(ops) != 0 {
+		t.Errorf("expected no writes, got %+v", ops)
+	}
+
+	// Removing the service from config deletes every port
+	if err := r.Apply(nil, vips); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if len(m.inner.Services) != 0 {
+		t.Fatalf("expected services deleted, got %d", len(m.inner.Services))
+	}
+	if oc := opCount(r.LastOps(), "service", ChangeDelete); oc.OK != 20 {
+		t.Errorf("unexpected service deletes %+v", oc)
+	}
+}
+
+func TestReconcilerConcurrentApplyErrors(t *testing.T) {
+	vips := []string{"192.168.1.100"}
+	m := newLockedManager()
+	r := NewReconciler(m, observability.NewLogger(observability.ErrorLevel))
+	r.SetConcurrency(4)
+
+	broken := &Service{Address: parseIP("192.168.1.100"), Protocol: "tcp", Port: 8005}
+	m.fail["create-service "+broken.Key()] = true
+	stuck := &Service{Address: parseIP("192.168.1.100"), Protocol: "tcp", Port: 8010}
+	first := &Destination{Address: parseIP("10.0.0.1"), Port: 8010}
+	m.fail["create-destination "+stuck.Key()+" "+first.Key()] = true
+
+	// Other services are still reconciled, and the failures are reported
+	// per service in key order
+	err := r.Apply([]config.Service{rangeService("10.0.0.1", "10.0.0.2")}, vips)
+	var ae *ApplyError
+	if !errors.As(err, &ae) {
+		t.Fatalf("expected an *ApplyError, got %v", err)
+	}
+	if ae.Services != 20 || len(ae.Failed) != 2 ||
+		ae.Failed[0].Service != broken.Key() || ae.Failed[0].Op != "create service" ||
+		ae.Failed[1].Service != stuck.Key() || ae.Failed[1].Op != "create destination "+first.Key() {
+		t.Fatalf("unexpected failures: %v", err)
+	}
+	// A failed service create skips its destinations
+	for _, op := range m.ops {
+		if op == "create-destination "+broken.Key()+" 10.0.0.1:8005" || op == "create-destination "+broken.Key()+" 10.0.0.2:8005" {
+			t.Errorf("unexpected write %q", op)
+		}
+	}
+	// The first destination failure skips the rest of that service
+	if m.index("create-destination "+stuck.Key()+" 10.0.0.2:8010") != -1 {
+		t.Error("expected remaining destinations of a failed service to be skipped")
+	}
+
+	ops := r.LastOps()
+	if oc := opCount(ops, "service", ChangeCreate); oc.OK != 19 || oc.Failed != 1 {
+		t.Errorf("unexpected service creates %+v", oc)
+	}
+	if oc := opCount(ops, "destination", ChangeCreate); oc.OK != 36 || oc.Failed != 1 {
+		t.Errorf("unexpected destination creates %+v", oc)
+	}
+	if !slices.IsSortedFunc(ops, func(a, b OpCount) int {
+		if a.Object != b.Object {
+			return strings.Compare(a.Object, b.Object)
+		}
+		return strings.Compare(a.Kind, b.Kind)
+	}) {
+		t.Errorf("expected ops sorted, got %+v", ops)
+	}
+}
+
+func TestReconcilerApplyErrorSummary(t *testing.T) {
+	vips := []string{"192.168.1.100"}
+	apply := func(concurrency int) (*lockedManager, error) {
+		m := newLockedManager()
+		r := NewReconciler(m, observability.NewLogger(observability.ErrorLevel))
+		r.SetConcurrency(concurrency)
+		if err := r.Apply([]config.Service{rangeService("10.0.0.1")}, vips); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		for port := 8000; port < 8014; port += 2 {
+			m.fail[fmt.Sprintf("get-destinations tcp:192.168.1.100:%d", port)] = true
+		}
+		return m, r.Apply([]config.Service{rangeService("10.0.0.1", "10.0.0.2")}, vips)
+	}
+
+	m, serial := apply(1)
+	_, concurrent := apply(8)
+	if serial == nil || concurrent == nil || serial.Error() != concurrent.Error() {
+		t.Fatalf("expected the same summary whatever the concurrency:\n%v\n%v", serial, concurrent)
+	}
+	want := "IPVS changes failed for 7 of 20 services: " +
+		"tcp:192.168.1.100:8000: read destinations: get-destinations tcp:192.168.1.100:8000 failed; " +
+		"tcp:192.168.1.100:8002: read destinations: get-destinations tcp:192.168.1.100:8002 failed; " +
+		"tcp:192.168.1.100:8004: read destinations: get-destinations tcp:192.168.1.100:8004 failed; " +
+		"tcp:192.168.1.100:8006: read destinations: get-destinations tcp:192.168.1.100:8006 failed; " +
+		"tcp:192.168.1.100:8008: read destinations: get-destinations tcp:192.168.1.100:8008 failed; and 2 more"
+	if serial.Error() != want {
+		t.Fatalf("unexpected summary:\n%s\nwant:\n%s", serial, want)
+	}
+	// Services that could be read were reconciled
+	if m.index("create-destination tcp:192.168.1.100:8001 10.0.0.2:8001") == -1 {
+		t.Error("expected readable services to get the new backend")
+	}
+	if m.index("create-destination tcp:192.168.1.100:8000 10.0.0.2:8000") != -1 {
+		t.Error("expected an unreadable service to be left alone")
+	}
+}
+
+func TestReconcilerConcurrencyFallback(t *testing.T) {
+	m := newLockedManager()
+	m.poolErr = fmt.Errorf("no netlink")
+	r := NewReconciler(m, observability.NewLogger(observability.ErrorLevel))
+	r.SetConcurrency(16)
+	if r.Concurrency() != 1 {
+		t.Fatalf("expected serial fallback, got %d", r.Concurrency())
+	}
+	if err := r.Apply([]config.Service{rangeService("10.0.0.1")}, []string{"192.168.1.100"}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if len(m.inner.Services) != 20 {
+		t.Errorf("expected 20 services, got %d", len(m.inner.Services))
+	}
+}
+
+// BenchmarkReconcilerApplyChurn creates and deletes a 20-port service with
+// three backends per iteration, so every per-change log line is exercised.
+func BenchmarkReconcilerApplyChurn(b *testing.B) {
+	logger := observability.NewLogger(observability.InfoLevel)
+	logger.SetConsoleOutput(io.Discard)
+	r := NewReconciler(NewMockManager(), logger)
+	vips := []string{"192.168.1.100"}
+	desired := []config.Service{rangeService("10.0.0.1", "10.0.0.2", "10.0.0.3")}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := r.Apply(desired, vips); err != nil {
+			b.Fatal(err)
+		}
+		if err := r.Apply(nil, vips); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package ipvs
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ConnTablePath is the kernel's IPVS connection table
+const ConnTablePath = "/proc/net/ip_vs_conn"
+
+// Connection is one entry of the IPVS connection table
+type Connection struct {
+	Protocol   string // tcp, udp
+	Client     net.IP
+	ClientPort uint16
+	VIP        net.IP
+	Port       uint16
+	Dest       net.IP // Backend the scheduler chose
+	DestPort   uint16
+	State      string
+}
+
+// Key uniquely identifies a connection in the table
+func (c Connection) Key() string {
+	return fmt.Sprintf("%s:%s:%d:%s:%d", c.Protocol, c.Client, c.ClientPort, c.VIP, c.Port)
+}
+
+// ReadConnections reads the current IPVS connection table
+func ReadConnections() ([]Connection, error) {
+	f, err := os.Open(ConnTablePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open connection table: %w", err)
+	}
+	defer f.Close()
+	return ParseConnections(f)
+}
+
+// ParseConnections parses the /proc/net/ip_vs_conn format. IPv4 addresses
+// are hex encoded, IPv6 addresses use the usual colon notation, ports are
+// hex. The header and lines that don't parse are skipped.
+func ParseConnections(r io.Reader) ([]Connection, error) {
+	var conns []Connection
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 8 || fields[0] == "Pro" {
+			continue
+		}
+		c := Connection{Protocol: strings.ToLower(fields[0]), State: fields[7]}
+		var ok bool
+		if c.Client, c.ClientPort, ok = parseConnAddr(fields[1], fields[2]); !ok {
+			continue
+		}
+		if c.VIP, c.Port, ok = parseConnAddr(fields[3], fields[4]); !ok {
+			continue
+		}
+		if c.Dest, c.DestPort, ok = parseConnAddr(fields[5], fields[6]); !ok {
+			continue
+		}
+		conns = append(conns, c)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read connection table: %w", err)
+	}
+	return conns, nil
+}
+
+func parseConnAddr(addr, port string) (net.IP, uint16, bool) {
+	p, err := strconv.ParseUint(port, 16, 16)
+	if err != nil {
+		return nil, 0, false
+	}
+	if strings.Contains(addr, ":") {
+		ip := net.ParseIP(addr)
+		return ip, uint16(p), ip != nil
+	}
+	b, err := hex.DecodeString(addr)
+	if err != nil || len(b) != 4 {
+		return nil, 0, false
+	}
+	return net.IP(b), uint16(p), true
+}
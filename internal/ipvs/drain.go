@@ -0,0 +1,119 @@
+package ipvs
+
+import (
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+)
+
+// DefaultDrainTimeout bounds how long a removed destination is drained when
+// daemon.drain.timeout_ms is unset.
+const DefaultDrainTimeout = 5 * time.Minute
+
+// DrainConfig controls graceful destination removal. When enabled, a
+// destination that leaves the desired state is first set to weight 0, so the
+// scheduler sends it no new connections, and deleted by a later Apply once its
+// connections fall to Threshold or Timeout has passed. TCP counts active
+// connections only; UDP has no connection state, so its entries, which IPVS
+// counts as inactive, count too. A service that leaves the desired state is
+// deleted once all its destinations have drained.
+type DrainConfig struct {
+	Enabled   bool
+	Timeout   time.Duration
+	Threshold int
+	Clock     clock.Clock // Optional; defaults to the real clock
+}
+
+// DrainConfigFromDaemonConfig converts config.DrainConfig (daemon.drain) into
+// an ipvs.DrainConfig.
+func DrainConfigFromDaemonConfig(cfg config.DrainConfig) DrainConfig {
+	timeout := time.Duration(cfg.TimeoutMS) * time.Millisecond
+	if timeout == 0 {
+		timeout = DefaultDrainTimeout
+	}
+	return DrainConfig{
+		Enabled:   cfg.Enabled,
+		Timeout:   timeout,
+		Threshold: cfg.Threshold,
+	}
+}
+
+// SetDrain sets how removed destinations are drained. Destinations already
+// draining keep their start time. It must not be called concurrently with
+// Apply.
+func (r *Reconciler) SetDrain(cfg DrainConfig) {
+	if cfg.Clock == nil {
+		cfg.Clock = clock.Real()
+	}
+	r.drain = cfg
+}
+
+// Draining returns the number of destinations left at weight 0 by the last
+// Apply that still wait for their connections to finish. Apply must be
+// called again to delete them.
+func (r *Reconciler) Draining() int {
+	return len(r.draining)
+}
+
+// drainKey identifies a destination of a service in the drain state
+func drainKey(svc *Service, dest *Destination) string {
+	return svc.Key() + "|" + dest.Key()
+}
+
+// removeDestination decides what happens to a destination that is no longer
+// desired: delete it now, start or continue draining it, or leave it for a
+// later Apply. draining receives the destinations that stay in the drain
+// state.
+func (r *Reconciler) removeDestination(svc *Service, dest *Destination, draining map[string]time.Time) *DestinationChange {
+	if !r.drain.Enabled || drainConns(svc, dest) <= r.drain.Threshold {
+		return &DestinationChange{Kind: ChangeDelete, Service: svc, Destination: dest}
+	}
+
+	key := drainKey(svc, dest)
+	now := r.drain.Clock.Now()
+	start, ok := r.draining[key]
+	if !ok {
+		start = now
+	}
+	if now.Sub(start) >= r.drain.Timeout {
+		return &DestinationChange{Kind: ChangeDelete, Service: svc, Destination: dest}
+	}
+	draining[key] = start
+	if ok && dest.Weight == 0 {
+		return nil // Still draining
+	}
+	drained := *dest
+	drained.Weight = 0
+	return &DestinationChange{Kind: ChangeDrain, Service: svc, Destination: &drained, Current: dest}
+}
+
+// drainConns counts the connections a removed destination waits for. A TCP
+// destination's inactive connections are closing (e.g. TIME_WAIT) and don't
+// hold it up; a UDP destination's are all inactive, so they count.
+func drainConns(svc *Service, dest *Destination) int {
+	if svc.Protocol == "udp" {
+		return dest.ActiveConns + dest.InactiveConns
+	}
+	return dest.ActiveConns
+}
+
+// drainService drains the destinations of svc, a service that is no longer
+// desired. It returns the destination changes to make while any of them are
+// still draining, and done once the service itself can be deleted.
+func (r *Reconciler) drainService(svc *Service, dests []*Destination, draining map[string]time.Time) (changes []DestinationChange, done bool) {
+	done = true
+	for _, dest := range dests {
+		c := r.removeDestination(svc, dest, draining)
+		if c == nil || c.Kind == ChangeDrain {
+			done = false
+		}
+		if c != nil {
+			changes = append(changes, *c)
+		}
+	}
+	if done {
+		return nil, true
+	}
+	return changes, false
+}
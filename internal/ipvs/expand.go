@@ -0,0 +1,131 @@
+package ipvs
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"net"
+	"strings"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+)
+
+// expandKey identifies the inputs of one service's expansion.
+type expandKey struct {
+	config  uint64 // VIPs, forwarding mode and every expanded field but weights
+	weights uint64 // Backend weights, which health checks adjust between reloads
+}
+
+// expandEntry is the memoized expansion of one config service.
+type expandEntry struct {
+	key    expandKey
+	states []*DesiredState
+}
+
+// ExpandStats counts the config services whose expansion the last Apply
+// reused (Hits) or had to recompute (Misses).
+type ExpandStats struct {
+	Hits   int
+	Misses int
+}
+
+// LastExpandStats returns the expansion cache results of the last Apply or
+// ApplyService.
+func (r *Reconciler) LastExpandStats() ExpandStats {
+	return r.lastExpand
+}
+
+// expandCached returns the expansion of svc, reusing the last one while its
+// config, weights, vips and the forwarding mode are unchanged. Services with
+// hostname backends are expanded every time, since their addresses can
+// change between resolutions. Memoized states are shared between Applies, so
+// nothing downstream may modify them.
+func (r *Reconciler) expandCached(svc config.Service, vips []string, managed map[string]bool) ([]*DesiredState, error) {
+	key, ok := r.expandKey(svc, vips)
+	if ok {
+		if e, hit := r.expanded[svc.Name]; hit && e.key == key {
+			r.lastExpand.Hits++
+			return e.states, nil
+		}
+	}
+	r.lastExpand.Misses++
+	states, err := r.expandService(svc, vips, managed)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if r.expanded == nil {
+			r.expanded = make(map[string]expandEntry)
+		}
+		r.expanded[svc.Name] = expandEntry{key: key, states: states}
+	} else {
+		delete(r.expanded, svc.Name)
+	}
+	return states, nil
+}
+
+// pruneExpanded forgets the expansions of services not in services.
+func (r *Reconciler) pruneExpanded(services []config.Service) {
+	keep := make(map[string]bool, len(services))
+	for _, svc := range services {
+		keep[svc.Name] = true
+	}
+	for name := range r.expanded {
+		if !keep[name] {
+			delete(r.expanded, name)
+		}
+	}
+}
+
+// expandKey hashes every input of expandService. It reports false if svc
+// has a hostname backend.
+func (r *Reconciler) expandKey(svc config.Service, vips []string) (expandKey, bool) {
+	h, w := fnv.New64a(), fnv.New64a()
+	writeString(h, r.forward)
+	writeString(h, strings.Join(vips, ","))
+	writeString(h, svc.VIP)
+	writeString(h, svc.Protocol)
+	writeString(h, svc.Scheduler)
+	writeString(h, strings.Join(svc.SchedulerFlags, ","))
+	writeString(h, svc.PersistenceNetmask)
+	writeInt(h, svc.PersistenceTimeout)
+	writeInt(h, len(svc.Ports))
+	for _, p := range svc.Ports {
+		writeInt(h, p)
+	}
+	writeInt(h, len(svc.PortRanges))
+	for _, pr := range svc.PortRanges {
+		writeInt(h, pr.Start)
+		writeInt(h, pr.End)
+	}
+	writeInt(h, len(svc.Backends))
+	for _, be := range svc.Backends {
+		if !be.Drain && net.ParseIP(be.Address) == nil {
+			return expandKey{}, false
+		}
+		writeString(h, be.Address)
+		writeString(h, be.Forward)
+		writeInt(h, be.Port)
+		writeInt(h, svc.BackendPortOffset(be))
+		writeInt(h, be.UpperThreshold)
+		writeInt(h, be.LowerThreshold)
+		if be.Drain {
+			writeInt(h, 1)
+		} else {
+			writeInt(h, 0)
+		}
+		writeInt(w, be.Weight)
+	}
+	return expandKey{config: h.Sum64(), weights: w.Sum64()}, true
+}
+
+func writeString(h hash.Hash64, s string) {
+	writeInt(h, len(s))
+	h.Write([]byte(s))
+}
+
+func writeInt(h hash.Hash64, n int) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(n))
+	h.Write(buf[:])
+}
@@ -1,9 +1,16 @@
 package ipvs
 
 import (
+	"bytes"
 	"fmt"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
 	"github.com/malindarathnayake/LibraFlux/internal/config"
 	"github.com/malindarathnayake/LibraFlux/internal/observability"
 )
@@ -11,6 +18,10 @@ import (
 type MockManager struct {
 	Services     map[string]*Service
 	Destinations map[string][]*Destination
+	Daemons      []SyncDaemon
+	DaemonOps    []string
+	Timeout      Timeouts
+	TimeoutSets  []Timeouts
 }
 
 func NewMockManager() *MockManager {
@@ -66,6 +77,40 @@ func (m *MockManager) UpdateDestination(svc *Service, dst *Destination) error {
 	return fmt.Errorf("destination not found")
 }
 
+func (m *MockManager) SyncDaemons() ([]SyncDaemon, error) {
+	return slices.Clone(m.Daemons), nil
+}
+
+func (m *MockManager) StartSyncDaemon(d SyncDaemon) error {
+	m.DaemonOps = append(m.DaemonOps, "start "+d.State.String())
+	m.Daemons = append(m.Daemons, d)
+	return nil
+}
+
+func (m *MockManager) StopSyncDaemon(state SyncState) error {
+	m.DaemonOps = append(m.DaemonOps, "stop "+state.String())
+	m.Daemons = slices.DeleteFunc(m.Daemons, func(d SyncDaemon) bool { return d.State == state })
+	return nil
+}
+
+func (m *MockManager) Timeouts() (Timeouts, error) {
+	return m.Timeout, nil
+}
+
+func (m *MockManager) SetTimeouts(t Timeouts) error {
+	m.TimeoutSets = append(m.TimeoutSets, t)
+	if t.TCP != 0 {
+		m.Timeout.TCP = t.TCP
+	}
+	if t.TCPFin != 0 {
+		m.Timeout.TCPFin = t.TCPFin
+	}
+	if t.UDP != 0 {
+		m.Timeout.UDP = t.UDP
+	}
+	return nil
+}
+
 func (m *MockManager) DeleteDestination(svc *Service, dst *Destination) error {
 	key := svc.Key()
 	dests := m.Destinations[key]
@@ -99,7 +144,7 @@ func TestReconciler(t *testing.T) {
 		},
 	}
 
-	if err := reconciler.Apply(desired, vip); err != nil {
+	if err := reconciler.Apply(desired, []string{vip}); err != nil {
 		t.Fatalf("Apply failed: %v", err)
 	}
 
@@ -141,7 +186,7 @@ func TestReconciler(t *testing.T) {
 
 	// 2. Update (Change Scheduler)
 	desired[0].Scheduler = "wrr"
-	if err := reconciler.Apply(desired, vip); err != nil {
+	if err := reconciler.Apply(desired, []string{vip}); err != nil {
 		t.Fatalf("Apply update failed: %v", err)
 	}
 
@@ -149,9 +194,91 @@ func TestReconciler(t *testing.T) {
 		t.Error("Scheduler not updated to wrr")
 	}
 
+	// 2b. Update (Scheduler flags, names normalized)
+	desired[0].Scheduler = "SH"
+	desired[0].SchedulerFlags = []string{"sh-port", "SH-Fallback"}
+	if err := reconciler.Apply(desired, []string{vip}); err != nil {
+		t.Fatalf("Apply scheduler flags failed: %v", err)
+	}
+	if svc := mock.Services[key80]; svc.Scheduler != "sh" || !slices.Equal(svc.Flags, []string{"sh-fallback", "sh-port"}) {
+		t.Errorf("Expected sh with sh-fallback,sh-port, got %s %v", svc.Scheduler, svc.Flags)
+	}
+	desired[0].SchedulerFlags = []string{"sh-port"}
+	if err := reconciler.Apply(desired, []string{vip}); err != nil {
+		t.Fatalf("Apply scheduler flags failed: %v", err)
+	}
+	if svc := mock.Services[key80]; !slices.Equal(svc.Flags, []string{"sh-port"}) {
+		t.Errorf("Expected flags updated to sh-port, got %v", svc.Flags)
+	}
+
+	// 2c. Update (Persistence)
+	desired[0].PersistenceTimeout = 300
+	desired[0].PersistenceNetmask = "255.255.255.0"
+	if err := reconciler.Apply(desired, []string{vip}); err != nil {
+		t.Fatalf("Apply persistence failed: %v", err)
+	}
+	if svc := mock.Services[key80]; svc.Timeout != 300 || svc.Netmask != 0xFFFFFF00 {
+		t.Errorf("Expected persistence 300s/0xFFFFFF00, got %ds/0x%x", svc.Timeout, svc.Netmask)
+	}
+	desired[0].PersistenceTimeout = 0
+	desired[0].PersistenceNetmask = ""
+	if err := reconciler.Apply(desired, []string{vip}); err != nil {
+		t.Fatalf("Apply persistence removal failed: %v", err)
+	}
+	if svc := mock.Services[key80]; svc.Timeout != 0 || svc.Netmask != 0xFFFFFFFF {
+		t.Errorf("Expected persistence disabled, got %ds/0x%x", svc.Timeout, svc.Netmask)
+	}
+
+	// 2d. Update (Forwarding method follows the config mode)
+	if got := mock.Destinations[key80][0].Forward; got != ForwardDR {
+		t.Errorf("Expected default DR forwarding, got %q", got)
+	}
+	reconciler.SetMode("nat")
+	if err := reconciler.Apply(desired, []string{vip}); err != nil {
+		t.Fatalf("Apply NAT mode failed: %v", err)
+	}
+	if got := mock.Destinations[key80][0].Forward; got != ForwardNAT {
+		t.Errorf("Expected NAT forwarding after mode change, got %q", got)
+	}
+	reconciler.SetMode("")
+
+	// 2e. Update (A backend's forward override beats the mode)
+	desired[0].Backends[0].Forward = "tun"
+	if err := reconciler.Apply(desired, []string{vip}); err != nil {
+		t.Fatalf("Apply backend forward override failed: %v", err)
+	}
+	if got := mock.Destinations[key80][0].Forward; got != ForwardTUN {
+		t.Errorf("Expected TUN forwarding from backend override, got %q", got)
+	}
+	desired[0].Backends[0].Forward = ""
+	if err := reconciler.Apply(desired, []string{vip}); err != nil {
+		t.Fatalf("Apply override removal failed: %v", err)
+	}
+	if got := mock.Destinations[key80][0].Forward; got != ForwardDR {
+		t.Errorf("Expected DR forwarding after override removal, got %q", got)
+	}
+
+	// Connection thresholds are reconciled like the weight
+	desired[0].Backends[0].UpperThreshold = 100
+	desired[0].Backends[0].LowerThreshold = 60
+	if err := reconciler.Apply(desired, []string{vip}); err != nil {
+		t.Fatalf("Apply thresholds failed: %v", err)
+	}
+	if d := mock.Destinations[key80][0]; d.UpperThreshold != 100 || d.LowerThreshold != 60 {
+		t.Errorf("Expected thresholds 100/60, got %d/%d", d.UpperThreshold, d.LowerThreshold)
+	}
+	desired[0].Backends[0].UpperThreshold = 0
+	desired[0].Backends[0].LowerThreshold = 0
+	if err := reconciler.Apply(desired, []string{vip}); err != nil {
+		t.Fatalf("Apply threshold removal failed: %v", err)
+	}
+	if d := mock.Destinations[key80][0]; d.UpperThreshold != 0 || d.LowerThreshold != 0 {
+		t.Errorf("Expected thresholds cleared, got %d/%d", d.UpperThreshold, d.LowerThreshold)
+	}
+
 	// 3. Update (Change Backend Weight)
 	desired[0].Backends[0].Weight = 2
-	if err := reconciler.Apply(desired, vip); err != nil {
+	if err := reconciler.Apply(desired, []string{vip}); err != nil {
 		t.Fatalf("Apply update weight failed: %v", err)
 	}
 
@@ -161,7 +288,7 @@ func TestReconciler(t *testing.T) {
 
 	// 4. Delete (Remove Service 443)
 	desired[0].Ports = []int{80} // Remove 443
-	if err := reconciler.Apply(desired, vip); err != nil {
+	if err := reconciler.Apply(desired, []string{vip}); err != nil {
 		t.Fatalf("Apply delete failed: %v", err)
 	}
 
@@ -173,6 +300,344 @@ func TestReconciler(t *testing.T) {
 	}
 }
 
+func TestReconcilerPlan(t *testing.T) {
+	mock := NewMockManager()
+	reconciler := NewReconciler(mock, observability.NewLogger(observability.ErrorLevel))
+	vip := "192.168.1.100"
+
+	desired := []config.Service{
+		{
+			Name:      "web",
+			Protocol:  "tcp",
+			Ports:     []int{80, 443},
+			Scheduler: "rr",
+			Backends: []config.Backend{
+				{Address: "10.0.0.1", Port: 80, Weight: 1},
+				{Address: "10.0.0.2", Port: 80, Weight: 1},
+			},
+		},
+	}
+	if err := reconciler.Apply(desired, []string{vip}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	plan, err := reconciler.Plan(desired, []string{vip})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if !plan.Empty() {
+		t.Fatalf("expected empty plan after Apply, got:\n%s", plan)
+	}
+
+	// Drop 443, change the scheduler, reweight one backend and replace the other
+	desired[0].Ports = []int{80}
+	desired[0].Scheduler = "wrr"
+	desired[0].Backends = []config.Backend{
+		{Address: "10.0.0.1", Port: 80, Weight: 5},
+		{Address: "10.0.0.3", Port: 80, Weight: 1},
+	}
+	plan, err = reconciler.Plan(desired, []string{vip})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	want := `~ service tcp 192.168.1.100:80 (wrr) (was tcp 192.168.1.100:80 (rr))
+- service tcp 192.168.1.100:443 (rr)
+~ destination tcp:192.168.1.100:80 -> 10.0.0.1:80 weight 5 dr (was 10.0.0.1:80 weight 1 dr)
++ destination tcp:192.168.1.100:80 -> 10.0.0.3:80 weight 1 dr
+- destination tcp:192.168.1.100:80 -> 10.0.0.2:80
+`
+	if got := plan.String(); got != want {
+		t.Errorf("unexpected plan:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Planning changes nothing
+	key80 := "tcp:192.168.1.100:80"
+	if len(mock.Services) != 2 || mock.Services[key80].Scheduler != "rr" || mock.Destinations[key80][0].Weight != 1 {
+		t.Fatalf("Plan modified IPVS state")
+	}
+
+	if err := reconciler.Apply(desired, []string{vip}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if plan, _ := reconciler.Plan(desired, []string{vip}); !plan.Empty() {
+		t.Errorf("expected plan to be applied, remaining:\n%s", plan)
+	}
+}
+
+func TestReconcilerMultipleVIPs(t *testing.T) {
+	mock := NewMockManager()
+	reconciler := NewReconciler(mock, observability.NewLogger(observability.ErrorLevel))
+	vips := []string{"192.168.1.100", "192.168.1.101"}
+
+	// A service on an address we don't manage must survive reconciles
+	foreign := &Service{Address: net.ParseIP("192.168.1.200"), Protocol: "tcp", Port: 80, Scheduler: "rr"}
+	mock.Services[foreign.Key()] = foreign
+
+	web := config.Service{Name: "web", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr",
+		Backends: []config.Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}}}
+	api := config.Service{Name: "api", VIP: "192.168.1.101", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr",
+		Backends: []config.Backend{{Address: "10.0.0.2", Port: 80, Weight: 1}}}
+	if err := reconciler.Apply([]config.Service{web, api}, vips); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	for _, key := range []string{"tcp:192.168.1.100:80", "tcp:192.168.1.101:80", foreign.Key()} {
+		if _, ok := mock.Services[key]; !ok {
+			t.Errorf("expected service %s", key)
+		}
+	}
+	if d := mock.Destinations["tcp:192.168.1.101:80"]; len(d) != 1 || d[0].Address.String() != "10.0.0.2" {
+		t.Errorf("unexpected destinations on the second VIP: %v", d)
+	}
+
+	// Dropping a service deletes it from its VIP only
+	if err := reconciler.Apply([]config.Service{web}, vips); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if _, ok := mock.Services["tcp:192.168.1.101:80"]; ok {
+		t.Error("expected service on the second VIP to be deleted")
+	}
+	if len(mock.Services) != 2 {
+		t.Errorf("expected web and the foreign service, got %d services", len(mock.Services))
+	}
+
+	// A vip outside the managed set is a config error
+	api.VIP = "192.168.1.200"
+	if err := reconciler.Apply([]config.Service{web, api}, vips); err == nil {
+		t.Error("expected an error for an unmanaged service vip")
+	}
+}
+
+func TestReconcilerApplyService(t *testing.T) {
+	mock := NewMockManager()
+	reconciler := NewReconciler(mock, observability.NewLogger(observability.ErrorLevel))
+	vips := []string{"192.168.1.100"}
+
+	web := config.Service{Name: "web", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr",
+		Backends: []config.Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}}}
+	api := config.Service{Name: "api", Protocol: "tcp", Ports: []int{8080}, Scheduler: "rr",
+		Backends: []config.Backend{{Address: "10.0.0.2", Port: 8080, Weight: 1}}}
+	if err := reconciler.Apply([]config.Service{web, api}, vips); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	// A full Apply would delete this; a single-service apply must not look at it
+	stray := &Service{Address: net.ParseIP("192.168.1.100"), Protocol: "tcp", Port: 9999, Scheduler: "rr"}
+	mock.Services[stray.Key()] = stray
+
+	moved := api
+	moved.Ports = []int{8081}
+	moved.Backends = []config.Backend{{Address: "10.0.0.3", Port: 8081, Weight: 2}}
+	if err := reconciler.ApplyService(&api, &moved, vips); err != nil {
+		t.Fatalf("ApplyService failed: %v", err)
+	}
+	if _, ok := mock.Services["tcp:192.168.1.100:8080"]; ok {
+		t.Error("expected the old port of api to be deleted")
+	}
+	if d := mock.Destinations["tcp:192.168.1.100:8081"]; len(d) != 1 || d[0].Address.String() != "10.0.0.3" || d[0].Weight != 2 {
+		t.Errorf("unexpected destinations on the new port of api: %v", d)
+	}
+	for _, key := range []string{"tcp:192.168.1.100:80", stray.Key()} {
+		if _, ok := mock.Services[key]; !ok {
+			t.Errorf("expected service %s to be left alone", key)
+		}
+	}
+
+	// Removing the service deletes only its IPVS services
+	if err := reconciler.ApplyService(&moved, nil, vips); err != nil {
+		t.Fatalf("ApplyService failed: %v", err)
+	}
+	if _, ok := mock.Services["tcp:192.168.1.100:8081"]; ok {
+		t.Error("expected api to be deleted")
+	}
+	if len(mock.Services) != 2 {
+		t.Errorf("expected web and the stray service, got %d services", len(mock.Services))
+	}
+}
+
+func TestReconcilerCleanupPolicy(t *testing.T) {
+	vips := []string{"192.168.1.100"}
+	web := config.Service{Name: "web", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr",
+		Backends: []config.Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}}}
+	other := &Service{Address: net.ParseIP("192.168.1.100"), Protocol: "tcp", Port: 9999, Scheduler: "rr"}
+
+	for _, tc := range []struct {
+		policy string
+		kept   bool
+	}{
+		{"", false},
+		{"strict", false},
+		{"warn", true},
+		{"IGNORE", true},
+	} {
+		mock := NewMockManager()
+		mock.Services[other.Key()] = other
+		reconciler := NewReconciler(mock, observability.NewLogger(observability.ErrorLevel))
+		reconciler.SetCleanup(tc.policy)
+
+		plan, err := reconciler.Plan([]config.Service{web}, vips)
+		if err != nil {
+			t.Fatalf("%q: Plan failed: %v", tc.policy, err)
+		}
+		if got := len(plan.Foreign) == 1; got != tc.kept {
+			t.Errorf("%q: expected foreign listed in plan = %v, got %v", tc.policy, tc.kept, plan.Foreign)
+		}
+
+		if err := reconciler.Apply([]config.Service{web}, vips); err != nil {
+			t.Fatalf("%q: Apply failed: %v", tc.policy, err)
+		}
+		if _, ok := mock.Services[other.Key()]; ok != tc.kept {
+			t.Errorf("%q: expected foreign service kept = %v", tc.policy, tc.kept)
+		}
+		if got := reconciler.Foreign(); tc.kept && (len(got) != 1 || got[0].Key() != other.Key()) || !tc.kept && len(got) != 0 {
+			t.Errorf("%q: unexpected Foreign() %v", tc.policy, got)
+		}
+	}
+}
+
+func TestReconcilerCleanupKeepsOnlyForeign(t *testing.T) {
+	vips := []string{"192.168.1.100"}
+	web := config.Service{Name: "web", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr",
+		Backends: []config.Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}}}
+	api := config.Service{Name: "api", Protocol: "tcp", Ports: []int{8080}, Scheduler: "rr",
+		Backends: []config.Backend{{Address: "10.0.0.2", Port: 8080, Weight: 1}}}
+	dns := config.Service{Name: "dns", Protocol: "udp", Ports: []int{53}, Scheduler: "rr",
+		Backends: []config.Backend{{Address: "10.0.0.3", Port: 53, Weight: 1}}}
+	other := &Service{Address: net.ParseIP("192.168.1.100"), Protocol: "tcp", Port: 9999, Scheduler: "rr"}
+
+	for _, policy := range []string{"warn", "ignore"} {
+		mock := NewMockManager()
+		mock.Services[other.Key()] = other
+		// Already in IPVS when lbctl starts; adopted by the first Apply
+		adopted := &Service{Address: net.ParseIP("192.168.1.100"), Protocol: "udp", Port: 53, Scheduler: "rr"}
+		mock.Services[adopted.Key()] = adopted
+		ownerFile := filepath.Join(t.TempDir(), "owned.json")
+		reconciler := NewReconciler(mock, observability.NewLogger(observability.ErrorLevel))
+		reconciler.SetCleanup(policy)
+		if err := reconciler.SetOwnerFile(ownerFile); err != nil {
+			t.Fatalf("%s: SetOwnerFile: %v", policy, err)
+		}
+
+		if err := reconciler.Apply([]config.Service{web, api, dns}, vips); err != nil {
+			t.Fatalf("%s: Apply failed: %v", policy, err)
+		}
+
+		// Removing a service from config deletes it
+		if err := reconciler.Apply([]config.Service{web, api}, vips); err != nil {
+			t.Fatalf("%s: Apply failed: %v", policy, err)
+		}
+		if _, ok := mock.Services[adopted.Key()]; ok {
+			t.Errorf("%s: expected dns removed from config to be deleted", policy)
+		}
+
+		// So does removing it with a single-service reload
+		if err := reconciler.ApplyService(&api, nil, vips); err != nil {
+			t.Fatalf("%s: ApplyService failed: %v", policy, err)
+		}
+		if _, ok := mock.Services["tcp:192.168.1.100:8080"]; ok {
+			t.Errorf("%s: expected api to be deleted", policy)
+		}
+
+		// A restarted daemon still owns web; disabling deletes it
+		restarted := NewReconciler(mock, observability.NewLogger(observability.ErrorLevel))
+		restarted.SetCleanup(policy)
+		if err := restarted.SetOwnerFile(ownerFile); err != nil {
+			t.Fatalf("%s: SetOwnerFile: %v", policy, err)
+		}
+		if err := restarted.Apply(nil, vips); err != nil {
+			t.Fatalf("%s: disable failed: %v", policy, err)
+		}
+		if len(mock.Services) != 1 || mock.Services[other.Key()] == nil {
+			t.Errorf("%s: expected only the foreign service left, got %v", policy, mock.Services)
+		}
+		if got := restarted.Foreign(); len(got) != 1 || got[0].Key() != other.Key() {
+			t.Errorf("%s: unexpected Foreign() %v", policy, got)
+		}
+	}
+}
+
+func TestReconcilerAdopt(t *testing.T) {
+	vips := []string{"192.168.1.100"}
+	web := config.Service{Name: "web", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr",
+		Backends: []config.Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}}}
+	// Left by a previous run, with settings that differ from config
+	left := &Service{Address: net.ParseIP("192.168.1.100"), Protocol: "tcp", Port: 80, Scheduler: "wrr"}
+	other := &Service{Address: net.ParseIP("192.168.1.100"), Protocol: "tcp", Port: 9999, Scheduler: "rr"}
+
+	mock := NewMockManager()
+	mock.Services[left.Key()] = left
+	mock.Services[other.Key()] = other
+	reconciler := NewReconciler(mock, observability.NewLogger(observability.ErrorLevel))
+	reconciler.SetCleanup(CleanupWarn)
+
+	keys, err := reconciler.Adopt([]config.Service{web}, vips)
+	if err != nil {
+		t.Fatalf("Adopt failed: %v", err)
+	}
+	if len(keys) != 1 || keys[0] != left.Key() {
+		t.Fatalf("expected web to be adopted, got %v", keys)
+	}
+	if mock.Services[left.Key()].Scheduler != "wrr" || len(mock.Services) != 2 {
+		t.Fatalf("expected Adopt to leave IPVS unchanged, got %v", mock.Services)
+	}
+
+	// Adopted services are deleted on disable; foreign ones are kept
+	if err := reconciler.Apply(nil, vips); err != nil {
+		t.Fatalf("disable failed: %v", err)
+	}
+	if len(mock.Services) != 1 || mock.Services[other.Key()] == nil {
+		t.Errorf("expected only the foreign service left, got %v", mock.Services)
+	}
+}
+
+// fakeResolver answers from addrs and fails for everything else
+type fakeResolver map[string][]net.IP
+
+func (r fakeResolver) LookupIP(host string) ([]net.IP, error) {
+	if ips, ok := r[host]; ok {
+		return ips, nil
+	}
+	return nil, fmt.Errorf("lookup %s: server misbehaving", host)
+}
+
+func TestReconcilerHostnameBackends(t *testing.T) {
+	mock := NewMockManager()
+	reconciler := NewReconciler(mock, observability.NewLogger(observability.ErrorLevel))
+	res := fakeResolver{"api.internal": {net.ParseIP("fd00::5"), net.ParseIP("10.0.0.5")}}
+	reconciler.SetResolver(res)
+	vips := []string{"192.168.1.100"}
+	web := config.Service{Name: "web", Protocol: "tcp", Ports: []int{80}, Scheduler: "rr",
+		Backends: []config.Backend{
+			{Address: "api.internal", Port: 8080, Weight: 1},
+			{Address: "gone.internal", Port: 8080, Weight: 1},
+		}}
+	key := "tcp:192.168.1.100:80"
+
+	if err := reconciler.Apply([]config.Service{web}, vips); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	// The IPv4 address matches the VIP; the unresolvable backend is left out
+	if d := mock.Destinations[key]; len(d) != 1 || d[0].Address.String() != "10.0.0.5" {
+		t.Fatalf("unexpected destinations %v", d)
+	}
+
+	// A failed lookup keeps the last address instead of removing the backend
+	delete(res, "api.internal")
+	if err := reconciler.Apply([]config.Service{web}, vips); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if d := mock.Destinations[key]; len(d) != 1 || d[0].Address.String() != "10.0.0.5" {
+		t.Fatalf("expected destination kept across a failed lookup, got %v", d)
+	}
+
+	res["api.internal"] = []net.IP{net.ParseIP("10.0.0.6")}
+	if err := reconciler.Apply([]config.Service{web}, vips); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if d := mock.Destinations[key]; len(d) != 1 || d[0].Address.String() != "10.0.0.6" {
+		t.Fatalf("expected destination moved to the new address, got %v", d)
+	}
+}
+
 func TestExpandConfig(t *testing.T) {
 	// Test port ranges and port 0 handling
 	r := &Reconciler{}
@@ -189,7 +654,7 @@ func TestExpandConfig(t *testing.T) {
 		},
 	}
 
-	state, err := r.expandConfig(desired, vip)
+	state, err := r.expandConfig(desired, []string{vip})
 	if err != nil {
 		t.Fatalf("expandConfig failed: %v", err)
 	}
@@ -208,3 +673,506 @@ func TestExpandConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestExpandConfig_BackendPortMapping(t *testing.T) {
+	r := &Reconciler{forward: ForwardNAT}
+	vip := "192.168.1.100"
+	desired := []config.Service{{
+		Name:       "game",
+		Protocol:   "udp",
+		PortRanges: []config.PortRange{{Start: 7000, End: 7002}},
+		Backends: []config.Backend{
+			{Address: "10.0.0.1", Weight: 1, PortOffset: 1000},
+			{Address: "10.0.0.2", Weight: 1, PortRange: config.PortRange{Start: 17000, End: 17002}},
+			{Address: "10.0.0.3", Weight: 1, Port: 9000},
+		},
+	}}
+
+	state, err := r.expandConfig(desired, []string{vip})
+	if err != nil {
+		t.Fatalf("expandConfig failed: %v", err)
+	}
+	for _, port := range []uint16{7000, 7001, 7002} {
+		s, ok := state[fmt.Sprintf("udp:%s:%d", vip, port)]
+		if !ok {
+			t.Fatalf("service %d missing", port)
+		}
+		want := []uint16{port + 1000, port + 10000, 9000}
+		for i, d := range s.Destinations {
+			if d.Port != want[i] {
+				t.Errorf("port %d: destination %s on port %d, want %d", port, d.Address, d.Port, want[i])
+			}
+		}
+	}
+
+	// A new offset is a new expansion
+	r2 := NewReconciler(NewMockManager(), observability.NewLogger(observability.ErrorLevel))
+	r2.SetMode("nat")
+	if err := r2.Apply(desired, []string{vip}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	desired[0].Backends[0].PortOffset = 2000
+	if err := r2.Apply(desired, []string{vip}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if got := r2.LastExpandStats(); got != (ExpandStats{Misses: 1}) {
+		t.Fatalf("LastExpandStats() = %+v, want a miss after changing the offset", got)
+	}
+}
+
+func TestExpandConfig_CatchAll(t *testing.T) {
+	r := &Reconciler{forward: ForwardDR}
+	vip := "192.168.1.100"
+	desired := []config.Service{{
+		Name:               "transparent",
+		Protocol:           "tcp",
+		Ports:              []int{0},
+		Scheduler:          "wlc",
+		PersistenceTimeout: 300,
+		Backends:           []config.Backend{{Address: "10.0.0.1", Weight: 1}},
+	}}
+
+	state, err := r.expandConfig(desired, []string{vip})
+	if err != nil {
+		t.Fatalf("expandConfig failed: %v", err)
+	}
+	s, ok := state[fmt.Sprintf("tcp:%s:0", vip)]
+	if len(state) != 1 || !ok {
+		t.Fatalf("expected a single port-0 service, got %v", state)
+	}
+	if s.Service.Timeout != 300 || s.Destinations[0].Port != 0 {
+		t.Fatalf("expected a persistent service keeping the destination port, got timeout %d, destination port %d",
+			s.Service.Timeout, s.Destinations[0].Port)
+	}
+}
+
+func TestExpandConfig_TCPAndUDP(t *testing.T) {
+	r := &Reconciler{forward: ForwardDR}
+	vip := "192.168.1.100"
+	desired := []config.Service{{
+		Name:      "dns",
+		Protocol:  "tcp+udp",
+		Ports:     []int{53},
+		Scheduler: "rr",
+		Backends:  []config.Backend{{Address: "10.0.0.1", Weight: 1}, {Address: "10.0.0.2", Weight: 1}},
+	}}
+
+	state, err := r.expandConfig(desired, []string{vip})
+	if err != nil {
+		t.Fatalf("expandConfig failed: %v", err)
+	}
+	if len(state) != 2 {
+		t.Fatalf("expected a tcp and a udp service, got %v", state)
+	}
+	for _, proto := range []string{"tcp", "udp"} {
+		s, ok := state[fmt.Sprintf("%s:%s:53", proto, vip)]
+		if !ok {
+			t.Fatalf("missing %s service", proto)
+		}
+		if s.Service.Protocol != proto || len(s.Destinations) != 2 {
+			t.Errorf("%s: expected protocol %s with 2 destinations, got %s with %d",
+				proto, proto, s.Service.Protocol, len(s.Destinations))
+		}
+	}
+}
+
+func TestReconcilerExpandCache(t *testing.T) {
+	mock := NewMockManager()
+	reconciler := NewReconciler(mock, observability.NewLogger(observability.ErrorLevel))
+	reconciler.SetResolver(fakeResolver{"api.internal": {net.ParseIP("10.0.0.9")}})
+	vips := []string{"192.168.1.100"}
+	desired := []config.Service{
+		{Name: "range", Protocol: "udp", Scheduler: "wrr", PortRanges: []config.PortRange{{Start: 100, End: 199}},
+			Backends: []config.Backend{{Address: "10.0.0.1", Weight: 1}}},
+		{Name: "web", Protocol: "tcp", Scheduler: "rr", Ports: []int{80},
+			Backends: []config.Backend{{Address: "api.internal", Weight: 1}}},
+	}
+	apply := func(want ExpandStats) {
+		t.Helper()
+		if err := reconciler.Apply(desired, vips); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		if got := reconciler.LastExpandStats(); got != want {
+			t.Fatalf("LastExpandStats() = %+v, want %+v", got, want)
+		}
+	}
+
+	// Hostname backends are expanded every time
+	apply(ExpandStats{Misses: 2})
+	apply(ExpandStats{Hits: 1, Misses: 1})
+
+	desired[0].Backends[0].Weight = 3
+	apply(ExpandStats{Misses: 2})
+	if d := mock.Destinations["udp:192.168.1.100:150"]; len(d) != 1 || d[0].Weight != 3 {
+		t.Fatalf("expected the new weight applied, got %v", d)
+	}
+	apply(ExpandStats{Hits: 1, Misses: 1})
+
+	// A mode change forwards differently, so nothing is reused
+	reconciler.SetMode("nat")
+	apply(ExpandStats{Misses: 2})
+	if d := mock.Destinations["udp:192.168.1.100:150"]; d[0].Forward != ForwardNAT {
+		t.Fatalf("expected NAT forwarding, got %q", d[0].Forward)
+	}
+
+	desired = desired[1:]
+	apply(ExpandStats{Misses: 1})
+	if _, ok := reconciler.expanded["range"]; ok {
+		t.Fatalf("expected the removed service's expansion to be dropped")
+	}
+}
+
+func TestSimManager(t *testing.T) {
+	var out bytes.Buffer
+	logger := observability.NewLogger(observability.InfoLevel)
+	logger.SetConsoleOutput(&out)
+	reconciler := NewReconciler(NewSimManager(logger), logger)
+	vips := []string{"192.168.1.100"}
+	desired := []config.Service{
+		{Name: "web", Protocol: "tcp", Scheduler: "rr", Ports: []int{80},
+			Backends: []config.Backend{{Address: "10.0.0.1", Port: 8080, Weight: 1}, {Address: "10.0.0.2", Port: 8080, Weight: 1}}},
+	}
+	ops := func() int {
+		n := 0
+		for _, op := range reconciler.LastOps() {
+			n += op.OK + op.Failed
+		}
+		return n
+	}
+
+	if err := reconciler.Apply(desired, vips); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if n := ops(); n != 3 {
+		t.Fatalf("expected 3 simulated writes, got %d", n)
+	}
+	for _, want := range []string{"Dry run: create service tcp 192.168.1.100:80 (rr)", "Dry run: create destination tcp:192.168.1.100:80 -> 10.0.0.2:8080 (weight 1)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q logged, got:\n%s", want, out.String())
+		}
+	}
+
+	// The simulated state converges like the kernel's
+	if err := reconciler.Apply(desired, vips); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if n := ops(); n != 0 {
+		t.Fatalf("expected no writes once converged, got %d", n)
+	}
+
+	desired[0].Backends = desired[0].Backends[:1]
+	if err := reconciler.Apply(desired, vips); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	stats, err := reconciler.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if len(stats) != 1 || len(stats[0].Destinations) != 1 {
+		t.Fatalf("expected 1 service with 1 destination, got %+v", stats)
+	}
+
+	m, err := OpenManager(config.DaemonConfig{DryRun: true, StateCache: config.CacheConfig{Enabled: true}}, false, logger)
+	if err != nil {
+		t.Fatalf("OpenManager failed: %v", err)
+	}
+	if cm, ok := m.(*CachedManager); !ok {
+		t.Fatalf("expected a cached manager, got %T", m)
+	} else if _, ok := cm.Inner().(*SimManager); !ok {
+		t.Fatalf("expected daemon.dry_run to simulate IPVS, got %T", cm.Inner())
+	}
+}
+
+func TestParseConnections(t *testing.T) {
+	table := `Pro FromIP   FPrt ToIP     TPrt DestIP   DPrt State       Expires PEName PEData
+TCP CB007107 9C40 C000020A 0050 0A000001 0050 ESTABLISHED     899
+UDP CB007108 D431 C000020A 0035 0A000002 0035 UDP              55
+TCP 2001:0db8:0000:0000:0000:0000:0000:0009 9C40 2001:0db8:0000:0000:0000:0000:0000:0010 01BB 2001:0db8:0000:0000:0000:0000:0001:0001 01BB SYN_RECV         30
+TCP ZZZZZZZZ 9C40 C000020A 0050 0A000001 0050 ESTABLISHED     899
+`
+	conns, err := ParseConnections(strings.NewReader(table))
+	if err != nil {
+		t.Fatalf("ParseConnections failed: %v", err)
+	}
+	if len(conns) != 3 {
+		t.Fatalf("expected 3 connections, got %d: %+v", len(conns), conns)
+	}
+	c := conns[0]
+	if c.Protocol != "tcp" || c.Client.String() != "203.0.113.7" || c.ClientPort != 40000 ||
+		c.VIP.String() != "192.0.2.10" || c.Port != 80 || c.Dest.String() != "10.0.0.1" || c.DestPort != 80 || c.State != "ESTABLISHED" {
+		t.Errorf("unexpected IPv4 connection: %+v", c)
+	}
+	if conns[1].Protocol != "udp" || conns[1].Port != 53 {
+		t.Errorf("unexpected UDP connection: %+v", conns[1])
+	}
+	if c := conns[2]; c.Client.String() != "2001:db8::9" || c.Port != 443 || c.Dest.String() != "2001:db8::1:1" {
+		t.Errorf("unexpected IPv6 connection: %+v", c)
+	}
+}
+
+func TestReconcilerStats(t *testing.T) {
+	mock := NewMockManager()
+	reconciler := NewReconciler(mock, observability.NewLogger(observability.ErrorLevel))
+
+	desired := []config.Service{{
+		Name:      "web",
+		Protocol:  "tcp",
+		Ports:     []int{80},
+		Scheduler: "rr",
+		Backends:  []config.Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}},
+	}}
+	if err := reconciler.Apply(desired, []string{"192.168.1.100"}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	key := "tcp:192.168.1.100:80"
+	mock.Services[key].Stats = Stats{Connections: 7, BytesIn: 1024, CPS: 2}
+	mock.Destinations[key][0].ActiveConns = 3
+	mock.Destinations[key][0].InactiveConns = 4
+
+	stats, err := reconciler.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("expected 1 service, got %d", len(stats))
+	}
+	if got := stats[0].Service.Stats; got.Connections != 7 || got.BytesIn != 1024 || got.CPS != 2 {
+		t.Errorf("unexpected service stats: %+v", got)
+	}
+	if len(stats[0].Destinations) != 1 {
+		t.Fatalf("expected 1 destination, got %d", len(stats[0].Destinations))
+	}
+	if d := stats[0].Destinations[0]; d.ActiveConns != 3 || d.InactiveConns != 4 {
+		t.Errorf("unexpected destination connections: active=%d inactive=%d", d.ActiveConns, d.InactiveConns)
+	}
+}
+
+func TestReconcilerDrain(t *testing.T) {
+	mock := NewMockManager()
+	reconciler := NewReconciler(mock, observability.NewLogger(observability.ErrorLevel))
+	clk := clock.NewFake(time.Unix(1000, 0))
+	reconciler.SetDrain(DrainConfig{Enabled: true, Timeout: time.Minute, Threshold: 1, Clock: clk})
+	vip := "192.168.1.100"
+	key := "tcp:192.168.1.100:80"
+
+	svc := config.Service{
+		Name:      "web",
+		Protocol:  "tcp",
+		Ports:     []int{80},
+		Scheduler: "rr",
+		Backends: []config.Backend{
+			{Address: "10.0.0.1", Port: 80, Weight: 1},
+			{Address: "10.0.0.2", Port: 80, Weight: 1},
+			{Address: "10.0.0.3", Port: 80, Weight: 1},
+		},
+	}
+	if err := reconciler.Apply([]config.Service{svc}, []string{vip}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	dest := func(addr string) *Destination {
+		for _, d := range mock.Destinations[key] {
+			if d.Address.String() == addr {
+				return d
+			}
+		}
+		return nil
+	}
+	dest("10.0.0.2").ActiveConns = 5
+	dest("10.0.0.3").ActiveConns = 1 // At the threshold
+
+	// .2 is removed from config and .3 marked for maintenance
+	removed := svc
+	removed.Backends = []config.Backend{
+		{Address: "10.0.0.1", Port: 80, Weight: 1},
+		{Address: "10.0.0.3", Port: 80, Weight: 1, Drain: true},
+	}
+	plan, err := reconciler.Plan([]config.Service{removed}, []string{vip})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if want := "~ destination " + key + " -> 10.0.0.2:80 drain (5 conns)\n- destination " + key + " -> 10.0.0.3:80\n"; plan.String() != want {
+		t.Fatalf("unexpected plan:\n%s\nwant:\n%s", plan, want)
+	}
+	if reconciler.Draining() != 0 {
+		t.Fatal("Plan must not start draining")
+	}
+
+	if err := reconciler.Apply([]config.Service{removed}, []string{vip}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if d := dest("10.0.0.2"); d == nil || d.Weight != 0 {
+		t.Fatalf("expected 10.0.0.2 kept at weight 0, got %+v", d)
+	}
+	if dest("10.0.0.3") != nil {
+		t.Fatal("expected 10.0.0.3 deleted at the connection threshold")
+	}
+	if reconciler.Draining() != 1 {
+		t.Fatalf("expected 1 draining destination, got %d", reconciler.Draining())
+	}
+
+	// Connections still open: nothing changes
+	clk.Advance(30 * time.Second)
+	if plan, _ := reconciler.Plan([]config.Service{removed}, []string{vip}); !plan.Empty() {
+		t.Fatalf("expected no changes while draining, got:\n%s", plan)
+	}
+
+	// The timeout deletes it regardless of connections
+	clk.Advance(30 * time.Second)
+	if err := reconciler.Apply([]config.Service{removed}, []string{vip}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if dest("10.0.0.2") != nil {
+		t.Fatal("expected 10.0.0.2 deleted after the drain timeout")
+	}
+	if reconciler.Draining() != 0 {
+		t.Fatalf("expected nothing draining, got %d", reconciler.Draining())
+	}
+
+	// A draining backend that returns to config gets its weight back
+	if err := reconciler.Apply([]config.Service{svc}, []string{vip}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	dest("10.0.0.2").ActiveConns = 5
+	if err := reconciler.Apply([]config.Service{removed}, []string{vip}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if reconciler.Draining() != 1 {
+		t.Fatalf("expected 1 draining destination, got %d", reconciler.Draining())
+	}
+	if err := reconciler.Apply([]config.Service{svc}, []string{vip}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if d := dest("10.0.0.2"); d == nil || d.Weight != 1 {
+		t.Fatalf("expected 10.0.0.2 back at weight 1, got %+v", d)
+	}
+	if reconciler.Draining() != 0 {
+		t.Fatalf("expected nothing draining, got %d", reconciler.Draining())
+	}
+}
+
+func TestReconcilerDrainRemovedService(t *testing.T) {
+	mock := NewMockManager()
+	reconciler := NewReconciler(mock, observability.NewLogger(observability.ErrorLevel))
+	clk := clock.NewFake(time.Unix(1000, 0))
+	reconciler.SetDrain(DrainConfig{Enabled: true, Timeout: time.Minute, Clock: clk})
+	vip := "192.168.1.100"
+	key := "udp:192.168.1.100:53"
+
+	svc := config.Service{
+		Name:      "dns",
+		Protocol:  "udp",
+		Ports:     []int{53},
+		Scheduler: "rr",
+		Backends: []config.Backend{
+			{Address: "10.0.0.1", Port: 53, Weight: 1},
+			{Address: "10.0.0.2", Port: 53, Weight: 1},
+		},
+	}
+	if err := reconciler.Apply([]config.Service{svc}, []string{vip}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	// UDP flows only ever show up as inactive connections
+	mock.Destinations[key][0].InactiveConns = 3
+
+	// The service is removed: its destinations drain before it is deleted
+	if err := reconciler.Apply(nil, []string{vip}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if _, ok := mock.Services[key]; !ok {
+		t.Fatal("expected the service kept while a destination drains")
+	}
+	if n := len(mock.Destinations[key]); n != 1 || mock.Destinations[key][0].Weight != 0 {
+		t.Fatalf("expected one destination left at weight 0, got %+v", mock.Destinations[key])
+	}
+	if reconciler.Draining() != 1 {
+		t.Fatalf("expected 1 draining destination, got %d", reconciler.Draining())
+	}
+
+	// Its flows expire: the service goes with it
+	mock.Destinations[key][0].InactiveConns = 0
+	if err := reconciler.Apply(nil, []string{vip}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if _, ok := mock.Services[key]; ok {
+		t.Fatal("expected the drained service deleted")
+	}
+	if reconciler.Draining() != 0 {
+		t.Fatalf("expected nothing draining, got %d", reconciler.Draining())
+	}
+}
+
+func TestReconcilerTimeouts(t *testing.T) {
+	mock := NewMockManager()
+	mock.Timeout = Timeouts{TCP: 900 * time.Second, TCPFin: 120 * time.Second, UDP: 300 * time.Second}
+	reconciler := NewReconciler(mock, observability.NewLogger(observability.ErrorLevel))
+
+	// Nothing configured: the kernel isn't touched
+	if err := reconciler.SetTimeouts(TimeoutsFromConfig(config.IPVSTimeoutsConfig{})); err != nil {
+		t.Fatalf("SetTimeouts failed: %v", err)
+	}
+	// Matches the kernel already
+	if err := reconciler.SetTimeouts(TimeoutsFromConfig(config.IPVSTimeoutsConfig{TCP: 900})); err != nil {
+		t.Fatalf("SetTimeouts failed: %v", err)
+	}
+	if len(mock.TimeoutSets) != 0 {
+		t.Fatalf("expected no timeout changes, got %+v", mock.TimeoutSets)
+	}
+
+	// Only the differing timeouts are sent
+	if err := reconciler.SetTimeouts(TimeoutsFromConfig(config.IPVSTimeoutsConfig{TCP: 900, UDP: 30})); err != nil {
+		t.Fatalf("SetTimeouts failed: %v", err)
+	}
+	if want := []Timeouts{{UDP: 30 * time.Second}}; !slices.Equal(mock.TimeoutSets, want) {
+		t.Fatalf("expected %+v, got %+v", want, mock.TimeoutSets)
+	}
+	if mock.Timeout.TCP != 900*time.Second || mock.Timeout.UDP != 30*time.Second {
+		t.Errorf("unexpected kernel timeouts: %+v", mock.Timeout)
+	}
+}
+
+func TestReconcilerSyncDaemons(t *testing.T) {
+	mock := NewMockManager()
+	reconciler := NewReconciler(mock, observability.NewLogger(observability.ErrorLevel))
+	cfg := config.ConnSyncConfig{Enabled: true, Interface: "eth1", SyncID: 7}
+
+	if err := reconciler.SetSyncDaemons(SyncDaemonsForConfig(cfg, false)); err != nil {
+		t.Fatalf("SetSyncDaemons failed: %v", err)
+	}
+	want := []SyncDaemon{{State: SyncBackup, Interface: "eth1", SyncID: 7}}
+	if !slices.Equal(mock.Daemons, want) {
+		t.Fatalf("expected backup daemon, got %+v", mock.Daemons)
+	}
+
+	// Already running: nothing to do
+	if err := reconciler.SetSyncDaemons(SyncDaemonsForConfig(cfg, false)); err != nil {
+		t.Fatalf("SetSyncDaemons failed: %v", err)
+	}
+	if len(mock.DaemonOps) != 1 {
+		t.Fatalf("expected no further ops, got %v", mock.DaemonOps)
+	}
+
+	// Taking over the VIP swaps backup for master
+	if err := reconciler.SetSyncDaemons(SyncDaemonsForConfig(cfg, true)); err != nil {
+		t.Fatalf("SetSyncDaemons failed: %v", err)
+	}
+	if got := strings.Join(mock.DaemonOps, ","); got != "start backup,stop backup,start master" {
+		t.Errorf("unexpected ops: %s", got)
+	}
+
+	// A changed interface restarts the daemon
+	cfg.Interface = "eth2"
+	if err := reconciler.SetSyncDaemons(SyncDaemonsForConfig(cfg, true)); err != nil {
+		t.Fatalf("SetSyncDaemons failed: %v", err)
+	}
+	if len(mock.Daemons) != 1 || mock.Daemons[0].Interface != "eth2" {
+		t.Errorf("expected master on eth2, got %+v", mock.Daemons)
+	}
+
+	cfg.Enabled = false
+	if err := reconciler.SetSyncDaemons(SyncDaemonsForConfig(cfg, true)); err != nil {
+		t.Fatalf("SetSyncDaemons failed: %v", err)
+	}
+	if len(mock.Daemons) != 0 {
+		t.Errorf("expected daemons stopped, got %+v", mock.Daemons)
+	}
+}
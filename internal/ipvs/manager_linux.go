@@ -4,32 +4,77 @@ package ipvs
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 	"syscall"
 
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
 	libipvs "github.com/moby/ipvs"
 )
 
-// RealManager implements Manager using moby/ipvs
+// maxHandles bounds the netlink sockets a RealManager keeps open
+const maxHandles = 64
+
+// RealManager implements Manager using moby/ipvs. A moby/ipvs handle owns one
+// netlink socket and must not be shared between goroutines, so the manager
+// keeps a pool of handles and each call borrows one. It is safe for
+// concurrent use; calls run in parallel up to the number of handles.
 type RealManager struct {
-	handle *libipvs.Handle
+	mu      sync.Mutex // Serializes SetHandles and Close
+	handles chan *libipvs.Handle
+	open    int
 }
 
 func NewManager() (*RealManager, error) {
-	handle, err := libipvs.New("")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create IPVS handle: %w", err)
+	m := &RealManager{handles: make(chan *libipvs.Handle, maxHandles)}
+	if err := m.SetHandles(1); err != nil {
+		return nil, err
 	}
-	return &RealManager{handle: handle}, nil
+	return m, nil
+}
+
+// SetHandles opens or closes netlink handles until n (at most 64) are open.
+// Closing waits for handles in use to be returned.
+func (m *RealManager) SetHandles(n int) error {
+	n = min(max(n, 1), maxHandles)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for m.open < n {
+		handle, err := libipvs.New("")
+		if err != nil {
+			return errdefs.Classify(fmt.Errorf("failed to create IPVS handle: %w", err))
+		}
+		m.handles <- handle
+		m.open++
+	}
+	for m.open > n {
+		(<-m.handles).Close()
+		m.open--
+	}
+	return nil
 }
 
 func (m *RealManager) Close() {
-	if m.handle != nil {
-		m.handle.Close()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ; m.open > 0; m.open-- {
+		(<-m.handles).Close()
 	}
 }
 
+// with runs fn on a borrowed handle
+func (m *RealManager) with(fn func(h *libipvs.Handle) error) error {
+	h := <-m.handles
+	defer func() { m.handles <- h }()
+	return fn(h)
+}
+
 func (m *RealManager) GetServices() ([]*Service, error) {
-	svcs, err := m.handle.GetServices()
+	var svcs []*libipvs.Service
+	err := m.with(func(h *libipvs.Handle) (err error) {
+		svcs, err = h.GetServices()
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -42,7 +87,11 @@ func (m *RealManager) GetServices() ([]*Service, error) {
 
 func (m *RealManager) GetDestinations(svc *Service) ([]*Destination, error) {
 	libSvc := fromService(svc)
-	dests, err := m.handle.GetDestinations(libSvc)
+	var dests []*libipvs.Destination
+	err := m.with(func(h *libipvs.Handle) (err error) {
+		dests, err = h.GetDestinations(libSvc)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -54,27 +103,84 @@ func (m *RealManager) GetDestinations(svc *Service) ([]*Destination, error) {
 }
 
 func (m *RealManager) CreateService(svc *Service) error {
-	return m.handle.NewService(fromService(svc))
+	return m.with(func(h *libipvs.Handle) error { return h.NewService(fromService(svc)) })
 }
 
 func (m *RealManager) UpdateService(svc *Service) error {
-	return m.handle.UpdateService(fromService(svc))
+	return m.with(func(h *libipvs.Handle) error { return h.UpdateService(fromService(svc)) })
 }
 
 func (m *RealManager) DeleteService(svc *Service) error {
-	return m.handle.DelService(fromService(svc))
+	return m.with(func(h *libipvs.Handle) error { return h.DelService(fromService(svc)) })
 }
 
 func (m *RealManager) CreateDestination(svc *Service, dst *Destination) error {
-	return m.handle.NewDestination(fromService(svc), fromDestination(dst))
+	return m.with(func(h *libipvs.Handle) error { return h.NewDestination(fromService(svc), fromDestination(dst)) })
 }
 
 func (m *RealManager) UpdateDestination(svc *Service, dst *Destination) error {
-	return m.handle.UpdateDestination(fromService(svc), fromDestination(dst))
+	return m.with(func(h *libipvs.Handle) error { return h.UpdateDestination(fromService(svc), fromDestination(dst)) })
 }
 
 func (m *RealManager) DeleteDestination(svc *Service, dst *Destination) error {
-	return m.handle.DelDestination(fromService(svc), fromDestination(dst))
+	return m.with(func(h *libipvs.Handle) error { return h.DelDestination(fromService(svc), fromDestination(dst)) })
+}
+
+func (m *RealManager) Timeouts() (Timeouts, error) {
+	var c *libipvs.Config
+	err := m.with(func(h *libipvs.Handle) (err error) {
+		c, err = h.GetConfig()
+		return err
+	})
+	if err != nil {
+		return Timeouts{}, errdefs.Classify(fmt.Errorf("failed to read IPVS timeouts: %w", err))
+	}
+	return Timeouts{TCP: c.TimeoutTCP, TCPFin: c.TimeoutTCPFin, UDP: c.TimeoutUDP}, nil
+}
+
+// SetTimeouts sets the kernel's timeouts; the kernel leaves zero ones as they are.
+func (m *RealManager) SetTimeouts(t Timeouts) error {
+	c := &libipvs.Config{TimeoutTCP: t.TCP, TimeoutTCPFin: t.TCPFin, TimeoutUDP: t.UDP}
+	if err := m.with(func(h *libipvs.Handle) error { return h.SetConfig(c) }); err != nil {
+		return errdefs.Classify(fmt.Errorf("failed to set IPVS timeouts: %w", err))
+	}
+	return nil
+}
+
+// Kernel service flag bits (IP_VS_SVC_F_*). The meaning of SCHED1/2 depends on
+// the scheduler; for sh they select fallback and port hashing.
+const (
+	svcFlagPersistent uint32 = 0x0001
+	svcFlagSched1     uint32 = 0x0008
+	svcFlagSched2     uint32 = 0x0010
+)
+
+var schedulerFlagBits = map[string]map[string]uint32{
+	"sh": {"sh-fallback": svcFlagSched1, "sh-port": svcFlagSched2},
+}
+
+// schedulerFlagsToBits maps configured scheduler flag names to kernel bits.
+// Flags that do not belong to the scheduler are ignored; the config validator
+// rejects them before they get here.
+func schedulerFlagsToBits(sched string, flags []string) uint32 {
+	var bits uint32
+	for _, f := range flags {
+		bits |= schedulerFlagBits[sched][f]
+	}
+	return bits
+}
+
+// schedulerFlagsFromBits is the inverse of schedulerFlagsToBits and returns
+// the flag names sorted.
+func schedulerFlagsFromBits(sched string, bits uint32) []string {
+	var flags []string
+	for name, bit := range schedulerFlagBits[sched] {
+		if bits&bit != 0 {
+			flags = append(flags, name)
+		}
+	}
+	sort.Strings(flags)
+	return flags
 }
 
 func toService(s *libipvs.Service) *Service {
@@ -82,11 +188,34 @@ func toService(s *libipvs.Service) *Service {
 	if s.Protocol == syscall.IPPROTO_UDP {
 		proto = "udp"
 	}
+	var timeout uint32
+	if s.Flags&svcFlagPersistent != 0 {
+		timeout = s.Timeout
+	}
 	return &Service{
 		Address:   s.Address,
 		Protocol:  proto,
 		Port:      s.Port,
 		Scheduler: s.SchedName,
+		Flags:     schedulerFlagsFromBits(s.SchedName, s.Flags),
+		Timeout:   timeout,
+		Netmask:   s.Netmask,
+		Stats:     toStats(s.Stats),
+	}
+}
+
+func toStats(s libipvs.SvcStats) Stats {
+	return Stats{
+		Connections: uint64(s.Connections),
+		PacketsIn:   uint64(s.PacketsIn),
+		PacketsOut:  uint64(s.PacketsOut),
+		BytesIn:     s.BytesIn,
+		BytesOut:    s.BytesOut,
+		CPS:         uint64(s.CPS),
+		PPSIn:       uint64(s.PPSIn),
+		PPSOut:      uint64(s.PPSOut),
+		BPSIn:       uint64(s.BPSIn),
+		BPSOut:      uint64(s.BPSOut),
 	}
 }
 
@@ -95,13 +224,56 @@ func fromService(s *Service) *libipvs.Service {
 	if s.Protocol == "udp" {
 		proto = syscall.IPPROTO_UDP
 	}
+	flags := schedulerFlagsToBits(s.Scheduler, s.Flags)
+	if s.Timeout > 0 {
+		flags |= svcFlagPersistent
+	}
+	netmask := s.Netmask
+	if netmask == 0 {
+		netmask = 0xFFFFFFFF
+	}
 	return &libipvs.Service{
 		Address:       s.Address,
 		Protocol:      uint16(proto),
 		Port:          s.Port,
 		SchedName:     s.Scheduler,
+		Flags:         flags,
+		Timeout:       s.Timeout,
 		AddressFamily: syscall.AF_INET,
-		Netmask:       0xFFFFFFFF,
+		Netmask:       netmask,
+	}
+}
+
+// Kernel destination forwarding methods (IP_VS_CONN_F_*), in the low bits of
+// the destination's connection flags.
+const (
+	connFwdMask        uint32 = 0x0007
+	connFwdMasq        uint32 = 0x0000
+	connFwdTunnel      uint32 = 0x0002
+	connFwdDirectRoute uint32 = 0x0003
+)
+
+func forwardToConnFlags(forward string) uint32 {
+	switch forward {
+	case ForwardNAT:
+		return connFwdMasq
+	case ForwardTUN:
+		return connFwdTunnel
+	default:
+		return connFwdDirectRoute
+	}
+}
+
+func forwardFromConnFlags(flags uint32) string {
+	switch flags & connFwdMask {
+	case connFwdMasq:
+		return ForwardNAT
+	case connFwdTunnel:
+		return ForwardTUN
+	case connFwdDirectRoute:
+		return ForwardDR
+	default:
+		return ""
 	}
 }
 
@@ -110,14 +282,25 @@ func toDestination(d *libipvs.Destination) *Destination {
 		Address: d.Address,
 		Port:    d.Port,
 		Weight:  d.Weight,
+		Forward: forwardFromConnFlags(d.ConnectionFlags),
+
+		UpperThreshold: d.UpperThreshold,
+		LowerThreshold: d.LowerThreshold,
+
+		ActiveConns:   d.ActiveConnections,
+		InactiveConns: d.InactiveConnections,
+		Stats:         toStats(libipvs.SvcStats(d.Stats)),
 	}
 }
 
 func fromDestination(d *Destination) *libipvs.Destination {
 	return &libipvs.Destination{
-		Address:       d.Address,
-		Port:          d.Port,
-		Weight:        d.Weight,
-		AddressFamily: syscall.AF_INET,
+		Address:         d.Address,
+		Port:            d.Port,
+		Weight:          d.Weight,
+		ConnectionFlags: forwardToConnFlags(d.Forward),
+		UpperThreshold:  d.UpperThreshold,
+		LowerThreshold:  d.LowerThreshold,
+		AddressFamily:   syscall.AF_INET,
 	}
 }
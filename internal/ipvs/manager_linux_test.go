@@ -0,0 +1,88 @@
+//go:build linux
+
+package ipvs
+
+import (
+	"net"
+	"slices"
+	"testing"
+)
+
+func TestSchedulerFlagMapping(t *testing.T) {
+	svc := &Service{
+		Address:   net.ParseIP("192.168.1.100"),
+		Protocol:  "tcp",
+		Port:      80,
+		Scheduler: "sh",
+		Flags:     []string{"sh-fallback", "sh-port"},
+	}
+
+	lib := fromService(svc)
+	if lib.Flags != svcFlagSched1|svcFlagSched2 {
+		t.Fatalf("expected flags 0x%x, got 0x%x", svcFlagSched1|svcFlagSched2, lib.Flags)
+	}
+	back := toService(lib)
+	if back.Scheduler != "sh" || !slices.Equal(back.Flags, svc.Flags) {
+		t.Fatalf("round trip mismatch: %s %v", back.Scheduler, back.Flags)
+	}
+
+	// Kernel-only bits (e.g. hashed) and sched bits on other schedulers are
+	// not reported as flags.
+	lib.Flags |= 0x0002
+	lib.SchedName = "wlc"
+	if got := toService(lib).Flags; len(got) != 0 {
+		t.Fatalf("expected no flags for wlc, got %v", got)
+	}
+	if bits := schedulerFlagsToBits("rr", []string{"sh-port"}); bits != 0 {
+		t.Fatalf("expected sh flags ignored for rr, got 0x%x", bits)
+	}
+}
+
+func TestPersistenceMapping(t *testing.T) {
+	svc := &Service{
+		Address:   net.ParseIP("192.168.1.100"),
+		Protocol:  "tcp",
+		Port:      443,
+		Scheduler: "wlc",
+		Timeout:   600,
+		Netmask:   0xFFFFFF00,
+	}
+
+	lib := fromService(svc)
+	if lib.Flags&svcFlagPersistent == 0 || lib.Timeout != 600 || lib.Netmask != 0xFFFFFF00 {
+		t.Fatalf("unexpected libipvs service: flags=0x%x timeout=%d netmask=0x%x", lib.Flags, lib.Timeout, lib.Netmask)
+	}
+	if back := toService(lib); !back.sameSettings(svc) {
+		t.Fatalf("round trip mismatch: %+v", back)
+	}
+
+	svc.Timeout = 0
+	svc.Netmask = 0
+	lib = fromService(svc)
+	if lib.Flags&svcFlagPersistent != 0 || lib.Netmask != 0xFFFFFFFF {
+		t.Fatalf("expected persistence disabled, got flags=0x%x netmask=0x%x", lib.Flags, lib.Netmask)
+	}
+	// The kernel may keep a stale timeout on a non-persistent service.
+	lib.Timeout = 300
+	if back := toService(lib); back.Timeout != 0 {
+		t.Fatalf("expected timeout ignored without persistent flag, got %d", back.Timeout)
+	}
+}
+
+func TestForwardingMapping(t *testing.T) {
+	for _, fwd := range []string{ForwardDR, ForwardNAT, ForwardTUN} {
+		d := &Destination{Address: net.ParseIP("10.0.0.1"), Port: 80, Weight: 1, Forward: fwd}
+		lib := fromDestination(d)
+		if got := toDestination(lib).Forward; got != fwd {
+			t.Errorf("forwarding %s round-tripped to %q (flags 0x%x)", fwd, got, lib.ConnectionFlags)
+		}
+	}
+	if got := fromDestination(&Destination{}).ConnectionFlags; got != connFwdDirectRoute {
+		t.Errorf("expected unset forwarding to default to DR, got 0x%x", got)
+	}
+	for mode, want := range map[string]string{"": ForwardDR, "dr": ForwardDR, "NAT": ForwardNAT, "tun": ForwardTUN} {
+		if got := ForwardingForMode(mode); got != want {
+			t.Errorf("ForwardingForMode(%q) = %q, want %q", mode, got, want)
+		}
+	}
+}
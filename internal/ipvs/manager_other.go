@@ -4,12 +4,16 @@ package ipvs
 
 import "fmt"
 
-type RealManager struct {}
+type RealManager struct{}
 
 func NewManager() (*RealManager, error) {
 	return nil, fmt.Errorf("ipvs only supported on linux")
 }
 
+func (m *RealManager) SetHandles(n int) error {
+	return fmt.Errorf("not implemented")
+}
+
 func (m *RealManager) Close() {}
 
 func (m *RealManager) GetServices() ([]*Service, error) {
@@ -43,3 +47,23 @@ func (m *RealManager) UpdateDestination(svc *Service, dst *Destination) error {
 func (m *RealManager) DeleteDestination(svc *Service, dst *Destination) error {
 	return fmt.Errorf("not implemented")
 }
+
+func (m *RealManager) SyncDaemons() ([]SyncDaemon, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (m *RealManager) StartSyncDaemon(d SyncDaemon) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (m *RealManager) StopSyncDaemon(state SyncState) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (m *RealManager) Timeouts() (Timeouts, error) {
+	return Timeouts{}, fmt.Errorf("not implemented")
+}
+
+func (m *RealManager) SetTimeouts(t Timeouts) error {
+	return fmt.Errorf("not implemented")
+}
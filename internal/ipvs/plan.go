@@ -0,0 +1,90 @@
+package ipvs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Change kinds in a Plan
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+	ChangeDrain  = "drain" // Destination set to weight 0 ahead of a delete
+)
+
+// ServiceChange is one planned change to an IPVS service. Service holds the
+// settings to apply, or the service to remove for a delete; Current holds
+// the settings an update replaces.
+type ServiceChange struct {
+	Kind    string
+	Service *Service
+	Current *Service
+}
+
+// DestinationChange is one planned change to a destination of Service.
+// Destination and Current follow the same rules as in ServiceChange.
+type DestinationChange struct {
+	Kind        string
+	Service     *Service
+	Destination *Destination
+	Current     *Destination
+}
+
+// Plan is the set of changes a reconcile would make. Services are sorted by
+// key; destinations follow the backend order of the config, with deletes
+// last. Destinations of deleted services are removed with the service and
+// are not listed.
+type Plan struct {
+	Services     []ServiceChange
+	Destinations []DestinationChange
+	Foreign      []*Service // Services on managed VIPs that aren't desired but the cleanup policy keeps
+
+	draining map[string]time.Time // Drain start of destinations still draining after this plan
+	adopted  []string             // Desired services already in IPVS; owned once the plan runs
+	unread   []*ServiceError      // Services left out because their destinations couldn't be read
+}
+
+// Empty reports whether the plan changes nothing
+func (p *Plan) Empty() bool {
+	return len(p.Services) == 0 && len(p.Destinations) == 0
+}
+
+// String renders the plan as a diff, one change per line: "+" creates, "~"
+// updates and drains, and "-" deletes.
+func (p *Plan) String() string {
+	var sb strings.Builder
+	for _, c := range p.Services {
+		switch c.Kind {
+		case ChangeCreate:
+			fmt.Fprintf(&sb, "+ service %s\n", c.Service)
+		case ChangeUpdate:
+			fmt.Fprintf(&sb, "~ service %s (was %s)\n", c.Service, c.Current)
+		case ChangeDelete:
+			fmt.Fprintf(&sb, "- service %s\n", c.Service)
+		}
+	}
+	for _, c := range p.Destinations {
+		svc := c.Service.Key()
+		switch c.Kind {
+		case ChangeCreate:
+			fmt.Fprintf(&sb, "+ destination %s -> %s\n", svc, destinationSummary(c.Destination))
+		case ChangeUpdate:
+			fmt.Fprintf(&sb, "~ destination %s -> %s (was %s)\n", svc, destinationSummary(c.Destination), destinationSummary(c.Current))
+		case ChangeDrain:
+			fmt.Fprintf(&sb, "~ destination %s -> %s drain (%d conns)\n", svc, c.Destination.Key(), drainConns(c.Service, c.Current))
+		case ChangeDelete:
+			fmt.Fprintf(&sb, "- destination %s -> %s\n", svc, c.Destination.Key())
+		}
+	}
+	return sb.String()
+}
+
+func destinationSummary(d *Destination) string {
+	s := fmt.Sprintf("%s weight %d", d.Key(), d.Weight)
+	if d.Forward != "" {
+		s += " " + d.Forward
+	}
+	return s
+}
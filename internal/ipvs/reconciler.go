@@ -1,24 +1,91 @@
 package ipvs
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"net"
-	"syscall"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
 	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
 	"github.com/malindarathnayake/LibraFlux/internal/observability"
 )
 
 type Reconciler struct {
 	manager Manager
 	logger  *observability.Logger
+	forward string
+
+	drain    DrainConfig
+	draining map[string]time.Time // Drain start by drainKey
+
+	cleanup   string              // Policy for foreign services, see SetCleanup
+	foreign   map[string]*Service // Foreign services kept by the last Apply, by key
+	owned     map[string]bool     // Services lbctl created or adopted, by key; never foreign
+	ownerFile string              // Where owned is persisted, see SetOwnerFile
+
+	concurrency int       // Parallel IPVS writes, see SetConcurrency
+	lastOps     []OpCount // Writes made by the last Apply
+
+	resolver Resolver          // Resolves hostname backends, see SetResolver
+	resolved map[string]net.IP // Last address of each hostname backend
+
+	expanded   map[string]expandEntry // Last expansion of each config service, by name
+	lastExpand ExpandStats            // Expansion cache results of the last Apply
+}
+
+// Resolver looks up the addresses of hostname backends
+type Resolver interface {
+	LookupIP(host string) ([]net.IP, error)
 }
 
 func NewReconciler(manager Manager, logger *observability.Logger) *Reconciler {
 	return &Reconciler{
 		manager: manager,
 		logger:  logger,
+		forward: ForwardDR,
+		drain:   DrainConfig{Clock: clock.Real()},
+	}
+}
+
+// SetMode selects the destination forwarding method from the config mode
+// (dr, nat or tun). It must not be called concurrently with Apply.
+func (r *Reconciler) SetMode(mode string) {
+	r.forward = ForwardingForMode(mode)
+}
+
+// SetResolver resolves backends given as hostnames. A backend whose lookup
+// fails keeps the last address it resolved to; one that never resolved is
+// left out until it does. It must not be called concurrently with Apply.
+func (r *Reconciler) SetResolver(res Resolver) {
+	r.resolver = res
+}
+
+// CacheStats returns the counters of the state cache the reconciler reads
+// through, if its manager is a CachedManager.
+func (r *Reconciler) CacheStats() (CacheStats, bool) {
+	cm, ok := r.manager.(*CachedManager)
+	if !ok {
+		return CacheStats{}, false
 	}
+	return cm.Snapshot(), true
+}
+
+// Services returns the IPVS services currently in the kernel, with their
+// counters.
+func (r *Reconciler) Services() ([]*Service, error) {
+	return r.manager.GetServices()
+}
+
+// Stats returns every IPVS service in the kernel with its destinations and
+// their counters. See CollectStats.
+func (r *Reconciler) Stats() ([]ServiceStats, error) {
+	return CollectStats(r.manager)
 }
 
 type DesiredState struct {
@@ -26,173 +93,508 @@ type DesiredState struct {
 	Destinations []*Destination
 }
 
-// Apply reconciles the desired state with the actual IPVS state
-func (r *Reconciler) Apply(desired []config.Service, vip string) error {
-	// 1. Expand desired config into flat list of IPVS services
-	desiredState, err := r.expandConfig(desired, vip)
+// Apply reconciles the desired state with the actual IPVS state on vips.
+// Services without a vip of their own are placed on the first of vips; IPVS
+// services on other addresses are left alone. A service that fails doesn't
+// stop the others: they are all reconciled, and the failures are returned
+// together as an *ApplyError.
+func (r *Reconciler) Apply(desired []config.Service, vips []string) error {
+	plan, err := r.plan(desired, vips)
+	if plan == nil {
+		return err
+	}
+	if err != nil {
+		// Services whose destinations couldn't be read are left alone
+		r.logger.Errorf("%v", err)
+	}
+	return r.execute(plan)
+}
+
+// Plan returns the changes Apply would make for desired without making them.
+// If the destinations of some services can't be read, the plan covers the
+// rest and the error lists the services left out.
+func (r *Reconciler) Plan(desired []config.Service, vips []string) (*Plan, error) {
+	return r.plan(desired, vips)
+}
+
+// ApplyService reconciles only the IPVS services of one config service:
+// those of current, as it is running now (nil for a new service), and of
+// desired (nil to remove it). Other IPVS services and their draining
+// destinations are left alone, so a single-service change doesn't have to
+// diff every service of a large config.
+func (r *Reconciler) ApplyService(current, desired *config.Service, vips []string) error {
+	managed, err := managedVIPs(vips)
 	if err != nil {
 		return err
 	}
+	var want []config.Service
+	if desired != nil {
+		want = append(want, *desired)
+	}
+	r.lastExpand = ExpandStats{}
+	desiredState, err := r.expandConfig(want, vips)
+	if err != nil {
+		return err
+	}
+	scope := make(map[string]bool, len(desiredState))
+	for key := range desiredState {
+		scope[key] = true
+	}
+	if current != nil {
+		// A service moved off a VIP that is no longer managed has nothing left to remove there
+		// Expanded without the cache, which keeps the desired version
+		if currentStates, err := r.expandService(*current, vips, managed); err == nil {
+			for _, state := range currentStates {
+				scope[state.Service.Key()] = true
+			}
+		}
+	}
+
+	services, err := r.manager.GetServices()
+	if err != nil {
+		return errdefs.Classify(fmt.Errorf("failed to get current IPVS services: %w", err))
+	}
+	var inScope []*Service
+	for _, svc := range services {
+		if scope[svc.Key()] {
+			inScope = append(inScope, svc)
+		}
+	}
+
+	plan, err := r.diff(desiredState, inScope, managed)
+	if err != nil {
+		r.logger.Errorf("%v", err)
+	}
+	for k, start := range r.draining {
+		if svcKey, _, _ := strings.Cut(k, "|"); !scope[svcKey] {
+			plan.draining[k] = start
+		}
+	}
+	for key, svc := range r.foreign {
+		if !scope[key] {
+			plan.Foreign = append(plan.Foreign, svc)
+		}
+	}
+	return r.execute(plan)
+}
+
+func (r *Reconciler) plan(desired []config.Service, vips []string) (*Plan, error) {
+	managed, err := managedVIPs(vips)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. Expand desired config into flat list of IPVS services
+	r.lastExpand = ExpandStats{}
+	desiredState, err := r.expandConfig(desired, vips)
+	if err != nil {
+		return nil, err
+	}
+	r.pruneExpanded(desired)
 
 	// 2. Get current state
 	currentServices, err := r.manager.GetServices()
 	if err != nil {
-		return fmt.Errorf("failed to get current IPVS services: %w", err)
+		return nil, errdefs.Classify(fmt.Errorf("failed to get current IPVS services: %w", err))
 	}
 
-	// 3. Reconcile
-	return r.reconcile(desiredState, currentServices, vip)
+	// 3. Diff
+	return r.diff(desiredState, currentServices, managed)
 }
 
-func (r *Reconciler) reconcile(desired map[string]*DesiredState, current []*Service, managedVIP string) error {
+func (r *Reconciler) diff(desired map[string]*DesiredState, current []*Service, managed map[string]bool) (*Plan, error) {
 	currentMap := make(map[string]*Service)
 	for _, svc := range current {
 		currentMap[svc.Key()] = svc
 	}
 
+	plan := &Plan{draining: make(map[string]time.Time)}
+	var errs []error
+
+	// Reading destinations is a netlink dump per service, so existing
+	// services are read in parallel; the diff itself stays in key order
+	keys := sortedKeys(desired)
+	var existing []*Service
+	for _, key := range keys {
+		if svc, ok := currentMap[key]; ok {
+			existing = append(existing, svc)
+		}
+	}
+	dests := make([][]*Destination, len(existing))
+	readErrs := make([]error, len(existing))
+	parallel(r.concurrency, len(existing), func(i int) {
+		dests[i], readErrs[i] = r.manager.GetDestinations(existing[i])
+	})
+	read := make(map[string]int, len(existing))
+	for i, svc := range existing {
+		read[svc.Key()] = i
+	}
+
 	// Add/Update
-	for key, state := range desired {
+	for _, key := range keys {
+		state := desired[key]
 		currentSvc, exists := currentMap[key]
 		if !exists {
-			// Add
-			r.logger.Infof("Creating IPVS service: %s", key)
-			if err := r.manager.CreateService(state.Service); err != nil {
-				r.logger.Errorf("Failed to create service %s: %v", key, err)
-				continue
-			}
-			// Add destinations
-			if err := r.reconcileDestinations(state.Service, state.Destinations, nil); err != nil {
-				r.logger.Errorf("Failed to reconcile destinations for %s: %v", key, err)
-			}
-		} else {
-			// Update if changed
-			if currentSvc.Scheduler != state.Service.Scheduler {
-				r.logger.Infof("Updating IPVS service: %s", key)
-				currentSvc.Scheduler = state.Service.Scheduler
-				if err := r.manager.UpdateService(currentSvc); err != nil {
-					r.logger.Errorf("Failed to update service %s: %v", key, err)
-				}
-			}
+			plan.Services = append(plan.Services, ServiceChange{Kind: ChangeCreate, Service: state.Service})
+			plan.Destinations = append(plan.Destinations, r.diffDestinations(state.Service, state.Destinations, nil, plan.draining)...)
+			continue
+		}
 
-			// Reconcile destinations
-			currentDests, err := r.manager.GetDestinations(currentSvc)
-			if err != nil {
-				r.logger.Errorf("Failed to get destinations for %s: %v", key, err)
-				continue
-			}
-			if err := r.reconcileDestinations(currentSvc, state.Destinations, currentDests); err != nil {
-				r.logger.Errorf("Failed to reconcile destinations for %s: %v", key, err)
+		plan.adopted = append(plan.adopted, key)
+		svc := currentSvc
+		if !currentSvc.sameSettings(state.Service) {
+			updated := *currentSvc
+			updated.Scheduler = state.Service.Scheduler
+			updated.Flags = state.Service.Flags
+			updated.Timeout = state.Service.Timeout
+			updated.Netmask = state.Service.Netmask
+			plan.Services = append(plan.Services, ServiceChange{Kind: ChangeUpdate, Service: &updated, Current: currentSvc})
+			svc = &updated
+		}
+
+		i := read[key]
+		if err := readErrs[i]; err != nil {
+			errs = append(errs, fmt.Errorf("failed to get destinations for %s: %w", key, err))
+			plan.unread = append(plan.unread, &ServiceError{Service: key, Op: "read destinations", Err: err})
+			// Keep draining what we can't see until the next reconcile
+			for k, start := range r.draining {
+				if strings.HasPrefix(k, key+"|") {
+					plan.draining[k] = start
+				}
 			}
+			continue
 		}
+		plan.Destinations = append(plan.Destinations, r.diffDestinations(svc, state.Destinations, dests[i], plan.draining)...)
 	}
 
 	// Delete
-	for key, svc := range currentMap {
-		// Only delete if it belongs to our managed VIP
-		if svc.Address.String() != managedVIP {
+	for _, key := range sortedKeys(currentMap) {
+		svc := currentMap[key]
+		// Only delete if it belongs to one of our managed VIPs
+		if !managed[svc.Address.String()] {
 			continue
 		}
-
 		if _, exists := desired[key]; !exists {
-			r.logger.Infof("Deleting IPVS service: %s", key)
-			if err := r.manager.DeleteService(svc); err != nil {
-				r.logger.Errorf("Failed to delete service %s: %v", key, err)
+			if r.keepsForeign() && !r.owned[key] {
+				plan.Foreign = append(plan.Foreign, svc)
+				continue
 			}
+			if r.drain.Enabled {
+				current, err := r.manager.GetDestinations(svc)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("failed to get destinations for %s: %w", key, err))
+					plan.unread = append(plan.unread, &ServiceError{Service: key, Op: "read destinations", Err: err})
+					for k, start := range r.draining {
+						if strings.HasPrefix(k, key+"|") {
+							plan.draining[k] = start
+						}
+					}
+					continue
+				}
+				if changes, done := r.drainService(svc, current, plan.draining); !done {
+					plan.Destinations = append(plan.Destinations, changes...)
+					continue
+				}
+			}
+			plan.Services = append(plan.Services, ServiceChange{Kind: ChangeDelete, Service: svc})
 		}
 	}
 
-	return nil
+	return plan, errors.Join(errs...)
 }
 
-func (r *Reconciler) reconcileDestinations(svc *Service, desired []*Destination, current []*Destination) error {
+func (r *Reconciler) diffDestinations(svc *Service, desired, current []*Destination, draining map[string]time.Time) []DestinationChange {
+	var changes []DestinationChange
 	currentMap := make(map[string]*Destination)
 	for _, dest := range current {
 		currentMap[dest.Key()] = dest
 	}
 
+	desiredKeys := make(map[string]bool, len(desired))
 	for _, dest := range desired {
 		key := dest.Key()
+		desiredKeys[key] = true
 		currDest, exists := currentMap[key]
 		if !exists {
-			if err := r.manager.CreateDestination(svc, dest); err != nil {
-				return err
-			}
-		} else {
-			if currDest.Weight != dest.Weight {
-				// Update
-				currDest.Weight = dest.Weight
-				if err := r.manager.UpdateDestination(svc, currDest); err != nil {
-					return err
-				}
-			}
+			changes = append(changes, DestinationChange{Kind: ChangeCreate, Service: svc, Destination: dest})
+		} else if currDest.Weight != dest.Weight || currDest.Forward != dest.Forward ||
+			currDest.UpperThreshold != dest.UpperThreshold || currDest.LowerThreshold != dest.LowerThreshold {
+			updated := *currDest
+			updated.Weight = dest.Weight
+			updated.Forward = dest.Forward
+			updated.UpperThreshold = dest.UpperThreshold
+			updated.LowerThreshold = dest.LowerThreshold
+			changes = append(changes, DestinationChange{Kind: ChangeUpdate, Service: svc, Destination: &updated, Current: currDest})
+		}
+	}
+
+	for _, dest := range current {
+		if desiredKeys[dest.Key()] {
+			continue
 		}
+		if c := r.removeDestination(svc, dest, draining); c != nil {
+			changes = append(changes, *c)
+		}
+	}
+
+	return changes
+}
+
+// execute applies plan. A service that fails to be created gets no
+// destinations, and the first destination failure of a service skips its
+// remaining destination changes; other services are still reconciled. With
+// SetConcurrency, services and the destinations of different services are
+// changed in parallel. The first failure of each service, including those
+// the plan couldn't read, is returned as an *ApplyError.
+func (r *Reconciler) execute(plan *Plan) error {
+	ops := &opCounter{}
+	fails := &failureCollector{}
+	var mu sync.Mutex
+	created := make(map[string]bool)
+	deleted := make(map[string]bool)
+	defer func() {
+		r.draining = plan.draining
+		r.recordForeign(plan.Foreign)
+		r.recordOwned(plan.adopted, created, deleted)
+		r.lastOps = ops.list()
+	}()
+	for _, u := range plan.unread {
+		fails.add(u.Service, u.Op, u.Err)
 	}
 
-	for key, dest := range currentMap {
-		// Check if exists in desired
-		found := false
-		for _, d := range desired {
-			if d.Key() == key {
-				found = true
-				break
+	failed := make(map[string]bool)
+	parallel(r.concurrency, len(plan.Services), func(i int) {
+		c := plan.Services[i]
+		key := c.Service.Key()
+		var err error
+		switch c.Kind {
+		case ChangeCreate:
+			r.logger.InfoFields("Creating IPVS service", observability.String("service", key))
+			if err = r.manager.CreateService(c.Service); err != nil {
+				r.logger.ErrorFields("Failed to create IPVS service", observability.String("service", key), observability.Err(err))
+				fails.add(key, "create service", err)
+				mu.Lock()
+				failed[key] = true
+				mu.Unlock()
+			} else {
+				fails.touch(key)
+				mu.Lock()
+				created[key] = true
+				mu.Unlock()
 			}
+		case ChangeUpdate:
+			r.logger.InfoFields("Updating IPVS service", observability.String("service", key))
+			if err = r.manager.UpdateService(c.Service); err != nil {
+				r.logger.ErrorFields("Failed to update IPVS service", observability.String("service", key), observability.Err(err))
+				fails.add(key, "update service", err)
+			} else {
+				fails.touch(key)
+			}
+		default:
+			return
 		}
-		if !found {
-			if err := r.manager.DeleteDestination(svc, dest); err != nil {
-				return err
+		ops.add("service", c.Kind, err)
+	})
+
+	groups := destinationsByService(plan.Destinations)
+	parallel(r.concurrency, len(groups), func(i int) {
+		for _, c := range groups[i] {
+			key := c.Service.Key()
+			if failed[key] {
+				return
 			}
+			fails.touch(key)
+			var err error
+			switch c.Kind {
+			case ChangeCreate:
+				err = r.manager.CreateDestination(c.Service, c.Destination)
+			case ChangeUpdate:
+				err = r.manager.UpdateDestination(c.Service, c.Destination)
+			case ChangeDrain:
+				r.logger.InfoFields("Draining destination", observability.String("service", key),
+					observability.String("destination", c.Destination.Key()), observability.Int("conns", drainConns(c.Service, c.Current)))
+				err = r.manager.UpdateDestination(c.Service, c.Destination)
+			case ChangeDelete:
+				if _, ok := r.draining[drainKey(c.Service, c.Destination)]; ok {
+					r.logger.InfoFields("Drained destination", observability.String("service", key),
+						observability.String("destination", c.Destination.Key()), observability.Int("conns", drainConns(c.Service, c.Destination)))
+				}
+				err = r.manager.DeleteDestination(c.Service, c.Destination)
+			}
+			ops.add("destination", c.Kind, err)
+			if err != nil {
+				r.logger.ErrorFields("Failed to reconcile destinations", observability.String("service", key),
+					observability.String("destination", c.Destination.Key()), observability.Err(err))
+				fails.add(key, c.Kind+" destination "+c.Destination.Key(), err)
+				return
+			}
+		}
+	})
+
+	parallel(r.concurrency, len(plan.Services), func(i int) {
+		c := plan.Services[i]
+		if c.Kind != ChangeDelete {
+			return
+		}
+		key := c.Service.Key()
+		r.logger.InfoFields("Deleting IPVS service", observability.String("service", key))
+		err := r.manager.DeleteService(c.Service)
+		if err != nil {
+			r.logger.ErrorFields("Failed to delete IPVS service", observability.String("service", key), observability.Err(err))
+			fails.add(key, "delete service", err)
+		} else {
+			fails.touch(key)
+			mu.Lock()
+			deleted[key] = true
+			mu.Unlock()
 		}
+		ops.add("service", c.Kind, err)
+	})
+	return fails.err()
+}
+
+// destinationsByService splits changes into one group per service, keeping
+// the order of changes within a service
+func destinationsByService(changes []DestinationChange) [][]DestinationChange {
+	var groups [][]DestinationChange
+	index := make(map[string]int)
+	for _, c := range changes {
+		key := c.Service.Key()
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], c)
 	}
+	return groups
+}
 
-	return nil
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
-func (r *Reconciler) expandConfig(services []config.Service, vip string) (map[string]*DesiredState, error) {
+// managedVIPs returns the canonical forms of vips, whose IPVS services the
+// reconciler owns.
+func managedVIPs(vips []string) (map[string]bool, error) {
+	managed := make(map[string]bool, len(vips))
+	for _, vip := range vips {
+		parsed := net.ParseIP(vip)
+		if parsed == nil {
+			return nil, errdefs.PermanentConfig(fmt.Errorf("invalid VIP: %s", vip))
+		}
+		managed[parsed.String()] = true
+	}
+	return managed, nil
+}
+
+// expandConfig flattens services into one desired IPVS service per VIP, port
+// and protocol, keyed by Service.Key. Services whose expansion inputs are
+// unchanged since the last call reuse it, see expandCached.
+func (r *Reconciler) expandConfig(services []config.Service, vips []string) (map[string]*DesiredState, error) {
 	result := make(map[string]*DesiredState)
-	parsedVIP := net.ParseIP(vip)
-	if parsedVIP == nil {
-		return nil, fmt.Errorf("invalid VIP: %s", vip)
+	managed, err := managedVIPs(vips)
+	if err != nil {
+		return nil, err
 	}
 
 	for _, svc := range services {
-		proto := ProtocolToUint16(svc.Protocol)
-		protoStr := "tcp"
-		if proto == syscall.IPPROTO_UDP {
-			protoStr = "udp"
+		states, err := r.expandCached(svc, vips, managed)
+		if err != nil {
+			return nil, err
+		}
+		for _, state := range states {
+			result[state.Service.Key()] = state
 		}
+	}
 
-		// Collect ports
-		ports := make([]uint16, 0)
-		for _, p := range svc.Ports {
-			ports = append(ports, uint16(p))
+	return result, nil
+}
+
+// expandService flattens one config service into its desired IPVS services.
+func (r *Reconciler) expandService(svc config.Service, vips []string, managed map[string]bool) ([]*DesiredState, error) {
+	vip := svc.VIP
+	if vip == "" {
+		if len(vips) == 0 {
+			return nil, errdefs.PermanentConfig(fmt.Errorf("service %s: no VIP", svc.Name))
 		}
-		for _, pr := range svc.PortRanges {
-			for p := pr.Start; p <= pr.End; p++ {
-				ports = append(ports, uint16(p))
-			}
+		vip = vips[0]
+	}
+	parsedVIP := net.ParseIP(vip)
+	if parsedVIP == nil || !managed[parsedVIP.String()] {
+		return nil, errdefs.PermanentConfig(fmt.Errorf("service %s: vip %s is not managed", svc.Name, vip))
+	}
+
+	// Collect ports
+	ports := make([]uint16, 0)
+	for _, p := range svc.Ports {
+		ports = append(ports, uint16(p))
+	}
+	for _, pr := range svc.PortRanges {
+		for p := pr.Start; p <= pr.End; p++ {
+			ports = append(ports, uint16(p))
 		}
+	}
 
-		// Pre-process backends info
-		type backendInfo struct {
-			address net.IP
-			port    uint16
-			weight  int
+	// Pre-process backends info
+	type backendInfo struct {
+		address net.IP
+		port    uint16
+		offset  int // Added to the service port when port is 0
+		weight  int
+		forward string
+		upper   uint32
+		lower   uint32
+	}
+	backends := make([]backendInfo, 0, len(svc.Backends))
+	for _, be := range svc.Backends {
+		// Drained backends are removed like deleted ones
+		if be.Drain {
+			continue
 		}
-		backends := make([]backendInfo, 0, len(svc.Backends))
-		for _, be := range svc.Backends {
-			backends = append(backends, backendInfo{
-				address: net.ParseIP(be.Address),
-				port:    uint16(be.Port),
-				weight:  be.Weight,
-			})
+		forward := r.forward
+		if be.Forward != "" {
+			forward = ForwardingForMode(be.Forward)
+		}
+		address := r.backendIP(be.Address, parsedVIP)
+		if address == nil {
+			continue
 		}
+		backends = append(backends, backendInfo{
+			address: address,
+			port:    uint16(be.Port),
+			offset:  svc.BackendPortOffset(be),
+			weight:  be.Weight,
+			forward: forward,
+			upper:   uint32(be.UpperThreshold),
+			lower:   uint32(be.LowerThreshold),
+		})
+	}
+
+	sched, flags := normalizeScheduler(svc.Scheduler, svc.SchedulerFlags)
+	mask, err := config.ParsePersistenceNetmask(svc.PersistenceNetmask)
+	if err != nil {
+		return nil, errdefs.PermanentConfig(fmt.Errorf("service %s: %w", svc.Name, err))
+	}
 
+	protocols := svc.Protocols()
+	states := make([]*DesiredState, 0, len(protocols)*len(ports))
+	for _, proto := range protocols {
 		for _, port := range ports {
 			ipvsSvc := &Service{
 				Address:   parsedVIP,
-				Protocol:  protoStr,
+				Protocol:  proto,
 				Port:      port,
-				Scheduler: svc.Scheduler,
+				Scheduler: sched,
+				Flags:     flags,
+				Timeout:   uint32(svc.PersistenceTimeout),
+				Netmask:   binary.BigEndian.Uint32(mask),
 			}
 
 			// Resolve destination ports
@@ -200,22 +602,58 @@ func (r *Reconciler) expandConfig(services []config.Service, vip string) (map[st
 			for i, be := range backends {
 				portToUse := be.port
 				if portToUse == 0 {
-					portToUse = port
+					portToUse = uint16(int(port) + be.offset)
 				}
 				resolvedDests[i] = &Destination{
 					Address: be.address,
 					Port:    portToUse,
 					Weight:  be.weight,
+					Forward: be.forward,
+
+					UpperThreshold: be.upper,
+					LowerThreshold: be.lower,
 				}
 			}
 
-			key := ipvsSvc.Key()
-			result[key] = &DesiredState{
+			states = append(states, &DesiredState{
 				Service:      ipvsSvc,
 				Destinations: resolvedDests,
-			}
+			})
 		}
 	}
 
-	return result, nil
+	return states, nil
+}
+
+// backendIP returns the address of a backend, resolving hostnames to their
+// first address in the family of vip. It returns nil for a hostname that
+// can't be resolved and never was.
+func (r *Reconciler) backendIP(addr string, vip net.IP) net.IP {
+	if ip := net.ParseIP(addr); ip != nil {
+		return ip
+	}
+	if r.resolver == nil {
+		r.logger.ErrorFields("Skipping backend: hostname backends need a resolver", observability.String("backend", addr))
+		return nil
+	}
+	ips, err := r.resolver.LookupIP(addr)
+	if err == nil {
+		for _, ip := range ips {
+			if (ip.To4() == nil) == (vip.To4() == nil) {
+				if r.resolved == nil {
+					r.resolved = make(map[string]net.IP)
+				}
+				r.resolved[addr] = ip
+				return ip
+			}
+		}
+		err = fmt.Errorf("no address in the family of vip %s", vip)
+	}
+	if ip, ok := r.resolved[addr]; ok {
+		r.logger.WarnFields("Failed to resolve backend, keeping its last address", observability.String("backend", addr),
+			observability.String("address", ip.String()), observability.Err(err))
+		return ip
+	}
+	r.logger.ErrorFields("Skipping backend", observability.String("backend", addr), observability.Err(err))
+	return nil
 }
@@ -0,0 +1,211 @@
+package ipvs
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/observability"
+)
+
+// SimManager is a Manager that never touches the kernel. It logs every write
+// it is asked to make and keeps the result in memory, so reconciles converge
+// as they would against IPVS. It lets the daemon run end to end (config,
+// health checks, metrics) on machines without IPVS netlink, such as non-Linux
+// development hosts and CI. It is safe for concurrent use.
+type SimManager struct {
+	logger *observability.Logger
+
+	mu           sync.Mutex
+	services     map[string]*Service
+	destinations map[string][]*Destination // By service key
+	daemons      []SyncDaemon
+	timeouts     Timeouts
+}
+
+// NewSimManager returns an empty SimManager with the kernel's default
+// timeouts.
+func NewSimManager(logger *observability.Logger) *SimManager {
+	return &SimManager{
+		logger:       logger,
+		services:     make(map[string]*Service),
+		destinations: make(map[string][]*Destination),
+		timeouts:     Timeouts{TCP: 900 * time.Second, TCPFin: 120 * time.Second, UDP: 300 * time.Second},
+	}
+}
+
+// OpenManager returns the Manager the daemon should program: a SimManager
+// when dryRun (the --dry-run flag) or daemon.dry_run is set, otherwise the
+// kernel manager. Either is wrapped in a CachedManager when
+// daemon.state_cache is enabled.
+func OpenManager(cfg config.DaemonConfig, dryRun bool, logger *observability.Logger) (Manager, error) {
+	var m Manager
+	if dryRun || cfg.DryRun {
+		logger.Warn("IPVS dry run: operations are logged, not applied")
+		m = NewSimManager(logger)
+	} else {
+		rm, err := NewManager()
+		if err != nil {
+			return nil, err
+		}
+		m = rm
+	}
+	if cache := CacheConfigFromDaemonConfig(cfg.StateCache); cache.Enabled {
+		m = NewCachedManager(m, cache)
+	}
+	return m, nil
+}
+
+func (m *SimManager) GetServices() ([]*Service, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]*Service, 0, len(m.services))
+	for _, svc := range m.services {
+		copied := *svc
+		result = append(result, &copied)
+	}
+	return result, nil
+}
+
+func (m *SimManager) GetDestinations(svc *Service) ([]*Destination, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.services[svc.Key()]; !ok {
+		return nil, fmt.Errorf("service not found: %s", svc.Key())
+	}
+	dests := m.destinations[svc.Key()]
+	result := make([]*Destination, len(dests))
+	for i, dst := range dests {
+		copied := *dst
+		result[i] = &copied
+	}
+	return result, nil
+}
+
+func (m *SimManager) CreateService(svc *Service) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := svc.Key()
+	if _, ok := m.services[key]; ok {
+		return fmt.Errorf("service already exists: %s", key)
+	}
+	m.logger.Infof("Dry run: create service %s", svc)
+	copied := *svc
+	m.services[key] = &copied
+	return nil
+}
+
+func (m *SimManager) UpdateService(svc *Service) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := svc.Key()
+	if _, ok := m.services[key]; !ok {
+		return fmt.Errorf("service not found: %s", key)
+	}
+	m.logger.Infof("Dry run: update service %s", svc)
+	copied := *svc
+	m.services[key] = &copied
+	return nil
+}
+
+func (m *SimManager) DeleteService(svc *Service) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := svc.Key()
+	if _, ok := m.services[key]; !ok {
+		return fmt.Errorf("service not found: %s", key)
+	}
+	m.logger.Infof("Dry run: delete service %s", svc)
+	delete(m.services, key)
+	delete(m.destinations, key)
+	return nil
+}
+
+func (m *SimManager) CreateDestination(svc *Service, dst *Destination) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := svc.Key()
+	if _, ok := m.services[key]; !ok {
+		return fmt.Errorf("service not found: %s", key)
+	}
+	if slices.ContainsFunc(m.destinations[key], func(d *Destination) bool { return d.Key() == dst.Key() }) {
+		return fmt.Errorf("destination already exists: %s -> %s", key, dst.Key())
+	}
+	m.logger.Infof("Dry run: create destination %s -> %s (weight %d)", key, dst.Key(), dst.Weight)
+	copied := *dst
+	m.destinations[key] = append(m.destinations[key], &copied)
+	return nil
+}
+
+func (m *SimManager) UpdateDestination(svc *Service, dst *Destination) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := svc.Key()
+	i := slices.IndexFunc(m.destinations[key], func(d *Destination) bool { return d.Key() == dst.Key() })
+	if i < 0 {
+		return fmt.Errorf("destination not found: %s -> %s", key, dst.Key())
+	}
+	m.logger.Infof("Dry run: update destination %s -> %s (weight %d)", key, dst.Key(), dst.Weight)
+	copied := *dst
+	m.destinations[key][i] = &copied
+	return nil
+}
+
+func (m *SimManager) DeleteDestination(svc *Service, dst *Destination) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := svc.Key()
+	i := slices.IndexFunc(m.destinations[key], func(d *Destination) bool { return d.Key() == dst.Key() })
+	if i < 0 {
+		return fmt.Errorf("destination not found: %s -> %s", key, dst.Key())
+	}
+	m.logger.Infof("Dry run: delete destination %s -> %s", key, dst.Key())
+	m.destinations[key] = slices.Delete(m.destinations[key], i, i+1)
+	return nil
+}
+
+func (m *SimManager) SyncDaemons() ([]SyncDaemon, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.daemons), nil
+}
+
+func (m *SimManager) StartSyncDaemon(d SyncDaemon) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logger.Infof("Dry run: start IPVS %s sync daemon on %s (sync id %d)", d.State, d.Interface, d.SyncID)
+	m.daemons = append(m.daemons, d)
+	return nil
+}
+
+func (m *SimManager) StopSyncDaemon(state SyncState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logger.Infof("Dry run: stop IPVS %s sync daemon", state)
+	m.daemons = slices.DeleteFunc(m.daemons, func(d SyncDaemon) bool { return d.State == state })
+	return nil
+}
+
+func (m *SimManager) Timeouts() (Timeouts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.timeouts, nil
+}
+
+func (m *SimManager) SetTimeouts(t Timeouts) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logger.Infof("Dry run: set IPVS timeouts: tcp %s tcpfin %s udp %s", t.TCP, t.TCPFin, t.UDP)
+	if t.TCP != 0 {
+		m.timeouts.TCP = t.TCP
+	}
+	if t.TCPFin != 0 {
+		m.timeouts.TCPFin = t.TCPFin
+	}
+	if t.UDP != 0 {
+		m.timeouts.UDP = t.UDP
+	}
+	return nil
+}
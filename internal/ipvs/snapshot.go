@@ -0,0 +1,107 @@
+package ipvs
+
+import (
+	"encoding/binary"
+	"net"
+	"sort"
+)
+
+// Snapshot is a read-only, serializable view of the IPVS table: every
+// service with its destinations, weights and counters, sorted by address,
+// protocol and port.
+type Snapshot struct {
+	Services []ServiceSnapshot `json:"services"`
+}
+
+// ServiceSnapshot is one IPVS service in a Snapshot.
+type ServiceSnapshot struct {
+	Protocol           string                `json:"protocol"`
+	Address            string                `json:"address"`
+	Port               uint16                `json:"port"`
+	Scheduler          string                `json:"scheduler"`
+	Flags              []string              `json:"flags,omitempty"`
+	PersistenceTimeout uint32                `json:"persistence_timeout,omitempty"` // Seconds
+	PersistenceNetmask string                `json:"persistence_netmask,omitempty"`
+	Stats              Stats                 `json:"stats"`
+	Destinations       []DestinationSnapshot `json:"destinations"`
+}
+
+// DestinationSnapshot is one destination of a ServiceSnapshot.
+type DestinationSnapshot struct {
+	Address        string `json:"address"`
+	Port           uint16 `json:"port"`
+	Weight         int    `json:"weight"`
+	Forward        string `json:"forward"`
+	UpperThreshold uint32 `json:"upper_threshold,omitempty"`
+	LowerThreshold uint32 `json:"lower_threshold,omitempty"`
+	ActiveConns    int    `json:"active_conns"`
+	InactiveConns  int    `json:"inactive_conns"`
+	Stats          Stats  `json:"stats"`
+}
+
+// TakeSnapshot reads the IPVS table from m. Like CollectStats, a service
+// whose destinations can't be read is included without them and the error
+// is returned alongside the snapshot; the snapshot is nil only if the
+// services can't be listed.
+func TakeSnapshot(m Manager) (*Snapshot, error) {
+	stats, err := CollectStats(m)
+	if stats == nil && err != nil {
+		return nil, err
+	}
+	snap := &Snapshot{Services: make([]ServiceSnapshot, 0, len(stats))}
+	for _, st := range stats {
+		svc := st.Service
+		ss := ServiceSnapshot{
+			Protocol:           svc.Protocol,
+			Address:            svc.Address.String(),
+			Port:               svc.Port,
+			Scheduler:          svc.Scheduler,
+			Flags:              svc.Flags,
+			PersistenceTimeout: svc.Timeout,
+			Stats:              svc.Stats,
+			Destinations:       make([]DestinationSnapshot, 0, len(st.Destinations)),
+		}
+		if svc.Netmask != 0 {
+			mask := make(net.IP, 4)
+			binary.BigEndian.PutUint32(mask, svc.Netmask)
+			ss.PersistenceNetmask = mask.String()
+		}
+		for _, d := range st.Destinations {
+			ss.Destinations = append(ss.Destinations, DestinationSnapshot{
+				Address:        d.Address.String(),
+				Port:           d.Port,
+				Weight:         d.Weight,
+				Forward:        d.Forward,
+				UpperThreshold: d.UpperThreshold,
+				LowerThreshold: d.LowerThreshold,
+				ActiveConns:    d.ActiveConns,
+				InactiveConns:  d.InactiveConns,
+				Stats:          d.Stats,
+			})
+		}
+		sort.Slice(ss.Destinations, func(i, j int) bool {
+			a, b := ss.Destinations[i], ss.Destinations[j]
+			if a.Address != b.Address {
+				return a.Address < b.Address
+			}
+			return a.Port < b.Port
+		})
+		snap.Services = append(snap.Services, ss)
+	}
+	sort.Slice(snap.Services, func(i, j int) bool {
+		a, b := snap.Services[i], snap.Services[j]
+		if a.Address != b.Address {
+			return a.Address < b.Address
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.Port < b.Port
+	})
+	return snap, err
+}
+
+// Snapshot returns a snapshot of the IPVS table. See TakeSnapshot.
+func (r *Reconciler) Snapshot() (*Snapshot, error) {
+	return TakeSnapshot(r.manager)
+}
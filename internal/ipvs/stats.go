@@ -0,0 +1,33 @@
+package ipvs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ServiceStats is a service with its destinations, as read back from the
+// kernel with their counters.
+type ServiceStats struct {
+	Service      *Service
+	Destinations []*Destination
+}
+
+// CollectStats reads every service and its destinations from m. A service
+// whose destinations can't be read is still returned, without them, and the
+// error is joined into the result.
+func CollectStats(m Manager) ([]ServiceStats, error) {
+	services, err := m.GetServices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+	stats := make([]ServiceStats, 0, len(services))
+	var errs []error
+	for _, svc := range services {
+		dests, err := m.GetDestinations(svc)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list destinations of %s: %w", svc.Key(), err))
+		}
+		stats = append(stats, ServiceStats{Service: svc, Destinations: dests})
+	}
+	return stats, errors.Join(errs...)
+}
@@ -0,0 +1,95 @@
+package ipvs
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+)
+
+// SyncState is the role of a kernel IPVS sync daemon. A master multicasts
+// changes to its connection table; a backup applies what it receives, so a
+// node taking over the VIP already knows the established connections.
+type SyncState uint32
+
+const (
+	SyncMaster SyncState = 1 // IP_VS_STATE_MASTER
+	SyncBackup SyncState = 2 // IP_VS_STATE_BACKUP
+)
+
+func (s SyncState) String() string {
+	switch s {
+	case SyncMaster:
+		return "master"
+	case SyncBackup:
+		return "backup"
+	}
+	return fmt.Sprintf("state(%d)", uint32(s))
+}
+
+// SyncDaemon is one running (or wanted) kernel sync daemon. The kernel runs
+// at most one per state.
+type SyncDaemon struct {
+	State     SyncState
+	Interface string // Multicast interface
+	SyncID    uint8
+}
+
+var errNoSyncDaemons = errors.New("IPVS manager cannot control sync daemons")
+
+// SyncDaemonManager is implemented by managers that can control the kernel's
+// sync daemons.
+type SyncDaemonManager interface {
+	SyncDaemons() ([]SyncDaemon, error)
+	StartSyncDaemon(d SyncDaemon) error
+	StopSyncDaemon(state SyncState) error
+}
+
+// SyncDaemonsForConfig returns the sync daemon to run for daemon.conn_sync:
+// master while this node owns the VIP, backup otherwise. It returns nil when
+// connection sync is disabled.
+func SyncDaemonsForConfig(cfg config.ConnSyncConfig, active bool) []SyncDaemon {
+	if !cfg.Enabled {
+		return nil
+	}
+	state := SyncBackup
+	if active {
+		state = SyncMaster
+	}
+	return []SyncDaemon{{State: state, Interface: cfg.Interface, SyncID: uint8(cfg.SyncID)}}
+}
+
+// SetSyncDaemons makes the kernel's sync daemons match want: daemons of a
+// state not in want are stopped, and daemons whose interface or sync ID
+// changed are restarted. It must not be called concurrently with Apply.
+func (r *Reconciler) SetSyncDaemons(want []SyncDaemon) error {
+	sm, ok := r.manager.(SyncDaemonManager)
+	if !ok {
+		return errNoSyncDaemons
+	}
+	running, err := sm.SyncDaemons()
+	if err != nil {
+		return err
+	}
+
+	for _, d := range running {
+		if slices.Contains(want, d) {
+			continue
+		}
+		r.logger.Infof("Stopping IPVS sync daemon: %s on %s (sync id %d)", d.State, d.Interface, d.SyncID)
+		if err := sm.StopSyncDaemon(d.State); err != nil {
+			return err
+		}
+	}
+	for _, d := range want {
+		if slices.Contains(running, d) {
+			continue
+		}
+		r.logger.Infof("Starting IPVS sync daemon: %s on %s (sync id %d)", d.State, d.Interface, d.SyncID)
+		if err := sm.StartSyncDaemon(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
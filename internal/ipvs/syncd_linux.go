@@ -0,0 +1,115 @@
+//go:build linux
+
+package ipvs
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// moby/ipvs doesn't expose the sync daemon commands, so they are sent on the
+// IPVS generic netlink family directly (include/uapi/linux/ip_vs.h).
+const (
+	ipvsGenlName    = "IPVS"
+	ipvsGenlVersion = 1
+
+	ipvsCmdNewDaemon = 9
+	ipvsCmdDelDaemon = 10
+	ipvsCmdGetDaemon = 11
+
+	ipvsCmdAttrDaemon = 3
+
+	ipvsDaemonAttrState    = 1
+	ipvsDaemonAttrMcastIfn = 2
+	ipvsDaemonAttrSyncID   = 3
+)
+
+func (m *RealManager) SyncDaemons() ([]SyncDaemon, error) {
+	msgs, err := syncDaemonRequest(ipvsCmdGetDaemon, unix.NLM_F_DUMP, nil)
+	if err != nil {
+		return nil, errdefs.Classify(fmt.Errorf("failed to list IPVS sync daemons: %w", err))
+	}
+	var daemons []SyncDaemon
+	for _, msg := range msgs {
+		if len(msg) < nl.SizeofGenlmsg {
+			continue
+		}
+		attrs, err := nl.ParseRouteAttr(msg[nl.SizeofGenlmsg:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse IPVS sync daemon: %w", err)
+		}
+		for _, a := range attrs {
+			if a.Attr.Type&nl.NLA_TYPE_MASK != ipvsCmdAttrDaemon {
+				continue
+			}
+			d, err := parseSyncDaemon(a.Value)
+			if err != nil {
+				return nil, err
+			}
+			daemons = append(daemons, d)
+		}
+	}
+	return daemons, nil
+}
+
+func (m *RealManager) StartSyncDaemon(d SyncDaemon) error {
+	attr := nl.NewRtAttr(ipvsCmdAttrDaemon|int(nl.NLA_F_NESTED), nil)
+	attr.AddRtAttr(ipvsDaemonAttrState, nl.Uint32Attr(uint32(d.State)))
+	attr.AddRtAttr(ipvsDaemonAttrMcastIfn, nl.ZeroTerminated(d.Interface))
+	attr.AddRtAttr(ipvsDaemonAttrSyncID, nl.Uint32Attr(uint32(d.SyncID)))
+	if _, err := syncDaemonRequest(ipvsCmdNewDaemon, unix.NLM_F_ACK, attr); err != nil {
+		return errdefs.Classify(fmt.Errorf("failed to start IPVS %s sync daemon on %s: %w", d.State, d.Interface, err))
+	}
+	return nil
+}
+
+func (m *RealManager) StopSyncDaemon(state SyncState) error {
+	attr := nl.NewRtAttr(ipvsCmdAttrDaemon|int(nl.NLA_F_NESTED), nil)
+	attr.AddRtAttr(ipvsDaemonAttrState, nl.Uint32Attr(uint32(state)))
+	if _, err := syncDaemonRequest(ipvsCmdDelDaemon, unix.NLM_F_ACK, attr); err != nil {
+		return errdefs.Classify(fmt.Errorf("failed to stop IPVS %s sync daemon: %w", state, err))
+	}
+	return nil
+}
+
+func syncDaemonRequest(cmd uint8, flags int, attr *nl.RtAttr) ([][]byte, error) {
+	family, err := netlink.GenlFamilyGet(ipvsGenlName)
+	if err != nil {
+		return nil, fmt.Errorf("IPVS netlink family unavailable: %w", err)
+	}
+	req := nl.NewNetlinkRequest(int(family.ID), flags)
+	req.AddData(&nl.Genlmsg{Command: cmd, Version: ipvsGenlVersion})
+	if attr != nil {
+		req.AddData(attr)
+	}
+	return req.Execute(unix.NETLINK_GENERIC, 0)
+}
+
+// parseSyncDaemon decodes the nested IPVS_CMD_ATTR_DAEMON attribute
+func parseSyncDaemon(b []byte) (SyncDaemon, error) {
+	attrs, err := nl.ParseRouteAttr(b)
+	if err != nil {
+		return SyncDaemon{}, fmt.Errorf("failed to parse IPVS sync daemon: %w", err)
+	}
+	var d SyncDaemon
+	for _, a := range attrs {
+		switch a.Attr.Type & nl.NLA_TYPE_MASK {
+		case ipvsDaemonAttrState:
+			if len(a.Value) >= 4 {
+				d.State = SyncState(nl.NativeEndian().Uint32(a.Value))
+			}
+		case ipvsDaemonAttrMcastIfn:
+			d.Interface = string(bytes.TrimRight(a.Value, "\x00"))
+		case ipvsDaemonAttrSyncID:
+			if len(a.Value) >= 4 {
+				d.SyncID = uint8(nl.NativeEndian().Uint32(a.Value))
+			}
+		}
+	}
+	return d, nil
+}
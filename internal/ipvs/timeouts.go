@@ -0,0 +1,75 @@
+package ipvs
+
+import (
+	"errors"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+)
+
+// Timeouts are the kernel's IPVS connection timeouts (ipvsadm --set). They
+// have second granularity; a zero field leaves that timeout unchanged.
+type Timeouts struct {
+	TCP    time.Duration
+	TCPFin time.Duration
+	UDP    time.Duration
+}
+
+var errNoTimeouts = errors.New("IPVS manager cannot control timeouts")
+
+// TimeoutManager is implemented by managers that can read and set the
+// kernel's IPVS timeouts.
+type TimeoutManager interface {
+	Timeouts() (Timeouts, error)
+	SetTimeouts(t Timeouts) error
+}
+
+// TimeoutsFromConfig converts daemon.ipvs_timeouts.
+func TimeoutsFromConfig(cfg config.IPVSTimeoutsConfig) Timeouts {
+	return Timeouts{
+		TCP:    time.Duration(cfg.TCP) * time.Second,
+		TCPFin: time.Duration(cfg.TCPFin) * time.Second,
+		UDP:    time.Duration(cfg.UDP) * time.Second,
+	}
+}
+
+// SetTimeouts sets the non-zero timeouts of want that differ from the
+// kernel's. It must not be called concurrently with Apply.
+func (r *Reconciler) SetTimeouts(want Timeouts) error {
+	if want == (Timeouts{}) {
+		return nil
+	}
+	tm, ok := r.manager.(TimeoutManager)
+	if !ok {
+		return errNoTimeouts
+	}
+	current, err := tm.Timeouts()
+	if err != nil {
+		return err
+	}
+
+	change := Timeouts{}
+	if want.TCP != 0 && want.TCP != current.TCP {
+		change.TCP = want.TCP
+	}
+	if want.TCPFin != 0 && want.TCPFin != current.TCPFin {
+		change.TCPFin = want.TCPFin
+	}
+	if want.UDP != 0 && want.UDP != current.UDP {
+		change.UDP = want.UDP
+	}
+	if change == (Timeouts{}) {
+		return nil
+	}
+	r.logger.Infof("Setting IPVS timeouts: tcp %s tcpfin %s udp %s (was %s %s %s)",
+		orCurrent(change.TCP, current.TCP), orCurrent(change.TCPFin, current.TCPFin), orCurrent(change.UDP, current.UDP),
+		current.TCP, current.TCPFin, current.UDP)
+	return tm.SetTimeouts(change)
+}
+
+func orCurrent(d, current time.Duration) time.Duration {
+	if d == 0 {
+		return current
+	}
+	return d
+}
@@ -3,6 +3,8 @@ package ipvs
 import (
 	"fmt"
 	"net"
+	"slices"
+	"sort"
 	"strings"
 	"syscall"
 )
@@ -16,6 +18,55 @@ type Service struct {
 	Protocol  string // tcp, udp
 	Port      uint16
 	Scheduler string // rr, wrr, lc, etc.
+
+	Flags []string // Scheduler flags such as sh-fallback, sorted
+
+	Timeout uint32 // Persistence timeout in seconds; 0 disables persistence
+	Netmask uint32 // Persistence netmask, e.g. 0xFFFFFF00 for /24
+
+	Stats Stats // Kernel counters; filled in by GetServices, ignored on writes
+}
+
+// Stats are the kernel's counters for a service or destination since it was
+// created, and the kernel's estimate of their current per-second rates.
+type Stats struct {
+	Connections uint64 `json:"connections"`
+	PacketsIn   uint64 `json:"packets_in"`
+	PacketsOut  uint64 `json:"packets_out"`
+	BytesIn     uint64 `json:"bytes_in"`
+	BytesOut    uint64 `json:"bytes_out"`
+
+	CPS    uint64 `json:"cps"` // Connections per second
+	PPSIn  uint64 `json:"pps_in"`
+	PPSOut uint64 `json:"pps_out"`
+	BPSIn  uint64 `json:"bps_in"` // Bytes per second
+	BPSOut uint64 `json:"bps_out"`
+}
+
+// Add returns the sum of s and o, field by field.
+func (s Stats) Add(o Stats) Stats {
+	return Stats{
+		Connections: s.Connections + o.Connections,
+		PacketsIn:   s.PacketsIn + o.PacketsIn,
+		PacketsOut:  s.PacketsOut + o.PacketsOut,
+		BytesIn:     s.BytesIn + o.BytesIn,
+		BytesOut:    s.BytesOut + o.BytesOut,
+		CPS:         s.CPS + o.CPS,
+		PPSIn:       s.PPSIn + o.PPSIn,
+		PPSOut:      s.PPSOut + o.PPSOut,
+		BPSIn:       s.BPSIn + o.BPSIn,
+		BPSOut:      s.BPSOut + o.BPSOut,
+	}
+}
+
+// sameSettings reports whether two services have identical scheduler and
+// persistence settings, i.e. whether moving from one to the other needs an
+// UpdateService.
+func (s *Service) sameSettings(o *Service) bool {
+	return s.Scheduler == o.Scheduler &&
+		slices.Equal(s.Flags, o.Flags) &&
+		s.Timeout == o.Timeout &&
+		s.Netmask == o.Netmask
 }
 
 // Destination represents an IPVS destination (backend)
@@ -23,6 +74,36 @@ type Destination struct {
 	Address net.IP
 	Port    uint16
 	Weight  int
+
+	Forward string // Forwarding method: ForwardDR, ForwardNAT or ForwardTUN
+
+	UpperThreshold uint32 // Connections at which the destination stops getting new ones; 0 disables
+	LowerThreshold uint32 // Connections at which it gets new ones again; 0 is 3/4 of UpperThreshold
+
+	// Filled in by GetDestinations, ignored on writes
+	ActiveConns   int
+	InactiveConns int
+	Stats         Stats
+}
+
+// Forwarding methods, named after the config modes that select them
+const (
+	ForwardDR  = "dr"
+	ForwardNAT = "nat"
+	ForwardTUN = "tun"
+)
+
+// ForwardingForMode returns the destination forwarding method for a config
+// mode. Anything other than nat or tun, including the empty default, is DR.
+func ForwardingForMode(mode string) string {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case ForwardNAT:
+		return ForwardNAT
+	case ForwardTUN:
+		return ForwardTUN
+	default:
+		return ForwardDR
+	}
 }
 
 // ServiceKey uniquely identifies a service
@@ -37,9 +118,23 @@ func (d Destination) Key() string {
 
 // String returns a string representation
 func (s Service) String() string {
+	if len(s.Flags) > 0 {
+		return fmt.Sprintf("%s %s:%d (%s %s)", s.Protocol, s.Address, s.Port, s.Scheduler, strings.Join(s.Flags, ","))
+	}
 	return fmt.Sprintf("%s %s:%d (%s)", s.Protocol, s.Address, s.Port, s.Scheduler)
 }
 
+// normalizeScheduler lowercases a configured scheduler and its flags and
+// sorts the flags, so that services read back from the kernel compare equal.
+func normalizeScheduler(sched string, flags []string) (string, []string) {
+	var out []string
+	for _, f := range flags {
+		out = append(out, strings.ToLower(strings.TrimSpace(f)))
+	}
+	sort.Strings(out)
+	return strings.ToLower(strings.TrimSpace(sched)), out
+}
+
 func ProtocolToUint16(proto string) uint16 {
 	switch strings.ToLower(strings.TrimSpace(proto)) {
 	case "udp":
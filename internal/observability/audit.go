@@ -6,16 +6,31 @@ type AuditEvent string
 const (
 	AuditConfigLoaded         AuditEvent = "config_loaded"
 	AuditConfigChanged        AuditEvent = "config_changed"
+	AuditConfigScheduled      AuditEvent = "config_change_scheduled"
+	AuditConfigCancelled      AuditEvent = "config_change_cancelled"
+	AuditConfigActivated      AuditEvent = "config_change_activated"
+	AuditConfigActivateFailed AuditEvent = "config_change_activation_failed"
 	AuditVIPAcquired          AuditEvent = "vip_acquired"
 	AuditVIPReleased          AuditEvent = "vip_released"
 	AuditServiceAdded         AuditEvent = "service_added"
 	AuditServiceRemoved       AuditEvent = "service_removed"
+	AuditServiceReloaded      AuditEvent = "service_reloaded"
 	AuditBackendAdded         AuditEvent = "backend_added"
 	AuditBackendRemoved       AuditEvent = "backend_removed"
 	AuditBackendWeightChanged AuditEvent = "backend_weight_changed"
 	AuditHealthStateChanged   AuditEvent = "health_state_changed"
+	AuditHealthFallback       AuditEvent = "health_fallback"
+	AuditHealthOverride       AuditEvent = "health_override"
 	AuditFRRConfigPatched     AuditEvent = "frr_config_patched"
 	AuditSysctlApplied        AuditEvent = "sysctl_applied"
+	AuditQuotaExceeded        AuditEvent = "quota_exceeded"
+	AuditQuotaCleared         AuditEvent = "quota_cleared"
+	AuditMaintenanceChanged   AuditEvent = "maintenance_changed"
+	AuditLogLevelChanged      AuditEvent = "log_level_changed"
+	AuditReconcileRequested   AuditEvent = "reconcile_requested"
+	AuditCircuitOpen          AuditEvent = "reconcile_circuit_open"
+	AuditCircuitClosed        AuditEvent = "reconcile_circuit_closed"
+	AuditShutdown             AuditEvent = "shutdown"
 
 	AuditLockAcquired  AuditEvent = "lock_acquired"
 	AuditLockReleased  AuditEvent = "lock_released"
@@ -28,12 +43,14 @@ const (
 type Auditor struct {
 	logger    *Logger
 	component string
+	dedup     *auditDedup
 }
 
 // NewAuditor creates a new auditor using the provided logger
 func NewAuditor(logger *Logger) *Auditor {
 	return &Auditor{
 		logger: logger,
+		dedup:  newAuditDedup(logger),
 	}
 }
 
@@ -42,21 +59,32 @@ func (a *Auditor) WithComponent(component string) *Auditor {
 	return &Auditor{
 		logger:    a.logger,
 		component: component,
+		dedup:     a.dedup,
 	}
 }
 
-// Emit records an audit event via the structured logger.
+// Emit records an audit event via the structured logger. Events matching a
+// dedup rule may be held back and reported later as a summary.
 func (a *Auditor) Emit(event AuditEvent, fields map[string]interface{}) {
+	ok, pending := a.dedup.admit(event, a.component, fields)
+	a.dedup.emit(pending)
+	if !ok {
+		return
+	}
+	writeAudit(a.logger, a.component, event, fields)
+}
+
+func writeAudit(logger *Logger, component string, event AuditEvent, fields map[string]interface{}) {
 	merged := make(map[string]interface{})
 	for k, v := range fields {
 		merged[k] = v
 	}
 
-	if a.component != "" {
-		merged["_component"] = a.component
+	if component != "" {
+		merged["_component"] = component
 	}
 	merged["_event_type"] = "audit"
 	merged["_audit_event"] = string(event)
 
-	a.logger.Info("AUDIT", merged)
+	logger.Info("AUDIT", merged)
 }
@@ -0,0 +1,175 @@
+package observability
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
+)
+
+// AuditDedupRule collapses bursts of one audit event type. Within each window
+// the first Burst events per key are emitted as-is; the rest are counted and
+// reported as a single summary event when the window closes.
+type AuditDedupRule struct {
+	Event     AuditEvent
+	Window    time.Duration
+	Burst     int      // Events passed through per window (minimum 1)
+	KeyFields []string // Fields identifying the source, e.g. service_name, backend
+}
+
+// auditDedup holds per-key windows. It is shared by auditors derived via WithComponent.
+type auditDedup struct {
+	logger  *Logger
+	mu      sync.Mutex
+	rules   map[AuditEvent]AuditDedupRule
+	buckets map[string]*dedupBucket
+	clock   clock.Clock
+}
+
+type dedupBucket struct {
+	rule       AuditDedupRule
+	component  string
+	start      time.Time
+	emitted    int
+	suppressed int
+	last       map[string]interface{} // Fields of the most recent suppressed event
+	timer      clock.Timer
+}
+
+// pendingSummary is a summary ready to be emitted outside the dedup lock
+type pendingSummary struct {
+	event     AuditEvent
+	component string
+	fields    map[string]interface{}
+}
+
+func newAuditDedup(logger *Logger) *auditDedup {
+	return &auditDedup{
+		logger:  logger,
+		rules:   make(map[AuditEvent]AuditDedupRule),
+		buckets: make(map[string]*dedupBucket),
+		clock:   clock.Real(),
+	}
+}
+
+// SetClock times dedup windows with c instead of the real clock. It is
+// shared by auditors derived via WithComponent.
+func (a *Auditor) SetClock(c clock.Clock) {
+	a.dedup.mu.Lock()
+	defer a.dedup.mu.Unlock()
+	a.dedup.clock = c
+}
+
+// SetDedupRules replaces the deduplication rules. Pending summaries for the
+// previous rules are emitted first. Passing no rules disables deduplication.
+func (a *Auditor) SetDedupRules(rules []AuditDedupRule) {
+	a.dedup.emit(a.dedup.flush(true))
+
+	a.dedup.mu.Lock()
+	defer a.dedup.mu.Unlock()
+	a.dedup.rules = make(map[AuditEvent]AuditDedupRule)
+	for _, r := range rules {
+		if r.Window <= 0 {
+			continue
+		}
+		if r.Burst < 1 {
+			r.Burst = 1
+		}
+		a.dedup.rules[r.Event] = r
+	}
+}
+
+// FlushDedup emits summaries for all open windows that suppressed events,
+// e.g. on shutdown.
+func (a *Auditor) FlushDedup() {
+	a.dedup.emit(a.dedup.flush(true))
+}
+
+func (d *auditDedup) emit(pending []pendingSummary) {
+	for _, p := range pending {
+		writeAudit(d.logger, p.component, p.event, p.fields)
+	}
+}
+
+// admit reports whether the event should be emitted now. Suppressed events
+// are recorded in their bucket. Expired windows are returned for emission.
+func (d *auditDedup) admit(event AuditEvent, component string, fields map[string]interface{}) (bool, []pendingSummary) {
+	if d == nil {
+		return true, nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	rule, ok := d.rules[event]
+	if !ok {
+		return true, nil
+	}
+
+	now := d.clock.Now()
+	pending := d.expireLocked(now, false)
+
+	key := dedupKey(event, component, rule.KeyFields, fields)
+	b, ok := d.buckets[key]
+	if !ok {
+		b = &dedupBucket{rule: rule, component: component, start: now}
+		d.buckets[key] = b
+	}
+
+	if b.emitted < rule.Burst {
+		b.emitted++
+		return true, pending
+	}
+
+	b.suppressed++
+	b.last = fields
+	if b.timer == nil {
+		// Summaries must go out even if the source goes quiet
+		b.timer = clock.AfterFunc(d.clock, b.start.Add(rule.Window).Sub(now), func() {
+			d.emit(d.flush(false))
+		})
+	}
+	return false, pending
+}
+
+func (d *auditDedup) flush(all bool) []pendingSummary {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.expireLocked(d.clock.Now(), all)
+}
+
+func (d *auditDedup) expireLocked(now time.Time, all bool) []pendingSummary {
+	var pending []pendingSummary
+	for key, b := range d.buckets {
+		if !all && now.Before(b.start.Add(b.rule.Window)) {
+			continue
+		}
+		if b.timer != nil {
+			b.timer.Stop()
+		}
+		delete(d.buckets, key)
+		if b.suppressed == 0 {
+			continue
+		}
+
+		fields := make(map[string]interface{}, len(b.last)+4)
+		for k, v := range b.last {
+			fields[k] = v
+		}
+		fields["_audit_summary"] = true
+		fields["suppressed_count"] = b.suppressed
+		fields["total_count"] = b.suppressed + b.emitted
+		fields["window_seconds"] = b.rule.Window.Seconds()
+		pending = append(pending, pendingSummary{event: b.rule.Event, component: b.component, fields: fields})
+	}
+	return pending
+}
+
+func dedupKey(event AuditEvent, component string, keyFields []string, fields map[string]interface{}) string {
+	parts := []string{string(event), component}
+	for _, k := range keyFields {
+		parts = append(parts, fmt.Sprintf("%s=%v", k, fields[k]))
+	}
+	return strings.Join(parts, "|")
+}
@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
 )
 
 func TestAuditEmit(t *testing.T) {
@@ -78,3 +81,83 @@ func TestAuditNilFields(t *testing.T) {
 		t.Fatalf("expected audit fields, got %q", output)
 	}
 }
+
+func TestAuditDedupCollapsesBursts(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(InfoLevel)
+	logger.SetConsoleOutput(&buf)
+
+	auditor := NewAuditor(logger).WithComponent("daemon")
+	auditor.SetDedupRules([]AuditDedupRule{{
+		Event:     AuditHealthStateChanged,
+		Window:    time.Hour,
+		Burst:     2,
+		KeyFields: []string{"backend"},
+	}})
+
+	states := []string{"unhealthy", "healthy", "unhealthy", "healthy", "unhealthy"}
+	for _, s := range states {
+		auditor.Emit(AuditHealthStateChanged, map[string]interface{}{"backend": "10.0.0.1", "new_state": s})
+	}
+	auditor.Emit(AuditHealthStateChanged, map[string]interface{}{"backend": "10.0.0.2", "new_state": "unhealthy"})
+	auditor.Emit(AuditVIPAcquired, map[string]interface{}{"vip": "192.168.94.250"})
+
+	if got := strings.Count(buf.String(), "_audit_event=health_state_changed"); got != 3 {
+		t.Fatalf("expected 3 health events before flush, got %d: %q", got, buf.String())
+	}
+	if !strings.Contains(buf.String(), "_audit_event=vip_acquired") {
+		t.Fatalf("expected events without a rule to pass through, got %q", buf.String())
+	}
+
+	buf.Reset()
+	auditor.FlushDedup()
+	output := buf.String()
+	for _, expected := range []string{
+		"_audit_summary=true",
+		"suppressed_count=3",
+		"total_count=5",
+		"backend=10.0.0.1",
+		"new_state=unhealthy",
+		"_component=daemon",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected summary to contain %q, got %q", expected, output)
+		}
+	}
+	if strings.Contains(output, "10.0.0.2") {
+		t.Fatalf("expected no summary for key without suppressed events, got %q", output)
+	}
+}
+
+func TestAuditDedupWindowExpiry(t *testing.T) {
+	var buf syncBuffer
+	logger := NewLogger(InfoLevel)
+	logger.SetConsoleOutput(&buf)
+
+	auditor := NewAuditor(logger)
+	clk := clock.NewFake(time.Unix(1000, 0))
+	auditor.SetClock(clk)
+	auditor.SetDedupRules([]AuditDedupRule{{Event: AuditHealthStateChanged, Window: time.Minute}})
+
+	auditor.Emit(AuditHealthStateChanged, map[string]interface{}{"new_state": "unhealthy"})
+	auditor.Emit(AuditHealthStateChanged, map[string]interface{}{"new_state": "healthy"})
+
+	// The summary goes out when the window closes on the auditor's clock,
+	// even if no further event arrives
+	clk.Set(clk.Now().Add(2 * time.Minute))
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(buf.String(), "suppressed_count=1") {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected summary for expired window, got %q", buf.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The next event starts a new window
+	auditor.Emit(AuditHealthStateChanged, map[string]interface{}{"new_state": "unhealthy"})
+	output := buf.String()
+	if got := strings.Count(output, "_audit_event=health_state_changed"); got != 3 {
+		t.Fatalf("expected first event, summary and new-window event, got %d: %q", got, output)
+	}
+	auditor.SetDedupRules(nil)
+}
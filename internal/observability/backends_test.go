@@ -2,13 +2,18 @@ package observability
 
 import (
 	"context"
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/malindarathnayake/LibraFlux/internal/routine"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -439,11 +444,11 @@ func TestPrometheusServer_GetURL(t *testing.T) {
 	registry := NewMetricsRegistry()
 
 	tests := []struct {
-		name     string
-		port     int
-		path     string
-		bind     string
-		wantURL  string
+		name    string
+		port    int
+		path    string
+		bind    string
+		wantURL string
 	}{
 		{
 			name:    "default metrics path",
@@ -546,7 +551,7 @@ func TestPrometheusServer_Lifecycle(t *testing.T) {
 
 	// Start in background
 	ctx, cancel := context.WithCancel(context.Background())
-	
+
 	done := make(chan error, 1)
 	go func() {
 		done <- server.Start(ctx)
@@ -581,3 +586,241 @@ func TestPrometheusServer_Lifecycle(t *testing.T) {
 		t.Errorf("Second Stop() returned error: %v", err)
 	}
 }
+
+// TestPrometheusServer_Ready tests the readiness endpoint gate
+func TestPrometheusServer_Ready(t *testing.T) {
+	logger := NewLogger(ErrorLevel)
+	registry := NewMetricsRegistry()
+
+	cfg := PrometheusConfig{
+		Port: 19093,
+		Path: "/metrics",
+	}
+	server, err := NewPrometheusServer(cfg, registry, logger)
+	if err != nil {
+		t.Fatalf("NewPrometheusServer() error: %v", err)
+	}
+
+	var mu sync.Mutex
+	ready := false
+	server.SetReadinessCheck(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return ready
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Start(ctx)
+	time.Sleep(200 * time.Millisecond)
+
+	url := fmt.Sprintf("http://localhost:%d/ready", cfg.Port)
+	status := func() int {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("GET %s error: %v", url, err)
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := status(); got != http.StatusServiceUnavailable {
+		t.Errorf("GET %s status = %d, want %d", url, got, http.StatusServiceUnavailable)
+	}
+
+	mu.Lock()
+	ready = true
+	mu.Unlock()
+
+	if got := status(); got != http.StatusOK {
+		t.Errorf("GET %s status = %d, want %d", url, got, http.StatusOK)
+	}
+}
+
+// TestPrometheusServer_DebugRoutines tests the tracked resource listing and listener release
+func TestPrometheusServer_DebugRoutines(t *testing.T) {
+	logger := NewLogger(ErrorLevel)
+	registry := NewMetricsRegistry()
+
+	cfg := PrometheusConfig{
+		Port: 19094,
+		Path: "/metrics",
+	}
+	server, err := NewPrometheusServer(cfg, registry, logger)
+	if err != nil {
+		t.Fatalf("NewPrometheusServer() error: %v", err)
+	}
+	tracker := routine.NewTracker()
+	server.SetTracker(tracker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Start(ctx)
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/debug/routines", cfg.Port))
+	if err != nil {
+		t.Fatalf("GET /debug/routines error: %v", err)
+	}
+	var entries []routine.Entry
+	err = json.NewDecoder(resp.Body).Decode(&entries)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name != "prometheus" || entries[0].Kind != routine.KindListener {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	cancel()
+	<-done
+	if err := tracker.WaitIdle(time.Second); err != nil {
+		t.Fatalf("listener not released: %v", err)
+	}
+}
+
+// TestIPFIXExporter_Messages decodes the exported messages
+func TestIPFIXExporter_Messages(t *testing.T) {
+	collector, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer collector.Close()
+
+	if _, err := NewIPFIXExporter(IPFIXConfig{}); err == nil {
+		t.Error("expected error without a collector")
+	}
+	x, err := NewIPFIXExporter(IPFIXConfig{Collector: collector.LocalAddr().String(), ObservationDomain: 7, CacheSize: 3})
+	if err != nil {
+		t.Fatalf("NewIPFIXExporter: %v", err)
+	}
+	defer x.Close()
+	x.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	start := time.UnixMilli(1700000000123)
+	v4 := IPFIXFlow{Protocol: 6, Source: net.ParseIP("203.0.113.7"), SrcPort: 40000, Dest: net.ParseIP("192.0.2.10"), DstPort: 80, Backend: net.ParseIP("10.0.0.1"), BackPort: 8080, Start: start}
+	v6 := IPFIXFlow{Protocol: 17, Source: net.ParseIP("2001:db8::9"), SrcPort: 5353, Dest: net.ParseIP("2001:db8::10"), DstPort: 53, Backend: net.ParseIP("2001:db8::1:1"), BackPort: 53, Start: start}
+	mixed := v4
+	mixed.Backend = net.ParseIP("2001:db8::1:1")
+
+	if dropped := x.Add(v4, mixed, v6, v4, v4); dropped != 2 {
+		t.Fatalf("expected the mixed-family flow and one over the cache size dropped, got %d", dropped)
+	}
+	sent, err := x.Flush()
+	if err != nil || sent != 3 {
+		t.Fatalf("Flush() = %d, %v; want 3", sent, err)
+	}
+
+	buf := make([]byte, 2048)
+	collector.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := collector.Read(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	msg := buf[:n]
+	if v := binary.BigEndian.Uint16(msg[0:2]); v != 10 {
+		t.Fatalf("version = %d, want 10", v)
+	}
+	if l := binary.BigEndian.Uint16(msg[2:4]); int(l) != n {
+		t.Fatalf("length = %d, datagram is %d bytes", l, n)
+	}
+	if ts, seq, dom := binary.BigEndian.Uint32(msg[4:8]), binary.BigEndian.Uint32(msg[8:12]), binary.BigEndian.Uint32(msg[12:16]); ts != 1700000000 || seq != 0 || dom != 7 {
+		t.Fatalf("header time/seq/domain = %d/%d/%d", ts, seq, dom)
+	}
+
+	// Walk the sets: templates, then two IPv4 records and one IPv6 record
+	sets := map[uint16][]byte{}
+	for off := 16; off < n; {
+		id, l := binary.BigEndian.Uint16(msg[off:]), int(binary.BigEndian.Uint16(msg[off+2:]))
+		sets[id] = msg[off+4 : off+l]
+		off += l
+	}
+	if len(sets[2]) != 2*(4+8*4) {
+		t.Errorf("unexpected template set length %d", len(sets[2]))
+	}
+	if len(sets[256]) != 2*27 || len(sets[257]) != 63 {
+		t.Fatalf("unexpected data set lengths: v4 %d, v6 %d", len(sets[256]), len(sets[257]))
+	}
+	rec := sets[256][:27]
+	if !net.IP(rec[0:4]).Equal(v4.Source) || !net.IP(rec[4:8]).Equal(v4.Dest) ||
+		binary.BigEndian.Uint16(rec[8:10]) != 40000 || binary.BigEndian.Uint16(rec[10:12]) != 80 || rec[12] != 6 ||
+		!net.IP(rec[13:17]).Equal(v4.Backend) || binary.BigEndian.Uint16(rec[17:19]) != 8080 ||
+		binary.BigEndian.Uint64(rec[19:27]) != 1700000000123 {
+		t.Errorf("unexpected IPv4 record % x", rec)
+	}
+	if rec := sets[257]; !net.IP(rec[0:16]).Equal(v6.Source) || !net.IP(rec[37:53]).Equal(v6.Backend) {
+		t.Errorf("unexpected IPv6 record % x", rec)
+	}
+
+	// The next message's sequence number counts the records sent so far
+	x.Add(v4)
+	if _, err := x.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	n, err = collector.Read(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if seq := binary.BigEndian.Uint32(buf[8:12]); seq != 3 {
+		t.Errorf("sequence = %d, want 3", seq)
+	}
+}
+
+// TestIPFIXExporter_SplitsLargeExports checks that no message exceeds the
+// size limit
+func TestIPFIXExporter_SplitsLargeExports(t *testing.T) {
+	x := &IPFIXExporter{cacheSize: 200, now: time.Now}
+	flow := IPFIXFlow{Protocol: 6, Source: net.ParseIP("203.0.113.7"), Dest: net.ParseIP("192.0.2.10"), Backend: net.ParseIP("10.0.0.1")}
+	for i := 0; i < 200; i++ {
+		x.Add(flow)
+	}
+	v4, total, messages := x.pending, 0, 0
+	for len(v4) > 0 {
+		var msg []byte
+		var records int
+		msg, records, v4, _ = x.encodeMessage(v4, nil)
+		if len(msg) > ipfixMaxMessageSize {
+			t.Fatalf("message of %d bytes exceeds the limit", len(msg))
+		}
+		total += records
+		messages++
+	}
+	if total != 200 || messages < 2 {
+		t.Errorf("expected 200 records over several messages, got %d in %d", total, messages)
+	}
+}
+
+// TestCheckPrometheusBind verifies free ports pass and ports held by another
+// process fail unless they serve the metrics path
+func TestCheckPrometheusBind(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	// Held by something that isn't lbctl
+	if err := CheckPrometheusBind(PrometheusConfig{Port: port, Bind: "127.0.0.1"}); err == nil {
+		t.Error("expected a port held by another process to fail")
+	}
+
+	// Held by a running metrics endpoint
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			http.NotFound(w, r)
+		}
+	})}
+	go srv.Serve(ln)
+	if err := CheckPrometheusBind(PrometheusConfig{Port: port, Bind: "127.0.0.1"}); err != nil {
+		t.Errorf("expected a running metrics endpoint to pass: %v", err)
+	}
+	srv.Close()
+
+	// Free again
+	if err := CheckPrometheusBind(PrometheusConfig{Port: port, Bind: "127.0.0.1"}); err != nil {
+		t.Errorf("expected a free port to pass: %v", err)
+	}
+}
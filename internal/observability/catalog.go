@@ -0,0 +1,54 @@
+package observability
+
+import (
+	"slices"
+	"sort"
+)
+
+// MetricDescription documents one metric registered with a MetricsRegistry.
+type MetricDescription struct {
+	Name   string   `json:"name"`
+	Type   string   `json:"type"` // counter, gauge, histogram or info
+	Help   string   `json:"help"`
+	Labels []string `json:"labels"` // Sorted, the registry's const labels included
+}
+
+// Catalog describes every metric registered so far, sorted by name, so
+// integrators can see exactly what the running version exports. The labels
+// of an info metric are those of its current series.
+func (m *MetricsRegistry) Catalog() []MetricDescription {
+	m.constMu.RLock()
+	var constLabels []string
+	for _, lp := range m.constLabels {
+		constLabels = append(constLabels, lp.GetName())
+	}
+	m.constMu.RUnlock()
+
+	m.mu.RLock()
+	all := make([]MetricDescription, 0, len(m.catalog)+len(m.infos))
+	for _, d := range m.catalog {
+		all = append(all, d)
+	}
+	infos := make([]*infoCollector, 0, len(m.infos))
+	for _, c := range m.infos {
+		infos = append(infos, c)
+	}
+	m.mu.RUnlock()
+
+	for _, c := range infos {
+		all = append(all, MetricDescription{Name: c.name, Type: "info", Help: c.help, Labels: c.labelNames()})
+	}
+	for i := range all {
+		labels := append(append([]string{}, all[i].Labels...), constLabels...)
+		sort.Strings(labels)
+		all[i].Labels = slices.Compact(labels)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// describe records a counter, gauge or histogram for Catalog. The caller
+// holds m.mu.
+func (m *MetricsRegistry) describe(name, kind, help string, labels []string) {
+	m.catalog[name] = MetricDescription{Name: name, Type: kind, Help: help, Labels: labels}
+}
@@ -0,0 +1,122 @@
+package observability
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+type fieldKind uint8
+
+const (
+	stringField fieldKind = iota
+	intField
+	boolField
+	floatField
+	durationField
+	errorField
+)
+
+// Field is a typed structured log field. Unlike map fields, typed fields are
+// formatted without boxing values into interfaces, so logging them through
+// DebugFields/InfoFields/... doesn't allocate on the console path.
+type Field struct {
+	Key  string
+	kind fieldKind
+	str  string
+	num  int64
+	err  error
+}
+
+func String(key, value string) Field {
+	return Field{Key: key, kind: stringField, str: value}
+}
+
+func Int(key string, value int) Field {
+	return Field{Key: key, kind: intField, num: int64(value)}
+}
+
+func Int64(key string, value int64) Field {
+	return Field{Key: key, kind: intField, num: value}
+}
+
+func Float64(key string, value float64) Field {
+	return Field{Key: key, kind: floatField, num: int64(math.Float64bits(value))}
+}
+
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, kind: durationField, num: int64(value)}
+}
+
+func Bool(key string, value bool) Field {
+	f := Field{Key: key, kind: boolField}
+	if value {
+		f.num = 1
+	}
+	return f
+}
+
+// Err returns an "error" field; a nil error logs as an empty string
+func Err(err error) Field { return Field{Key: "error", kind: errorField, err: err} }
+
+// Value returns the field's value as it would appear in a map field
+func (f Field) Value() interface{} {
+	switch f.kind {
+	case intField:
+		return f.num
+	case boolField:
+		return f.num != 0
+	case floatField:
+		return math.Float64frombits(uint64(f.num))
+	case durationField:
+		return time.Duration(f.num).String()
+	case errorField:
+		if f.err == nil {
+			return ""
+		}
+		return f.err.Error()
+	}
+	return f.str
+}
+
+// appendTo writes the field's value as the console format shows it, masking
+// secrets in string values.
+func (f Field) appendTo(buf *bytes.Buffer, r *redactor) {
+	switch f.kind {
+	case intField:
+		buf.Write(strconv.AppendInt(buf.AvailableBuffer(), f.num, 10))
+	case boolField:
+		buf.Write(strconv.AppendBool(buf.AvailableBuffer(), f.num != 0))
+	case floatField:
+		buf.Write(strconv.AppendFloat(buf.AvailableBuffer(), math.Float64frombits(uint64(f.num)), 'g', -1, 64))
+	case durationField:
+		buf.WriteString(time.Duration(f.num).String())
+	case errorField:
+		if f.err != nil {
+			buf.WriteString(r.redact(f.err.Error()))
+		}
+	default:
+		buf.WriteString(r.redact(f.str))
+	}
+}
+
+// appendValue writes a map field value in the console format. Common types
+// skip fmt to avoid its allocations.
+func appendValue(buf *bytes.Buffer, v interface{}) {
+	switch val := v.(type) {
+	case string:
+		buf.WriteString(val)
+	case int:
+		buf.Write(strconv.AppendInt(buf.AvailableBuffer(), int64(val), 10))
+	case int64:
+		buf.Write(strconv.AppendInt(buf.AvailableBuffer(), val, 10))
+	case bool:
+		buf.Write(strconv.AppendBool(buf.AvailableBuffer(), val))
+	case float64:
+		buf.Write(strconv.AppendFloat(buf.AvailableBuffer(), val, 'g', -1, 64))
+	default:
+		fmt.Fprintf(buf, "%v", val)
+	}
+}
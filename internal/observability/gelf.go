@@ -0,0 +1,310 @@
+package observability
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Graylog2/go-gelf/gelf"
+	"github.com/malindarathnayake/LibraFlux/internal/routine"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// GELFOptions tunes GELF delivery. The zero value matches the library defaults.
+type GELFOptions struct {
+	// UDP: "gzip" (default), "zlib" or "none". Messages larger than one
+	// datagram are chunked by the GELF library.
+	Compression      string
+	CompressionLevel int // 0 uses the library default
+
+	// TCP: messages are buffered and written together once BatchSize is
+	// reached or FlushInterval elapses. BatchSize <= 1 writes every message.
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// GELFStats counts GELF delivery outcomes per message
+type GELFStats struct {
+	Sent    uint64
+	Failed  uint64 // Write attempts that returned an error
+	Dropped uint64 // Messages that were never delivered
+}
+
+type gelfStats struct {
+	sent    atomic.Uint64
+	failed  atomic.Uint64
+	dropped atomic.Uint64
+	metrics atomic.Pointer[MetricsRegistry]
+}
+
+func (s *gelfStats) record(result string, n int) {
+	if n <= 0 {
+		return
+	}
+	switch result {
+	case "sent":
+		s.sent.Add(uint64(n))
+	case "failed":
+		s.failed.Add(uint64(n))
+	case "dropped":
+		s.dropped.Add(uint64(n))
+	}
+	if m := s.metrics.Load(); m != nil {
+		m.fixedCounter("lbctl_gelf_messages_total", prometheus.Labels{"result": result}).Add(float64(n))
+	}
+}
+
+func (s *gelfStats) snapshot() GELFStats {
+	return GELFStats{
+		Sent:    s.sent.Load(),
+		Failed:  s.failed.Load(),
+		Dropped: s.dropped.Load(),
+	}
+}
+
+// GELFStats returns delivery counters since the logger was created
+func (l *Logger) GELFStats() GELFStats {
+	return l.gelfStats.snapshot()
+}
+
+// SetMetrics exports GELF delivery counters as lbctl_gelf_messages_total{result}
+func (l *Logger) SetMetrics(m *MetricsRegistry) {
+	if m != nil {
+		m.NewCounter("lbctl_gelf_messages_total", "GELF messages by delivery result", []string{"result"})
+	}
+	l.gelfStats.metrics.Store(m)
+}
+
+func parseGELFCompression(name string) (gelf.CompressType, error) {
+	switch strings.ToLower(name) {
+	case "", "gzip":
+		return gelf.CompressGzip, nil
+	case "zlib":
+		return gelf.CompressZlib, nil
+	case "none":
+		return gelf.CompressNone, nil
+	default:
+		return gelf.CompressNone, fmt.Errorf("invalid gelf compression: %s", name)
+	}
+}
+
+// Timeouts for one batch; both bound how long a dead collector can delay the
+// flush loop, never a logging call.
+const (
+	gelfDialTimeout  = 5 * time.Second
+	gelfWriteTimeout = 5 * time.Second
+)
+
+// gelfMaxBatches caps how many batches may queue behind a slow collector
+// before new messages are dropped.
+const gelfMaxBatches = 8
+
+// gelfBatchWriter is a GELF TCP writer that coalesces messages into fewer writes.
+// Each message is terminated by a null byte as the GELF TCP framing requires.
+// WriteMessage only appends to the buffer, since the logger calls it under its
+// own lock; all network I/O happens on the flush loop.
+type gelfBatchWriter struct {
+	address   string
+	facility  string
+	batchSize int
+	stats     *gelfStats
+	dial      func(address string) (net.Conn, error)
+
+	mu      sync.Mutex // Guards buf, pending and closed
+	buf     []byte
+	pending int
+	closed  bool
+
+	conn     net.Conn // Owned by flushLoop
+	closeErr error    // Result of the final flush, set before done closes
+	kick     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newGELFBatchWriter(address, facility string, opts GELFOptions, stats *gelfStats, tracker *routine.Tracker) *gelfBatchWriter {
+	w := &gelfBatchWriter{
+		address:   address,
+		facility:  facility,
+		batchSize: opts.BatchSize,
+		stats:     stats,
+		dial: func(address string) (net.Conn, error) {
+			return net.DialTimeout("tcp", address, gelfDialTimeout)
+		},
+		kick: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	interval := opts.FlushInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	tracker.Go("gelf-flush", func() { w.flushLoop(interval) })
+	return w
+}
+
+func (w *gelfBatchWriter) flushLoop(interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			w.closeErr = w.flush()
+			if w.conn != nil {
+				w.conn.Close()
+				w.conn = nil
+			}
+			return
+		case <-w.kick:
+			_ = w.flush()
+		case <-ticker.C:
+			_ = w.flush()
+		}
+	}
+}
+
+// Write sends p as the short message of a new GELF message
+func (w *gelfBatchWriter) Write(p []byte) (int, error) {
+	msg := &gelf.Message{
+		Version:  "1.1",
+		Short:    strings.TrimSpace(string(p)),
+		TimeUnix: float64(time.Now().UnixNano()) / 1e9,
+		Level:    6,
+		Facility: w.facility,
+	}
+	if err := w.WriteMessage(msg); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *gelfBatchWriter) WriteMessage(m *gelf.Message) error {
+	data, err := marshalGELF(m)
+	if err != nil {
+		w.stats.record("dropped", 1)
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		w.stats.record("dropped", 1)
+		return fmt.Errorf("gelf writer closed")
+	}
+	if w.pending >= w.batchSize*gelfMaxBatches {
+		w.stats.record("dropped", 1)
+		return fmt.Errorf("gelf buffer full")
+	}
+
+	w.buf = append(w.buf, data...)
+	w.buf = append(w.buf, 0)
+	w.pending++
+	if w.pending >= w.batchSize {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// take swaps out the buffered messages so they can be written without
+// holding mu
+func (w *gelfBatchWriter) take() ([]byte, int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	data, n := w.buf, w.pending
+	w.buf, w.pending = nil, 0
+	return data, n
+}
+
+// flush writes the buffered batch. A failed batch is dropped rather than
+// retried so a dead collector cannot grow memory without bound.
+func (w *gelfBatchWriter) flush() error {
+	data, n := w.take()
+	if n == 0 {
+		return nil
+	}
+
+	if w.conn == nil {
+		conn, err := w.dial(w.address)
+		if err != nil {
+			w.stats.record("failed", n)
+			w.stats.record("dropped", n)
+			return fmt.Errorf("gelf dial failed: %w", err)
+		}
+		w.conn = conn
+	}
+
+	err := w.conn.SetWriteDeadline(time.Now().Add(gelfWriteTimeout))
+	if err == nil {
+		_, err = w.conn.Write(data)
+	}
+	if err != nil {
+		w.conn.Close()
+		w.conn = nil
+		w.stats.record("failed", n)
+		w.stats.record("dropped", n)
+		return fmt.Errorf("gelf write failed: %w", err)
+	}
+	w.stats.record("sent", n)
+	return nil
+}
+
+// Close flushes what is buffered and waits for the flush loop to exit
+func (w *gelfBatchWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	close(w.stop)
+	<-w.done
+	return w.closeErr
+}
+
+// marshalGELF encodes a message in GELF 1.1 JSON with extra fields inlined
+func marshalGELF(m *gelf.Message) ([]byte, error) {
+	out := make(map[string]interface{}, len(m.Extra)+7)
+	for k, v := range m.Extra {
+		out[k] = v
+	}
+	out["version"] = m.Version
+	out["host"] = m.Host
+	out["short_message"] = m.Short
+	if m.Full != "" {
+		out["full_message"] = m.Full
+	}
+	out["timestamp"] = m.TimeUnix
+	out["level"] = m.Level
+	if m.Facility != "" {
+		out["facility"] = m.Facility
+	}
+	return json.Marshal(out)
+}
+
+// countingGELFWriter records delivery stats for library writers that send
+// each message immediately (UDP, unbatched TCP).
+type countingGELFWriter struct {
+	gelf.Writer
+	stats *gelfStats
+}
+
+func (w countingGELFWriter) WriteMessage(m *gelf.Message) error {
+	if err := w.Writer.WriteMessage(m); err != nil {
+		w.stats.record("failed", 1)
+		w.stats.record("dropped", 1)
+		return err
+	}
+	w.stats.record("sent", 1)
+	return nil
+}
@@ -0,0 +1,225 @@
+package observability
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Graylog2/go-gelf/gelf"
+	"github.com/malindarathnayake/LibraFlux/internal/routine"
+)
+
+// TestGELFBatchWriter verifies batched TCP delivery and stats
+func TestGELFBatchWriter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+
+	received := make(chan map[string]interface{}, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			frame, err := r.ReadBytes(0)
+			if err != nil {
+				return
+			}
+			var m map[string]interface{}
+			if err := json.Unmarshal(frame[:len(frame)-1], &m); err == nil {
+				received <- m
+			}
+		}
+	}()
+
+	var stats gelfStats
+	metrics := NewMetricsRegistry()
+	stats.metrics.Store(metrics)
+	metrics.NewCounter("lbctl_gelf_messages_total", "GELF messages by delivery result", []string{"result"})
+
+	tracker := routine.NewTracker()
+	w := newGELFBatchWriter(ln.Addr().String(), "lbctl", GELFOptions{BatchSize: 3, FlushInterval: time.Hour}, &stats, tracker)
+	if got := tracker.Count("gelf-flush"); got != 1 {
+		t.Fatalf("expected flush loop tracked, got %d", got)
+	}
+	for _, msg := range []string{"one", "two"} {
+		if err := w.WriteMessage(&gelf.Message{Version: "1.1", Short: msg, Extra: map[string]interface{}{"_node": "lb1"}}); err != nil {
+			t.Fatalf("WriteMessage: %v", err)
+		}
+	}
+	if got := stats.snapshot().Sent; got != 0 {
+		t.Fatalf("expected partial batch to be buffered, sent=%d", got)
+	}
+	if err := w.WriteMessage(&gelf.Message{Version: "1.1", Short: "three"}); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+
+	for _, want := range []string{"one", "two", "three"} {
+		select {
+		case m := <-received:
+			if m["short_message"] != want {
+				t.Fatalf("expected %q, got %v", want, m["short_message"])
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+	waitGELFStats(t, &stats, GELFStats{Sent: 3})
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := tracker.WaitIdle(time.Second); err != nil {
+		t.Fatalf("flush loop still running after Close: %v", err)
+	}
+	if err := w.WriteMessage(&gelf.Message{Short: "late"}); err == nil {
+		t.Fatalf("expected write after close to fail")
+	}
+	if got := stats.snapshot().Dropped; got != 1 {
+		t.Fatalf("expected 1 dropped message, got %d", got)
+	}
+}
+
+// TestGELFBatchWriterDropsOnDialFailure verifies a dead collector drops the batch
+func TestGELFBatchWriterDropsOnDialFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	var stats gelfStats
+	w := newGELFBatchWriter(addr, "lbctl", GELFOptions{BatchSize: 2, FlushInterval: time.Hour}, &stats, nil)
+	defer w.Close()
+
+	for _, msg := range []string{"a", "b"} {
+		if err := w.WriteMessage(&gelf.Message{Short: msg}); err != nil {
+			t.Fatalf("WriteMessage: %v", err)
+		}
+	}
+	waitGELFStats(t, &stats, GELFStats{Failed: 2, Dropped: 2})
+}
+
+// TestGELFBatchWriterDoesNotBlockOnCollector verifies that a collector which
+// never answers stalls only the flush loop, and that the backlog is bounded
+func TestGELFBatchWriterDoesNotBlockOnCollector(t *testing.T) {
+	var stats gelfStats
+	w := newGELFBatchWriter("192.0.2.1:12201", "lbctl", GELFOptions{BatchSize: 2, FlushInterval: time.Hour}, &stats, nil)
+	dialing := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	w.dial = func(string) (net.Conn, error) {
+		once.Do(func() { close(dialing) })
+		<-release
+		return nil, errors.New("collector unreachable")
+	}
+
+	for _, msg := range []string{"a", "b"} {
+		_ = w.WriteMessage(&gelf.Message{Short: msg})
+	}
+	<-dialing
+	// The flush loop is stuck dialing; writes keep returning until the
+	// backlog is full
+	start := time.Now()
+	var full error
+	for i := 0; i < 2*gelfMaxBatches+1 && full == nil; i++ {
+		full = w.WriteMessage(&gelf.Message{Short: "queued"})
+	}
+	if full == nil {
+		t.Fatal("expected writes beyond the backlog to be dropped")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("writes blocked for %s", elapsed)
+	}
+
+	close(release)
+	w.Close()
+	// 2 failed in flight, the queued backlog failed next, 1 rejected
+	waitGELFStats(t, &stats, GELFStats{Failed: 2 + 2*gelfMaxBatches, Dropped: 3 + 2*gelfMaxBatches})
+}
+
+func waitGELFStats(t *testing.T, stats *gelfStats, want GELFStats) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for stats.snapshot() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected stats %+v, got %+v", want, stats.snapshot())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestGELFCompressionOption verifies compression names are validated
+func TestGELFCompressionOption(t *testing.T) {
+	logger := NewLogger(InfoLevel)
+	defer logger.Close()
+
+	if err := logger.InitGELFWithOptions("127.0.0.1", 12201, "udp", "lbctl", GELFOptions{Compression: "brotli"}); err == nil {
+		t.Fatalf("expected invalid compression to fail")
+	}
+	for _, name := range []string{"", "gzip", "zlib", "none"} {
+		if _, err := parseGELFCompression(name); err != nil {
+			t.Fatalf("compression %q: %v", name, err)
+		}
+	}
+}
+
+// TestCheckGELF verifies the self-test message over TCP and that a closed UDP
+// port is reported
+func TestCheckGELF(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+	got := make(chan map[string]interface{}, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, err := bufio.NewReader(conn).ReadBytes(0)
+		if err != nil {
+			return
+		}
+		var m map[string]interface{}
+		if json.Unmarshal(line[:len(line)-1], &m) == nil {
+			got <- m
+		}
+	}()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	if err := CheckGELF("127.0.0.1", port, "tcp", "lbctl", time.Second); err != nil {
+		t.Fatalf("CheckGELF tcp: %v", err)
+	}
+	select {
+	case m := <-got:
+		if m["short_message"] != "lbctl observability self-test" || m["_facility"] != "lbctl" {
+			t.Errorf("unexpected self-test message: %v", m)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("self-test message not received")
+	}
+
+	// Nothing listens on this UDP port any more
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket: %v", err)
+	}
+	closed := pc.LocalAddr().(*net.UDPAddr).Port
+	pc.Close()
+	if err := CheckGELF("127.0.0.1", closed, "udp", "lbctl", time.Second); err == nil {
+		t.Error("expected a closed UDP port to fail the check")
+	}
+}
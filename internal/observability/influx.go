@@ -8,6 +8,7 @@ import (
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
 	"github.com/influxdata/influxdb-client-go/v2/api"
 	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/malindarathnayake/LibraFlux/internal/routine"
 	dto "github.com/prometheus/client_model/go"
 )
 
@@ -22,6 +23,8 @@ type InfluxPusher struct {
 	logger   *Logger
 	stopCh   chan struct{}
 	doneCh   chan struct{}
+
+	supervisor *routine.Supervisor
 }
 
 // InfluxConfig holds InfluxDB connection parameters
@@ -67,11 +70,20 @@ func NewInfluxPusher(cfg InfluxConfig, registry *MetricsRegistry, logger *Logger
 	}, nil
 }
 
+// SetSupervisor restarts the push loop after a panic. It must be called before Start.
+func (p *InfluxPusher) SetSupervisor(sup *routine.Supervisor) {
+	p.supervisor = sup
+}
+
 // Start begins the periodic push loop
 func (p *InfluxPusher) Start(ctx context.Context) {
+	defer close(p.doneCh)
+	p.supervisor.Run("influx_pusher", p.stopCh, func() { p.loop(ctx) })
+}
+
+func (p *InfluxPusher) loop(ctx context.Context) {
 	ticker := time.NewTicker(p.interval)
 	defer ticker.Stop()
-	defer close(p.doneCh)
 
 	p.logger.Info("InfluxDB pusher started", map[string]interface{}{
 		"interval": p.interval.String(),
@@ -106,7 +118,7 @@ func (p *InfluxPusher) Stop() {
 	default:
 		close(p.stopCh)
 	}
-	
+
 	// Only wait for doneCh if Start() is running
 	// This prevents deadlock when Stop() is called without Start()
 	select {
@@ -115,14 +127,14 @@ func (p *InfluxPusher) Stop() {
 	default:
 		// Start() was never called or hasn't started yet
 	}
-	
+
 	p.client.Close()
 }
 
 // push collects metrics from registry and pushes to InfluxDB
 func (p *InfluxPusher) push(ctx context.Context) error {
 	// Gather metrics from Prometheus registry
-	metricFamilies, err := p.registry.Registry.Gather()
+	metricFamilies, err := p.registry.Gatherer().Gather()
 	if err != nil {
 		return fmt.Errorf("failed to gather metrics: %w", err)
 	}
@@ -177,7 +189,7 @@ func (p *InfluxPusher) convertToPoints(metricFamilies []*dto.MetricFamily) []*wr
 					value = m.Untyped.GetValue()
 					hasValue = true
 				}
-			// HISTOGRAM and SUMMARY are more complex - skip for now
+				// HISTOGRAM and SUMMARY are more complex - skip for now
 			}
 
 			if hasValue {
@@ -208,7 +220,11 @@ func (p *InfluxPusher) TestConnection(ctx context.Context) error {
 	}
 
 	if health.Status != "pass" {
-		return fmt.Errorf("influxdb health status: %s (message: %s)", health.Status, *health.Message)
+		msg := ""
+		if health.Message != nil {
+			msg = *health.Message
+		}
+		return fmt.Errorf("influxdb health status: %s (message: %s)", health.Status, msg)
 	}
 
 	return nil
@@ -216,5 +232,5 @@ func (p *InfluxPusher) TestConnection(ctx context.Context) error {
 
 // GatherMetrics is a helper to manually gather current metrics (useful for testing)
 func (p *InfluxPusher) GatherMetrics() ([]*dto.MetricFamily, error) {
-	return p.registry.Registry.Gather()
+	return p.registry.Gatherer().Gather()
 }
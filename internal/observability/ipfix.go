@@ -0,0 +1,222 @@
+package observability
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// DefaultIPFIXCacheSize is the default number of flows buffered between
+// exports.
+const DefaultIPFIXCacheSize = 1024
+
+// IPFIX (RFC 7011) constants. Messages are kept below a typical path MTU
+// since they are sent as single UDP datagrams.
+const (
+	ipfixVersion        = 10
+	ipfixTemplateSetID  = 2
+	ipfixTemplateIPv4   = 256
+	ipfixTemplateIPv6   = 257
+	ipfixMaxMessageSize = 1400
+	ipfixHeaderSize     = 16
+	ipfixSetHeaderSize  = 4
+)
+
+// Information elements exported for each flow, in record order. The address
+// elements depend on the template's address family. Each record marks a flow
+// start (flowStartMilliseconds); there are no flow-end records and no
+// octetDeltaCount or packetDeltaCount, since the IPVS connection table the
+// flows come from doesn't count bytes or packets per connection.
+var (
+	ipfixFieldsIPv4 = []ipfixField{{8, 4}, {12, 4}, {7, 2}, {11, 2}, {4, 1}, {226, 4}, {228, 2}, {152, 8}}
+	ipfixFieldsIPv6 = []ipfixField{{27, 16}, {28, 16}, {7, 2}, {11, 2}, {4, 1}, {282, 16}, {228, 2}, {152, 8}}
+)
+
+type ipfixField struct {
+	id     uint16
+	length uint16
+}
+
+func ipfixRecordSize(fields []ipfixField) int {
+	n := 0
+	for _, f := range fields {
+		n += int(f.length)
+	}
+	return n
+}
+
+// IPFIXFlow is one load-balanced flow: the client's connection to the VIP
+// and the backend IPVS forwarded it to (exported as the post-NAT
+// destination).
+type IPFIXFlow struct {
+	Protocol uint8 // IANA protocol number, e.g. 6 for TCP
+	Source   net.IP
+	SrcPort  uint16
+	Dest     net.IP // VIP
+	DstPort  uint16
+	Backend  net.IP
+	BackPort uint16
+	Start    time.Time // When the flow was first seen
+}
+
+// IPFIXConfig holds IPFIX exporter parameters
+type IPFIXConfig struct {
+	Collector         string // host:port, UDP
+	ObservationDomain uint32
+	CacheSize         int // Flows buffered between exports; 0 uses DefaultIPFIXCacheSize
+}
+
+// IPFIXExporter buffers flows and sends them to a collector as IPFIX over
+// UDP. Templates are included in every message, so a collector can decode
+// any message on its own. It is not safe for concurrent use.
+type IPFIXExporter struct {
+	conn      net.Conn
+	domain    uint32
+	cacheSize int
+	pending   []IPFIXFlow
+	seq       uint32 // Data records sent so far, as required by the header
+	now       func() time.Time
+}
+
+// NewIPFIXExporter creates an exporter sending to cfg.Collector
+func NewIPFIXExporter(cfg IPFIXConfig) (*IPFIXExporter, error) {
+	if cfg.Collector == "" {
+		return nil, fmt.Errorf("ipfix collector is required")
+	}
+	conn, err := net.Dial("udp", cfg.Collector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ipfix collector: %w", err)
+	}
+	size := cfg.CacheSize
+	if size <= 0 {
+		size = DefaultIPFIXCacheSize
+	}
+	return &IPFIXExporter{conn: conn, domain: cfg.ObservationDomain, cacheSize: size, now: time.Now}, nil
+}
+
+// Add buffers flows for the next Flush and returns how many were dropped
+// because the cache is full or the flow mixes address families.
+func (x *IPFIXExporter) Add(flows ...IPFIXFlow) (dropped int) {
+	for _, f := range flows {
+		if len(x.pending) >= x.cacheSize || (f.Source.To4() == nil) != (f.Backend.To4() == nil) {
+			dropped++
+			continue
+		}
+		x.pending = append(x.pending, f)
+	}
+	return dropped
+}
+
+// Flush sends the buffered flows and returns how many were sent. Flows are
+// discarded even if sending fails, as a retry would report stale data.
+func (x *IPFIXExporter) Flush() (int, error) {
+	flows := x.pending
+	x.pending = nil
+	if len(flows) == 0 {
+		return 0, nil
+	}
+
+	var v4, v6 []IPFIXFlow
+	for _, f := range flows {
+		if f.Source.To4() != nil {
+			v4 = append(v4, f)
+		} else {
+			v6 = append(v6, f)
+		}
+	}
+
+	sent := 0
+	for len(v4) > 0 || len(v6) > 0 {
+		var msg []byte
+		var records int
+		msg, records, v4, v6 = x.encodeMessage(v4, v6)
+		if _, err := x.conn.Write(msg); err != nil {
+			return sent, fmt.Errorf("failed to send ipfix message: %w", err)
+		}
+		x.seq += uint32(records)
+		sent += records
+	}
+	return sent, nil
+}
+
+// Close closes the connection to the collector
+func (x *IPFIXExporter) Close() error {
+	return x.conn.Close()
+}
+
+// encodeMessage builds one message holding both templates and as many
+// records as fit, and returns the number of records and the flows that did
+// not fit.
+func (x *IPFIXExporter) encodeMessage(v4, v6 []IPFIXFlow) ([]byte, int, []IPFIXFlow, []IPFIXFlow) {
+	buf := make([]byte, ipfixHeaderSize, ipfixMaxMessageSize)
+	buf = appendTemplateSet(buf)
+
+	records := 0
+	v4, buf, records = appendDataSet(buf, ipfixTemplateIPv4, ipfixFieldsIPv4, v4, records)
+	v6, buf, records = appendDataSet(buf, ipfixTemplateIPv6, ipfixFieldsIPv6, v6, records)
+
+	binary.BigEndian.PutUint16(buf[0:2], ipfixVersion)
+	binary.BigEndian.PutUint16(buf[2:4], uint16(len(buf)))
+	binary.BigEndian.PutUint32(buf[4:8], uint32(x.now().Unix()))
+	binary.BigEndian.PutUint32(buf[8:12], x.seq) // Records sent before this message
+	binary.BigEndian.PutUint32(buf[12:16], x.domain)
+	return buf, records, v4, v6
+}
+
+func appendTemplateSet(buf []byte) []byte {
+	start := len(buf)
+	buf = binary.BigEndian.AppendUint16(buf, ipfixTemplateSetID)
+	buf = binary.BigEndian.AppendUint16(buf, 0) // Length, set below
+	for _, t := range []struct {
+		id     uint16
+		fields []ipfixField
+	}{{ipfixTemplateIPv4, ipfixFieldsIPv4}, {ipfixTemplateIPv6, ipfixFieldsIPv6}} {
+		buf = binary.BigEndian.AppendUint16(buf, t.id)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(t.fields)))
+		for _, f := range t.fields {
+			buf = binary.BigEndian.AppendUint16(buf, f.id)
+			buf = binary.BigEndian.AppendUint16(buf, f.length)
+		}
+	}
+	binary.BigEndian.PutUint16(buf[start+2:], uint16(len(buf)-start))
+	return buf
+}
+
+// appendDataSet appends a data set with as many of flows as fit in the
+// message and returns the remaining flows.
+func appendDataSet(buf []byte, templateID uint16, fields []ipfixField, flows []IPFIXFlow, records int) ([]IPFIXFlow, []byte, int) {
+	size := ipfixRecordSize(fields)
+	fit := (ipfixMaxMessageSize - len(buf) - ipfixSetHeaderSize) / size
+	if fit <= 0 || len(flows) == 0 {
+		return flows, buf, records
+	}
+	if fit > len(flows) {
+		fit = len(flows)
+	}
+
+	buf = binary.BigEndian.AppendUint16(buf, templateID)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(ipfixSetHeaderSize+fit*size))
+	for _, f := range flows[:fit] {
+		buf = appendFlow(buf, f, templateID == ipfixTemplateIPv6)
+	}
+	return flows[fit:], buf, records + fit
+}
+
+func appendFlow(buf []byte, f IPFIXFlow, v6 bool) []byte {
+	addr := func(ip net.IP) []byte {
+		if v6 {
+			return ip.To16()
+		}
+		return ip.To4()
+	}
+	buf = append(buf, addr(f.Source)...)
+	buf = append(buf, addr(f.Dest)...)
+	buf = binary.BigEndian.AppendUint16(buf, f.SrcPort)
+	buf = binary.BigEndian.AppendUint16(buf, f.DstPort)
+	buf = append(buf, f.Protocol)
+	buf = append(buf, addr(f.Backend)...)
+	buf = binary.BigEndian.AppendUint16(buf, f.BackPort)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(f.Start.UnixMilli()))
+	return buf
+}
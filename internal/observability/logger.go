@@ -1,15 +1,18 @@
 package observability
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
-	"sort"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Graylog2/go-gelf/gelf"
+	"github.com/malindarathnayake/LibraFlux/internal/routine"
 )
 
 // LogLevel represents the severity of a log message
@@ -57,34 +60,62 @@ func ParseLogLevel(level string) (LogLevel, error) {
 // Logger provides dual-output logging (console + GELF)
 type Logger struct {
 	mu          sync.Mutex
-	level       LogLevel
+	level       atomic.Int32 // LogLevel; read without mu so filtered calls stay cheap
 	consoleOut  io.Writer
 	gelfWriter  gelf.Writer
 	gelfEnabled bool
 	facility    string
 	hostname    string
-	nodeConfig  map[string]interface{} // Additional fields from config (node name, etc.)
+	nodeConfig  map[string]interface{}   // Additional fields from config (node name, etc.)
+	redactor    atomic.Pointer[redactor] // Masks secrets before any sink; replaced, never mutated
+	gelfStats   gelfStats
+	tracker     *routine.Tracker // Records the GELF flush loop
 }
 
 // NewLogger creates a new logger with console output only
 func NewLogger(level LogLevel) *Logger {
 	hostname, _ := os.Hostname()
-	
-	return &Logger{
-		level:       level,
+
+	l := &Logger{
 		consoleOut:  os.Stdout,
 		gelfEnabled: false,
 		facility:    "lbctl",
 		hostname:    hostname,
 		nodeConfig:  make(map[string]interface{}),
 	}
+	l.level.Store(int32(level))
+	return l
 }
 
 // SetLevel changes the minimum log level
 func (l *Logger) SetLevel(level LogLevel) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.level = level
+	l.level.Store(int32(level))
+}
+
+// Level returns the minimum log level
+func (l *Logger) Level() LogLevel {
+	return LogLevel(l.level.Load())
+}
+
+// enabled reports whether messages at level are logged
+func (l *Logger) enabled(level LogLevel) bool {
+	return level >= LogLevel(l.level.Load())
+}
+
+// bufPool holds console line buffers so formatting doesn't allocate per call
+var bufPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	// Don't let one huge line pin memory in the pool
+	if buf.Cap() <= 64<<10 {
+		bufPool.Put(buf)
+	}
 }
 
 // SetConsoleOutput sets the console output writer (useful for testing)
@@ -98,86 +129,140 @@ func (l *Logger) SetConsoleOutput(w io.Writer) {
 func (l *Logger) SetNodeConfig(nodeName string, additionalFields map[string]interface{}) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	
+
 	l.nodeConfig = make(map[string]interface{})
 	l.nodeConfig["_node"] = nodeName
-	
+
 	for k, v := range additionalFields {
 		l.nodeConfig[k] = v
 	}
 }
 
+// AddSecrets registers values (tokens, passwords) that must never appear in log output.
+// Common credential patterns are masked regardless.
+func (l *Logger) AddSecrets(values ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r := l.redactor.Load(); r != nil {
+		values = append(slices.Clone(r.secrets), values...)
+	}
+	l.redactor.Store(newRedactor(values...))
+}
+
+// SetSecrets replaces the registered secrets, e.g. on config reload, so a
+// rotated token stops being masked once it is no longer configured.
+func (l *Logger) SetSecrets(values ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.redactor.Store(newRedactor(values...))
+}
+
+// SetTracker records goroutines started by later InitGELF calls, so shutdown
+// can check that Close stopped them.
+func (l *Logger) SetTracker(t *routine.Tracker) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tracker = t
+}
+
 // InitGELF initializes GELF output to the specified host
 // protocol can be "udp" or "tcp"
 func (l *Logger) InitGELF(host string, port int, protocol, facility string) error {
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	return l.InitGELFWithOptions(host, port, protocol, facility, GELFOptions{})
+}
 
+// InitGELFWithOptions initializes GELF output with compression (UDP) or
+// batching (TCP) settings
+func (l *Logger) InitGELFWithOptions(host string, port int, protocol, facility string, opts GELFOptions) error {
 	address := fmt.Sprintf("%s:%d", host, port)
-	
+
 	var gw gelf.Writer
-	
-	if protocol == "tcp" {
+
+	if protocol == "tcp" && opts.BatchSize > 1 {
+		l.mu.Lock()
+		tracker := l.tracker
+		l.mu.Unlock()
+		gw = newGELFBatchWriter(address, facility, opts, &l.gelfStats, tracker)
+	} else if protocol == "tcp" {
 		tcpWriter, err := gelf.NewTCPWriter(address)
 		if err != nil {
 			return fmt.Errorf("failed to create GELF TCP writer: %w", err)
 		}
 		tcpWriter.Facility = facility
-		gw = tcpWriter
+		gw = countingGELFWriter{Writer: tcpWriter, stats: &l.gelfStats}
 	} else {
+		compression, err := parseGELFCompression(opts.Compression)
+		if err != nil {
+			return err
+		}
 		udpWriter, err := gelf.NewUDPWriter(address)
 		if err != nil {
 			return fmt.Errorf("failed to create GELF UDP writer: %w", err)
 		}
 		udpWriter.Facility = facility
-		gw = udpWriter
+		udpWriter.CompressionType = compression
+		if opts.CompressionLevel != 0 {
+			udpWriter.CompressionLevel = opts.CompressionLevel
+		}
+		gw = countingGELFWriter{Writer: udpWriter, stats: &l.gelfStats}
 	}
-	
+
+	l.mu.Lock()
+	old := l.gelfWriter
 	l.gelfWriter = gw
 	l.gelfEnabled = true
 	l.facility = facility
-	
+	l.mu.Unlock()
+
+	// Closing flushes over the network, so it runs without the logger lock
+	if old != nil {
+		old.Close()
+	}
 	return nil
 }
 
 // DisableGELF disables GELF output
 func (l *Logger) DisableGELF() {
 	l.mu.Lock()
-	defer l.mu.Unlock()
-	
-	if l.gelfWriter != nil {
-		l.gelfWriter.Close()
-		l.gelfWriter = nil
-	}
-	
+	gw := l.gelfWriter
+	l.gelfWriter = nil
 	l.gelfEnabled = false
+	l.mu.Unlock()
+
+	if gw != nil {
+		gw.Close()
+	}
 }
 
-// Close closes any open GELF connections
+// Close closes any open GELF connections. The final flush runs without the
+// logger lock, so concurrent log calls aren't blocked behind the network.
 func (l *Logger) Close() error {
 	l.mu.Lock()
-	defer l.mu.Unlock()
-	
-	if l.gelfWriter != nil {
-		return l.gelfWriter.Close()
+	gw := l.gelfWriter
+	l.mu.Unlock()
+
+	if gw != nil {
+		return gw.Close()
 	}
-	
 	return nil
 }
 
 // log is the internal logging method
 func (l *Logger) log(level LogLevel, msg string, fields map[string]interface{}) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	
-	// Check if we should log at this level
-	if level < l.level {
+	if !l.enabled(level) {
 		return
 	}
-	
+	// Redact before fan-out so every sink sees the same masked output
+	r := l.redactor.Load()
+	msg = r.redact(msg)
+	fields = r.redactFields(fields)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	// Console output
 	l.logConsole(level, msg, fields)
-	
+
 	// GELF output (if enabled)
 	if l.gelfEnabled && l.gelfWriter != nil {
 		l.logGELF(level, msg, fields)
@@ -186,34 +271,73 @@ func (l *Logger) log(level LogLevel, msg string, fields map[string]interface{})
 
 // logConsole writes to console in format: [LEVEL] message key=value key=value
 func (l *Logger) logConsole(level LogLevel, msg string, fields map[string]interface{}) {
-	var sb strings.Builder
-	
-	sb.WriteString("[")
-	sb.WriteString(level.String())
-	sb.WriteString("] ")
-	sb.WriteString(msg)
-	
+	if l.consoleOut == nil {
+		return
+	}
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	writeLinePrefix(buf, level, msg)
+
 	// Sort keys for consistent output
 	if len(fields) > 0 {
-		keys := make([]string, 0, len(fields))
+		var arr [16]string
+		keys := arr[:0]
 		for k := range fields {
 			keys = append(keys, k)
 		}
-		sort.Strings(keys)
-		
+		slices.Sort(keys)
+
 		for _, k := range keys {
-			sb.WriteString(" ")
-			sb.WriteString(k)
-			sb.WriteString("=")
-			sb.WriteString(fmt.Sprintf("%v", fields[k]))
+			buf.WriteByte(' ')
+			buf.WriteString(k)
+			buf.WriteByte('=')
+			appendValue(buf, fields[k])
 		}
 	}
-	
-	sb.WriteString("\n")
-	
-	// Write to console output
+
+	buf.WriteByte('\n')
+	l.consoleOut.Write(buf.Bytes())
+}
+
+func writeLinePrefix(buf *bytes.Buffer, level LogLevel, msg string) {
+	buf.WriteByte('[')
+	buf.WriteString(level.String())
+	buf.WriteString("] ")
+	buf.WriteString(msg)
+}
+
+// logFields is log for typed fields. Fields are written in the order given.
+func (l *Logger) logFields(level LogLevel, msg string, fields []Field) {
+	if !l.enabled(level) {
+		return
+	}
+	r := l.redactor.Load()
+	msg = r.redact(msg)
+
+	// Format outside the lock; only the writes are serialized
+	buf := getBuffer()
+	defer putBuffer(buf)
+	writeLinePrefix(buf, level, msg)
+	for _, f := range fields {
+		buf.WriteByte(' ')
+		buf.WriteString(f.Key)
+		buf.WriteByte('=')
+		f.appendTo(buf, r)
+	}
+	buf.WriteByte('\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	if l.consoleOut != nil {
-		l.consoleOut.Write([]byte(sb.String()))
+		l.consoleOut.Write(buf.Bytes())
+	}
+	if l.gelfEnabled && l.gelfWriter != nil {
+		m := make(map[string]interface{}, len(fields))
+		for _, f := range fields {
+			m[f.Key] = f.Value()
+		}
+		l.logGELF(level, msg, r.redactFields(m))
 	}
 }
 
@@ -222,7 +346,7 @@ func (l *Logger) logGELF(level LogLevel, msg string, fields map[string]interface
 	if l.gelfWriter == nil {
 		return
 	}
-	
+
 	// Map log level to GELF level
 	var gelfLevel int32
 	switch level {
@@ -237,7 +361,7 @@ func (l *Logger) logGELF(level LogLevel, msg string, fields map[string]interface
 	default:
 		gelfLevel = 6
 	}
-	
+
 	// Create GELF message
 	gelfMsg := &gelf.Message{
 		Version:  "1.1",
@@ -248,12 +372,12 @@ func (l *Logger) logGELF(level LogLevel, msg string, fields map[string]interface
 		Facility: l.facility,
 		Extra:    make(map[string]interface{}),
 	}
-	
+
 	// Add node config fields
 	for k, v := range l.nodeConfig {
 		gelfMsg.Extra[k] = v
 	}
-	
+
 	// Add custom fields
 	for k, v := range fields {
 		// GELF requires custom fields to start with underscore
@@ -262,25 +386,34 @@ func (l *Logger) logGELF(level LogLevel, msg string, fields map[string]interface
 		}
 		gelfMsg.Extra[k] = v
 	}
-	
+
 	// Send to GELF (ignore errors to not block logging)
 	l.gelfWriter.WriteMessage(gelfMsg)
 }
 
 // Debug logs a debug message with optional structured fields
 func (l *Logger) Debug(msg string, fields ...map[string]interface{}) {
+	if !l.enabled(DebugLevel) {
+		return
+	}
 	mergedFields := mergeFields(fields...)
 	l.log(DebugLevel, msg, mergedFields)
 }
 
 // Info logs an info message with optional structured fields
 func (l *Logger) Info(msg string, fields ...map[string]interface{}) {
+	if !l.enabled(InfoLevel) {
+		return
+	}
 	mergedFields := mergeFields(fields...)
 	l.log(InfoLevel, msg, mergedFields)
 }
 
 // Warn logs a warning message with optional structured fields
 func (l *Logger) Warn(msg string, fields ...map[string]interface{}) {
+	if !l.enabled(WarnLevel) {
+		return
+	}
 	mergedFields := mergeFields(fields...)
 	l.log(WarnLevel, msg, mergedFields)
 }
@@ -291,18 +424,48 @@ func (l *Logger) Error(msg string, fields ...map[string]interface{}) {
 	l.log(ErrorLevel, msg, mergedFields)
 }
 
+// DebugFields logs a debug message with typed fields, without allocating
+// for the console output
+func (l *Logger) DebugFields(msg string, fields ...Field) {
+	l.logFields(DebugLevel, msg, fields)
+}
+
+// InfoFields logs an info message with typed fields
+func (l *Logger) InfoFields(msg string, fields ...Field) {
+	l.logFields(InfoLevel, msg, fields)
+}
+
+// WarnFields logs a warning message with typed fields
+func (l *Logger) WarnFields(msg string, fields ...Field) {
+	l.logFields(WarnLevel, msg, fields)
+}
+
+// ErrorFields logs an error message with typed fields
+func (l *Logger) ErrorFields(msg string, fields ...Field) {
+	l.logFields(ErrorLevel, msg, fields)
+}
+
 // Debugf logs a formatted debug message
 func (l *Logger) Debugf(format string, args ...interface{}) {
+	if !l.enabled(DebugLevel) {
+		return
+	}
 	l.log(DebugLevel, fmt.Sprintf(format, args...), nil)
 }
 
 // Infof logs a formatted info message
 func (l *Logger) Infof(format string, args ...interface{}) {
+	if !l.enabled(InfoLevel) {
+		return
+	}
 	l.log(InfoLevel, fmt.Sprintf(format, args...), nil)
 }
 
 // Warnf logs a formatted warning message
 func (l *Logger) Warnf(format string, args ...interface{}) {
+	if !l.enabled(WarnLevel) {
+		return
+	}
 	l.log(WarnLevel, fmt.Sprintf(format, args...), nil)
 }
 
@@ -322,13 +485,13 @@ func (l *Logger) With(fields map[string]interface{}) *LoggerContext {
 // mergeFields combines multiple field maps into one
 func mergeFields(fieldMaps ...map[string]interface{}) map[string]interface{} {
 	result := make(map[string]interface{})
-	
+
 	for _, fm := range fieldMaps {
 		for k, v := range fm {
 			result[k] = v
 		}
 	}
-	
+
 	return result
 }
 
@@ -340,6 +503,9 @@ type LoggerContext struct {
 
 // Debug logs a debug message with context fields
 func (lc *LoggerContext) Debug(msg string, fields ...map[string]interface{}) {
+	if !lc.logger.enabled(DebugLevel) {
+		return
+	}
 	mergedFields := mergeFields(lc.fields)
 	for _, f := range fields {
 		for k, v := range f {
@@ -351,6 +517,9 @@ func (lc *LoggerContext) Debug(msg string, fields ...map[string]interface{}) {
 
 // Info logs an info message with context fields
 func (lc *LoggerContext) Info(msg string, fields ...map[string]interface{}) {
+	if !lc.logger.enabled(InfoLevel) {
+		return
+	}
 	mergedFields := mergeFields(lc.fields)
 	for _, f := range fields {
 		for k, v := range f {
@@ -362,6 +531,9 @@ func (lc *LoggerContext) Info(msg string, fields ...map[string]interface{}) {
 
 // Warn logs a warning message with context fields
 func (lc *LoggerContext) Warn(msg string, fields ...map[string]interface{}) {
+	if !lc.logger.enabled(WarnLevel) {
+		return
+	}
 	mergedFields := mergeFields(lc.fields)
 	for _, f := range fields {
 		for k, v := range f {
@@ -1,18 +1,62 @@
 package observability
 
 import (
+	"sort"
 	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
 )
 
-// MetricsRegistry manages Prometheus metrics
+// DefaultSeriesBudget is the default cap on label combinations per metric.
+// Per-backend metrics grow with services x backends, so a single large
+// service (or a CIDR pool) can otherwise create an unbounded number of series.
+const DefaultSeriesBudget = 10000
+
+// OtherLabelValue replaces the label values of counter and histogram updates
+// beyond a metric's series budget, so they aggregate into a single overflow
+// series.
+const OtherLabelValue = "other"
+
+const overflowHelp = "Metric updates aggregated into the \"other\" series, or dropped for gauges, because the metric reached its series budget"
+
+// seriesIdleGathers is how many gathers a series may go without an update
+// before a metric at its series budget may delete it to make room.
+const seriesIdleGathers = 10
+
+// identityLabels say which node a series came from. Overflow keeps them, so
+// each node's overflow series stays distinguishable.
+var identityLabels = map[string]bool{"node": true, "cluster": true}
+
+// MetricsRegistry manages Prometheus metrics. Each counter, gauge and
+// histogram may hold at most the series budget's worth of label combinations.
+// Counter and histogram updates that would create more are aggregated into one
+// series per node with the other labels set to OtherLabelValue; gauge updates
+// are dropped, since samples from unrelated series can't be summed. Either way
+// they are counted in lbctl_metrics_series_overflow_total and logged once per
+// metric. Before overflowing, a metric at its budget deletes the series that
+// haven't been updated for seriesIdleGathers gathers, such as those of removed
+// backends. Series created before the budget is lowered are kept.
 type MetricsRegistry struct {
-	Registry *prometheus.Registry
-	counters map[string]*prometheus.CounterVec
-	gauges   map[string]*prometheus.GaugeVec
-	mu       sync.RWMutex
+	Registry   *prometheus.Registry
+	counters   map[string]*prometheus.CounterVec
+	gauges     map[string]*prometheus.GaugeVec
+	histograms map[string]*prometheus.HistogramVec
+	infos      map[string]*infoCollector
+	catalog    map[string]MetricDescription // Counters, gauges and histograms by name
+	mu         sync.RWMutex
+
+	seriesMu sync.Mutex
+	budget   int
+	series   map[string]map[uint64]*seriesEntry // Metric name -> series by seriesKey
+	warned   map[string]bool                    // Metrics whose overflow has been logged
+	gathers  uint64                             // Gathers through Gatherer so far
+	swept    map[string]uint64                  // Metric name -> gather of its last idle sweep
+	overflow *prometheus.CounterVec
+	logger   *Logger
+
+	constMu     sync.RWMutex
+	constLabels []*dto.LabelPair // Added to every gathered series, sorted by name
 }
 
 // NewMetricsRegistry creates a new metrics registry with a custom Prometheus registry
@@ -20,11 +64,237 @@ func NewMetricsRegistry() *MetricsRegistry {
 	// Include Go runtime metrics and process metrics by default?
 	// For a custom registry, they are not included by default.
 	// We'll keep it clean for now.
-	return &MetricsRegistry{
-		Registry: prometheus.NewRegistry(),
-		counters: make(map[string]*prometheus.CounterVec),
-		gauges:   make(map[string]*prometheus.GaugeVec),
+	m := &MetricsRegistry{
+		Registry:   prometheus.NewRegistry(),
+		counters:   make(map[string]*prometheus.CounterVec),
+		gauges:     make(map[string]*prometheus.GaugeVec),
+		histograms: make(map[string]*prometheus.HistogramVec),
+		infos:      make(map[string]*infoCollector),
+		catalog:    make(map[string]MetricDescription),
+		budget:     DefaultSeriesBudget,
+		series:     make(map[string]map[uint64]*seriesEntry),
+		warned:     make(map[string]bool),
+		swept:      make(map[string]uint64),
+		overflow: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lbctl_metrics_series_overflow_total",
+			Help: overflowHelp,
+		}, []string{"metric"}),
 	}
+	m.Registry.MustRegister(m.overflow)
+	m.describe("lbctl_metrics_series_overflow_total", "counter", overflowHelp, []string{"metric"})
+	return m
+}
+
+// SetSeriesBudget sets the per-metric series cap; n <= 0 restores
+// DefaultSeriesBudget.
+func (m *MetricsRegistry) SetSeriesBudget(n int) {
+	if n <= 0 {
+		n = DefaultSeriesBudget
+	}
+	m.seriesMu.Lock()
+	m.budget = n
+	m.warned = make(map[string]bool)
+	m.seriesMu.Unlock()
+}
+
+// SetLogger sets where series budget warnings are logged
+func (m *MetricsRegistry) SetLogger(l *Logger) {
+	m.seriesMu.Lock()
+	m.logger = l
+	m.seriesMu.Unlock()
+}
+
+// SeriesCount returns the number of label combinations recorded for a metric
+func (m *MetricsRegistry) SeriesCount(name string) int {
+	m.seriesMu.Lock()
+	defer m.seriesMu.Unlock()
+	return len(m.series[name])
+}
+
+// seriesEntry is a label set counted against a metric's series budget
+type seriesEntry struct {
+	labels  prometheus.Labels
+	touched uint64 // Value of gathers at the last update
+}
+
+// admit returns the labels an update to name should be recorded under:
+// labels itself while within the series budget, the overflow series after.
+// ok is false when the update should be dropped, i.e. an overflowing gauge.
+// vec is the metric's vector, from which idle series are evicted.
+func (m *MetricsRegistry) admit(name string, vec *prometheus.MetricVec, labels prometheus.Labels, aggregate bool) (admitted prometheus.Labels, ok bool) {
+	key := seriesKey(labels)
+
+	m.seriesMu.Lock()
+	seen := m.series[name]
+	if s, ok := seen[key]; ok {
+		s.touched = m.gathers
+		m.seriesMu.Unlock()
+		return labels, true
+	}
+	if len(seen) >= m.budget {
+		m.evictIdle(name, vec, seen)
+	}
+	if len(seen) < m.budget {
+		if seen == nil {
+			seen = make(map[uint64]*seriesEntry)
+			m.series[name] = seen
+		}
+		own := make(prometheus.Labels, len(labels))
+		for k, v := range labels {
+			own[k] = v
+		}
+		seen[key] = &seriesEntry{labels: own, touched: m.gathers}
+		m.seriesMu.Unlock()
+		return labels, true
+	}
+
+	m.overflow.With(prometheus.Labels{"metric": name}).Inc()
+	logger, budget := m.logger, m.budget
+	warn := !m.warned[name] && logger != nil
+	if warn {
+		m.warned[name] = true
+	}
+	m.seriesMu.Unlock()
+
+	if warn {
+		action := "aggregating new label sets into \"" + OtherLabelValue + "\""
+		if !aggregate {
+			action = "dropping updates to new label sets"
+		}
+		logger.Warn("Metric reached its series budget; "+action, map[string]interface{}{
+			"metric": name,
+			"budget": budget,
+		})
+	}
+	if !aggregate {
+		return nil, false
+	}
+	other := make(prometheus.Labels, len(labels))
+	for k, v := range labels {
+		if identityLabels[k] {
+			other[k] = v
+		} else {
+			other[k] = OtherLabelValue
+		}
+	}
+	return other, true
+}
+
+// evictIdle deletes name's series that haven't been updated for
+// seriesIdleGathers gathers. It sweeps at most once per gather, so a metric
+// that stays at its budget doesn't rescan it on every update. Called with
+// seriesMu held.
+func (m *MetricsRegistry) evictIdle(name string, vec *prometheus.MetricVec, seen map[uint64]*seriesEntry) {
+	if m.gathers < seriesIdleGathers || m.swept[name] == m.gathers {
+		return
+	}
+	m.swept[name] = m.gathers
+	for key, s := range seen {
+		if s.touched+seriesIdleGathers <= m.gathers {
+			vec.Delete(s.labels)
+			delete(seen, key)
+		}
+	}
+}
+
+// seriesKey identifies a label set in the series budget: the sum of the
+// FNV-1a hashes of its name/value pairs. Summing makes it independent of map
+// order, so it needs neither sorting nor allocation; a collision only
+// miscounts the budget by a series.
+func seriesKey(labels prometheus.Labels) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	var key uint64
+	for k, v := range labels {
+		h := uint64(offset64)
+		for i := 0; i < len(k); i++ {
+			h = (h ^ uint64(k[i])) * prime64
+		}
+		h = (h ^ 0xff) * prime64
+		for i := 0; i < len(v); i++ {
+			h = (h ^ uint64(v[i])) * prime64
+		}
+		key += h
+	}
+	return key
+}
+
+// forget removes a deleted series from name's budget.
+func (m *MetricsRegistry) forget(name string, labels prometheus.Labels) {
+	m.seriesMu.Lock()
+	defer m.seriesMu.Unlock()
+	if seen := m.series[name]; seen != nil {
+		delete(seen, seriesKey(labels))
+		if len(seen) == 0 {
+			delete(m.series, name)
+		}
+	}
+}
+
+// SetConstLabels sets labels added to every series at gather time, such as
+// the cluster/peer pair shared by both nodes of an HA pair. Unlike labels
+// fixed at registration they follow config reloads. A series that already
+// has a label of the same name keeps its own value.
+func (m *MetricsRegistry) SetConstLabels(labels map[string]string) {
+	pairs := make([]*dto.LabelPair, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, &dto.LabelPair{Name: &name, Value: &value})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].GetName() < pairs[j].GetName() })
+
+	m.constMu.Lock()
+	m.constLabels = pairs
+	m.constMu.Unlock()
+}
+
+// Gatherer returns the registry's metrics with the const labels applied.
+// Exporters should gather through it rather than from Registry directly;
+// its gathers are what series idle out against.
+func (m *MetricsRegistry) Gatherer() prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		m.seriesMu.Lock()
+		m.gathers++
+		m.seriesMu.Unlock()
+
+		families, err := m.Registry.Gather()
+
+		m.constMu.RLock()
+		extra := m.constLabels
+		m.constMu.RUnlock()
+		if len(extra) == 0 {
+			return families, err
+		}
+		for _, mf := range families {
+			for _, metric := range mf.GetMetric() {
+				metric.Label = withLabels(metric.Label, extra)
+			}
+		}
+		return families, err
+	})
+}
+
+// withLabels merges extra into labels, keeping both sorted by name and
+// labels' own value on a name clash.
+func withLabels(labels, extra []*dto.LabelPair) []*dto.LabelPair {
+	merged := make([]*dto.LabelPair, 0, len(labels)+len(extra))
+	i, j := 0, 0
+	for i < len(labels) || j < len(extra) {
+		switch {
+		case j == len(extra) || (i < len(labels) && labels[i].GetName() < extra[j].GetName()):
+			merged = append(merged, labels[i])
+			i++
+		case i == len(labels) || extra[j].GetName() < labels[i].GetName():
+			merged = append(merged, extra[j])
+			j++
+		default:
+			merged = append(merged, labels[i])
+			i++
+			j++
+		}
+	}
+	return merged
 }
 
 // NewCounter creates or retrieves a counter metric
@@ -43,6 +313,7 @@ func (m *MetricsRegistry) NewCounter(name, help string, labels []string) *promet
 
 	m.Registry.MustRegister(c)
 	m.counters[name] = c
+	m.describe(name, "counter", help, labels)
 	return c
 }
 
@@ -62,9 +333,117 @@ func (m *MetricsRegistry) NewGauge(name, help string, labels []string) *promethe
 
 	m.Registry.MustRegister(g)
 	m.gauges[name] = g
+	m.describe(name, "gauge", help, labels)
 	return g
 }
 
+// NewHistogram creates or retrieves a histogram metric. Nil buckets use
+// prometheus.DefBuckets.
+func (m *MetricsRegistry) NewHistogram(name, help string, labels []string, buckets []float64) *prometheus.HistogramVec {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if h, exists := m.histograms[name]; exists {
+		return h
+	}
+
+	h := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    name,
+		Help:    help,
+		Buckets: buckets,
+	}, labels)
+
+	m.Registry.MustRegister(h)
+	m.histograms[name] = h
+	m.describe(name, "histogram", help, labels)
+	return h
+}
+
+// InfoSeries is the label set of one series of an info metric
+type InfoSeries map[string]string
+
+// NewInfo registers an info metric (value always 1) whose series are computed
+// at scrape time. Label names are the union across series, so the set can
+// follow configuration; series without a label report it as "". A second call
+// with the same name replaces the series function.
+func (m *MetricsRegistry) NewInfo(name, help string, series func() []InfoSeries) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, exists := m.infos[name]; exists {
+		c.setSeries(series)
+		return
+	}
+	c := &infoCollector{name: name, help: help, series: series}
+	m.Registry.MustRegister(c)
+	m.infos[name] = c
+}
+
+// infoCollector is an unchecked collector: it describes nothing up front
+// because its label names aren't known until Collect.
+type infoCollector struct {
+	name string
+	help string
+
+	mu     sync.Mutex
+	series func() []InfoSeries
+}
+
+func (c *infoCollector) setSeries(series func() []InfoSeries) {
+	c.mu.Lock()
+	c.series = series
+	c.mu.Unlock()
+}
+
+func (c *infoCollector) Describe(_ chan<- *prometheus.Desc) {}
+
+func (c *infoCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	fn := c.series
+	c.mu.Unlock()
+	if fn == nil {
+		return
+	}
+	all := fn()
+	names := infoLabelNames(all)
+
+	desc := prometheus.NewDesc(c.name, c.help, names, nil)
+	for _, s := range all {
+		values := make([]string, len(names))
+		for i, k := range names {
+			values[i] = s[k]
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, values...)
+	}
+}
+
+// labelNames returns the label names of the current series
+func (c *infoCollector) labelNames() []string {
+	c.mu.Lock()
+	fn := c.series
+	c.mu.Unlock()
+	if fn == nil {
+		return nil
+	}
+	return infoLabelNames(fn())
+}
+
+// infoLabelNames is the sorted union of the label names of series
+func infoLabelNames(series []InfoSeries) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, s := range series {
+		for k := range s {
+			if !seen[k] {
+				seen[k] = true
+				names = append(names, k)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Counter is a helper to increment a counter with labels
 func (m *MetricsRegistry) Counter(name string, labels prometheus.Labels) prometheus.Counter {
 	m.mu.RLock()
@@ -75,6 +454,21 @@ func (m *MetricsRegistry) Counter(name string, labels prometheus.Labels) prometh
 		return noopCounter{}
 	}
 
+	labels, _ = m.admit(name, c.MetricVec, labels, true)
+	return c.With(labels)
+}
+
+// fixedCounter is Counter for metrics whose label values come from a small
+// fixed set. It skips the series budget, so it can be used under the
+// logger's lock, where a budget warning could not be logged.
+func (m *MetricsRegistry) fixedCounter(name string, labels prometheus.Labels) prometheus.Counter {
+	m.mu.RLock()
+	c, ok := m.counters[name]
+	m.mu.RUnlock()
+
+	if !ok {
+		return noopCounter{}
+	}
 	return c.With(labels)
 }
 
@@ -88,9 +482,41 @@ func (m *MetricsRegistry) Gauge(name string, labels prometheus.Labels) prometheu
 		return noopGauge{}
 	}
 
+	labels, ok = m.admit(name, g.MetricVec, labels, false)
+	if !ok {
+		return noopGauge{}
+	}
 	return g.With(labels)
 }
 
+// DeleteGauge removes the series of a gauge with exactly labels, e.g. for a
+// backend that no longer exists, and frees its place in the series budget.
+func (m *MetricsRegistry) DeleteGauge(name string, labels prometheus.Labels) bool {
+	m.mu.RLock()
+	g, ok := m.gauges[name]
+	m.mu.RUnlock()
+
+	if !ok || !g.Delete(labels) {
+		return false
+	}
+	m.forget(name, labels)
+	return true
+}
+
+// Histogram is a helper to observe into a histogram with labels
+func (m *MetricsRegistry) Histogram(name string, labels prometheus.Labels) prometheus.Observer {
+	m.mu.RLock()
+	h, ok := m.histograms[name]
+	m.mu.RUnlock()
+
+	if !ok {
+		return noopObserver{}
+	}
+
+	labels, _ = m.admit(name, h.MetricVec, labels, true)
+	return h.With(labels)
+}
+
 type noopCounter struct{}
 
 func (noopCounter) Desc() *prometheus.Desc {
@@ -134,3 +560,7 @@ func (noopGauge) Dec()                               {}
 func (noopGauge) Add(_ float64)                      {}
 func (noopGauge) Sub(_ float64)                      {}
 func (noopGauge) SetToCurrentTime()                  {}
+
+type noopObserver struct{}
+
+func (noopObserver) Observe(_ float64) {}
@@ -1,6 +1,10 @@
 package observability
 
 import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
 
@@ -154,3 +158,281 @@ func TestMetricsConcurrency(t *testing.T) {
 		t.Errorf("expected %d, got %f", count, val)
 	}
 }
+
+func TestMetricsHistogramOperations(t *testing.T) {
+	registry := NewMetricsRegistry()
+	name := "check_duration_seconds"
+	h1 := registry.NewHistogram(name, "check duration", []string{"backend"}, []float64{0.01, 0.1})
+	if h2 := registry.NewHistogram(name, "check duration", []string{"backend"}, nil); h1 != h2 {
+		t.Error("NewHistogram should return existing metric if already registered")
+	}
+
+	registry.Histogram(name, prometheus.Labels{"backend": "10.0.0.1"}).Observe(0.005)
+	registry.Histogram(name, prometheus.Labels{"backend": "10.0.0.1"}).Observe(0.05)
+
+	families, err := registry.Registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	var found bool
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+		found = true
+		h := mf.GetMetric()[0].GetHistogram()
+		if h.GetSampleCount() != 2 {
+			t.Errorf("expected 2 samples, got %d", h.GetSampleCount())
+		}
+		if sum := h.GetSampleSum(); sum < 0.0549 || sum > 0.0551 {
+			t.Errorf("expected sum 0.055, got %f", sum)
+		}
+	}
+	if !found {
+		t.Fatalf("histogram %s not gathered", name)
+	}
+
+	// Unknown histograms are a no-op
+	registry.Histogram("non_existent", prometheus.Labels{}).Observe(1)
+}
+
+func TestMetricsInfoUnionsLabelNames(t *testing.T) {
+	registry := NewMetricsRegistry()
+	registry.NewInfo("service_info", "service labels", func() []InfoSeries {
+		return []InfoSeries{
+			{"service": "web", "label_team": "payments"},
+			{"service": "dns", "label_env": "prod"},
+		}
+	})
+
+	families, err := registry.Registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	if len(families) != 1 || len(families[0].GetMetric()) != 2 {
+		t.Fatalf("expected one family with two series, got %v", families)
+	}
+	got := make(map[string]map[string]string)
+	for _, m := range families[0].GetMetric() {
+		labels := make(map[string]string)
+		for _, lp := range m.GetLabel() {
+			labels[lp.GetName()] = lp.GetValue()
+		}
+		if len(labels) != 3 || m.GetGauge().GetValue() != 1 {
+			t.Fatalf("expected 3 labels and value 1, got %v = %v", labels, m.GetGauge().GetValue())
+		}
+		got[labels["service"]] = labels
+	}
+	if got["web"]["label_team"] != "payments" || got["web"]["label_env"] != "" || got["dns"]["label_env"] != "prod" {
+		t.Fatalf("unexpected series: %v", got)
+	}
+
+	// Re-registering swaps the series function instead of panicking
+	registry.NewInfo("service_info", "service labels", func() []InfoSeries { return nil })
+	if families, _ := registry.Registry.Gather(); len(families) != 0 {
+		t.Fatalf("expected no series after replacement, got %v", families)
+	}
+}
+
+func TestMetricsSeriesBudget(t *testing.T) {
+	registry := NewMetricsRegistry()
+	registry.SetSeriesBudget(2)
+	registry.NewGauge("backend_weight", "weight", []string{"backend"})
+
+	for _, be := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		registry.Gauge("backend_weight", prometheus.Labels{"backend": be}).Set(1)
+	}
+	// Existing series still update once the budget is reached
+	registry.Gauge("backend_weight", prometheus.Labels{"backend": "10.0.0.1"}).Set(5)
+
+	if got := registry.SeriesCount("backend_weight"); got != 2 {
+		t.Fatalf("expected 2 series within budget, got %d", got)
+	}
+	if got := getMetricValue(registry.Gauge("backend_weight", prometheus.Labels{"backend": "10.0.0.1"})); got != 5 {
+		t.Fatalf("expected existing series to update, got %f", got)
+	}
+	if got := getMetricValue(registry.overflow.With(prometheus.Labels{"metric": "backend_weight"})); got != 1 {
+		t.Fatalf("expected one overflowed update, got %f", got)
+	}
+	// Gauge samples from different series can't be combined, so the
+	// overflowed update is dropped rather than written to "other"
+	families, _ := registry.Registry.Gather()
+	for _, mf := range families {
+		if mf.GetName() == "backend_weight" && len(mf.GetMetric()) != 2 {
+			t.Fatalf("expected no overflow gauge series, got %v", mf.GetMetric())
+		}
+	}
+
+	registry.SetSeriesBudget(0)
+	registry.Gauge("backend_weight", prometheus.Labels{"backend": "10.0.0.3"}).Set(1)
+	if got := registry.SeriesCount("backend_weight"); got != 3 {
+		t.Fatalf("expected default budget to admit a third series, got %d", got)
+	}
+}
+
+func TestMetricsSeriesBudgetReclaimsSeries(t *testing.T) {
+	registry := NewMetricsRegistry()
+	registry.SetSeriesBudget(2)
+	registry.NewGauge("backend_weight", "weight", []string{"backend"})
+	gatherer := registry.Gatherer()
+
+	registry.Gauge("backend_weight", prometheus.Labels{"backend": "10.0.0.1"}).Set(1)
+	registry.Gauge("backend_weight", prometheus.Labels{"backend": "10.0.0.2"}).Set(1)
+
+	// Deleting a series frees its place in the budget
+	if !registry.DeleteGauge("backend_weight", prometheus.Labels{"backend": "10.0.0.2"}) {
+		t.Fatal("expected the series to be deleted")
+	}
+	if got := registry.SeriesCount("backend_weight"); got != 1 {
+		t.Fatalf("expected 1 series after delete, got %d", got)
+	}
+	registry.Gauge("backend_weight", prometheus.Labels{"backend": "10.0.0.3"}).Set(1)
+
+	// At the budget, series not updated for seriesIdleGathers gathers make
+	// room for new ones; recently updated series are kept
+	for i := 0; i < seriesIdleGathers; i++ {
+		registry.Gauge("backend_weight", prometheus.Labels{"backend": "10.0.0.3"}).Set(2)
+		if _, err := gatherer.Gather(); err != nil {
+			t.Fatalf("gather: %v", err)
+		}
+	}
+	registry.Gauge("backend_weight", prometheus.Labels{"backend": "10.0.0.4"}).Set(1)
+
+	got := map[string]float64{}
+	families, _ := registry.Registry.Gather()
+	for _, mf := range families {
+		if mf.GetName() != "backend_weight" {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			got[metric.GetLabel()[0].GetValue()] = metric.GetGauge().GetValue()
+		}
+	}
+	want := map[string]float64{"10.0.0.3": 2, "10.0.0.4": 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected idle series evicted, got %v", got)
+	}
+	if got := getMetricValue(registry.overflow.With(prometheus.Labels{"metric": "backend_weight"})); got != 0 {
+		t.Fatalf("expected no overflow, got %f", got)
+	}
+}
+
+func TestMetricsSeriesOverflowAggregatesIntoOther(t *testing.T) {
+	var out syncBuffer
+	logger := NewLogger(WarnLevel)
+	logger.SetConsoleOutput(&out)
+
+	registry := NewMetricsRegistry()
+	registry.SetLogger(logger)
+	registry.SetSeriesBudget(1)
+	registry.NewCounter("checks_total", "checks", []string{"node", "service", "backend"})
+
+	registry.Counter("checks_total", prometheus.Labels{"node": "lb1", "service": "web", "backend": "10.0.0.1"}).Inc()
+	registry.Counter("checks_total", prometheus.Labels{"node": "lb1", "service": "web", "backend": "10.0.0.2"}).Inc()
+	registry.Counter("checks_total", prometheus.Labels{"node": "lb1", "service": "dns", "backend": "10.0.0.3"}).Add(2)
+
+	// The node label identifies the sender, so overflow keeps it
+	other := prometheus.Labels{"node": "lb1", "service": OtherLabelValue, "backend": OtherLabelValue}
+	if got := getMetricValue(registry.counters["checks_total"].With(other)); got != 3 {
+		t.Fatalf("expected overflow updates to aggregate into the other series, got %f", got)
+	}
+	if got := getMetricValue(registry.overflow.With(prometheus.Labels{"metric": "checks_total"})); got != 2 {
+		t.Fatalf("expected two overflowed updates, got %f", got)
+	}
+
+	if !strings.Contains(out.String(), "metric=checks_total") {
+		t.Fatalf("expected a series budget warning, got %q", out.String())
+	}
+	if n := strings.Count(out.String(), "series budget"); n != 1 {
+		t.Fatalf("expected the warning once per metric, got %d:\n%s", n, out.String())
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe to read while a logger writes to it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestMetricsConstLabels(t *testing.T) {
+	registry := NewMetricsRegistry()
+	registry.NewGauge("test_owner", "test gauge", []string{"node", "vip"})
+	registry.Gauge("test_owner", prometheus.Labels{"node": "lb-a", "vip": "10.0.0.1"}).Set(1)
+
+	labelsOf := func() map[string]string {
+		families, err := registry.Gatherer().Gather()
+		if err != nil {
+			t.Fatalf("Gather failed: %v", err)
+		}
+		for _, mf := range families {
+			if mf.GetName() != "test_owner" {
+				continue
+			}
+			got := make(map[string]string)
+			var names []string
+			for _, lp := range mf.GetMetric()[0].GetLabel() {
+				got[lp.GetName()] = lp.GetValue()
+				names = append(names, lp.GetName())
+			}
+			if !sort.StringsAreSorted(names) {
+				t.Errorf("labels not sorted: %v", names)
+			}
+			return got
+		}
+		t.Fatal("test_owner not gathered")
+		return nil
+	}
+
+	if got := labelsOf(); len(got) != 2 {
+		t.Errorf("expected no const labels by default, got %v", got)
+	}
+
+	registry.SetConstLabels(map[string]string{"cluster": "edge", "peer": "lb-a", "vip": "ignored"})
+	got := labelsOf()
+	if got["cluster"] != "edge" || got["peer"] != "lb-a" {
+		t.Errorf("missing cluster/peer labels: %v", got)
+	}
+	if got["vip"] != "10.0.0.1" {
+		t.Errorf("const label must not override a series label, got vip=%q", got["vip"])
+	}
+
+	// Reloads replace the labels rather than accumulating them
+	registry.SetConstLabels(nil)
+	if got := labelsOf(); len(got) != 2 {
+		t.Errorf("expected const labels cleared, got %v", got)
+	}
+}
+
+func TestMetricsCatalog(t *testing.T) {
+	registry := NewMetricsRegistry()
+	registry.NewGauge("lbctl_b", "gauge b", []string{"service", "node"})
+	registry.NewCounter("lbctl_a", "counter a", nil)
+	registry.NewHistogram("lbctl_c", "histogram c", []string{"service"}, nil)
+	registry.NewInfo("lbctl_d", "info d", func() []InfoSeries {
+		return []InfoSeries{{"service": "web"}, {"label_team": "payments"}}
+	})
+	registry.SetConstLabels(map[string]string{"cluster": "east", "node": "lb-a"})
+
+	want := []MetricDescription{
+		{Name: "lbctl_a", Type: "counter", Help: "counter a", Labels: []string{"cluster", "node"}},
+		{Name: "lbctl_b", Type: "gauge", Help: "gauge b", Labels: []string{"cluster", "node", "service"}},
+		{Name: "lbctl_c", Type: "histogram", Help: "histogram c", Labels: []string{"cluster", "node", "service"}},
+		{Name: "lbctl_d", Type: "info", Help: "info d", Labels: []string{"cluster", "label_team", "node", "service"}},
+		{Name: "lbctl_metrics_series_overflow_total", Type: "counter", Help: overflowHelp, Labels: []string{"cluster", "metric", "node"}},
+	}
+	if got := registry.Catalog(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Catalog() =\n%+v\nwant\n%+v", got, want)
+	}
+}
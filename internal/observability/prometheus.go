@@ -2,11 +2,13 @@ package observability
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
 	"time"
 
+	"github.com/malindarathnayake/LibraFlux/internal/routine"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -18,6 +20,8 @@ type PrometheusServer struct {
 	port     int
 	path     string
 	bind     string
+	ready    func() bool
+	tracker  *routine.Tracker
 }
 
 // PrometheusConfig holds Prometheus server parameters
@@ -54,13 +58,24 @@ func NewPrometheusServer(cfg PrometheusConfig, registry *MetricsRegistry, logger
 	}, nil
 }
 
+// SetReadinessCheck installs the function backing the /ready endpoint.
+// Without one, /ready reports ready as soon as the server is up.
+func (s *PrometheusServer) SetReadinessCheck(fn func() bool) {
+	s.ready = fn
+}
+
+// SetTracker enables /debug/routines and records the server's listener
+func (s *PrometheusServer) SetTracker(t *routine.Tracker) {
+	s.tracker = t
+}
+
 // Start starts the HTTP server
 func (s *PrometheusServer) Start(ctx context.Context) error {
 	mux := http.NewServeMux()
-	
+
 	// Prometheus metrics endpoint
 	mux.Handle(s.path, promhttp.HandlerFor(
-		s.registry.Registry,
+		s.registry.Gatherer(),
 		promhttp.HandlerOpts{
 			EnableOpenMetrics: true,
 		},
@@ -72,6 +87,25 @@ func (s *PrometheusServer) Start(ctx context.Context) error {
 		w.Write([]byte("ok"))
 	})
 
+	// Readiness endpoint (503 until the daemon has programmed IPVS)
+	mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
+		if s.ready != nil && !s.ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("not ready"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ready"))
+	})
+
+	// Tracked goroutines and listeners, for spotting leaks across reloads
+	if s.tracker != nil {
+		mux.HandleFunc("/debug/routines", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(s.tracker.Active())
+		})
+	}
+
 	// Root endpoint with helpful info
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/" {
@@ -97,6 +131,7 @@ func (s *PrometheusServer) Start(ctx context.Context) error {
 		<ul>
 			<li><a href="%s">%s</a> - Prometheus metrics</li>
 			<li><a href="/health">/health</a> - Health check</li>
+			<li><a href="/ready">/ready</a> - Readiness check</li>
 		</ul>
 	</div>
 </body>
@@ -118,8 +153,10 @@ func (s *PrometheusServer) Start(ctx context.Context) error {
 		"path": s.path,
 	})
 
-	// Start server in goroutine
+	// Start server in goroutine; the listener stays tracked until Shutdown
+	release := s.tracker.Track("prometheus", routine.KindListener)
 	go func() {
+		defer release()
 		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			s.logger.Error("Prometheus server error", map[string]interface{}{
 				"error": err.Error(),
@@ -154,8 +191,8 @@ func (s *PrometheusServer) Stop() error {
 
 // TestConnection verifies the Prometheus endpoint is accessible
 func (s *PrometheusServer) TestConnection() error {
-	url := fmt.Sprintf("http://localhost:%d%s", s.port, s.path)
-	
+	url := s.GetURL()
+
 	client := &http.Client{
 		Timeout: 2 * time.Second,
 	}
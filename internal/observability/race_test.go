@@ -0,0 +1,6 @@
+//go:build race
+
+package observability
+
+// raceEnabled is true when tests run under the race detector
+const raceEnabled = true
@@ -0,0 +1,91 @@
+package observability
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// SinkCheck is the outcome of exercising one telemetry sink
+type SinkCheck struct {
+	Sink    string // gelf, influxdb or prometheus
+	Target  string // Address or URL that was exercised
+	Err     error
+	Latency time.Duration
+}
+
+// udpRefusedWait is how long a UDP check waits for an ICMP port unreachable
+// after sending its message
+const udpRefusedWait = 200 * time.Millisecond
+
+// CheckGELF sends one self-test message to a GELF input. Over TCP the input
+// must accept the connection and the message. UDP delivery can't be
+// confirmed, so only a closed port (ICMP port unreachable) fails the check.
+func CheckGELF(host string, port int, protocol, facility string, timeout time.Duration) error {
+	network := "udp"
+	if strings.EqualFold(protocol, "tcp") {
+		network = "tcp"
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	conn, err := net.DialTimeout(network, addr, timeout)
+	if err != nil {
+		return fmt.Errorf("gelf %s %s unreachable: %w", network, addr, err)
+	}
+	defer conn.Close()
+
+	hostname, _ := os.Hostname()
+	msg, err := json.Marshal(map[string]interface{}{
+		"version":       "1.1",
+		"host":          hostname,
+		"short_message": "lbctl observability self-test",
+		"level":         6,
+		"_facility":     facility,
+	})
+	if err != nil {
+		return err
+	}
+	if network == "tcp" {
+		msg = append(msg, 0) // TCP inputs frame messages with a null byte
+	}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(msg); err != nil {
+		return fmt.Errorf("gelf %s %s write failed: %w", network, addr, err)
+	}
+	if network == "udp" {
+		// A closed port answers with ICMP unreachable, reported on the next read
+		_ = conn.SetReadDeadline(time.Now().Add(min(timeout, udpRefusedWait)))
+		if _, err := conn.Read(make([]byte, 1)); errors.Is(err, syscall.ECONNREFUSED) {
+			return fmt.Errorf("gelf udp %s refused: %w", addr, err)
+		}
+	}
+	return nil
+}
+
+// CheckPrometheusBind reports whether the metrics endpoint can be served. A
+// free port is bound and released again; a port already in use must answer
+// on the metrics path, i.e. belong to a running lbctl rather than another
+// process.
+func CheckPrometheusBind(cfg PrometheusConfig) error {
+	s, err := NewPrometheusServer(cfg, nil, nil)
+	if err != nil {
+		return err
+	}
+	addr := net.JoinHostPort(s.bind, strconv.Itoa(s.port))
+	ln, err := net.Listen("tcp", addr)
+	if err == nil {
+		return ln.Close()
+	}
+	if !errors.Is(err, syscall.EADDRINUSE) {
+		return fmt.Errorf("prometheus cannot bind %s: %w", addr, err)
+	}
+	if err := s.TestConnection(); err != nil {
+		return fmt.Errorf("prometheus port %s is in use by another process: %w", addr, err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package resolver
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// DNS record types
+const (
+	TypeA     uint16 = 1
+	TypeCNAME uint16 = 5
+	TypeAAAA  uint16 = 28
+	TypeSRV   uint16 = 33
+)
+
+// DNS response codes
+const (
+	RcodeSuccess  = 0
+	RcodeServFail = 2
+	RcodeNXDomain = 3
+)
+
+// Record is an answer record. IP is set for A and AAAA records only.
+type Record struct {
+	Type uint16
+	TTL  uint32
+	IP   net.IP
+}
+
+type Response struct {
+	Rcode     int
+	Truncated bool // TC: the answer didn't fit and should be retried over TCP
+	Answers   []Record
+}
+
+// BuildQuery encodes a recursive query for name in class IN
+func BuildQuery(id uint16, name string, qtype uint16) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" {
+		return nil, fmt.Errorf("missing dns query name")
+	}
+
+	msg := make([]byte, 12, 12+len(name)+6)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], 0x0100) // RD
+	binary.BigEndian.PutUint16(msg[4:], 1)      // QDCOUNT
+
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, fmt.Errorf("invalid dns query name: %s", name)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	msg = binary.BigEndian.AppendUint16(msg, 1) // IN
+	return msg, nil
+}
+
+// ParseResponse decodes the header and answer section of a response to the
+// query with the given id.
+func ParseResponse(msg []byte, id uint16) (*Response, error) {
+	if len(msg) < 12 {
+		return nil, fmt.Errorf("short dns response: %d bytes", len(msg))
+	}
+	if got := binary.BigEndian.Uint16(msg[0:]); got != id {
+		return nil, fmt.Errorf("dns response id mismatch: %d != %d", got, id)
+	}
+	flags := binary.BigEndian.Uint16(msg[2:])
+	if flags&0x8000 == 0 {
+		return nil, fmt.Errorf("dns message is not a response")
+	}
+	resp := &Response{Rcode: int(flags & 0x000F), Truncated: flags&0x0200 != 0}
+
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	ancount := int(binary.BigEndian.Uint16(msg[6:]))
+	off := 12
+	for i := 0; i < qdcount; i++ {
+		next, err := skipName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		off = next + 4 // QTYPE, QCLASS
+	}
+
+	for i := 0; i < ancount; i++ {
+		next, err := skipName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		off = next
+		if off+10 > len(msg) {
+			return nil, fmt.Errorf("truncated dns answer")
+		}
+		rtype := binary.BigEndian.Uint16(msg[off:])
+		ttl := binary.BigEndian.Uint32(msg[off+4:])
+		rdlen := int(binary.BigEndian.Uint16(msg[off+8:]))
+		off += 10
+		if off+rdlen > len(msg) {
+			return nil, fmt.Errorf("truncated dns answer")
+		}
+
+		rec := Record{Type: rtype, TTL: ttl}
+		switch {
+		case rtype == TypeA && rdlen == net.IPv4len:
+			rec.IP = net.IP(append([]byte(nil), msg[off:off+rdlen]...))
+		case rtype == TypeAAAA && rdlen == net.IPv6len:
+			rec.IP = net.IP(append([]byte(nil), msg[off:off+rdlen]...))
+		}
+		resp.Answers = append(resp.Answers, rec)
+		off += rdlen
+	}
+	return resp, nil
+}
+
+// skipName returns the offset just past the (possibly compressed) name at off
+func skipName(msg []byte, off int) (int, error) {
+	for {
+		if off >= len(msg) {
+			return 0, fmt.Errorf("truncated dns name")
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			return off + 1, nil
+		case n&0xC0 == 0xC0:
+			// Compression pointer ends the name
+			if off+2 > len(msg) {
+				return 0, fmt.Errorf("truncated dns name")
+			}
+			return off + 2, nil
+		case n > 63:
+			return 0, fmt.Errorf("invalid dns label length: %d", n)
+		default:
+			off += 1 + n
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Package resolver resolves hostnames with a cache, so health checks, the
+// reconciler and discovery providers don't query DNS on every probe.
+// By default lookups go through the Go resolver, which honours /etc/hosts, the
+// resolv.conf search list and ndots. It doesn't report record TTLs, so its
+// results are cached for CacheTTL. With Servers set, the resolver queries
+// them itself and caches each answer for its lowest record TTL, capped at
+// CacheTTL.
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+)
+
+const (
+	DefaultCacheTTL    = 30 * time.Second
+	DefaultNegativeTTL = 5 * time.Second
+	DefaultTimeout     = 2 * time.Second
+)
+
+// ErrNotFound is returned for names that don't exist or have no A/AAAA
+// records. These results are cached for NegativeTTL.
+var ErrNotFound = errors.New("no such host")
+
+// LookupFunc returns the addresses of host
+type LookupFunc func(ctx context.Context, host string) ([]net.IPAddr, error)
+
+// lookupFunc returns the addresses of host and how long they may be cached
+type lookupFunc func(ctx context.Context, host string) ([]net.IP, time.Duration, error)
+
+// Options configure a Resolver. Zero values use the defaults.
+type Options struct {
+	// Servers overrides the nameservers of /etc/resolv.conf; "ip" or
+	// "ip:port", tried in order. Names are queried as given, without
+	// /etc/hosts or the search list.
+	Servers     []string
+	Timeout     time.Duration // Per lookup, across all servers
+	CacheTTL    time.Duration // Longest time resolved addresses are cached
+	NegativeTTL time.Duration
+	Clock       clock.Clock
+
+	Lookup LookupFunc // Optional; replaces the Go resolver and Servers
+}
+
+// Stats counts cache outcomes since the resolver was created
+type Stats struct {
+	Hits     uint64
+	Misses   uint64 // Lookups that queried DNS
+	Negative uint64 // Hits that returned a cached ErrNotFound
+}
+
+type Resolver struct {
+	lookups     []lookupFunc // Tried in order until one gives a definite answer
+	timeout     time.Duration
+	cacheTTL    time.Duration
+	negativeTTL time.Duration
+	clock       clock.Clock
+
+	mu       sync.Mutex
+	cache    map[string]entry
+	inflight map[string]*call
+	stats    Stats
+}
+
+type entry struct {
+	ips     []net.IP
+	err     error
+	expires time.Time
+}
+
+// call is a lookup in progress; concurrent lookups of the same name wait on it
+type call struct {
+	done chan struct{}
+	ips  []net.IP
+	err  error
+}
+
+func New(opts Options) *Resolver {
+	r := &Resolver{
+		timeout:     opts.Timeout,
+		cacheTTL:    opts.CacheTTL,
+		negativeTTL: opts.NegativeTTL,
+		clock:       opts.Clock,
+		cache:       make(map[string]entry),
+		inflight:    make(map[string]*call),
+	}
+	if r.timeout <= 0 {
+		r.timeout = DefaultTimeout
+	}
+	if r.cacheTTL <= 0 {
+		r.cacheTTL = DefaultCacheTTL
+	}
+	if r.negativeTTL <= 0 {
+		r.negativeTTL = DefaultNegativeTTL
+	}
+	if r.clock == nil {
+		r.clock = clock.Real()
+	}
+	switch {
+	case opts.Lookup != nil:
+		r.lookups = []lookupFunc{fixedTTL(opts.Lookup, r.cacheTTL)}
+	case len(opts.Servers) > 0:
+		for _, s := range opts.Servers {
+			r.lookups = append(r.lookups, serverLookup(s))
+		}
+	default:
+		r.lookups = []lookupFunc{fixedTTL(net.DefaultResolver.LookupIPAddr, r.cacheTTL)}
+	}
+	return r
+}
+
+// fixedTTL adapts a lookup that doesn't report TTLs, caching its answers for ttl
+func fixedTTL(lookup LookupFunc, ttl time.Duration) lookupFunc {
+	return func(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+		addrs, err := lookup(ctx, host)
+		if err != nil {
+			return nil, 0, err
+		}
+		ips := make([]net.IP, len(addrs))
+		for i, a := range addrs {
+			ips[i] = a.IP
+		}
+		return ips, ttl, nil
+	}
+}
+
+// LookupIP returns the A and AAAA addresses for host. IP literals are
+// returned as-is without touching the cache.
+func (r *Resolver) LookupIP(host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	name := strings.ToLower(strings.TrimSuffix(host, "."))
+
+	r.mu.Lock()
+	if e, ok := r.cache[name]; ok && r.clock.Now().Before(e.expires) {
+		r.stats.Hits++
+		if e.err != nil {
+			r.stats.Negative++
+		}
+		r.mu.Unlock()
+		return copyIPs(e.ips), e.err
+	}
+	if c, ok := r.inflight[name]; ok {
+		r.mu.Unlock()
+		<-c.done
+		return copyIPs(c.ips), c.err
+	}
+	c := &call{done: make(chan struct{})}
+	r.inflight[name] = c
+	r.stats.Misses++
+	r.mu.Unlock()
+
+	ips, ttl, err := r.resolve(name)
+	c.ips, c.err = ips, err
+
+	r.mu.Lock()
+	delete(r.inflight, name)
+	// Transient failures aren't cached; the next lookup retries
+	switch {
+	case err == nil:
+		r.cache[name] = entry{ips: ips, expires: r.clock.Now().Add(min(ttl, r.cacheTTL))}
+	case errors.Is(err, ErrNotFound):
+		r.cache[name] = entry{err: err, expires: r.clock.Now().Add(r.negativeTTL)}
+	}
+	r.mu.Unlock()
+	close(c.done)
+
+	return copyIPs(ips), err
+}
+
+// Flush drops all cached results
+func (r *Resolver) Flush() {
+	r.mu.Lock()
+	r.cache = make(map[string]entry)
+	r.mu.Unlock()
+}
+
+func (r *Resolver) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// resolve asks each lookup in turn, moving on only when one fails without a
+// definite answer, e.g. a timeout or SERVFAIL. ttl is how long the answer
+// may be cached.
+func (r *Resolver) resolve(name string) (ips []net.IP, ttl time.Duration, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var lastErr error
+	for _, lookup := range r.lookups {
+		ips, ttl, err := lookup(ctx, name)
+		if err == nil {
+			if len(ips) == 0 {
+				return nil, 0, fmt.Errorf("lookup %s: %w", name, ErrNotFound)
+			}
+			return ips, ttl, nil
+		}
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, 0, fmt.Errorf("lookup %s: %w", name, ErrNotFound)
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, 0, errdefs.Transient(fmt.Errorf("lookup %s: %w", name, lastErr))
+}
+
+func copyIPs(ips []net.IP) []net.IP {
+	if ips == nil {
+		return nil
+	}
+	out := make([]net.IP, len(ips))
+	copy(out, ips)
+	return out
+}
@@ -0,0 +1,304 @@
+package resolver
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+)
+
+// fakeDNS answers A queries from records and AAAA queries with no answers.
+// Unknown names get NXDOMAIN; names in servfail get SERVFAIL.
+type fakeDNS struct {
+	records  map[string]net.IP
+	ttl      uint32
+	servfail map[string]bool
+	queries  atomic.Int32
+	addr     string
+}
+
+func startFakeDNS(t *testing.T, d *fakeDNS) {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	d.addr = pc.LocalAddr().String()
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			d.queries.Add(1)
+			pc.WriteTo(d.answer(buf[:n]), from)
+		}
+	}()
+}
+
+func (d *fakeDNS) answer(q []byte) []byte {
+	name, end := readName(q, 12)
+	qtype := binary.BigEndian.Uint16(q[end:])
+
+	resp := append([]byte(nil), q[:end+4]...)
+	binary.BigEndian.PutUint16(resp[2:], 0x8180) // QR, RD, RA
+	binary.BigEndian.PutUint16(resp[10:], 0)     // Drop the query's EDNS record
+	switch {
+	case d.servfail[name]:
+		resp[3] |= RcodeServFail
+	case d.records[name] == nil:
+		resp[3] |= RcodeNXDomain
+	case qtype == TypeA:
+		binary.BigEndian.PutUint16(resp[6:], 1) // ANCOUNT
+		resp = append(resp, 0xC0, 12)           // Pointer to the question name
+		resp = binary.BigEndian.AppendUint16(resp, TypeA)
+		resp = binary.BigEndian.AppendUint16(resp, 1)
+		resp = binary.BigEndian.AppendUint32(resp, d.ttl)
+		resp = binary.BigEndian.AppendUint16(resp, 4)
+		resp = append(resp, d.records[name].To4()...)
+	}
+	return resp
+}
+
+func readName(msg []byte, off int) (string, int) {
+	var name string
+	for msg[off] != 0 {
+		n := int(msg[off])
+		if name != "" {
+			name += "."
+		}
+		name += string(msg[off+1 : off+1+n])
+		off += 1 + n
+	}
+	return name, off + 1
+}
+
+// fakeLookup answers from records and counts lookups. Unknown names are not
+// found; names in fail get a temporary error.
+type fakeLookup struct {
+	records map[string]net.IP
+	fail    map[string]bool
+	calls   atomic.Int32
+}
+
+func (f *fakeLookup) lookup(ctx context.Context, host string) ([]net.IPAddr, error) {
+	f.calls.Add(1)
+	if f.fail[host] {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: host, IsTemporary: true}
+	}
+	ip := f.records[host]
+	if ip == nil {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return []net.IPAddr{{IP: ip}}, nil
+}
+
+func TestResolverCachesForTTL(t *testing.T) {
+	f := &fakeLookup{records: map[string]net.IP{"api.example": net.ParseIP("10.0.0.5")}}
+	clk := clock.NewFake(time.Unix(0, 0))
+	r := New(Options{Lookup: f.lookup, Clock: clk, CacheTTL: 30 * time.Second})
+
+	for i := 0; i < 3; i++ {
+		ips, err := r.LookupIP("API.example.")
+		if err != nil {
+			t.Fatalf("LookupIP() error = %v", err)
+		}
+		if len(ips) != 1 || !ips[0].Equal(net.ParseIP("10.0.0.5")) {
+			t.Fatalf("unexpected addresses: %v", ips)
+		}
+	}
+	if got := f.calls.Load(); got != 1 {
+		t.Fatalf("expected one lookup, got %d", got)
+	}
+
+	clk.Advance(29 * time.Second)
+	if _, err := r.LookupIP("api.example"); err != nil {
+		t.Fatalf("LookupIP() error = %v", err)
+	}
+	if got := f.calls.Load(); got != 1 {
+		t.Fatalf("expected cached answer within TTL, got %d lookups", got)
+	}
+
+	clk.Advance(time.Second)
+	if _, err := r.LookupIP("api.example"); err != nil {
+		t.Fatalf("LookupIP() error = %v", err)
+	}
+	if got := f.calls.Load(); got != 2 {
+		t.Fatalf("expected a fresh lookup after TTL, got %d lookups", got)
+	}
+
+	if s := r.Stats(); s.Hits != 3 || s.Misses != 2 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+}
+
+func TestResolverNegativeCaching(t *testing.T) {
+	f := &fakeLookup{fail: map[string]bool{"flaky.example": true}}
+	clk := clock.NewFake(time.Unix(0, 0))
+	r := New(Options{Lookup: f.lookup, Clock: clk, NegativeTTL: 5 * time.Second})
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.LookupIP("missing.example"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if got := f.calls.Load(); got != 1 {
+		t.Fatalf("expected not found to be cached, got %d lookups", got)
+	}
+	if s := r.Stats(); s.Negative != 1 {
+		t.Fatalf("expected one negative hit, got %+v", s)
+	}
+	clk.Advance(5 * time.Second)
+	r.LookupIP("missing.example")
+	if got := f.calls.Load(); got != 2 {
+		t.Fatalf("expected negative entry to expire, got %d lookups", got)
+	}
+
+	// Temporary failures must not be cached
+	for i := 0; i < 2; i++ {
+		_, err := r.LookupIP("flaky.example")
+		if !errors.Is(err, errdefs.ErrTransient) {
+			t.Fatalf("expected transient error, got %v", err)
+		}
+	}
+	if got := f.calls.Load(); got != 4 {
+		t.Fatalf("expected temporary failures to be retried, got %d lookups", got)
+	}
+}
+
+func TestResolverServersFallBack(t *testing.T) {
+	d := &fakeDNS{records: map[string]net.IP{"api.example": net.ParseIP("10.0.0.5")}, ttl: 30}
+	startFakeDNS(t, d)
+
+	// Nothing listens on the first server, so the lookup moves on to the second
+	dead, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	deadAddr := dead.LocalAddr().String()
+	dead.Close()
+
+	r := New(Options{Servers: []string{deadAddr, d.addr}, Timeout: 5 * time.Second})
+	ips, err := r.LookupIP("api.example")
+	if err != nil {
+		t.Fatalf("LookupIP() error = %v", err)
+	}
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("10.0.0.5")) {
+		t.Fatalf("unexpected addresses: %v", ips)
+	}
+	if _, err := r.LookupIP("missing.example"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestResolverServersHonourRecordTTL(t *testing.T) {
+	d := &fakeDNS{records: map[string]net.IP{"api.example": net.ParseIP("10.0.0.5")}, ttl: 10}
+	startFakeDNS(t, d)
+	clk := clock.NewFake(time.Unix(0, 0))
+	r := New(Options{Servers: []string{d.addr}, Clock: clk, CacheTTL: 30 * time.Second, Timeout: 5 * time.Second})
+
+	lookup := func(r *Resolver) {
+		t.Helper()
+		if _, err := r.LookupIP("api.example"); err != nil {
+			t.Fatalf("LookupIP() error = %v", err)
+		}
+	}
+	// Each lookup asks for A and AAAA
+	lookup(r)
+	clk.Advance(9 * time.Second)
+	lookup(r)
+	if got := d.queries.Load(); got != 2 {
+		t.Fatalf("expected the answer cached within its TTL, got %d queries", got)
+	}
+	clk.Advance(time.Second)
+	lookup(r)
+	if got := d.queries.Load(); got != 4 {
+		t.Fatalf("expected a fresh lookup once the record TTL expired, got %d queries", got)
+	}
+
+	// CacheTTL caps longer record TTLs
+	long := &fakeDNS{records: d.records, ttl: 3600}
+	startFakeDNS(t, long)
+	r = New(Options{Servers: []string{long.addr}, Clock: clk, CacheTTL: 30 * time.Second, Timeout: 5 * time.Second})
+	lookup(r)
+	clk.Advance(30 * time.Second)
+	lookup(r)
+	if got := long.queries.Load(); got != 4 {
+		t.Fatalf("expected CacheTTL to cap the record TTL, got %d queries", got)
+	}
+}
+
+func TestResolverCoalescesConcurrentLookups(t *testing.T) {
+	f := &fakeLookup{records: map[string]net.IP{"api.example": net.ParseIP("10.0.0.5")}}
+	release := make(chan struct{})
+	r := New(Options{
+		Lookup: func(ctx context.Context, host string) ([]net.IPAddr, error) {
+			<-release
+			return f.lookup(ctx, host)
+		},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.LookupIP("api.example"); err != nil {
+				t.Errorf("LookupIP() error = %v", err)
+			}
+		}()
+	}
+	// Let every goroutine reach the cache before the first lookup completes
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := f.calls.Load(); got != 1 {
+		t.Fatalf("expected a single lookup, got %d", got)
+	}
+}
+
+func TestResolverIPLiteral(t *testing.T) {
+	f := &fakeLookup{}
+	r := New(Options{Lookup: f.lookup})
+	ips, err := r.LookupIP("10.1.2.3")
+	if err != nil || len(ips) != 1 || ips[0].String() != "10.1.2.3" {
+		t.Fatalf("LookupIP(literal) = %v, %v", ips, err)
+	}
+	if s := r.Stats(); s.Misses != 0 {
+		t.Fatalf("IP literals must bypass the cache: %+v", s)
+	}
+}
+
+func TestParseResponseRejectsTruncated(t *testing.T) {
+	q, err := BuildQuery(7, "api.example", TypeA)
+	if err != nil {
+		t.Fatalf("BuildQuery() error = %v", err)
+	}
+	d := &fakeDNS{records: map[string]net.IP{"api.example": net.ParseIP("10.0.0.5")}, ttl: 30}
+	resp := d.answer(q)
+
+	parsed, err := ParseResponse(resp, 7)
+	if err != nil {
+		t.Fatalf("ParseResponse() error = %v", err)
+	}
+	if len(parsed.Answers) != 1 || parsed.Answers[0].TTL != 30 || !parsed.Answers[0].IP.Equal(net.ParseIP("10.0.0.5")) {
+		t.Fatalf("unexpected answers: %+v", parsed.Answers)
+	}
+	if _, err := ParseResponse(resp[:len(resp)-2], 7); err == nil {
+		t.Fatalf("expected truncated answer to fail")
+	}
+	if _, err := ParseResponse(resp, 8); err == nil {
+		t.Fatalf("expected id mismatch to fail")
+	}
+}
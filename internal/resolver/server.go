@@ -0,0 +1,111 @@
+package resolver
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// maxUDPResponse is the largest UDP answer to a query without EDNS. Larger
+// answers come back truncated and are retried over TCP.
+const maxUDPResponse = 512
+
+// serverLookup queries server for the A and AAAA records of host and reports
+// the lowest TTL in the answers, so addresses aren't cached past their
+// records. host is looked up as given: /etc/hosts and the resolv.conf search
+// list don't apply.
+func serverLookup(server string) lookupFunc {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	return func(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+		var ips []net.IP
+		ttl := time.Duration(-1)
+		for _, qtype := range []uint16{TypeA, TypeAAAA} {
+			resp, err := exchange(ctx, server, host, qtype)
+			if err != nil {
+				return nil, 0, &net.DNSError{Err: err.Error(), Name: host, Server: server, IsTemporary: true}
+			}
+			switch resp.Rcode {
+			case RcodeSuccess:
+			case RcodeNXDomain:
+				return nil, 0, &net.DNSError{Err: "no such host", Name: host, Server: server, IsNotFound: true}
+			default:
+				return nil, 0, &net.DNSError{Err: fmt.Sprintf("server misbehaving: rcode %d", resp.Rcode), Name: host, Server: server, IsTemporary: true}
+			}
+			// CNAME records count too: the chain is only good as long as
+			// its shortest-lived link
+			for _, rec := range resp.Answers {
+				if recTTL := time.Duration(rec.TTL) * time.Second; ttl < 0 || recTTL < ttl {
+					ttl = recTTL
+				}
+				if rec.IP != nil {
+					ips = append(ips, rec.IP)
+				}
+			}
+		}
+		return ips, ttl, nil
+	}
+}
+
+// exchange sends one query for name to server over UDP, repeating it over
+// TCP if the answer was truncated.
+func exchange(ctx context.Context, server, name string, qtype uint16) (*Response, error) {
+	resp, err := exchangeOver(ctx, "udp", server, name, qtype)
+	if err != nil || !resp.Truncated {
+		return resp, err
+	}
+	return exchangeOver(ctx, "tcp", server, name, qtype)
+}
+
+func exchangeOver(ctx context.Context, network, server, name string, qtype uint16) (*Response, error) {
+	id := uint16(rand.Intn(1 << 16))
+	query, err := BuildQuery(id, name, qtype)
+	if err != nil {
+		return nil, err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	var msg []byte
+	if network == "tcp" {
+		// Messages over TCP are prefixed with their length
+		framed := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+		if _, err := conn.Write(append(framed, query...)); err != nil {
+			return nil, err
+		}
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return nil, err
+		}
+		msg = make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, maxUDPResponse)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		msg = buf[:n]
+	}
+	return ParseResponse(msg, id)
+}
@@ -0,0 +1,123 @@
+// Package routine keeps long-lived goroutines running across panics.
+package routine
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	DefaultMinBackoff  = time.Second
+	DefaultMaxBackoff  = time.Minute
+	DefaultStableAfter = time.Minute
+)
+
+// Panic describes a recovered panic passed to the supervisor's handler
+type Panic struct {
+	Name     string
+	Value    interface{}
+	Stack    []byte
+	Restarts int           // Consecutive restarts, including this one
+	Backoff  time.Duration // Delay before the restart
+}
+
+func (p Panic) Error() string {
+	return fmt.Sprintf("%s panicked: %v", p.Name, p.Value)
+}
+
+// Supervisor restarts functions that panic, with exponential backoff between
+// restarts. A run that lasts StableAfter resets the backoff.
+type Supervisor struct {
+	OnPanic     func(p Panic)
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	StableAfter time.Duration
+
+	now   func() time.Time
+	after func(d time.Duration) <-chan time.Time
+}
+
+// NewSupervisor returns a supervisor with default backoff settings
+func NewSupervisor(onPanic func(p Panic)) *Supervisor {
+	return &Supervisor{
+		OnPanic:     onPanic,
+		MinBackoff:  DefaultMinBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+		StableAfter: DefaultStableAfter,
+	}
+}
+
+// Run calls fn until it returns without panicking or stop is closed. It
+// blocks, so callers start it with `go`. A nil supervisor runs fn unprotected.
+func (s *Supervisor) Run(name string, stop <-chan struct{}, fn func()) {
+	if s == nil {
+		fn()
+		return
+	}
+
+	now := s.now
+	if now == nil {
+		now = time.Now
+	}
+	after := s.after
+	if after == nil {
+		after = time.After
+	}
+
+	restarts := 0
+	for {
+		start := now()
+		p, panicked := call(fn)
+		if !panicked {
+			return
+		}
+
+		if now().Sub(start) >= s.StableAfter {
+			restarts = 0
+		}
+		restarts++
+
+		p.Name = name
+		p.Restarts = restarts
+		p.Backoff = s.backoff(restarts)
+		if s.OnPanic != nil {
+			s.OnPanic(p)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-after(p.Backoff):
+		}
+	}
+}
+
+func (s *Supervisor) backoff(restarts int) time.Duration {
+	d := s.MinBackoff
+	if d <= 0 {
+		d = DefaultMinBackoff
+	}
+	max := s.MaxBackoff
+	if max <= 0 {
+		max = DefaultMaxBackoff
+	}
+	for i := 1; i < restarts && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+func call(fn func()) (p Panic, panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			p = Panic{Value: r, Stack: debug.Stack()}
+			panicked = true
+		}
+	}()
+	fn()
+	return p, false
+}
@@ -0,0 +1,99 @@
+package routine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSupervisorRestartsAfterPanic(t *testing.T) {
+	var panics []Panic
+	var waits []time.Duration
+	s := NewSupervisor(func(p Panic) { panics = append(panics, p) })
+	s.MinBackoff = 10 * time.Millisecond
+	s.MaxBackoff = 25 * time.Millisecond
+	s.after = func(d time.Duration) <-chan time.Time {
+		waits = append(waits, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Time{}
+		return ch
+	}
+
+	calls := 0
+	s.Run("worker", nil, func() {
+		calls++
+		if calls <= 3 {
+			panic("boom")
+		}
+	})
+
+	if calls != 4 {
+		t.Fatalf("expected 4 calls, got %d", calls)
+	}
+	if len(panics) != 3 {
+		t.Fatalf("expected 3 panics, got %d", len(panics))
+	}
+	if panics[0].Name != "worker" || panics[0].Value != "boom" || len(panics[0].Stack) == 0 {
+		t.Fatalf("unexpected panic details: %+v", panics[0])
+	}
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 25 * time.Millisecond}
+	for i, w := range want {
+		if waits[i] != w || panics[i].Backoff != w || panics[i].Restarts != i+1 {
+			t.Fatalf("restart %d: backoff %s (panic %+v), want %s", i+1, waits[i], panics[i], w)
+		}
+	}
+}
+
+func TestSupervisorStableRunResetsBackoff(t *testing.T) {
+	var restarts []int
+	s := NewSupervisor(func(p Panic) { restarts = append(restarts, p.Restarts) })
+	s.StableAfter = time.Minute
+	clock := time.Unix(0, 0)
+	s.now = func() time.Time { return clock }
+	s.after = func(time.Duration) <-chan time.Time {
+		ch := make(chan time.Time, 1)
+		ch <- time.Time{}
+		return ch
+	}
+
+	calls := 0
+	s.Run("worker", nil, func() {
+		calls++
+		if calls == 2 {
+			clock = clock.Add(2 * time.Minute)
+		}
+		if calls <= 3 {
+			panic("boom")
+		}
+	})
+
+	// The second run lasted past StableAfter, so its panic starts over at 1
+	if len(restarts) != 3 || restarts[0] != 1 || restarts[1] != 1 || restarts[2] != 2 {
+		t.Fatalf("unexpected restart counts: %v", restarts)
+	}
+}
+
+func TestSupervisorStopDuringBackoff(t *testing.T) {
+	s := NewSupervisor(nil)
+	s.MinBackoff = time.Hour
+	stop := make(chan struct{})
+	close(stop)
+
+	calls := 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run("worker", stop, func() {
+			calls++
+			panic("boom")
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Run did not return after stop")
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 call, got %d", calls)
+	}
+}
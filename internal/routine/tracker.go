@@ -0,0 +1,130 @@
+package routine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resource kinds recorded by Tracker
+const (
+	KindGoroutine = "goroutine"
+	KindListener  = "listener"
+)
+
+// Entry is a tracked long-lived resource
+type Entry struct {
+	ID      int       `json:"id"`
+	Name    string    `json:"name"`
+	Kind    string    `json:"kind"`
+	Started time.Time `json:"started"`
+}
+
+// Tracker records long-lived goroutines and listeners so shutdown and reload
+// paths can be checked for leaks. A nil Tracker is valid and tracks nothing.
+type Tracker struct {
+	mu     sync.Mutex
+	nextID int
+	active map[int]Entry
+	idle   *sync.Cond
+}
+
+func NewTracker() *Tracker {
+	t := &Tracker{active: make(map[int]Entry)}
+	t.idle = sync.NewCond(&t.mu)
+	return t
+}
+
+// Go runs fn in a new goroutine that stays tracked until fn returns
+func (t *Tracker) Go(name string, fn func()) {
+	done := t.Track(name, KindGoroutine)
+	go func() {
+		defer done()
+		fn()
+	}()
+}
+
+// Track registers a resource managed elsewhere (e.g. a listener). The
+// returned func releases it and is safe to call more than once.
+func (t *Tracker) Track(name, kind string) (release func()) {
+	if t == nil {
+		return func() {}
+	}
+
+	t.mu.Lock()
+	t.nextID++
+	id := t.nextID
+	t.active[id] = Entry{ID: id, Name: name, Kind: kind, Started: time.Now()}
+	t.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			delete(t.active, id)
+			t.idle.Broadcast()
+			t.mu.Unlock()
+		})
+	}
+}
+
+// Active returns tracked resources ordered by start
+func (t *Tracker) Active() []Entry {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entries := make([]Entry, 0, len(t.active))
+	for _, e := range t.active {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries
+}
+
+// Count returns the number of tracked resources with the given name prefix
+func (t *Tracker) Count(prefix string) int {
+	n := 0
+	for _, e := range t.Active() {
+		if strings.HasPrefix(e.Name, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+// WaitIdle blocks until nothing is tracked or the timeout expires. On timeout
+// the error lists what is still running.
+func (t *Tracker) WaitIdle(timeout time.Duration) error {
+	if t == nil {
+		return nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	timer := time.AfterFunc(timeout, func() {
+		t.mu.Lock()
+		t.idle.Broadcast()
+		t.mu.Unlock()
+	})
+	defer timer.Stop()
+
+	t.mu.Lock()
+	for len(t.active) > 0 && time.Now().Before(deadline) {
+		t.idle.Wait()
+	}
+	remaining := len(t.active)
+	t.mu.Unlock()
+
+	if remaining == 0 {
+		return nil
+	}
+	var names []string
+	for _, e := range t.Active() {
+		names = append(names, fmt.Sprintf("%s %s", e.Kind, e.Name))
+	}
+	return fmt.Errorf("%d resources still running: %s", len(names), strings.Join(names, ", "))
+}
@@ -0,0 +1,41 @@
+package routine
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrackerWaitIdle(t *testing.T) {
+	tr := NewTracker()
+	stop := make(chan struct{})
+	tr.Go("worker", func() { <-stop })
+	release := tr.Track("api", KindListener)
+
+	if got := tr.Active(); len(got) != 2 || got[0].Name != "worker" || got[1].Kind != KindListener {
+		t.Fatalf("unexpected active entries: %+v", got)
+	}
+
+	err := tr.WaitIdle(20 * time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "goroutine worker") || !strings.Contains(err.Error(), "listener api") {
+		t.Fatalf("expected leak report, got %v", err)
+	}
+
+	close(stop)
+	release()
+	release()
+	if err := tr.WaitIdle(time.Second); err != nil {
+		t.Fatalf("expected idle tracker, got %v", err)
+	}
+}
+
+func TestTrackerNil(t *testing.T) {
+	var tr *Tracker
+	done := make(chan struct{})
+	tr.Go("worker", func() { close(done) })
+	<-done
+	tr.Track("api", KindListener)()
+	if tr.Active() != nil || tr.WaitIdle(0) != nil {
+		t.Fatalf("expected nil tracker to be a no-op")
+	}
+}
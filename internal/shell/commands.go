@@ -1,9 +1,22 @@
 package shell
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/daemon"
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+	"github.com/malindarathnayake/LibraFlux/internal/health"
+	"github.com/malindarathnayake/LibraFlux/internal/ipvs"
+	"github.com/malindarathnayake/LibraFlux/internal/system"
 )
 
 func (s *Shell) handleRoot(tokens []string) error {
@@ -13,11 +26,20 @@ func (s *Shell) handleRoot(tokens []string) error {
 	case "exit":
 		return ErrExitShell
 	case "configure":
-		if err := s.enterConfigureMode(); err != nil {
+		args := tokens[1:]
+		exclusive := len(args) > 0 && strings.EqualFold(args[0], "exclusive")
+		if exclusive {
+			args = args[1:]
+		}
+		intent, rest, err := parseConfigureArgs(args)
+		if err != nil {
 			return err
 		}
-		if len(tokens) > 1 {
-			return s.handleConfig(tokens[1:])
+		if err := s.enterConfigureMode(intent, exclusive); err != nil {
+			return err
+		}
+		if len(rest) > 0 {
+			return s.handleConfig(rest)
 		}
 		return nil
 	case "lock":
@@ -32,27 +54,132 @@ func (s *Shell) handleRoot(tokens []string) error {
 			}
 			if meta == nil {
 				fmt.Fprintln(s.out, "No configuration lock held.")
-				return nil
+			} else {
+				fmt.Fprintf(s.out, "Configuration locked by %s@%s (PID %d)\n", meta.User, meta.Host, meta.PID)
+				s.printLockIntent(meta)
+			}
+			services, err := s.lockManager.ServiceLocks()
+			if err != nil {
+				return err
+			}
+			s.printServiceLocks(services)
+			readers, err := s.lockManager.Readers()
+			if err != nil {
+				return err
 			}
-			fmt.Fprintf(s.out, "Configuration locked by %s@%s (PID %d)\n", meta.User, meta.Host, meta.PID)
+			s.printLockReaders(readers)
 			return nil
 		case "break":
-			force := len(tokens) >= 3 && tokens[2] == "--force"
+			force := tokens[len(tokens)-1] == "--force"
+			if len(tokens) >= 4 && strings.EqualFold(tokens[2], "service") {
+				return s.lockManager.BreakService(tokens[3], force)
+			}
 			return s.lockManager.Break(force)
 		default:
-			return fmt.Errorf("unknown lock command: %s", tokens[1])
+			return unknownCommandf("unknown lock command: %s", tokens[1])
 		}
 	case "show":
-		fmt.Fprintln(s.out, "show: not implemented (daemon integration in Phase 7)")
-		return nil
+		if len(tokens) >= 2 && strings.EqualFold(tokens[1], "services") {
+			return s.showServices(tokens[2:])
+		}
+		if len(tokens) >= 2 && strings.EqualFold(tokens[1], "status") {
+			return s.showStatus()
+		}
+		if len(tokens) >= 2 && strings.EqualFold(tokens[1], "schedule") {
+			return s.showSchedule()
+		}
+		if len(tokens) >= 2 && strings.EqualFold(tokens[1], "ipvs") {
+			return s.showIPVS(tokens[2:])
+		}
+		if len(tokens) >= 2 && strings.EqualFold(tokens[1], "health") {
+			return s.showHealth()
+		}
+		if len(tokens) >= 2 && strings.EqualFold(tokens[1], "system-files") {
+			return s.showSystemFiles(tokens[2:])
+		}
+		if len(tokens) >= 2 {
+			return unknownCommandf("unknown show command: %s", tokens[1])
+		}
+		return s.showDaemon()
 	case "doctor":
-		fmt.Fprintln(s.out, "doctor: not implemented (Phase 7)")
-		return nil
+		if len(tokens) >= 2 && strings.EqualFold(tokens[1], "probes") {
+			cfg, err := config.LoadConfig(s.configPath)
+			if err != nil {
+				return err
+			}
+			return s.doctorProbes(cfg)
+		}
+		return s.doctor()
+	case "observability":
+		if len(tokens) < 2 || !strings.EqualFold(tokens[1], "test") {
+			return usageError("usage: observability test")
+		}
+		cfg, err := config.LoadConfig(s.configPath)
+		if err != nil {
+			return err
+		}
+		return s.observabilityTest(cfg)
+	case "lint":
+		return s.lint()
+	case "schedule":
+		if len(tokens) != 2 || !strings.EqualFold(tokens[1], "cancel") {
+			return usageError("usage: schedule cancel")
+		}
+		return s.cancelSchedule()
+	case "service":
+		if len(tokens) != 3 || !strings.EqualFold(tokens[1], "reload") {
+			return usageError("usage: service reload <name>")
+		}
+		return s.serviceReload(tokens[2])
+	case "status":
+		return s.status()
 	case "reload":
-		fmt.Fprintln(s.out, "reload: not implemented (Phase 7)")
+		switch len(tokens) {
+		case 1:
+			return s.reloadDaemon("")
+		case 2:
+			return s.reloadDaemon(tokens[1])
+		}
+		return usageError("usage: reload [<config-path>]")
+	case "reconcile":
+		if err := s.control.Reconcile(context.Background()); err != nil {
+			return err
+		}
+		fmt.Fprintln(s.out, "Reconcile queued.")
 		return nil
+	case "drain":
+		return s.drain(tokens[1:])
+	case "undrain":
+		if len(tokens) != 3 {
+			return usageError("usage: undrain <service> <backend>")
+		}
+		return s.undrain(tokens[1], tokens[2])
+	case "backend":
+		switch {
+		case len(tokens) >= 2 && strings.EqualFold(tokens[1], "drain"):
+			return s.drain(tokens[2:])
+		case len(tokens) == 4 && strings.EqualFold(tokens[1], "restore"):
+			return s.undrain(tokens[2], tokens[3])
+		}
+		return usageError("usage: backend drain <service> <backend> [--ttl <duration>] | backend restore <service> <backend>")
+	case "log-level":
+		if len(tokens) != 2 {
+			return usageError("usage: log-level <debug|info|warn|error>")
+		}
+		if err := s.control.SetLogLevel(context.Background(), tokens[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out, "Daemon log level set to %s until it restarts.\n", strings.ToLower(tokens[1]))
+		return nil
+	case "maintenance":
+		return s.maintenance(tokens[1:])
+	case "install":
+		if len(tokens) < 2 || !strings.EqualFold(tokens[1], "systemd") {
+			return usageError("usage: install systemd [--binary <path>]")
+		}
+		return s.installSystemd(tokens[2:])
 	default:
-		return fmt.Errorf("unknown command: %s", tokens[0])
+		return unknownCommandf("unknown command: %s", tokens[0])
 	}
 }
 
@@ -72,20 +199,41 @@ func (s *Shell) handleConfig(tokens []string) error {
 		return nil
 	case "commit":
 		return s.configMode.Commit(s)
+	case "apply":
+		if len(tokens) != 3 || !strings.EqualFold(tokens[1], "at") {
+			return usageError("usage: apply at <HH:MM|YYYY-MM-DDTHH:MM|RFC3339>")
+		}
+		at, err := parseActivationTime(s.clock.Now(), tokens[2])
+		if err != nil {
+			return err
+		}
+		return s.configMode.Schedule(s, at)
 	case "show":
 		return s.configMode.ShowPending(s)
 	case "service":
 		if len(tokens) < 2 {
-			return errors.New("usage: service <name>")
+			return usageError("usage: service <name>")
 		}
 		return s.enterServiceMode(tokens[1])
 	case "delete":
 		if len(tokens) < 2 {
-			return errors.New("usage: delete <service>")
+			return usageError("usage: delete <service>")
 		}
 		return s.configMode.DeleteService(tokens[1])
+	case "doctor":
+		if len(tokens) < 2 || !strings.EqualFold(tokens[1], "probes") {
+			return usageError("usage: doctor probes")
+		}
+		cfg, err := s.configMode.Candidate()
+		if err != nil {
+			return err
+		}
+		if err := config.Validate(cfg); err != nil {
+			return err
+		}
+		return s.doctorProbes(cfg)
 	default:
-		return fmt.Errorf("unknown configure command: %s", tokens[0])
+		return unknownCommandf("unknown configure command: %s", tokens[0])
 	}
 }
 
@@ -104,3 +252,843 @@ func (s *Shell) handleService(tokens []string) error {
 	}
 }
 
+// showServices lists configured services, optionally filtered by
+// --selector key=value[,key=value...]
+func (s *Shell) showServices(args []string) error {
+	var expr string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--selector" && i+1 < len(args):
+			i++
+			expr = args[i]
+		case strings.HasPrefix(args[i], "--selector="):
+			expr = strings.TrimPrefix(args[i], "--selector=")
+		default:
+			return usageError("usage: show services [--selector key=value[,key=value...]]")
+		}
+	}
+	sel, err := config.ParseSelector(expr)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(s.configPath)
+	if err != nil {
+		return err
+	}
+	for _, svc := range cfg.Services {
+		if !sel.Matches(svc.Labels) {
+			continue
+		}
+		line := fmt.Sprintf("%s %s backends=%d", svc.Name, svc.Protocol, len(svc.Backends))
+		if len(svc.Labels) > 0 {
+			line += " " + config.Selector(svc.Labels).String()
+		}
+		fmt.Fprintln(s.out, line)
+	}
+	return nil
+}
+
+// showIPVS prints the kernel's IPVS table, like ipvsadm -Ln, or as JSON with
+// --json. The table comes from the daemon, which already holds a netlink
+// handle; only when it isn't running does the shell read IPVS itself.
+func (s *Shell) showIPVS(args []string) error {
+	asJSON := len(args) == 1 && args[0] == "--json"
+	if len(args) > 0 && !asJSON {
+		return usageError("usage: show ipvs [--json]")
+	}
+
+	snap, err := s.control.IPVS(context.Background())
+	if errdefs.CodeOf(err) == errdefs.CodeDaemonUnreachable {
+		snap, err = s.readIPVS()
+	}
+	if snap == nil {
+		return err
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(s.out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(snap); err != nil {
+			return err
+		}
+		return err
+	}
+	if len(snap.Services) == 0 {
+		fmt.Fprintln(s.out, "No IPVS services.")
+	}
+	for _, svc := range snap.Services {
+		line := fmt.Sprintf("%s %s %s", strings.ToUpper(svc.Protocol), net.JoinHostPort(svc.Address, strconv.Itoa(int(svc.Port))), svc.Scheduler)
+		if len(svc.Flags) > 0 {
+			line += " " + strings.Join(svc.Flags, ",")
+		}
+		if svc.PersistenceTimeout > 0 {
+			line += fmt.Sprintf(" persistent %d", svc.PersistenceTimeout)
+			if svc.PersistenceNetmask != "" {
+				line += " mask " + svc.PersistenceNetmask
+			}
+		}
+		fmt.Fprintf(s.out, "%s conns=%d cps=%d\n", line, svc.Stats.Connections, svc.Stats.CPS)
+		for _, d := range svc.Destinations {
+			fmt.Fprintf(s.out, "  -> %s %s weight=%d active=%d inactive=%d\n",
+				net.JoinHostPort(d.Address, strconv.Itoa(int(d.Port))), d.Forward, d.Weight, d.ActiveConns, d.InactiveConns)
+		}
+	}
+	return err
+}
+
+// readIPVS snapshots the IPVS table without the daemon
+func (s *Shell) readIPVS() (*ipvs.Snapshot, error) {
+	m := s.ipvs
+	if m == nil {
+		rm, err := ipvs.NewManager()
+		if err != nil {
+			return nil, err
+		}
+		defer rm.Close()
+		m = rm
+	}
+	return ipvs.TakeSnapshot(m)
+}
+
+// showSystemFiles prints the FRR block and sysctl file the on-disk config
+// renders, without writing them.
+func (s *Shell) showSystemFiles(args []string) error {
+	asJSON := len(args) == 1 && args[0] == "--json"
+	if len(args) > 0 && !asJSON {
+		return usageError("usage: show system-files [--json]")
+	}
+	cfg, err := config.LoadConfig(s.configPath)
+	if err != nil {
+		return err
+	}
+	files := system.Render(cfg)
+	if asJSON {
+		enc := json.NewEncoder(s.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(files)
+	}
+	for i, f := range files {
+		if i > 0 {
+			fmt.Fprintln(s.out)
+		}
+		path := f.Path
+		if path == "" {
+			path = "(not written)"
+		}
+		fmt.Fprintf(s.out, "# %s: %s\n%s", f.Kind, path, f.Content)
+	}
+	return nil
+}
+
+// doctorProbes runs every configured health check once and prints one line
+// per backend. It fails if any probe fails.
+func (s *Shell) doctorProbes(cfg *config.Config) error {
+	results := daemon.ProbeAll(cfg)
+	if len(results) == 0 {
+		fmt.Fprintln(s.out, "No health checks configured.")
+		return nil
+	}
+
+	failed := 0
+	for _, r := range results {
+		status := "OK"
+		if r.Err != nil {
+			status = "FAIL"
+			failed++
+		}
+		line := fmt.Sprintf("%s %s (%s:%d) %s %s", r.Key.Service, r.Key.Backend, r.Address, r.Port, status, r.Latency.Round(time.Microsecond))
+		if r.Err != nil {
+			line += ": " + r.Err.Error()
+		}
+		fmt.Fprintln(s.out, line)
+	}
+	fmt.Fprintf(s.out, "%d/%d probes passed\n", len(results)-failed, len(results))
+	if failed > 0 {
+		return fmt.Errorf("%d health probe(s) failed", failed)
+	}
+	return nil
+}
+
+// observabilityTest exercises every enabled telemetry sink and prints one
+// line per sink. It fails if any sink is unreachable.
+func (s *Shell) observabilityTest(cfg *config.Config) error {
+	results := daemon.CheckSinks(context.Background(), cfg)
+	if len(results) == 0 {
+		fmt.Fprintln(s.out, "No telemetry sinks configured.")
+		return nil
+	}
+
+	failed := 0
+	for _, r := range results {
+		status := "OK"
+		if r.Err != nil {
+			status = "FAIL"
+			failed++
+		}
+		line := fmt.Sprintf("%s %s %s %s", r.Sink, r.Target, status, r.Latency.Round(time.Microsecond))
+		if r.Err != nil {
+			line += ": " + r.Err.Error()
+		}
+		fmt.Fprintln(s.out, line)
+	}
+	fmt.Fprintf(s.out, "%d/%d sinks reachable\n", len(results)-failed, len(results))
+	if failed > 0 {
+		return fmt.Errorf("%d telemetry sink(s) unreachable", failed)
+	}
+	return nil
+}
+
+// parseActivationTime parses the time of "apply at". A bare HH:MM is its
+// next occurrence after now, in now's location.
+func parseActivationTime(now time.Time, arg string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, arg); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04", arg, now.Location()); err == nil {
+		return t, nil
+	}
+	hm, err := time.Parse("15:04", arg)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid activation time %q: want HH:MM, YYYY-MM-DDTHH:MM or RFC3339", arg)
+	}
+	at := time.Date(now.Year(), now.Month(), now.Day(), hm.Hour(), hm.Minute(), 0, 0, now.Location())
+	if !at.After(now) {
+		at = at.AddDate(0, 0, 1)
+	}
+	return at, nil
+}
+
+// showSchedule prints the change bundle waiting for activation, if any.
+func (s *Shell) showSchedule() error {
+	change, err := config.ReadScheduledChange(s.configDir)
+	if err != nil {
+		return err
+	}
+	if change == nil {
+		fmt.Fprintln(s.out, "No change scheduled.")
+		return nil
+	}
+	now := s.clock.Now()
+	fmt.Fprintf(s.out, "Change scheduled for %s", change.At.In(now.Location()).Format("2006-01-02 15:04 MST"))
+	if d := change.At.Sub(now); d > 0 {
+		fmt.Fprintf(s.out, " (in %s)", d.Round(time.Minute))
+	} else {
+		fmt.Fprint(s.out, " (due; the daemon activates it on its next tick)")
+	}
+	fmt.Fprintln(s.out)
+	by := change.CreatedBy
+	if by == "" {
+		by = "unknown"
+	}
+	fmt.Fprintf(s.out, "  Staged by %s at %s on generation %d\n", by, change.CreatedAt.In(now.Location()).Format("2006-01-02 15:04 MST"), change.BaseGeneration)
+	for _, svc := range change.Services {
+		fmt.Fprintf(s.out, "  ~ service %s\n", svc.Name)
+	}
+	for _, name := range change.Deleted {
+		fmt.Fprintf(s.out, "  - service %s (deleted)\n", name)
+	}
+	return nil
+}
+
+// cancelSchedule drops the change bundle waiting for activation.
+func (s *Shell) cancelSchedule() error {
+	cancelled, err := config.CancelScheduledChange(s.configDir)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		fmt.Fprintln(s.out, "No change scheduled.")
+		return nil
+	}
+	fmt.Fprintln(s.out, "Scheduled change cancelled.")
+	return nil
+}
+
+// lint validates the config on disk and prints one line per best-practice
+// finding. It fails if the config is invalid or anything is flagged.
+func (s *Shell) lint() error {
+	cfg, err := config.LoadConfig(s.configPath)
+	if err != nil {
+		return err
+	}
+	if err := config.Validate(cfg); err != nil {
+		return err
+	}
+	findings := config.Lint(cfg)
+	for _, f := range findings {
+		fmt.Fprintln(s.out, f)
+	}
+	if len(findings) > 0 {
+		return fmt.Errorf("%d lint finding(s); suppress a rule with lint_ignore", len(findings))
+	}
+	fmt.Fprintf(s.out, "%d services checked, no findings\n", len(cfg.Services))
+	return nil
+}
+
+// serviceReload validates one service from the config on disk and asks the
+// daemon to reload just that service. A service no longer on disk is removed
+// by the daemon.
+func (s *Shell) serviceReload(name string) error {
+	cfg, err := config.LoadConfig(s.configPath)
+	if err != nil {
+		return err
+	}
+	removed := true
+	for _, svc := range cfg.Services {
+		if svc.Name != name {
+			continue
+		}
+		// The daemon keeps every other service as running, so only this one is checked
+		single := *cfg
+		single.Services = []config.Service{svc}
+		if err := config.Validate(&single); err != nil {
+			return err
+		}
+		removed = false
+	}
+	if err := config.RequestServiceReload(s.configDir, name); err != nil {
+		return err
+	}
+	if removed {
+		fmt.Fprintf(s.out, "Service %s is not in %s; the daemon will remove it on its next reconcile tick.\n", name, s.configDir)
+		return nil
+	}
+	fmt.Fprintf(s.out, "Reload of service %s requested; the daemon applies it on its next reconcile tick.\n", name)
+	return nil
+}
+
+// installSystemd writes the unit and tmpfiles snippet for the daemon, with
+// ReadWritePaths derived from the current config.
+func (s *Shell) installSystemd(args []string) error {
+	opts := system.SystemdUnitOptions{ConfigPath: s.configPath}
+	for len(args) > 0 {
+		if !strings.EqualFold(args[0], "--binary") || len(args) < 2 {
+			return usageError("usage: install systemd [--binary <path>]")
+		}
+		opts.BinaryPath = args[1]
+		args = args[2:]
+	}
+	if abs, err := filepath.Abs(opts.ConfigPath); err == nil {
+		opts.ConfigPath = abs
+	}
+	cfg, err := config.LoadConfig(s.configPath)
+	if err != nil {
+		return err
+	}
+	if err := s.systemd.Install(cfg, opts); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "Wrote %s and %s.\n", s.systemd.UnitPath, s.systemd.TmpfilesPath)
+	fmt.Fprintln(s.out, "Run `systemctl daemon-reload` and `systemd-tmpfiles --create` to activate them.")
+	return nil
+}
+
+// parseConfigureArgs consumes leading --reason and --duration flags from the
+// configure command and returns the remaining tokens.
+func parseConfigureArgs(args []string) (LockIntent, []string, error) {
+	var intent LockIntent
+	for len(args) > 0 {
+		switch strings.ToLower(args[0]) {
+		case "--reason":
+			if len(args) < 2 || strings.TrimSpace(args[1]) == "" {
+				return LockIntent{}, nil, errors.New("--reason requires a value")
+			}
+			intent.Reason = args[1]
+		case "--duration":
+			if len(args) < 2 {
+				return LockIntent{}, nil, errors.New("--duration requires a value")
+			}
+			d, err := time.ParseDuration(args[1])
+			if err != nil {
+				return LockIntent{}, nil, fmt.Errorf("invalid --duration %q: %w", args[1], err)
+			}
+			if d <= 0 {
+				return LockIntent{}, nil, fmt.Errorf("invalid --duration %q: must be positive", args[1])
+			}
+			intent.Duration = d
+		default:
+			if strings.HasPrefix(args[0], "--") {
+				return LockIntent{}, nil, fmt.Errorf("unknown configure option: %s", args[0])
+			}
+			return intent, args, nil
+		}
+		args = args[2:]
+	}
+	return intent, nil, nil
+}
+
+func (s *Shell) printLockIntent(meta *LockMetadata) {
+	if meta.Reason != "" {
+		fmt.Fprintf(s.out, "  Reason:   %s\n", meta.Reason)
+	}
+	if meta.StartedAt.IsZero() {
+		return
+	}
+	held := s.clock.Now().UTC().Sub(meta.StartedAt).Round(time.Second)
+	if meta.PlannedDuration <= 0 {
+		fmt.Fprintf(s.out, "  Held for: %s\n", held)
+		return
+	}
+	planned := meta.PlannedDuration.Round(time.Second)
+	until := meta.StartedAt.Add(meta.PlannedDuration).Format(time.RFC3339)
+	if remaining := planned - held; remaining >= 0 {
+		fmt.Fprintf(s.out, "  Held for: %s of planned %s (%s remaining, until %s)\n", held, planned, remaining, until)
+	} else {
+		fmt.Fprintf(s.out, "  Held for: %s of planned %s (overrun by %s, expected until %s)\n", held, planned, -remaining, until)
+	}
+}
+
+func (s *Shell) printServiceLocks(locks []ServiceLock) {
+	if len(locks) == 0 {
+		return
+	}
+	fmt.Fprintf(s.out, "Services locked (%d):\n", len(locks))
+	now := s.clock.Now().UTC()
+	for _, l := range locks {
+		fmt.Fprintf(s.out, "  %s by %s@%s (PID %d), held for %s", l.Service, l.User, l.Host, l.PID, now.Sub(l.StartedAt).Round(time.Second))
+		if l.Reason != "" {
+			fmt.Fprintf(s.out, ", reason %q", l.Reason)
+		}
+		fmt.Fprintln(s.out)
+	}
+}
+
+func (s *Shell) printLockReaders(readers []LockMetadata) {
+	if len(readers) == 0 {
+		return
+	}
+	fmt.Fprintf(s.out, "Read-only sessions (%d):\n", len(readers))
+	now := s.clock.Now().UTC()
+	for _, r := range readers {
+		fmt.Fprintf(s.out, "  %s@%s (PID %d, tty %s), since %s\n", r.User, r.Host, r.PID, r.TTY, now.Sub(r.StartedAt).Round(time.Second))
+	}
+}
+
+// showStatus reports the daemon's lifecycle state and compares the generation
+// committed to config.d with the one it last applied, so operators can tell
+// when a commit is not live yet.
+func (s *Shell) showStatus() error {
+	onDisk, err := config.ReadGeneration(s.configDir)
+	if err != nil {
+		return err
+	}
+	applied, err := config.ReadAppliedGeneration(s.stateDir)
+	if err != nil {
+		return err
+	}
+	engine, err := daemon.ReadEngineStatus(s.stateDir)
+	if err != nil {
+		return err
+	}
+
+	if engine == nil {
+		fmt.Fprintln(s.out, "Engine state:              unknown (daemon has not reported)")
+	} else {
+		fmt.Fprintf(s.out, "Engine state:              %s (since %s)\n", engine.State, engine.Since.Format(time.RFC3339))
+	}
+	if st, err := s.control.Status(context.Background()); err != nil {
+		fmt.Fprintf(s.out, "Daemon:                    not reachable (%v)\n", err)
+	} else {
+		s.printStatusSnapshot(st)
+	}
+
+	fmt.Fprintf(s.out, "Config generation on disk: %d", onDisk)
+	if onDisk%2 == 1 {
+		fmt.Fprint(s.out, " (commit in progress)")
+	}
+	fmt.Fprintln(s.out)
+	if applied == nil {
+		fmt.Fprintln(s.out, "Applied generation:        unknown (daemon has not reported)")
+		return nil
+	}
+	fmt.Fprintf(s.out, "Applied generation:        %d (reported %s)\n", applied.Generation, applied.Time.Format(time.RFC3339))
+	if applied.Rejected > 0 {
+		fmt.Fprintf(s.out, "Last reload rejected generation %d: %s\n", applied.Rejected, applied.Error)
+	}
+	if onDisk != applied.Generation {
+		fmt.Fprintln(s.out, "WARNING: on-disk config differs from the config the daemon is running; reload to apply.")
+	}
+	return nil
+}
+
+// printStatusSnapshot prints the parts of the daemon's status snapshot that
+// show status adds to the generations on disk.
+func (s *Shell) printStatusSnapshot(st *daemon.DaemonStatus) {
+	role := "standby"
+	if st.Active {
+		role = "active (VIP owner)"
+	}
+	fmt.Fprintf(s.out, "Node:                      %s (%s), %s\n", st.Node, st.Role, role)
+	if !st.StartedAt.IsZero() {
+		uptime := time.Duration(st.UptimeSeconds) * time.Second
+		fmt.Fprintf(s.out, "Uptime:                    %s (since %s)\n", uptime, st.StartedAt.Format(time.RFC3339))
+	}
+	if st.ConfigPath != "" {
+		fmt.Fprintf(s.out, "Config file:               %s\n", st.ConfigPath)
+	}
+	fmt.Fprintf(s.out, "Config hash:               %s\n", st.ConfigHash)
+	switch last := st.LastReconcile; {
+	case last == nil:
+		fmt.Fprintln(s.out, "Last reconcile:            none yet")
+	case last.Error != "":
+		fmt.Fprintf(s.out, "Last reconcile:            failed at %s: %s\n", last.Time.Format(time.RFC3339), last.Error)
+	default:
+		fmt.Fprintf(s.out, "Last reconcile:            ok at %s\n", last.Time.Format(time.RFC3339))
+	}
+	b := st.Backends
+	fmt.Fprintf(s.out, "Backends:                  %d/%d healthy, %d unhealthy, %d unknown\n", b.Healthy, b.Total, b.Unhealthy, b.Unknown)
+
+	var pending []string
+	if p := st.Pending; p.Reconcile != "" {
+		pending = append(pending, "reconcile ("+p.Reconcile+")")
+	}
+	if !st.Pending.RetryAt.IsZero() {
+		pending = append(pending, "retry at "+st.Pending.RetryAt.Format(time.RFC3339))
+	}
+	if st.Pending.Stalled {
+		pending = append(pending, "waiting for a config reload")
+	}
+	if n := st.Pending.Draining; n > 0 {
+		pending = append(pending, fmt.Sprintf("%d destinations draining", n))
+	}
+	if len(pending) == 0 {
+		pending = []string{"none"}
+	}
+	fmt.Fprintf(s.out, "Pending:                   %s\n", strings.Join(pending, ", "))
+}
+
+// showDaemon prints the running daemon's state, asked over its control
+// socket.
+func (s *Shell) showDaemon() error {
+	st, err := s.control.Status(context.Background())
+	if err != nil {
+		return err
+	}
+	state := string(st.State)
+	if !st.StateSince.IsZero() {
+		state += " (since " + st.StateSince.Format(time.RFC3339) + ")"
+	}
+	fmt.Fprintf(s.out, "Node:        %s\n", st.Node)
+	fmt.Fprintf(s.out, "State:       %s\n", state)
+	fmt.Fprintf(s.out, "VIP owner:   %s\n", yesNo(st.Active))
+	fmt.Fprintf(s.out, "Ready:       %s\n", yesNo(st.Ready))
+	fmt.Fprintf(s.out, "Generation:  %d\n", st.Generation)
+	fmt.Fprintf(s.out, "Services:    %d\n", st.Services)
+	fmt.Fprintf(s.out, "Log level:   %s\n", st.LogLevel)
+	if st.Maintenance {
+		fmt.Fprintf(s.out, "Maintenance: yes (since %s)\n", st.MaintenanceSince.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// maintenance takes the node out of rotation or puts it back.
+func (s *Shell) maintenance(args []string) error {
+	if len(args) != 1 {
+		return usageError("usage: maintenance <on|off>")
+	}
+	var enabled bool
+	switch strings.ToLower(args[0]) {
+	case "on":
+		enabled = true
+	case "off":
+	default:
+		return usageError("usage: maintenance <on|off>")
+	}
+	if err := s.control.SetMaintenance(context.Background(), enabled); err != nil {
+		return err
+	}
+	if enabled {
+		fmt.Fprintln(s.out, "Node in maintenance: acting as standby with the lowest VRRP priority.")
+	} else {
+		fmt.Fprintln(s.out, "Node back in rotation.")
+	}
+	return nil
+}
+
+// showHealth prints every backend's health as the daemon last saw it.
+func (s *Shell) showHealth() error {
+	backends, err := s.control.BackendHealth(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(backends) == 0 {
+		fmt.Fprintln(s.out, "No backends configured.")
+		return nil
+	}
+	for _, b := range backends {
+		line := fmt.Sprintf("%s %s %s weight=%d", b.Service, b.Backend, b.State, b.Weight)
+		if b.Override != health.OverrideNone {
+			line += " override=" + string(b.Override)
+		}
+		fmt.Fprintln(s.out, line)
+	}
+	return nil
+}
+
+// doctor checks the running daemon: that it answers, isn't stuck retrying
+// reconciles, runs the config on disk, and has healthy backends. It prints
+// one line per check and fails if any check fails.
+func (s *Shell) doctor() error {
+	type check struct {
+		name   string
+		err    error
+		detail string
+	}
+	var checks []check
+
+	st, err := s.control.Status(context.Background())
+	if err != nil {
+		checks = append(checks, check{name: "daemon", err: err})
+	} else {
+		checks = append(checks, check{name: "daemon", detail: fmt.Sprintf("%s, %s", st.Node, st.State)})
+
+		c := check{name: "reconcile", detail: "IPVS matches config"}
+		switch st.State {
+		case daemon.StateDegradedBackoff:
+			c.err = errors.New("reconciles are failing; see the daemon log")
+		case daemon.StateStandby:
+			c.detail = "standby; nothing to program"
+		case daemon.StateActivating, daemon.StateDraining:
+			c.detail = string(st.State)
+		}
+		checks = append(checks, c)
+
+		c = check{name: "config", detail: fmt.Sprintf("generation %d", st.Generation)}
+		if onDisk, err := config.ReadGeneration(s.configDir); err != nil {
+			c.err = err
+		} else if onDisk != st.Generation {
+			c.err = fmt.Errorf("daemon runs generation %d, disk has %d; reload to apply", st.Generation, onDisk)
+		}
+		checks = append(checks, c)
+
+		if backends, err := s.control.BackendHealth(context.Background()); err != nil {
+			checks = append(checks, check{name: "backends", err: err})
+		} else {
+			var unhealthy []string
+			for _, b := range backends {
+				if b.State == health.StateUnhealthy {
+					unhealthy = append(unhealthy, b.Service+"/"+b.Backend)
+				}
+			}
+			c = check{name: "backends", detail: fmt.Sprintf("%d/%d healthy or unchecked", len(backends)-len(unhealthy), len(backends))}
+			if len(unhealthy) > 0 {
+				c.err = fmt.Errorf("unhealthy: %s", strings.Join(unhealthy, ", "))
+			}
+			checks = append(checks, c)
+		}
+	}
+
+	failed := 0
+	for _, c := range checks {
+		if c.err != nil {
+			failed++
+			fmt.Fprintf(s.out, "%s FAIL: %s\n", c.name, c.err)
+			continue
+		}
+		fmt.Fprintf(s.out, "%s OK %s\n", c.name, c.detail)
+	}
+	fmt.Fprintf(s.out, "%d/%d checks passed\n", len(checks)-failed, len(checks))
+	if failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+	return nil
+}
+
+// Exit codes of lbctl status, as monitoring plugins use them.
+const (
+	StatusOK       = 0
+	StatusDegraded = 1 // Serving, with something an operator should look at
+	StatusCritical = 2 // Reconciles failing, or a service with no healthy backend
+	StatusUnknown  = 3 // Daemon unreachable
+)
+
+// StatusError is returned by status when the node isn't fully healthy. Code
+// is the exit code lbctl status exits with.
+type StatusError struct {
+	Code     int
+	Problems []string
+}
+
+func (e *StatusError) Error() string {
+	return e.level() + ": " + strings.Join(e.Problems, "; ")
+}
+
+func (e *StatusError) level() string {
+	switch e.Code {
+	case StatusOK:
+		return "ok"
+	case StatusCritical:
+		return "critical"
+	case StatusUnknown:
+		return "unknown"
+	default:
+		return "degraded"
+	}
+}
+
+// ExitCode is the process exit code for err returned by a one-shot command:
+// the StatusError code, 1 for other errors, or 0.
+func ExitCode(err error) int {
+	var st *StatusError
+	switch {
+	case err == nil:
+		return StatusOK
+	case errors.As(err, &st):
+		return st.Code
+	default:
+		return 1
+	}
+}
+
+// status prints a short summary of the node for scripts and login banners:
+// its role, VIP ownership, each service's backends and the last reconcile.
+// Anything that needs attention makes it return a StatusError.
+func (s *Shell) status() error {
+	ctx := context.Background()
+	st, err := s.control.Status(ctx)
+	if err != nil {
+		return &StatusError{Code: StatusUnknown, Problems: []string{err.Error()}}
+	}
+	services, err := s.control.Services(ctx)
+	if err != nil {
+		return &StatusError{Code: StatusUnknown, Problems: []string{err.Error()}}
+	}
+	backends, err := s.control.BackendHealth(ctx)
+	if err != nil {
+		return &StatusError{Code: StatusUnknown, Problems: []string{err.Error()}}
+	}
+
+	result := &StatusError{Code: StatusOK}
+	flag := func(code int, problem string) {
+		result.Code = max(result.Code, code)
+		result.Problems = append(result.Problems, problem)
+	}
+
+	fmt.Fprintf(s.out, "Node:           %s (%s)\n", st.Node, st.Role)
+	fmt.Fprintf(s.out, "State:          %s\n", st.State)
+	fmt.Fprintf(s.out, "VIP owner:      %s\n", yesNo(st.Active))
+	switch st.State {
+	case daemon.StateDegradedBackoff:
+		flag(StatusCritical, "reconciles are failing")
+	case daemon.StateActivating, daemon.StateDraining:
+		flag(StatusDegraded, "node is "+string(st.State))
+	}
+	if st.Maintenance {
+		fmt.Fprintf(s.out, "Maintenance:    yes (since %s)\n", st.MaintenanceSince.Format(time.RFC3339))
+		flag(StatusDegraded, "node in maintenance")
+	}
+	if !st.Ready {
+		flag(StatusDegraded, "daemon not ready")
+	}
+
+	switch last := st.LastReconcile; {
+	case last == nil:
+		fmt.Fprintln(s.out, "Last reconcile: none yet")
+	case last.Error != "":
+		fmt.Fprintf(s.out, "Last reconcile: failed at %s: %s\n", last.Time.Format(time.RFC3339), last.Error)
+		if st.State != daemon.StateDegradedBackoff {
+			flag(StatusDegraded, "last reconcile failed")
+		}
+	default:
+		fmt.Fprintf(s.out, "Last reconcile: ok at %s\n", last.Time.Format(time.RFC3339))
+	}
+
+	unhealthy := make(map[string]int)
+	for _, b := range backends {
+		if b.State == health.StateUnhealthy {
+			unhealthy[b.Service]++
+		}
+	}
+	if len(services) > 0 {
+		fmt.Fprintln(s.out, "Services:")
+	}
+	for _, svc := range services {
+		line := fmt.Sprintf("  %s %d/%d healthy", svc.Name, svc.Healthy, svc.Backends)
+		if n := unhealthy[svc.Name]; n > 0 {
+			line += fmt.Sprintf(", %d unhealthy", n)
+			if n == svc.Backends && st.Active {
+				flag(StatusCritical, svc.Name+" has no healthy backend")
+			} else {
+				flag(StatusDegraded, fmt.Sprintf("%s has %d unhealthy backend(s)", svc.Name, n))
+			}
+		}
+		if svc.Fallback {
+			line += ", serving fallback backends"
+			flag(StatusDegraded, svc.Name+" below health.min_healthy")
+		}
+		fmt.Fprintln(s.out, line)
+	}
+
+	fmt.Fprintf(s.out, "Status:         %s\n", strings.ToUpper(result.level()))
+	if result.Code == StatusOK {
+		return nil
+	}
+	return result
+}
+
+// reloadDaemon has the daemon reload its config, or switch to the config
+// file at path, and reports the generation it runs afterwards.
+func (s *Shell) reloadDaemon(path string) error {
+	if path == "" {
+		gen, err := s.control.Reload(context.Background())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out, "Daemon reloaded; running generation %d.\n", gen)
+		return nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	gen, err := s.control.ReloadFrom(context.Background(), abs)
+	if err != nil {
+		return fmt.Errorf("config switch rejected, daemon keeps its current config: %w", err)
+	}
+	fmt.Fprintf(s.out, "Daemon switched to %s; running generation %d.\n", abs, gen)
+	return nil
+}
+
+// drain sets a backend's weight to 0 so it takes no new connections while its
+// health checks keep running.
+func (s *Shell) drain(args []string) error {
+	const usage = "usage: drain <service> <backend> [--ttl <duration>]"
+	var ttl time.Duration
+	switch {
+	case len(args) == 2:
+	case len(args) == 4 && args[2] == "--ttl":
+		d, err := time.ParseDuration(args[3])
+		if err != nil || d <= 0 {
+			return usageError(usage)
+		}
+		ttl = d
+	default:
+		return usageError(usage)
+	}
+	if err := s.control.SetOverride(context.Background(), args[0], args[1], health.OverrideDrain, ttl); err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("Backend %s of %s drained", args[1], args[0])
+	if ttl > 0 {
+		msg += " for " + ttl.String()
+	}
+	fmt.Fprintln(s.out, msg+".")
+	return nil
+}
+
+// undrain clears a backend's override, returning it to service.
+func (s *Shell) undrain(service, backend string) error {
+	if err := s.control.SetOverride(context.Background(), service, backend, health.OverrideNone, 0); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "Backend %s of %s no longer drained.\n", backend, service)
+	return nil
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -3,6 +3,8 @@ package shell
 import (
 	"sort"
 	"strings"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
 )
 
 func (s *Shell) Complete(line string) []string {
@@ -20,11 +22,14 @@ func (s *Shell) completeForMode(tokens []string, hasTrailingSpace bool) []string
 	var words []string
 	switch s.mode {
 	case ModeConfig:
-		words = []string{"service", "delete", "commit", "abort", "show", "exit", "help", "?"}
+		words = []string{"service", "delete", "commit", "apply", "abort", "show", "doctor", "exit", "help", "?"}
 	case ModeService:
-		words = []string{"protocol", "ports", "port-range", "scheduler", "backend", "no", "health", "show", "exit", "help", "?"}
+		words = []string{"protocol", "ports", "port-range", "scheduler", "backend", "label", "no", "health", "show", "exit", "help", "?"}
 	default:
-		words = []string{"configure", "show", "doctor", "reload", "lock", "exit", "help", "?"}
+		words = []string{"configure", "status", "show", "doctor", "observability", "lint", "schedule", "service", "reload", "reconcile", "drain", "undrain", "backend", "log-level", "maintenance", "install", "lock", "exit", "help", "?"}
+	}
+	if s.mode == ModeService && len(tokens) > 0 && strings.EqualFold(tokens[0], "scheduler") {
+		words = completeScheduler(tokens, hasTrailingSpace)
 	}
 
 	prefix := ""
@@ -42,3 +47,24 @@ func (s *Shell) completeForMode(tokens []string, hasTrailingSpace bool) []string
 	return out
 }
 
+// completeScheduler completes "scheduler <name> [flag ...]": the scheduler
+// name first, then the flags that apply to it.
+func completeScheduler(tokens []string, hasTrailingSpace bool) []string {
+	arg := len(tokens) - 1
+	if hasTrailingSpace {
+		arg++
+	}
+	switch {
+	case arg == 0:
+		return []string{"scheduler"}
+	case arg == 1:
+		return config.IPVSSchedulers
+	}
+	var flags []string
+	for flag, sched := range config.IPVSSchedulerFlags {
+		if strings.EqualFold(sched, tokens[1]) {
+			flags = append(flags, flag)
+		}
+	}
+	return flags
+}
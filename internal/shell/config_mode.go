@@ -1,6 +1,8 @@
 package shell
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"os"
@@ -10,6 +12,7 @@ import (
 	"time"
 
 	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
 )
 
 type ConfigMode struct {
@@ -17,29 +20,136 @@ type ConfigMode struct {
 	configDir   string
 	idleTimeout time.Duration
 
-	lock    *HeldLock
-	base    *config.Config
-	staged  map[string]config.Service
-	deleted map[string]bool
+	// An exclusive session holds lock, the configuration lock. Otherwise the
+	// session locks each service from locks as it first edits it.
+	lock         *HeldLock
+	locks        *LockManager
+	intent       LockIntent
+	services     map[string]*HeldLock
+	id           LockIdentity
+	lastActivity time.Time
+
+	base     *config.Config
+	baseMain []byte // Digest of config.yaml when base was loaded
+	staged   map[string]config.Service
+	deleted  map[string]bool
 }
 
-func NewConfigMode(configPath, configDir string, idleTimeout time.Duration, lock *HeldLock) (*ConfigMode, error) {
-	if lock == nil {
+// NewConfigMode starts a configure session. With lock, the session holds the
+// configuration lock; without, it takes service locks from locks, recording
+// intent in them.
+func NewConfigMode(configPath, configDir string, idleTimeout time.Duration, lock *HeldLock, locks *LockManager, intent LockIntent) (*ConfigMode, error) {
+	if lock == nil && locks == nil {
 		return nil, errors.New("lock is required")
 	}
-	base, err := config.LoadConfig(configPath)
-	if err != nil {
-		return nil, err
-	}
-	return &ConfigMode{
+	m := &ConfigMode{
 		configPath:  configPath,
 		configDir:   configDir,
 		idleTimeout: idleTimeout,
 		lock:        lock,
-		base:        base,
+		locks:       locks,
+		intent:      intent,
+		services:    make(map[string]*HeldLock),
+		id:          DefaultIdentity(),
 		staged:      make(map[string]config.Service),
 		deleted:     make(map[string]bool),
-	}, nil
+	}
+	if lock != nil {
+		meta := lock.Metadata()
+		m.id = LockIdentity{PID: meta.PID, User: meta.User, Host: meta.Host, TTY: meta.TTY}
+	}
+	if err := m.loadBase(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// loadBase reads the config the session's pending changes are shown against.
+func (m *ConfigMode) loadBase() error {
+	digest, err := mainDigest(m.configPath)
+	if err != nil {
+		return err
+	}
+	base, err := config.LoadRawConfig(m.configPath)
+	if err != nil {
+		return err
+	}
+	m.base, m.baseMain = base, digest
+	return nil
+}
+
+// mainDigest returns the SHA-256 of the main config file.
+func mainDigest(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+// checkMain fails when config.yaml changed since the session loaded it,
+// which other sessions share, and reloads it so the next attempt goes ahead
+// once the operator has reviewed the changes against it.
+func (m *ConfigMode) checkMain() error {
+	digest, err := mainDigest(m.configPath)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(digest, m.baseMain) {
+		return nil
+	}
+	if err := m.loadBase(); err != nil {
+		return err
+	}
+	return errdefs.WithCode(errdefs.CodeConflict, fmt.Errorf("%s changed since this session loaded it; review the pending changes and retry", filepath.Base(m.configPath)))
+}
+
+// lockService takes name's lock unless the session already holds it or the
+// configuration lock.
+func (m *ConfigMode) lockService(name string) error {
+	if m.lock != nil || m.services[name] != nil {
+		return nil
+	}
+	held, err := m.locks.AcquireService(m.id, name, m.intent)
+	if err != nil {
+		return err
+	}
+	m.services[name] = held
+	return nil
+}
+
+// releaseServices drops the service locks, once their pending changes have
+// been committed, scheduled or aborted.
+func (m *ConfigMode) releaseServices() {
+	for name, held := range m.services {
+		_ = held.Release()
+		delete(m.services, name)
+	}
+}
+
+// Release drops every lock the session holds.
+func (m *ConfigMode) Release() {
+	m.releaseServices()
+	if m.lock != nil {
+		_ = m.lock.Release()
+	}
+}
+
+// Touch records activity at now, in the session and in its locks' metadata.
+func (m *ConfigMode) Touch(now time.Time) {
+	m.lastActivity = now
+	if m.lock != nil {
+		_ = m.lock.UpdateActivity()
+	}
+	for _, held := range m.services {
+		_ = held.UpdateActivity()
+	}
+}
+
+// LastActivity is when the session last ran a command.
+func (m *ConfigMode) LastActivity() time.Time {
+	return m.lastActivity
 }
 
 func (m *ConfigMode) EnterService(name string) (*ServiceMode, error) {
@@ -47,6 +157,9 @@ func (m *ConfigMode) EnterService(name string) (*ServiceMode, error) {
 	if name == "" {
 		return nil, errors.New("service name required")
 	}
+	if err := m.lockService(name); err != nil {
+		return nil, err
+	}
 
 	if m.deleted[name] {
 		delete(m.deleted, name)
@@ -55,7 +168,13 @@ func (m *ConfigMode) EnterService(name string) (*ServiceMode, error) {
 	if svc, ok := m.staged[name]; ok {
 		return NewServiceMode(svc)
 	}
-	for _, svc := range m.base.Services {
+	// Another session may have committed the service since base was loaded.
+	// Its pools stay unexpanded so a commit writes them back as they were.
+	current, err := config.LoadRawConfig(m.configPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range current.Services {
 		if svc.Name == name {
 			return NewServiceMode(svc)
 		}
@@ -85,6 +204,9 @@ func (m *ConfigMode) DeleteService(name string) error {
 	if name == "" {
 		return errors.New("service name required")
 	}
+	if err := m.lockService(name); err != nil {
+		return err
+	}
 	delete(m.staged, name)
 	m.deleted[name] = true
 	return nil
@@ -93,6 +215,7 @@ func (m *ConfigMode) DeleteService(name string) error {
 func (m *ConfigMode) Abort(s *Shell) error {
 	m.staged = make(map[string]config.Service)
 	m.deleted = make(map[string]bool)
+	m.releaseServices()
 	fmt.Fprintln(s.out, "Aborted pending changes.")
 	return nil
 }
@@ -121,10 +244,12 @@ func (m *ConfigMode) ShowPending(s *Shell) error {
 	return nil
 }
 
-func (m *ConfigMode) Commit(s *Shell) error {
-	current, err := config.LoadConfig(m.configPath)
+// Candidate returns the on-disk config with pending changes applied and
+// pools expanded, as the daemon would load it
+func (m *ConfigMode) Candidate() (*config.Config, error) {
+	current, err := config.LoadRawConfig(m.configPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var next []config.Service
@@ -138,20 +263,157 @@ func (m *ConfigMode) Commit(s *Shell) error {
 		next = append(next, svc)
 	}
 	var stagedNames []string
-	for name, svc := range m.staged {
+	for name := range m.staged {
 		stagedNames = append(stagedNames, name)
-		next = append(next, svc)
 	}
 	sort.Strings(stagedNames)
+	for _, name := range stagedNames {
+		next = append(next, m.staged[name])
+	}
 
 	current.Services = next
+	return config.ExpandConfigPools(current)
+}
+
+// lockCommit serializes a commit or schedule with other sessions' and
+// returns the function that ends it. An exclusive session has no session to
+// serialize with, but like every writer it takes the include directory's
+// commit lock, which the daemon holds to activate a scheduled change.
+func (m *ConfigMode) lockCommit() (func(), error) {
+	release := func() {}
+	if m.lock == nil {
+		held, err := m.locks.AcquireCommit(m.id)
+		if err != nil {
+			return nil, err
+		}
+		release = func() { _ = held.Release() }
+	}
+	if err := os.MkdirAll(m.configDir, 0755); err != nil {
+		release()
+		return nil, err
+	}
+	unlockDir, err := config.LockCommit(m.configDir)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return func() {
+		unlockDir()
+		release()
+	}, nil
+}
+
+func (m *ConfigMode) Commit(s *Shell) error {
+	unlock, err := m.lockCommit()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := m.checkMain(); err != nil {
+		return err
+	}
+	current, err := m.Candidate()
+	if err != nil {
+		return err
+	}
 	if err := config.Validate(current); err != nil {
 		return err
 	}
 
-	if err := os.MkdirAll(m.configDir, 0755); err != nil {
+	var stagedNames []string
+	for name := range m.staged {
+		stagedNames = append(stagedNames, name)
+	}
+	sort.Strings(stagedNames)
+
+	inProgress, err := config.BeginCommit(m.configDir)
+	if err != nil {
+		return err
+	}
+	writeErr := m.writeStaged(s, stagedNames)
+	// Publish even after a failed write: the daemon must not stay blocked on
+	// an odd generation, and it validates whatever is on disk anyway.
+	gen, err := config.EndCommit(m.configDir, inProgress)
+	if writeErr != nil {
+		return writeErr
+	}
+	if err != nil {
 		return err
 	}
+
+	m.staged = make(map[string]config.Service)
+	m.deleted = make(map[string]bool)
+	m.releaseServices()
+	fmt.Fprintf(s.out, "Committed (generation %d).\n", gen)
+	return s.awaitReload(gen)
+}
+
+// Schedule stages the pending changes for the daemon to commit at at,
+// instead of committing them now. The candidate config is validated now and
+// again by the daemon at activation.
+func (m *ConfigMode) Schedule(s *Shell, at time.Time) error {
+	if len(m.staged) == 0 && len(m.deleted) == 0 {
+		return errors.New("no pending changes to schedule")
+	}
+	now := s.clock.Now()
+	if !at.After(now) {
+		return fmt.Errorf("activation time %s is not in the future", at.Format(time.RFC3339))
+	}
+	unlock, err := m.lockCommit()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := m.checkMain(); err != nil {
+		return err
+	}
+	current, err := m.Candidate()
+	if err != nil {
+		return err
+	}
+	if err := config.Validate(current); err != nil {
+		return err
+	}
+	// The daemon refuses the bundle if a service it touches changes before
+	// activation
+	base, err := config.LoadConfig(m.configPath)
+	if err != nil {
+		return err
+	}
+
+	change := &config.ScheduledChange{
+		At:             at.UTC(),
+		CreatedAt:      now.UTC(),
+		BaseGeneration: base.Generation,
+	}
+	if m.id.User != "" {
+		change.CreatedBy = m.id.User + "@" + m.id.Host
+	}
+	var stagedNames []string
+	for name := range m.staged {
+		stagedNames = append(stagedNames, name)
+	}
+	sort.Strings(stagedNames)
+	for _, name := range stagedNames {
+		change.Services = append(change.Services, m.staged[name])
+	}
+	for name := range m.deleted {
+		change.Deleted = append(change.Deleted, name)
+	}
+	sort.Strings(change.Deleted)
+	change.BaseServices = config.ServiceDigests(base, change.Touched())
+
+	if err := config.ScheduleChange(m.configDir, change); err != nil {
+		return err
+	}
+	m.staged = make(map[string]config.Service)
+	m.deleted = make(map[string]bool)
+	m.releaseServices()
+	fmt.Fprintf(s.out, "Scheduled for %s (in %s). Cancel with \"schedule cancel\".\n", at.Format("2006-01-02 15:04 MST"), at.Sub(now).Round(time.Minute))
+	return nil
+}
+
+func (m *ConfigMode) writeStaged(s *Shell, stagedNames []string) error {
 	for _, name := range stagedNames {
 		fmt.Fprintf(s.out, "Writing %s...\n", filepath.Join(m.configDir, name+".yaml"))
 		if err := config.WriteServiceConfig(m.configDir, m.staged[name]); err != nil {
@@ -166,10 +428,6 @@ func (m *ConfigMode) Commit(s *Shell) error {
 	for _, name := range deletedNames {
 		_ = os.Remove(filepath.Join(m.configDir, name+".yaml"))
 	}
-
-	m.staged = make(map[string]config.Service)
-	m.deleted = make(map[string]bool)
-	fmt.Fprintln(s.out, "Committed.")
 	return nil
 }
 
@@ -189,4 +447,3 @@ func (m *ConfigMode) diff() (added []string, updated []string) {
 	sort.Strings(updated)
 	return added, updated
 }
-
@@ -10,11 +10,34 @@ type helpEntry struct {
 }
 
 var helpRoot = []helpEntry{
-	{"configure", "Enter configuration mode"},
-	{"show", "Display running state and configuration"},
-	{"doctor", "Run system diagnostics"},
-	{"reload", "Reload configuration from disk"},
+	{"configure", "Enter configuration mode, locking each service as it is edited"},
+	{"configure --reason <text> --duration <dur>", "Enter configuration mode, recording lock intent"},
+	{"configure exclusive", "Enter configuration mode holding the whole configuration, for system settings"},
+	{"status", "Summarize role, VIP, backends and last reconcile; exits 1 degraded, 2 critical, 3 unknown"},
+	{"show", "Show the running daemon's node, state and config generation"},
+	{"show health", "Show each backend's health, weight and override as the daemon sees it"},
+	{"show services [--selector k=v,...]", "List services, optionally filtered by label"},
+	{"show status", "Show the daemon status snapshot and compare on-disk and applied config generations"},
+	{"show schedule", "Show the change waiting for its activation time"},
+	{"show ipvs [--json]", "Show the kernel's IPVS services, destinations and counters"},
+	{"show system-files [--json]", "Show the FRR block and sysctl file the config renders"},
+	{"schedule cancel", "Drop the change waiting for its activation time"},
+	{"doctor", "Check that the daemon answers, reconciles, runs the on-disk config and has healthy backends"},
+	{"doctor probes", "Run every health check once and report results"},
+	{"observability test", "Check that GELF, InfluxDB and the Prometheus listener are reachable"},
+	{"lint", "Validate the config and flag risky patterns by rule ID"},
+	{"reload [<config-path>]", "Have the daemon reload its configuration, or switch to another config file, and report the result"},
+	{"reconcile", "Have the daemon reprogram IPVS now, skipping any retry backoff"},
+	{"drain <service> <backend> [--ttl <dur>]", "Stop new connections to a backend; health checks keep running"},
+	{"undrain <service> <backend>", "Return a drained backend to service"},
+	{"backend drain|restore <service> <backend>", "Same as drain and undrain"},
+	{"log-level <debug|info|warn|error>", "Change the daemon's log level until it restarts"},
+	{"maintenance <on|off>", "Take the node out of rotation: act as standby and lower VRRP priority"},
+	{"service reload <name>", "Revalidate and reapply only one service"},
+	{"install systemd [--binary <path>]", "Write the systemd unit and tmpfiles snippet for the daemon"},
 	{"lock", "Manage configuration lock"},
+	{"lock status", "Show the lock holder, its intent, locked services and read-only sessions"},
+	{"lock break [service <name>] --force", "Stop the session holding the configuration or a service lock"},
 	{"exit", "Exit shell"},
 	{"help", "Show this help"},
 }
@@ -23,20 +46,37 @@ var helpConfig = []helpEntry{
 	{"service <name>", "Add or modify a service"},
 	{"delete <name>", "Delete a service"},
 	{"commit", "Write changes to disk"},
+	{"apply at <HH:MM|time>", "Have the daemon commit the changes at a later time, e.g. a maintenance window"},
 	{"abort", "Discard uncommitted changes"},
 	{"show", "Show pending changes"},
+	{"doctor probes", "Run health checks for the pending config once"},
 	{"exit", "Exit configuration mode"},
 	{"help", "Show this help"},
 }
 
 var helpService = []helpEntry{
-	{"protocol <tcp|udp>", "Set service protocol"},
+	{"protocol <tcp|udp|tcp+udp>", "Set service protocol"},
 	{"ports <p1,p2,...>", "Set discrete ports"},
 	{"port-range <start-end>", "Add a port range"},
-	{"scheduler <rr|wrr|sh>", "Set scheduler"},
-	{"backend <ip> [weight]", "Add backend"},
-	{"no backend <ip>", "Remove backend"},
-	{"health tcp port <p> interval <ms> timeout <ms>", "Enable health check"},
+	{"scheduler <name> [flag ...]", "Set scheduler (rr, wrr, lc, wlc, sed, nq, dh, sh, lblc, lblcr); sh takes sh-fallback, sh-port"},
+	{"backend <ip|host> [weight]", "Add backend"},
+	{"backend <ip|host> [weight] check-address <ip|host> [check-port <p>]", "Health check a different address or port"},
+	{"backend <ip|host> [weight] forward <dr|nat|tun>", "Override the forwarding method for this backend"},
+	{"backend <ip|host> [weight] upper-threshold <conns> [lower-threshold <conns>]", "Stop new connections to an overloaded backend until it drains"},
+	{"no backend <ip|host>", "Remove backend"},
+	{"label <key> <value>", "Set a service label"},
+	{"no label <key>", "Remove a service label"},
+	{"health tcp port <p> interval <ms> timeout <ms> [jitter <pct>]", "Enable health check"},
+	{"health <type> ... slow-start <ms> [slow-steps <n>]", "Ramp weight up after recovery"},
+	{"health <type> ... adaptive", "Scale weight by check latency"},
+	{"health <type> ... icmp-precheck", "Ping backends first; fail fast as host down"},
+	{"health <type> ... min-healthy <n> [fallback <all|last_healthy>]", "Keep serving when too few backends are healthy"},
+	{"health <type> ... combine <all|any>", "How the health check and its probes combine"},
+	{"health probe <type> [port <p>] ...", "Add a probe to the health check"},
+	{"health udp port <p> ... [payload <s>] [expect <s>]", "Enable UDP health check"},
+	{"health <redis|mysql> port <p> interval <ms> timeout <ms>", "Enable protocol health check"},
+	{"health mysql port <p> ... [user <name>]", "Log in as user (default lbctl) and quit"},
+	{"health dns port <p> ... query <name> [qtype <A|AAAA|SRV>] [rcode <name>]", "Enable DNS health check"},
 	{"no health", "Disable health check"},
 	{"show", "Show current service"},
 	{"exit", "Exit to configure mode"},
@@ -60,4 +100,3 @@ func PrintHelp(s *Shell, mode Mode) error {
 	}
 	return nil
 }
-
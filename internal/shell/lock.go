@@ -15,6 +15,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
 	"github.com/malindarathnayake/LibraFlux/internal/observability"
 	"golang.org/x/sys/unix"
 )
@@ -33,6 +35,11 @@ type LockMetadata struct {
 	TTY          string    `json:"tty"`
 	StartedAt    time.Time `json:"started_at"`
 	LastActivity time.Time `json:"last_activity"`
+
+	Reason          string        `json:"reason,omitempty"`
+	PlannedDuration time.Duration `json:"planned_duration,omitempty"`
+
+	ProcStart uint64 `json:"proc_start,omitempty"` // Holder start time in clock ticks since boot, to detect PID reuse
 }
 
 type LockIdentity struct {
@@ -42,12 +49,26 @@ type LockIdentity struct {
 	TTY  string
 }
 
+// LockIntent describes why a session takes the configuration lock and for
+// how long it expects to hold it. Both fields are optional.
+type LockIntent struct {
+	Reason   string
+	Duration time.Duration
+}
+
 type ProcessChecker interface {
 	IsAlive(pid int) bool
 	CommandName(pid int) (string, error)
 	Signal(pid int, sig Signal) error
 }
 
+// ProcessStartTimer is optionally implemented by a ProcessChecker to report
+// when a process started. A lock whose recorded start time no longer matches
+// the running process belongs to a recycled PID and is treated as stale.
+type ProcessStartTimer interface {
+	StartTime(pid int) (uint64, error)
+}
+
 type defaultProcessChecker struct{}
 
 func (defaultProcessChecker) IsAlive(pid int) bool {
@@ -69,6 +90,35 @@ func (defaultProcessChecker) CommandName(pid int) (string, error) {
 	return strings.TrimSpace(string(b)), nil
 }
 
+// StartTime returns the process start time from /proc/<pid>/stat, in clock
+// ticks since boot.
+func (defaultProcessChecker) StartTime(pid int) (uint64, error) {
+	if pid <= 0 {
+		return 0, fmt.Errorf("invalid pid: %d", pid)
+	}
+	b, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, err
+	}
+	return parseProcStartTime(b)
+}
+
+// parseProcStartTime extracts field 22 (starttime) from a /proc/<pid>/stat
+// line. The command name in field 2 may contain spaces and parentheses, so
+// fields are counted from the last closing parenthesis.
+func parseProcStartTime(stat []byte) (uint64, error) {
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 {
+		return 0, errors.New("malformed /proc stat: missing command name")
+	}
+	fields := strings.Fields(string(stat[i+1:]))
+	// fields[0] is field 3 (state), so starttime (field 22) is fields[19].
+	if len(fields) < 20 {
+		return 0, errors.New("malformed /proc stat: too few fields")
+	}
+	return strconv.ParseUint(fields[19], 10, 64)
+}
+
 func (defaultProcessChecker) Signal(pid int, sig Signal) error {
 	if pid <= 0 {
 		return fmt.Errorf("invalid pid: %d", pid)
@@ -84,28 +134,39 @@ func (defaultProcessChecker) Signal(pid int, sig Signal) error {
 }
 
 type ErrLockHeld struct {
-	Meta LockMetadata
-	Idle time.Duration
+	Meta    LockMetadata
+	Idle    time.Duration
+	Service string // Set when the lock in the way is a service lock
 }
 
 func (e *ErrLockHeld) Error() string {
+	what := lockSubject(e.Service)
 	if e.Meta.User == "" {
-		return "configuration locked by another session"
+		return what + " locked by another session"
 	}
 	idle := "unknown"
 	if e.Idle >= 0 {
 		idle = e.Idle.Round(time.Second).String()
 	}
-	return fmt.Sprintf("configuration locked by %s@%s (pid %d), idle %s", e.Meta.User, e.Meta.Host, e.Meta.PID, idle)
+	msg := fmt.Sprintf("%s locked by %s@%s (pid %d), idle %s", what, e.Meta.User, e.Meta.Host, e.Meta.PID, idle)
+	if e.Meta.Reason != "" {
+		msg += fmt.Sprintf(", reason %q", e.Meta.Reason)
+	}
+	if e.Meta.PlannedDuration > 0 {
+		msg += fmt.Sprintf(", planned until %s", e.Meta.StartedAt.Add(e.Meta.PlannedDuration).Format(time.RFC3339))
+	}
+	return msg
 }
 
+func (e *ErrLockHeld) ErrorCode() errdefs.Code { return errdefs.CodeLockHeld }
+
 type AuditEmitter func(event observability.AuditEvent, fields map[string]interface{})
 
 type LockManager struct {
 	Path         string
 	ExpectedComm string
 	Checker      ProcessChecker
-	Now          func() time.Time
+	Clock        clock.Clock
 	Audit        AuditEmitter
 
 	mu sync.Mutex
@@ -118,8 +179,8 @@ func (m *LockManager) ensureDefaults() {
 	if m.Checker == nil {
 		m.Checker = defaultProcessChecker{}
 	}
-	if m.Now == nil {
-		m.Now = time.Now
+	if m.Clock == nil {
+		m.Clock = clock.Real()
 	}
 }
 
@@ -143,6 +204,9 @@ func DefaultIdentity() LockIdentity {
 
 type HeldLock struct {
 	mgr      *LockManager
+	path     string
+	service  string // Set for a service lock
+	quiet    bool   // Not audited, for the short-lived commit lock
 	file     *os.File
 	meta     LockMetadata
 	released bool
@@ -161,7 +225,7 @@ func (h *HeldLock) UpdateActivity() error {
 	if h.released {
 		return errors.New("lock already released")
 	}
-	h.meta.LastActivity = h.mgr.Now().UTC()
+	h.meta.LastActivity = h.mgr.Clock.Now().UTC()
 	return writeMetadata(h.file, h.meta)
 }
 
@@ -173,27 +237,42 @@ func (h *HeldLock) Release() error {
 	}
 	h.released = true
 
-	if h.mgr.Audit != nil {
-		duration := h.mgr.Now().UTC().Sub(h.meta.StartedAt)
-		h.mgr.Audit(observability.AuditLockReleased, map[string]interface{}{
+	if h.mgr.Audit != nil && !h.quiet {
+		duration := h.mgr.Clock.Now().UTC().Sub(h.meta.StartedAt)
+		fields := map[string]interface{}{
 			"user":        h.meta.User,
 			"pid":         h.meta.PID,
 			"tty":         h.meta.TTY,
 			"duration_ms": duration.Milliseconds(),
-		})
+		}
+		if h.service != "" {
+			fields["service"] = h.service
+		}
+		h.mgr.Audit(observability.AuditLockReleased, fields)
 	}
 
 	_ = unix.Flock(int(h.file.Fd()), unix.LOCK_UN)
 	_ = h.file.Close()
-	_ = os.Remove(h.mgr.Path)
+	_ = os.Remove(h.path)
 	return nil
 }
 
 func (m *LockManager) Acquire(id LockIdentity) (*HeldLock, error) {
+	return m.AcquireWithIntent(id, LockIntent{})
+}
+
+// AcquireWithIntent acquires the lock and records intent in the lock metadata
+// so that other sessions can see why and for how long it is held.
+func (m *LockManager) AcquireWithIntent(id LockIdentity, intent LockIntent) (*HeldLock, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.ensureDefaults()
+	return m.acquire(&HeldLock{path: m.Path}, id, intent)
+}
 
+// acquire takes the lock at h.path for id and returns h holding it. The
+// caller must hold m.mu.
+func (m *LockManager) acquire(h *HeldLock, id LockIdentity, intent LockIntent) (*HeldLock, error) {
 	if id.PID == 0 {
 		id.PID = os.Getpid()
 	}
@@ -210,12 +289,12 @@ func (m *LockManager) Acquire(id LockIdentity) (*HeldLock, error) {
 		}
 	}
 
-	if err := os.MkdirAll(filepath.Dir(m.Path), 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
 		return nil, fmt.Errorf("create lock directory: %w", err)
 	}
 
 	for attempt := 0; attempt < 2; attempt++ {
-		f, err := os.OpenFile(m.Path, os.O_RDWR|os.O_CREATE, 0644)
+		f, err := os.OpenFile(h.path, os.O_RDWR|os.O_CREATE, 0644)
 		if err != nil {
 			return nil, fmt.Errorf("open lock file: %w", err)
 		}
@@ -225,7 +304,7 @@ func (m *LockManager) Acquire(id LockIdentity) (*HeldLock, error) {
 			_ = f.Close()
 
 			if metaErr == nil && m.isStale(meta) {
-				if m.Audit != nil {
+				if m.Audit != nil && !h.quiet {
 					m.Audit(observability.AuditLockRecovered, map[string]interface{}{
 						"old_user": meta.User,
 						"old_pid":  meta.PID,
@@ -233,20 +312,16 @@ func (m *LockManager) Acquire(id LockIdentity) (*HeldLock, error) {
 						"new_pid":  id.PID,
 					})
 				}
-				_ = os.Remove(m.Path)
+				_ = os.Remove(h.path)
 				continue
 			}
 
-			idle := time.Duration(-1)
-			if !meta.LastActivity.IsZero() {
-				idle = m.Now().UTC().Sub(meta.LastActivity)
-			}
-			return nil, &ErrLockHeld{Meta: meta, Idle: idle}
+			return nil, &ErrLockHeld{Meta: meta, Idle: m.idle(meta), Service: h.service}
 		}
 
 		previous, prevErr := readMetadataFromFile(f)
 		if prevErr == nil && previous.PID != 0 && previous.PID != id.PID && m.isStale(previous) {
-			if m.Audit != nil {
+			if m.Audit != nil && !h.quiet {
 				m.Audit(observability.AuditLockRecovered, map[string]interface{}{
 					"old_user": previous.User,
 					"old_pid":  previous.PID,
@@ -256,7 +331,7 @@ func (m *LockManager) Acquire(id LockIdentity) (*HeldLock, error) {
 			}
 		}
 
-		now := m.Now().UTC()
+		now := m.Clock.Now().UTC()
 		meta := LockMetadata{
 			PID:          id.PID,
 			User:         id.User,
@@ -264,6 +339,11 @@ func (m *LockManager) Acquire(id LockIdentity) (*HeldLock, error) {
 			TTY:          id.TTY,
 			StartedAt:    now,
 			LastActivity: now,
+
+			Reason:          intent.Reason,
+			PlannedDuration: intent.Duration,
+
+			ProcStart: m.procStart(id.PID),
 		}
 
 		if err := writeMetadata(f, meta); err != nil {
@@ -272,15 +352,26 @@ func (m *LockManager) Acquire(id LockIdentity) (*HeldLock, error) {
 			return nil, err
 		}
 
-		if m.Audit != nil {
-			m.Audit(observability.AuditLockAcquired, map[string]interface{}{
+		if m.Audit != nil && !h.quiet {
+			fields := map[string]interface{}{
 				"user": id.User,
 				"pid":  id.PID,
 				"tty":  id.TTY,
-			})
+			}
+			if h.service != "" {
+				fields["service"] = h.service
+			}
+			if intent.Reason != "" {
+				fields["reason"] = intent.Reason
+			}
+			if intent.Duration > 0 {
+				fields["planned_duration_ms"] = intent.Duration.Milliseconds()
+			}
+			m.Audit(observability.AuditLockAcquired, fields)
 		}
 
-		return &HeldLock{mgr: m, file: f, meta: meta}, nil
+		h.mgr, h.file, h.meta = m, f, meta
+		return h, nil
 	}
 
 	return nil, errors.New("failed to recover stale lock")
@@ -290,8 +381,13 @@ func (m *LockManager) Status() (*LockMetadata, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.ensureDefaults()
+	return m.probe(m.Path)
+}
 
-	f, err := os.OpenFile(m.Path, os.O_RDWR, 0644)
+// probe returns the metadata of the lock at path, or nil when it isn't held.
+// The caller must hold m.mu.
+func (m *LockManager) probe(path string) (*LockMetadata, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, nil
@@ -316,15 +412,20 @@ func (m *LockManager) Break(force bool) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.ensureDefaults()
+	return m.breakLock(m.Path, "", force)
+}
 
+// breakLock signals the holder of the lock at path, which guards service or
+// the whole configuration, and removes it. The caller must hold m.mu.
+func (m *LockManager) breakLock(path, service string, force bool) error {
 	if !force {
 		return errors.New("refusing to break lock without --force")
 	}
 
-	f, err := os.OpenFile(m.Path, os.O_RDWR, 0644)
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return errors.New("no configuration lock held")
+			return fmt.Errorf("no %s lock held", lockSubject(service))
 		}
 		return fmt.Errorf("open lock file: %w", err)
 	}
@@ -332,7 +433,7 @@ func (m *LockManager) Break(force bool) error {
 
 	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err == nil {
 		_ = unix.Flock(int(f.Fd()), unix.LOCK_UN)
-		return errors.New("no configuration lock held")
+		return fmt.Errorf("no %s lock held", lockSubject(service))
 	}
 
 	meta, err := readMetadataFromFile(f)
@@ -344,24 +445,28 @@ func (m *LockManager) Break(force bool) error {
 	}
 
 	_ = m.Checker.Signal(meta.PID, SignalTerm)
-	deadline := m.Now().Add(5 * time.Second)
-	for m.Checker.IsAlive(meta.PID) && m.Now().Before(deadline) {
-		time.Sleep(50 * time.Millisecond)
+	deadline := m.Clock.Now().Add(5 * time.Second)
+	for m.Checker.IsAlive(meta.PID) && m.Clock.Now().Before(deadline) {
+		m.Clock.Sleep(50 * time.Millisecond)
 	}
 	if m.Checker.IsAlive(meta.PID) {
 		_ = m.Checker.Signal(meta.PID, SignalKill)
 	}
 
-	_ = os.Remove(m.Path)
+	_ = os.Remove(path)
 	if m.Audit != nil {
-		m.Audit(observability.AuditLockBroken, map[string]interface{}{
+		fields := map[string]interface{}{
 			"holder_user": meta.User,
 			"holder_pid":  meta.PID,
 			"breaker_user": func() string {
 				id := DefaultIdentity()
 				return id.User
 			}(),
-		})
+		}
+		if service != "" {
+			fields["service"] = service
+		}
+		m.Audit(observability.AuditLockBroken, fields)
 	}
 	return nil
 }
@@ -377,7 +482,32 @@ func (m *LockManager) isStale(meta LockMetadata) bool {
 	if err != nil {
 		return false
 	}
-	return comm != m.ExpectedComm
+	if comm != m.ExpectedComm {
+		return true
+	}
+	if meta.ProcStart == 0 {
+		return false
+	}
+	st, ok := m.Checker.(ProcessStartTimer)
+	if !ok {
+		return false
+	}
+	start, err := st.StartTime(meta.PID)
+	return err == nil && start != meta.ProcStart
+}
+
+// procStart returns the start time of pid, or 0 when the checker cannot
+// report one.
+func (m *LockManager) procStart(pid int) uint64 {
+	st, ok := m.Checker.(ProcessStartTimer)
+	if !ok {
+		return 0
+	}
+	start, err := st.StartTime(pid)
+	if err != nil {
+		return 0
+	}
+	return start
 }
 
 func readMetadataFromFile(f *os.File) (LockMetadata, error) {
@@ -413,3 +543,18 @@ func writeMetadata(f *os.File, meta LockMetadata) error {
 	}
 	return f.Sync()
 }
+
+func tryLockExclusiveNonBlocking(f *os.File) (bool, error) {
+	err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		return false, nil
+	}
+	return false, err
+}
+
+func unlockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_UN)
+}
@@ -0,0 +1,197 @@
+//go:build !windows || lbctl_full
+
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// commitLockWait bounds how long a commit waits for another session's commit
+// to finish.
+const commitLockWait = 5 * time.Second
+
+// ServiceLock is a service lock held by a configure session.
+type ServiceLock struct {
+	Service string
+	LockMetadata
+}
+
+// lockSubject names what a lock guards, for messages.
+func lockSubject(service string) string {
+	if service == "" {
+		return "configuration"
+	}
+	return "service " + service
+}
+
+func (m *LockManager) servicesDir() string {
+	return m.Path + ".services"
+}
+
+func (m *LockManager) serviceLockPath(service string) (string, error) {
+	if service == "" || service != filepath.Base(service) || strings.HasPrefix(service, ".") {
+		return "", fmt.Errorf("invalid service name: %q", service)
+	}
+	return filepath.Join(m.servicesDir(), service+".lock"), nil
+}
+
+// AcquireService locks one service for a configure session, so sessions
+// editing different services can work at the same time. It fails while
+// another session holds the configuration lock.
+func (m *LockManager) AcquireService(id LockIdentity, service string, intent LockIntent) (*HeldLock, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ensureDefaults()
+
+	path, err := m.serviceLockPath(service)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.heldBy(m.Path, ""); err != nil {
+		return nil, err
+	}
+	h, err := m.acquire(&HeldLock{path: path, service: service}, id, intent)
+	if err != nil {
+		return nil, err
+	}
+	// AcquireExclusive takes its lock and then checks for service locks, the
+	// reverse of this, so of two sessions racing at least one backs off.
+	if err := m.heldBy(m.Path, ""); err != nil {
+		_ = h.Release()
+		return nil, err
+	}
+	return h, nil
+}
+
+// AcquireExclusive takes the configuration lock for a session that may edit
+// anything, including system settings. It fails while any service is locked.
+func (m *LockManager) AcquireExclusive(id LockIdentity, intent LockIntent) (*HeldLock, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ensureDefaults()
+
+	if err := m.serviceHeld(); err != nil {
+		return nil, err
+	}
+	h, err := m.acquire(&HeldLock{path: m.Path}, id, intent)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.serviceHeld(); err != nil {
+		_ = h.Release()
+		return nil, err
+	}
+	return h, nil
+}
+
+// AcquireCommit takes the short-lived lock that serializes commits, so each
+// is validated against the services the others wrote. It waits up to
+// commitLockWait for another session's commit to finish.
+func (m *LockManager) AcquireCommit(id LockIdentity) (*HeldLock, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ensureDefaults()
+
+	deadline := m.Clock.Now().Add(commitLockWait)
+	for {
+		h, err := m.acquire(&HeldLock{path: m.Path + ".commit", quiet: true}, id, LockIntent{})
+		var held *ErrLockHeld
+		if !errors.As(err, &held) {
+			return h, err
+		}
+		if !m.Clock.Now().Before(deadline) {
+			return nil, fmt.Errorf("another session is committing: %w", err)
+		}
+		m.Clock.Sleep(50 * time.Millisecond)
+	}
+}
+
+// ServiceLocks lists the locked services by name.
+func (m *LockManager) ServiceLocks() ([]ServiceLock, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ensureDefaults()
+	return m.serviceLocks()
+}
+
+// BreakService signals the session holding service's lock and removes it.
+func (m *LockManager) BreakService(service string, force bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ensureDefaults()
+
+	path, err := m.serviceLockPath(service)
+	if err != nil {
+		return err
+	}
+	return m.breakLock(path, service, force)
+}
+
+// serviceLocks lists the locked services. The caller must hold m.mu.
+func (m *LockManager) serviceLocks() ([]ServiceLock, error) {
+	entries, err := os.ReadDir(m.servicesDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read service locks directory: %w", err)
+	}
+
+	var locks []ServiceLock
+	for _, e := range entries {
+		service, ok := strings.CutSuffix(e.Name(), ".lock")
+		if e.IsDir() || !ok {
+			continue
+		}
+		meta, err := m.probe(filepath.Join(m.servicesDir(), e.Name()))
+		if err != nil || meta == nil {
+			continue
+		}
+		locks = append(locks, ServiceLock{Service: service, LockMetadata: *meta})
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].Service < locks[j].Service })
+	return locks, nil
+}
+
+// serviceHeld returns an ErrLockHeld for the first service another session
+// has locked. The caller must hold m.mu.
+func (m *LockManager) serviceHeld() error {
+	locks, err := m.serviceLocks()
+	if err != nil {
+		return err
+	}
+	for _, l := range locks {
+		if !m.isStale(l.LockMetadata) {
+			return &ErrLockHeld{Meta: l.LockMetadata, Idle: m.idle(l.LockMetadata), Service: l.Service}
+		}
+	}
+	return nil
+}
+
+// heldBy returns an ErrLockHeld when another session holds the lock at path.
+// The caller must hold m.mu.
+func (m *LockManager) heldBy(path, service string) error {
+	meta, err := m.probe(path)
+	if err != nil {
+		// Locked, but the holder hasn't written its metadata yet
+		return &ErrLockHeld{Idle: -1, Service: service}
+	}
+	if meta == nil || m.isStale(*meta) {
+		return nil
+	}
+	return &ErrLockHeld{Meta: *meta, Idle: m.idle(*meta), Service: service}
+}
+
+// idle is how long the holder of meta has been inactive, or -1 if unknown.
+func (m *LockManager) idle(meta LockMetadata) time.Duration {
+	if meta.LastActivity.IsZero() {
+		return -1
+	}
+	return m.Clock.Now().UTC().Sub(meta.LastActivity)
+}
@@ -0,0 +1,164 @@
+//go:build !windows || lbctl_full
+
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SharedLock is an advisory read lock held by a read-only session. Any
+// number of shared locks may coexist with each other and with the exclusive
+// configure lock; they exist so that lock status can show who is watching.
+type SharedLock struct {
+	path     string
+	file     *os.File
+	meta     LockMetadata
+	released bool
+	mu       sync.Mutex
+}
+
+func (l *SharedLock) Metadata() LockMetadata {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.meta
+}
+
+func (l *SharedLock) Release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.released {
+		return nil
+	}
+	l.released = true
+	_ = unlockFile(l.file)
+	_ = l.file.Close()
+	_ = os.Remove(l.path)
+	return nil
+}
+
+func (m *LockManager) readersDir() string {
+	return m.Path + ".readers"
+}
+
+// AcquireShared registers a read-only session. Each reader owns a metadata
+// file in the readers directory and holds an exclusive lock on it for as long
+// as the session lives, so files left behind by dead processes can be told
+// apart from live readers. The file only gets its .json name once it is
+// locked and written, so Readers never sees a half-created entry.
+func (m *LockManager) AcquireShared(id LockIdentity) (*SharedLock, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ensureDefaults()
+
+	if id.PID == 0 {
+		id.PID = os.Getpid()
+	}
+	if id.User == "" || id.Host == "" || id.TTY == "" {
+		def := DefaultIdentity()
+		if id.User == "" {
+			id.User = def.User
+		}
+		if id.Host == "" {
+			id.Host = def.Host
+		}
+		if id.TTY == "" {
+			id.TTY = def.TTY
+		}
+	}
+
+	dir := m.readersDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create readers directory: %w", err)
+	}
+	f, err := os.CreateTemp(dir, fmt.Sprintf("%d-*.tmp", id.PID))
+	if err != nil {
+		return nil, fmt.Errorf("create reader lock file: %w", err)
+	}
+	ok, err := tryLockExclusiveNonBlocking(f)
+	if err != nil || !ok {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		if err == nil {
+			err = errors.New("reader lock file already locked")
+		}
+		return nil, fmt.Errorf("lock reader file: %w", err)
+	}
+
+	now := m.Clock.Now().UTC()
+	meta := LockMetadata{
+		PID:          id.PID,
+		User:         id.User,
+		Host:         id.Host,
+		TTY:          id.TTY,
+		StartedAt:    now,
+		LastActivity: now,
+	}
+	path := strings.TrimSuffix(f.Name(), ".tmp") + ".json"
+	err = writeMetadata(f, meta)
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = unlockFile(f)
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+	return &SharedLock{path: path, file: f, meta: meta}, nil
+}
+
+// Readers lists the live read-only sessions, oldest first. Reader files that
+// are no longer locked belong to sessions that exited without releasing and
+// are removed.
+func (m *LockManager) Readers() ([]LockMetadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ensureDefaults()
+
+	entries, err := os.ReadDir(m.readersDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read readers directory: %w", err)
+	}
+
+	var readers []LockMetadata
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(m.readersDir(), e.Name())
+		f, err := os.OpenFile(path, os.O_RDWR, 0644)
+		if err != nil {
+			continue
+		}
+		ok, err := tryLockExclusiveNonBlocking(f)
+		if err != nil {
+			_ = f.Close()
+			continue
+		}
+		if ok {
+			_ = unlockFile(f)
+			_ = f.Close()
+			_ = os.Remove(path)
+			continue
+		}
+		meta, err := readMetadataFromFile(f)
+		_ = f.Close()
+		if err != nil {
+			continue
+		}
+		readers = append(readers, meta)
+	}
+	sort.Slice(readers, func(i, j int) bool {
+		return readers[i].StartedAt.Before(readers[j].StartedAt)
+	})
+	return readers, nil
+}
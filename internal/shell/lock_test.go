@@ -6,9 +6,11 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
 	"github.com/malindarathnayake/LibraFlux/internal/observability"
 )
 
@@ -32,13 +34,14 @@ func TestLockAcquireReleaseAndStatus(t *testing.T) {
 	lockPath := filepath.Join(dir, "config.lock")
 
 	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	clk := clock.NewFake(now)
 	var events []observability.AuditEvent
 
 	m := &LockManager{
 		Path:         lockPath,
 		ExpectedComm: "lbctl",
 		Checker:      fakeChecker{alive: map[int]bool{1: true}, comm: map[int]string{1: "lbctl"}},
-		Now:          func() time.Time { return now },
+		Clock:        clk,
 		Audit: func(e observability.AuditEvent, _ map[string]interface{}) {
 			events = append(events, e)
 		},
@@ -58,7 +61,7 @@ func TestLockAcquireReleaseAndStatus(t *testing.T) {
 	}
 
 	now2 := now.Add(2 * time.Minute)
-	m.Now = func() time.Time { return now2 }
+	clk.Set(now2)
 	if err := held.UpdateActivity(); err != nil {
 		t.Fatalf("UpdateActivity() error: %v", err)
 	}
@@ -110,7 +113,7 @@ func TestLockRecoversStaleMetadata(t *testing.T) {
 		Path:         lockPath,
 		ExpectedComm: "lbctl",
 		Checker:      fakeChecker{alive: map[int]bool{2: true, 999: false}, comm: map[int]string{2: "lbctl"}},
-		Now:          time.Now,
+		Clock:        clock.Real(),
 		Audit: func(e observability.AuditEvent, _ map[string]interface{}) {
 			if e == observability.AuditLockRecovered {
 				recovered = true
@@ -127,3 +130,214 @@ func TestLockRecoversStaleMetadata(t *testing.T) {
 		t.Fatalf("expected lock recovery audit event")
 	}
 }
+
+func TestLockSharedReadersCoexist(t *testing.T) {
+	dir := t.TempDir()
+	lockPath := filepath.Join(dir, "config.lock")
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	clk := clock.NewFake(now)
+	m := &LockManager{
+		Path:         lockPath,
+		ExpectedComm: "lbctl",
+		Checker:      fakeChecker{alive: map[int]bool{1: true}, comm: map[int]string{1: "lbctl"}},
+		Clock:        clk,
+	}
+
+	alice, err := m.AcquireShared(LockIdentity{PID: 1, User: "alice", Host: "h", TTY: "t1"})
+	if err != nil {
+		t.Fatalf("AcquireShared(alice) error: %v", err)
+	}
+	clk.Advance(time.Minute)
+	bob, err := m.AcquireShared(LockIdentity{PID: 1, User: "bob", Host: "h", TTY: "t2"})
+	if err != nil {
+		t.Fatalf("AcquireShared(bob) error: %v", err)
+	}
+
+	held, err := m.Acquire(LockIdentity{PID: 1, User: "carol", Host: "h", TTY: "t3"})
+	if err != nil {
+		t.Fatalf("Acquire() with readers present error: %v", err)
+	}
+	defer held.Release()
+
+	// A reader file whose owner has gone away is not locked and gets pruned.
+	staleMeta := LockMetadata{PID: 999, User: "ghost", Host: "h", TTY: "t9", StartedAt: now}
+	b, _ := json.Marshal(staleMeta)
+	stalePath := filepath.Join(lockPath+".readers", "999-stale.json")
+	if err := os.WriteFile(stalePath, b, 0644); err != nil {
+		t.Fatalf("write stale reader: %v", err)
+	}
+
+	readers, err := m.Readers()
+	if err != nil {
+		t.Fatalf("Readers() error: %v", err)
+	}
+	if len(readers) != 2 || readers[0].User != "alice" || readers[1].User != "bob" {
+		t.Fatalf("unexpected readers: %#v", readers)
+	}
+	if _, err := os.Stat(stalePath); !os.IsNotExist(err) {
+		t.Fatalf("expected stale reader file removed, stat err=%v", err)
+	}
+
+	if err := alice.Release(); err != nil {
+		t.Fatalf("Release() error: %v", err)
+	}
+	readers, err = m.Readers()
+	if err != nil {
+		t.Fatalf("Readers() error: %v", err)
+	}
+	if len(readers) != 1 || readers[0].User != "bob" {
+		t.Fatalf("expected only bob after release, got %#v", readers)
+	}
+	_ = bob.Release()
+
+	meta, err := m.Status()
+	if err != nil || meta == nil || meta.User != "carol" {
+		t.Fatalf("expected exclusive lock still held by carol, got %#v, %v", meta, err)
+	}
+}
+
+func TestLockServiceLocks(t *testing.T) {
+	dir := t.TempDir()
+	clk := clock.NewFake(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	var events []map[string]interface{}
+	m := &LockManager{
+		Path:         filepath.Join(dir, "config.lock"),
+		ExpectedComm: "lbctl",
+		Checker:      fakeChecker{alive: map[int]bool{1: true, 2: true}, comm: map[int]string{1: "lbctl", 2: "lbctl"}},
+		Clock:        clk,
+		Audit: func(e observability.AuditEvent, fields map[string]interface{}) {
+			if e == observability.AuditLockAcquired {
+				events = append(events, fields)
+			}
+		},
+	}
+	alice := LockIdentity{PID: 1, User: "alice", Host: "h", TTY: "t1"}
+	bob := LockIdentity{PID: 2, User: "bob", Host: "h", TTY: "t2"}
+
+	web, err := m.AcquireService(alice, "web", LockIntent{})
+	if err != nil {
+		t.Fatalf("AcquireService(web) error: %v", err)
+	}
+	if _, err := m.AcquireService(bob, "web", LockIntent{}); err == nil || err.Error() != "service web locked by alice@h (pid 1), idle 0s" {
+		t.Fatalf("expected web locked by alice, got %v", err)
+	}
+	api, err := m.AcquireService(bob, "api", LockIntent{})
+	if err != nil {
+		t.Fatalf("AcquireService(api) error: %v", err)
+	}
+	if len(events) != 2 || events[0]["service"] != "web" || events[1]["service"] != "api" {
+		t.Fatalf("expected service lock audit events, got %#v", events)
+	}
+	if _, err := m.AcquireService(bob, "../config", LockIntent{}); err == nil {
+		t.Fatal("expected an invalid service name to fail")
+	}
+
+	locks, err := m.ServiceLocks()
+	if err != nil || len(locks) != 2 || locks[0].Service != "api" || locks[0].User != "bob" || locks[1].Service != "web" {
+		t.Fatalf("unexpected service locks %#v, %v", locks, err)
+	}
+	if _, err := m.AcquireExclusive(bob, LockIntent{}); err == nil {
+		t.Fatal("expected service locks to block the configuration lock")
+	}
+	if meta, _ := m.Status(); meta != nil {
+		t.Fatalf("expected a failed exclusive acquire to leave the configuration lock free, got %#v", meta)
+	}
+
+	// Commits are serialized; a commit in progress fails the next once its
+	// wait runs out
+	commit, err := m.AcquireCommit(alice)
+	if err != nil {
+		t.Fatalf("AcquireCommit() error: %v", err)
+	}
+	start := clk.Now()
+	if _, err := m.AcquireCommit(bob); err == nil || !strings.Contains(err.Error(), "another session is committing") {
+		t.Fatalf("expected the commit lock to be held, got %v", err)
+	}
+	if waited := clk.Now().Sub(start); waited < commitLockWait {
+		t.Fatalf("expected to wait out the commit lock, waited %s", waited)
+	}
+	_ = commit.Release()
+
+	if err := m.BreakService("api", true); err != nil {
+		t.Fatalf("BreakService() error: %v", err)
+	}
+	_ = api.Release()
+	_ = web.Release()
+	if locks, err := m.ServiceLocks(); err != nil || len(locks) != 0 {
+		t.Fatalf("expected no service locks, got %#v, %v", locks, err)
+	}
+
+	held, err := m.AcquireExclusive(bob, LockIntent{})
+	if err != nil {
+		t.Fatalf("AcquireExclusive() error: %v", err)
+	}
+	defer held.Release()
+	if _, err := m.AcquireService(alice, "web", LockIntent{}); err == nil || !strings.Contains(err.Error(), "configuration locked by bob@h") {
+		t.Fatalf("expected the configuration lock to block service locks, got %v", err)
+	}
+}
+
+type fakeStartChecker struct {
+	fakeChecker
+	start map[int]uint64
+}
+
+func (f fakeStartChecker) StartTime(pid int) (uint64, error) {
+	return f.start[pid], nil
+}
+
+func TestLockStaleOnPIDReuse(t *testing.T) {
+	dir := t.TempDir()
+	lockPath := filepath.Join(dir, "config.lock")
+
+	checker := fakeStartChecker{
+		fakeChecker: fakeChecker{alive: map[int]bool{1: true, 7: true}, comm: map[int]string{1: "lbctl", 7: "lbctl"}},
+		start:       map[int]uint64{1: 100, 7: 555},
+	}
+	m := &LockManager{Path: lockPath, ExpectedComm: "lbctl", Checker: checker, Clock: clock.Real()}
+	m.ensureDefaults()
+
+	held, err := m.Acquire(LockIdentity{PID: 1, User: "alice", Host: "h", TTY: "t"})
+	if err != nil {
+		t.Fatalf("Acquire() error: %v", err)
+	}
+	if got := held.Metadata().ProcStart; got != 100 {
+		t.Fatalf("expected recorded start time 100, got %d", got)
+	}
+	_ = held.Release()
+
+	// Same PID, still lbctl, but started at a different time: the PID was
+	// recycled by another lbctl process.
+	if !m.isStale(LockMetadata{PID: 7, ProcStart: 554}) {
+		t.Fatalf("expected lock with mismatched start time to be stale")
+	}
+	if m.isStale(LockMetadata{PID: 7, ProcStart: 555}) {
+		t.Fatalf("expected lock with matching start time to be live")
+	}
+	// Metadata written before start times were recorded falls back to the
+	// comm check.
+	if m.isStale(LockMetadata{PID: 7}) {
+		t.Fatalf("expected lock without start time to be live")
+	}
+}
+
+func TestParseProcStartTime(t *testing.T) {
+	stat := "4242 (lb ctl) (x)) S 1 4242 4242 0 -1 4194560 100 0 0 0 1 2 0 0 20 0 1 0 987654 1000 100 18446744073709551615\n"
+	got, err := parseProcStartTime([]byte(stat))
+	if err != nil {
+		t.Fatalf("parseProcStartTime() error: %v", err)
+	}
+	if got != 987654 {
+		t.Fatalf("expected 987654, got %d", got)
+	}
+	if _, err := parseProcStartTime([]byte("4242 (lbctl) S 1")); err == nil {
+		t.Fatalf("expected error for truncated stat")
+	}
+
+	if _, err := os.Stat("/proc/self/stat"); err == nil {
+		if start, err := (defaultProcessChecker{}).StartTime(os.Getpid()); err != nil || start == 0 {
+			t.Fatalf("StartTime(self) = %d, %v", start, err)
+		}
+	}
+}
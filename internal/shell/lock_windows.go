@@ -5,6 +5,8 @@ package shell
 import (
 	"errors"
 	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
 )
 
 type LockMetadata struct {
@@ -14,6 +16,11 @@ type LockMetadata struct {
 	TTY          string    `json:"tty"`
 	StartedAt    time.Time `json:"started_at"`
 	LastActivity time.Time `json:"last_activity"`
+
+	Reason          string        `json:"reason,omitempty"`
+	PlannedDuration time.Duration `json:"planned_duration,omitempty"`
+
+	ProcStart uint64 `json:"proc_start,omitempty"` // Holder start time in clock ticks since boot, to detect PID reuse
 }
 
 type LockIdentity struct {
@@ -23,9 +30,15 @@ type LockIdentity struct {
 	TTY  string
 }
 
+type LockIntent struct {
+	Reason   string
+	Duration time.Duration
+}
+
 type ErrLockHeld struct {
-	Meta LockMetadata
-	Idle time.Duration
+	Meta    LockMetadata
+	Idle    time.Duration
+	Service string
 }
 
 func (e *ErrLockHeld) Error() string { return "configuration lock is not supported on windows" }
@@ -36,9 +49,14 @@ func (h *HeldLock) Metadata() LockMetadata { return LockMetadata{} }
 func (h *HeldLock) UpdateActivity() error  { return nil }
 func (h *HeldLock) Release() error         { return nil }
 
+type SharedLock struct{}
+
+func (l *SharedLock) Metadata() LockMetadata { return LockMetadata{} }
+func (l *SharedLock) Release() error         { return nil }
+
 type LockManager struct {
-	Path string
-	Now  func() time.Time
+	Path  string
+	Clock clock.Clock
 }
 
 func DefaultIdentity() LockIdentity { return LockIdentity{} }
@@ -47,6 +65,38 @@ func (m *LockManager) Acquire(_ LockIdentity) (*HeldLock, error) {
 	return nil, errors.New("configuration locking is not supported on windows")
 }
 
-func (m *LockManager) Status() (*LockMetadata, error) { return nil, nil }
-func (m *LockManager) Break(_ bool) error             { return errors.New("configuration locking is not supported on windows") }
+func (m *LockManager) AcquireWithIntent(_ LockIdentity, _ LockIntent) (*HeldLock, error) {
+	return nil, errors.New("configuration locking is not supported on windows")
+}
+
+func (m *LockManager) AcquireShared(_ LockIdentity) (*SharedLock, error) {
+	return nil, errors.New("configuration locking is not supported on windows")
+}
+
+func (m *LockManager) AcquireService(_ LockIdentity, _ string, _ LockIntent) (*HeldLock, error) {
+	return nil, errors.New("configuration locking is not supported on windows")
+}
+
+func (m *LockManager) AcquireExclusive(_ LockIdentity, _ LockIntent) (*HeldLock, error) {
+	return nil, errors.New("configuration locking is not supported on windows")
+}
+
+func (m *LockManager) AcquireCommit(_ LockIdentity) (*HeldLock, error) {
+	return nil, errors.New("configuration locking is not supported on windows")
+}
+
+type ServiceLock struct {
+	Service string
+	LockMetadata
+}
 
+func (m *LockManager) Readers() ([]LockMetadata, error)     { return nil, nil }
+func (m *LockManager) ServiceLocks() ([]ServiceLock, error) { return nil, nil }
+func (m *LockManager) BreakService(_ string, _ bool) error {
+	return errors.New("configuration locking is not supported on windows")
+}
+
+func (m *LockManager) Status() (*LockMetadata, error) { return nil, nil }
+func (m *LockManager) Break(_ bool) error {
+	return errors.New("configuration locking is not supported on windows")
+}
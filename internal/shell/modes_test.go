@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
 )
 
 func TestIdleTimeoutExitsConfigureMode(t *testing.T) {
@@ -17,10 +19,10 @@ func TestIdleTimeoutExitsConfigureMode(t *testing.T) {
 	var errOut bytes.Buffer
 
 	lockPath := filepath.Join(dir, "config.lock")
-	cur := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC))
 	mgr := &LockManager{
-		Path: lockPath,
-		Now:  func() time.Time { return cur },
+		Path:  lockPath,
+		Clock: clk,
 	}
 
 	sh, err := New(ShellOptions{
@@ -30,7 +32,7 @@ func TestIdleTimeoutExitsConfigureMode(t *testing.T) {
 		ConfigDir:   configDir,
 		LockManager: mgr,
 		IdleTimeout: 1 * time.Minute,
-		Now:         func() time.Time { return cur },
+		Clock:       clk,
 	})
 	if err != nil {
 		t.Fatalf("New() error: %v", err)
@@ -43,7 +45,7 @@ func TestIdleTimeoutExitsConfigureMode(t *testing.T) {
 		t.Fatalf("expected ModeConfig, got %v", sh.Mode())
 	}
 
-	cur = cur.Add(2 * time.Minute)
+	clk.Advance(2 * time.Minute)
 	if err := sh.ExecuteLine("show"); err != nil {
 		t.Fatalf("show error: %v", err)
 	}
@@ -3,7 +3,7 @@ package shell
 import (
 	"errors"
 	"fmt"
-	"net"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -28,19 +28,36 @@ func (m *ServiceMode) Handle(s *Shell, tokens []string) error {
 		return m.show(s)
 	case "protocol":
 		if len(tokens) < 2 {
-			return errors.New("usage: protocol <tcp|udp>")
+			return usageError("usage: protocol <tcp|udp|tcp+udp>")
 		}
 		m.Service.Protocol = strings.ToLower(tokens[1])
 		return nil
 	case "scheduler":
 		if len(tokens) < 2 {
-			return errors.New("usage: scheduler <rr|wrr|sh>")
+			return fmt.Errorf("usage: scheduler <%s> [flag ...]", strings.Join(config.IPVSSchedulers, "|"))
 		}
-		m.Service.Scheduler = strings.ToLower(tokens[1])
+		sched := strings.ToLower(tokens[1])
+		if !slices.Contains(config.IPVSSchedulers, sched) {
+			return fmt.Errorf("unknown scheduler: %s", tokens[1])
+		}
+		var flags []string
+		for _, t := range tokens[2:] {
+			flag := strings.ToLower(t)
+			owner, ok := config.IPVSSchedulerFlags[flag]
+			if !ok {
+				return fmt.Errorf("unknown scheduler flag: %s", t)
+			}
+			if owner != sched {
+				return fmt.Errorf("scheduler flag %s requires scheduler %s", t, owner)
+			}
+			flags = append(flags, flag)
+		}
+		m.Service.Scheduler = sched
+		m.Service.SchedulerFlags = flags
 		return nil
 	case "ports":
 		if len(tokens) < 2 {
-			return errors.New("usage: ports <p1,p2,...>")
+			return usageError("usage: ports <p1,p2,...>")
 		}
 		ports, err := parseCSVPorts(tokens[1])
 		if err != nil {
@@ -50,7 +67,7 @@ func (m *ServiceMode) Handle(s *Shell, tokens []string) error {
 		return nil
 	case "port-range":
 		if len(tokens) < 2 {
-			return errors.New("usage: port-range <start-end>")
+			return usageError("usage: port-range <start-end>")
 		}
 		pr, err := parsePortRange(tokens[1])
 		if err != nil {
@@ -60,34 +77,78 @@ func (m *ServiceMode) Handle(s *Shell, tokens []string) error {
 		return nil
 	case "backend":
 		if len(tokens) < 2 {
-			return errors.New("usage: backend <ip> [weight]")
+			return usageError("usage: backend <ip|host> [weight] [check-address <ip|host>] [check-port <port>] [forward <dr|nat|tun>] [upper-threshold <conns>] [lower-threshold <conns>]")
 		}
 		ip := tokens[1]
-		if net.ParseIP(ip) == nil {
-			return fmt.Errorf("invalid ip: %s", ip)
+		if !config.IsHost(ip) {
+			return fmt.Errorf("invalid address: %s", ip)
 		}
-		weight := 1
-		if len(tokens) >= 3 {
-			w, err := strconv.Atoi(tokens[2])
+		be := config.Backend{Address: ip, Port: 0, Weight: 1}
+		rest := tokens[2:]
+		if len(rest) > 0 && !isBackendOption(rest[0]) {
+			w, err := strconv.Atoi(rest[0])
 			if err != nil {
 				return fmt.Errorf("invalid weight: %w", err)
 			}
-			weight = w
+			be.Weight = w
+			rest = rest[1:]
+		}
+		for len(rest) > 0 {
+			if len(rest) < 2 {
+				return fmt.Errorf("missing value for %s", rest[0])
+			}
+			switch strings.ToLower(rest[0]) {
+			case "check-address":
+				if !config.IsHost(rest[1]) {
+					return fmt.Errorf("invalid check address: %s", rest[1])
+				}
+				be.CheckAddress = rest[1]
+			case "check-port":
+				p, err := strconv.Atoi(rest[1])
+				if err != nil || p < 1 || p > 65535 {
+					return fmt.Errorf("invalid check port: %s", rest[1])
+				}
+				be.CheckPort = p
+			case "forward":
+				fwd := strings.ToLower(rest[1])
+				if !slices.Contains(config.ForwardMethods, fwd) {
+					return fmt.Errorf("invalid forwarding method: %s (expected dr, nat or tun)", rest[1])
+				}
+				be.Forward = fwd
+			case "upper-threshold", "lower-threshold":
+				n, err := strconv.Atoi(rest[1])
+				if err != nil || n < 0 {
+					return fmt.Errorf("invalid %s: %s", strings.ToLower(rest[0]), rest[1])
+				}
+				if strings.EqualFold(rest[0], "upper-threshold") {
+					be.UpperThreshold = n
+				} else {
+					be.LowerThreshold = n
+				}
+			default:
+				return fmt.Errorf("unknown backend option: %s", rest[0])
+			}
+			rest = rest[2:]
 		}
-		m.Service.Backends = append(m.Service.Backends, config.Backend{
-			Address: ip,
-			Port:    0,
-			Weight:  weight,
-		})
+		m.Service.Backends = append(m.Service.Backends, be)
+		return nil
+	case "label":
+		if len(tokens) != 3 {
+			return usageError("usage: label <key> <value>")
+		}
+		if m.Service.Labels == nil {
+			m.Service.Labels = make(map[string]string)
+		}
+		m.Service.Labels[tokens[1]] = tokens[2]
 		return nil
 	case "no":
 		if len(tokens) < 2 {
-			return errors.New("usage: no <subcommand>")
+			return usageError("usage: no <subcommand>")
 		}
 		switch strings.ToLower(tokens[1]) {
 		case "backend":
 			if len(tokens) < 3 {
-				return errors.New("usage: no backend <ip>")
+				return usageError("usage: no backend <ip|host>")
 			}
 			ip := tokens[2]
 			var next []config.Backend
@@ -98,16 +159,25 @@ func (m *ServiceMode) Handle(s *Shell, tokens []string) error {
 			}
 			m.Service.Backends = next
 			return nil
+		case "label":
+			if len(tokens) < 3 {
+				return usageError("usage: no label <key>")
+			}
+			delete(m.Service.Labels, tokens[2])
+			if len(m.Service.Labels) == 0 {
+				m.Service.Labels = nil
+			}
+			return nil
 		case "health":
 			m.Service.Health = config.HealthCheck{Enabled: false, Type: "tcp"}
 			return nil
 		default:
-			return fmt.Errorf("unknown no subcommand: %s", tokens[1])
+			return unknownCommandf("unknown no subcommand: %s", tokens[1])
 		}
 	case "health":
 		return m.health(tokens[1:])
 	default:
-		return fmt.Errorf("unknown service command: %s", tokens[0])
+		return unknownCommandf("unknown service command: %s", tokens[0])
 	}
 }
 
@@ -125,26 +195,129 @@ func (m *ServiceMode) show(s *Shell) error {
 	for _, pr := range m.Service.PortRanges {
 		fmt.Fprintf(s.out, "  port-range %d-%d\n", pr.Start, pr.End)
 	}
-	fmt.Fprintf(s.out, "  scheduler %s\n", m.Service.Scheduler)
+	if len(m.Service.SchedulerFlags) > 0 {
+		fmt.Fprintf(s.out, "  scheduler %s %s\n", m.Service.Scheduler, strings.Join(m.Service.SchedulerFlags, " "))
+	} else {
+		fmt.Fprintf(s.out, "  scheduler %s\n", m.Service.Scheduler)
+	}
+	var keys []string
+	for k := range m.Service.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(s.out, "  label %s %s\n", k, m.Service.Labels[k])
+	}
 	for _, be := range m.Service.Backends {
-		fmt.Fprintf(s.out, "  backend %s weight %d\n", be.Address, be.Weight)
+		line := fmt.Sprintf("  backend %s weight %d", be.Address, be.Weight)
+		if be.CheckAddress != "" {
+			line += fmt.Sprintf(" check-address %s", be.CheckAddress)
+		}
+		if be.CheckPort > 0 {
+			line += fmt.Sprintf(" check-port %d", be.CheckPort)
+		}
+		if be.Forward != "" {
+			line += fmt.Sprintf(" forward %s", be.Forward)
+		}
+		if be.UpperThreshold > 0 {
+			line += fmt.Sprintf(" upper-threshold %d", be.UpperThreshold)
+		}
+		if be.LowerThreshold > 0 {
+			line += fmt.Sprintf(" lower-threshold %d", be.LowerThreshold)
+		}
+		fmt.Fprintln(s.out, line)
 	}
 	if m.Service.Health.Enabled {
-		fmt.Fprintf(s.out, "  health tcp port %d interval %d timeout %d\n", m.Service.Health.Port, m.Service.Health.IntervalMS, m.Service.Health.TimeoutMS)
+		h := m.Service.Health
+		line := fmt.Sprintf("  health %s port %d interval %d timeout %d", h.Type, h.Port, h.IntervalMS, h.TimeoutMS)
+		if h.JitterPct > 0 {
+			line += fmt.Sprintf(" jitter %d", h.JitterPct)
+		}
+		if h.SlowStartMS > 0 {
+			line += fmt.Sprintf(" slow-start %d", h.SlowStartMS)
+		}
+		if h.SlowStartSteps > 0 {
+			line += fmt.Sprintf(" slow-steps %d", h.SlowStartSteps)
+		}
+		if h.AdaptiveWeight {
+			line += " adaptive"
+		}
+		if h.ICMPPrecheck {
+			line += " icmp-precheck"
+		}
+		if h.MinHealthy > 0 {
+			line += fmt.Sprintf(" min-healthy %d", h.MinHealthy)
+		}
+		if h.Fallback != "" {
+			line += fmt.Sprintf(" fallback %s", h.Fallback)
+		}
+		if h.Payload != "" {
+			line += fmt.Sprintf(" payload %s", h.Payload)
+		}
+		if h.Expect != "" {
+			line += fmt.Sprintf(" expect %s", h.Expect)
+		}
+		if h.QueryName != "" {
+			line += fmt.Sprintf(" query %s", h.QueryName)
+		}
+		if h.QueryType != "" {
+			line += fmt.Sprintf(" qtype %s", h.QueryType)
+		}
+		if h.ExpectRcode != "" {
+			line += fmt.Sprintf(" rcode %s", h.ExpectRcode)
+		}
+		if h.MySQLUser != "" {
+			line += fmt.Sprintf(" user %s", h.MySQLUser)
+		}
+		if h.Combine != "" {
+			line += fmt.Sprintf(" combine %s", h.Combine)
+		}
+		fmt.Fprintln(s.out, line)
+		for _, p := range h.Probes {
+			line := fmt.Sprintf("  health probe %s", p.Type)
+			if p.Port > 0 {
+				line += fmt.Sprintf(" port %d", p.Port)
+			}
+			if p.Payload != "" {
+				line += fmt.Sprintf(" payload %s", p.Payload)
+			}
+			if p.Expect != "" {
+				line += fmt.Sprintf(" expect %s", p.Expect)
+			}
+			if p.QueryName != "" {
+				line += fmt.Sprintf(" query %s", p.QueryName)
+			}
+			if p.QueryType != "" {
+				line += fmt.Sprintf(" qtype %s", p.QueryType)
+			}
+			if p.ExpectRcode != "" {
+				line += fmt.Sprintf(" rcode %s", p.ExpectRcode)
+			}
+			if p.MySQLUser != "" {
+				line += fmt.Sprintf(" user %s", p.MySQLUser)
+			}
+			fmt.Fprintln(s.out, line)
+		}
 	}
 	return nil
 }
 
 func (m *ServiceMode) health(args []string) error {
 	if len(args) == 0 {
-		return errors.New("usage: health tcp port <p> interval <ms> timeout <ms>")
+		return usageError("usage: health <tcp|udp|dns|redis|mysql> port <p> interval <ms> timeout <ms>")
+	}
+	if strings.EqualFold(args[0], "probe") {
+		return m.healthProbe(args[1:])
 	}
-	if strings.ToLower(args[0]) != "tcp" {
-		return errors.New("only tcp health checks supported")
+	checkType := strings.ToLower(args[0])
+	switch checkType {
+	case "tcp", "udp", "dns", "redis", "mysql":
+	default:
+		return errors.New("only tcp, udp, dns, redis and mysql health checks supported")
 	}
 	h := config.HealthCheck{
 		Enabled:      true,
-		Type:         "tcp",
+		Type:         checkType,
 		FailAfter:    3,
 		RecoverAfter: 2,
 	}
@@ -202,6 +375,98 @@ func (m *ServiceMode) health(args []string) error {
 				return err
 			}
 			h.RecoverAfter = v
+		case "jitter":
+			i++
+			if i >= len(args) {
+				return errors.New("missing jitter percent")
+			}
+			v, err := strconv.Atoi(args[i])
+			if err != nil {
+				return err
+			}
+			h.JitterPct = v
+		case "slow-start":
+			i++
+			if i >= len(args) {
+				return errors.New("missing slow-start duration")
+			}
+			v, err := strconv.Atoi(args[i])
+			if err != nil {
+				return err
+			}
+			h.SlowStartMS = v
+		case "slow-steps":
+			i++
+			if i >= len(args) {
+				return errors.New("missing slow-start steps")
+			}
+			v, err := strconv.Atoi(args[i])
+			if err != nil {
+				return err
+			}
+			h.SlowStartSteps = v
+		case "adaptive":
+			h.AdaptiveWeight = true
+		case "icmp-precheck":
+			h.ICMPPrecheck = true
+		case "min-healthy":
+			i++
+			if i >= len(args) {
+				return errors.New("missing min-healthy count")
+			}
+			v, err := strconv.Atoi(args[i])
+			if err != nil {
+				return err
+			}
+			h.MinHealthy = v
+		case "fallback":
+			i++
+			if i >= len(args) {
+				return errors.New("missing fallback policy")
+			}
+			h.Fallback = strings.ToLower(args[i])
+		case "payload":
+			i++
+			if i >= len(args) {
+				return errors.New("missing payload")
+			}
+			h.Payload = args[i]
+		case "expect":
+			i++
+			if i >= len(args) {
+				return errors.New("missing expect")
+			}
+			h.Expect = args[i]
+		case "query":
+			i++
+			if i >= len(args) {
+				return errors.New("missing query name")
+			}
+			h.QueryName = args[i]
+		case "qtype":
+			i++
+			if i >= len(args) {
+				return errors.New("missing query type")
+			}
+			h.QueryType = strings.ToUpper(args[i])
+		case "rcode":
+			i++
+			if i >= len(args) {
+				return errors.New("missing rcode")
+			}
+			h.ExpectRcode = strings.ToUpper(args[i])
+		case "user":
+			i++
+			if i >= len(args) {
+				return errors.New("missing mysql user")
+			}
+			h.MySQLUser = args[i]
+		case "combine":
+			i++
+			if i >= len(args) {
+				return errors.New("missing combine mode")
+			}
+			h.Combine = strings.ToLower(args[i])
 		default:
 			return fmt.Errorf("unknown health field: %s", args[i])
 		}
@@ -211,6 +476,53 @@ func (m *ServiceMode) health(args []string) error {
 	return nil
 }
 
+// healthProbe adds a probe to the service's existing health check
+func (m *ServiceMode) healthProbe(args []string) error {
+	if len(args) == 0 {
+		return usageError("usage: health probe <tcp|udp|dns|redis|mysql> [port <p>] [payload <s>] [expect <s>] [query <name>] [qtype <t>] [rcode <name>] [user <name>]")
+	}
+	if !m.Service.Health.Enabled {
+		return errors.New("configure a health check before adding probes")
+	}
+	p := config.HealthProbe{Type: strings.ToLower(args[0])}
+	switch p.Type {
+	case "tcp", "udp", "dns", "redis", "mysql":
+	default:
+		return errors.New("only tcp, udp, dns, redis and mysql health probes supported")
+	}
+
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return fmt.Errorf("missing value for %s", args[i])
+		}
+		v := args[i+1]
+		switch strings.ToLower(args[i]) {
+		case "port":
+			port, err := strconv.Atoi(v)
+			if err != nil {
+				return err
+			}
+			p.Port = port
+		case "payload":
+			p.Payload = v
+		case "expect":
+			p.Expect = v
+		case "query":
+			p.QueryName = v
+		case "qtype":
+			p.QueryType = strings.ToUpper(v)
+		case "rcode":
+			p.ExpectRcode = strings.ToUpper(v)
+		case "user":
+			p.MySQLUser = v
+		default:
+			return fmt.Errorf("unknown health probe field: %s", args[i])
+		}
+	}
+	m.Service.Health.Probes = append(m.Service.Health.Probes, p)
+	return nil
+}
+
 func parseCSVPorts(s string) ([]int, error) {
 	var ports []int
 	for _, p := range strings.Split(s, ",") {
@@ -230,7 +542,7 @@ func parseCSVPorts(s string) ([]int, error) {
 func parsePortRange(s string) (config.PortRange, error) {
 	parts := strings.Split(s, "-")
 	if len(parts) != 2 {
-		return config.PortRange{}, errors.New("usage: port-range <start-end>")
+		return config.PortRange{}, usageError("usage: port-range <start-end>")
 	}
 	start, err := strconv.Atoi(parts[0])
 	if err != nil {
@@ -243,3 +555,9 @@ func parsePortRange(s string) (config.PortRange, error) {
 	return config.PortRange{Start: start, End: end}, nil
 }
 
+// isBackendOption reports whether tok starts a backend option rather than
+// giving the weight
+func isBackendOption(tok string) bool {
+	tok = strings.ToLower(tok)
+	return strings.HasPrefix(tok, "check-") || tok == "forward" || strings.HasSuffix(tok, "-threshold")
+}
@@ -6,8 +6,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/daemon"
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+	"github.com/malindarathnayake/LibraFlux/internal/ipvs"
+	"github.com/malindarathnayake/LibraFlux/internal/system"
 )
 
 var ErrExitShell = errors.New("exit shell")
@@ -26,9 +34,28 @@ type ShellOptions struct {
 	Err         io.Writer
 	ConfigPath  string
 	ConfigDir   string
+	StateDir    string // Where the daemon acknowledges reloads; defaults to system.state_dir of ConfigPath
 	LockManager *LockManager
 	IdleTimeout time.Duration
-	Now         func() time.Time
+	Clock       clock.Clock
+
+	// Reload asks the daemon to reload (e.g. by sending it SIGHUP). When set,
+	// commit triggers it and waits up to ReloadTimeout (default 10s) for the
+	// daemon to acknowledge the committed generation.
+	Reload        func() error
+	ReloadTimeout time.Duration
+
+	// Systemd writes the files for "install systemd"; defaults to the
+	// system unit and tmpfiles paths.
+	Systemd *system.SystemdInstaller
+
+	// IPVS is read by "show ipvs" when the daemon can't be reached; nil
+	// opens the kernel's IPVS table.
+	IPVS ipvs.Manager
+
+	// Control reaches the running daemon for show, doctor, reload and the
+	// other live commands; nil uses the control socket in StateDir.
+	Control *daemon.ControlClient
 }
 
 type Shell struct {
@@ -37,9 +64,16 @@ type Shell struct {
 	err         io.Writer
 	configPath  string
 	configDir   string
+	stateDir    string
 	lockManager *LockManager
 	idleTimeout time.Duration
-	now         func() time.Time
+	clock       clock.Clock
+
+	reload        func() error
+	reloadTimeout time.Duration
+	systemd       *system.SystemdInstaller
+	ipvs          ipvs.Manager
+	control       *daemon.ControlClient
 
 	mode        Mode
 	configMode  *ConfigMode
@@ -59,12 +93,26 @@ func New(opts ShellOptions) (*Shell, error) {
 	if opts.In == nil {
 		opts.In = strings.NewReader("")
 	}
-	if opts.Now == nil {
-		opts.Now = time.Now
+	if opts.Clock == nil {
+		opts.Clock = clock.Real()
 	}
 	if opts.IdleTimeout == 0 {
 		opts.IdleTimeout = 10 * time.Minute
 	}
+	if opts.ReloadTimeout == 0 {
+		opts.ReloadTimeout = 10 * time.Second
+	}
+	if opts.StateDir == "" {
+		// A config that doesn't load still gets a shell, so it can be fixed
+		cfg, _ := config.LoadRawConfig(opts.ConfigPath)
+		opts.StateDir = system.StateDir(cfg)
+	}
+	if opts.Systemd == nil {
+		opts.Systemd = system.NewSystemdInstaller()
+	}
+	if opts.Control == nil {
+		opts.Control = daemon.NewControlClient(filepath.Join(opts.StateDir, daemon.ControlSocketFile))
+	}
 
 	return &Shell{
 		in:          opts.In,
@@ -72,10 +120,17 @@ func New(opts ShellOptions) (*Shell, error) {
 		err:         opts.Err,
 		configPath:  opts.ConfigPath,
 		configDir:   opts.ConfigDir,
+		stateDir:    opts.StateDir,
 		lockManager: opts.LockManager,
 		idleTimeout: opts.IdleTimeout,
-		now:         opts.Now,
+		clock:       opts.Clock,
 		mode:        ModeRoot,
+
+		reload:        opts.Reload,
+		reloadTimeout: opts.ReloadTimeout,
+		systemd:       opts.Systemd,
+		ipvs:          opts.IPVS,
+		control:       opts.Control,
 	}, nil
 }
 
@@ -93,6 +148,12 @@ func (s *Shell) Prompt() string {
 }
 
 func (s *Shell) Run(ctx context.Context) error {
+	// Register as a reader so that lock status shows this session. The lock is
+	// advisory, so failing to take it never prevents the shell from running.
+	if reader, err := s.lockManager.AcquireShared(DefaultIdentity()); err == nil {
+		defer reader.Release()
+	}
+
 	sc := bufio.NewScanner(s.in)
 	for {
 		select {
@@ -108,11 +169,20 @@ func (s *Shell) Run(ctx context.Context) error {
 			if errors.Is(err, ErrExitShell) {
 				return nil
 			}
-			fmt.Fprintf(s.err, "error: %v\n", err)
+			fmt.Fprintf(s.err, "error [%s]: %v\n", errdefs.CodeOf(err), err)
 		}
 	}
 }
 
+// usageError is the error for a malformed command
+func usageError(usage string) error {
+	return errdefs.WithCode(errdefs.CodeUsage, errors.New(usage))
+}
+
+func unknownCommandf(format string, args ...interface{}) error {
+	return errdefs.WithCode(errdefs.CodeUnknownCommand, fmt.Errorf(format, args...))
+}
+
 func (s *Shell) ExecuteLine(line string) error {
 	line = strings.TrimSpace(line)
 	if line == "" {
@@ -121,8 +191,8 @@ func (s *Shell) ExecuteLine(line string) error {
 
 	if s.mode == ModeConfig || s.mode == ModeService {
 		if s.configMode != nil && s.idleTimeout > 0 {
-			last := s.configMode.lock.Metadata().LastActivity
-			if !last.IsZero() && s.now().UTC().Sub(last) > s.idleTimeout {
+			last := s.configMode.LastActivity()
+			if !last.IsZero() && s.clock.Now().UTC().Sub(last) > s.idleTimeout {
 				fmt.Fprintf(s.out, "Session idle for %s. Releasing lock...\n", s.idleTimeout.Round(time.Second))
 				_ = s.configMode.Abort(s)
 				s.leaveConfigureMode()
@@ -131,7 +201,10 @@ func (s *Shell) ExecuteLine(line string) error {
 		}
 	}
 
-	tokens := strings.Fields(line)
+	tokens, err := splitCommandLine(line)
+	if err != nil {
+		return err
+	}
 	if len(tokens) == 0 {
 		return nil
 	}
@@ -139,7 +212,6 @@ func (s *Shell) ExecuteLine(line string) error {
 		tokens = []string{"help"}
 	}
 
-	var err error
 	switch s.mode {
 	case ModeRoot:
 		err = s.handleRoot(tokens)
@@ -154,24 +226,31 @@ func (s *Shell) ExecuteLine(line string) error {
 		return err
 	}
 	if s.mode == ModeConfig || s.mode == ModeService {
-		if s.configMode != nil && s.configMode.lock != nil {
-			_ = s.configMode.lock.UpdateActivity()
+		if s.configMode != nil {
+			s.configMode.Touch(s.clock.Now().UTC())
 		}
 	}
 	return nil
 }
 
-func (s *Shell) enterConfigureMode() error {
+// enterConfigureMode starts a configure session. An exclusive session takes
+// the configuration lock; otherwise services are locked as they are edited.
+func (s *Shell) enterConfigureMode(intent LockIntent, exclusive bool) error {
 	if s.configMode != nil {
 		return nil
 	}
-	lock, err := s.lockManager.Acquire(DefaultIdentity())
-	if err != nil {
-		return err
+	var lock *HeldLock
+	if exclusive {
+		var err error
+		if lock, err = s.lockManager.AcquireExclusive(DefaultIdentity(), intent); err != nil {
+			return err
+		}
 	}
-	cm, err := NewConfigMode(s.configPath, s.configDir, s.idleTimeout, lock)
+	cm, err := NewConfigMode(s.configPath, s.configDir, s.idleTimeout, lock, s.lockManager, intent)
 	if err != nil {
-		_ = lock.Release()
+		if lock != nil {
+			_ = lock.Release()
+		}
 		return err
 	}
 	s.configMode = cm
@@ -180,8 +259,8 @@ func (s *Shell) enterConfigureMode() error {
 }
 
 func (s *Shell) leaveConfigureMode() {
-	if s.configMode != nil && s.configMode.lock != nil {
-		_ = s.configMode.lock.Release()
+	if s.configMode != nil {
+		s.configMode.Release()
 	}
 	s.configMode = nil
 	s.serviceMode = nil
@@ -215,3 +294,67 @@ func (s *Shell) leaveServiceMode() error {
 	return nil
 }
 
+// splitCommandLine splits line on whitespace, keeping double-quoted sections
+// (e.g. a lock reason) together as a single token.
+func splitCommandLine(line string) ([]string, error) {
+	var tokens []string
+	var cur strings.Builder
+	inQuote, inToken := false, false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			inToken = true
+		case !inQuote && (r == ' ' || r == '\t'):
+			if inToken {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+				inToken = false
+			}
+		default:
+			cur.WriteRune(r)
+			inToken = true
+		}
+	}
+	if inQuote {
+		return nil, errors.New("unterminated quote")
+	}
+	if inToken {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens, nil
+}
+
+// reloadPollInterval is how often commit checks for the daemon's
+// acknowledgement of a reload.
+const reloadPollInterval = 100 * time.Millisecond
+
+// awaitReload asks the daemon to reload and waits until it acknowledges
+// generation gen or newer. Without a reload hook the commit stays on disk
+// until the next reload.
+func (s *Shell) awaitReload(gen uint64) error {
+	if s.reload == nil {
+		return nil
+	}
+	if err := s.reload(); err != nil {
+		return fmt.Errorf("committed generation %d but reload failed: %w", gen, err)
+	}
+	deadline := s.clock.Now().Add(s.reloadTimeout)
+	for {
+		applied, err := config.ReadAppliedGeneration(s.stateDir)
+		if err != nil {
+			return err
+		}
+		if applied != nil && applied.Rejected >= gen {
+			return errdefs.WithCode(errdefs.CodeReloadRejected, fmt.Errorf("daemon rejected generation %d: %s", applied.Rejected, applied.Error))
+		}
+		if applied != nil && applied.Generation >= gen {
+			fmt.Fprintf(s.out, "Daemon applied generation %d.\n", applied.Generation)
+			return nil
+		}
+		if !s.clock.Now().Before(deadline) {
+			return errdefs.WithCode(errdefs.CodeReloadRejected, fmt.Errorf("timed out after %s waiting for daemon to apply generation %d", s.reloadTimeout, gen))
+		}
+		s.clock.Sleep(reloadPollInterval)
+	}
+}
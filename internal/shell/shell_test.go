@@ -4,92 +4,1018 @@ package shell
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/clock"
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/daemon"
+	"github.com/malindarathnayake/LibraFlux/internal/health"
+	"github.com/malindarathnayake/LibraFlux/internal/ipvs"
+	"github.com/malindarathnayake/LibraFlux/internal/observability"
+	"github.com/malindarathnayake/LibraFlux/internal/system"
 )
 
-func TestShellRootHelpAndCompletion(t *testing.T) {
+func TestShellRootHelpAndCompletion(t *testing.T) {
+	dir := t.TempDir()
+	configPath, configDir := writeTestConfig(t, dir)
+
+	var out bytes.Buffer
+	var errOut bytes.Buffer
+	clk := clock.Real()
+	lockPath := filepath.Join(dir, "config.lock")
+	mgr := &LockManager{Path: lockPath, ExpectedComm: "lbctl", Clock: clk}
+
+	sh, err := New(ShellOptions{
+		Out:         &out,
+		Err:         &errOut,
+		ConfigPath:  configPath,
+		ConfigDir:   configDir,
+		LockManager: mgr,
+		Clock:       clk,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	if err := sh.ExecuteLine("help"); err != nil {
+		t.Fatalf("help error: %v", err)
+	}
+	if got := out.String(); !bytes.Contains([]byte(got), []byte("configure")) {
+		t.Fatalf("expected help to mention configure, got: %s", got)
+	}
+
+	compl := sh.Complete("sh")
+	found := false
+	for _, c := range compl {
+		if c == "show" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected completion to include show, got %#v", compl)
+	}
+}
+
+func TestShellConfigureServiceCommit(t *testing.T) {
+	dir := t.TempDir()
+	configPath, configDir := writeTestConfig(t, dir)
+
+	var out bytes.Buffer
+	var errOut bytes.Buffer
+
+	lockPath := filepath.Join(dir, "config.lock")
+	mgr := &LockManager{Path: lockPath, ExpectedComm: "lbctl"}
+	sh, err := New(ShellOptions{
+		Out:         &out,
+		Err:         &errOut,
+		ConfigPath:  configPath,
+		ConfigDir:   configDir,
+		LockManager: mgr,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	steps := []string{
+		"configure service svc1",
+		"protocol udp",
+		"port-range 1000-1001",
+		"scheduler sh",
+		"backend 10.0.0.1",
+		"health tcp port 8080 interval 1000 timeout 300",
+		"exit",
+		"commit",
+		"exit",
+	}
+	for _, step := range steps {
+		if err := sh.ExecuteLine(step); err != nil {
+			t.Fatalf("step %q error: %v", step, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(configDir, "svc1.yaml")); err != nil {
+		t.Fatalf("expected service file written: %v", err)
+	}
+}
+
+func TestShellConfigureKeepsPools(t *testing.T) {
+	dir := t.TempDir()
+	configPath, configDir := writeTestConfig(t, dir)
+
+	pool := config.BackendPool{
+		CIDR:      "10.0.1.0/29",
+		Port:      8080,
+		Weight:    10,
+		Exclude:   []string{"10.0.1.2"},
+		Overrides: []config.PoolOverride{{Address: "10.0.1.3", Weight: 50}},
+	}
+	svc := config.Service{
+		Name:      "web",
+		Protocol:  "tcp",
+		Ports:     []int{80},
+		Scheduler: "rr",
+		Backends:  []config.Backend{{Address: "10.0.0.9", Port: 8080, Weight: 1}},
+		Pools:     []config.BackendPool{pool},
+	}
+	if err := config.WriteServiceConfig(configDir, svc); err != nil {
+		t.Fatalf("WriteServiceConfig: %v", err)
+	}
+
+	mgr := &LockManager{Path: filepath.Join(dir, "config.lock"), ExpectedComm: "lbctl"}
+	sh, err := New(ShellOptions{
+		Out:         &bytes.Buffer{},
+		Err:         &bytes.Buffer{},
+		ConfigPath:  configPath,
+		ConfigDir:   configDir,
+		LockManager: mgr,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	for _, step := range []string{"configure service web", "scheduler wrr", "exit", "commit", "exit"} {
+		if err := sh.ExecuteLine(step); err != nil {
+			t.Fatalf("step %q error: %v", step, err)
+		}
+	}
+
+	raw, err := config.LoadRawConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadRawConfig: %v", err)
+	}
+	if len(raw.Services) != 1 {
+		t.Fatalf("expected one service, got %d", len(raw.Services))
+	}
+	got := raw.Services[0]
+	if got.Scheduler != "wrr" {
+		t.Fatalf("expected the edit to be committed, got scheduler %q", got.Scheduler)
+	}
+	if len(got.Backends) != 1 || !reflect.DeepEqual(got.Pools, []config.BackendPool{pool}) {
+		t.Fatalf("expected the pool written back unexpanded, got backends %v pools %#v", got.Backends, got.Pools)
+	}
+
+	// The daemon still sees the pool's addresses
+	loaded, err := config.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if n := len(loaded.Services[0].Backends); n != 6 {
+		t.Fatalf("expected the explicit backend and five pool addresses, got %d", n)
+	}
+}
+
+func TestShellShowSystemFiles(t *testing.T) {
+	dir := t.TempDir()
+	configPath, configDir := writeTestConfig(t, dir)
+
+	var out bytes.Buffer
+	mgr := &LockManager{Path: filepath.Join(dir, "config.lock"), ExpectedComm: "lbctl"}
+	sh, err := New(ShellOptions{
+		Out:         &out,
+		Err:         &bytes.Buffer{},
+		ConfigPath:  configPath,
+		ConfigDir:   configDir,
+		LockManager: mgr,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	if err := sh.ExecuteLine("show system-files"); err != nil {
+		t.Fatalf("show system-files error: %v", err)
+	}
+	got := out.String()
+	for _, want := range []string{"# frr: ", system.FRRManagedBegin, " vrrp 1 priority 150\n", "# sysctl: ", "net.ipv4.ip_forward = 1\n"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in:\n%s", want, got)
+		}
+	}
+
+	out.Reset()
+	if err := sh.ExecuteLine("show system-files --json"); err != nil {
+		t.Fatalf("show system-files --json error: %v", err)
+	}
+	var files []system.RenderedFile
+	if err := json.Unmarshal(out.Bytes(), &files); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out.String())
+	}
+	if len(files) != 2 || files[0].Kind != system.FileKindFRR || files[1].Kind != system.FileKindSysctl {
+		t.Fatalf("unexpected files: %+v", files)
+	}
+}
+
+func TestShellShowServicesSelector(t *testing.T) {
+	dir := t.TempDir()
+	configPath, configDir := writeTestConfig(t, dir)
+
+	var out bytes.Buffer
+	mgr := &LockManager{Path: filepath.Join(dir, "config.lock"), ExpectedComm: "lbctl"}
+	sh, err := New(ShellOptions{
+		Out:         &out,
+		Err:         &bytes.Buffer{},
+		ConfigPath:  configPath,
+		ConfigDir:   configDir,
+		LockManager: mgr,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	steps := []string{
+		"configure service pay",
+		"ports 443",
+		"backend 10.0.0.1",
+		"label team payments",
+		"label env prod",
+		"exit",
+		"service search",
+		"ports 9200",
+		"backend 10.0.0.2",
+		"label team discovery",
+		"exit",
+		"commit",
+		"exit",
+	}
+	for _, step := range steps {
+		if err := sh.ExecuteLine(step); err != nil {
+			t.Fatalf("step %q error: %v", step, err)
+		}
+	}
+
+	out.Reset()
+	if err := sh.ExecuteLine("show services --selector team=payments"); err != nil {
+		t.Fatalf("show services error: %v", err)
+	}
+	if got := out.String(); got != "pay tcp backends=1 env=prod,team=payments\n" {
+		t.Fatalf("unexpected selector output: %q", got)
+	}
+
+	out.Reset()
+	if err := sh.ExecuteLine("show services"); err != nil {
+		t.Fatalf("show services error: %v", err)
+	}
+	if got := strings.Count(out.String(), "\n"); got != 2 {
+		t.Fatalf("expected both services without a selector, got %q", out.String())
+	}
+
+	if err := sh.ExecuteLine("show services --selector Team=x"); err == nil {
+		t.Fatalf("expected invalid selector to fail")
+	}
+}
+
+func TestShellDoctorProbes(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	// A port nothing listens on, for the failing backend
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	dir := t.TempDir()
+	configPath, configDir := writeTestConfig(t, dir)
+
+	var out bytes.Buffer
+	mgr := &LockManager{Path: filepath.Join(dir, "config.lock"), ExpectedComm: "lbctl"}
+	sh, err := New(ShellOptions{
+		Out:         &out,
+		Err:         &bytes.Buffer{},
+		ConfigPath:  configPath,
+		ConfigDir:   configDir,
+		LockManager: mgr,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	steps := []string{
+		"configure service web",
+		"ports 80",
+		"backend 127.0.0.1",
+		fmt.Sprintf("health tcp port %d interval 1000 timeout 500", port),
+		"exit",
+	}
+	for _, step := range steps {
+		if err := sh.ExecuteLine(step); err != nil {
+			t.Fatalf("step %q error: %v", step, err)
+		}
+	}
+
+	// Probes run against the pending config, before commit
+	out.Reset()
+	if err := sh.ExecuteLine("doctor probes"); err != nil {
+		t.Fatalf("doctor probes error: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "web 127.0.0.1") || !strings.Contains(out.String(), "1/1 probes passed") {
+		t.Fatalf("unexpected doctor output: %q", out.String())
+	}
+
+	for _, step := range []string{
+		"service web",
+		fmt.Sprintf("backend 192.0.2.1 1 check-address 127.0.0.1 check-port %d", closedPort),
+		"exit",
+	} {
+		if err := sh.ExecuteLine(step); err != nil {
+			t.Fatalf("step %q error: %v", step, err)
+		}
+	}
+	out.Reset()
+	if err := sh.ExecuteLine("doctor probes"); err == nil {
+		t.Fatalf("expected a failing probe to fail doctor probes")
+	}
+	if !strings.Contains(out.String(), "web 192.0.2.1 (127.0.0.1:"+strconv.Itoa(closedPort)+") FAIL") {
+		t.Fatalf("expected failing backend in output: %q", out.String())
+	}
+}
+
+func TestShellConfigureLockIntent(t *testing.T) {
+	dir := t.TempDir()
+	configPath, configDir := writeTestConfig(t, dir)
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	clk := clock.NewFake(now)
+	lockPath := filepath.Join(dir, "config.lock")
+	pid := os.Getpid()
+	checker := fakeChecker{alive: map[int]bool{pid: true}, comm: map[int]string{pid: "lbctl"}}
+
+	newShell := func(out *bytes.Buffer) *Shell {
+		mgr := &LockManager{Path: lockPath, ExpectedComm: "lbctl", Checker: checker, Clock: clk}
+		sh, err := New(ShellOptions{
+			Out:         out,
+			Err:         out,
+			ConfigPath:  configPath,
+			ConfigDir:   configDir,
+			LockManager: mgr,
+			Clock:       clk,
+		})
+		if err != nil {
+			t.Fatalf("New() error: %v", err)
+		}
+		return sh
+	}
+
+	var holderOut, otherOut bytes.Buffer
+	holder := newShell(&holderOut)
+	other := newShell(&otherOut)
+
+	for _, bad := range []string{
+		"configure --duration soon",
+		"configure --duration -5m",
+		"configure --reason",
+		"configure --ticket 123",
+		`configure --reason "unterminated`,
+	} {
+		if err := holder.ExecuteLine(bad); err == nil {
+			t.Fatalf("expected %q to fail", bad)
+		}
+	}
+
+	if err := holder.ExecuteLine(`configure exclusive --reason "adding svc payments" --duration 30m`); err != nil {
+		t.Fatalf("configure error: %v", err)
+	}
+	clk.Advance(10 * time.Minute)
+
+	if err := other.ExecuteLine("lock status"); err != nil {
+		t.Fatalf("lock status error: %v", err)
+	}
+	got := otherOut.String()
+	for _, want := range []string{"Reason:   adding svc payments", "10m0s of planned 30m0s (20m0s remaining"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("lock status missing %q:\n%s", want, got)
+		}
+	}
+
+	err := other.ExecuteLine("configure service web")
+	if err == nil || !strings.Contains(err.Error(), `reason "adding svc payments"`) {
+		t.Fatalf("expected lock held error with reason, got %v", err)
+	}
+	if err := other.ExecuteLine("exit"); err != nil {
+		t.Fatalf("exit error: %v", err)
+	}
+
+	clk.Advance(25 * time.Minute)
+	otherOut.Reset()
+	if err := other.ExecuteLine("lock status"); err != nil {
+		t.Fatalf("lock status error: %v", err)
+	}
+	if got := otherOut.String(); !strings.Contains(got, "overrun by 5m0s") {
+		t.Fatalf("expected overrun in lock status:\n%s", got)
+	}
+}
+
+func TestShellConcurrentServiceSessions(t *testing.T) {
+	dir := t.TempDir()
+	configPath, configDir := writeTestConfig(t, dir)
+
+	lockPath := filepath.Join(dir, "config.lock")
+	pid := os.Getpid()
+	checker := fakeChecker{alive: map[int]bool{pid: true}, comm: map[int]string{pid: "lbctl"}}
+	newShell := func(out *bytes.Buffer) *Shell {
+		mgr := &LockManager{Path: lockPath, ExpectedComm: "lbctl", Checker: checker}
+		sh, err := New(ShellOptions{Out: out, Err: out, ConfigPath: configPath, ConfigDir: configDir, LockManager: mgr})
+		if err != nil {
+			t.Fatalf("New() error: %v", err)
+		}
+		return sh
+	}
+	run := func(sh *Shell, steps ...string) {
+		t.Helper()
+		for _, step := range steps {
+			if err := sh.ExecuteLine(step); err != nil {
+				t.Fatalf("step %q error: %v", step, err)
+			}
+		}
+	}
+
+	var aliceOut, bobOut, carolOut bytes.Buffer
+	alice, bob, carol := newShell(&aliceOut), newShell(&bobOut), newShell(&carolOut)
+
+	run(alice, `configure --reason "web rollout"`, "service web", "ports 80", "backend 10.0.0.1", "exit")
+	if err := bob.ExecuteLine("configure service web"); err == nil || !strings.Contains(err.Error(), "service web locked by") {
+		t.Fatalf("expected web to be locked, got %v", err)
+	}
+	if bob.Mode() != ModeConfig {
+		t.Fatalf("expected bob to stay in configure mode, got %v", bob.Mode())
+	}
+	run(bob, "service api", "ports 8080", "backend 10.0.0.2", "exit")
+
+	if err := carol.ExecuteLine("configure exclusive"); err == nil || !strings.Contains(err.Error(), "service api locked by") {
+		t.Fatalf("expected exclusive configure to wait for service locks, got %v", err)
+	}
+	run(carol, "lock status")
+	if got := carolOut.String(); !strings.Contains(got, "Services locked (2):") || !strings.Contains(got, `web by`) || !strings.Contains(got, `reason "web rollout"`) {
+		t.Fatalf("expected lock status to list service locks:\n%s", got)
+	}
+
+	// A change to the shared main config refuses the next commit once
+	main, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, append(main, "# edited\n"...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := alice.ExecuteLine("commit"); err == nil || !strings.Contains(err.Error(), "config.yaml changed") {
+		t.Fatalf("expected a main config conflict, got %v", err)
+	}
+	run(alice, "commit", "service web", "exit")
+	if err := bob.ExecuteLine("commit"); err == nil || !strings.Contains(err.Error(), "config.yaml changed") {
+		t.Fatalf("expected bob to see the main config conflict too, got %v", err)
+	}
+	run(bob, "commit")
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	var names []string
+	for _, svc := range cfg.Services {
+		names = append(names, svc.Name)
+	}
+	if got := strings.Join(names, ","); got != "api,web" {
+		t.Fatalf("expected both sessions' services committed, got %s", got)
+	}
+	if gen, err := config.ReadGeneration(configDir); err != nil || gen != 4 {
+		t.Fatalf("expected generation 4 after two commits, got %d, %v", gen, err)
+	}
+
+	// Alice still holds web, having entered it after her commit
+	if err := carol.ExecuteLine("configure exclusive"); err == nil || !strings.Contains(err.Error(), "service web locked by") {
+		t.Fatalf("expected exclusive configure to wait for web, got %v", err)
+	}
+	run(alice, "exit")
+	run(carol, "configure exclusive")
+	if err := bob.ExecuteLine("service api"); err == nil || !strings.Contains(err.Error(), "configuration locked by") {
+		t.Fatalf("expected the configuration lock to block service edits, got %v", err)
+	}
+}
+
+func TestShellRunRegistersReader(t *testing.T) {
+	dir := t.TempDir()
+	configPath, configDir := writeTestConfig(t, dir)
+
+	var out bytes.Buffer
+	lockPath := filepath.Join(dir, "config.lock")
+	mgr := &LockManager{Path: lockPath, ExpectedComm: "lbctl"}
+	sh, err := New(ShellOptions{
+		In:          strings.NewReader("lock status\nexit\n"),
+		Out:         &out,
+		Err:         &out,
+		ConfigPath:  configPath,
+		ConfigDir:   configDir,
+		LockManager: mgr,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if err := sh.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if got := out.String(); !strings.Contains(got, "Read-only sessions (1):") {
+		t.Fatalf("expected lock status to list this session, got:\n%s", got)
+	}
+
+	readers, err := mgr.Readers()
+	if err != nil {
+		t.Fatalf("Readers() error: %v", err)
+	}
+	if len(readers) != 0 {
+		t.Fatalf("expected reader released after Run, got %#v", readers)
+	}
+}
+
+func TestShellRunPrintsErrorCodes(t *testing.T) {
+	dir := t.TempDir()
+	configPath, configDir := writeTestConfig(t, dir)
+
+	var out, errOut bytes.Buffer
+	sh, err := New(ShellOptions{
+		In:          strings.NewReader("frobnicate\nmaintenance sideways\nexit\n"),
+		Out:         &out,
+		Err:         &errOut,
+		ConfigPath:  configPath,
+		ConfigDir:   configDir,
+		LockManager: &LockManager{Path: filepath.Join(dir, "config.lock"), ExpectedComm: "lbctl"},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if err := sh.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	want := "error [LBCTL-E1003]: unknown command: frobnicate\n" +
+		"error [LBCTL-E1002]: usage: maintenance <on|off>\n"
+	if got := errOut.String(); got != want {
+		t.Fatalf("unexpected errors:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestShellSchedulerFlags(t *testing.T) {
+	dir := t.TempDir()
+	configPath, configDir := writeTestConfig(t, dir)
+
+	var out bytes.Buffer
+	lockPath := filepath.Join(dir, "config.lock")
+	mgr := &LockManager{Path: lockPath, ExpectedComm: "lbctl"}
+	sh, err := New(ShellOptions{
+		Out:         &out,
+		Err:         &out,
+		ConfigPath:  configPath,
+		ConfigDir:   configDir,
+		LockManager: mgr,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	if err := sh.ExecuteLine("configure service svc1"); err != nil {
+		t.Fatalf("configure error: %v", err)
+	}
+	for _, bad := range []string{"scheduler fifo", "scheduler wlc sh-port", "scheduler sh sh-sticky"} {
+		if err := sh.ExecuteLine(bad); err == nil {
+			t.Fatalf("expected %q to fail", bad)
+		}
+	}
+
+	if got := sh.Complete("scheduler wl"); len(got) != 1 || got[0] != "wlc" {
+		t.Fatalf("expected scheduler completion wlc, got %#v", got)
+	}
+	if got := sh.Complete("scheduler sh "); strings.Join(got, ",") != "sh-fallback,sh-port" {
+		t.Fatalf("expected sh flag completion, got %#v", got)
+	}
+	if got := sh.Complete("scheduler rr "); len(got) != 0 {
+		t.Fatalf("expected no flag completion for rr, got %#v", got)
+	}
+
+	steps := []string{
+		"protocol tcp",
+		"ports 80",
+		"scheduler SH sh-port sh-fallback",
+		"backend 10.0.0.1",
+		"show",
+		"exit",
+		"commit",
+	}
+	for _, step := range steps {
+		if err := sh.ExecuteLine(step); err != nil {
+			t.Fatalf("step %q error: %v", step, err)
+		}
+	}
+	if !strings.Contains(out.String(), "scheduler sh sh-port sh-fallback") {
+		t.Fatalf("expected show to include scheduler flags, got:\n%s", out.String())
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	for _, svc := range cfg.Services {
+		if svc.Name == "svc1" {
+			if svc.Scheduler != "sh" || strings.Join(svc.SchedulerFlags, ",") != "sh-port,sh-fallback" {
+				t.Fatalf("unexpected scheduler in committed config: %s %v", svc.Scheduler, svc.SchedulerFlags)
+			}
+			return
+		}
+	}
+	t.Fatalf("svc1 not found in committed config")
+}
+
+func TestShellBackendForward(t *testing.T) {
+	dir := t.TempDir()
+	configPath, configDir := writeTestConfig(t, dir)
+
+	var out bytes.Buffer
+	mgr := &LockManager{Path: filepath.Join(dir, "config.lock"), ExpectedComm: "lbctl"}
+	sh, err := New(ShellOptions{
+		Out:         &out,
+		Err:         &out,
+		ConfigPath:  configPath,
+		ConfigDir:   configDir,
+		LockManager: mgr,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	if err := sh.ExecuteLine("configure service svc1"); err != nil {
+		t.Fatalf("configure error: %v", err)
+	}
+	for _, bad := range []string{"backend 10.0.0.1 forward gre", "backend 10.0.0.1 forward", "backend 10.0.0.1 upper-threshold -1"} {
+		if err := sh.ExecuteLine(bad); err == nil {
+			t.Fatalf("expected %q to fail", bad)
+		}
+	}
+	for _, step := range []string{"backend 10.0.0.1 forward nat", "backend 10.0.0.2 5 forward TUN", "backend 10.0.0.3 upper-threshold 100 lower-threshold 50", "show"} {
+		if err := sh.ExecuteLine(step); err != nil {
+			t.Fatalf("step %q error: %v", step, err)
+		}
+	}
+	for _, want := range []string{"backend 10.0.0.1 weight 1 forward nat", "backend 10.0.0.2 weight 5 forward tun", "backend 10.0.0.3 weight 1 upper-threshold 100 lower-threshold 50"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected show to include %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestShellCommitWaitsForReloadAck(t *testing.T) {
+	dir := t.TempDir()
+	configPath, configDir := writeTestConfig(t, dir)
+	stateDir := t.TempDir()
+
+	clk := clock.NewFake(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	var reloads int
+	var daemonErr string
+	ack := true
+	// Stands in for the daemon: reload the committed config and acknowledge it.
+	reload := func() error {
+		reloads++
+		if !ack {
+			return nil
+		}
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			return err
+		}
+		if daemonErr != "" {
+			return config.WriteAppliedGeneration(stateDir, config.AppliedGeneration{Generation: 2, Rejected: cfg.Generation, Error: daemonErr})
+		}
+		return config.WriteAppliedGeneration(stateDir, config.AppliedGeneration{Generation: cfg.Generation})
+	}
+
+	var out bytes.Buffer
+	lockPath := filepath.Join(dir, "config.lock")
+	sh, err := New(ShellOptions{
+		Out:           &out,
+		Err:           &out,
+		ConfigPath:    configPath,
+		ConfigDir:     configDir,
+		StateDir:      stateDir,
+		LockManager:   &LockManager{Path: lockPath, ExpectedComm: "lbctl", Clock: clk},
+		Clock:         clk,
+		Reload:        reload,
+		ReloadTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	stage := func(name string) {
+		t.Helper()
+		for _, step := range []string{
+			"service " + name,
+			"protocol tcp",
+			"ports 80",
+			"backend 10.0.0.1",
+			"exit",
+		} {
+			if err := sh.ExecuteLine(step); err != nil {
+				t.Fatalf("step %q error: %v", step, err)
+			}
+		}
+	}
+
+	if err := sh.ExecuteLine("configure"); err != nil {
+		t.Fatalf("configure error: %v", err)
+	}
+	stage("svc1")
+	if err := sh.ExecuteLine("commit"); err != nil {
+		t.Fatalf("commit error: %v", err)
+	}
+	if got := out.String(); !strings.Contains(got, "Committed (generation 2).") || !strings.Contains(got, "Daemon applied generation 2.") {
+		t.Fatalf("expected commit acknowledged, got:\n%s", got)
+	}
+
+	stage("svc2")
+	daemonErr = "service svc2: rejected"
+	if err := sh.ExecuteLine("commit"); err == nil || !strings.Contains(err.Error(), "daemon rejected generation 4") {
+		t.Fatalf("expected rejection error, got %v", err)
+	}
+
+	out.Reset()
+	if err := sh.ExecuteLine("exit"); err != nil {
+		t.Fatalf("exit error: %v", err)
+	}
+	since := time.Date(2025, 1, 2, 3, 0, 0, 0, time.UTC)
+	if err := daemon.WriteEngineStatus(stateDir, daemon.EngineStatus{State: daemon.StateDegradedBackoff, Since: since}); err != nil {
+		t.Fatalf("write engine state: %v", err)
+	}
+	if err := sh.ExecuteLine("show status"); err != nil {
+		t.Fatalf("show status error: %v", err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"Engine state:              degraded-backoff (since 2025-01-02T03:00:00Z)",
+		"Config generation on disk: 4",
+		"Applied generation:        2",
+		"Last reload rejected generation 4: service svc2: rejected",
+		"WARNING: on-disk config differs",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("show status missing %q:\n%s", want, got)
+		}
+	}
+	if err := sh.ExecuteLine("configure"); err != nil {
+		t.Fatalf("configure error: %v", err)
+	}
+
+	stage("svc3")
+	ack = false
+	if err := sh.ExecuteLine("commit"); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if reloads != 3 {
+		t.Fatalf("expected 3 reloads, got %d", reloads)
+	}
+}
+
+func TestShellServiceReload(t *testing.T) {
 	dir := t.TempDir()
 	configPath, configDir := writeTestConfig(t, dir)
+	web := config.Service{
+		Name:      "web",
+		Protocol:  "tcp",
+		Ports:     []int{80},
+		Scheduler: "rr",
+		Backends:  []config.Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}},
+	}
+	if err := config.WriteServiceConfig(configDir, web); err != nil {
+		t.Fatalf("write service: %v", err)
+	}
+	// WriteServiceConfig refuses invalid services, so edit one by hand
+	data, err := os.ReadFile(filepath.Join(configDir, "web.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(bytes.Replace(data, []byte("name: web"), []byte("name: bad"), 1), []byte("scheduler: rr"), []byte("scheduler: bogus"), 1)
+	if err := os.WriteFile(filepath.Join(configDir, "bad.yaml"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
 
 	var out bytes.Buffer
-	var errOut bytes.Buffer
-	now := time.Now
-	lockPath := filepath.Join(dir, "config.lock")
-	mgr := &LockManager{Path: lockPath, ExpectedComm: "lbctl", Now: now}
-
 	sh, err := New(ShellOptions{
 		Out:         &out,
-		Err:         &errOut,
+		Err:         &out,
 		ConfigPath:  configPath,
 		ConfigDir:   configDir,
-		LockManager: mgr,
-		Now:         now,
+		LockManager: &LockManager{Path: filepath.Join(dir, "config.lock"), ExpectedComm: "lbctl"},
 	})
 	if err != nil {
 		t.Fatalf("New() error: %v", err)
 	}
 
-	if err := sh.ExecuteLine("help"); err != nil {
-		t.Fatalf("help error: %v", err)
+	if err := sh.ExecuteLine("service reload"); err == nil {
+		t.Fatalf("expected a usage error without a service name")
 	}
-	if got := out.String(); !bytes.Contains([]byte(got), []byte("configure")) {
-		t.Fatalf("expected help to mention configure, got: %s", got)
+	if err := sh.ExecuteLine("service reload bad"); err == nil {
+		t.Fatalf("expected an invalid service to be rejected")
 	}
+	if err := sh.ExecuteLine("service reload web"); err != nil {
+		t.Fatalf("service reload web: %v", err)
+	}
+	if err := sh.ExecuteLine("service reload gone"); err != nil {
+		t.Fatalf("service reload gone: %v", err)
+	}
+	if !strings.Contains(out.String(), "Reload of service web requested") || !strings.Contains(out.String(), "daemon will remove it") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+	names, err := config.PendingServiceReloads(configDir)
+	if err != nil || strings.Join(names, ",") != "gone,web" {
+		t.Fatalf("expected reload requests for gone and web, got %v, %v", names, err)
+	}
+}
 
-	compl := sh.Complete("sh")
-	found := false
-	for _, c := range compl {
-		if c == "show" {
-			found = true
+func TestShellInstallSystemd(t *testing.T) {
+	dir := t.TempDir()
+	configPath, configDir := writeTestConfig(t, dir)
+
+	var out bytes.Buffer
+	clk := clock.Real()
+	installer := &system.SystemdInstaller{
+		UnitPath:     filepath.Join(dir, "systemd", "lbctl.service"),
+		TmpfilesPath: filepath.Join(dir, "tmpfiles.d", "lbctl.conf"),
+	}
+	sh, err := New(ShellOptions{
+		Out:         &out,
+		Err:         &out,
+		ConfigPath:  configPath,
+		ConfigDir:   configDir,
+		LockManager: &LockManager{Path: filepath.Join(dir, "config.lock"), ExpectedComm: "lbctl", Clock: clk},
+		Clock:       clk,
+		Systemd:     installer,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	if err := sh.ExecuteLine("install systemd --binary /opt/lbctl/lbctl"); err != nil {
+		t.Fatalf("install systemd: %v", err)
+	}
+	unit, err := os.ReadFile(installer.UnitPath)
+	if err != nil {
+		t.Fatalf("read unit: %v", err)
+	}
+	for _, want := range []string{
+		"ExecStart=/opt/lbctl/lbctl apply --config " + configPath + " --daemon\n",
+		// The daemon consumes reload requests from the include dir
+		configDir,
+	} {
+		if !strings.Contains(string(unit), want) {
+			t.Errorf("unit missing %q:\n%s", want, unit)
 		}
 	}
-	if !found {
-		t.Fatalf("expected completion to include show, got %#v", compl)
+	if _, err := os.Stat(installer.TmpfilesPath); err != nil {
+		t.Errorf("tmpfiles not written: %v", err)
+	}
+	if !strings.Contains(out.String(), "systemctl daemon-reload") {
+		t.Errorf("expected daemon-reload hint, got: %s", out.String())
+	}
+
+	if err := sh.ExecuteLine("install systemd --binary"); err == nil {
+		t.Error("expected usage error for --binary without a path")
 	}
 }
 
-func TestShellConfigureServiceCommit(t *testing.T) {
+func TestShellLint(t *testing.T) {
 	dir := t.TempDir()
 	configPath, configDir := writeTestConfig(t, dir)
 
 	var out bytes.Buffer
-	var errOut bytes.Buffer
-
-	lockPath := filepath.Join(dir, "config.lock")
-	mgr := &LockManager{Path: lockPath, ExpectedComm: "lbctl"}
 	sh, err := New(ShellOptions{
 		Out:         &out,
-		Err:         &errOut,
+		Err:         &out,
 		ConfigPath:  configPath,
 		ConfigDir:   configDir,
-		LockManager: mgr,
+		LockManager: &LockManager{Path: filepath.Join(dir, "config.lock"), ExpectedComm: "lbctl"},
 	})
 	if err != nil {
 		t.Fatalf("New() error: %v", err)
 	}
 
-	steps := []string{
-		"configure service svc1",
-		"protocol udp",
-		"port-range 1000-1001",
-		"scheduler sh",
-		"backend 10.0.0.1",
-		"health tcp port 8080 interval 1000 timeout 300",
-		"exit",
-		"commit",
-		"exit",
+	web := config.Service{
+		Name:      "web",
+		Protocol:  "tcp",
+		Ports:     []int{80},
+		Scheduler: "rr",
+		Backends:  []config.Backend{{Address: "10.0.0.1", Port: 80, Weight: 1}},
 	}
-	for _, step := range steps {
+	if err := config.WriteServiceConfig(configDir, web); err != nil {
+		t.Fatalf("write service: %v", err)
+	}
+	if err := sh.ExecuteLine("lint"); err == nil {
+		t.Fatalf("expected a single-backend service to be flagged")
+	}
+	if !strings.Contains(out.String(), config.LintSingleBackend+" web:") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+
+	out.Reset()
+	web.LintIgnore = []string{config.LintSingleBackend}
+	if err := config.WriteServiceConfig(configDir, web); err != nil {
+		t.Fatalf("write service: %v", err)
+	}
+	if err := sh.ExecuteLine("lint"); err != nil {
+		t.Fatalf("lint with suppression: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "no findings") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestShellScheduleChange(t *testing.T) {
+	dir := t.TempDir()
+	configPath, configDir := writeTestConfig(t, dir)
+
+	clk := clock.NewFake(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	var out bytes.Buffer
+	sh, err := New(ShellOptions{
+		Out:         &out,
+		Err:         &out,
+		ConfigPath:  configPath,
+		ConfigDir:   configDir,
+		LockManager: &LockManager{Path: filepath.Join(dir, "config.lock"), ExpectedComm: "lbctl", Clock: clk},
+		Clock:       clk,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	for _, step := range []string{"configure service web", "protocol tcp", "ports 80", "backend 10.0.0.1", "exit"} {
 		if err := sh.ExecuteLine(step); err != nil {
 			t.Fatalf("step %q error: %v", step, err)
 		}
 	}
+	if err := sh.ExecuteLine("apply at 2:00pm"); err == nil {
+		t.Fatalf("expected an unparseable time to be rejected")
+	}
+	if err := sh.ExecuteLine("apply at 2025-01-01T02:00"); err == nil {
+		t.Fatalf("expected a past time to be rejected")
+	}
+	// 02:00 has passed today, so it means tomorrow
+	if err := sh.ExecuteLine("apply at 02:00"); err != nil {
+		t.Fatalf("apply at: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(configDir, "web.yaml")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing committed before the activation time, got %v", err)
+	}
+	change, err := config.ReadScheduledChange(configDir)
+	if err != nil || change == nil {
+		t.Fatalf("expected a scheduled change, got %v, %v", change, err)
+	}
+	if want := time.Date(2025, 1, 3, 2, 0, 0, 0, time.UTC); !change.At.Equal(want) || len(change.Services) != 1 || change.Services[0].Name != "web" {
+		t.Fatalf("unexpected scheduled change %+v", change)
+	}
 
-	if _, err := os.Stat(filepath.Join(configDir, "svc1.yaml")); err != nil {
-		t.Fatalf("expected service file written: %v", err)
+	// Only one bundle waits at a time
+	if err := sh.ExecuteLine("delete web"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sh.ExecuteLine("apply at 03:00"); !errors.Is(err, config.ErrChangeScheduled) {
+		t.Fatalf("expected ErrChangeScheduled, got %v", err)
+	}
+	if err := sh.ExecuteLine("exit"); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	if err := sh.ExecuteLine("show schedule"); err != nil {
+		t.Fatalf("show schedule: %v", err)
+	}
+	if !strings.Contains(out.String(), "2025-01-03 02:00 UTC (in 22h56m0s)") || !strings.Contains(out.String(), "service web") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+	if err := sh.ExecuteLine("schedule cancel"); err != nil {
+		t.Fatalf("schedule cancel: %v", err)
+	}
+	out.Reset()
+	if err := sh.ExecuteLine("show schedule"); err != nil || !strings.Contains(out.String(), "No change scheduled.") {
+		t.Fatalf("expected no schedule after cancel, got %v:\n%s", err, out.String())
 	}
 }
 
@@ -153,3 +1079,355 @@ include: "config.d/*.yaml"
 	}
 	return configPath, configDir
 }
+
+func TestShellStateDirFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	configPath, configDir := writeTestConfig(t, dir)
+	opts := ShellOptions{
+		Out:         io.Discard,
+		Err:         io.Discard,
+		ConfigPath:  configPath,
+		ConfigDir:   configDir,
+		LockManager: &LockManager{Path: filepath.Join(dir, "config.lock"), ExpectedComm: "lbctl"},
+	}
+	sh, err := New(opts)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if sh.stateDir != "varliblbctl" {
+		t.Fatalf("expected system.state_dir of the config, got %q", sh.stateDir)
+	}
+
+	// A config that doesn't load falls back to the default
+	opts.ConfigPath = filepath.Join(dir, "missing.yaml")
+	if sh, err = New(opts); err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if sh.stateDir != system.DefaultStateDir {
+		t.Fatalf("expected %s, got %q", system.DefaultStateDir, sh.stateDir)
+	}
+}
+
+func TestShellShowIPVS(t *testing.T) {
+	dir := t.TempDir()
+	configPath, configDir := writeTestConfig(t, dir)
+
+	m := ipvs.NewSimManager(observability.NewLogger(observability.ErrorLevel))
+	svc := &ipvs.Service{Address: net.ParseIP("192.0.2.10"), Protocol: "tcp", Port: 80, Scheduler: "wrr", Timeout: 300, Netmask: 0xFFFFFF00}
+	if err := m.CreateService(svc); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	for _, addr := range []string{"10.0.0.2", "10.0.0.1"} {
+		if err := m.CreateDestination(svc, &ipvs.Destination{Address: net.ParseIP(addr), Port: 8080, Weight: 3, Forward: ipvs.ForwardDR}); err != nil {
+			t.Fatalf("CreateDestination: %v", err)
+		}
+	}
+
+	var out bytes.Buffer
+	sh, err := New(ShellOptions{
+		Out:         &out,
+		Err:         &out,
+		ConfigPath:  configPath,
+		ConfigDir:   configDir,
+		LockManager: &LockManager{Path: filepath.Join(dir, "config.lock"), ExpectedComm: "lbctl"},
+		IPVS:        m,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	if err := sh.ExecuteLine("show ipvs"); err != nil {
+		t.Fatalf("show ipvs: %v", err)
+	}
+	want := "TCP 192.0.2.10:80 wrr persistent 300 mask 255.255.255.0 conns=0 cps=0\n" +
+		"  -> 10.0.0.1:8080 dr weight=3 active=0 inactive=0\n" +
+		"  -> 10.0.0.2:8080 dr weight=3 active=0 inactive=0\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := sh.ExecuteLine("show ipvs --json"); err != nil {
+		t.Fatalf("show ipvs --json: %v", err)
+	}
+	var snap ipvs.Snapshot
+	if err := json.Unmarshal(out.Bytes(), &snap); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out.String())
+	}
+	if len(snap.Services) != 1 || len(snap.Services[0].Destinations) != 2 || snap.Services[0].Destinations[0].Address != "10.0.0.1" {
+		t.Fatalf("unexpected snapshot: %+v", snap)
+	}
+}
+
+type fakeController struct {
+	status    daemon.DaemonStatus
+	services  []daemon.ServiceStatus
+	backends  []daemon.BackendHealth
+	ipvs      *ipvs.Snapshot
+	reloads   int
+	switches  []string // Config paths of ReloadFrom
+	overrides []daemon.OverrideRequest
+	level     observability.LogLevel
+}
+
+func (c *fakeController) Status() daemon.DaemonStatus           { return c.status }
+func (c *fakeController) BackendHealth() []daemon.BackendHealth { return c.backends }
+func (c *fakeController) Services() []daemon.ServiceStatus      { return c.services }
+func (c *fakeController) Reconcile(context.Context) error       { return nil }
+
+func (c *fakeController) IPVS() (*ipvs.Snapshot, error) {
+	if c.ipvs == nil {
+		return nil, errors.New("no snapshot")
+	}
+	return c.ipvs, nil
+}
+
+func (c *fakeController) Reload(context.Context) error {
+	c.reloads++
+	c.status.Generation += 2
+	return nil
+}
+
+func (c *fakeController) ReloadFrom(ctx context.Context, path string) error {
+	if path == "" {
+		return c.Reload(ctx)
+	}
+	if path == "/etc/lbctl/broken.yaml" {
+		return errors.New("config /etc/lbctl/broken.yaml: invalid vip")
+	}
+	c.switches = append(c.switches, path)
+	c.status.Generation++
+	return nil
+}
+
+func (c *fakeController) SetBackendOverride(_ context.Context, service, backend string, mode health.Override, ttl time.Duration) error {
+	c.overrides = append(c.overrides, daemon.OverrideRequest{Service: service, Backend: backend, Mode: mode, TTLSeconds: int(ttl / time.Second)})
+	return nil
+}
+
+func (c *fakeController) ClearBackendOverride(_ context.Context, service, backend string) error {
+	c.overrides = append(c.overrides, daemon.OverrideRequest{Service: service, Backend: backend})
+	return nil
+}
+
+func (c *fakeController) SetLogLevel(_ context.Context, level observability.LogLevel) {
+	c.level = level
+}
+
+func (c *fakeController) SetMaintenance(_ context.Context, enabled bool) error {
+	c.status.Maintenance = enabled
+	return nil
+}
+
+func TestShellDaemonControl(t *testing.T) {
+	dir := t.TempDir()
+	configPath, configDir := writeTestConfig(t, dir)
+	since := time.Date(2025, 1, 2, 3, 0, 0, 0, time.UTC)
+	ctrl := &fakeController{
+		status: daemon.DaemonStatus{Node: "lb-a", State: daemon.StateActive, StateSince: since, Active: true, Ready: true, Services: 1, LogLevel: "info"},
+		backends: []daemon.BackendHealth{
+			{Service: "web", Backend: "10.0.0.1", State: health.StateHealthy, Weight: 5},
+			{Service: "web", Backend: "10.0.0.2", State: health.StateUnhealthy, Weight: 0, Override: health.OverrideDrain},
+		},
+	}
+	srv, err := daemon.ListenControl(filepath.Join(dir, daemon.ControlSocketFile), ctrl)
+	if err != nil {
+		t.Fatalf("ListenControl: %v", err)
+	}
+	go srv.Serve()
+	defer srv.Close()
+
+	var out bytes.Buffer
+	sh, err := New(ShellOptions{
+		Out:         &out,
+		Err:         &out,
+		ConfigPath:  configPath,
+		ConfigDir:   configDir,
+		StateDir:    dir,
+		LockManager: &LockManager{Path: filepath.Join(dir, "config.lock"), ExpectedComm: "lbctl"},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	run := func(line string) string {
+		t.Helper()
+		out.Reset()
+		if err := sh.ExecuteLine(line); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		return out.String()
+	}
+
+	if got := run("show"); !strings.Contains(got, "Node:        lb-a") || !strings.Contains(got, "State:       active (since 2025-01-02T03:00:00Z)") {
+		t.Fatalf("unexpected show output:\n%s", got)
+	}
+	ctrl.status.Role = "primary"
+	ctrl.status.ConfigHash = "abc123"
+	ctrl.status.StartedAt = since
+	ctrl.status.UptimeSeconds = 3723
+	ctrl.status.LastReconcile = &daemon.ReconcileResult{Time: since, Error: "netlink: busy"}
+	ctrl.status.Backends = daemon.BackendSummary{Total: 2, Healthy: 1, Unhealthy: 1}
+	ctrl.status.Pending = daemon.PendingWork{Reconcile: "weight", Draining: 2}
+	got := run("show status")
+	for _, want := range []string{
+		"Node:                      lb-a (primary), active (VIP owner)",
+		"Uptime:                    1h2m3s (since 2025-01-02T03:00:00Z)",
+		"Config hash:               abc123",
+		"Last reconcile:            failed at 2025-01-02T03:00:00Z: netlink: busy",
+		"Backends:                  1/2 healthy, 1 unhealthy, 0 unknown",
+		"Pending:                   reconcile (weight), 2 destinations draining",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("show status missing %q:\n%s", want, got)
+		}
+	}
+	if got, want := run("show health"), "web 10.0.0.1 HEALTHY weight=5\nweb 10.0.0.2 UNHEALTHY weight=0 override=drain\n"; got != want {
+		t.Fatalf("unexpected show health output:\n%s", got)
+	}
+	// show ipvs reads the table through the daemon
+	ctrl.ipvs = &ipvs.Snapshot{Services: []ipvs.ServiceSnapshot{{
+		Protocol: "udp", Address: "192.0.2.10", Port: 53, Scheduler: "rr",
+		Destinations: []ipvs.DestinationSnapshot{{Address: "10.0.0.1", Port: 53, Weight: 5, Forward: ipvs.ForwardDR, InactiveConns: 2}},
+	}}}
+	if got, want := run("show ipvs"), "UDP 192.0.2.10:53 rr conns=0 cps=0\n  -> 10.0.0.1:53 dr weight=5 active=0 inactive=2\n"; got != want {
+		t.Fatalf("unexpected show ipvs output:\n%s", got)
+	}
+
+	// One unhealthy backend fails doctor
+	out.Reset()
+	if err := sh.ExecuteLine("doctor"); err == nil || !strings.Contains(out.String(), "backends FAIL: unhealthy: web/10.0.0.2") ||
+		!strings.Contains(out.String(), "3/4 checks passed") {
+		t.Fatalf("expected doctor to flag the unhealthy backend, got %v:\n%s", err, out.String())
+	}
+	ctrl.backends = ctrl.backends[:1]
+	if got := run("doctor"); !strings.Contains(got, "4/4 checks passed") {
+		t.Fatalf("expected doctor to pass:\n%s", got)
+	}
+
+	if got := run("reload"); got != "Daemon reloaded; running generation 2.\n" || ctrl.reloads != 1 {
+		t.Fatalf("unexpected reload output %q after %d reloads", got, ctrl.reloads)
+	}
+	if got := run("reload /etc/lbctl/green.yaml"); got != "Daemon switched to /etc/lbctl/green.yaml; running generation 3.\n" {
+		t.Fatalf("unexpected config switch output %q", got)
+	}
+	if err := sh.ExecuteLine("reload /etc/lbctl/broken.yaml"); err == nil || !strings.Contains(err.Error(), "keeps its current config") ||
+		!strings.Contains(err.Error(), "invalid vip") || len(ctrl.switches) != 1 {
+		t.Fatalf("expected the broken config to be rejected, got %v after %v", err, ctrl.switches)
+	}
+	run("drain web 10.0.0.1 --ttl 30m")
+	run("undrain web 10.0.0.1")
+	run("backend drain web 10.0.0.2")
+	run("backend restore web 10.0.0.2")
+	want := []daemon.OverrideRequest{
+		{Service: "web", Backend: "10.0.0.1", Mode: health.OverrideDrain, TTLSeconds: 1800},
+		{Service: "web", Backend: "10.0.0.1"},
+		{Service: "web", Backend: "10.0.0.2", Mode: health.OverrideDrain},
+		{Service: "web", Backend: "10.0.0.2"},
+	}
+	if !reflect.DeepEqual(ctrl.overrides, want) {
+		t.Fatalf("unexpected overrides: %+v", ctrl.overrides)
+	}
+	run("log-level debug")
+	if ctrl.level != observability.DebugLevel {
+		t.Fatalf("expected debug level, got %s", ctrl.level)
+	}
+	run("maintenance on")
+	if !ctrl.status.Maintenance {
+		t.Fatal("expected the node in maintenance")
+	}
+	if got := run("show"); !strings.Contains(got, "Maintenance: yes") {
+		t.Fatalf("expected show to report maintenance:\n%s", got)
+	}
+	run("maintenance off")
+	if got := run("show"); ctrl.status.Maintenance || strings.Contains(got, "Maintenance") {
+		t.Fatalf("expected the node back in rotation:\n%s", got)
+	}
+	if err := sh.ExecuteLine("show nonsense"); err == nil {
+		t.Fatal("expected an unknown show command to fail")
+	}
+}
+
+func TestShellStatus(t *testing.T) {
+	dir := t.TempDir()
+	configPath, configDir := writeTestConfig(t, dir)
+	at := time.Date(2025, 1, 2, 3, 0, 0, 0, time.UTC)
+	ctrl := &fakeController{
+		status: daemon.DaemonStatus{Node: "lb-a", Role: "primary", State: daemon.StateActive, Active: true, Ready: true,
+			LastReconcile: &daemon.ReconcileResult{Time: at}},
+		services: []daemon.ServiceStatus{{Name: "web", Backends: 2, Healthy: 2}},
+		backends: []daemon.BackendHealth{
+			{Service: "web", Backend: "10.0.0.1", State: health.StateHealthy},
+			{Service: "web", Backend: "10.0.0.2", State: health.StateHealthy},
+		},
+	}
+	socket := filepath.Join(dir, daemon.ControlSocketFile)
+
+	var out bytes.Buffer
+	sh, err := New(ShellOptions{
+		Out:         &out,
+		Err:         &out,
+		ConfigPath:  configPath,
+		ConfigDir:   configDir,
+		StateDir:    dir,
+		LockManager: &LockManager{Path: filepath.Join(dir, "config.lock"), ExpectedComm: "lbctl"},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	status := func() int {
+		out.Reset()
+		return ExitCode(sh.ExecuteLine("status"))
+	}
+
+	// No daemon to ask
+	if code := status(); code != StatusUnknown {
+		t.Fatalf("expected unknown without a daemon, got %d", code)
+	}
+
+	srv, err := daemon.ListenControl(socket, ctrl)
+	if err != nil {
+		t.Fatalf("ListenControl: %v", err)
+	}
+	go srv.Serve()
+	defer srv.Close()
+
+	code := status()
+	want := "Node:           lb-a (primary)\n" +
+		"State:          active\n" +
+		"VIP owner:      yes\n" +
+		"Last reconcile: ok at 2025-01-02T03:00:00Z\n" +
+		"Services:\n" +
+		"  web 2/2 healthy\n" +
+		"Status:         OK\n"
+	if code != StatusOK || out.String() != want {
+		t.Fatalf("unexpected status %d:\n%s", code, out.String())
+	}
+
+	ctrl.services[0].Healthy = 1
+	ctrl.backends[1].State = health.StateUnhealthy
+	out.Reset()
+	err = sh.ExecuteLine("status")
+	if ExitCode(err) != StatusDegraded || !strings.Contains(out.String(), "  web 1/2 healthy, 1 unhealthy\n") ||
+		err.Error() != "degraded: web has 1 unhealthy backend(s)" {
+		t.Fatalf("expected a degraded status, got %v:\n%s", err, out.String())
+	}
+
+	ctrl.services[0].Healthy = 0
+	ctrl.backends[0].State = health.StateUnhealthy
+	if code := status(); code != StatusCritical {
+		t.Fatalf("expected critical with no healthy backend, got %d", code)
+	}
+
+	// Reconciles backing off are critical whatever the backends
+	ctrl.services[0].Healthy = 2
+	ctrl.backends[0].State, ctrl.backends[1].State = health.StateHealthy, health.StateHealthy
+	ctrl.status.State = daemon.StateDegradedBackoff
+	ctrl.status.LastReconcile = &daemon.ReconcileResult{Time: at, Error: "permission denied"}
+	out.Reset()
+	err = sh.ExecuteLine("status")
+	if ExitCode(err) != StatusCritical || !strings.Contains(out.String(), "Last reconcile: failed at 2025-01-02T03:00:00Z: permission denied\n") ||
+		!strings.Contains(out.String(), "Status:         CRITICAL\n") {
+		t.Fatalf("expected a critical status, got %v:\n%s", err, out.String())
+	}
+}
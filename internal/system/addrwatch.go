@@ -0,0 +1,18 @@
+package system
+
+import "net"
+
+// AddressChange is an address added to or removed from an interface.
+type AddressChange struct {
+	IP    net.IP
+	Added bool
+}
+
+// AddressWatcher is optionally implemented by a NetworkManager that reports
+// address changes as they happen, so a VIP moving is seen without waiting for
+// the next poll.
+type AddressWatcher interface {
+	// WatchAddresses streams address changes until done is closed. The
+	// channel is closed when the subscription ends, including on failure.
+	WatchAddresses(done <-chan struct{}) (<-chan AddressChange, error)
+}
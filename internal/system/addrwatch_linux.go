@@ -0,0 +1,31 @@
+//go:build linux
+
+package system
+
+import (
+	"fmt"
+
+	"github.com/vishvananda/netlink"
+)
+
+// WatchAddresses subscribes to the kernel's IPv4 and IPv6 address
+// notifications over netlink.
+func (n *RealNetworkManager) WatchAddresses(done <-chan struct{}) (<-chan AddressChange, error) {
+	updates := make(chan netlink.AddrUpdate, 16)
+	if err := netlink.AddrSubscribeWithOptions(updates, done, netlink.AddrSubscribeOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to address changes: %w", err)
+	}
+	changes := make(chan AddressChange, 16)
+	go func() {
+		// netlink closes updates when done is closed or the socket fails
+		defer close(changes)
+		for u := range updates {
+			select {
+			case changes <- AddressChange{IP: u.LinkAddress.IP, Added: u.NewAddr}:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return changes, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package system
+
+import "fmt"
+
+func (n *RealNetworkManager) WatchAddresses(done <-chan struct{}) (<-chan AddressChange, error) {
+	return nil, fmt.Errorf("address notifications are only supported on linux")
+}
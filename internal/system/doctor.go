@@ -3,6 +3,7 @@ package system
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/malindarathnayake/LibraFlux/internal/config"
@@ -14,13 +15,19 @@ type CheckResult struct {
 	Message string
 }
 
+// timeWaitSeconds is how long Linux keeps an actively closed TCP connection
+// in TIME_WAIT, holding its source port
+const timeWaitSeconds = 60
+
 type Doctor struct {
 	netManager NetworkManager
+	sysctlDir  string
 }
 
 func NewDoctor(nm NetworkManager) *Doctor {
 	return &Doctor{
 		netManager: nm,
+		sysctlDir:  "/proc/sys",
 	}
 }
 
@@ -66,7 +73,7 @@ func (d *Doctor) RunChecks(cfg *config.Config) ([]CheckResult, error) {
 			results = append(results, CheckResult{"VIP Check", true, msg})
 		}
 	}
-	
+
 	// Check Kernel Modules
 	// We verify if /proc/modules exists and is readable
 	if _, err := os.Stat("/proc/modules"); err == nil {
@@ -92,5 +99,79 @@ func (d *Doctor) RunChecks(cfg *config.Config) ([]CheckResult, error) {
 		results = append(results, CheckResult{"Kernel Modules", false, "Cannot access /proc/modules"})
 	}
 
+	if res, ok := d.checkPortRange(cfg); ok {
+		results = append(results, res)
+	}
+
 	return results, nil
 }
+
+// checkPortRange compares the source ports held in TIME_WAIT by connecting
+// health checks against the ports available to them: the configured
+// daemon.health source port range, or else net.ipv4.ip_local_port_range. It
+// fails when checks alone would use more than half of the range. It reports
+// nothing when no check leaves ports in TIME_WAIT.
+func (d *Doctor) checkPortRange(cfg *config.Config) (CheckResult, bool) {
+	const name = "Health Check Source Ports"
+	demand := timeWaitDemand(cfg)
+	if demand == 0 {
+		return CheckResult{}, false
+	}
+
+	var low, high int
+	source := "daemon.health source port range"
+	if h := cfg.Daemon.Health; h.SourcePortMin != 0 || h.SourcePortMax != 0 {
+		low, high = h.SourcePortMin, h.SourcePortMax
+	} else {
+		source = "net.ipv4.ip_local_port_range"
+		path := filepath.Join(d.sysctlDir, "net/ipv4/ip_local_port_range")
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return CheckResult{name, false, fmt.Sprintf("Cannot read %s: %v", path, err)}, true
+		}
+		if _, err := fmt.Sscan(string(content), &low, &high); err != nil || low > high {
+			return CheckResult{name, false, fmt.Sprintf("Cannot parse %s: %q", path, strings.TrimSpace(string(content)))}, true
+		}
+	}
+
+	size := high - low + 1
+	msg := fmt.Sprintf("%s %d-%d (%d ports), ~%d held in TIME_WAIT by health checks", source, low, high, size, demand)
+	if demand > size/2 {
+		return CheckResult{name, false, msg + "; widen the range or use tcp_mode reuse/half_open or tcp_reset"}, true
+	}
+	return CheckResult{name, true, msg}, true
+}
+
+// timeWaitDemand estimates how many source ports health checks hold in
+// TIME_WAIT at any time: every actively closed TCP connection keeps its port
+// for timeWaitSeconds. Half-open, reuse and tcp_reset checks and UDP-based
+// checks leave nothing behind.
+func timeWaitDemand(cfg *config.Config) int {
+	demand := 0
+	for _, svc := range cfg.Services {
+		h := svc.Health
+		if !h.Enabled || h.IntervalMS <= 0 {
+			continue
+		}
+		checks := 0
+		for _, p := range append([]config.HealthProbe{h.Primary()}, h.Probes...) {
+			if leavesTimeWait(p) {
+				checks++
+			}
+		}
+		perMinute := timeWaitSeconds * 1000 / h.IntervalMS
+		demand += len(svc.Backends) * checks * perMinute
+	}
+	return demand
+}
+
+func leavesTimeWait(p config.HealthProbe) bool {
+	switch strings.ToLower(p.Type) {
+	case "tcp":
+		mode := strings.ToLower(p.TCPMode)
+		return !p.TCPReset && (mode == "" || mode == "connect")
+	case "redis", "mysql":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,14 @@
+package system
+
+// FileWatcher reports changes to the entries of directories as they happen,
+// so an edited config file is seen without waiting for the next poll.
+type FileWatcher interface {
+	// WatchDirs signals each time a file in one of dirs is created,
+	// written, removed or renamed, until done is closed. Signals coalesce,
+	// so one may stand for several changes. The channel is closed when the
+	// watch ends, including on failure or when a directory is removed.
+	WatchDirs(dirs []string, done <-chan struct{}) (<-chan struct{}, error)
+}
+
+// InotifyWatcher watches directories with inotify.
+type InotifyWatcher struct{}
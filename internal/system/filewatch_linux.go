@@ -0,0 +1,70 @@
+//go:build linux
+
+package system
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// inotifyDirMask selects the events that change what a directory's files
+// hold: writes, creations and removals, renames in and out (editors and
+// config commits replace files by renaming), and metadata such as mtime.
+const inotifyDirMask = unix.IN_CLOSE_WRITE | unix.IN_MODIFY | unix.IN_ATTRIB |
+	unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+	unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+
+// WatchDirs watches dirs with one inotify instance. The descriptor is
+// non-blocking, so the runtime poller serves reads and closing the file on
+// done ends a pending one.
+func (InotifyWatcher) WatchDirs(dirs []string, done <-chan struct{}) (<-chan struct{}, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create inotify instance: %w", err)
+	}
+	for _, dir := range dirs {
+		if _, err := unix.InotifyAddWatch(fd, dir, inotifyDirMask); err != nil {
+			unix.Close(fd)
+			return nil, fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+	f := os.NewFile(uintptr(fd), "inotify")
+
+	changes := make(chan struct{}, 1)
+	go func() {
+		<-done
+		f.Close()
+	}()
+	go func() {
+		defer close(changes)
+		buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+		for {
+			n, err := f.Read(buf)
+			if err != nil || n < unix.SizeofInotifyEvent {
+				return
+			}
+			ended := false
+			for off := 0; off+unix.SizeofInotifyEvent <= n; {
+				// struct inotify_event: wd, mask, cookie, len, then the name
+				mask := binary.NativeEndian.Uint32(buf[off+4:])
+				length := binary.NativeEndian.Uint32(buf[off+12:])
+				// A watched directory went away; the caller rewatches
+				if mask&(unix.IN_IGNORED|unix.IN_DELETE_SELF|unix.IN_MOVE_SELF) != 0 {
+					ended = true
+				}
+				off += unix.SizeofInotifyEvent + int(length)
+			}
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+			if ended {
+				return
+			}
+		}
+	}()
+	return changes, nil
+}
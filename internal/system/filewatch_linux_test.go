@@ -0,0 +1,58 @@
+//go:build linux
+
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInotifyWatcher(t *testing.T) {
+	dir := t.TempDir()
+	done := make(chan struct{})
+	changes, err := InotifyWatcher{}.WatchDirs([]string{dir}, done)
+	if err != nil {
+		t.Fatalf("WatchDirs: %v", err)
+	}
+
+	// Replacing a file by rename, as config commits do, is seen
+	tmp := filepath.Join(dir, "web.yaml.tmp")
+	if err := os.WriteFile(tmp, []byte("services: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, "web.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case _, ok := <-changes:
+		if !ok {
+			t.Fatal("watch ended instead of reporting the change")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported")
+	}
+
+	close(done)
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-changes:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("watch didn't end when done was closed")
+		}
+	}
+}
+
+func TestInotifyWatcherMissingDir(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	var w InotifyWatcher
+	if _, err := w.WatchDirs([]string{filepath.Join(t.TempDir(), "missing")}, done); err == nil {
+		t.Fatal("expected an error watching a missing directory")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package system
+
+import "fmt"
+
+func (InotifyWatcher) WatchDirs(dirs []string, done <-chan struct{}) (<-chan struct{}, error) {
+	return nil, fmt.Errorf("file notifications are only supported on linux")
+}
@@ -3,11 +3,13 @@ package system
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
 )
 
 const (
@@ -42,25 +44,25 @@ func (p *FRRPatcher) Patch(cfg *config.Config) error {
 			// If file doesn't exist, create it with managed block
 			content = []byte{}
 		} else {
-			return fmt.Errorf("failed to read FRR config: %w", err)
+			return errdefs.Classify(fmt.Errorf("failed to read FRR config: %w", err))
 		}
 	}
 
 	// 2. Generate new managed block
-	newBlock := generateManagedBlock(cfg)
+	newBlock := RenderFRRBlock(FRRDataFromConfig(cfg))
 
 	// 3. Replace or Append
 	newContent, err := replaceManagedBlock(content, newBlock)
 	if err != nil {
 		return err
 	}
-	
+
 	// 4. Backup
 	if err := p.backup(content); err != nil {
 		// Log warning but proceed? Or fail? Spec says "Back up full file before first patch"
 		// and "Back up managed block".
 		// For simplicity, we backup the full file if it exists.
-		return fmt.Errorf("failed to backup FRR config: %w", err)
+		return errdefs.Classify(fmt.Errorf("failed to backup FRR config: %w", err))
 	}
 
 	// 5. Write new config
@@ -68,9 +70,9 @@ func (p *FRRPatcher) Patch(cfg *config.Config) error {
 	if err := os.MkdirAll(filepath.Dir(p.configPath), 0755); err != nil {
 		return err
 	}
-	
+
 	if err := os.WriteFile(p.configPath, newContent, 0644); err != nil {
-		return fmt.Errorf("failed to write FRR config: %w", err)
+		return errdefs.Classify(fmt.Errorf("failed to write FRR config: %w", err))
 	}
 
 	return nil
@@ -80,55 +82,81 @@ func (p *FRRPatcher) backup(content []byte) error {
 	if len(content) == 0 {
 		return nil
 	}
-	
+
 	if err := os.MkdirAll(p.backupDir, 0750); err != nil {
 		return err
 	}
-	
+
 	timestamp := time.Now().Format("20060102-150405")
 	backupPath := filepath.Join(p.backupDir, fmt.Sprintf("frr.conf.%s", timestamp))
-	
+
 	return os.WriteFile(backupPath, content, 0640)
 }
 
-func generateManagedBlock(cfg *config.Config) string {
-	var sb strings.Builder
-	
-	sb.WriteString(FRRManagedBegin)
-	sb.WriteString("\n")
-	
-	sb.WriteString(fmt.Sprintf("interface %s\n", cfg.Network.Frontend.Interface))
-	sb.WriteString(fmt.Sprintf(" vrrp %d version 3\n", cfg.VRRP.VRID))
-	
-	priority := cfg.VRRP.PriorityPrimary
+// VRRPPriority is the node's configured VRRP priority, by its role
+func VRRPPriority(cfg *config.Config) int {
 	if cfg.Node.Role == "secondary" {
-		priority = cfg.VRRP.PrioritySecondary
+		return cfg.VRRP.PrioritySecondary
 	}
-	sb.WriteString(fmt.Sprintf(" vrrp %d priority %d\n", cfg.VRRP.VRID, priority))
-	
-	// advert_interval_ms to centiseconds (ms / 10)
-	advert := cfg.VRRP.AdvertIntervalMS / 10
-	if advert < 1 {
-		advert = 100 // Default to 1s if invalid
+	return cfg.VRRP.PriorityPrimary
+}
+
+// VRRPPreempter enables VRRP preemption on the running routing daemon.
+type VRRPPreempter interface {
+	EnablePreempt(cfg *config.Config) error
+}
+
+// VtyshPreempter enables preemption through vtysh without touching frr.conf,
+// so a restart falls back to the non-preempting managed block.
+type VtyshPreempter struct {
+	Run func(name string, args ...string) error
+}
+
+func NewVtyshPreempter() *VtyshPreempter {
+	return &VtyshPreempter{
+		Run: func(name string, args ...string) error {
+			out, err := exec.Command(name, args...).CombinedOutput()
+			if err != nil {
+				return fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(string(out)))
+			}
+			return nil
+		},
+	}
+}
+
+func (p *VtyshPreempter) EnablePreempt(cfg *config.Config) error {
+	return p.Run("vtysh", preemptArgs(cfg)...)
+}
+
+// SetPriority changes the node's VRRP priority on the running routing daemon.
+// Like EnablePreempt it leaves frr.conf alone, so a restart of FRR returns to
+// the configured priority.
+func (p *VtyshPreempter) SetPriority(cfg *config.Config, priority int) error {
+	return p.Run("vtysh", priorityArgs(cfg, priority)...)
+}
+
+func priorityArgs(cfg *config.Config, priority int) []string {
+	return []string{
+		"-c", "configure terminal",
+		"-c", fmt.Sprintf("interface %s", cfg.Network.Frontend.Interface),
+		"-c", fmt.Sprintf("vrrp %d priority %d", cfg.VRRP.VRID, priority),
 	}
-	sb.WriteString(fmt.Sprintf(" vrrp %d advertisement-interval %d\n", cfg.VRRP.VRID, advert))
-	
-	if cfg.Network.Frontend.VIP != "" {
-		sb.WriteString(fmt.Sprintf(" vrrp %d ip %s\n", cfg.VRRP.VRID, cfg.Network.Frontend.VIP))
+}
+
+func preemptArgs(cfg *config.Config) []string {
+	return []string{
+		"-c", "configure terminal",
+		"-c", fmt.Sprintf("interface %s", cfg.Network.Frontend.Interface),
+		"-c", fmt.Sprintf("vrrp %d preempt", cfg.VRRP.VRID),
 	}
-	
-	sb.WriteString(FRRManagedEnd)
-	sb.WriteString("\n")
-	
-	return sb.String()
 }
 
 func replaceManagedBlock(content []byte, newBlock string) ([]byte, error) {
 	s := string(content)
-	
+
 	startIdx := strings.Index(s, FRRManagedBegin)
 	endIdx := strings.Index(s, FRRManagedEnd)
-	
+
 	if startIdx == -1 {
 		// Block not found, append to end (with newline if needed)
 		if len(s) > 0 && !strings.HasSuffix(s, "\n") {
@@ -136,20 +164,20 @@ func replaceManagedBlock(content []byte, newBlock string) ([]byte, error) {
 		}
 		return []byte(s + newBlock), nil
 	}
-	
+
 	if endIdx == -1 {
 		return nil, fmt.Errorf("found managed block start but no end")
 	}
-	
+
 	// Include the end marker and newline in replacement
 	endOfBlock := endIdx + len(FRRManagedEnd)
 	if endOfBlock < len(s) && s[endOfBlock] == '\n' {
 		endOfBlock++
 	}
-	
+
 	// Construct new content
 	before := s[:startIdx]
 	after := s[endOfBlock:]
-	
+
 	return []byte(before + newBlock + after), nil
 }
@@ -13,10 +13,10 @@ func TestFRRPatcher(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "frr.conf")
 	backupDir := filepath.Join(tmpDir, "backups")
-	
+
 	patcher := NewFRRPatcher(configPath)
 	patcher.SetBackupDir(backupDir)
-	
+
 	// Initial content
 	initialContent := `
 ! Unmanaged content
@@ -27,7 +27,7 @@ router bgp 65000
 	if err := os.WriteFile(configPath, []byte(initialContent), 0644); err != nil {
 		t.Fatal(err)
 	}
-	
+
 	cfg := &config.Config{
 		Node: config.NodeConfig{Role: "primary"},
 		Network: config.NetworkConfig{
@@ -39,19 +39,19 @@ router bgp 65000
 			AdvertIntervalMS: 1000,
 		},
 	}
-	
+
 	// Test Patch (Append)
 	if err := patcher.Patch(cfg); err != nil {
 		t.Fatalf("Patch() failed: %v", err)
 	}
-	
+
 	// Verify content
 	content, err := os.ReadFile(configPath)
 	if err != nil {
 		t.Fatal(err)
 	}
 	s := string(content)
-	
+
 	if !strings.Contains(s, "! Unmanaged content") {
 		t.Error("Unmanaged content lost")
 	}
@@ -64,7 +64,7 @@ router bgp 65000
 	if !strings.Contains(s, "advertisement-interval 100") {
 		t.Error("advertisement-interval 100 missing") // 1000ms / 10 = 100
 	}
-	
+
 	// Verify backup
 	entries, err := os.ReadDir(backupDir)
 	if err != nil {
@@ -73,29 +73,29 @@ router bgp 65000
 	if len(entries) != 1 {
 		t.Errorf("Expected 1 backup, got %d", len(entries))
 	}
-	
+
 	// Test Patch (Replace)
 	// Change config
 	cfg.Node.Role = "secondary"
 	cfg.VRRP.PrioritySecondary = 100
-	
+
 	if err := patcher.Patch(cfg); err != nil {
 		t.Fatalf("Patch() failed: %v", err)
 	}
-	
+
 	content, err = os.ReadFile(configPath)
 	if err != nil {
 		t.Fatal(err)
 	}
 	s = string(content)
-	
+
 	if !strings.Contains(s, "! Unmanaged content") {
 		t.Error("Unmanaged content lost")
 	}
 	if !strings.Contains(s, "priority 100") {
 		t.Error("Priority 100 missing (secondary)")
 	}
-	
+
 	// Ensure we don't have duplicates
 	if strings.Count(s, FRRManagedBegin) != 1 {
 		t.Errorf("Expected 1 managed block, got %d", strings.Count(s, FRRManagedBegin))
@@ -105,27 +105,160 @@ router bgp 65000
 func TestFRRNewFile(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "frr.conf")
-	
+
 	patcher := NewFRRPatcher(configPath)
 	patcher.SetBackupDir(filepath.Join(tmpDir, "backups"))
-	
+
 	cfg := &config.Config{
-		Node: config.NodeConfig{Role: "primary"},
+		Node:    config.NodeConfig{Role: "primary"},
 		Network: config.NetworkConfig{Frontend: config.InterfaceConfig{Interface: "eth0"}},
-		VRRP: config.VRRPConfig{VRID: 10},
+		VRRP:    config.VRRPConfig{VRID: 10},
 	}
-	
+
 	if err := patcher.Patch(cfg); err != nil {
 		t.Fatalf("Patch() failed: %v", err)
 	}
-	
+
 	content, err := os.ReadFile(configPath)
 	if err != nil {
 		t.Fatal(err)
 	}
 	s := string(content)
-	
+
 	if !strings.Contains(s, FRRManagedBegin) {
 		t.Error("Managed block missing")
 	}
 }
+
+func TestFRRPreemptAfterReady(t *testing.T) {
+	cfg := &config.Config{
+		Node:    config.NodeConfig{Role: "primary"},
+		Network: config.NetworkConfig{Frontend: config.InterfaceConfig{Interface: "eth0", VIP: "192.168.1.100"}},
+		VRRP:    config.VRRPConfig{VRID: 50, PriorityPrimary: 150, AdvertIntervalMS: 1000, PreemptAfterReady: true},
+	}
+
+	block := RenderFRRBlock(FRRDataFromConfig(cfg))
+	if !strings.Contains(block, " no vrrp 50 preempt\n") {
+		t.Errorf("expected preemption disabled in managed block, got:\n%s", block)
+	}
+
+	var gotName string
+	var gotArgs []string
+	p := &VtyshPreempter{Run: func(name string, args ...string) error {
+		gotName = name
+		gotArgs = args
+		return nil
+	}}
+	if err := p.EnablePreempt(cfg); err != nil {
+		t.Fatalf("EnablePreempt() failed: %v", err)
+	}
+	want := "-c|configure terminal|-c|interface eth0|-c|vrrp 50 preempt"
+	if gotName != "vtysh" || strings.Join(gotArgs, "|") != want {
+		t.Errorf("unexpected command: %s %v", gotName, gotArgs)
+	}
+
+	cfg.VRRP.PreemptAfterReady = false
+	if strings.Contains(RenderFRRBlock(FRRDataFromConfig(cfg)), "preempt") {
+		t.Error("preempt line should be omitted by default")
+	}
+}
+
+func TestVtyshSetPriority(t *testing.T) {
+	cfg := &config.Config{
+		Node:    config.NodeConfig{Role: "secondary"},
+		Network: config.NetworkConfig{Frontend: config.InterfaceConfig{Interface: "eth0"}},
+		VRRP:    config.VRRPConfig{VRID: 50, PriorityPrimary: 150, PrioritySecondary: 100},
+	}
+	if got := VRRPPriority(cfg); got != 100 {
+		t.Errorf("VRRPPriority() = %d, want 100", got)
+	}
+
+	var gotArgs []string
+	p := &VtyshPreempter{Run: func(name string, args ...string) error {
+		gotArgs = args
+		return nil
+	}}
+	if err := p.SetPriority(cfg, 1); err != nil {
+		t.Fatalf("SetPriority() failed: %v", err)
+	}
+	want := "-c|configure terminal|-c|interface eth0|-c|vrrp 50 priority 1"
+	if strings.Join(gotArgs, "|") != want {
+		t.Errorf("unexpected command: %v", gotArgs)
+	}
+}
+
+func TestFRRMultipleVIPs(t *testing.T) {
+	cfg := &config.Config{
+		Node: config.NodeConfig{Role: "primary"},
+		Network: config.NetworkConfig{Frontend: config.InterfaceConfig{
+			Interface: "eth0",
+			VIP:       "192.168.1.100",
+			VIPs:      []string{"192.168.1.101", "192.168.1.102"},
+		}},
+		VRRP: config.VRRPConfig{VRID: 50, PriorityPrimary: 150, AdvertIntervalMS: 1000},
+	}
+
+	block := RenderFRRBlock(FRRDataFromConfig(cfg))
+	for _, vip := range []string{"192.168.1.100", "192.168.1.101", "192.168.1.102"} {
+		if !strings.Contains(block, " vrrp 50 ip "+vip+"\n") {
+			t.Errorf("expected %s in managed block, got:\n%s", vip, block)
+		}
+	}
+}
+
+func TestFRRIPv6VIPs(t *testing.T) {
+	block := RenderFRRBlock(FRRData{
+		Interface:        "eth0",
+		VRID:             50,
+		Priority:         150,
+		AdvertIntervalMS: 1000,
+		VIPs:             []string{"2001:db8::100", "192.168.1.100"},
+	})
+	for _, line := range []string{" vrrp 50 ip 192.168.1.100\n", " vrrp 50 ipv6 2001:db8::100\n"} {
+		if !strings.Contains(block, line) {
+			t.Errorf("expected %q in managed block, got:\n%s", line, block)
+		}
+	}
+	if strings.Contains(block, " ip 2001:db8::100") {
+		t.Errorf("IPv6 VIP rendered as ip:\n%s", block)
+	}
+}
+
+func TestVtyshVRRPState(t *testing.T) {
+	cfg := &config.Config{
+		Network: config.NetworkConfig{Frontend: config.InterfaceConfig{Interface: "eth0", VIP: "192.168.1.100"}},
+		VRRP:    config.VRRPConfig{VRID: 50},
+	}
+	out := `[{"vrid":50,"version":3,"interface":"eth0","v4":{"interface":"vrrp4-2-50","status":"Master"},"v6":{"interface":"","status":"Initialize"}}]`
+
+	var gotArgs []string
+	r := &VtyshStateReader{Output: func(name string, args ...string) ([]byte, error) {
+		gotArgs = args
+		return []byte(out), nil
+	}}
+	state, err := r.VRRPState(cfg)
+	if err != nil {
+		t.Fatalf("VRRPState() failed: %v", err)
+	}
+	if state != VRRPStateMaster {
+		t.Errorf("VRRPState() = %q, want Master", state)
+	}
+	if want := "-c|show vrrp interface eth0 50 json"; strings.Join(gotArgs, "|") != want {
+		t.Errorf("unexpected command: %v", gotArgs)
+	}
+
+	// An IPv6 VIP follows the v6 router
+	cfg.Network.Frontend.VIP = "2001:db8::100"
+	if state, _ := r.VRRPState(cfg); state != VRRPStateInitialize {
+		t.Errorf("VRRPState() for IPv6 = %q, want Initialize", state)
+	}
+
+	cfg.VRRP.VRID = 51
+	if _, err := r.VRRPState(cfg); err == nil {
+		t.Error("expected an error when FRR has no router for the VRID")
+	}
+	out = `not json`
+	if _, err := r.VRRPState(cfg); err == nil {
+		t.Error("expected an error for unparsable output")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
 	"github.com/vishvananda/netlink"
 )
 
@@ -26,13 +27,13 @@ func (n *RealNetworkManager) CheckVIPPresent(vip string) (bool, error) {
 	// Parse VIP
 	parsedVIP := net.ParseIP(vip)
 	if parsedVIP == nil {
-		return false, fmt.Errorf("invalid VIP: %s", vip)
+		return false, errdefs.PermanentConfig(fmt.Errorf("invalid VIP: %s", vip))
 	}
 
 	// List all addresses (family 0 = all)
 	addrs, err := netlink.AddrList(nil, 0)
 	if err != nil {
-		return false, fmt.Errorf("failed to list addresses: %w", err)
+		return false, errdefs.Classify(fmt.Errorf("failed to list addresses: %w", err))
 	}
 
 	for _, addr := range addrs {
@@ -48,7 +49,7 @@ func (n *RealNetworkManager) CheckVIPPresent(vip string) (bool, error) {
 func (n *RealNetworkManager) GetInterfaceStatus(iface string) (bool, error) {
 	link, err := netlink.LinkByName(iface)
 	if err != nil {
-		return false, fmt.Errorf("interface %s not found: %w", iface, err)
+		return false, errdefs.PermanentConfig(fmt.Errorf("interface %s not found: %w", iface, err))
 	}
 
 	attrs := link.Attrs()
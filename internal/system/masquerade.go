@@ -0,0 +1,93 @@
+package system
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+	"github.com/malindarathnayake/LibraFlux/internal/errdefs"
+)
+
+// MasqueradeTable is the nftables table lbctl owns. Rules live only here, so
+// applying or removing them never touches operator-managed rules.
+const MasqueradeTable = "lbctl"
+
+// MasqueradeManager keeps the source NAT rules that NAT mode needs in sync
+// with the config.
+type MasqueradeManager interface {
+	Apply(cfg *config.Config) error
+}
+
+// NftMasquerade manages the MASQUERADE rule set through `nft -f -`. Each
+// Apply replaces the whole table atomically; when no backend is forwarded
+// with NAT the table is removed.
+type NftMasquerade struct {
+	Run func(stdin string, name string, args ...string) error
+}
+
+func NewNftMasquerade() *NftMasquerade {
+	return &NftMasquerade{
+		Run: func(stdin string, name string, args ...string) error {
+			cmd := exec.Command(name, args...)
+			cmd.Stdin = strings.NewReader(stdin)
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				return fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(string(out)))
+			}
+			return nil
+		},
+	}
+}
+
+func (m *NftMasquerade) Apply(cfg *config.Config) error {
+	if err := m.Run(masqueradeRuleset(cfg), "nft", "-f", "-"); err != nil {
+		return errdefs.Classify(fmt.Errorf("failed to apply masquerade rules: %w", err))
+	}
+	return nil
+}
+
+// usesNAT reports whether NAT mode or any backend's forward override sends
+// traffic through the load balancer with NAT.
+func usesNAT(cfg *config.Config) bool {
+	return usesForward(cfg, "nat")
+}
+
+// usesForward reports whether the global mode or any backend's forward
+// override is method.
+func usesForward(cfg *config.Config, method string) bool {
+	if strings.EqualFold(strings.TrimSpace(cfg.Mode), method) {
+		return true
+	}
+	for _, svc := range cfg.Services {
+		for _, be := range svc.Backends {
+			if strings.EqualFold(be.Forward, method) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// masqueradeRuleset renders the nft script for cfg. Declaring the table
+// before deleting it makes the delete succeed whether or not it existed.
+// When NAT is in use, traffic that enters on the backend interface and leaves
+// on the frontend interface is masqueraded, so backends that route through
+// the load balancer can reach the outside world. IPVS itself rewrites replies to
+// virtual services and does not depend on this rule.
+func masqueradeRuleset(cfg *config.Config) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("table ip %s\n", MasqueradeTable))
+	sb.WriteString(fmt.Sprintf("delete table ip %s\n", MasqueradeTable))
+	if !usesNAT(cfg) {
+		return sb.String()
+	}
+
+	sb.WriteString(fmt.Sprintf("table ip %s {\n", MasqueradeTable))
+	sb.WriteString("\tchain postrouting {\n")
+	sb.WriteString("\t\ttype nat hook postrouting priority srcnat; policy accept;\n")
+	sb.WriteString(fmt.Sprintf("\t\tiifname %q oifname %q masquerade\n", cfg.Network.Backend.Interface, cfg.Network.Frontend.Interface))
+	sb.WriteString("\t}\n")
+	sb.WriteString("}\n")
+	return sb.String()
+}
@@ -0,0 +1,9 @@
+package system
+
+// PeerCred is the process at the other end of a unix socket connection, as
+// the kernel recorded it when the connection was made.
+type PeerCred struct {
+	UID int
+	GID int
+	PID int
+}
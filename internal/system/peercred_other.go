@@ -0,0 +1,12 @@
+//go:build !linux
+
+package system
+
+import (
+	"fmt"
+	"net"
+)
+
+func PeerCredOf(conn *net.UnixConn) (PeerCred, error) {
+	return PeerCred{}, fmt.Errorf("peer credentials are only supported on linux")
+}
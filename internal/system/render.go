@@ -0,0 +1,185 @@
+package system
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+)
+
+// Rendered file kinds
+const (
+	FileKindFRR    = "frr"
+	FileKindSysctl = "sysctl"
+)
+
+// RenderedFile is what lbctl would write to one system file. For frr.conf,
+// which lbctl shares with the operator, Content is only the managed block.
+type RenderedFile struct {
+	Kind    string `json:"kind"` // FileKindFRR or FileKindSysctl
+	Path    string `json:"path"` // system.frr_config or system.sysctl_file; "" when unset
+	Content string `json:"content"`
+}
+
+// Render previews the system files cfg generates without touching the
+// system, in a fixed order: the FRR block, then the sysctl file. The
+// output only depends on cfg, and lines are in a canonical order (see
+// RenderFRRBlock and RenderSysctl), so two renders of equivalent configs
+// diff cleanly.
+func Render(cfg *config.Config) []RenderedFile {
+	return []RenderedFile{
+		{Kind: FileKindFRR, Path: cfg.System.FRRConfig, Content: RenderFRRBlock(FRRDataFromConfig(cfg))},
+		{Kind: FileKindSysctl, Path: cfg.System.SysctlFile, Content: RenderSysctl(SysctlDataFromConfig(cfg))},
+	}
+}
+
+// FRRData is everything the managed FRR block is rendered from.
+type FRRData struct {
+	Interface         string
+	VRID              int
+	Priority          int
+	AdvertIntervalMS  int
+	VIPs              []string
+	PreemptAfterReady bool // Render preemption off; the daemon enables it once IPVS is programmed
+}
+
+// FRRDataFromConfig extracts the FRR block's data from cfg.
+func FRRDataFromConfig(cfg *config.Config) FRRData {
+	return FRRData{
+		Interface:         cfg.Network.Frontend.Interface,
+		VRID:              cfg.VRRP.VRID,
+		Priority:          VRRPPriority(cfg),
+		AdvertIntervalMS:  cfg.VRRP.AdvertIntervalMS,
+		VIPs:              cfg.Network.Frontend.AllVIPs(),
+		PreemptAfterReady: cfg.VRRP.PreemptAfterReady,
+	}
+}
+
+// RenderFRRBlock renders the managed block of frr.conf, markers included.
+// VIPs are listed once each in address order, whatever their order in the
+// config.
+func RenderFRRBlock(d FRRData) string {
+	var sb strings.Builder
+
+	sb.WriteString(FRRManagedBegin)
+	sb.WriteString("\n")
+
+	sb.WriteString(fmt.Sprintf("interface %s\n", d.Interface))
+	sb.WriteString(fmt.Sprintf(" vrrp %d version 3\n", d.VRID))
+	sb.WriteString(fmt.Sprintf(" vrrp %d priority %d\n", d.VRID, d.Priority))
+
+	// advert_interval_ms to centiseconds (ms / 10)
+	advert := d.AdvertIntervalMS / 10
+	if advert < 1 {
+		advert = 100 // Default to 1s if invalid
+	}
+	sb.WriteString(fmt.Sprintf(" vrrp %d advertisement-interval %d\n", d.VRID, advert))
+
+	// All VIPs share the VRRP instance, so they fail over together. FRR
+	// takes IPv6 addresses with "ipv6"; they run as the instance's v6 router.
+	for _, vip := range canonicalAddrs(d.VIPs) {
+		family := "ip"
+		if addr, err := netip.ParseAddr(vip); err == nil && addr.Is6() && !addr.Is4In6() {
+			family = "ipv6"
+		}
+		sb.WriteString(fmt.Sprintf(" vrrp %d %s %s\n", d.VRID, family, vip))
+	}
+
+	// Preemption is enabled at runtime by the daemon once IPVS is programmed
+	if d.PreemptAfterReady {
+		sb.WriteString(fmt.Sprintf(" no vrrp %d preempt\n", d.VRID))
+	}
+
+	sb.WriteString(FRRManagedEnd)
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+// canonicalAddrs sorts addrs by address and drops duplicates. Entries that
+// don't parse sort after the rest, by their text.
+func canonicalAddrs(addrs []string) []string {
+	sorted := append([]string(nil), addrs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, aErr := netip.ParseAddr(sorted[i])
+		b, bErr := netip.ParseAddr(sorted[j])
+		switch {
+		case aErr == nil && bErr == nil:
+			return a.Less(b)
+		case aErr == nil || bErr == nil:
+			return aErr == nil
+		default:
+			return sorted[i] < sorted[j]
+		}
+	})
+	out := sorted[:0]
+	for i, a := range sorted {
+		if i == 0 || a != sorted[i-1] {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// SysctlData is everything the sysctl file is rendered from.
+type SysctlData struct {
+	Mode     string
+	Profile  string            // Tuning profile name, for the header
+	NAT      bool              // Some traffic is forwarded with NAT
+	TUN      bool              // Some traffic is forwarded through IPIP tunnels
+	Settings map[string]string // Tuning profile values
+}
+
+// SysctlDataFromConfig extracts the sysctl file's data from cfg. Backend
+// forward overrides count too: one NAT or TUN backend needs the same
+// settings as the whole node in that mode.
+func SysctlDataFromConfig(cfg *config.Config) SysctlData {
+	return SysctlData{
+		Mode:     cfg.Mode,
+		Profile:  cfg.System.TuningProfile,
+		NAT:      usesNAT(cfg),
+		TUN:      usesForward(cfg, "tun"),
+		Settings: GetTuningProfile(cfg.System.TuningProfile),
+	}
+}
+
+// RenderSysctl renders the sysctl file. The mode settings come first in a
+// fixed order, then the tuning settings sorted by key.
+func RenderSysctl(d SysctlData) string {
+	var sb strings.Builder
+
+	sb.WriteString("# lbctl managed sysctl configuration\n")
+	sb.WriteString(fmt.Sprintf("# Mode: %s\n", d.Mode))
+	sb.WriteString(fmt.Sprintf("# Profile: %s\n\n", d.Profile))
+
+	// Mode specific
+	sb.WriteString("# Mode settings\n")
+	sb.WriteString("net.ipv4.ip_forward = 1\n")
+	if d.NAT {
+		sb.WriteString("net.ipv4.vs.conntrack = 1\n")
+	}
+	if d.TUN {
+		// IPVS encapsulates in IPIP, which needs the ipip module loaded.
+		// Loose reverse path filtering keeps ICMP errors from the tunnel
+		// endpoints, which arrive on a different interface, from being dropped.
+		sb.WriteString("# TUN forwarding requires the ipip kernel module\n")
+		sb.WriteString("net.ipv4.conf.all.rp_filter = 2\n")
+		sb.WriteString("net.ipv4.conf.default.rp_filter = 2\n")
+	}
+	sb.WriteString("\n")
+
+	// Tuning profile
+	sb.WriteString("# Tuning profile settings\n")
+	keys := make([]string, 0, len(d.Settings))
+	for k := range d.Settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf("%s = %s\n", k, d.Settings[k]))
+	}
+
+	return sb.String()
+}
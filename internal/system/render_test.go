@@ -0,0 +1,89 @@
+package system
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares got with testdata/<name>.golden
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *updateGolden {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file (run with -update to create it): %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from %s:\n--- got\n%s--- want\n%s", name, path, got, want)
+	}
+}
+
+func TestRenderGolden(t *testing.T) {
+	cfg := &config.Config{
+		Mode: "dr",
+		Node: config.NodeConfig{Role: "secondary"},
+		Network: config.NetworkConfig{Frontend: config.InterfaceConfig{
+			Interface: "eth0",
+			VIP:       "192.168.1.100",
+			VIPs:      []string{"192.168.1.20", "10.0.0.5", "192.168.1.100"},
+		}},
+		VRRP:   config.VRRPConfig{VRID: 51, PriorityPrimary: 150, PrioritySecondary: 100, AdvertIntervalMS: 200, PreemptAfterReady: true},
+		System: config.SystemConfig{FRRConfig: "/etc/frr/frr.conf", SysctlFile: "/etc/sysctl.d/99-lbctl.conf", TuningProfile: "aggressive"},
+		Services: []config.Service{{Backends: []config.Backend{
+			{Address: "10.1.0.1"},
+			{Address: "10.1.0.2", Forward: "nat"},
+			{Address: "10.1.0.3", Forward: "tun"},
+		}}},
+	}
+
+	files := Render(cfg)
+	if len(files) != 2 || files[0].Kind != FileKindFRR || files[1].Kind != FileKindSysctl {
+		t.Fatalf("expected the FRR block then the sysctl file, got %+v", files)
+	}
+	if files[0].Path != "/etc/frr/frr.conf" || files[1].Path != "/etc/sysctl.d/99-lbctl.conf" {
+		t.Fatalf("unexpected paths: %q, %q", files[0].Path, files[1].Path)
+	}
+	checkGolden(t, "frr_block", files[0].Content)
+	checkGolden(t, "sysctl", files[1].Content)
+
+	// The order of the VIPs in the config doesn't matter
+	cfg.Network.Frontend.VIPs = []string{"10.0.0.5", "192.168.1.20"}
+	if got := Render(cfg)[0].Content; got != files[0].Content {
+		t.Errorf("expected the same block for reordered VIPs, got:\n%s", got)
+	}
+
+	plain := &config.Config{Mode: "dr", System: config.SystemConfig{TuningProfile: "minimal"}}
+	checkGolden(t, "sysctl_minimal", Render(plain)[1].Content)
+}
+
+func TestRenderSysctlData(t *testing.T) {
+	// Renderers only see their data, so tools can preview hypothetical settings
+	got := RenderSysctl(SysctlData{Mode: "nat", Profile: "custom", NAT: true, Settings: map[string]string{
+		"net.core.somaxconn": "1024",
+		"net.core.rmem_max":  "4096",
+	}})
+	want := "# lbctl managed sysctl configuration\n" +
+		"# Mode: nat\n" +
+		"# Profile: custom\n\n" +
+		"# Mode settings\n" +
+		"net.ipv4.ip_forward = 1\n" +
+		"net.ipv4.vs.conntrack = 1\n\n" +
+		"# Tuning profile settings\n" +
+		"net.core.rmem_max = 4096\n" +
+		"net.core.somaxconn = 1024\n"
+	if got != want {
+		t.Errorf("unexpected sysctl file:\n%s", got)
+	}
+}
@@ -0,0 +1,72 @@
+package system
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Notifier tells the service manager about the daemon's lifecycle.
+type Notifier interface {
+	// Notify sends newline-separated sd_notify assignments such as "READY=1"
+	Notify(state string) error
+	// WatchdogInterval is how often the service manager expects
+	// "WATCHDOG=1", or 0 when no watchdog is configured
+	WatchdogInterval() time.Duration
+}
+
+// SystemdNotifier implements the sd_notify protocol: datagrams to the unix
+// socket systemd passes in NOTIFY_SOCKET.
+type SystemdNotifier struct {
+	Socket   string
+	Watchdog time.Duration
+}
+
+// NewSystemdNotifier returns a notifier for the service manager that started
+// this process, or NopNotifier when it wasn't started by one (or not with
+// Type=notify).
+func NewSystemdNotifier() Notifier {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return NopNotifier{}
+	}
+	return &SystemdNotifier{Socket: socket, Watchdog: watchdogFromEnv()}
+}
+
+// watchdogFromEnv returns WATCHDOG_USEC when the watchdog is meant for this
+// process: WATCHDOG_PID is unset or names it.
+func watchdogFromEnv() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+func (n *SystemdNotifier) Notify(state string) error {
+	// A leading @ names an abstract socket, which net maps to a leading NUL
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: n.Socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to reach NOTIFY_SOCKET %s: %w", n.Socket, err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to notify %s: %w", n.Socket, err)
+	}
+	return nil
+}
+
+func (n *SystemdNotifier) WatchdogInterval() time.Duration {
+	return n.Watchdog
+}
+
+// NopNotifier drops every notification, for runs outside systemd
+type NopNotifier struct{}
+
+func (NopNotifier) Notify(string) error             { return nil }
+func (NopNotifier) WatchdogInterval() time.Duration { return 0 }
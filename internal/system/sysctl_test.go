@@ -12,9 +12,9 @@ import (
 func TestSysctlGeneration(t *testing.T) {
 	tmpDir := t.TempDir()
 	path := filepath.Join(tmpDir, "99-lbctl.conf")
-	
+
 	mgr := NewSysctlManager(path)
-	
+
 	// Test DR Mode + Minimal
 	cfg := &config.Config{
 		Mode: "dr",
@@ -22,17 +22,17 @@ func TestSysctlGeneration(t *testing.T) {
 			TuningProfile: "minimal",
 		},
 	}
-	
+
 	if err := mgr.Apply(cfg); err != nil {
 		t.Fatalf("Apply() failed: %v", err)
 	}
-	
+
 	content, err := os.ReadFile(path)
 	if err != nil {
 		t.Fatal(err)
 	}
 	s := string(content)
-	
+
 	if !strings.Contains(s, "Mode: dr") {
 		t.Error("Missing mode header")
 	}
@@ -42,45 +42,70 @@ func TestSysctlGeneration(t *testing.T) {
 	if strings.Contains(s, "net.ipv4.vs.conntrack") {
 		t.Error("conntrack should not be present in DR mode")
 	}
-	
+
 	// Check minimal profile settings
 	if !strings.Contains(s, "net.ipv4.vs.conn_tab_bits = 12") {
 		t.Error("Minimal profile setting missing")
 	}
-	
+
 	// Test NAT Mode + Aggressive
 	cfg.Mode = "nat"
 	cfg.System.TuningProfile = "aggressive"
-	
+
 	if err := mgr.Apply(cfg); err != nil {
 		t.Fatalf("Apply() failed: %v", err)
 	}
-	
+
 	content, err = os.ReadFile(path)
 	if err != nil {
 		t.Fatal(err)
 	}
 	s = string(content)
-	
+
 	if !strings.Contains(s, "Mode: nat") {
 		t.Error("Missing mode header")
 	}
 	if !strings.Contains(s, "net.ipv4.vs.conntrack = 1") {
 		t.Error("Missing conntrack in NAT mode")
 	}
-	
+
 	// Check aggressive profile settings
 	if !strings.Contains(s, "net.ipv4.vs.conn_tab_bits = 20") {
 		t.Error("Aggressive profile setting missing")
 	}
 }
 
+func TestSysctlGenerationTun(t *testing.T) {
+	cfg := &config.Config{Mode: "tun"}
+	s := RenderSysctl(SysctlDataFromConfig(cfg))
+	for _, want := range []string{"ipip", "net.ipv4.conf.all.rp_filter = 2", "net.ipv4.conf.default.rp_filter = 2"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("TUN mode missing %q:\n%s", want, s)
+		}
+	}
+	if strings.Contains(s, "net.ipv4.vs.conntrack") {
+		t.Error("conntrack should not be present in TUN mode")
+	}
+
+	// A single tunnelled backend in DR mode needs the same settings
+	cfg = &config.Config{Mode: "dr", Services: []config.Service{{Backends: []config.Backend{
+		{Address: "10.0.0.1"},
+		{Address: "10.0.0.2", Forward: "tun"},
+	}}}}
+	if s := RenderSysctl(SysctlDataFromConfig(cfg)); !strings.Contains(s, "net.ipv4.conf.all.rp_filter = 2") {
+		t.Errorf("DR mode with a TUN backend missing rp_filter:\n%s", s)
+	}
+	if s := RenderSysctl(SysctlDataFromConfig(&config.Config{Mode: "dr"})); strings.Contains(s, "rp_filter") {
+		t.Errorf("DR mode should leave rp_filter alone:\n%s", s)
+	}
+}
+
 func TestGetTuningProfile(t *testing.T) {
 	p := GetTuningProfile("minimal")
 	if p["net.ipv4.vs.conn_tab_bits"] != "12" {
 		t.Error("Expected minimal profile")
 	}
-	
+
 	p = GetTuningProfile("unknown")
 	if p["net.ipv4.vs.conn_tab_bits"] != "18" { // Balanced default
 		t.Error("Expected balanced profile for unknown")
@@ -2,6 +2,8 @@ package system
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -44,35 +46,35 @@ func TestDoctor(t *testing.T) {
 			"192.168.1.100": true,
 		},
 	}
-	
+
 	doctor := NewDoctor(mockNM)
-	
+
 	cfg := &config.Config{
 		Network: config.NetworkConfig{
 			Frontend: config.InterfaceConfig{Interface: "eth0", VIP: "192.168.1.100"},
 			Backend:  config.InterfaceConfig{Interface: "eth1"},
 		},
 	}
-	
+
 	results, err := doctor.RunChecks(cfg)
 	if err != nil {
 		t.Fatalf("RunChecks failed: %v", err)
 	}
-	
+
 	// Expect Frontend UP, Backend UP, VIP Present
 	checkMap := make(map[string]CheckResult)
 	for _, res := range results {
 		checkMap[res.Name] = res
 	}
-	
+
 	if !checkMap["Frontend Interface"].Passed {
 		t.Error("Frontend Interface should pass")
 	}
-	
+
 	if !checkMap["Backend Interface"].Passed {
 		t.Error("Backend Interface should pass")
 	}
-	
+
 	if !checkMap["VIP Check"].Passed {
 		t.Error("VIP Check should pass")
 	}
@@ -88,30 +90,30 @@ func TestDoctorFailures(t *testing.T) {
 		},
 		VIPs: map[string]bool{},
 	}
-	
+
 	doctor := NewDoctor(mockNM)
-	
+
 	cfg := &config.Config{
 		Network: config.NetworkConfig{
 			Frontend: config.InterfaceConfig{Interface: "eth0", VIP: "192.168.1.100"},
 		},
 	}
-	
+
 	results, err := doctor.RunChecks(cfg)
 	if err != nil {
 		t.Fatalf("RunChecks failed: %v", err)
 	}
-	
+
 	checkMap := make(map[string]CheckResult)
 	for _, res := range results {
 		checkMap[res.Name] = res
 	}
-	
+
 	if checkMap["Frontend Interface"].Passed {
 		t.Error("Frontend Interface should fail (Down)")
 	}
-	
-	// VIP not present should still pass check logic (just status report), 
+
+	// VIP not present should still pass check logic (just status report),
 	// based on my implementation
 	if !checkMap["VIP Check"].Passed {
 		t.Error("VIP Check should pass (informational)")
@@ -125,18 +127,134 @@ func TestDoctorInterfaceMissing(t *testing.T) {
 	mockNM := &MockNetworkManager{
 		Interfaces: map[string]bool{},
 	}
-	
+
 	doctor := NewDoctor(mockNM)
-	
+
 	cfg := &config.Config{
 		Network: config.NetworkConfig{
 			Frontend: config.InterfaceConfig{Interface: "missing0"},
 		},
 	}
-	
+
 	results, _ := doctor.RunChecks(cfg)
-	
+
 	if results[0].Passed {
 		t.Error("Missing interface should fail")
 	}
 }
+
+func TestDoctorPortRange(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "net/ipv4"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "net/ipv4/ip_local_port_range"), []byte("32768\t33767\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	doctor := NewDoctor(&MockNetworkManager{Interfaces: map[string]bool{"eth0": true}})
+	doctor.sysctlDir = dir
+
+	backends := make([]config.Backend, 5)
+	cfg := &config.Config{
+		Network: config.NetworkConfig{Frontend: config.InterfaceConfig{Interface: "eth0"}},
+		Services: []config.Service{{
+			Name:     "web",
+			Backends: backends,
+			Health:   config.HealthCheck{Enabled: true, Type: "tcp", IntervalMS: 1000},
+		}},
+	}
+	find := func() (CheckResult, bool) {
+		results, err := doctor.RunChecks(cfg)
+		if err != nil {
+			t.Fatalf("RunChecks failed: %v", err)
+		}
+		for _, res := range results {
+			if res.Name == "Health Check Source Ports" {
+				return res, true
+			}
+		}
+		return CheckResult{}, false
+	}
+
+	// 5 backends * 60 checks/min = 300 ports out of 1000
+	res, ok := find()
+	if !ok || !res.Passed {
+		t.Fatalf("expected port range check to pass, got %+v", res)
+	}
+	if !strings.Contains(res.Message, "32768-33767") || !strings.Contains(res.Message, "~300") {
+		t.Errorf("unexpected message: %s", res.Message)
+	}
+
+	// 5 backends * 600 checks/min = 3000 ports out of 1000
+	cfg.Services[0].Health.IntervalMS = 100
+	if res, _ := find(); res.Passed {
+		t.Fatalf("expected port range check to fail, got %+v", res)
+	}
+
+	// The configured source range replaces the kernel's
+	cfg.Daemon.Health = config.DaemonHealthConfig{SourcePortMin: 40000, SourcePortMax: 49999}
+	if res, _ := find(); !res.Passed || !strings.Contains(res.Message, "40000-49999") {
+		t.Fatalf("expected configured range to pass, got %+v", res)
+	}
+
+	// Resetting checks hold no ports
+	cfg.Services[0].Health.TCPReset = true
+	if res, ok := find(); ok {
+		t.Fatalf("expected no port range check for resetting checks, got %+v", res)
+	}
+}
+
+func TestNftMasquerade(t *testing.T) {
+	cfg := &config.Config{
+		Mode: "nat",
+		Network: config.NetworkConfig{
+			Frontend: config.InterfaceConfig{Interface: "eth0"},
+			Backend:  config.InterfaceConfig{Interface: "eth1"},
+		},
+	}
+
+	var gotStdin, gotCmd string
+	m := &NftMasquerade{Run: func(stdin string, name string, args ...string) error {
+		gotStdin = stdin
+		gotCmd = name + " " + strings.Join(args, " ")
+		return nil
+	}}
+	if err := m.Apply(cfg); err != nil {
+		t.Fatalf("Apply() failed: %v", err)
+	}
+	if gotCmd != "nft -f -" {
+		t.Errorf("unexpected command: %q", gotCmd)
+	}
+	for _, want := range []string{
+		"delete table ip lbctl\n",
+		"type nat hook postrouting priority srcnat;",
+		`iifname "eth1" oifname "eth0" masquerade`,
+	} {
+		if !strings.Contains(gotStdin, want) {
+			t.Errorf("NAT ruleset missing %q:\n%s", want, gotStdin)
+		}
+	}
+
+	// Leaving NAT mode removes the table and adds nothing back.
+	cfg.Mode = "dr"
+	if err := m.Apply(cfg); err != nil {
+		t.Fatalf("Apply() failed: %v", err)
+	}
+	if strings.Contains(gotStdin, "masquerade") || !strings.HasSuffix(gotStdin, "delete table ip lbctl\n") {
+		t.Errorf("expected DR ruleset to only remove the table:\n%s", gotStdin)
+	}
+
+	// A single NAT backend in a DR config still needs the rule
+	cfg.Services = []config.Service{{Name: "web", Backends: []config.Backend{{Address: "10.0.0.1", Forward: "nat"}}}}
+	if err := m.Apply(cfg); err != nil {
+		t.Fatalf("Apply() failed: %v", err)
+	}
+	if !strings.Contains(gotStdin, "masquerade") {
+		t.Errorf("expected masquerade for a NAT backend:\n%s", gotStdin)
+	}
+
+	m.Run = func(string, string, ...string) error { return fmt.Errorf("nft: not found") }
+	if err := m.Apply(cfg); err == nil {
+		t.Error("expected nft failure to be returned")
+	}
+}
@@ -0,0 +1,172 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+)
+
+const (
+	DefaultBinaryPath   = "/usr/local/bin/lbctl"
+	DefaultConfigPath   = "/etc/lbctl/config.yaml"
+	DefaultStateDir     = "/var/lib/lbctl"
+	SystemdUnitPath     = "/etc/systemd/system/lbctl.service"
+	SystemdTmpfilesPath = "/etc/tmpfiles.d/lbctl.conf"
+)
+
+// SystemdUnitOptions controls the generated lbctl.service unit
+type SystemdUnitOptions struct {
+	BinaryPath string
+	ConfigPath string
+}
+
+// SystemdInstaller writes the unit and tmpfiles snippet for the daemon
+type SystemdInstaller struct {
+	UnitPath     string
+	TmpfilesPath string
+}
+
+func NewSystemdInstaller() *SystemdInstaller {
+	return &SystemdInstaller{
+		UnitPath:     SystemdUnitPath,
+		TmpfilesPath: SystemdTmpfilesPath,
+	}
+}
+
+// Install writes both files. The caller is expected to run
+// `systemctl daemon-reload` and `systemd-tmpfiles --create` afterwards.
+func (i *SystemdInstaller) Install(cfg *config.Config, opts SystemdUnitOptions) error {
+	files := []struct {
+		path    string
+		content string
+	}{
+		{i.UnitPath, GenerateSystemdUnit(cfg, opts)},
+		{i.TmpfilesPath, GenerateTmpfiles(cfg)},
+	}
+	for _, f := range files {
+		if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(f.path, []byte(f.content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.path, err)
+		}
+	}
+	return nil
+}
+
+// GenerateSystemdUnit renders a hardened unit whose writable paths are derived
+// from the same config the daemon runs with.
+func GenerateSystemdUnit(cfg *config.Config, opts SystemdUnitOptions) string {
+	binary := opts.BinaryPath
+	if binary == "" {
+		binary = DefaultBinaryPath
+	}
+	configPath := opts.ConfigPath
+	if configPath == "" {
+		configPath = DefaultConfigPath
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("[Unit]\n")
+	sb.WriteString("Description=lbctl IPVS Load Balancer Controller\n")
+	// Ordering after frr.service means lbctl is stopped before FRR on shutdown,
+	// so IPVS is torn down while VRRP still owns the VIP.
+	sb.WriteString("After=network-online.target frr.service\n")
+	sb.WriteString("Wants=network-online.target frr.service\n")
+	sb.WriteString("\n")
+
+	sb.WriteString("[Service]\n")
+	// The daemon reports READY=1 once IPVS matches its startup role and pings
+	// the watchdog from its main loop, so a hung engine is restarted.
+	sb.WriteString("Type=notify\n")
+	sb.WriteString("NotifyAccess=main\n")
+	sb.WriteString("WatchdogSec=30\n")
+	sb.WriteString(fmt.Sprintf("ExecStart=%s apply --config %s --daemon\n", binary, configPath))
+	sb.WriteString("ExecReload=/bin/kill -HUP $MAINPID\n")
+	sb.WriteString("KillSignal=SIGTERM\n")
+	sb.WriteString("TimeoutStopSec=30\n")
+	sb.WriteString("Restart=on-failure\n")
+	sb.WriteString("RestartSec=5\n")
+	sb.WriteString("\n")
+
+	// IPVS and VIP checks go through netlink and need CAP_NET_ADMIN; CAP_NET_RAW
+	// covers raw-socket probes. Without CAP_DAC_OVERRIDE, root can't write the
+	// frr-owned /etc/frr/frr.conf or reach FRR's vty sockets for vtysh, and
+	// supplementary frr groups would stop the unit on hosts without FRR.
+	// /proc/sys stays writable for sysctl tuning.
+	sb.WriteString("CapabilityBoundingSet=CAP_NET_ADMIN CAP_NET_RAW CAP_DAC_OVERRIDE\n")
+	sb.WriteString("AmbientCapabilities=CAP_NET_ADMIN CAP_NET_RAW CAP_DAC_OVERRIDE\n")
+	sb.WriteString("NoNewPrivileges=yes\n")
+	sb.WriteString("ProtectSystem=strict\n")
+	sb.WriteString("ProtectHome=yes\n")
+	sb.WriteString("PrivateTmp=yes\n")
+	sb.WriteString("ProtectControlGroups=yes\n")
+	sb.WriteString("RestrictAddressFamilies=AF_UNIX AF_INET AF_INET6 AF_NETLINK\n")
+	sb.WriteString(fmt.Sprintf("ReadWritePaths=%s\n", strings.Join(writablePaths(cfg, configPath), " ")))
+	sb.WriteString("\n")
+
+	sb.WriteString("[Install]\n")
+	sb.WriteString("WantedBy=multi-user.target\n")
+
+	return sb.String()
+}
+
+// GenerateTmpfiles renders a tmpfiles.d snippet creating the state directories
+func GenerateTmpfiles(cfg *config.Config) string {
+	stateDir := StateDir(cfg)
+
+	var sb strings.Builder
+	sb.WriteString("# lbctl managed tmpfiles configuration\n")
+	sb.WriteString(fmt.Sprintf("d %s 0750 root root -\n", stateDir))
+	sb.WriteString(fmt.Sprintf("d %s 0750 root root -\n", FRRBackupDir))
+	return sb.String()
+}
+
+// StateDir returns system.state_dir, or DefaultStateDir when it is unset
+func StateDir(cfg *config.Config) string {
+	if cfg != nil && cfg.System.StateDir != "" {
+		return cfg.System.StateDir
+	}
+	return DefaultStateDir
+}
+
+// writablePaths lists the directories the daemon writes to: the state dir,
+// FRR and sysctl config, and the include dir, where it consumes service reload
+// requests.
+func writablePaths(cfg *config.Config, configPath string) []string {
+	set := map[string]bool{
+		StateDir(cfg): true,
+		FRRBackupDir:  true,
+	}
+	if dir := config.IncludeDir(configPath, cfg); dir != "" {
+		set[dir] = true
+	}
+	if cfg != nil {
+		if cfg.System.FRRConfig != "" {
+			set[filepath.Dir(cfg.System.FRRConfig)] = true
+		}
+		if cfg.System.SysctlFile != "" {
+			set[filepath.Dir(cfg.System.SysctlFile)] = true
+		}
+	}
+
+	// Drop paths already covered by a parent entry
+	var paths []string
+	for p := range set {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var out []string
+	for _, p := range paths {
+		if len(out) > 0 && strings.HasPrefix(p, out[len(out)-1]+"/") {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
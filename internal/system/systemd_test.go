@@ -0,0 +1,127 @@
+package system
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+)
+
+func defaultSystemConfig() *config.Config {
+	return &config.Config{
+		Include: "/etc/lbctl/config.d/*.yaml",
+		System: config.SystemConfig{
+			StateDir:   "/var/lib/lbctl",
+			FRRConfig:  "/etc/frr/frr.conf",
+			SysctlFile: "/etc/sysctl.d/99-lbctl.conf",
+		},
+	}
+}
+
+func TestSystemdUnitMatchesDist(t *testing.T) {
+	want, err := os.ReadFile(filepath.Join("..", "..", "dist", "lbctl.service"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := GenerateSystemdUnit(defaultSystemConfig(), SystemdUnitOptions{})
+	if got != string(want) {
+		t.Errorf("dist/lbctl.service is out of sync with GenerateSystemdUnit():\n%s", got)
+	}
+
+	want, err = os.ReadFile(filepath.Join("..", "..", "dist", "lbctl.tmpfiles.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := GenerateTmpfiles(defaultSystemConfig()); got != string(want) {
+		t.Errorf("dist/lbctl.tmpfiles.conf is out of sync with GenerateTmpfiles():\n%s", got)
+	}
+}
+
+func TestSystemdUnitOptions(t *testing.T) {
+	cfg := defaultSystemConfig()
+	cfg.System.StateDir = "/srv/lbctl"
+	cfg.Include = "services/*.yaml"
+
+	unit := GenerateSystemdUnit(cfg, SystemdUnitOptions{
+		BinaryPath: "/opt/lbctl/bin/lbctl",
+		ConfigPath: "/opt/lbctl/config.yaml",
+	})
+
+	for _, want := range []string{
+		"ExecStart=/opt/lbctl/bin/lbctl apply --config /opt/lbctl/config.yaml --daemon\n",
+		// The include dir is relative to the config file
+		"ReadWritePaths=/etc/frr /etc/sysctl.d /opt/lbctl/services /srv/lbctl /var/lib/lbctl/backups\n",
+		// The daemon sends READY=1 and pings the watchdog
+		"Type=notify\nNotifyAccess=main\nWatchdogSec=30\n",
+		// Root writes the frr-owned frr.conf and runs vtysh against FRR's sockets
+		"CapabilityBoundingSet=CAP_NET_ADMIN CAP_NET_RAW CAP_DAC_OVERRIDE\n",
+		"AmbientCapabilities=CAP_NET_ADMIN CAP_NET_RAW CAP_DAC_OVERRIDE\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit missing %q:\n%s", want, unit)
+		}
+	}
+}
+
+func TestSystemdNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", path)
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	n := NewSystemdNotifier()
+	if got := n.WatchdogInterval(); got != 30*time.Second {
+		t.Errorf("WatchdogInterval() = %s, want 30s", got)
+	}
+	if err := n.Notify("READY=1\nSTATUS=active"); err != nil {
+		t.Fatalf("Notify() failed: %v", err)
+	}
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	nr, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got := string(buf[:nr]); got != "READY=1\nSTATUS=active" {
+		t.Errorf("received %q", got)
+	}
+
+	// The watchdog belongs to another process
+	t.Setenv("WATCHDOG_PID", "1")
+	if got := NewSystemdNotifier().WatchdogInterval(); got != 0 {
+		t.Errorf("expected no watchdog for another pid, got %s", got)
+	}
+
+	t.Setenv("NOTIFY_SOCKET", "")
+	if _, ok := NewSystemdNotifier().(NopNotifier); !ok {
+		t.Error("expected NopNotifier without NOTIFY_SOCKET")
+	}
+}
+
+func TestSystemdInstaller(t *testing.T) {
+	tmpDir := t.TempDir()
+	inst := &SystemdInstaller{
+		UnitPath:     filepath.Join(tmpDir, "systemd", "lbctl.service"),
+		TmpfilesPath: filepath.Join(tmpDir, "tmpfiles.d", "lbctl.conf"),
+	}
+
+	if err := inst.Install(defaultSystemConfig(), SystemdUnitOptions{}); err != nil {
+		t.Fatalf("Install() failed: %v", err)
+	}
+
+	for _, p := range []string{inst.UnitPath, inst.TmpfilesPath} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("expected %s to be written: %v", p, err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/malindarathnayake/LibraFlux/internal/config"
+)
+
+// VRRP router states, as FRR names them
+const (
+	VRRPStateInitialize = "Initialize"
+	VRRPStateMaster     = "Master"
+	VRRPStateBackup     = "Backup"
+)
+
+// VRRPStateReader reports the node's VRRP state for the configured instance
+// from the routing daemon.
+type VRRPStateReader interface {
+	VRRPState(cfg *config.Config) (string, error)
+}
+
+// VtyshStateReader reads the VRRP state with `show vrrp ... json` through
+// vtysh.
+type VtyshStateReader struct {
+	Output func(name string, args ...string) ([]byte, error)
+}
+
+func NewVtyshStateReader() *VtyshStateReader {
+	return &VtyshStateReader{
+		Output: func(name string, args ...string) ([]byte, error) {
+			out, err := exec.Command(name, args...).Output()
+			if err != nil {
+				if ee, ok := err.(*exec.ExitError); ok {
+					return nil, fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(string(ee.Stderr)))
+				}
+				return nil, fmt.Errorf("%s failed: %w", name, err)
+			}
+			return out, nil
+		},
+	}
+}
+
+// frrVRRPRouter is the part of one `show vrrp json` entry lbctl reads. FRR
+// runs a separate router per address family under the same VRID.
+type frrVRRPRouter struct {
+	Interface string `json:"interface"`
+	VRID      int    `json:"vrid"`
+	V4        struct {
+		Status string `json:"status"`
+	} `json:"v4"`
+	V6 struct {
+		Status string `json:"status"`
+	} `json:"v6"`
+}
+
+// VRRPState returns the state of the router for the frontend VIP's address
+// family on the frontend interface.
+func (r *VtyshStateReader) VRRPState(cfg *config.Config) (string, error) {
+	iface := cfg.Network.Frontend.Interface
+	vrid := cfg.VRRP.VRID
+	out, err := r.Output("vtysh", "-c", fmt.Sprintf("show vrrp interface %s %d json", iface, vrid))
+	if err != nil {
+		return "", err
+	}
+	return parseVRRPState(out, iface, vrid, cfg.Network.Frontend.VIP)
+}
+
+func parseVRRPState(out []byte, iface string, vrid int, vip string) (string, error) {
+	var routers []frrVRRPRouter
+	if err := json.Unmarshal(out, &routers); err != nil {
+		return "", fmt.Errorf("failed to parse vtysh output: %w", err)
+	}
+	for _, r := range routers {
+		if r.Interface != iface || r.VRID != vrid {
+			continue
+		}
+		status := r.V4.Status
+		if ip := net.ParseIP(vip); ip != nil && ip.To4() == nil {
+			status = r.V6.Status
+		}
+		switch status {
+		case VRRPStateInitialize, VRRPStateMaster, VRRPStateBackup:
+			return status, nil
+		default:
+			return "", fmt.Errorf("unknown VRRP state %q for %s vrid %d", status, iface, vrid)
+		}
+	}
+	return "", fmt.Errorf("no VRRP router for %s vrid %d in FRR", iface, vrid)
+}